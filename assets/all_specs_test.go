@@ -0,0 +1,13 @@
+package assets_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(FSSpec)
+	r.AddSpec(PackSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,300 @@
+// Package assets provides a virtual filesystem for game data, so that a game
+// can load its data from loose directories while it is being worked on and
+// from a single archive when it ships, without the loading code caring
+// which.  An FS is an fs.FS, so it can be given to sprite.LoadSpriteFS(),
+// sound.OpenStreamFS(), or anything else that reads from an fs.FS, and
+// fonts can be read with text.LoadDictionary() from a file that it opens.
+package assets
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An FS is made up of other filesystems mounted at directories within it,
+// like directories on disk, zip archives, and embed.FSs.  It is safe to use
+// from any goroutine.
+type FS struct {
+	mutex  sync.RWMutex
+	mounts []*mount
+}
+
+type mount struct {
+	// Where in the FS this is mounted, "." for the root.
+	at     string
+	fsys   fs.FS
+	closer io.Closer
+}
+
+func MakeFS() *FS {
+	return &FS{}
+}
+
+// Mounts fsys at dir, so that dir/name in v is name in fsys.  dir can be "."
+// to mount at the root.  Files in later mounts hide files with the same name
+// in earlier ones, which is handy for patches and mods.  Directories that are
+// in more than one mount have everything from all of them.
+func (v *FS) Mount(dir string, fsys fs.FS) {
+	v.mount(dir, fsys, nil)
+}
+
+func (v *FS) mount(dir string, fsys fs.FS, closer io.Closer) {
+	if !fs.ValidPath(dir) {
+		panic(fmt.Sprintf("Cannot mount at '%s'.", dir))
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.mounts = append(v.mounts, &mount{at: dir, fsys: fsys, closer: closer})
+}
+
+// Mounts the directory at path on disk at dir.
+func (v *FS) MountDir(dir, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory.", path)
+	}
+	v.Mount(dir, os.DirFS(path))
+	return nil
+}
+
+// Mounts the zip archive at path on disk at dir.  The archive stays open
+// until v is closed.
+func (v *FS) MountZip(dir, path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	v.mount(dir, r, r)
+	return nil
+}
+
+// Unmounts everything and closes any archives that were mounted.
+func (v *FS) Close() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	var err error
+	for _, m := range v.mounts {
+		if m.closer != nil {
+			if cerr := m.closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	v.mounts = nil
+	return err
+}
+
+// Returns name relative to where m is mounted, ok is false if name isn't in
+// m.
+func (m *mount) rel(name string) (rel string, ok bool) {
+	switch {
+	case m.at == ".":
+		return name, true
+	case name == m.at:
+		return ".", true
+	case strings.HasPrefix(name, m.at+"/"):
+		return name[len(m.at)+1:], true
+	}
+	return "", false
+}
+
+// If m is mounted somewhere below the directory name, returns the entry in
+// name that leads to it.
+func (m *mount) child(name string) (child string, ok bool) {
+	rest := m.at
+	switch {
+	case m.at == ".":
+		return "", false
+	case name == ".":
+	case strings.HasPrefix(m.at, name+"/"):
+		rest = m.at[len(name)+1:]
+	default:
+		return "", false
+	}
+	if i := strings.Index(rest, "/"); i != -1 {
+		return rest[:i], true
+	}
+	return rest, true
+}
+
+func (v *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	for i := len(v.mounts) - 1; i >= 0; i-- {
+		rel, ok := v.mounts[i].rel(name)
+		if !ok {
+			continue
+		}
+		f, err := v.mounts[i].fsys.Open(rel)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if !info.IsDir() {
+			return f, nil
+		}
+		// Directories can be spread across several mounts, so they're always
+		// read through v.
+		f.Close()
+		return v.openDir(name, rename(info, name))
+	}
+	for _, m := range v.mounts {
+		if _, ok := m.child(name); ok {
+			return v.openDir(name, dirInfo(baseName(name)))
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Lists the directory name with everything from every mount that has it,
+// sorted by name.
+func (v *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.readDir(name)
+}
+
+// Does the work of ReadDir(), the mutex must be held.
+func (v *FS) readDir(name string) ([]fs.DirEntry, error) {
+	found := false
+	entries := make(map[string]fs.DirEntry)
+	for _, m := range v.mounts {
+		if rel, ok := m.rel(name); ok {
+			list, err := fs.ReadDir(m.fsys, rel)
+			if err == nil {
+				found = true
+				for _, entry := range list {
+					entries[entry.Name()] = entry
+				}
+			}
+		}
+		if child, ok := m.child(name); ok {
+			found = true
+			if m.at == joinName(name, child) {
+				// This is where m is mounted, so it hides whatever was here.
+				if info, err := fs.Stat(m.fsys, "."); err == nil {
+					entries[child] = fs.FileInfoToDirEntry(rename(info, child))
+					continue
+				}
+			}
+			if entry, ok := entries[child]; !ok || !entry.IsDir() {
+				entries[child] = fs.FileInfoToDirEntry(dirInfo(child))
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+func (v *FS) openDir(name string, info fs.FileInfo) (fs.File, error) {
+	entries, err := v.readDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &dir{info: info, entries: entries}, nil
+}
+
+func joinName(dir, name string) string {
+	if dir == "." {
+		return name
+	}
+	return dir + "/" + name
+}
+
+func baseName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// Gives info the last element of name as its name, since the root of a mount
+// usually calls itself ".".
+func rename(info fs.FileInfo, name string) fs.FileInfo {
+	if base := baseName(name); info.Name() != base {
+		return renamedInfo{info, base}
+	}
+	return info
+}
+
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (ri renamedInfo) Name() string {
+	return ri.name
+}
+
+// The FileInfo for directories that don't have one of their own, like ones
+// that only exist because something is mounted inside of them.
+type dirInfo string
+
+func (di dirInfo) Name() string       { return string(di) }
+func (di dirInfo) Size() int64        { return 0 }
+func (di dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (di dirInfo) ModTime() time.Time { return time.Time{} }
+func (di dirInfo) IsDir() bool        { return true }
+func (di dirInfo) Sys() interface{}   { return nil }
+
+type dir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fmt.Errorf("Is a directory.")}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,120 @@
+package assets_test
+
+import (
+	"archive/zip"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/assets"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing/fstest"
+)
+
+func writeZip(path string, files map[string]string) {
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, contents := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		fw.Write([]byte(contents))
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+}
+
+func readFile(fsys fs.FS, name string) string {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func names(entries []fs.DirEntry) []string {
+	var n []string
+	for _, entry := range entries {
+		n = append(n, entry.Name())
+	}
+	return n
+}
+
+func FSSpec(c gospec.Context) {
+	tmp, err := os.MkdirTemp("", "assets")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tmp)
+	disk := filepath.Join(tmp, "disk")
+	os.MkdirAll(filepath.Join(disk, "sprites", "guy", "0"), 0755)
+	os.WriteFile(filepath.Join(disk, "sprites", "guy", "anim.xgml"), []byte("disk anim"), 0644)
+	os.WriteFile(filepath.Join(disk, "sprites", "guy", "0", "walk.png"), []byte("disk walk"), 0644)
+	os.WriteFile(filepath.Join(disk, "readme.txt"), []byte("disk readme"), 0644)
+	pack := filepath.Join(tmp, "pack.zip")
+	writeZip(pack, map[string]string{
+		"guy/anim.xgml":    "zip anim",
+		"girl/anim.xgml":   "zip girl",
+		"girl/0/stand.png": "zip stand",
+		"boom.wav":         "zip boom",
+	})
+	embedded := fstest.MapFS{
+		"shaders/glow.frag": &fstest.MapFile{Data: []byte("embedded glow")},
+	}
+
+	v := assets.MakeFS()
+	defer v.Close()
+	c.Assume(v.MountDir(".", disk), Equals, nil)
+	c.Assume(v.MountZip("sprites", pack), Equals, nil)
+	v.Mount("data/builtin", embedded)
+
+	c.Specify("Files can be read from every mount", func() {
+		c.Expect(readFile(v, "readme.txt"), Equals, "disk readme")
+		c.Expect(readFile(v, "sprites/guy/0/walk.png"), Equals, "disk walk")
+		c.Expect(readFile(v, "sprites/girl/0/stand.png"), Equals, "zip stand")
+		c.Expect(readFile(v, "sprites/boom.wav"), Equals, "zip boom")
+		c.Expect(readFile(v, "data/builtin/shaders/glow.frag"), Equals, "embedded glow")
+		_, err := v.Open("sprites/nobody/anim.xgml")
+		c.Expect(err, Not(Equals), nil)
+	})
+
+	c.Specify("Later mounts hide files in earlier ones", func() {
+		c.Expect(readFile(v, "sprites/guy/anim.xgml"), Equals, "zip anim")
+	})
+
+	c.Specify("Directories list everything from every mount", func() {
+		entries, err := v.ReadDir(".")
+		c.Assume(err, Equals, nil)
+		c.Expect(names(entries), ContainsInOrder, []string{"data", "readme.txt", "sprites"})
+		entries, err = v.ReadDir("sprites")
+		c.Assume(err, Equals, nil)
+		c.Expect(names(entries), ContainsInOrder, []string{"boom.wav", "girl", "guy"})
+		entries, err = v.ReadDir("sprites/guy")
+		c.Assume(err, Equals, nil)
+		c.Expect(names(entries), ContainsInOrder, []string{"0", "anim.xgml"})
+	})
+
+	c.Specify("Sub-directories work like their own filesystem", func() {
+		guy, err := fs.Sub(v, "sprites/guy")
+		c.Assume(err, Equals, nil)
+		c.Expect(readFile(guy, "anim.xgml"), Equals, "zip anim")
+		c.Expect(readFile(guy, "0/walk.png"), Equals, "disk walk")
+	})
+
+	c.Specify("It behaves like any other fs.FS", func() {
+		err := fstest.TestFS(v, "readme.txt", "sprites/guy/anim.xgml", "sprites/girl/0/stand.png", "data/builtin/shaders/glow.frag")
+		c.Expect(err, Equals, nil)
+	})
+
+	c.Specify("Closing unmounts everything", func() {
+		c.Expect(v.Close(), Equals, nil)
+		_, err := v.Open("readme.txt")
+		c.Expect(err, Not(Equals), nil)
+	})
+}
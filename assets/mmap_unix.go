@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package assets
+
+import (
+	"os"
+	"syscall"
+)
+
+// Memory maps all of f read-only, the mapping stays valid after f is closed
+// until unmap is called.
+func mapFile(f *os.File) (data []byte, unmap func() error, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	data, err = syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
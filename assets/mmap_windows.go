@@ -0,0 +1,16 @@
+package assets
+
+import (
+	"io"
+	"os"
+)
+
+// Windows needs a file mapping object to memory map a file, for now the whole
+// file is just read in.
+func mapFile(f *os.File) ([]byte, func() error, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
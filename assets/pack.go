@@ -0,0 +1,368 @@
+package assets
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A pack file starts with packMagic and packVersion, then the number of
+// files in it and an entry for each one, then all of the files' data.
+// Everything is little endian:
+//
+//	header: "GLPK" uint32(version) uint32(n) entry*n
+//	entry:  uint16(len) name uint8(method) uint64(offset) uint64(stored size)
+//	        uint64(size) uint64(hash) int64(mod time)
+//
+// The hash is the 64 bit FNV-1a hash of the uncompressed data.  Files with
+// the same contents share their data.
+const packMagic = "GLPK"
+const packVersion uint32 = 1
+
+const (
+	packStored uint8 = iota
+	packDeflated
+)
+
+type packEntry struct {
+	name     string
+	method   uint8
+	offset   uint64
+	stored   uint64
+	size     uint64
+	hash     uint64
+	mod_time int64
+}
+
+func (e *packEntry) headerSize() int {
+	return 2 + len(e.name) + 1 + 5*8
+}
+
+// Packs every file in dir on disk, other than hidden ones, into a single
+// file at out that OpenArchive() or FS.MountPack() can read.  Files are
+// compressed unless that doesn't make them any smaller, like pngs usually.
+// out is written to a temporary file first and then renamed, so a failed
+// pack never leaves a partial file behind.
+func Pack(dir, out string) error {
+	abs_out, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	var entries []*packEntry
+	var paths []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Don't pack an old copy of the pack if it's being written into dir.
+		if abs, err := filepath.Abs(path); err == nil && abs == abs_out {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if len(name) > 0xffff {
+			return fmt.Errorf("Name is too long to pack: %s", name)
+		}
+		entries = append(entries, &packEntry{name: name, mod_time: info.ModTime().UnixNano()})
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(out), "."+filepath.Base(out)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = writePack(tmp, entries, paths)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), out)
+}
+
+func writePack(f *os.File, entries []*packEntry, paths []string) error {
+	offset := uint64(len(packMagic) + 8)
+	for _, e := range entries {
+		offset += uint64(e.headerSize())
+	}
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return err
+	}
+
+	// Where data with each hash and size was already written.
+	type content struct {
+		hash, size uint64
+	}
+	written := make(map[content]*packEntry)
+	var compressed bytes.Buffer
+	for i, e := range entries {
+		data, err := os.ReadFile(paths[i])
+		if err != nil {
+			return err
+		}
+		h := fnv.New64a()
+		h.Write(data)
+		e.hash = h.Sum64()
+		e.size = uint64(len(data))
+		if prev, ok := written[content{e.hash, e.size}]; ok {
+			e.method, e.offset, e.stored = prev.method, prev.offset, prev.stored
+			continue
+		}
+		compressed.Reset()
+		w, _ := flate.NewWriter(&compressed, flate.BestCompression)
+		w.Write(data)
+		w.Close()
+		if compressed.Len() < len(data) {
+			e.method = packDeflated
+			data = compressed.Bytes()
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		e.offset = offset
+		e.stored = uint64(len(data))
+		offset += e.stored
+		written[content{e.hash, e.size}] = e
+	}
+
+	var header bytes.Buffer
+	le := binary.LittleEndian
+	header.WriteString(packMagic)
+	binary.Write(&header, le, packVersion)
+	binary.Write(&header, le, uint32(len(entries)))
+	for _, e := range entries {
+		binary.Write(&header, le, uint16(len(e.name)))
+		header.WriteString(e.name)
+		header.WriteByte(e.method)
+		binary.Write(&header, le, []uint64{e.offset, e.stored, e.size, e.hash})
+		binary.Write(&header, le, e.mod_time)
+	}
+	_, err := f.WriteAt(header.Bytes(), 0)
+	return err
+}
+
+// An Archive is a pack file made by Pack().  The file is memory mapped where
+// that's supported, so opening it is cheap no matter how big it is and only
+// the parts that are read get loaded.  Archives are read-only and safe to
+// use from any goroutine.
+type Archive struct {
+	data  []byte
+	unmap func() error
+
+	files map[string]*packEntry
+	dirs  map[string][]fs.DirEntry
+}
+
+// Opens the pack file at path.  Files opened from the archive must not be
+// used after it is closed.
+func OpenArchive(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, unmap, err := mapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{data: data, unmap: unmap}
+	if err := a.readHeader(); err != nil {
+		unmap()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return a, nil
+}
+
+func (a *Archive) readHeader() error {
+	r := bytes.NewReader(a.data)
+	le := binary.LittleEndian
+	magic := make([]byte, len(packMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != packMagic {
+		return fmt.Errorf("Not a pack file.")
+	}
+	var version, n uint32
+	binary.Read(r, le, &version)
+	if version != packVersion {
+		return fmt.Errorf("Pack file is version %d, expected %d.", version, packVersion)
+	}
+	if err := binary.Read(r, le, &n); err != nil {
+		return fmt.Errorf("Pack file header is truncated.")
+	}
+	a.files = make(map[string]*packEntry)
+	dirs := map[string]map[string]fs.DirEntry{".": {}}
+	for i := uint32(0); i < n; i++ {
+		e := &packEntry{}
+		var length uint16
+		var fields [4]uint64
+		binary.Read(r, le, &length)
+		name := make([]byte, length)
+		io.ReadFull(r, name)
+		e.name = string(name)
+		e.method, _ = r.ReadByte()
+		binary.Read(r, le, fields[:])
+		if err := binary.Read(r, le, &e.mod_time); err != nil {
+			return fmt.Errorf("Pack file header is truncated.")
+		}
+		e.offset, e.stored, e.size, e.hash = fields[0], fields[1], fields[2], fields[3]
+		if !fs.ValidPath(e.name) || e.name == "." || e.offset+e.stored > uint64(len(a.data)) || e.offset+e.stored < e.offset {
+			return fmt.Errorf("Pack file has a bad entry for '%s'.", e.name)
+		}
+		a.files[e.name] = e
+
+		// Add the file and every directory above it to their parents.
+		child := fs.FileInfoToDirEntry(&packInfo{e})
+		for name := e.name; name != "."; {
+			parent := "."
+			if slash := strings.LastIndex(name, "/"); slash != -1 {
+				parent = name[:slash]
+			}
+			if dirs[parent] == nil {
+				dirs[parent] = make(map[string]fs.DirEntry)
+			}
+			dirs[parent][child.Name()] = child
+			name = parent
+			child = fs.FileInfoToDirEntry(dirInfo(baseName(parent)))
+		}
+	}
+	a.dirs = make(map[string][]fs.DirEntry)
+	for name, entries := range dirs {
+		if _, ok := a.files[name]; ok {
+			return fmt.Errorf("Pack file has '%s' as both a file and a directory.", name)
+		}
+		list := make([]fs.DirEntry, 0, len(entries))
+		for _, entry := range entries {
+			list = append(list, entry)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+		a.dirs[name] = list
+	}
+	return nil
+}
+
+func (a *Archive) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if entries, ok := a.dirs[name]; ok {
+		return &dir{info: dirInfo(baseName(name)), entries: entries}, nil
+	}
+	e, ok := a.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	var r io.Reader = bytes.NewReader(a.data[e.offset : e.offset+e.stored])
+	if e.method == packDeflated {
+		r = flate.NewReader(r)
+	}
+	return &packFile{entry: e, r: r, hash: fnv.New64a()}, nil
+}
+
+func (a *Archive) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, ok := a.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]fs.DirEntry(nil), entries...), nil
+}
+
+// Returns the hash of the contents of the file name, which changes whenever
+// the file does.
+func (a *Archive) Hash(name string) (uint64, bool) {
+	e, ok := a.files[name]
+	if !ok {
+		return 0, false
+	}
+	return e.hash, true
+}
+
+func (a *Archive) Close() error {
+	a.files = nil
+	a.dirs = nil
+	return a.unmap()
+}
+
+// Mounts the pack file at path on disk at dir.  The archive stays open until
+// v is closed.
+func (v *FS) MountPack(dir, path string) error {
+	a, err := OpenArchive(path)
+	if err != nil {
+		return err
+	}
+	v.mount(dir, a, a)
+	return nil
+}
+
+type packInfo struct {
+	entry *packEntry
+}
+
+func (pi *packInfo) Name() string       { return baseName(pi.entry.name) }
+func (pi *packInfo) Size() int64        { return int64(pi.entry.size) }
+func (pi *packInfo) Mode() fs.FileMode  { return 0444 }
+func (pi *packInfo) ModTime() time.Time { return time.Unix(0, pi.entry.mod_time) }
+func (pi *packInfo) IsDir() bool        { return false }
+func (pi *packInfo) Sys() interface{}   { return nil }
+
+// Reads a file from an archive, checking its hash at the end.
+type packFile struct {
+	entry *packEntry
+	r     io.Reader
+	hash  hash.Hash64
+	read  uint64
+}
+
+func (pf *packFile) Stat() (fs.FileInfo, error) {
+	return &packInfo{pf.entry}, nil
+}
+
+func (pf *packFile) Read(b []byte) (int, error) {
+	n, err := pf.r.Read(b)
+	pf.hash.Write(b[:n])
+	pf.read += uint64(n)
+	if err == io.EOF && (pf.read != pf.entry.size || pf.hash.Sum64() != pf.entry.hash) {
+		err = &fs.PathError{Op: "read", Path: pf.entry.name, Err: fmt.Errorf("File is corrupt.")}
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = &fs.PathError{Op: "read", Path: pf.entry.name, Err: fmt.Errorf("File is corrupt.")}
+	}
+	return n, err
+}
+
+func (pf *packFile) Close() error {
+	if c, ok := pf.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
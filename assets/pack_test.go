@@ -0,0 +1,110 @@
+package assets_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/assets"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing/fstest"
+	"time"
+)
+
+func PackSpec(c gospec.Context) {
+	tmp, err := os.MkdirTemp("", "assets")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tmp)
+	src := filepath.Join(tmp, "data")
+	os.MkdirAll(filepath.Join(src, "sprites", "guy", "0"), 0755)
+	os.MkdirAll(filepath.Join(src, ".svn"), 0755)
+	graph := strings.Repeat("node [ label \"walk\" ]\n", 100)
+	os.WriteFile(filepath.Join(src, "sprites", "guy", "anim.xgml"), []byte(graph), 0644)
+	os.WriteFile(filepath.Join(src, "sprites", "guy", "state.xgml"), []byte(graph), 0644)
+	os.WriteFile(filepath.Join(src, "sprites", "guy", "0", "walk.png"), []byte("\x89PNG"), 0644)
+	os.WriteFile(filepath.Join(src, "sprites", "guy", "0", "empty.png"), nil, 0644)
+	os.WriteFile(filepath.Join(src, ".hidden"), []byte("secret"), 0644)
+	os.WriteFile(filepath.Join(src, ".svn", "entries"), []byte("secret"), 0644)
+	when := time.Date(2012, 3, 4, 5, 6, 7, 0, time.UTC)
+	os.Chtimes(filepath.Join(src, "sprites", "guy", "anim.xgml"), when, when)
+
+	out := filepath.Join(tmp, "data.pack")
+	c.Assume(assets.Pack(src, out), Equals, nil)
+	a, err := assets.OpenArchive(out)
+	c.Assume(err, Equals, nil)
+	defer a.Close()
+
+	c.Specify("Packed files read back the same", func() {
+		c.Expect(readFile(a, "sprites/guy/anim.xgml"), Equals, graph)
+		c.Expect(readFile(a, "sprites/guy/0/walk.png"), Equals, "\x89PNG")
+		c.Expect(readFile(a, "sprites/guy/0/empty.png"), Equals, "")
+		info, err := fs.Stat(a, "sprites/guy/anim.xgml")
+		c.Assume(err, Equals, nil)
+		c.Expect(info.Size(), Equals, int64(len(graph)))
+		c.Expect(info.ModTime().Equal(when), Equals, true)
+	})
+
+	c.Specify("Hidden files aren't packed", func() {
+		entries, err := a.ReadDir(".")
+		c.Assume(err, Equals, nil)
+		c.Expect(names(entries), ContainsInOrder, []string{"sprites"})
+	})
+
+	c.Specify("Files are compressed and duplicates are only stored once", func() {
+		info, err := os.Stat(out)
+		c.Assume(err, Equals, nil)
+		c.Expect(info.Size() < int64(len(graph)), Equals, true)
+		anim, _ := a.Hash("sprites/guy/anim.xgml")
+		state, _ := a.Hash("sprites/guy/state.xgml")
+		walk, _ := a.Hash("sprites/guy/0/walk.png")
+		c.Expect(anim, Equals, state)
+		c.Expect(anim, Not(Equals), walk)
+		_, ok := a.Hash("sprites/guy/nothing.png")
+		c.Expect(ok, Equals, false)
+	})
+
+	c.Specify("Archives behave like any other fs.FS", func() {
+		err := fstest.TestFS(a, "sprites/guy/anim.xgml", "sprites/guy/0/walk.png", "sprites/guy/0/empty.png")
+		c.Expect(err, Equals, nil)
+	})
+
+	c.Specify("Packs can be mounted", func() {
+		v := assets.MakeFS()
+		defer v.Close()
+		c.Assume(v.MountPack("data", out), Equals, nil)
+		c.Expect(readFile(v, "data/sprites/guy/0/walk.png"), Equals, "\x89PNG")
+	})
+
+	c.Specify("Repacking into the same directory doesn't pack the old pack", func() {
+		inside := filepath.Join(src, "data.pack")
+		c.Assume(assets.Pack(src, inside), Equals, nil)
+		c.Assume(assets.Pack(src, inside), Equals, nil)
+		b, err := assets.OpenArchive(inside)
+		c.Assume(err, Equals, nil)
+		defer b.Close()
+		_, ok := b.Hash("data.pack")
+		c.Expect(ok, Equals, false)
+	})
+
+	c.Specify("Corrupt files are caught", func() {
+		data, _ := os.ReadFile(out)
+		i := strings.Index(string(data), "\x89PNG")
+		c.Assume(i, Not(Equals), -1)
+		data[i+1] = 'X'
+		bad := filepath.Join(tmp, "bad.pack")
+		os.WriteFile(bad, data, 0644)
+		b, err := assets.OpenArchive(bad)
+		c.Assume(err, Equals, nil)
+		defer b.Close()
+		_, err = fs.ReadFile(b, "sprites/guy/0/walk.png")
+		c.Expect(err, Not(Equals), nil)
+		c.Expect(readFile(b, "sprites/guy/anim.xgml"), Equals, graph)
+
+		os.WriteFile(bad, []byte("GLPK"), 0644)
+		_, err = assets.OpenArchive(bad)
+		c.Expect(err, Not(Equals), nil)
+	})
+}
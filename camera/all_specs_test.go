@@ -0,0 +1,14 @@
+package camera_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(TransformSpec)
+	r.AddSpec(FollowSpec)
+	r.AddSpec(ShakeSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,293 @@
+// Package camera keeps track of what part of a 2d world is on the screen.
+// A Camera can follow a target smoothly, zoom, rotate, and shake, and it
+// converts between world coordinates and screen coordinates, which have
+// their origin at the bottom left of the window like gin's cursors and
+// render's QuadBatch.
+package camera
+
+import (
+	"github.com/runningwild/glop/gin"
+	"math"
+	"math/rand"
+)
+
+type Camera struct {
+	// Size of the viewport in pixels.
+	width, height float64
+
+	// The world point at the center of the viewport, not counting shake.
+	x, y float64
+
+	// Pixels per world unit, and radians counter-clockwise.
+	zoom, rotation float64
+
+	// Zooming over time with ZoomTo(), geometrically from zoom_from to
+	// zoom_to.
+	zoom_from, zoom_to       float64
+	zoom_elapsed, zoom_total int64
+
+	following          bool
+	target_x, target_y float64
+
+	// How many milliseconds it takes to get about 2/3 of the way to the
+	// target, and how far the target can get from the center before the
+	// camera starts moving at all.
+	lag                    float64
+	deadzone_x, deadzone_y float64
+
+	has_bounds                 bool
+	min_x, min_y, max_x, max_y float64
+
+	// trauma goes from 0 to 1 and decays by decay per second, the camera
+	// shakes by trauma squared times max_offset pixels and max_angle radians.
+	trauma, decay         float64
+	max_offset, max_angle float64
+	shake_x, shake_y      float64
+	shake_angle           float64
+	rng                   *rand.Rand
+}
+
+// Makes a camera for a viewport that is width by height pixels, looking at
+// the origin with a zoom of 1.
+func MakeCamera(width, height int) *Camera {
+	return &Camera{
+		width:      float64(width),
+		height:     float64(height),
+		zoom:       1,
+		decay:      1,
+		max_offset: 20,
+		max_angle:  0.05,
+		// Seeded the same way every time so that shakes are deterministic,
+		// which lockstep games need.
+		rng: rand.New(rand.NewSource(1)),
+	}
+}
+
+func (c *Camera) SetViewport(width, height int) {
+	c.width, c.height = float64(width), float64(height)
+	c.clamp()
+}
+
+func (c *Camera) Viewport() (width, height int) {
+	return int(c.width), int(c.height)
+}
+
+// Puts the world point x, y at the center of the viewport.
+func (c *Camera) SetPosition(x, y float64) {
+	c.x, c.y = x, y
+	c.clamp()
+}
+
+func (c *Camera) Position() (x, y float64) {
+	return c.x, c.y
+}
+
+// Sets the zoom to zoom pixels per world unit right away, stopping any
+// ZoomTo() in progress.
+func (c *Camera) SetZoom(zoom float64) {
+	if zoom <= 0 {
+		panic("Zoom must be positive.")
+	}
+	c.zoom = zoom
+	c.zoom_total = 0
+	c.clamp()
+}
+
+// Zooms to zoom pixels per world unit over ms milliseconds of Think().  The
+// zoom changes geometrically so that it feels like a steady speed.
+func (c *Camera) ZoomTo(zoom float64, ms int64) {
+	if zoom <= 0 {
+		panic("Zoom must be positive.")
+	}
+	if ms <= 0 {
+		c.SetZoom(zoom)
+		return
+	}
+	c.zoom_from, c.zoom_to = c.zoom, zoom
+	c.zoom_elapsed, c.zoom_total = 0, ms
+}
+
+func (c *Camera) Zoom() float64 {
+	return c.zoom
+}
+
+// Rotates the view by angle radians counter-clockwise, so that the world
+// looks like it turned clockwise.
+func (c *Camera) SetRotation(angle float64) {
+	c.rotation = angle
+}
+
+func (c *Camera) Rotation() float64 {
+	return c.rotation
+}
+
+// Sets how the camera follows its target.  lag is how many milliseconds it
+// takes to get about two thirds of the way there, 0 keeps the target
+// centered exactly.  The target can move dx world units to either side of
+// the center and dy units above or below it before the camera moves at all.
+func (c *Camera) SetFollow(lag float64, dx, dy float64) {
+	c.lag = math.Max(lag, 0)
+	c.deadzone_x, c.deadzone_y = math.Abs(dx), math.Abs(dy)
+}
+
+// Makes the camera move towards x, y on Think().  Call it every frame with
+// wherever the thing being followed is.
+func (c *Camera) Follow(x, y float64) {
+	c.following = true
+	c.target_x, c.target_y = x, y
+}
+
+// Stops following the target, the camera stays where it is.
+func (c *Camera) StopFollowing() {
+	c.following = false
+}
+
+// Keeps the viewport inside of the given world rectangle, or centered on it
+// if it's too small to fill the viewport.  Rotation isn't taken into
+// account.
+func (c *Camera) SetBounds(min_x, min_y, max_x, max_y float64) {
+	c.has_bounds = true
+	c.min_x, c.min_y, c.max_x, c.max_y = min_x, min_y, max_x, max_y
+	c.clamp()
+}
+
+func (c *Camera) ClearBounds() {
+	c.has_bounds = false
+}
+
+func (c *Camera) clamp() {
+	if !c.has_bounds {
+		return
+	}
+	clampAxis := func(v, lo, hi, half float64) float64 {
+		if hi-lo < 2*half {
+			return (lo + hi) / 2
+		}
+		return math.Min(math.Max(v, lo+half), hi-half)
+	}
+	c.x = clampAxis(c.x, c.min_x, c.max_x, c.width/2/c.zoom)
+	c.y = clampAxis(c.y, c.min_y, c.max_y, c.height/2/c.zoom)
+}
+
+// Sets how shakes look.  The camera moves up to max_offset pixels and turns
+// up to max_angle radians at the strongest, and shakes die down by decay
+// per second, so a full strength shake lasts 1/decay seconds.
+func (c *Camera) SetShake(max_offset, max_angle, decay float64) {
+	c.max_offset, c.max_angle, c.decay = max_offset, max_angle, decay
+}
+
+// Shakes the camera.  amount adds to any shake already going on, and the
+// total is capped at 1.  Small amounts barely shake at all, since the shake
+// goes with the square of the total, so lots of little hits build up.
+func (c *Camera) Shake(amount float64) {
+	c.trauma = math.Min(math.Max(c.trauma+amount, 0), 1)
+}
+
+// Returns how much the camera is shaking, from 0 to 1.
+func (c *Camera) Shaking() float64 {
+	return c.trauma
+}
+
+// Advances following, zooming, and shaking by dt milliseconds.
+func (c *Camera) Think(dt int64) {
+	if dt <= 0 {
+		return
+	}
+	if c.zoom_total > 0 {
+		c.zoom_elapsed += dt
+		if c.zoom_elapsed >= c.zoom_total {
+			c.zoom = c.zoom_to
+			c.zoom_total = 0
+		} else {
+			t := float64(c.zoom_elapsed) / float64(c.zoom_total)
+			c.zoom = c.zoom_from * math.Pow(c.zoom_to/c.zoom_from, t)
+		}
+	}
+
+	if c.following {
+		goal := func(pos, target, deadzone float64) float64 {
+			switch {
+			case target > pos+deadzone:
+				return target - deadzone
+			case target < pos-deadzone:
+				return target + deadzone
+			}
+			return pos
+		}
+		gx := goal(c.x, c.target_x, c.deadzone_x)
+		gy := goal(c.y, c.target_y, c.deadzone_y)
+		if c.lag == 0 {
+			c.x, c.y = gx, gy
+		} else {
+			f := 1 - math.Exp(-float64(dt)/c.lag)
+			c.x += (gx - c.x) * f
+			c.y += (gy - c.y) * f
+		}
+	}
+	c.clamp()
+
+	c.trauma = math.Max(c.trauma-c.decay*float64(dt)/1000, 0)
+	strength := c.trauma * c.trauma
+	c.shake_x = c.max_offset * strength * (2*c.rng.Float64() - 1)
+	c.shake_y = c.max_offset * strength * (2*c.rng.Float64() - 1)
+	c.shake_angle = c.max_angle * strength * (2*c.rng.Float64() - 1)
+}
+
+// Returns the world to screen transform as sx = a*x + b*y + tx and
+// sy = c*x + d*y + ty.
+func (c *Camera) transform() (a, b, cc, d, tx, ty float64) {
+	sin, cos := math.Sincos(c.rotation + c.shake_angle)
+	a, b = c.zoom*cos, c.zoom*sin
+	cc, d = -c.zoom*sin, c.zoom*cos
+	tx = c.width/2 + c.shake_x - (a*c.x + b*c.y)
+	ty = c.height/2 + c.shake_y - (cc*c.x + d*c.y)
+	return
+}
+
+// Returns where the world point x, y is on the screen.
+func (c *Camera) WorldToScreen(x, y float64) (sx, sy float64) {
+	a, b, cc, d, tx, ty := c.transform()
+	return a*x + b*y + tx, cc*x + d*y + ty
+}
+
+// Returns the world point that is at sx, sy on the screen.
+func (c *Camera) ScreenToWorld(sx, sy float64) (x, y float64) {
+	a, b, cc, d, tx, ty := c.transform()
+	sx -= tx
+	sy -= ty
+	det := a*d - b*cc
+	return (d*sx - b*sy) / det, (a*sy - cc*sx) / det
+}
+
+// Returns the world point under cursor, like one from gin.Input.GetCursor().
+func (c *Camera) CursorToWorld(cursor gin.Cursor) (x, y float64) {
+	sx, sy := cursor.Point()
+	return c.ScreenToWorld(float64(sx), float64(sy))
+}
+
+// Returns the smallest world rectangle that contains everything on the
+// screen, which is useful for culling.
+func (c *Camera) Visible() (min_x, min_y, max_x, max_y float64) {
+	min_x, min_y = math.Inf(1), math.Inf(1)
+	max_x, max_y = math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{0, 0}, {c.width, 0}, {0, c.height}, {c.width, c.height}} {
+		x, y := c.ScreenToWorld(corner[0], corner[1])
+		min_x, min_y = math.Min(min_x, x), math.Min(min_y, y)
+		max_x, max_y = math.Max(max_x, x), math.Max(max_y, y)
+	}
+	return
+}
+
+// Returns a column-major matrix that takes world coordinates to clip
+// coordinates, for use as a projection matrix in a shader or with
+// glLoadMatrixf.
+func (c *Camera) Matrix() [16]float32 {
+	a, b, cc, d, tx, ty := c.transform()
+	w, h := c.width, c.height
+	return [16]float32{
+		float32(2 * a / w), float32(2 * cc / h), 0, 0,
+		float32(2 * b / w), float32(2 * d / h), 0, 0,
+		0, 0, -1, 0,
+		float32(2*tx/w - 1), float32(2*ty/h - 1), 0, 1,
+	}
+}
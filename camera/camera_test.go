@@ -0,0 +1,158 @@
+package camera_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/camera"
+	"github.com/runningwild/glop/gin"
+	"math"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+type fakeCursor struct {
+	x, y int
+}
+
+func (fc fakeCursor) Name() string         { return "Mouse 0" }
+func (fc fakeCursor) Point() (int, int)    { return fc.x, fc.y }
+func (fc fakeCursor) Device() gin.DeviceId { return gin.DeviceId{} }
+
+func TransformSpec(c gospec.Context) {
+	cam := camera.MakeCamera(800, 600)
+	cam.SetPosition(100, 50)
+
+	c.Specify("The camera's position is at the center of the screen", func() {
+		x, y := cam.WorldToScreen(100, 50)
+		c.Expect(near(x, 400) && near(y, 300), Equals, true)
+		x, y = cam.WorldToScreen(110, 45)
+		c.Expect(near(x, 410) && near(y, 295), Equals, true)
+	})
+
+	c.Specify("Zoom and rotation change where things end up", func() {
+		cam.SetZoom(2)
+		x, y := cam.WorldToScreen(110, 50)
+		c.Expect(near(x, 420) && near(y, 300), Equals, true)
+		cam.SetRotation(math.Pi / 2)
+		x, y = cam.WorldToScreen(110, 50)
+		c.Expect(near(x, 400) && near(y, 280), Equals, true)
+	})
+
+	c.Specify("Screen to world undoes world to screen", func() {
+		cam.SetZoom(3.5)
+		cam.SetRotation(0.7)
+		cam.Shake(1)
+		cam.Think(16)
+		for _, p := range [][2]float64{{0, 0}, {-30, 12}, {1000, -2000}} {
+			sx, sy := cam.WorldToScreen(p[0], p[1])
+			x, y := cam.ScreenToWorld(sx, sy)
+			c.Expect(near(x, p[0]) && near(y, p[1]), Equals, true)
+		}
+		sx, sy := cam.WorldToScreen(7, 8)
+		x, y := cam.CursorToWorld(fakeCursor{int(sx), int(sy)})
+		c.Expect(math.Abs(x-7) < 1 && math.Abs(y-8) < 1, Equals, true)
+	})
+
+	c.Specify("The matrix does the same thing as WorldToScreen", func() {
+		cam.SetZoom(1.5)
+		cam.SetRotation(-0.3)
+		m := cam.Matrix()
+		sx, sy := cam.WorldToScreen(20, 30)
+		cx := float64(m[0])*20 + float64(m[4])*30 + float64(m[12])
+		cy := float64(m[1])*20 + float64(m[5])*30 + float64(m[13])
+		c.Expect(math.Abs(cx-(2*sx/800-1)) < 1e-5, Equals, true)
+		c.Expect(math.Abs(cy-(2*sy/600-1)) < 1e-5, Equals, true)
+	})
+
+	c.Specify("Visible covers the whole screen", func() {
+		cam.SetZoom(2)
+		min_x, min_y, max_x, max_y := cam.Visible()
+		c.Expect(near(min_x, -100) && near(max_x, 300), Equals, true)
+		c.Expect(near(min_y, -100) && near(max_y, 200), Equals, true)
+	})
+
+	c.Specify("ZoomTo zooms over time", func() {
+		cam.ZoomTo(4, 100)
+		cam.Think(50)
+		c.Expect(near(cam.Zoom(), 2), Equals, true)
+		cam.Think(100)
+		c.Expect(cam.Zoom(), Equals, 4.0)
+	})
+}
+
+func FollowSpec(c gospec.Context) {
+	cam := camera.MakeCamera(100, 100)
+
+	c.Specify("Without lag the target is kept centered", func() {
+		cam.Follow(30, 40)
+		cam.Think(16)
+		x, y := cam.Position()
+		c.Expect(x, Equals, 30.0)
+		c.Expect(y, Equals, 40.0)
+	})
+
+	c.Specify("With lag the camera catches up gradually", func() {
+		cam.SetFollow(100, 0, 0)
+		cam.Follow(100, 0)
+		cam.Think(100)
+		x, _ := cam.Position()
+		c.Expect(x > 60 && x < 66, Equals, true)
+		for i := 0; i < 200; i++ {
+			cam.Think(16)
+		}
+		x, _ = cam.Position()
+		c.Expect(near(x, 100), Equals, true)
+	})
+
+	c.Specify("The target can move around inside the deadzone", func() {
+		cam.SetFollow(0, 10, 5)
+		cam.Follow(8, -4)
+		cam.Think(16)
+		x, y := cam.Position()
+		c.Expect(x, Equals, 0.0)
+		c.Expect(y, Equals, 0.0)
+		cam.Follow(25, -4)
+		cam.Think(16)
+		x, _ = cam.Position()
+		c.Expect(x, Equals, 15.0)
+	})
+
+	c.Specify("Bounds keep the view inside the world", func() {
+		cam.SetBounds(0, 0, 1000, 80)
+		x, y := cam.Position()
+		c.Expect(x, Equals, 50.0)
+		c.Expect(y, Equals, 40.0)
+		cam.Follow(2000, 0)
+		cam.Think(16)
+		x, _ = cam.Position()
+		c.Expect(x, Equals, 950.0)
+	})
+}
+
+func ShakeSpec(c gospec.Context) {
+	cam := camera.MakeCamera(100, 100)
+	cam.SetShake(10, 0, 2)
+
+	c.Specify("Shakes move the view and then die down", func() {
+		cam.Shake(0.75)
+		cam.Shake(0.75)
+		c.Expect(cam.Shaking(), Equals, 1.0)
+		moved := false
+		for i := 0; i < 10; i++ {
+			cam.Think(10)
+			x, y := cam.WorldToScreen(0, 0)
+			if !near(x, 50) || !near(y, 50) {
+				moved = true
+			}
+			c.Expect(math.Abs(x-50) <= 10 && math.Abs(y-50) <= 10, Equals, true)
+		}
+		c.Expect(moved, Equals, true)
+		cam.Think(500)
+		c.Expect(cam.Shaking(), Equals, 0.0)
+		x, y := cam.WorldToScreen(0, 0)
+		c.Expect(x, Equals, 50.0)
+		c.Expect(y, Equals, 50.0)
+	})
+}
@@ -0,0 +1,13 @@
+package clock_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(TimerSpec)
+	r.AddSpec(TweenSpec)
+	gospec.MainGoTest(r, t)
+}
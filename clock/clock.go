@@ -0,0 +1,230 @@
+// Package clock runs timers and tweens off of game time rather than wall
+// time.  A Clock only moves forward when Think() is called, so calling it
+// from the main loop with the same dt that everything else gets means that
+// timers pause when the game does, speed up and slow down with it, and fire
+// at exactly the same points in a replay or a lockstep game.
+//
+// All times are in milliseconds.  A Clock isn't safe to use from more than
+// one goroutine, and callbacks are run from inside of Think().
+package clock
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+type Clock struct {
+	now    int64
+	timers timerHeap
+	tweens []*Timer
+
+	// Incremented for each timer so that timers that are due at the same
+	// time fire in the order they were made.
+	count int64
+}
+
+// A Timer is returned by everything that schedules something, so that it can
+// be cancelled.
+type Timer struct {
+	clock *Clock
+	due   int64
+	count int64
+	index int
+
+	// For Every(), 0 for timers that only fire once.
+	period int64
+	fn     func()
+
+	// For Tween().
+	start, duration int64
+	from, to        float64
+	easing          Easing
+	apply           func(float64)
+
+	done bool
+}
+
+func MakeClock() *Clock {
+	return &Clock{}
+}
+
+var the_clock = MakeClock()
+
+// Returns the Clock used by the package level functions.
+func Default() *Clock {
+	return the_clock
+}
+
+func After(ms int64, fn func()) *Timer {
+	return the_clock.After(ms, fn)
+}
+
+func Every(ms int64, fn func()) *Timer {
+	return the_clock.Every(ms, fn)
+}
+
+func Tween(from, to float64, duration int64, easing Easing, apply func(float64)) *Timer {
+	return the_clock.Tween(from, to, duration, easing, apply)
+}
+
+func Think(dt int64) {
+	the_clock.Think(dt)
+}
+
+func Now() int64 {
+	return the_clock.Now()
+}
+
+// Returns how many milliseconds of Think() there have been.
+func (c *Clock) Now() int64 {
+	return c.now
+}
+
+func (c *Clock) schedule(t *Timer) *Timer {
+	t.clock = c
+	t.count = c.count
+	c.count++
+	heap.Push(&c.timers, t)
+	return t
+}
+
+// Calls fn once ms milliseconds from now.
+func (c *Clock) After(ms int64, fn func()) *Timer {
+	if ms < 0 {
+		ms = 0
+	}
+	return c.schedule(&Timer{due: c.now + ms, fn: fn})
+}
+
+// Calls fn every ms milliseconds, starting ms milliseconds from now.  If a
+// single Think() covers more than one period fn is called once for each of
+// them.
+func (c *Clock) Every(ms int64, fn func()) *Timer {
+	if ms <= 0 {
+		panic(fmt.Sprintf("Cannot call something every %d ms.", ms))
+	}
+	return c.schedule(&Timer{due: c.now + ms, period: ms, fn: fn})
+}
+
+// Calls apply with values going from from to to over duration milliseconds,
+// once right away and then on every Think() until it has been called with to.
+// easing shapes how the value changes over time, nil means Linear.
+func (c *Clock) Tween(from, to float64, duration int64, easing Easing, apply func(float64)) *Timer {
+	if easing == nil {
+		easing = Linear
+	}
+	t := &Timer{
+		clock:    c,
+		start:    c.now,
+		duration: duration,
+		from:     from,
+		to:       to,
+		easing:   easing,
+		apply:    apply,
+		index:    -1,
+	}
+	if t.tween() {
+		t.done = true
+	} else {
+		c.tweens = append(c.tweens, t)
+	}
+	return t
+}
+
+// Applies the value of a tween at the current time, returns true once it is
+// finished.
+func (t *Timer) tween() bool {
+	elapsed := t.clock.now - t.start
+	if elapsed >= t.duration {
+		t.apply(t.to)
+		return true
+	}
+	f := t.easing(float64(elapsed) / float64(t.duration))
+	t.apply(t.from + (t.to-t.from)*f)
+	return false
+}
+
+// Stops t from firing again, or stops a tween where it is.  Cancelling a
+// timer that has already finished does nothing.
+func (t *Timer) Cancel() {
+	if t.done {
+		return
+	}
+	t.done = true
+	if t.index >= 0 {
+		heap.Remove(&t.clock.timers, t.index)
+	}
+}
+
+// Returns true iff t has fired, if it only fires once, has finished tweening,
+// or has been cancelled.
+func (t *Timer) Done() bool {
+	return t.done
+}
+
+// Advances c by dt milliseconds, firing every timer that comes due in the
+// order that they come due and then updating tweens.  While a timer's
+// callback runs Now() returns the time it was due, so timers that it sets up
+// are relative to that.
+func (c *Clock) Think(dt int64) {
+	if dt < 0 {
+		panic(fmt.Sprintf("Cannot think for %d ms.", dt))
+	}
+	end := c.now + dt
+	for len(c.timers) > 0 && c.timers[0].due <= end {
+		t := c.timers[0]
+		c.now = t.due
+		if t.period > 0 {
+			t.due += t.period
+			t.count = c.count
+			c.count++
+			heap.Fix(&c.timers, 0)
+		} else {
+			heap.Pop(&c.timers)
+			t.done = true
+		}
+		t.fn()
+	}
+	c.now = end
+
+	// Tweens can start and cancel other tweens, so this goes over a copy.
+	tweens := c.tweens
+	c.tweens = nil
+	for _, t := range tweens {
+		if !t.done {
+			if t.tween() {
+				t.done = true
+			} else {
+				c.tweens = append(c.tweens, t)
+			}
+		}
+	}
+}
+
+type timerHeap []*Timer
+
+func (th timerHeap) Len() int {
+	return len(th)
+}
+func (th timerHeap) Less(i, j int) bool {
+	if th[i].due != th[j].due {
+		return th[i].due < th[j].due
+	}
+	return th[i].count < th[j].count
+}
+func (th timerHeap) Swap(i, j int) {
+	th[i], th[j] = th[j], th[i]
+	th[i].index = i
+	th[j].index = j
+}
+func (th *timerHeap) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index = len(*th)
+	*th = append(*th, t)
+}
+func (th *timerHeap) Pop() interface{} {
+	t := (*th)[len(*th)-1]
+	t.index = -1
+	*th = (*th)[:len(*th)-1]
+	return t
+}
@@ -0,0 +1,108 @@
+package clock_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/clock"
+)
+
+func TimerSpec(c gospec.Context) {
+	clk := clock.MakeClock()
+	var fired []int64
+	record := func() { fired = append(fired, clk.Now()) }
+
+	c.Specify("After fires once when its time comes", func() {
+		t := clk.After(100, record)
+		clk.Think(99)
+		c.Expect(len(fired), Equals, 0)
+		c.Expect(t.Done(), Equals, false)
+		clk.Think(1)
+		c.Expect(fired, ContainsInOrder, []int64{100})
+		c.Expect(t.Done(), Equals, true)
+		clk.Think(1000)
+		c.Expect(len(fired), Equals, 1)
+	})
+
+	c.Specify("Every fires once per period, even within one Think", func() {
+		clk.Every(30, record)
+		clk.Think(100)
+		c.Expect(fired, ContainsInOrder, []int64{30, 60, 90})
+		clk.Think(20)
+		c.Expect(fired, ContainsInOrder, []int64{30, 60, 90, 120})
+	})
+
+	c.Specify("Timers fire in order and can be cancelled", func() {
+		var order []string
+		clk.After(50, func() { order = append(order, "b") })
+		clk.After(20, func() { order = append(order, "a") })
+		cancelled := clk.After(30, func() { order = append(order, "never") })
+		clk.After(50, func() { order = append(order, "c") })
+		var every *clock.Timer
+		every = clk.Every(10, func() {
+			order = append(order, "e")
+			if clk.Now() == 20 {
+				every.Cancel()
+			}
+		})
+		cancelled.Cancel()
+		clk.Think(100)
+		c.Expect(order, ContainsInOrder, []string{"e", "a", "e", "b", "c"})
+		c.Expect(cancelled.Done(), Equals, true)
+	})
+
+	c.Specify("Timers set up by timers are relative to when they fired", func() {
+		clk.After(10, func() {
+			record()
+			clk.After(15, record)
+		})
+		clk.Think(100)
+		c.Expect(fired, ContainsInOrder, []int64{10, 25})
+		c.Expect(clk.Now(), Equals, int64(100))
+	})
+
+	c.Specify("Nothing happens without Think", func() {
+		clk.After(0, record)
+		c.Expect(len(fired), Equals, 0)
+		clk.Think(0)
+		c.Expect(len(fired), Equals, 1)
+	})
+}
+
+func TweenSpec(c gospec.Context) {
+	clk := clock.MakeClock()
+	var values []float64
+	apply := func(v float64) { values = append(values, v) }
+
+	c.Specify("Tweens go from start to end", func() {
+		t := clk.Tween(10, 20, 100, nil, apply)
+		clk.Think(50)
+		clk.Think(50)
+		clk.Think(50)
+		c.Expect(values, ContainsInOrder, []float64{10, 15, 20})
+		c.Expect(t.Done(), Equals, true)
+	})
+
+	c.Specify("Easing changes the values along the way", func() {
+		clk.Tween(0, 100, 100, clock.EaseIn, apply)
+		clk.Think(50)
+		clk.Tween(0, 100, 100, clock.EaseOut, apply)
+		clk.Think(50)
+		c.Expect(values, ContainsInOrder, []float64{0, 25, 0, 100, 75})
+	})
+
+	c.Specify("Cancelled tweens stop where they are", func() {
+		t := clk.Tween(0, 1, 100, clock.Linear, apply)
+		clk.Think(25)
+		t.Cancel()
+		clk.Think(100)
+		c.Expect(values, ContainsInOrder, []float64{0, 0.25})
+	})
+
+	c.Specify("Easings start at 0 and end at 1", func() {
+		for _, e := range []clock.Easing{clock.Linear, clock.EaseIn, clock.EaseOut, clock.EaseInOut} {
+			c.Expect(e(0), Equals, 0.0)
+			c.Expect(e(1), Equals, 1.0)
+		}
+		c.Expect(clock.EaseInOut(0.5), Equals, 0.5)
+	})
+}
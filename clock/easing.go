@@ -0,0 +1,27 @@
+package clock
+
+// An Easing takes how far through a tween it is, from 0 to 1, and returns how
+// far from the start value to the end value the tween should be.
+type Easing func(t float64) float64
+
+func Linear(t float64) float64 {
+	return t
+}
+
+// Starts slow and speeds up.
+func EaseIn(t float64) float64 {
+	return t * t
+}
+
+// Starts fast and slows down.
+func EaseOut(t float64) float64 {
+	return t * (2 - t)
+}
+
+// Starts slow, speeds up, and slows down again at the end.
+func EaseInOut(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return -1 + (4-2*t)*t
+}
@@ -0,0 +1,14 @@
+package collide_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(OverlapSpec)
+	r.AddSpec(MoveSpec)
+	r.AddSpec(WorldSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,157 @@
+package collide_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/collide"
+	"math"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func OverlapSpec(c gospec.Context) {
+	box := collide.Box{0, 0, 10, 10}
+	c.Specify("Boxes overlap boxes", func() {
+		c.Expect(collide.Overlaps(box, collide.Box{5, 5, 15, 15}), Equals, true)
+		c.Expect(collide.Overlaps(box, collide.Box{10, 0, 20, 10}), Equals, false)
+		c.Expect(collide.Overlaps(box, collide.Box{2, 2, 3, 3}), Equals, true)
+	})
+	c.Specify("Circles overlap circles and boxes", func() {
+		c.Expect(collide.Overlaps(collide.Circle{0, 0, 1}, collide.Circle{1.5, 0, 1}), Equals, true)
+		c.Expect(collide.Overlaps(collide.Circle{0, 0, 1}, collide.Circle{2, 0, 1}), Equals, false)
+		c.Expect(collide.Overlaps(collide.Circle{11, 5, 2}, box), Equals, true)
+		c.Expect(collide.Overlaps(box, collide.Circle{11, 5, 2}), Equals, true)
+		c.Expect(collide.Overlaps(collide.Circle{12, 12, 2}, box), Equals, false)
+		c.Expect(collide.Overlaps(collide.Circle{5, 5, 1}, box), Equals, true)
+	})
+}
+
+func MoveSpec(c gospec.Context) {
+	w := collide.MakeWorld(16)
+	wall := w.Add(collide.Box{10, -100, 20, 100}, 1, 0)
+
+	c.Specify("A box stops at a wall", func() {
+		b := w.Add(collide.Box{0, 0, 4, 4}, 2, 1)
+		hits := w.Move(b, 10, 0, nil)
+		c.Expect(len(hits), Equals, 1)
+		c.Expect(hits[0].Body, Equals, wall)
+		c.Expect(hits[0].NX, Equals, -1.0)
+		c.Expect(b.Shape().Bounds().X2, Equals, 10.0)
+	})
+
+	c.Specify("A box slides along a wall", func() {
+		b := w.Add(collide.Box{0, 0, 4, 4}, 2, 1)
+		w.Move(b, 12, 5, nil)
+		box := b.Shape().Bounds()
+		c.Expect(box.X2, Equals, 10.0)
+		c.Expect(near(box.Y, 5), IsTrue)
+		hits := w.Move(b, 0, 20, nil)
+		c.Expect(len(hits), Equals, 0)
+		c.Expect(near(b.Shape().Bounds().Y, 25), IsTrue)
+	})
+
+	c.Specify("Fast things don't go through walls", func() {
+		b := w.Add(collide.Circle{0, 0, 1}, 2, 1)
+		w.Move(b, 1000, 0, func(*collide.Body, collide.Hit) collide.Response { return collide.Stop })
+		circle := b.Shape().(collide.Circle)
+		c.Expect(near(circle.X, 9), IsTrue)
+	})
+
+	c.Specify("Circles roll around corners", func() {
+		block := w.Add(collide.Box{-20, 20, -10, 30}, 1, 0)
+		b := w.Add(collide.Circle{-15, 0, 1}, 2, 1)
+		w.Move(b, 0, 30, collide.Resolver(func(_ *collide.Body, hit collide.Hit) collide.Response {
+			c.Expect(hit.Body, Equals, block)
+			return collide.Stop
+		}))
+		circle := b.Shape().(collide.Circle)
+		c.Expect(near(circle.Y, 19), IsTrue)
+
+		// Just missing the corner doesn't hit it, and hitting it pushes
+		// straight out from the corner.
+		b.SetShape(collide.Circle{-21, 0, 1})
+		hits := w.Move(b, 0, 30, func(*collide.Body, collide.Hit) collide.Response { return collide.Stop })
+		c.Expect(len(hits), Equals, 0)
+		b.SetShape(collide.Circle{-20 - math.Sqrt2/2, 0, 1})
+		hits = w.Move(b, 0, 30, func(*collide.Body, collide.Hit) collide.Response { return collide.Stop })
+		c.Expect(len(hits), Equals, 1)
+		c.Expect(near(hits[0].NX, -math.Sqrt2/2), IsTrue)
+		c.Expect(near(b.Shape().(collide.Circle).Y, 20-math.Sqrt2/2), IsTrue)
+	})
+
+	c.Specify("Things can bounce", func() {
+		b := w.Add(collide.Box{0, 0, 4, 4}, 2, 1)
+		w.Move(b, 10, 0, func(*collide.Body, collide.Hit) collide.Response { return collide.Bounce })
+		c.Expect(b.Shape().Bounds().X, Equals, 2.0)
+	})
+
+	c.Specify("Things can pass through triggers", func() {
+		trigger := w.Add(collide.Box{5, -1, 6, 1}, 4, 0)
+		b := w.Add(collide.Box{0, 0, 1, 1}, 2, 1|4)
+		var responses []collide.Response
+		hits := w.Move(b, 20, 0, func(_ *collide.Body, hit collide.Hit) collide.Response {
+			if hit.Body == trigger {
+				responses = append(responses, collide.Pass)
+				return collide.Pass
+			}
+			responses = append(responses, collide.Stop)
+			return collide.Stop
+		})
+		c.Expect(len(hits), Equals, 2)
+		c.Expect(hits[0].Body, Equals, trigger)
+		c.Expect(hits[1].Body, Equals, wall)
+		c.Expect(responses, ContainsInOrder, []collide.Response{collide.Pass, collide.Stop})
+		c.Expect(b.Shape().Bounds().X2, Equals, 10.0)
+	})
+
+	c.Specify("Layer masks decide what collides", func() {
+		ghost := w.Add(collide.Box{0, 0, 4, 4}, 2, 0)
+		hits := w.Move(ghost, 30, 0, nil)
+		c.Expect(len(hits), Equals, 0)
+		c.Expect(ghost.Shape().Bounds().X, Equals, 30.0)
+	})
+
+	c.Specify("Things that overlap can move apart but not further in", func() {
+		b := w.Add(collide.Box{8, 0, 12, 4}, 2, 1)
+		hits := w.Move(b, 5, 0, nil)
+		c.Expect(len(hits), Equals, 1)
+		c.Expect(b.Shape().Bounds().X, Equals, 8.0)
+		hits = w.Move(b, -5, 0, nil)
+		c.Expect(len(hits), Equals, 0)
+		c.Expect(b.Shape().Bounds().X, Equals, 3.0)
+	})
+}
+
+type sized struct{}
+
+func (sized) Dims() (int, int) { return 10, 20 }
+
+func WorldSpec(c gospec.Context) {
+	w := collide.MakeWorld(8)
+	a := w.Add(collide.Box{0, 0, 4, 4}, 1, 0)
+	b := w.Add(collide.Circle{50, 50, 30}, 2, 0)
+
+	c.Specify("Queries find overlapping bodies on the right layers", func() {
+		c.Expect(len(w.Query(collide.Box{2, 2, 3, 3}, 1)), Equals, 1)
+		c.Expect(len(w.Query(collide.Box{2, 2, 3, 3}, 2)), Equals, 0)
+		c.Expect(len(w.Query(collide.Circle{30, 30, 1}, 3)), Equals, 1)
+		c.Expect(w.Query(collide.Circle{30, 30, 1}, 3)[0], Equals, b)
+	})
+
+	c.Specify("Bodies can be moved and removed", func() {
+		a.SetShape(collide.Box{40, 40, 44, 44})
+		c.Expect(len(w.Query(collide.Box{2, 2, 3, 3}, 1)), Equals, 0)
+		a.SetLayers(1, 2)
+		c.Expect(w.Overlapping(a), ContainsInOrder, []*collide.Body{b})
+		w.Remove(b)
+		c.Expect(w.NumBodies(), Equals, 1)
+		c.Expect(len(w.Overlapping(a)), Equals, 0)
+	})
+
+	c.Specify("Boxes can be made from sprites", func() {
+		box := collide.BoxOf(sized{}, 100, 10)
+		c.Expect(box, Equals, collide.Box{95, 10, 105, 30})
+	})
+}
@@ -0,0 +1,233 @@
+package collide
+
+import "math"
+
+// A Shape is either a Box or a Circle.
+type Shape interface {
+	// The smallest Box that contains the shape.
+	Bounds() Box
+
+	// Returns the shape moved by dx, dy.
+	Translate(dx, dy float64) Shape
+
+	// Only the shapes in this package can be used, since every pair of them
+	// needs its own collision code.
+	shape()
+}
+
+// An axis aligned box from X, Y to X2, Y2, which must not be less than X, Y.
+type Box struct {
+	X, Y, X2, Y2 float64
+}
+
+type Circle struct {
+	X, Y, R float64
+}
+
+func (b Box) Bounds() Box {
+	return b
+}
+
+func (b Box) Translate(dx, dy float64) Shape {
+	return Box{b.X + dx, b.Y + dy, b.X2 + dx, b.Y2 + dy}
+}
+
+func (b Box) shape() {}
+
+func (b Box) Center() (x, y float64) {
+	return (b.X + b.X2) / 2, (b.Y + b.Y2) / 2
+}
+
+func (c Circle) Bounds() Box {
+	return Box{c.X - c.R, c.Y - c.R, c.X + c.R, c.Y + c.R}
+}
+
+func (c Circle) Translate(dx, dy float64) Shape {
+	return Circle{c.X + dx, c.Y + dy, c.R}
+}
+
+func (c Circle) shape() {}
+
+// Returns true iff b and b2 overlap.  Shapes that are only touching don't
+// overlap.
+func (b Box) overlaps(b2 Box) bool {
+	return b.X < b2.X2 && b2.X < b.X2 && b.Y < b2.Y2 && b2.Y < b.Y2
+}
+
+// Returns true iff a and b overlap.  Shapes that are only touching don't
+// overlap.
+func Overlaps(a, b Shape) bool {
+	_, ok := separation(a, b)
+	return ok
+}
+
+// Returns the point in b closest to x, y.
+func (b Box) closest(x, y float64) (cx, cy float64) {
+	return math.Min(math.Max(x, b.X), b.X2), math.Min(math.Max(y, b.Y), b.Y2)
+}
+
+// If a and b overlap returns the direction that a would have to move in to
+// get out of b the fastest.
+func separation(a, b Shape) (normal [2]float64, ok bool) {
+	switch a := a.(type) {
+	case Box:
+		switch b := b.(type) {
+		case Box:
+			if !a.overlaps(b) {
+				return normal, false
+			}
+			// Push out along whichever side a is the least far into.
+			depths := [4]float64{b.X2 - a.X, a.X2 - b.X, b.Y2 - a.Y, a.Y2 - b.Y}
+			normals := [4][2]float64{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+			best := 0
+			for i := range depths {
+				if depths[i] < depths[best] {
+					best = i
+				}
+			}
+			return normals[best], true
+		case Circle:
+			normal, ok = separation(b, a)
+			return [2]float64{-normal[0], -normal[1]}, ok
+		}
+	case Circle:
+		switch b := b.(type) {
+		case Box:
+			cx, cy := b.closest(a.X, a.Y)
+			dx, dy := a.X-cx, a.Y-cy
+			dist2 := dx*dx + dy*dy
+			if dist2 >= a.R*a.R {
+				return normal, false
+			}
+			if dist2 > 0 {
+				d := math.Sqrt(dist2)
+				return [2]float64{dx / d, dy / d}, true
+			}
+			// The center of the circle is inside the box.
+			return separation(Box{a.X, a.Y, a.X, a.Y}, b)
+		case Circle:
+			dx, dy := a.X-b.X, a.Y-b.Y
+			r := a.R + b.R
+			dist2 := dx*dx + dy*dy
+			if dist2 >= r*r {
+				return normal, false
+			}
+			if dist2 == 0 {
+				return [2]float64{0, 1}, true
+			}
+			d := math.Sqrt(dist2)
+			return [2]float64{dx / d, dy / d}, true
+		}
+	}
+	return normal, false
+}
+
+// Returns the fraction of the way along dx, dy that the ray from x, y enters
+// box, and the side it enters through.  Only returns hits between 0 and 1.
+// A ray that only grazes box, or starts inside of it, doesn't hit it.
+func rayBox(x, y, dx, dy float64, box Box) (t float64, normal [2]float64, ok bool) {
+	enter, exit := math.Inf(-1), math.Inf(1)
+	slab := func(p, d, lo, hi float64, n [2]float64) bool {
+		if d == 0 {
+			return p > lo && p < hi
+		}
+		t0, t1 := (lo-p)/d, (hi-p)/d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+			n[0], n[1] = -n[0], -n[1]
+		}
+		if t0 > enter {
+			enter = t0
+			normal = n
+		}
+		exit = math.Min(exit, t1)
+		return true
+	}
+	// Hitting the low side of a slab means moving in the positive direction,
+	// so the normal is negative.
+	if !slab(x, dx, box.X, box.X2, [2]float64{-1, 0}) || !slab(y, dy, box.Y, box.Y2, [2]float64{0, -1}) {
+		return 0, normal, false
+	}
+	if enter >= exit || enter < 0 || enter > 1 {
+		return 0, normal, false
+	}
+	return enter, normal, true
+}
+
+// Like rayBox() but for a circle at x, y with radius r.
+func rayCircle(x, y, dx, dy, cx, cy, r float64) (t float64, normal [2]float64, ok bool) {
+	// Solve |p + t*d - c|^2 = r^2 for the smaller t.
+	px, py := x-cx, y-cy
+	a := dx*dx + dy*dy
+	b := px*dx + py*dy
+	c := px*px + py*py - r*r
+	if a == 0 || b >= 0 {
+		return 0, normal, false
+	}
+	if c <= 0 {
+		// Already touching and moving inwards.
+		d := math.Hypot(px, py)
+		return 0, [2]float64{px / d, py / d}, true
+	}
+	disc := b*b - a*c
+	if disc <= 0 {
+		return 0, normal, false
+	}
+	t = (-b - math.Sqrt(disc)) / a
+	if t > 1 {
+		return 0, normal, false
+	}
+	hx, hy := px+t*dx, py+t*dy
+	d := math.Hypot(hx, hy)
+	return t, [2]float64{hx / d, hy / d}, true
+}
+
+// Returns the fraction of the way along dx, dy that a can move before it hits
+// b, and the normal of the surface of b that it hits.  If they start out
+// overlapping a only hits b if it is moving further into it.
+func sweep(a Shape, dx, dy float64, b Shape) (t float64, normal [2]float64, ok bool) {
+	if n, overlapping := separation(a, b); overlapping {
+		if dx*n[0]+dy*n[1] < 0 {
+			return 0, n, true
+		}
+		return 0, normal, false
+	}
+	switch a := a.(type) {
+	case Box:
+		switch b := b.(type) {
+		case Box:
+			// Sweeping the bottom left corner of a against b grown by the
+			// size of a is the same as sweeping a against b.
+			return rayBox(a.X, a.Y, dx, dy, Box{b.X - (a.X2 - a.X), b.Y - (a.Y2 - a.Y), b.X2, b.Y2})
+		case Circle:
+			t, normal, ok = sweep(b, -dx, -dy, a)
+			return t, [2]float64{-normal[0], -normal[1]}, ok
+		}
+	case Circle:
+		switch b := b.(type) {
+		case Box:
+			// The shape that the center of a can't enter is b with rounded
+			// corners of radius a.R, which is b grown sideways, b grown
+			// vertically, and a circle on each corner.
+			r := a.R
+			t = math.Inf(1)
+			try := func(ht float64, n [2]float64, hit bool) {
+				if hit && ht < t {
+					t, normal, ok = ht, n, true
+				}
+			}
+			try(rayBox(a.X, a.Y, dx, dy, Box{b.X - r, b.Y, b.X2 + r, b.Y2}))
+			try(rayBox(a.X, a.Y, dx, dy, Box{b.X, b.Y - r, b.X2, b.Y2 + r}))
+			for _, corner := range [4][2]float64{{b.X, b.Y}, {b.X2, b.Y}, {b.X, b.Y2}, {b.X2, b.Y2}} {
+				try(rayCircle(a.X, a.Y, dx, dy, corner[0], corner[1], r))
+			}
+			if !ok {
+				t = 0
+			}
+			return t, normal, ok
+		case Circle:
+			return rayCircle(a.X, a.Y, dx, dy, b.X, b.Y, a.R+b.R)
+		}
+	}
+	return 0, normal, false
+}
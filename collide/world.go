@@ -0,0 +1,284 @@
+// Package collide finds collisions between boxes and circles and moves them
+// without letting them pass through each other.  It is meant for games that
+// need things to bump into walls and each other, but don't need a physics
+// engine.
+//
+// Bodies are kept in a spatial hash, so finding what is near something only
+// looks at the bodies in the cells around it.  Each body is on some layers
+// and has a mask of the layers it collides with, so that, for example,
+// bullets can hit players without hitting each other.
+package collide
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Body is a Shape in a World.
+type Body struct {
+	world *World
+	shape Shape
+
+	// The layers the body is on and the layers it collides with.  A body
+	// only bumps into bodies with a layer in its mask.
+	layer, mask uint32
+
+	// The cells that the body is in.
+	x, y, x2, y2 int
+
+	// For the game to use, collide doesn't touch it.
+	Data interface{}
+}
+
+func (b *Body) Shape() Shape {
+	return b.shape
+}
+
+// Moves or changes the shape of b without checking for collisions, like to
+// teleport it.
+func (b *Body) SetShape(shape Shape) {
+	b.world.unhash(b)
+	b.shape = shape
+	b.world.hash(b)
+}
+
+func (b *Body) Layer() uint32 {
+	return b.layer
+}
+
+func (b *Body) Mask() uint32 {
+	return b.mask
+}
+
+func (b *Body) SetLayers(layer, mask uint32) {
+	b.layer, b.mask = layer, mask
+}
+
+// Returns true iff b bumps into other.
+func (b *Body) CollidesWith(other *Body) bool {
+	return b != other && b.mask&other.layer != 0
+}
+
+type World struct {
+	cell_size float64
+	cells     map[[2]int][]*Body
+	num       int
+
+	// Bumped by every query so that bodies in more than one cell are only
+	// looked at once.
+	query int
+	seen  map[*Body]int
+}
+
+// Makes a world whose spatial hash has square cells that are cell_size
+// across.  The cells should be a bit bigger than most bodies.
+func MakeWorld(cell_size float64) *World {
+	if cell_size <= 0 {
+		panic(fmt.Sprintf("Cannot make a world with cells of size %v.", cell_size))
+	}
+	return &World{
+		cell_size: cell_size,
+		cells:     make(map[[2]int][]*Body),
+		seen:      make(map[*Body]int),
+	}
+}
+
+// Adds a body with shape to w, on the given layers and colliding with the
+// layers in mask.
+func (w *World) Add(shape Shape, layer, mask uint32) *Body {
+	b := &Body{world: w, shape: shape, layer: layer, mask: mask}
+	w.hash(b)
+	w.num++
+	return b
+}
+
+func (w *World) Remove(b *Body) {
+	if b.world != w {
+		panic("Cannot remove a body from a world it isn't in.")
+	}
+	w.unhash(b)
+	delete(w.seen, b)
+	b.world = nil
+	w.num--
+}
+
+func (w *World) NumBodies() int {
+	return w.num
+}
+
+// Returns the cells that box is in.
+func (w *World) cellRange(box Box) (x, y, x2, y2 int) {
+	cell := func(v float64) int {
+		return int(math.Floor(v / w.cell_size))
+	}
+	return cell(box.X), cell(box.Y), cell(box.X2), cell(box.Y2)
+}
+
+func (w *World) hash(b *Body) {
+	b.x, b.y, b.x2, b.y2 = w.cellRange(b.shape.Bounds())
+	for x := b.x; x <= b.x2; x++ {
+		for y := b.y; y <= b.y2; y++ {
+			w.cells[[2]int{x, y}] = append(w.cells[[2]int{x, y}], b)
+		}
+	}
+}
+
+func (w *World) unhash(b *Body) {
+	for x := b.x; x <= b.x2; x++ {
+		for y := b.y; y <= b.y2; y++ {
+			key := [2]int{x, y}
+			bodies := w.cells[key]
+			for i := range bodies {
+				if bodies[i] == b {
+					bodies[i] = bodies[len(bodies)-1]
+					bodies = bodies[:len(bodies)-1]
+					break
+				}
+			}
+			if len(bodies) == 0 {
+				delete(w.cells, key)
+			} else {
+				w.cells[key] = bodies
+			}
+		}
+	}
+}
+
+// Calls f once for each body that might be in box.
+func (w *World) near(box Box, f func(*Body)) {
+	w.query++
+	x, y, x2, y2 := w.cellRange(box)
+	for cx := x; cx <= x2; cx++ {
+		for cy := y; cy <= y2; cy++ {
+			for _, b := range w.cells[[2]int{cx, cy}] {
+				if w.seen[b] != w.query {
+					w.seen[b] = w.query
+					f(b)
+				}
+			}
+		}
+	}
+}
+
+// Returns all of the bodies that overlap shape and are on a layer in mask.
+func (w *World) Query(shape Shape, mask uint32) []*Body {
+	var found []*Body
+	w.near(shape.Bounds(), func(b *Body) {
+		if b.layer&mask != 0 && Overlaps(shape, b.shape) {
+			found = append(found, b)
+		}
+	})
+	return found
+}
+
+// Returns all of the bodies that b overlaps and collides with.
+func (w *World) Overlapping(b *Body) []*Body {
+	var found []*Body
+	w.near(b.shape.Bounds(), func(other *Body) {
+		if b.CollidesWith(other) && Overlaps(b.shape, other.shape) {
+			found = append(found, other)
+		}
+	})
+	return found
+}
+
+// What a moving body does when it hits something.
+type Response int
+
+const (
+	// Stop moving into the surface that was hit but keep moving along it,
+	// like walking into a wall at an angle.
+	Slide Response = iota
+
+	// Stop where the body hit.
+	Stop
+
+	// Bounce off of the surface that was hit.
+	Bounce
+
+	// Move through it as if it wasn't there, like for pickups and triggers.
+	Pass
+)
+
+type Hit struct {
+	Body *Body
+
+	// The normal of the surface of Body that was hit.
+	NX, NY float64
+
+	// Where the moving body was when it hit, it is touching Body there.
+	Shape Shape
+}
+
+// Decides what a body does when it hits something.  A nil Resolver always
+// slides.
+type Resolver func(b *Body, hit Hit) Response
+
+// The most times that a body can hit something in one Move().
+const maxHits = 16
+
+// Moves b by dx, dy, stopping, sliding, or bouncing off of things that it
+// collides with along the way as resolve says to, and returns everything
+// that it hit in the order that it hit them.  Bodies that b already overlaps
+// only block it from moving further into them, so things that end up inside
+// of each other can get out.
+func (w *World) Move(b *Body, dx, dy float64, resolve Resolver) []Hit {
+	var hits []Hit
+	passed := make(map[*Body]bool)
+	shape := b.shape
+	for len(hits) < maxHits && (dx != 0 || dy != 0) {
+		var first *Body
+		best := math.Inf(1)
+		var normal [2]float64
+		swept := shape.Bounds()
+		moved := shape.Translate(dx, dy).Bounds()
+		swept = Box{math.Min(swept.X, moved.X), math.Min(swept.Y, moved.Y), math.Max(swept.X2, moved.X2), math.Max(swept.Y2, moved.Y2)}
+		w.near(swept, func(other *Body) {
+			if !b.CollidesWith(other) || passed[other] {
+				return
+			}
+			if t, n, ok := sweep(shape, dx, dy, other.shape); ok && t < best {
+				first, best, normal = other, t, n
+			}
+		})
+		if first == nil {
+			shape = shape.Translate(dx, dy)
+			break
+		}
+		shape = shape.Translate(dx*best, dy*best)
+		dx, dy = dx*(1-best), dy*(1-best)
+		hit := Hit{Body: first, NX: normal[0], NY: normal[1], Shape: shape}
+		hits = append(hits, hit)
+		response := Slide
+		if resolve != nil {
+			response = resolve(b, hit)
+		}
+		into := dx*normal[0] + dy*normal[1]
+		switch response {
+		case Slide:
+			dx, dy = dx-into*normal[0], dy-into*normal[1]
+		case Stop:
+			dx, dy = 0, 0
+		case Bounce:
+			dx, dy = dx-2*into*normal[0], dy-2*into*normal[1]
+		case Pass:
+			passed[first] = true
+		}
+	}
+	b.SetShape(shape)
+	return hits
+}
+
+// Anything with dimensions, like a sprite.Sprite.
+type Sized interface {
+	Dims() (dx, dy int)
+}
+
+// Returns a Box the size of s with the middle of its bottom edge at x, y,
+// which is how sprites usually stand.  Sprite.Dims() is the size of the
+// frame the sprite is on, so calling this every frame and giving the result
+// to Body.SetShape() keeps a body the size of its sprite.
+func BoxOf(s Sized, x, y float64) Box {
+	dx, dy := s.Dims()
+	return Box{x - float64(dx)/2, y, x + float64(dx)/2, y + float64(dy)}
+}
@@ -0,0 +1,13 @@
+package config_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(SettingSpec)
+	r.AddSpec(SaveSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,325 @@
+// Package config keeps the player's settings, like the resolution, vsync,
+// volumes, and key bindings, and saves them between runs.
+//
+// Settings are registered once, usually from an init function, with a name,
+// a label to show the player, and a default:
+//
+//	var vsync = config.Bool("vsync", "Vertical sync", true)
+//	var volume = config.Float("volume", "Volume", 0.8, 0, 1)
+//
+// Settings that are read from a file before they are registered are kept and
+// applied when they are registered, so the order doesn't matter.  Listeners
+// hear about every change, whether it comes from the game or from loading a
+// file, which is how the game applies settings like vsync.
+//
+// Settings() returns everything that was registered, in order, along with
+// what kind of value each one is and its range or choices, which is enough to
+// build an options screen without listing the settings again.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type Kind int
+
+const (
+	KindBool Kind = iota
+	KindInt
+	KindFloat
+	KindChoice
+	KindBindings
+)
+
+// A Setting is one of *BoolSetting, *IntSetting, *FloatSetting,
+// *ChoiceSetting, or *BindingsSetting.
+type Setting interface {
+	Name() string
+	Label() string
+	Kind() Kind
+
+	// Sets the setting back to its default.
+	Reset()
+
+	marshal() (json.RawMessage, error)
+	unmarshal(data json.RawMessage) error
+}
+
+// Anything that needs to know when a setting changes.
+type Listener interface {
+	SettingChanged(s Setting)
+}
+
+type Config struct {
+	mutex     sync.Mutex
+	settings  []Setting
+	by_name   map[string]Setting
+	listeners []Listener
+
+	// Values that were loaded for settings that haven't been registered yet.
+	pending map[string]json.RawMessage
+}
+
+func MakeConfig() *Config {
+	return &Config{
+		by_name: make(map[string]Setting),
+		pending: make(map[string]json.RawMessage),
+	}
+}
+
+var the_config = MakeConfig()
+
+// Returns the Config used by the package level functions.
+func Default() *Config {
+	return the_config
+}
+
+func (c *Config) add(s Setting) {
+	c.mutex.Lock()
+	if _, ok := c.by_name[s.Name()]; ok {
+		c.mutex.Unlock()
+		panic(fmt.Sprintf("Cannot register two settings called '%s'.", s.Name()))
+	}
+	c.settings = append(c.settings, s)
+	c.by_name[s.Name()] = s
+	data, ok := c.pending[s.Name()]
+	delete(c.pending, s.Name())
+	c.mutex.Unlock()
+	if ok {
+		// A bad value in the file just leaves the default.
+		s.unmarshal(data)
+	}
+}
+
+// Returns all of the registered settings in the order they were registered.
+func (c *Config) Settings() []Setting {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]Setting(nil), c.settings...)
+}
+
+// Returns the setting called name, or nil if there isn't one.
+func (c *Config) Lookup(name string) Setting {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.by_name[name]
+}
+
+func (c *Config) RegisterListener(l Listener) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.listeners = append(c.listeners, l)
+}
+
+func (c *Config) UnregisterListener(l Listener) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := range c.listeners {
+		if c.listeners[i] == l {
+			c.listeners = append(c.listeners[:i], c.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Config) changed(s Setting) {
+	c.mutex.Lock()
+	listeners := append([]Listener(nil), c.listeners...)
+	c.mutex.Unlock()
+	for _, l := range listeners {
+		l.SettingChanged(s)
+	}
+}
+
+// Sets every setting back to its default.
+func (c *Config) Reset() {
+	for _, s := range c.Settings() {
+		s.Reset()
+	}
+}
+
+// Writes every setting to w as a JSON object, along with any that were
+// loaded but never registered so that they aren't lost.
+func (c *Config) Save(w io.Writer) error {
+	values := make(map[string]json.RawMessage)
+	c.mutex.Lock()
+	for name, data := range c.pending {
+		values[name] = data
+	}
+	settings := append([]Setting(nil), c.settings...)
+	c.mutex.Unlock()
+	for _, s := range settings {
+		data, err := s.marshal()
+		if err != nil {
+			return fmt.Errorf("Cannot save %s: %v", s.Name(), err)
+		}
+		values[s.Name()] = data
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Written by hand so that each setting is on its own line, in order,
+	// which is friendlier to anyone editing the file.
+	var b bytes.Buffer
+	b.WriteString("{\n")
+	for i, name := range names {
+		key, _ := json.Marshal(name)
+		var value bytes.Buffer
+		if err := json.Compact(&value, values[name]); err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "  %s: %s", key, value.Bytes())
+		if i < len(names)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Reads settings written by Save().  Values that are the wrong type or out of
+// range are ignored, leaving those settings as they were, and the names of
+// those settings are returned in the error, but everything else is still
+// loaded.
+func (c *Config) Load(r io.Reader) error {
+	var values map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&values); err != nil {
+		return err
+	}
+	var bad []string
+	for name, data := range values {
+		c.mutex.Lock()
+		s, ok := c.by_name[name]
+		if !ok {
+			c.pending[name] = data
+		}
+		c.mutex.Unlock()
+		if ok {
+			if err := s.unmarshal(data); err != nil {
+				bad = append(bad, name)
+			}
+		}
+	}
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return fmt.Errorf("Bad values for %v.", bad)
+	}
+	return nil
+}
+
+// Saves the settings to path, replacing what was there only once they have
+// been completely written.  The directory is created if it doesn't exist.
+func (c *Config) SaveFile(path string) error {
+	var b bytes.Buffer
+	if err := c.Save(&b); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b.Bytes())
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Loads the settings at path.  A file that doesn't exist isn't an error, it
+// just means that everything is still at its default.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.Load(f)
+}
+
+// Anything that knows where the user's config directory is, like a
+// system.System.
+type ConfigDirer interface {
+	UserConfigDir(app string) (string, error)
+}
+
+// Returns where app's settings should be saved, settings.json in app's config
+// directory:
+//
+//	path, err := config.UserPath(sys, "mygame")
+func UserPath(dirs ConfigDirer, app string) (string, error) {
+	dir, err := dirs.UserConfigDir(app)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "settings.json"), nil
+}
+
+func Bool(name, label string, def bool) *BoolSetting {
+	return the_config.Bool(name, label, def)
+}
+
+func Int(name, label string, def, min, max int) *IntSetting {
+	return the_config.Int(name, label, def, min, max)
+}
+
+func Float(name, label string, def, min, max float64) *FloatSetting {
+	return the_config.Float(name, label, def, min, max)
+}
+
+func Choice(name, label string, def string, choices []string) *ChoiceSetting {
+	return the_config.Choice(name, label, def, choices)
+}
+
+func Bindings(name, label string, b *gin.Bindings) *BindingsSetting {
+	return the_config.Bindings(name, label, b)
+}
+
+func Settings() []Setting {
+	return the_config.Settings()
+}
+
+func Lookup(name string) Setting {
+	return the_config.Lookup(name)
+}
+
+func RegisterListener(l Listener) {
+	the_config.RegisterListener(l)
+}
+
+func UnregisterListener(l Listener) {
+	the_config.UnregisterListener(l)
+}
+
+func LoadFile(path string) error {
+	return the_config.LoadFile(path)
+}
+
+func SaveFile(path string) error {
+	return the_config.SaveFile(path)
+}
@@ -0,0 +1,132 @@
+package config_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/config"
+	"github.com/runningwild/glop/gin"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type listener struct {
+	changed []string
+}
+
+func (l *listener) SettingChanged(s config.Setting) {
+	l.changed = append(l.changed, s.Name())
+}
+
+func SettingSpec(c gospec.Context) {
+	cfg := config.MakeConfig()
+	vsync := cfg.Bool("vsync", "Vertical sync", true)
+	scale := cfg.Int("scale", "UI scale", 2, 1, 4)
+	volume := cfg.Float("volume", "Volume", 0.8, 0, 1)
+	res := cfg.Choice("resolution", "Resolution", "1280x720", []string{"1280x720", "1920x1080"})
+	var l listener
+	cfg.RegisterListener(&l)
+
+	c.Specify("Settings start at their defaults", func() {
+		c.Expect(vsync.Get(), Equals, true)
+		c.Expect(scale.Get(), Equals, 2)
+		c.Expect(volume.Get(), Equals, 0.8)
+		c.Expect(res.Get(), Equals, "1280x720")
+	})
+
+	c.Specify("Settings are listed in order with enough to build an options screen", func() {
+		settings := cfg.Settings()
+		c.Assume(len(settings), Equals, 4)
+		c.Expect(settings[0].Label(), Equals, "Vertical sync")
+		c.Expect(settings[1].Kind(), Equals, config.KindInt)
+		min, max := settings[1].(*config.IntSetting).Range()
+		c.Expect(min, Equals, 1)
+		c.Expect(max, Equals, 4)
+		c.Expect(settings[3].(*config.ChoiceSetting).Choices(), ContainsInOrder, []string{"1280x720", "1920x1080"})
+		c.Expect(cfg.Lookup("volume"), Equals, config.Setting(volume))
+		c.Expect(cfg.Lookup("nothing"), Equals, nil)
+	})
+
+	c.Specify("Values are clamped and bad choices are ignored", func() {
+		scale.Set(10)
+		volume.Set(-1)
+		res.Set("640x480")
+		c.Expect(scale.Get(), Equals, 4)
+		c.Expect(volume.Get(), Equals, 0.0)
+		c.Expect(res.Get(), Equals, "1280x720")
+	})
+
+	c.Specify("Listeners only hear about real changes", func() {
+		vsync.Set(true)
+		vsync.Set(false)
+		res.Set("1920x1080")
+		scale.Set(2)
+		cfg.Reset()
+		c.Expect(l.changed, ContainsInOrder, []string{"vsync", "resolution", "vsync", "resolution"})
+		c.Expect(len(l.changed), Equals, 4)
+	})
+}
+
+type configDirs string
+
+func (d configDirs) UserConfigDir(app string) (string, error) {
+	return filepath.Join(string(d), app), nil
+}
+
+func SaveSpec(c gospec.Context) {
+	cfg := config.MakeConfig()
+	vsync := cfg.Bool("vsync", "Vertical sync", true)
+	volume := cfg.Float("volume", "Volume", 0.8, 0, 1)
+	input := gin.Make()
+	space := gin.KeyId{Index: gin.Space, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	up := gin.KeyId{Index: gin.Up, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	bindings := input.MakeBindings()
+	bindings.Bind("jump", space)
+	keys := cfg.Bindings("keys", "Controls", bindings)
+
+	c.Specify("Settings round trip, including ones that aren't registered yet", func() {
+		vsync.Set(false)
+		volume.Set(0.25)
+		bindings.Rebind("jump", up)
+		keys.Changed()
+		var b bytes.Buffer
+		c.Assume(cfg.Save(&b), Equals, nil)
+		c.Expect(strings.Count(b.String(), "\n"), Equals, 5)
+
+		loaded := config.MakeConfig()
+		c.Assume(loaded.Load(bytes.NewReader(b.Bytes())), Equals, nil)
+		lvsync := loaded.Bool("vsync", "Vertical sync", true)
+		lvolume := loaded.Float("volume", "Volume", 0.8, 0, 1)
+		lbindings := input.MakeBindings()
+		loaded.Bindings("keys", "Controls", lbindings)
+		c.Expect(lvsync.Get(), Equals, false)
+		c.Expect(lvolume.Get(), Equals, 0.25)
+		c.Expect(lbindings.Keys("jump"), ContainsInOrder, []gin.KeyId{up})
+	})
+
+	c.Specify("Bad values are reported and skipped", func() {
+		err := cfg.Load(strings.NewReader(`{"vsync": "yes", "volume": 0.5}`))
+		c.Assume(err, Not(Equals), nil)
+		c.Expect(err.Error(), Equals, "Bad values for [vsync].")
+		c.Expect(vsync.Get(), Equals, true)
+		c.Expect(volume.Get(), Equals, 0.5)
+	})
+
+	c.Specify("Files are written atomically and missing files are fine", func() {
+		dir, err := os.MkdirTemp("", "config")
+		c.Assume(err, Equals, nil)
+		defer os.RemoveAll(dir)
+		path, err := config.UserPath(configDirs(dir), "game")
+		c.Assume(err, Equals, nil)
+		c.Expect(path, Equals, filepath.Join(dir, "game", "settings.json"))
+		c.Expect(cfg.LoadFile(path), Equals, nil)
+		volume.Set(0.5)
+		c.Assume(cfg.SaveFile(path), Equals, nil)
+		volume.Set(1)
+		c.Assume(cfg.LoadFile(path), Equals, nil)
+		c.Expect(volume.Get(), Equals, 0.5)
+		entries, _ := os.ReadDir(filepath.Dir(path))
+		c.Expect(len(entries), Equals, 1)
+	})
+}
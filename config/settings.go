@@ -0,0 +1,256 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/runningwild/glop/gin"
+)
+
+// What all of the settings with simple values have in common.
+type value[T comparable] struct {
+	c     *Config
+	name  string
+	label string
+	def   T
+	cur   T
+
+	// Returns the value that v should be set to, or an error if there isn't
+	// one.
+	check func(v T) (T, error)
+
+	// The setting that this is part of, for listeners.
+	setting Setting
+}
+
+func (v *value[T]) Name() string {
+	return v.name
+}
+
+func (v *value[T]) Label() string {
+	return v.label
+}
+
+func (v *value[T]) Default() T {
+	return v.def
+}
+
+func (v *value[T]) Get() T {
+	v.c.mutex.Lock()
+	defer v.c.mutex.Unlock()
+	return v.cur
+}
+
+// Sets the value and tells the listeners if it changed.  Values that are out
+// of range are clamped, and anything that isn't one of a ChoiceSetting's
+// choices is ignored.
+func (v *value[T]) Set(t T) {
+	v.set(t)
+}
+
+func (v *value[T]) set(t T) error {
+	t, err := v.check(t)
+	if err != nil {
+		return err
+	}
+	v.c.mutex.Lock()
+	changed := v.cur != t
+	v.cur = t
+	v.c.mutex.Unlock()
+	if changed {
+		v.c.changed(v.setting)
+	}
+	return nil
+}
+
+func (v *value[T]) Reset() {
+	v.set(v.def)
+}
+
+func (v *value[T]) marshal() (json.RawMessage, error) {
+	return json.Marshal(v.Get())
+}
+
+func (v *value[T]) unmarshal(data json.RawMessage) error {
+	var t T
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	return v.set(t)
+}
+
+type BoolSetting struct {
+	value[bool]
+}
+
+func (b *BoolSetting) Kind() Kind {
+	return KindBool
+}
+
+func (c *Config) Bool(name, label string, def bool) *BoolSetting {
+	b := &BoolSetting{value[bool]{c: c, name: name, label: label, def: def, cur: def}}
+	b.check = func(v bool) (bool, error) { return v, nil }
+	b.setting = b
+	c.add(b)
+	return b
+}
+
+type IntSetting struct {
+	value[int]
+	min, max int
+}
+
+func (i *IntSetting) Kind() Kind {
+	return KindInt
+}
+
+func (i *IntSetting) Range() (min, max int) {
+	return i.min, i.max
+}
+
+// Registers an int that is kept between min and max, inclusive.
+func (c *Config) Int(name, label string, def, min, max int) *IntSetting {
+	if min > max || def < min || def > max {
+		panic(fmt.Sprintf("Cannot make setting '%s' with default %d in [%d, %d].", name, def, min, max))
+	}
+	i := &IntSetting{value: value[int]{c: c, name: name, label: label, def: def, cur: def}, min: min, max: max}
+	i.check = func(v int) (int, error) {
+		if v < min {
+			return min, nil
+		}
+		if v > max {
+			return max, nil
+		}
+		return v, nil
+	}
+	i.setting = i
+	c.add(i)
+	return i
+}
+
+type FloatSetting struct {
+	value[float64]
+	min, max float64
+}
+
+func (f *FloatSetting) Kind() Kind {
+	return KindFloat
+}
+
+func (f *FloatSetting) Range() (min, max float64) {
+	return f.min, f.max
+}
+
+// Registers a float that is kept between min and max, inclusive.
+func (c *Config) Float(name, label string, def, min, max float64) *FloatSetting {
+	if min > max || def < min || def > max {
+		panic(fmt.Sprintf("Cannot make setting '%s' with default %v in [%v, %v].", name, def, min, max))
+	}
+	f := &FloatSetting{value: value[float64]{c: c, name: name, label: label, def: def, cur: def}, min: min, max: max}
+	f.check = func(v float64) (float64, error) {
+		if v != v {
+			return 0, fmt.Errorf("Setting '%s' cannot be NaN.", name)
+		}
+		if v < min {
+			return min, nil
+		}
+		if v > max {
+			return max, nil
+		}
+		return v, nil
+	}
+	f.setting = f
+	c.add(f)
+	return f
+}
+
+type ChoiceSetting struct {
+	value[string]
+	choices []string
+}
+
+func (ch *ChoiceSetting) Kind() Kind {
+	return KindChoice
+}
+
+func (ch *ChoiceSetting) Choices() []string {
+	return append([]string(nil), ch.choices...)
+}
+
+// Registers a string that must be one of choices, like a resolution.
+func (c *Config) Choice(name, label string, def string, choices []string) *ChoiceSetting {
+	ch := &ChoiceSetting{value: value[string]{c: c, name: name, label: label, def: def, cur: def}, choices: append([]string(nil), choices...)}
+	ch.check = func(v string) (string, error) {
+		for _, choice := range ch.choices {
+			if v == choice {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("'%s' isn't a choice for setting '%s'.", v, name)
+	}
+	if _, err := ch.check(def); err != nil {
+		panic(err.Error())
+	}
+	ch.setting = ch
+	c.add(ch)
+	return ch
+}
+
+// A BindingsSetting saves a gin.Bindings.  The game rebinds keys on the
+// Bindings directly and then calls Changed().
+type BindingsSetting struct {
+	c        *Config
+	name     string
+	label    string
+	bindings *gin.Bindings
+	def      json.RawMessage
+}
+
+func (b *BindingsSetting) Name() string {
+	return b.name
+}
+
+func (b *BindingsSetting) Label() string {
+	return b.label
+}
+
+func (b *BindingsSetting) Kind() Kind {
+	return KindBindings
+}
+
+func (b *BindingsSetting) Bindings() *gin.Bindings {
+	return b.bindings
+}
+
+// Tells the listeners that the bindings have changed.
+func (b *BindingsSetting) Changed() {
+	b.c.changed(b)
+}
+
+// Sets the bindings back to how they were when they were registered.
+func (b *BindingsSetting) Reset() {
+	b.unmarshal(b.def)
+}
+
+func (b *BindingsSetting) marshal() (json.RawMessage, error) {
+	return json.Marshal(b.bindings)
+}
+
+func (b *BindingsSetting) unmarshal(data json.RawMessage) error {
+	if err := b.bindings.UnmarshalJSON(data); err != nil {
+		// The bindings are left as they were.
+		return err
+	}
+	b.Changed()
+	return nil
+}
+
+// Registers bindings, whose keys as they are now are the default.
+func (c *Config) Bindings(name, label string, bindings *gin.Bindings) *BindingsSetting {
+	def, err := json.Marshal(bindings)
+	if err != nil {
+		panic(err.Error())
+	}
+	b := &BindingsSetting{c: c, name: name, label: label, bindings: bindings, def: def}
+	c.add(b)
+	return b
+}
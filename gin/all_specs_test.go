@@ -17,7 +17,32 @@ func TestAllSpecs(t *testing.T) {
 	r.AddSpec(EventSpec)
 	r.AddSpec(GeneralSpec)
 	r.AddSpec(AxisSpec)
+	r.AddSpec(WheelSpec)
+	r.AddSpec(AxisConfigSpec)
+	r.AddSpec(AssistSpec)
 	r.AddSpec(EventListenerSpec)
+	r.AddSpec(ListenerPrioritySpec)
+	r.AddSpec(ContextSpec)
 	r.AddSpec(FocusSpec)
+	r.AddSpec(PollSpec)
+	r.AddSpec(TimestampSpec)
+	r.AddSpec(LatencySpec)
+	r.AddSpec(BindingsSpec)
+	r.AddSpec(KeyNameSpec)
+	r.AddSpec(DevicesSpec)
+	r.AddSpec(ResizeSpec)
+	r.AddSpec(WindowEventSpec)
+	r.AddSpec(FileDropSpec)
+	r.AddSpec(TextSpec)
+	r.AddSpec(ChordKeySpec)
+	r.AddSpec(SequenceKeySpec)
+	r.AddSpec(DoubleClickKeySpec)
+	r.AddSpec(AxisKeySpec)
+	r.AddSpec(Vector2KeySpec)
+	r.AddSpec(RecordSpec)
+	r.AddSpec(InjectSpec)
+	r.AddSpec(CursorSpec)
+	r.AddSpec(DoubleClickCursorSpec)
+	r.AddSpec(TouchSpec)
 	gospec.MainGoTest(r, t)
 }
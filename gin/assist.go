@@ -0,0 +1,250 @@
+package gin
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A KeyAssist changes when a key is reported as pressed and released, for
+// players who have trouble holding keys down, pressing them briefly, or
+// pressing them quickly.  Assists are applied to the events from the OS
+// before anything else sees them, so keys, derived keys, Bindings, and
+// listeners all see the assisted presses.  Assisted keys are treated as
+// buttons, any press amount other than 0 is a press and they are only ever
+// pressed with an amount of 1.
+//
+// The parts of an assist are applied in order: Hold decides when the key
+// counts as down, Toggle turns those presses into on and off, and Repeat
+// repeats the key while it's on.
+type KeyAssist struct {
+	// If Hold is greater than 0 the key has to be held down for Hold ms
+	// before it is pressed, anything shorter is ignored.  This helps with
+	// tremors and accidental presses.
+	Hold int64
+
+	// If Toggle is true then pressing the key presses it and leaves it down
+	// until the next time it is pressed, like sticky keys.
+	Toggle bool
+
+	// If Repeat is greater than 0 then while the key is down it is released
+	// and pressed again every Repeat ms, starting Delay ms after it was
+	// pressed, or Repeat ms after if Delay is 0.
+	Repeat int64
+	Delay  int64
+}
+
+// The state of a key that has an assist.
+type assistState struct {
+	assist KeyAssist
+
+	// Whether the key is down according to the OS, and when it went down.
+	raw       bool
+	raw_since int64
+
+	// Whether the key is down after Hold is applied.
+	held bool
+
+	// Whether the key is down as far as the rest of gin is concerned, and
+	// when it should next be repeated, if it is repeating.
+	on          bool
+	next_repeat int64
+}
+
+// ConfigureAssist sets the KeyAssist for the key specified by id.  id may use
+// DeviceIndexAny to assist that key on all devices of a type, an assist for a
+// specific device takes precedence over one for DeviceIndexAny.  The zero
+// KeyAssist turns assistance off.  Keys that an assist was holding down are
+// released during the next call to Think().
+func (input *Input) ConfigureAssist(id KeyId, assist KeyAssist) {
+	if id.Device.Type == DeviceTypeAny || id.Device.Type == DeviceTypeDerived || id.Index == AnyKey {
+		panic(fmt.Sprintf("Cannot assist %v, it must be a single key on a real device.", id))
+	}
+	if input.index_to_agg_type[id.Index] == aggregatorTypeAxis {
+		panic(fmt.Sprintf("Cannot assist %v, it is an axis.", id))
+	}
+	if assist.Hold < 0 || assist.Repeat < 0 || assist.Delay < 0 {
+		panic(fmt.Sprintf("Cannot assist %v with negative times.", id))
+	}
+	if input.assists == nil {
+		input.assists = make(map[KeyId]KeyAssist)
+		input.assist_states = make(map[KeyId]*assistState)
+	}
+	if assist == (KeyAssist{}) {
+		delete(input.assists, id)
+	} else {
+		input.assists[id] = assist
+	}
+	// Start over with any keys that this affects.
+	for state_id, state := range input.assist_states {
+		if state_id.Index != id.Index || state_id.Device.Type != id.Device.Type {
+			continue
+		}
+		if id.Device.Index != DeviceIndexAny && state_id.Device.Index != id.Device.Index {
+			continue
+		}
+		if state.on {
+			input.assist_releases = append(input.assist_releases, state_id)
+		}
+		delete(input.assist_states, state_id)
+	}
+}
+
+func (input *Input) getAssist(id KeyId) (KeyAssist, bool) {
+	if assist, ok := input.assists[id]; ok {
+		return assist, true
+	}
+	id.Device.Index = DeviceIndexAny
+	assist, ok := input.assists[id]
+	return assist, ok
+}
+
+// Returns the next time that something will happen to state on its own, or
+// false if nothing will.
+func (state *assistState) deadline() (int64, bool) {
+	if state.raw && !state.held && state.assist.Hold > 0 {
+		return state.raw_since + state.assist.Hold, true
+	}
+	if state.on && state.next_repeat > 0 {
+		return state.next_repeat, true
+	}
+	return 0, false
+}
+
+// Returns os_events with the assists applied, along with events for holds
+// that finish and keys that repeat up to t.  The events returned are sorted
+// and have had their timestamps clamped, os_events itself is not modified.
+func (input *Input) applyAssists(t int64, os_events []OsEvent) []OsEvent {
+	if len(input.assist_states) == 0 && len(input.assists) == 0 && len(input.assist_releases) == 0 {
+		return os_events
+	}
+	sorted := make([]OsEvent, len(os_events))
+	copy(sorted, os_events)
+	for i := range sorted {
+		sorted[i].Timestamp = input.clampTimestamp(t, sorted[i].Timestamp)
+	}
+	sort.Stable(osEventsByTime(sorted))
+	os_events = sorted
+
+	var events []OsEvent
+	emit := func(id KeyId, ts int64, down bool) {
+		var amt float64
+		if down {
+			amt = 1
+		}
+		events = append(events, OsEvent{KeyId: id, Press_amt: amt, Timestamp: ts})
+	}
+	for _, id := range input.assist_releases {
+		ts := t
+		if len(os_events) > 0 {
+			ts = os_events[0].Timestamp
+		}
+		emit(id, ts, false)
+	}
+	input.assist_releases = nil
+
+	var setOn func(id KeyId, state *assistState, on bool, ts int64)
+	setOn = func(id KeyId, state *assistState, on bool, ts int64) {
+		if state.on == on {
+			return
+		}
+		state.on = on
+		emit(id, ts, on)
+		state.next_repeat = 0
+		if on && state.assist.Repeat > 0 {
+			state.next_repeat = ts + state.assist.Repeat
+			if state.assist.Delay > 0 {
+				state.next_repeat = ts + state.assist.Delay
+			}
+		}
+	}
+	setHeld := func(id KeyId, state *assistState, held bool, ts int64) {
+		if state.held == held {
+			return
+		}
+		state.held = held
+		if !state.assist.Toggle {
+			setOn(id, state, held, ts)
+		} else if held {
+			setOn(id, state, !state.on, ts)
+		}
+	}
+
+	// Handles everything that happens on its own before limit, or at limit
+	// if inclusive, in order.
+	think := func(limit int64, inclusive bool) {
+		for {
+			var next KeyId
+			var next_state *assistState
+			var when int64
+			for id, state := range input.assist_states {
+				d, ok := state.deadline()
+				if !ok || d > limit || (d == limit && !inclusive) {
+					continue
+				}
+				if next_state == nil || d < when || (d == when && keyIdLess(id, next)) {
+					next, next_state, when = id, state, d
+				}
+			}
+			if next_state == nil {
+				return
+			}
+			if next_state.raw && !next_state.held && next_state.assist.Hold > 0 {
+				setHeld(next, next_state, true, when)
+			} else {
+				emit(next, when, false)
+				emit(next, when, true)
+				next_state.next_repeat += next_state.assist.Repeat
+			}
+		}
+	}
+
+	for _, event := range os_events {
+		assist, ok := input.getAssist(event.KeyId)
+		if !ok {
+			think(event.Timestamp, false)
+			events = append(events, event)
+			continue
+		}
+		// A hold that finishes at the same time the key is released counts.
+		think(event.Timestamp, true)
+		state := input.assist_states[event.KeyId]
+		if state == nil {
+			state = &assistState{assist: assist}
+			input.assist_states[event.KeyId] = state
+		}
+		raw := event.Press_amt != 0
+		if raw == state.raw {
+			continue
+		}
+		state.raw = raw
+		if raw {
+			state.raw_since = event.Timestamp
+			if assist.Hold == 0 {
+				setHeld(event.KeyId, state, true, event.Timestamp)
+			}
+		} else {
+			setHeld(event.KeyId, state, false, event.Timestamp)
+		}
+	}
+	think(t, true)
+	return events
+}
+
+func keyIdLess(a, b KeyId) bool {
+	if a.Device.Type != b.Device.Type {
+		return a.Device.Type < b.Device.Type
+	}
+	if a.Device.Index != b.Device.Index {
+		return a.Device.Index < b.Device.Index
+	}
+	return a.Index < b.Index
+}
+
+// Forgets the state of every assisted key, for when the window loses focus
+// and every key is released.
+func (input *Input) resetAssists() {
+	for id := range input.assist_states {
+		delete(input.assist_states, id)
+	}
+	input.assist_releases = nil
+}
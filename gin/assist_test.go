@@ -0,0 +1,113 @@
+package gin_test
+
+import (
+	"fmt"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+// Records the presses and releases of one key as "+t" and "-t".
+type pressRecorder struct {
+	key   gin.KeyId
+	times []string
+}
+
+func (r *pressRecorder) HandleEventGroup(group gin.EventGroup) {
+	for _, e := range group.Events {
+		if e.Key.Id() != r.key {
+			continue
+		}
+		switch e.Type {
+		case gin.Press:
+			r.times = append(r.times, fmt.Sprintf("+%d", group.Timestamp))
+		case gin.Release:
+			r.times = append(r.times, fmt.Sprintf("-%d", group.Timestamp))
+		}
+	}
+}
+
+func (r *pressRecorder) Think() {}
+
+func AssistSpec(c gospec.Context) {
+	input := gin.Make()
+	space := gin.KeyId{Index: gin.Space, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	keyw := gin.KeyId{Index: gin.KeyW, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	rec := &pressRecorder{key: space}
+	input.RegisterEventListener(rec)
+	press := func(key gin.KeyIndex, amt float64, t int64) []gin.OsEvent {
+		var events []gin.OsEvent
+		injectEvent(&events, key, 1, gin.DeviceTypeKeyboard, amt, t)
+		return events
+	}
+
+	c.Specify("Toggled keys stay down until they are pressed again.", func() {
+		input.ConfigureAssist(space, gin.KeyAssist{Toggle: true})
+		input.Think(10, true, press(gin.Space, 1, 5))
+		input.Think(20, true, press(gin.Space, 0, 15))
+		c.Expect(input.GetKey(space).IsDown(), Equals, true)
+		input.Think(30, true, press(gin.Space, 1, 25))
+		input.Think(40, true, press(gin.Space, 0, 35))
+		c.Expect(input.GetKey(space).IsDown(), Equals, false)
+		c.Expect(rec.times, ContainsInOrder, []string{"+5", "-25"})
+		c.Expect(len(rec.times), Equals, 2)
+	})
+
+	c.Specify("Held keys are only pressed once they've been held long enough.", func() {
+		input.ConfigureAssist(gin.KeyId{Index: gin.Space, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: gin.DeviceIndexAny}}, gin.KeyAssist{Hold: 100})
+		input.Think(10, true, press(gin.Space, 1, 10))
+		input.Think(60, true, press(gin.Space, 0, 60))
+		c.Expect(len(rec.times), Equals, 0)
+		input.Think(200, true, press(gin.Space, 1, 150))
+		c.Expect(input.GetKey(space).IsDown(), Equals, false)
+		input.Think(300, true, nil)
+		c.Expect(input.GetKey(space).IsDown(), Equals, true)
+		input.Think(400, true, press(gin.Space, 0, 320))
+		c.Expect(rec.times, ContainsInOrder, []string{"+250", "-320"})
+		c.Expect(len(rec.times), Equals, 2)
+	})
+
+	c.Specify("Repeating keys are released and pressed again while they're down.", func() {
+		input.ConfigureAssist(space, gin.KeyAssist{Repeat: 50, Delay: 200})
+		input.Think(100, true, press(gin.Space, 1, 100))
+		input.Think(400, true, nil)
+		input.Think(500, true, press(gin.Space, 0, 420))
+		c.Expect(rec.times, ContainsInOrder, []string{"+100", "-300", "+300", "-350", "+350", "-400", "+400", "-420"})
+		c.Expect(len(rec.times), Equals, 8)
+	})
+
+	c.Specify("Other keys aren't affected and events stay in order.", func() {
+		input.ConfigureAssist(space, gin.KeyAssist{Hold: 30})
+		all := &pressRecorder{key: keyw}
+		input.RegisterEventListener(all)
+		var events []gin.OsEvent
+		injectEvent(&events, gin.KeyW, 1, gin.DeviceTypeKeyboard, 0, 50)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 1, 10)
+		injectEvent(&events, gin.KeyW, 1, gin.DeviceTypeKeyboard, 1, 20)
+		input.Think(100, true, events)
+		c.Expect(rec.times, ContainsInOrder, []string{"+40"})
+		c.Expect(all.times, ContainsInOrder, []string{"+20", "-50"})
+	})
+
+	c.Specify("Turning off an assist releases anything it was holding down.", func() {
+		input.ConfigureAssist(space, gin.KeyAssist{Toggle: true})
+		input.Think(10, true, press(gin.Space, 1, 5))
+		input.Think(20, true, press(gin.Space, 0, 15))
+		input.ConfigureAssist(space, gin.KeyAssist{})
+		input.Think(30, true, nil)
+		c.Expect(input.GetKey(space).IsDown(), Equals, false)
+		input.Think(40, true, press(gin.Space, 1, 35))
+		input.Think(50, true, press(gin.Space, 0, 45))
+		c.Expect(rec.times, ContainsInOrder, []string{"+5", "-30", "+35", "-45"})
+	})
+
+	c.Specify("Losing focus releases toggled keys.", func() {
+		input.ConfigureAssist(space, gin.KeyAssist{Toggle: true})
+		input.Think(10, true, press(gin.Space, 1, 5))
+		input.Think(20, true, press(gin.Space, 0, 15))
+		input.Think(30, false, nil)
+		c.Expect(input.GetKey(space).IsDown(), Equals, false)
+		input.Think(40, true, press(gin.Space, 1, 35))
+		c.Expect(input.GetKey(space).IsDown(), Equals, true)
+	})
+}
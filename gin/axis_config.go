@@ -0,0 +1,122 @@
+package gin
+
+import (
+	"fmt"
+	"math"
+)
+
+// An AxisConfig describes how the raw press amounts reported for an analog
+// key should be calibrated before they are used to update that key or any
+// derived keys that depend on it.
+type AxisConfig struct {
+	// Press amounts with a magnitude no greater than DeadZone are treated as
+	// zero.  For controller axes, which report press amounts between 0 and 1,
+	// the remaining range is stretched so that amounts just outside of the dead
+	// zone start near 0 rather than jumping to DeadZone.  For mouse axes the
+	// dead zone is in pixels, so it is just a threshold.
+	DeadZone float64
+
+	// After the dead zone is applied the magnitude of the press amount is
+	// raised to the power Curve, so values above 1 give finer control near the
+	// center of a controller axis and acceleration on a mouse axis.  The zero
+	// value is treated as 1, which leaves the press amount unchanged.
+	Curve float64
+
+	// If Invert is true the direction of the axis is reversed.  For controller
+	// axes this means that the positive and negative halves are swapped.
+	Invert bool
+}
+
+// ConfigureAxis sets the AxisConfig used for the analog key specified by id.
+// Controller axes are configured as a whole, so the positive and negative
+// halves of an axis share a config, and either one may be given.  id may use
+// DeviceIndexAny to configure that axis on all devices of a type, a config
+// for a specific device takes precedence over one for DeviceIndexAny.
+func (input *Input) ConfigureAxis(id KeyId, config AxisConfig) {
+	if !isControllerAxis(id.Index) && input.index_to_agg_type[id.Index] != aggregatorTypeAxis {
+		panic(fmt.Sprintf("Cannot configure %v as an axis, it is not an analog key.", id))
+	}
+	id.Index = axisConfigIndex(id.Index)
+	if input.axis_configs == nil {
+		input.axis_configs = make(map[KeyId]AxisConfig)
+	}
+	input.axis_configs[id] = config
+}
+
+func isControllerAxis(index KeyIndex) bool {
+	return index >= ControllerAxis0Positive && index < ControllerHatSwitchUp
+}
+
+// Both halves of a controller axis are configured with the index of the
+// positive half.
+func axisConfigIndex(index KeyIndex) KeyIndex {
+	if index >= ControllerAxis0Negative && index < ControllerHatSwitchUp {
+		return index - ControllerAxis0Negative + ControllerAxis0Positive
+	}
+	return index
+}
+
+func (input *Input) getAxisConfig(id KeyId) (AxisConfig, bool) {
+	id.Index = axisConfigIndex(id.Index)
+	if config, ok := input.axis_configs[id]; ok {
+		return config, true
+	}
+	id.Device.Index = DeviceIndexAny
+	config, ok := input.axis_configs[id]
+	return config, ok
+}
+
+// Returns events with any relevant AxisConfigs applied.  events itself is not
+// modified.
+func (input *Input) applyAxisConfigs(os_events []OsEvent) []OsEvent {
+	if len(input.axis_configs) == 0 {
+		return os_events
+	}
+	events := make([]OsEvent, len(os_events))
+	copy(events, os_events)
+	for i := range events {
+		config, ok := input.getAxisConfig(events[i].KeyId)
+		if !ok {
+			continue
+		}
+		index := events[i].KeyId.Index
+		if !isControllerAxis(index) {
+			events[i].Press_amt = config.apply(events[i].Press_amt, false)
+			continue
+		}
+		events[i].Press_amt = config.apply(events[i].Press_amt, true)
+		if config.Invert {
+			if index >= ControllerAxis0Negative {
+				index = index - ControllerAxis0Negative + ControllerAxis0Positive
+			} else {
+				index = index - ControllerAxis0Positive + ControllerAxis0Negative
+			}
+			events[i].KeyId.Index = index
+		}
+	}
+	return events
+}
+
+// Returns amt after applying the dead zone and curve.  If normalized is true
+// then amt is in [-1, 1] and the range outside of the dead zone is stretched
+// to fill it, otherwise amt is a mouse delta and is negated if the axis is
+// inverted.
+func (config AxisConfig) apply(amt float64, normalized bool) float64 {
+	mag := math.Abs(amt)
+	if mag <= config.DeadZone {
+		return 0
+	}
+	if normalized {
+		mag = math.Min(1, (mag-config.DeadZone)/(1-config.DeadZone))
+	}
+	if config.Curve != 0 {
+		mag = math.Pow(mag, config.Curve)
+	}
+	if amt < 0 {
+		mag = -mag
+	}
+	if config.Invert && !normalized {
+		mag = -mag
+	}
+	return mag
+}
@@ -0,0 +1,108 @@
+package gin
+
+import (
+	"fmt"
+	"math"
+)
+
+// MakeAxisKey returns a derived key whose press amount is the press amount of
+// pos minus that of neg, so that code that moves something back and forth can
+// read one key instead of two.  It is pressed when it goes from zero to
+// anything else, released when it goes back to zero, and sends an Adjust
+// event whenever it changes in between, including when it goes straight from
+// negative to positive.
+func (input *Input) MakeAxisKey(neg, pos KeyId) Key {
+	ak := &axisKey{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       fmt.Sprintf("Axis(%s)", input.comboName([]KeyId{neg, pos}, ", ")),
+			aggregator: &standardAggregator{},
+		},
+		input: input,
+		neg:   neg,
+		pos:   pos,
+	}
+	input.registerComboKey(ak, []KeyId{neg, pos})
+	return ak
+}
+
+type axisKey struct {
+	keyState
+	input    *Input
+	neg, pos KeyId
+}
+
+func (ak *axisKey) SetPressAmt(amt float64, ms int64, cause Event) Event {
+	value := ak.input.GetKey(ak.pos).CurPressAmt() - ak.input.GetKey(ak.neg).CurPressAmt()
+	return ak.keyState.SetPressAmt(value, ms, cause)
+}
+
+// A Vector2Key combines four keys into a direction, like WASD or the two
+// axes of an analog stick.
+type Vector2Key interface {
+	Key
+
+	// Returns right minus left and up minus down, scaled down if needed so
+	// that the vector is no longer than 1.  This way moving diagonally with
+	// two keys isn't faster than moving with one.
+	Vector() (x, y float64)
+}
+
+// MakeVector2Key returns a derived key whose Vector() is the direction that
+// left, right, up, and down are pushing in, and whose press amount is the
+// length of that vector.  It sends events like a key made with MakeAxisKey(),
+// and also sends an Adjust event when the direction changes without the
+// length changing.
+func (input *Input) MakeVector2Key(left, right, up, down KeyId) Vector2Key {
+	keys := []KeyId{left, right, up, down}
+	vk := &vector2Key{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       fmt.Sprintf("Vector2(%s)", input.comboName(keys, ", ")),
+			aggregator: &standardAggregator{},
+		},
+		input: input,
+		keys:  keys,
+	}
+	input.registerComboKey(vk, keys)
+	return vk
+}
+
+type vector2Key struct {
+	keyState
+	input *Input
+
+	// left, right, up, down
+	keys []KeyId
+
+	x, y float64
+}
+
+func (vk *vector2Key) Vector() (x, y float64) {
+	return vk.x, vk.y
+}
+
+func (vk *vector2Key) SetPressAmt(amt float64, ms int64, cause Event) Event {
+	amts := make([]float64, len(vk.keys))
+	for i, key := range vk.keys {
+		amts[i] = vk.input.GetKey(key).CurPressAmt()
+	}
+	x, y := amts[1]-amts[0], amts[2]-amts[3]
+	length := math.Sqrt(x*x + y*y)
+	if length > 1 {
+		x, y, length = x/length, y/length, 1
+	}
+	turned := x != vk.x || y != vk.y
+	vk.x, vk.y = x, y
+	event := vk.keyState.SetPressAmt(length, ms, cause)
+	if event.Type == NoEvent && turned && length != 0 {
+		event.Type = Adjust
+	}
+	return event
+}
@@ -0,0 +1,108 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+// Returns the type of the event for id in the group at timestamp, or NoEvent.
+func eventTypeAt(groups []gin.EventGroup, id gin.KeyId, timestamp int64) gin.EventType {
+	for _, group := range groups {
+		if group.Timestamp != timestamp {
+			continue
+		}
+		if found, event := group.FindEvent(id); found {
+			return event.Type
+		}
+	}
+	return gin.NoEvent
+}
+
+func AxisKeySpec(c gospec.Context) {
+	input := gin.Make()
+	keya := gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	keyd := gin.KeyId{Index: gin.KeyD, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	axis := input.MakeAxisKey(keya, keyd)
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Axis keys are pos minus neg.", func() {
+		injectEvent(&events, gin.KeyD, 1, gin.DeviceTypeKeyboard, 1, 1)
+		input.Think(2, true, events)
+		events = events[0:0]
+		c.Expect(axis.CurPressAmt(), Equals, 1.0)
+		c.Expect(axis.FramePressCount(), Equals, 1)
+
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 3)
+		input.Think(4, true, events)
+		events = events[0:0]
+		c.Expect(axis.CurPressAmt(), Equals, 0.0)
+		c.Expect(axis.FrameReleaseCount(), Equals, 1)
+
+		injectEvent(&events, gin.KeyD, 1, gin.DeviceTypeKeyboard, 0, 5)
+		input.Think(6, true, events)
+		c.Expect(axis.CurPressAmt(), Equals, -1.0)
+		c.Expect(axis.IsDown(), Equals, true)
+	})
+
+	c.Specify("Axis keys send events as they cross zero.", func() {
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 1)
+		injectEvent(&events, gin.KeyD, 1, gin.DeviceTypeKeyboard, 1, 2)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 3)
+		groups := input.Think(4, true, events)
+		c.Expect(eventTypeAt(groups, axis.Id(), 1), Equals, gin.Press)
+		c.Expect(eventTypeAt(groups, axis.Id(), 2), Equals, gin.Release)
+		c.Expect(eventTypeAt(groups, axis.Id(), 3), Equals, gin.Press)
+		c.Expect(axis.CurPressAmt(), Equals, 1.0)
+	})
+}
+
+func Vector2KeySpec(c gospec.Context) {
+	input := gin.Make()
+	kb := func(index gin.KeyIndex) gin.KeyId {
+		return gin.KeyId{Index: index, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	}
+	wasd := input.MakeVector2Key(kb(gin.KeyA), kb(gin.KeyD), kb(gin.KeyW), kb(gin.KeyS))
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Vector2 keys point where their keys push.", func() {
+		injectEvent(&events, gin.KeyW, 1, gin.DeviceTypeKeyboard, 1, 1)
+		input.Think(2, true, events)
+		events = events[0:0]
+		x, y := wasd.Vector()
+		c.Expect(x, Equals, 0.0)
+		c.Expect(y, Equals, 1.0)
+		c.Expect(wasd.CurPressAmt(), Equals, 1.0)
+		c.Expect(wasd.FramePressCount(), Equals, 1)
+
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 3)
+		input.Think(4, true, events)
+		events = events[0:0]
+		x, y = wasd.Vector()
+		c.Expect(x < 0 && y > 0, IsTrue)
+		c.Expect(x*x+y*y > 0.999 && x*x+y*y < 1.001, IsTrue)
+		c.Expect(wasd.CurPressAmt(), Equals, 1.0)
+
+		injectEvent(&events, gin.KeyW, 1, gin.DeviceTypeKeyboard, 0, 5)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 6)
+		input.Think(7, true, events)
+		x, y = wasd.Vector()
+		c.Expect(x, Equals, 0.0)
+		c.Expect(y, Equals, 0.0)
+		c.Expect(wasd.IsDown(), Equals, false)
+		c.Expect(wasd.FrameReleaseCount(), Equals, 1)
+	})
+
+	c.Specify("Vector2 keys adjust when they turn.", func() {
+		injectEvent(&events, gin.KeyW, 1, gin.DeviceTypeKeyboard, 1, 1)
+		injectEvent(&events, gin.KeyD, 1, gin.DeviceTypeKeyboard, 1, 2)
+		injectEvent(&events, gin.KeyW, 1, gin.DeviceTypeKeyboard, 0, 3)
+		groups := input.Think(4, true, events)
+		c.Expect(eventTypeAt(groups, wasd.Id(), 1), Equals, gin.Press)
+		c.Expect(eventTypeAt(groups, wasd.Id(), 2), Equals, gin.Adjust)
+		c.Expect(eventTypeAt(groups, wasd.Id(), 3), Equals, gin.Adjust)
+		x, y := wasd.Vector()
+		c.Expect(x, Equals, 1.0)
+		c.Expect(y, Equals, 0.0)
+	})
+}
@@ -0,0 +1,293 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Bindings maps named actions, like "jump" or "fire", to one or more keys.
+// Game code can then ask about the action rather than the keys, and the keys
+// can be changed at runtime or loaded from a user's config file without the
+// game code needing to know about it.
+//
+// Any key that Input.GetKey() understands can be bound to an action, including
+// derived keys and general keys like AnySpace.
+type Bindings struct {
+	input *Input
+
+	// map from action name to all of the keys bound to that action
+	actions map[string][]KeyId
+
+	// down state of each action as of the last EventGroup passed to Translate(),
+	// this is used to turn key events into action events.
+	down map[string]bool
+
+	// if non-nil, actions are only down while this context is active
+	context *InputContext
+}
+
+// An ActionEvent is generated by Bindings.Translate() when an action goes down,
+// goes up, or has its press amount changed.
+type ActionEvent struct {
+	Action string
+	Type   EventType
+
+	// The key event that caused this action event.
+	Event Event
+}
+
+// A BindingConflict describes a key that is bound to more than one action.
+type BindingConflict struct {
+	Key     KeyId
+	Actions []string
+}
+
+// MakeBindings returns an empty set of bindings for keys on this Input.
+func (input *Input) MakeBindings() *Bindings {
+	return &Bindings{
+		input:   input,
+		actions: make(map[string][]KeyId),
+		down:    make(map[string]bool),
+	}
+}
+
+// Bind adds keys to the set of keys that trigger action.  Any keys already
+// bound to action remain bound to it.
+func (b *Bindings) Bind(action string, keys ...KeyId) {
+	for _, key := range keys {
+		// Makes sure that the key exists, this will panic on invalid keys, which
+		// is better to do here than the first time someone presses it.
+		b.input.GetKey(key)
+		if !b.isBound(action, key) {
+			b.actions[action] = append(b.actions[action], key)
+		}
+	}
+	if _, ok := b.actions[action]; !ok {
+		b.actions[action] = nil
+	}
+}
+
+// Rebind replaces all keys bound to action with keys.  Returns the list of
+// other actions that are also bound to any of the keys, which will be empty
+// if there are no conflicts.
+func (b *Bindings) Rebind(action string, keys ...KeyId) []string {
+	delete(b.actions, action)
+	b.Bind(action, keys...)
+	conflicts := make(map[string]bool)
+	for _, key := range keys {
+		for _, other := range b.ConflictsWith(key) {
+			if other != action {
+				conflicts[other] = true
+			}
+		}
+	}
+	var ret []string
+	for other := range conflicts {
+		ret = append(ret, other)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Unbind removes action and all of its keys.
+func (b *Bindings) Unbind(action string) {
+	delete(b.actions, action)
+	delete(b.down, action)
+}
+
+func (b *Bindings) isBound(action string, id KeyId) bool {
+	for _, key := range b.actions[action] {
+		if key == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Actions returns the names of all bound actions in sorted order.
+func (b *Bindings) Actions() []string {
+	var ret []string
+	for action := range b.actions {
+		ret = append(ret, action)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Keys returns the keys bound to action, in the order that they were bound.
+func (b *Bindings) Keys(action string) []KeyId {
+	keys := make([]KeyId, len(b.actions[action]))
+	copy(keys, b.actions[action])
+	return keys
+}
+
+// ConflictsWith returns all actions that id is bound to, in sorted order.
+// Only exact matches are reported, so binding Space on keyboard 1 and AnySpace
+// to different actions is not considered a conflict.
+func (b *Bindings) ConflictsWith(id KeyId) []string {
+	var ret []string
+	for action := range b.actions {
+		if b.isBound(action, id) {
+			ret = append(ret, action)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Conflicts returns a BindingConflict for every key that is bound to more than
+// one action.
+func (b *Bindings) Conflicts() []BindingConflict {
+	seen := make(map[KeyId]bool)
+	var ret []BindingConflict
+	for _, action := range b.Actions() {
+		for _, key := range b.actions[action] {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if actions := b.ConflictsWith(key); len(actions) > 1 {
+				ret = append(ret, BindingConflict{Key: key, Actions: actions})
+			}
+		}
+	}
+	return ret
+}
+
+// IsDown returns true iff any key bound to action is down.  Unknown actions are
+// never down, and neither are any actions in bindings made with
+// InputContext.MakeBindings() while that context is not active.
+func (b *Bindings) IsDown(action string) bool {
+	if b.context != nil && !b.context.IsActive() {
+		return false
+	}
+	for _, key := range b.actions[action] {
+		if b.input.GetKey(key).IsDown() {
+			return true
+		}
+	}
+	return false
+}
+
+// PressAmt returns the sum of the current press amounts of all keys bound to
+// action.
+func (b *Bindings) PressAmt(action string) float64 {
+	sum := 0.0
+	if b.context != nil && !b.context.IsActive() {
+		return sum
+	}
+	for _, key := range b.actions[action] {
+		sum += b.input.GetKey(key).CurPressAmt()
+	}
+	return sum
+}
+
+// Translate converts the key events in group into action events.  An action
+// gets a Press event when the first of its keys goes down and a Release event
+// when the last of its keys goes up, Adjust events are passed along while it
+// is down.  Translate should be called from HandleEventGroup() with every
+// EventGroup, in order, so that the action states are kept up to date.
+func (b *Bindings) Translate(group EventGroup) []ActionEvent {
+	var ret []ActionEvent
+	for _, action := range b.Actions() {
+		for _, event := range group.Events {
+			if !b.isBound(action, event.Key.Id()) {
+				continue
+			}
+			was_down := b.down[action]
+			is_down := b.IsDown(action)
+			b.down[action] = is_down
+			ae := ActionEvent{Action: action, Event: event}
+			switch {
+			case is_down && !was_down:
+				ae.Type = Press
+			case !is_down && was_down:
+				ae.Type = Release
+			case is_down && event.Type == Adjust:
+				ae.Type = Adjust
+			default:
+				continue
+			}
+			ret = append(ret, ae)
+			break
+		}
+	}
+	return ret
+}
+
+// MarshalJSON encodes the bindings as an object mapping action names to lists
+// of keys.
+func (b *Bindings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.actions)
+}
+
+// UnmarshalJSON replaces all of the bindings with those in data.  The Bindings
+// must have been created with Input.MakeBindings() so that the keys can be
+// validated.
+func (b *Bindings) UnmarshalJSON(data []byte) error {
+	if b.input == nil {
+		return fmt.Errorf("Cannot unmarshal into Bindings that were not made with MakeBindings().")
+	}
+	var actions map[string][]KeyId
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return err
+	}
+	for action, keys := range actions {
+		for _, key := range keys {
+			if err := b.input.validKeyId(key); err != nil {
+				return fmt.Errorf("Action '%s': %v", action, err)
+			}
+		}
+	}
+	b.actions = make(map[string][]KeyId)
+	b.down = make(map[string]bool)
+	for action, keys := range actions {
+		b.Bind(action, keys...)
+	}
+	return nil
+}
+
+// Save writes the bindings to w as JSON, suitable for a user config file.
+func (b *Bindings) Save(w io.Writer) error {
+	data, err := json.MarshalIndent(b.actions, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Load replaces the bindings with those read from r, which should have been
+// written by Save().
+func (b *Bindings) Load(r io.Reader) error {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return err
+	}
+	return b.UnmarshalJSON(raw)
+}
+
+// validKeyId returns an error if GetKey() would panic when given id.
+func (input *Input) validKeyId(id KeyId) error {
+	if id.Device.Type >= DeviceTypeMax || id.Device.Type < 0 {
+		return fmt.Errorf("Invalid DeviceType, %d.", id.Device.Type)
+	}
+	if _, ok := input.key_map[id]; ok {
+		return nil
+	}
+	if _, ok := input.index_to_family[id.Index]; ok {
+		return nil
+	}
+	if id.Index == AnyKey || id.Device.Type == DeviceTypeAny || id.Device.Index == DeviceIndexAny {
+		if id.Device.Type == DeviceTypeAny && id.Device.Index != DeviceIndexAny {
+			return fmt.Errorf("Cannot specify a Device Index but not a Device Type.")
+		}
+		return nil
+	}
+	if _, ok := input.index_to_agg_type[id.Index]; !ok {
+		return fmt.Errorf("No key registered with id == %v.", id)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package gin_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func BindingsSpec(c gospec.Context) {
+	input := gin.Make()
+	space := gin.KeyId{Index: gin.Space, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	keyw := gin.KeyId{Index: gin.KeyW, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	up := gin.KeyId{Index: gin.Up, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	bindings := input.MakeBindings()
+	bindings.Bind("jump", space, up)
+	bindings.Bind("forward", keyw)
+
+	c.Specify("Actions are down iff one of their keys is down.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Up, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(bindings.IsDown("jump"), Equals, true)
+		c.Expect(bindings.IsDown("forward"), Equals, false)
+		c.Expect(bindings.IsDown("unknown"), Equals, false)
+	})
+
+	c.Specify("Rebinding replaces keys and reports conflicts.", func() {
+		conflicts := bindings.Rebind("forward", keyw, up)
+		c.Expect(conflicts, ContainsInOrder, []string{"jump"})
+		c.Expect(bindings.Keys("forward"), ContainsInOrder, []gin.KeyId{keyw, up})
+		all := bindings.Conflicts()
+		c.Expect(len(all), Equals, 1)
+		c.Expect(all[0].Key, Equals, up)
+		c.Expect(all[0].Actions, ContainsInOrder, []string{"forward", "jump"})
+
+		conflicts = bindings.Rebind("forward", keyw)
+		c.Expect(len(conflicts), Equals, 0)
+		c.Expect(len(bindings.Conflicts()), Equals, 0)
+	})
+
+	c.Specify("Key events are translated into action events.", func() {
+		var actions []gin.ActionEvent
+		listener := &translator{bindings: bindings, actions: &actions}
+		input.RegisterEventListener(listener)
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 1, 1)
+		injectEvent(&events, gin.Up, 1, gin.DeviceTypeKeyboard, 1, 2)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 0, 3)
+		injectEvent(&events, gin.KeyW, 1, gin.DeviceTypeKeyboard, 1, 4)
+		injectEvent(&events, gin.Up, 1, gin.DeviceTypeKeyboard, 0, 5)
+		input.Think(10, true, events)
+		c.Expect(len(actions), Equals, 3)
+		if len(actions) == 3 {
+			c.Expect(actions[0].Action, Equals, "jump")
+			c.Expect(actions[0].Type, Equals, gin.Press)
+			c.Expect(actions[1].Action, Equals, "forward")
+			c.Expect(actions[1].Type, Equals, gin.Press)
+			c.Expect(actions[2].Action, Equals, "jump")
+			c.Expect(actions[2].Type, Equals, gin.Release)
+		}
+	})
+
+	c.Specify("Bindings survive a round trip through Save and Load.", func() {
+		buf := bytes.NewBuffer(nil)
+		c.Expect(bindings.Save(buf), Equals, nil)
+		loaded := input.MakeBindings()
+		c.Expect(loaded.Load(buf), Equals, nil)
+		c.Expect(loaded.Actions(), ContainsInOrder, []string{"forward", "jump"})
+		c.Expect(loaded.Keys("jump"), ContainsInOrder, []gin.KeyId{space, up})
+		c.Expect(loaded.Keys("forward"), ContainsInOrder, []gin.KeyId{keyw})
+	})
+
+	c.Specify("Loading invalid keys fails and leaves the bindings alone.", func() {
+		err := bindings.Load(bytes.NewBufferString(`{"jump":[{"Device":{"Type":1,"Index":1},"Index":-5}]}`))
+		c.Expect(err == nil, Equals, false)
+		c.Expect(bindings.Keys("jump"), ContainsInOrder, []gin.KeyId{space, up})
+	})
+}
+
+type translator struct {
+	bindings *gin.Bindings
+	actions  *[]gin.ActionEvent
+}
+
+func (t *translator) HandleEventGroup(group gin.EventGroup) {
+	*t.actions = append(*t.actions, t.bindings.Translate(group)...)
+}
+func (t *translator) Think() {}
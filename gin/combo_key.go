@@ -0,0 +1,283 @@
+package gin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MakeChordKey returns a derived key that is down while all of keys are down,
+// regardless of the order in which they were pressed.  Its press amount is 1
+// while it is down.
+func (input *Input) MakeChordKey(keys ...KeyId) Key {
+	return input.makeChordKey(false, keys)
+}
+
+// MakeOrderedChordKey is like MakeChordKey, except that the chord only goes
+// down if its keys were pressed in the order given.  Once down it stays down
+// until any of its keys are released.
+func (input *Input) MakeOrderedChordKey(keys ...KeyId) Key {
+	return input.makeChordKey(true, keys)
+}
+
+func (input *Input) makeChordKey(ordered bool, keys []KeyId) Key {
+	if len(keys) == 0 {
+		panic("Cannot make a chord key out of zero keys.")
+	}
+	ck := &chordKey{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       fmt.Sprintf("Chord(%s)", input.comboName(keys, "+")),
+			aggregator: &standardAggregator{},
+		},
+		input:   input,
+		keys:    keys,
+		ordered: ordered,
+	}
+	input.registerComboKey(ck, keys)
+	return ck
+}
+
+// MakeSequenceKey returns a derived key that is pressed when keys are pressed
+// one after another, in order, with the entire sequence taking no more than
+// window_ms.  Presses of keys that are not in the sequence are ignored.  The
+// sequence key stays down until the last key in the sequence is released.
+func (input *Input) MakeSequenceKey(window_ms int64, keys ...KeyId) Key {
+	if len(keys) == 0 {
+		panic("Cannot make a sequence key out of zero keys.")
+	}
+	sk := &sequenceKey{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       fmt.Sprintf("Sequence(%s)", input.comboName(keys, ", ")),
+			aggregator: &standardAggregator{},
+		},
+		keys:      keys,
+		window_ms: window_ms,
+	}
+	input.registerComboKey(sk, keys)
+	return sk
+}
+
+func (input *Input) comboName(keys []KeyId, sep string) string {
+	var names []string
+	for _, key := range keys {
+		names = append(names, input.GetKey(key).Name())
+	}
+	return strings.Join(names, sep)
+}
+
+// Registers key as depending on each of keys.  Keys that appear more than once
+// are only registered once so that key is only told about each event once.
+func (input *Input) registerComboKey(key Key, keys []KeyId) {
+	registered := make(map[KeyId]bool)
+	for _, dep := range keys {
+		// Makes sure that the key exists so that we panic on invalid keys now
+		// rather than later.
+		input.GetKey(dep)
+		if registered[dep] {
+			continue
+		}
+		registered[dep] = true
+		input.registerDependence(key, dep)
+	}
+	input.key_map[key.Id()] = key
+	input.all_keys = append(input.all_keys, key)
+}
+
+// Returns the event that should be sent when a combo key goes from was_down
+// to is_down, and updates the aggregator to match.
+func (ks *keyState) setComboState(was_down, is_down bool, ms int64) (event Event) {
+	event.Type = NoEvent
+	event.Key = ks
+	amt := 0.0
+	if is_down {
+		amt = 1
+	}
+	switch {
+	case is_down && !was_down:
+		event.Type = Press
+	case !is_down && was_down:
+		event.Type = Release
+	}
+	ks.aggregator.SetPressAmt(amt, ms, event.Type)
+	return
+}
+
+// A chordKey is down while all of its keys are down.
+type chordKey struct {
+	keyState
+	input   *Input
+	keys    []KeyId
+	ordered bool
+
+	// The keys in this chord that are currently down, in the order that they
+	// were pressed.
+	down_order []KeyId
+}
+
+func (ck *chordKey) SetPressAmt(amt float64, ms int64, cause Event) Event {
+	if cause.Key != nil {
+		id := cause.Key.Id()
+		switch cause.Type {
+		case Press:
+			ck.removeDown(id)
+			ck.down_order = append(ck.down_order, id)
+		case Release:
+			ck.removeDown(id)
+		}
+	}
+	all_down := true
+	for _, key := range ck.keys {
+		if !ck.input.GetKey(key).IsDown() {
+			all_down = false
+		}
+	}
+	was_down := ck.IsDown()
+	is_down := all_down && (was_down || !ck.ordered || ck.pressedInOrder())
+	return ck.setComboState(was_down, is_down, ms)
+}
+
+func (ck *chordKey) removeDown(id KeyId) {
+	for i := range ck.down_order {
+		if ck.down_order[i] == id {
+			ck.down_order = append(ck.down_order[:i], ck.down_order[i+1:]...)
+			return
+		}
+	}
+}
+
+// Returns true iff the keys in the chord went down in the order they were
+// specified.
+func (ck *chordKey) pressedInOrder() bool {
+	if len(ck.down_order) != len(ck.keys) {
+		return false
+	}
+	for i := range ck.keys {
+		if ck.down_order[i] != ck.keys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A sequenceKey is pressed when its keys are pressed in order within a window
+// of time.
+type sequenceKey struct {
+	keyState
+	keys      []KeyId
+	window_ms int64
+
+	// Index into keys of the next key we're waiting for, and the time at which
+	// the first key in the sequence was pressed.
+	progress int
+	start_ms int64
+}
+
+func (sk *sequenceKey) SetPressAmt(amt float64, ms int64, cause Event) Event {
+	was_down := sk.IsDown()
+	is_down := was_down
+	if cause.Key != nil {
+		id := cause.Key.Id()
+		switch cause.Type {
+		case Press:
+			if sk.progress > 0 && ms-sk.start_ms > sk.window_ms {
+				sk.progress = 0
+			}
+			if id != sk.keys[sk.progress] {
+				sk.progress = 0
+			}
+			if id == sk.keys[sk.progress] {
+				if sk.progress == 0 {
+					sk.start_ms = ms
+				}
+				sk.progress++
+			}
+			if sk.progress == len(sk.keys) {
+				sk.progress = 0
+				is_down = true
+			}
+		case Release:
+			if id == sk.keys[len(sk.keys)-1] {
+				is_down = false
+			}
+		}
+	}
+	return sk.setComboState(was_down, is_down, ms)
+}
+
+// MakeDoubleClickKey returns a derived key that is pressed when source is
+// pressed twice with no more than interval_ms between the presses.  If source
+// has a Cursor then the cursor also must not have moved more than distance in
+// either direction between the presses.  The double click key is released when
+// source is released.  A third press starts a new double click rather than
+// completing another one.
+func (input *Input) MakeDoubleClickKey(source KeyId, interval_ms int64, distance int) Key {
+	dk := &doubleClickKey{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       fmt.Sprintf("DoubleClick(%s)", input.GetKey(source).Name()),
+			aggregator: &standardAggregator{},
+		},
+		source:      source,
+		interval_ms: interval_ms,
+		distance:    distance,
+	}
+	input.registerComboKey(dk, []KeyId{source})
+	return dk
+}
+
+type doubleClickKey struct {
+	keyState
+	source      KeyId
+	interval_ms int64
+	distance    int
+
+	// Whether or not we've seen the first click, and when and where it happened.
+	clicked  bool
+	click_ms int64
+	click_x  int
+	click_y  int
+}
+
+func (dk *doubleClickKey) SetPressAmt(amt float64, ms int64, cause Event) Event {
+	was_down := dk.IsDown()
+	is_down := was_down
+	if cause.Key != nil && cause.Key.Id() == dk.source {
+		switch cause.Type {
+		case Press:
+			var x, y int
+			if cursor := cause.Key.Cursor(); cursor != nil {
+				x, y = cursor.Point()
+			}
+			if dk.clicked && ms-dk.click_ms <= dk.interval_ms &&
+				abs(x-dk.click_x) <= dk.distance && abs(y-dk.click_y) <= dk.distance {
+				dk.clicked = false
+				is_down = true
+			} else {
+				dk.clicked = true
+				dk.click_ms = ms
+				dk.click_x = x
+				dk.click_y = y
+			}
+		case Release:
+			is_down = false
+		}
+	}
+	return dk.setComboState(was_down, is_down, ms)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,145 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func ChordKeySpec(c gospec.Context) {
+	input := gin.Make()
+	keya := gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	keyb := gin.KeyId{Index: gin.KeyB, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	chord := input.MakeChordKey(keya, keyb)
+	ordered := input.MakeOrderedChordKey(keya, keyb)
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Chords are down only while all of their keys are down.", func() {
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 1)
+		input.Think(2, true, events)
+		events = events[0:0]
+		c.Expect(chord.IsDown(), Equals, false)
+
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 3)
+		input.Think(4, true, events)
+		events = events[0:0]
+		c.Expect(chord.IsDown(), Equals, true)
+		c.Expect(chord.FramePressCount(), Equals, 1)
+		c.Expect(chord.CurPressAmt(), Equals, 1.0)
+
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 0, 5)
+		input.Think(6, true, events)
+		c.Expect(chord.IsDown(), Equals, false)
+		c.Expect(chord.FrameReleaseCount(), Equals, 1)
+	})
+
+	c.Specify("Ordered chords only go down if pressed in order.", func() {
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 1)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 2)
+		input.Think(3, true, events)
+		events = events[0:0]
+		c.Expect(chord.IsDown(), Equals, true)
+		c.Expect(ordered.IsDown(), Equals, false)
+
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 0, 4)
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(6, true, events)
+		c.Expect(ordered.IsDown(), Equals, true)
+		c.Expect(ordered.FramePressCount(), Equals, 1)
+	})
+}
+
+func SequenceKeySpec(c gospec.Context) {
+	input := gin.Make()
+	down := gin.KeyId{Index: gin.Down, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	right := gin.KeyId{Index: gin.Right, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	punch := gin.KeyId{Index: gin.KeyP, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	fireball := input.MakeSequenceKey(100, down, right, punch)
+	events := make([]gin.OsEvent, 0)
+	tap := func(index gin.KeyIndex, t int64) {
+		injectEvent(&events, index, 1, gin.DeviceTypeKeyboard, 1, t)
+		injectEvent(&events, index, 1, gin.DeviceTypeKeyboard, 0, t+1)
+	}
+
+	c.Specify("Sequences are pressed when their keys are pressed in order.", func() {
+		tap(gin.Down, 10)
+		tap(gin.KeyZ, 20)
+		tap(gin.Right, 30)
+		injectEvent(&events, gin.KeyP, 1, gin.DeviceTypeKeyboard, 1, 40)
+		input.Think(50, true, events)
+		events = events[0:0]
+		c.Expect(fireball.FramePressCount(), Equals, 1)
+		c.Expect(fireball.IsDown(), Equals, true)
+
+		injectEvent(&events, gin.KeyP, 1, gin.DeviceTypeKeyboard, 0, 60)
+		input.Think(70, true, events)
+		c.Expect(fireball.FrameReleaseCount(), Equals, 1)
+		c.Expect(fireball.IsDown(), Equals, false)
+	})
+
+	c.Specify("Sequences must be completed within their window.", func() {
+		tap(gin.Down, 10)
+		tap(gin.Right, 60)
+		tap(gin.KeyP, 111)
+		input.Think(200, true, events)
+		c.Expect(fireball.FramePressCount(), Equals, 0)
+	})
+
+	c.Specify("Sequences are interrupted by pressing the wrong key in the sequence.", func() {
+		tap(gin.Down, 10)
+		tap(gin.KeyP, 20)
+		tap(gin.Right, 30)
+		tap(gin.KeyP, 40)
+		input.Think(50, true, events)
+		c.Expect(fireball.FramePressCount(), Equals, 0)
+	})
+
+	c.Specify("A broken sequence can restart on its first key.", func() {
+		tap(gin.Down, 10)
+		tap(gin.Down, 20)
+		tap(gin.Right, 30)
+		tap(gin.KeyP, 40)
+		input.Think(50, true, events)
+		c.Expect(fireball.FramePressCount(), Equals, 1)
+	})
+}
+
+func DoubleClickKeySpec(c gospec.Context) {
+	input := gin.Make()
+	button := gin.KeyId{Index: gin.MouseLButton, Device: gin.DeviceId{Type: gin.DeviceTypeMouse, Index: 1}}
+	double := input.MakeDoubleClickKey(button, 250, 5)
+	events := make([]gin.OsEvent, 0)
+	click := func(t int64) {
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, t)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 0, t+10)
+	}
+
+	c.Specify("Two quick clicks make a double click.", func() {
+		click(100)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, 300)
+		input.Think(310, true, events)
+		events = events[0:0]
+		c.Expect(double.FramePressCount(), Equals, 1)
+		c.Expect(double.IsDown(), Equals, true)
+
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 0, 320)
+		input.Think(330, true, events)
+		c.Expect(double.IsDown(), Equals, false)
+		c.Expect(double.FrameReleaseCount(), Equals, 1)
+	})
+
+	c.Specify("Slow clicks are not double clicks.", func() {
+		click(100)
+		click(400)
+		input.Think(500, true, events)
+		c.Expect(double.FramePressCount(), Equals, 0)
+	})
+
+	c.Specify("Three clicks are only one double click.", func() {
+		click(100)
+		click(200)
+		click(300)
+		input.Think(400, true, events)
+		c.Expect(double.FramePressCount(), Equals, 1)
+	})
+}
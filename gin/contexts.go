@@ -0,0 +1,180 @@
+package gin
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/util/algorithm"
+)
+
+// An InputContext is a set of listeners and bindings that should only receive
+// input while the game is in a particular state, like "gameplay", "menu", or
+// "chat".  Contexts are kept on a stack, and only the context on top of the
+// stack is active, so opening a menu by pushing a "menu" context keeps keys
+// from also moving the player around underneath it.  A context can be made
+// pass-through, in which case the context beneath it is active as well.
+//
+// Listeners registered directly on the Input, rather than on a context, are
+// not part of any context and always receive events.
+type InputContext struct {
+	input        *Input
+	name         string
+	pass_through bool
+
+	// listeners in this context, sorted by priority just like Input.listeners
+	listeners  []Listener
+	priorities map[Listener]int
+}
+
+// Context returns the context named name, creating it if necessary.  This does
+// not push the context, but it allows listeners and bindings to be set up
+// before it is pushed for the first time.
+func (input *Input) Context(name string) *InputContext {
+	if ctx, ok := input.contexts[name]; ok {
+		return ctx
+	}
+	ctx := &InputContext{
+		input:      input,
+		name:       name,
+		priorities: make(map[Listener]int),
+	}
+	input.contexts[name] = ctx
+	input.context_order = append(input.context_order, ctx)
+	return ctx
+}
+
+// PushContext pushes the context named name onto the context stack, creating
+// it if necessary, and returns it.  It is an error to push a context that is
+// already on the stack.
+func (input *Input) PushContext(name string) *InputContext {
+	ctx := input.Context(name)
+	if ctx.onStack() {
+		panic(fmt.Sprintf("Cannot push context '%s', it is already on the stack.", name))
+	}
+	input.context_stack = append(input.context_stack, ctx)
+	return ctx
+}
+
+// PopContext removes the top context from the context stack and returns it.
+// The context keeps its listeners and bindings, so it can be pushed again
+// later.
+func (input *Input) PopContext() *InputContext {
+	if len(input.context_stack) == 0 {
+		panic("Cannot pop a context, the context stack is empty.")
+	}
+	ctx := input.context_stack[len(input.context_stack)-1]
+	input.context_stack = input.context_stack[:len(input.context_stack)-1]
+	return ctx
+}
+
+// CurrentContext returns the context on top of the context stack, or nil if
+// the stack is empty.
+func (input *Input) CurrentContext() *InputContext {
+	if len(input.context_stack) == 0 {
+		return nil
+	}
+	return input.context_stack[len(input.context_stack)-1]
+}
+
+func (ctx *InputContext) Name() string {
+	return ctx.name
+}
+
+// SetPassThrough sets whether or not the context beneath ctx on the context
+// stack remains active while ctx is on top of it.
+func (ctx *InputContext) SetPassThrough(pass_through bool) {
+	ctx.pass_through = pass_through
+}
+
+func (ctx *InputContext) onStack() bool {
+	for _, c := range ctx.input.context_stack {
+		if c == ctx {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive returns true iff ctx is on the context stack and every context
+// above it is pass-through.
+func (ctx *InputContext) IsActive() bool {
+	stack := ctx.input.context_stack
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == ctx {
+			return true
+		}
+		if !stack[i].pass_through {
+			return false
+		}
+	}
+	return false
+}
+
+// RegisterEventListener registers listener with DefaultPriority so that it
+// receives events while ctx is active.
+func (ctx *InputContext) RegisterEventListener(listener Listener) {
+	ctx.RegisterEventListenerWithPriority(listener, DefaultPriority)
+}
+
+// RegisterEventListenerWithPriority is like
+// Input.RegisterEventListenerWithPriority(), but listener only receives events
+// while ctx is active.  Priorities only order listeners within a context, the
+// listeners of a context always come before those of the contexts beneath it.
+func (ctx *InputContext) RegisterEventListenerWithPriority(listener Listener, priority int) {
+	ctx.UnregisterEventListener(listener)
+	insertListener(&ctx.listeners, ctx.priorities, listener, priority)
+}
+
+func (ctx *InputContext) UnregisterEventListener(listener Listener) {
+	ctx.listeners = algorithm.Filter(ctx.listeners, func(l Listener) bool { return l != listener })
+	delete(ctx.priorities, listener)
+}
+
+// MakeBindings returns an empty set of bindings whose actions are only ever
+// down while ctx is active.
+func (ctx *InputContext) MakeBindings() *Bindings {
+	b := ctx.input.MakeBindings()
+	b.context = ctx
+	return b
+}
+
+// Inserts listener into listeners after all listeners with a priority at least
+// as high as priority.
+func insertListener(listeners *[]Listener, priorities map[Listener]int, listener Listener, priority int) {
+	priorities[listener] = priority
+	pos := len(*listeners)
+	for i, l := range *listeners {
+		if priorities[l] < priority {
+			pos = i
+			break
+		}
+	}
+	*listeners = append(*listeners, nil)
+	copy((*listeners)[pos+1:], (*listeners)[pos:])
+	(*listeners)[pos] = listener
+}
+
+// Returns the listeners that should receive events right now, in the order
+// that they should receive them.  This is the listeners of each active context
+// from the top of the stack down, followed by the listeners registered
+// directly on the Input.
+func (input *Input) activeListeners() []Listener {
+	if len(input.context_stack) == 0 {
+		return input.listeners
+	}
+	var listeners []Listener
+	for i := len(input.context_stack) - 1; i >= 0; i-- {
+		listeners = append(listeners, input.context_stack[i].listeners...)
+		if !input.context_stack[i].pass_through {
+			break
+		}
+	}
+	return append(listeners, input.listeners...)
+}
+
+// Returns every listener, including those in contexts that are not active.
+func (input *Input) allListeners() []Listener {
+	var listeners []Listener
+	for _, ctx := range input.context_order {
+		listeners = append(listeners, ctx.listeners...)
+	}
+	return append(listeners, input.listeners...)
+}
@@ -0,0 +1,101 @@
+package gin
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Returns the id that the cursor for key id is stored under, and whether or
+// not id has a cursor at all.  All of the keys on a mouse share one cursor,
+// but each finger on a touch device gets its own.
+func cursorId(id KeyId) (KeyId, bool) {
+	if id.Device.Index == DeviceIndexAny {
+		return id, false
+	}
+	switch id.Device.Type {
+	case DeviceTypeMouse:
+		return KeyId{Device: id.Device}, true
+	case DeviceTypeTouch:
+		return id, isTouchFinger(id.Index)
+	}
+	return id, false
+}
+
+// Returns the cursor for key id, creating it if necessary, or nil if id does
+// not have a cursor.
+func (input *Input) getCursor(id KeyId) *cursor {
+	id, ok := cursorId(id)
+	if !ok {
+		return nil
+	}
+	c, ok := input.cursors[id]
+	if !ok {
+		c = &cursor{
+			name:   fmt.Sprintf("Mouse %d", id.Device.Index),
+			device: id.Device,
+		}
+		if id.Device.Type == DeviceTypeTouch {
+			c.name = fmt.Sprintf("Touch %d Finger %d", id.Device.Index, id.Index-TouchFinger0)
+		}
+		input.cursors[id] = c
+	}
+	return c
+}
+
+// Updates the position of the cursor for the key that generated event, if
+// that key has a cursor.  Mouse axes follow the OS convention that positive y
+// is down, but cursors are in window coordinates where positive y is up.
+func (input *Input) moveCursor(event OsEvent) {
+	c := input.getCursor(event.KeyId)
+	if c == nil {
+		return
+	}
+	switch {
+	case event.Has_cursor:
+		c.X, c.Y = event.Cursor_x, event.Cursor_y
+	case event.KeyId.Index == MouseXAxis:
+		c.X += int(event.Press_amt)
+	case event.KeyId.Index == MouseYAxis:
+		c.Y -= int(event.Press_amt)
+	}
+}
+
+// GetCursor returns the Cursor for the mouse specified by device, or nil if
+// device is not a mouse.  The cursors for the fingers on a touch device can be
+// found through the Cursor() method on their keys.
+func (input *Input) GetCursor(device DeviceId) Cursor {
+	if device.Type != DeviceTypeMouse {
+		return nil
+	}
+	c := input.getCursor(KeyId{Device: device})
+	if c == nil {
+		return nil
+	}
+	return c
+}
+
+// Cursors returns all of the Cursors that have been seen so far, sorted by
+// device.
+func (input *Input) Cursors() []Cursor {
+	var ids keyIdSlice
+	for id := range input.cursors {
+		ids = append(ids, id)
+	}
+	sort.Sort(ids)
+	var cursors []Cursor
+	for _, id := range ids {
+		cursors = append(cursors, input.cursors[id])
+	}
+	return cursors
+}
+
+type keyIdSlice []KeyId
+
+func (k keyIdSlice) Len() int      { return len(k) }
+func (k keyIdSlice) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k keyIdSlice) Less(i, j int) bool {
+	if k[i].Device != k[j].Device {
+		return deviceIdSlice{k[i].Device, k[j].Device}.Less(0, 1)
+	}
+	return k[i].Index < k[j].Index
+}
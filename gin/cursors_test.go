@@ -0,0 +1,85 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func CursorSpec(c gospec.Context) {
+	input := gin.Make()
+	mouse1 := gin.DeviceId{Type: gin.DeviceTypeMouse, Index: 1}
+	mouse2 := gin.DeviceId{Type: gin.DeviceTypeMouse, Index: 2}
+	events := make([]gin.OsEvent, 0)
+	place := func(device gin.DeviceIndex, x, y int, t int64) {
+		injectEvent(&events, gin.MouseLButton, device, gin.DeviceTypeMouse, 1, t)
+		events[len(events)-1].Has_cursor = true
+		events[len(events)-1].Cursor_x = x
+		events[len(events)-1].Cursor_y = y
+	}
+
+	c.Specify("Each mouse has its own cursor.", func() {
+		place(1, 10, 20, 1)
+		place(2, 30, 40, 2)
+		groups := input.Think(3, true, events)
+		x, y := input.GetCursor(mouse1).Point()
+		c.Expect(x, Equals, 10)
+		c.Expect(y, Equals, 20)
+		x, y = input.GetCursor(mouse2).Point()
+		c.Expect(x, Equals, 30)
+		c.Expect(y, Equals, 40)
+		c.Expect(len(input.Cursors()), Equals, 2)
+		c.Expect(input.Cursors()[1].Device(), Equals, mouse2)
+
+		c.Expect(len(groups), Equals, 2)
+		if len(groups) == 2 {
+			c.Expect(groups[0].Cursor.Device(), Equals, mouse1)
+			c.Expect(groups[1].Cursor.Device(), Equals, mouse2)
+		}
+		c.Expect(input.GetKeyFlat(gin.MouseLButton, gin.DeviceTypeMouse, 2).Cursor().Device(), Equals, mouse2)
+	})
+
+	c.Specify("Cursors without positions are moved by the mouse axes.", func() {
+		place(1, 10, 20, 1)
+		injectEvent(&events, gin.MouseXAxis, 1, gin.DeviceTypeMouse, 5, 2)
+		injectEvent(&events, gin.MouseYAxis, 1, gin.DeviceTypeMouse, 3, 3)
+		input.Think(4, true, events)
+		x, y := input.GetCursor(mouse1).Point()
+		c.Expect(x, Equals, 15)
+		c.Expect(y, Equals, 17)
+	})
+
+	c.Specify("Only mice have cursors.", func() {
+		keyboard := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+		c.Expect(input.GetCursor(keyboard) == nil, Equals, true)
+		c.Expect(input.GetKeyFlat(gin.KeyA, gin.DeviceTypeKeyboard, 1).Cursor() == nil, Equals, true)
+	})
+}
+
+func DoubleClickCursorSpec(c gospec.Context) {
+	input := gin.Make()
+	button := gin.KeyId{Index: gin.MouseLButton, Device: gin.DeviceId{Type: gin.DeviceTypeMouse, Index: 1}}
+	double := input.MakeDoubleClickKey(button, 250, 5)
+	events := make([]gin.OsEvent, 0)
+	click := func(x, y int, t int64) {
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, t)
+		events[len(events)-1].Has_cursor = true
+		events[len(events)-1].Cursor_x = x
+		events[len(events)-1].Cursor_y = y
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 0, t+10)
+	}
+
+	c.Specify("Double clicks must be close together.", func() {
+		click(100, 100, 10)
+		click(120, 100, 50)
+		input.Think(100, true, events)
+		c.Expect(double.FramePressCount(), Equals, 0)
+	})
+
+	c.Specify("Double clicks may move a little.", func() {
+		click(100, 100, 10)
+		click(103, 96, 50)
+		input.Think(100, true, events)
+		c.Expect(double.FramePressCount(), Equals, 1)
+	})
+}
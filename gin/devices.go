@@ -0,0 +1,125 @@
+package gin
+
+import (
+	"sort"
+)
+
+// A DeviceEvent is sent to listeners when a device, like a controller, is
+// connected or disconnected.
+type DeviceEvent struct {
+	Device    DeviceId
+	Connected bool
+	Timestamp int64
+}
+
+// Listeners that also implement DeviceListener will be told about devices
+// being connected and disconnected.  Connections are reported before any key
+// events in the same call to Input.Think(), disconnections are reported after
+// all of the keys on the disconnected device have been released.
+type DeviceListener interface {
+	HandleDeviceEvent(DeviceEvent)
+}
+
+// UpdateActiveDevices tells the Input object which devices are currently
+// connected.  Any differences from the previous call are turned into
+// DeviceEvents that are sent out on the next call to Think(), and any keys
+// that are down on a device that was disconnected are released at that time.
+// t should be no earlier than the timestamp of any event that will be passed to
+// the next call to Think().  A nil map indicates that the devices are not known
+// and is ignored.
+func (input *Input) UpdateActiveDevices(t int64, devices map[DeviceType][]DeviceIndex) {
+	if devices == nil {
+		return
+	}
+	current := make(map[DeviceId]bool)
+	for device_type, indexes := range devices {
+		for _, index := range indexes {
+			current[DeviceId{Type: device_type, Index: index}] = true
+		}
+	}
+	var changed []DeviceId
+	for device := range current {
+		if !input.devices[device] {
+			changed = append(changed, device)
+		}
+	}
+	for device := range input.devices {
+		if !current[device] {
+			changed = append(changed, device)
+		}
+	}
+	sort.Sort(deviceIdSlice(changed))
+	for _, device := range changed {
+		input.device_events = append(input.device_events, DeviceEvent{
+			Device:    device,
+			Connected: current[device],
+			Timestamp: t,
+		})
+	}
+	input.devices = current
+}
+
+// ActiveDevices returns all of the devices that were connected as of the last
+// call to UpdateActiveDevices, in the same format that it takes them.
+func (input *Input) ActiveDevices() map[DeviceType][]DeviceIndex {
+	var ids []DeviceId
+	for device := range input.devices {
+		ids = append(ids, device)
+	}
+	sort.Sort(deviceIdSlice(ids))
+	ret := make(map[DeviceType][]DeviceIndex)
+	for _, id := range ids {
+		ret[id.Type] = append(ret[id.Type], id.Index)
+	}
+	return ret
+}
+
+// Returns release events for all natural keys that are down on any device that
+// has been disconnected since the last call to Think().
+func (input *Input) disconnectedDeviceReleases() []OsEvent {
+	var releases []OsEvent
+	for _, device_event := range input.device_events {
+		if device_event.Connected {
+			continue
+		}
+		for _, key := range input.all_keys {
+			if !key.Id().IsNatural() || key.Id().Device != device_event.Device {
+				continue
+			}
+			if key.IsDown() {
+				releases = append(releases, OsEvent{
+					KeyId:     key.Id(),
+					Press_amt: 0,
+					Timestamp: device_event.Timestamp,
+				})
+			}
+		}
+	}
+	return releases
+}
+
+// Sends all pending device events with Connected == connected to any listeners
+// that are DeviceListeners.
+func (input *Input) sendDeviceEvents(connected bool) {
+	for _, device_event := range input.device_events {
+		if device_event.Connected != connected {
+			continue
+		}
+		for _, listener := range input.allListeners() {
+			if dl, ok := listener.(DeviceListener); ok {
+				dl.HandleDeviceEvent(device_event)
+			}
+		}
+	}
+}
+
+type deviceIdSlice []DeviceId
+
+func (d deviceIdSlice) Len() int      { return len(d) }
+func (d deviceIdSlice) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d deviceIdSlice) Less(i, j int) bool {
+	if d[i].Type != d[j].Type {
+		return d[i].Type < d[j].Type
+	}
+	return d[i].Index < d[j].Index
+}
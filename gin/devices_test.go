@@ -0,0 +1,98 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func DevicesSpec(c gospec.Context) {
+	input := gin.Make()
+	var device_events []gin.DeviceEvent
+	var key_events []gin.Event
+	listener := &deviceWatcher{devices: &device_events, keys: &key_events}
+	input.RegisterEventListener(listener)
+	pad1 := gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}
+	pad2 := gin.DeviceId{Type: gin.DeviceTypeController, Index: 2}
+	input.UpdateActiveDevices(1, map[gin.DeviceType][]gin.DeviceIndex{
+		gin.DeviceTypeKeyboard:   []gin.DeviceIndex{1},
+		gin.DeviceTypeController: []gin.DeviceIndex{2, 1},
+	})
+	input.Think(1, true, nil)
+
+	c.Specify("Connected devices are reported to DeviceListeners.", func() {
+		c.Expect(len(device_events), Equals, 3)
+		if len(device_events) == 3 {
+			c.Expect(device_events[0].Device.Type, Equals, gin.DeviceTypeKeyboard)
+			c.Expect(device_events[1].Device, Equals, pad1)
+			c.Expect(device_events[2].Device, Equals, pad2)
+			c.Expect(device_events[2].Connected, Equals, true)
+		}
+		active := input.ActiveDevices()
+		c.Expect(active[gin.DeviceTypeController], ContainsInOrder, []gin.DeviceIndex{1, 2})
+		c.Expect(active[gin.DeviceTypeKeyboard], ContainsInOrder, []gin.DeviceIndex{1})
+	})
+
+	c.Specify("A nil device map is ignored.", func() {
+		device_events = nil
+		input.UpdateActiveDevices(5, nil)
+		input.Think(5, true, nil)
+		c.Expect(len(device_events), Equals, 0)
+		c.Expect(len(input.ActiveDevices()[gin.DeviceTypeController]), Equals, 2)
+	})
+
+	c.Specify("Disconnecting a device releases its keys.", func() {
+		button := input.GetKeyFlat(gin.ControllerButton0+3, gin.DeviceTypeController, 2)
+		other := input.GetKeyFlat(gin.ControllerButton0+3, gin.DeviceTypeController, 1)
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerButton0+3, 2, gin.DeviceTypeController, 1, 5)
+		injectEvent(&events, gin.ControllerButton0+3, 1, gin.DeviceTypeController, 1, 6)
+		input.Think(10, true, events)
+		c.Expect(button.IsDown(), Equals, true)
+		c.Expect(other.IsDown(), Equals, true)
+
+		device_events = nil
+		key_events = nil
+		input.UpdateActiveDevices(20, map[gin.DeviceType][]gin.DeviceIndex{
+			gin.DeviceTypeKeyboard:   []gin.DeviceIndex{1},
+			gin.DeviceTypeController: []gin.DeviceIndex{1},
+		})
+		input.Think(20, true, nil)
+		c.Expect(button.IsDown(), Equals, false)
+		c.Expect(button.FrameReleaseCount(), Equals, 1)
+		c.Expect(other.IsDown(), Equals, true)
+		c.Expect(len(device_events), Equals, 1)
+		if len(device_events) == 1 {
+			c.Expect(device_events[0].Device, Equals, pad2)
+			c.Expect(device_events[0].Connected, Equals, false)
+			c.Expect(device_events[0].Timestamp, Equals, int64(20))
+		}
+
+		// The listener should have seen the release before it heard about the
+		// disconnect.
+		c.Expect(listener.released_before_disconnect, Equals, true)
+		c.Expect(input.ActiveDevices()[gin.DeviceTypeController], ContainsInOrder, []gin.DeviceIndex{1})
+	})
+}
+
+type deviceWatcher struct {
+	devices *[]gin.DeviceEvent
+	keys    *[]gin.Event
+
+	released_before_disconnect bool
+}
+
+func (dw *deviceWatcher) HandleEventGroup(group gin.EventGroup) {
+	*dw.keys = append(*dw.keys, group.Events...)
+}
+func (dw *deviceWatcher) Think() {}
+func (dw *deviceWatcher) HandleDeviceEvent(event gin.DeviceEvent) {
+	if !event.Connected {
+		for _, key_event := range *dw.keys {
+			if key_event.Type == gin.Release && key_event.Key.Id().Device == event.Device {
+				dw.released_before_disconnect = true
+			}
+		}
+	}
+	*dw.devices = append(*dw.devices, event)
+}
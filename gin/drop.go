@@ -0,0 +1,42 @@
+package gin
+
+// A FileDropEvent is sent to listeners when files are dragged from another
+// application and dropped on the window.  Paths are absolute, and X and Y are
+// where they were dropped, in the same window coordinates as cursor
+// positions.
+type FileDropEvent struct {
+	Paths     []string
+	X, Y      int
+	Timestamp int64
+}
+
+// Listeners that also implement FileDropListener will be told about all files
+// that are dropped on the window.  Like window events, these are sent before
+// any key events in the same call to Input.Think(), and they are sent even if
+// the window doesn't have focus, since the application that the files were
+// dragged from usually keeps it.
+type FileDropListener interface {
+	HandleFileDropEvent(FileDropEvent)
+}
+
+// AddFileDropEvents queues up file drops that will be sent to listeners during
+// the next call to Think().  Drops without any paths are ignored.
+func (input *Input) AddFileDropEvents(events []FileDropEvent) {
+	for _, event := range events {
+		if len(event.Paths) > 0 {
+			input.file_drop_events = append(input.file_drop_events, event)
+		}
+	}
+}
+
+// Sends all pending file drops to listeners that are FileDropListeners.
+func (input *Input) sendFileDropEvents() {
+	for _, event := range input.file_drop_events {
+		for _, listener := range input.allListeners() {
+			if fl, ok := listener.(FileDropListener); ok {
+				fl.HandleFileDropEvent(event)
+			}
+		}
+	}
+	input.file_drop_events = input.file_drop_events[0:0]
+}
@@ -0,0 +1,51 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+type dropWatcher struct {
+	drops []gin.FileDropEvent
+}
+
+func (dw *dropWatcher) HandleEventGroup(group gin.EventGroup) {}
+func (dw *dropWatcher) Think()                                {}
+func (dw *dropWatcher) HandleFileDropEvent(event gin.FileDropEvent) {
+	dw.drops = append(dw.drops, event)
+}
+
+func FileDropSpec(c gospec.Context) {
+	input := gin.Make()
+	listener := &dropWatcher{}
+	input.RegisterEventListener(listener)
+
+	c.Specify("Dropped files are sent to FileDropListeners once.", func() {
+		input.AddFileDropEvents([]gin.FileDropEvent{
+			{Paths: []string{"/a.png", "/b.png"}, X: 10, Y: 20, Timestamp: 2},
+		})
+		input.Think(10, true, nil)
+		input.Think(20, true, nil)
+		c.Expect(len(listener.drops), Equals, 1)
+		if len(listener.drops) == 1 {
+			c.Expect(len(listener.drops[0].Paths), Equals, 2)
+			c.Expect(listener.drops[0].Paths[0], Equals, "/a.png")
+			c.Expect(listener.drops[0].Paths[1], Equals, "/b.png")
+			c.Expect(listener.drops[0].X, Equals, 10)
+			c.Expect(listener.drops[0].Y, Equals, 20)
+		}
+	})
+
+	c.Specify("Drops without any paths are ignored.", func() {
+		input.AddFileDropEvents([]gin.FileDropEvent{{X: 10, Y: 20, Timestamp: 2}})
+		input.Think(10, true, nil)
+		c.Expect(len(listener.drops), Equals, 0)
+	})
+
+	c.Specify("Files dropped on an unfocused window are still sent.", func() {
+		input.AddFileDropEvents([]gin.FileDropEvent{{Paths: []string{"/a.png"}, Timestamp: 2}})
+		input.Think(10, false, nil)
+		c.Expect(len(listener.drops), Equals, 1)
+	})
+}
@@ -0,0 +1,99 @@
+package gin
+
+import (
+	"sort"
+)
+
+// A FocusPolicy says what Input.Think() does when the window doesn't have
+// focus.  The OS doesn't send releases for keys that are let go of while
+// another window has focus, so something has to be done about keys that were
+// down when focus was lost or they stay down forever.
+type FocusPolicy int
+
+const (
+	// Every key that is down when the window loses focus is released, and
+	// input from the OS is ignored until focus comes back, so characters
+	// don't keep walking after alt-tab.  Focus lost and regained between two
+	// calls to Think() is caught by the WindowBlur event it causes.  This is
+	// the default.
+	ReleaseOnBlur FocusPolicy = iota
+
+	// Input from the OS is ignored while the window doesn't have focus, but
+	// keys stay the way they were when it lost focus.  Keys let go of in the
+	// meantime stay down until they are pressed and released again.
+	HoldOnBlur
+
+	// Input from the OS is used whether or not the window has focus, for
+	// tools that watch input in the background.  Only useful with an Os that
+	// reports input while unfocused.
+	IgnoreFocus
+)
+
+func (p FocusPolicy) String() string {
+	switch p {
+	case ReleaseOnBlur:
+		return "ReleaseOnBlur"
+	case HoldOnBlur:
+		return "HoldOnBlur"
+	case IgnoreFocus:
+		return "IgnoreFocus"
+	}
+	return "FocusPolicy(?)"
+}
+
+// SetFocusPolicy sets what Think() does when the window doesn't have focus,
+// starting with the next call to Think().
+func (input *Input) SetFocusPolicy(policy FocusPolicy) {
+	input.focus_policy = policy
+}
+
+func (input *Input) FocusPolicy() FocusPolicy {
+	return input.focus_policy
+}
+
+// SetFocusPolicy calls SetFocusPolicy() on the default Input.
+func SetFocusPolicy(policy FocusPolicy) {
+	input_obj.SetFocusPolicy(policy)
+}
+
+// Returns the time of the first WindowBlur event waiting to be sent to
+// listeners, and whether there is one.
+func (input *Input) pendingBlur() (int64, bool) {
+	for _, event := range input.window_events {
+		if event.Type == WindowBlur {
+			return event.Timestamp, true
+		}
+	}
+	return 0, false
+}
+
+// Returns a release at time t for every natural key that is down once the
+// events in os_events from up to t have happened.
+func (input *Input) focusReleases(t int64, os_events []OsEvent) []OsEvent {
+	var ids []KeyId
+	down := make(map[KeyId]bool)
+	for _, key := range input.all_keys {
+		if key.Id().IsNatural() && key.IsDown() {
+			ids = append(ids, key.Id())
+			down[key.Id()] = true
+		}
+	}
+	sorted := append([]OsEvent(nil), os_events...)
+	sort.Stable(osEventsByTime(sorted))
+	for _, event := range sorted {
+		if event.Timestamp > t {
+			break
+		}
+		if _, ok := down[event.KeyId]; !ok {
+			ids = append(ids, event.KeyId)
+		}
+		down[event.KeyId] = event.Press_amt != 0
+	}
+	var releases []OsEvent
+	for _, id := range ids {
+		if down[id] {
+			releases = append(releases, OsEvent{KeyId: id, Press_amt: 0, Timestamp: t})
+		}
+	}
+	return releases
+}
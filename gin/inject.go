@@ -0,0 +1,31 @@
+package gin
+
+// InjectEvent queues up event as if it came from the OS, it is merged with
+// the events passed to the next call to Think() and goes through the same
+// timestamp normalization, see timestamps.go.  event.Timestamp is on the same
+// clock as the horizon passed to Think(), but since callers on other
+// goroutines usually don't know that clock an event with a Timestamp of 0 or
+// less happens at the horizon of the Think() that it is merged into.
+// Injected events are used even if the window doesn't have focus, so tests,
+// network play, and scripted demos keep working in the background.  Unlike
+// the rest of Input this is safe to call from any goroutine.
+func (input *Input) InjectEvent(event OsEvent) {
+	input.injected_mutex.Lock()
+	defer input.injected_mutex.Unlock()
+	input.injected_events = append(input.injected_events, event)
+}
+
+// Removes and returns all of the events queued up by InjectEvent(), with
+// unset timestamps set to t.
+func (input *Input) takeInjectedEvents(t int64) []OsEvent {
+	input.injected_mutex.Lock()
+	events := input.injected_events
+	input.injected_events = nil
+	input.injected_mutex.Unlock()
+	for i := range events {
+		if events[i].Timestamp <= 0 {
+			events[i].Timestamp = t
+		}
+	}
+	return events
+}
@@ -0,0 +1,75 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"sync"
+)
+
+func InjectSpec(c gospec.Context) {
+	input := gin.Make()
+	keya := input.GetKeyFlat(gin.KeyA, gin.DeviceTypeKeyboard, 1)
+	keyb := input.GetKeyFlat(gin.KeyB, gin.DeviceTypeKeyboard, 1)
+	press := func(index gin.KeyIndex, amt float64, timestamp int64) gin.OsEvent {
+		var events []gin.OsEvent
+		injectEvent(&events, index, 1, gin.DeviceTypeKeyboard, amt, timestamp)
+		return events[0]
+	}
+
+	c.Specify("Injected events are merged into the next call to Think().", func() {
+		input.InjectEvent(press('a', 1, 5))
+		var events []gin.OsEvent
+		injectEvent(&events, 'b', 1, gin.DeviceTypeKeyboard, 1, 3)
+		groups := input.Think(10, true, events)
+		c.Expect(keya.FramePressCount(), Equals, 1)
+		c.Expect(keyb.FramePressCount(), Equals, 1)
+		c.Assume(len(groups), Equals, 2)
+		c.Expect(groups[0].Timestamp, Equals, int64(3))
+		c.Expect(groups[1].Timestamp, Equals, int64(5))
+
+		input.Think(20, true, nil)
+		c.Expect(keya.FramePressCount(), Equals, 0)
+		c.Expect(keya.IsDown(), Equals, true)
+	})
+
+	c.Specify("Injected events without a timestamp happen at the horizon.", func() {
+		input.Think(10, true, nil)
+		input.InjectEvent(press('a', 1, 0))
+		groups := input.Think(20, true, nil)
+		c.Assume(len(groups), Equals, 1)
+		c.Expect(groups[0].Timestamp, Equals, int64(20))
+	})
+
+	c.Specify("Injected events are clamped to the previous horizon.", func() {
+		input.Think(10, true, nil)
+		input.InjectEvent(press('a', 1, 5))
+		groups := input.Think(20, true, nil)
+		c.Assume(len(groups), Equals, 1)
+		c.Expect(groups[0].Timestamp, Equals, int64(10))
+	})
+
+	c.Specify("Injected events are used without focus.", func() {
+		input.InjectEvent(press('a', 1, 5))
+		input.Think(10, false, nil)
+		c.Expect(keya.IsDown(), Equals, true)
+	})
+
+	c.Specify("Events can be injected from many goroutines at once.", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					input.InjectEvent(press('a', 1, 5))
+					input.InjectEvent(press('a', 0, 5))
+				}
+			}()
+		}
+		wg.Wait()
+		input.Think(10, true, nil)
+		c.Expect(keya.FramePressCount(), Equals, 100)
+		c.Expect(keya.FrameReleaseCount(), Equals, 100)
+	})
+}
@@ -3,6 +3,7 @@ package gin
 import (
 	"fmt"
 	"github.com/runningwild/glop/util/algorithm"
+	"sync"
 )
 
 var (
@@ -230,6 +231,19 @@ const (
 	ControllerHatSwitchLeft      = 90006
 	ControllerHatSwitchUpLeft    = 90007
 
+	// TouchFinger0 + N is the Nth finger touching a touch device.  A finger's
+	// press amount is how hard it is pressing, or 1 if the device can't tell,
+	// and its Cursor is where it is touching.
+	TouchFinger0 = 95000
+
+	// Gestures recognized on touch devices, see touch.go.
+	TouchTap     = 95100
+	TouchDragX   = 95101
+	TouchDragY   = 95102
+	TouchPinch   = 95103
+	TouchScrollX = 95104
+	TouchScrollY = 95105
+
 	// standard derived keys start here
 	EitherShift = 100000 + iota
 	EitherControl
@@ -239,13 +253,19 @@ const (
 	DeleteOrBackspace
 )
 
+// Every mouse has its own Cursor, so if there are multiple mice connected
+// each one can point at something different.
 type Cursor interface {
 	Name() string
 	Point() (int, int)
+
+	// The device that this cursor belongs to.
+	Device() DeviceId
 }
 
 type cursor struct {
-	name string
+	name   string
+	device DeviceId
 
 	// Window coordinates of the cursor with the origin set as the lower-left
 	// corner of the window.
@@ -258,11 +278,21 @@ func (c *cursor) Name() string {
 func (c *cursor) Point() (int, int) {
 	return c.X, c.Y
 }
+func (c *cursor) Device() DeviceId {
+	return c.device
+}
 
 type OsEvent struct {
 	KeyId     KeyId
 	Press_amt float64
 	Timestamp int64
+
+	// If Has_cursor is true then Cursor_x and Cursor_y are the window
+	// coordinates of the cursor for this event's device at the time of the
+	// event.  Otherwise the cursor, if the device has one, is only moved by
+	// MouseXAxis and MouseYAxis events.
+	Has_cursor         bool
+	Cursor_x, Cursor_y int
 }
 
 // Everything 'global' is put inside a struct so that tests can be run without stepping
@@ -290,9 +320,68 @@ type Input struct {
 	index_to_name map[KeyIndex]string
 
 	// The listeners will receive all events immediately after those events have been used to
-	// update all key states.  The order in which listeners are notified of a particular event
-	// group can change from group to group.
-	listeners []Listener
+	// update all key states.  Listeners are kept sorted by priority, highest first, and are
+	// notified of each event group in that order.
+	listeners  []Listener
+	priorities map[Listener]int
+
+	// set of devices that were active as of the last call to UpdateActiveDevices
+	devices map[DeviceId]bool
+
+	// device events that have not yet been sent to listeners, these are sent out
+	// during the next call to Think()
+	device_events []DeviceEvent
+
+	// text events that have not yet been sent to listeners
+	text_events []TextEvent
+
+	// size of the window as of the last call to WindowResized, and window
+	// events that have not yet been sent to listeners
+	window_dx, window_dy int
+	has_window_dims      bool
+	window_events        []WindowEvent
+
+	// files dropped on the window that have not yet been sent to listeners
+	file_drop_events []FileDropEvent
+
+	// events from InjectEvent() that haven't been merged into a call to Think()
+	// yet, these are the only fields that are touched from other goroutines
+	injected_mutex  sync.Mutex
+	injected_events []OsEvent
+
+	// the horizon passed to the most recent call to Think(), after it was
+	// normalized, see timestamps.go
+	last_horizon int64
+	has_thought  bool
+
+	// calibration for analog keys, set with ConfigureAxis()
+	axis_configs map[KeyId]AxisConfig
+
+	// assists set with ConfigureAssist(), the state of each key that has been
+	// assisted, and keys that were left down when their assists changed
+	assist_states   map[KeyId]*assistState
+	assists         map[KeyId]KeyAssist
+	assist_releases []KeyId
+
+	// cursors for all of the mice and touch device fingers we've seen so far,
+	// see cursorId()
+	cursors map[KeyId]*cursor
+
+	// gesture recognition state for each touch device
+	touches map[DeviceId]*touchState
+
+	// all contexts that have been created, by name and in the order they were
+	// created, and the stack of contexts that decides which are active, see
+	// contexts.go
+	contexts      map[string]*InputContext
+	context_order []*InputContext
+	context_stack []*InputContext
+
+	// what Think() does without focus, see focus.go
+	focus_policy FocusPolicy
+
+	// how long os events waited to be sent to listeners, see latency.go
+	latency latencyStats
 }
 
 // The standard input object
@@ -319,6 +408,11 @@ func Make() *Input {
 	input.index_to_name = make(map[KeyIndex]string)
 	input.index_to_family_deps = make(map[KeyIndex][]derivedKeyFamily)
 	input.index_to_family = make(map[KeyIndex]derivedKeyFamily)
+	input.devices = make(map[DeviceId]bool)
+	input.cursors = make(map[KeyId]*cursor)
+	input.priorities = make(map[Listener]int)
+	input.touches = make(map[DeviceId]*touchState)
+	input.contexts = make(map[string]*InputContext)
 
 	input.registerKeyIndex(AnyKey, aggregatorTypeStandard, "AnyKey")
 	for c := 'a'; c <= 'z'; c++ {
@@ -389,7 +483,10 @@ func Make() *Input {
 
 	input.registerKeyIndex(MouseXAxis, aggregatorTypeAxis, "X Axis")
 	input.registerKeyIndex(MouseYAxis, aggregatorTypeAxis, "Y Axis")
+	// The mouse wheels report how far they were scrolled in each event, positive
+	// values are up and to the right.
 	input.registerKeyIndex(MouseWheelVertical, aggregatorTypeWheel, "MouseWheel")
+	input.registerKeyIndex(MouseWheelHorizontal, aggregatorTypeWheel, "MouseWheelHorizontal")
 	input.registerKeyIndex(MouseLButton, aggregatorTypeStandard, "MouseLButton")
 	input.registerKeyIndex(MouseRButton, aggregatorTypeStandard, "MouseRButton")
 	input.registerKeyIndex(MouseMButton, aggregatorTypeStandard, "MouseMButton")
@@ -412,6 +509,16 @@ func Make() *Input {
 	input.registerKeyIndex(ControllerHatSwitchLeft, aggregatorTypeStandard, "HatSwitchLeft")
 	input.registerKeyIndex(ControllerHatSwitchUpLeft, aggregatorTypeStandard, "HatSwitchUpLeft")
 
+	for i := 0; i < maxTouchFingers; i++ {
+		input.registerKeyIndex(TouchFinger0+KeyIndex(i), aggregatorTypeStandard, fmt.Sprintf("Finger%d", i))
+	}
+	input.registerKeyIndex(TouchTap, aggregatorTypeWheel, "Tap")
+	input.registerKeyIndex(TouchDragX, aggregatorTypeAxis, "DragX")
+	input.registerKeyIndex(TouchDragY, aggregatorTypeAxis, "DragY")
+	input.registerKeyIndex(TouchPinch, aggregatorTypeAxis, "Pinch")
+	input.registerKeyIndex(TouchScrollX, aggregatorTypeWheel, "ScrollX")
+	input.registerKeyIndex(TouchScrollY, aggregatorTypeWheel, "ScrollY")
+
 	input.bindDerivedKeyFamilyWithIndex(
 		"EitherShift",
 		EitherShift,
@@ -490,7 +597,6 @@ func (event EventType) String() string {
 		return "adjust"
 	}
 	panic(fmt.Sprintf("%d is not a valid EventType", event))
-	return ""
 }
 
 // TODO: Consider making a Timestamp type (int64)
@@ -513,6 +619,10 @@ func (e Event) String() string {
 type EventGroup struct {
 	Events    []Event
 	Timestamp int64
+
+	// The cursor of the device that created these events, or nil if that device
+	// doesn't have a cursor.
+	Cursor Cursor
 }
 
 // Returns a bool indicating whether an event corresponding to the given KeyId is present
@@ -531,7 +641,7 @@ func (input *Input) registerKeyIndex(index KeyIndex, agg_type aggregatorType, na
 		panic(fmt.Sprintf("Cannot register a key with index %d, indexes must be greater than 0.", index))
 	}
 	if prev, ok := input.index_to_agg_type[index]; ok {
-		panic(fmt.Sprintf("Cannot register key index %d, it has already been registered with the name %s and aggregator %v.", index, prev, agg_type))
+		panic(fmt.Sprintf("Cannot register key index %d, it has already been registered with the name %s and aggregator %v.", index, input.index_to_name[index], prev))
 	}
 	input.index_to_agg_type[index] = agg_type
 	input.index_to_name[index] = name
@@ -601,6 +711,7 @@ func (input *Input) GetKey(id KeyId) Key {
 				id:         id,
 				name:       input.index_to_name[id.Index],
 				aggregator: agg,
+				cursor:     input.getCursor(id),
 			}
 			key = input.key_map[id]
 			input.all_keys = append(input.all_keys, key)
@@ -617,6 +728,29 @@ func (input *Input) GetKeyByName(name string) Key {
 	return nil
 }
 
+// WasPressed returns true iff the key specified by id was pressed during the
+// most recent call to Think().  This, along with WasReleased() and
+// PressCount(), is convenient for code that would rather poll for input each
+// frame than be an EventListener.  Keys with wildcards in their ids are derived
+// keys that are created the first time they are asked for, so they will not
+// have seen any events from before then.
+func (input *Input) WasPressed(id KeyId) bool {
+	return input.PressCount(id) > 0
+}
+
+// WasReleased returns true iff the key specified by id was released during
+// the most recent call to Think().
+func (input *Input) WasReleased(id KeyId) bool {
+	return input.GetKey(id).FrameReleaseCount() > 0
+}
+
+// PressCount returns the number of times that the key specified by id was
+// pressed during the most recent call to Think().  This can be more than one
+// if the key was pressed and released multiple times within a single frame.
+func (input *Input) PressCount(id KeyId) int {
+	return input.GetKey(id).FramePressCount()
+}
+
 func (input *Input) informDeps(event Event, group *EventGroup) {
 	id := event.Key.Id()
 	any_device := id.Device
@@ -671,70 +805,135 @@ type EventDispatcher interface {
 	UnregisterEventListener(Listener)
 }
 
+// Listeners that also implement EventConsumer are sent event groups through
+// ConsumeEventGroup() instead of HandleEventGroup().  If ConsumeEventGroup()
+// returns true the group has been consumed and listeners with a lower priority
+// will not be sent it.  Consuming a group does not affect key state, and the
+// group is still returned from Think().
+type EventConsumer interface {
+	ConsumeEventGroup(EventGroup) bool
+}
+
+// Listeners registered with RegisterEventListener() have DefaultPriority.
+const DefaultPriority = 0
+
 // TODO: These two functions should be synchronized
 func (input *Input) RegisterEventListener(listener Listener) {
-	input.listeners = append(input.listeners, listener)
+	input.RegisterEventListenerWithPriority(listener, DefaultPriority)
+}
+
+// RegisterEventListenerWithPriority registers listener so that it is sent
+// events before all listeners with a lower priority and after all listeners
+// with a higher priority.  Listeners with the same priority are sent events in
+// the order that they were registered.
+func (input *Input) RegisterEventListenerWithPriority(listener Listener, priority int) {
+	input.UnregisterEventListener(listener)
+	insertListener(&input.listeners, input.priorities, listener, priority)
 }
 
 func (input *Input) UnregisterEventListener(listener Listener) {
-	algorithm.Choose(&input.listeners, func(l Listener) bool { return l != listener })
+	input.listeners = algorithm.Filter(input.listeners, func(l Listener) bool { return l != listener })
+	delete(input.priorities, listener)
+}
+
+// Sends group to each active listener in order until one of them consumes it.
+func (input *Input) sendEventGroup(group EventGroup) {
+	for _, listener := range input.activeListeners() {
+		if consumer, ok := listener.(EventConsumer); ok {
+			if consumer.ConsumeEventGroup(group) {
+				return
+			}
+		} else {
+			listener.HandleEventGroup(group)
+		}
+	}
 }
 
 func (input *Input) Think(t int64, has_focus bool, os_events []OsEvent) []EventGroup {
-	// If we have lost focus, clear all key state.
+	t = input.normalizeHorizon(t)
+	injected := input.takeInjectedEvents(t)
+	if input.focus_policy == IgnoreFocus {
+		has_focus = true
+	}
+	if has_focus {
+		input.recordLatency(t, os_events)
+	}
+
+	// If we have lost focus, ignore the OS and, depending on the focus
+	// policy, clear all key state.
 	if !has_focus {
-		// clearAllKeyState()
 		os_events = nil
-		for _, key := range input.all_keys {
-			if !key.Id().IsNatural() {
-				continue
-			}
-			if key.IsDown() {
-				os_events = append(os_events, OsEvent{
-					KeyId:     key.Id(),
-					Press_amt: 0,
-					Timestamp: t,
-				})
-			}
+		input.text_events = input.text_events[0:0]
+		if input.focus_policy == ReleaseOnBlur {
+			input.touches = make(map[DeviceId]*touchState)
+			input.resetAssists()
+			os_events = input.focusReleases(t, nil)
 		}
+	} else if blur, ok := input.pendingBlur(); ok && input.focus_policy == ReleaseOnBlur {
+		// Focus was lost and regained since the last call to Think(), any
+		// keys that were let go of in the meantime were never released.
+		blur = input.clampTimestamp(t, blur)
+		os_events = append(append([]OsEvent(nil), os_events...), input.focusReleases(blur, os_events)...)
+	}
+	if len(injected) > 0 {
+		os_events = append(append([]OsEvent(nil), os_events...), injected...)
+	}
+	// Release any keys that are still held down on devices that have been
+	// disconnected.  If we don't have focus this has already been done above.
+	// Axis configs must be applied first since they can change which key an
+	// event is for, and these releases are already for the right keys.
+	if has_focus {
+		os_events = input.applyAxisConfigs(os_events)
+		os_events = input.applyAssists(t, os_events)
+		os_events = append(os_events, input.disconnectedDeviceReleases()...)
 	}
+	os_events = input.normalizeTimestamps(t, os_events)
+	input.sendDeviceEvents(true)
+	input.sendWindowEvents()
+	input.sendFileDropEvents()
+
 	// Generate all key events here.  Derived keys are handled through pressKey and all
 	// events are aggregated into one array.  Events in this array will necessarily be in
 	// sorted order.
 	var groups []EventGroup
 	for _, os_event := range os_events {
+		input.sendTextEvents(os_event.Timestamp)
+		key := input.GetKey(os_event.KeyId)
+		input.moveCursor(os_event)
 		group := EventGroup{
 			Timestamp: os_event.Timestamp,
+			Cursor:    key.Cursor(),
 		}
 		input.pressKey(
-			input.GetKey(os_event.KeyId),
+			key,
 			os_event.Press_amt,
 			Event{},
 			&group)
+		input.recognizeGestures(os_event, &group)
 		if len(group.Events) > 0 {
 			groups = append(groups, group)
-			for _, listener := range input.listeners {
-				listener.HandleEventGroup(group)
-			}
+			input.sendEventGroup(group)
 		}
 	}
+	input.sendTextEvents(t + 1)
 
 	for _, key := range input.all_keys {
 		gen, amt := key.Think(t)
 		if !gen {
 			continue
 		}
-		group := EventGroup{Timestamp: t}
+		group := EventGroup{Timestamp: t, Cursor: key.Cursor()}
 		input.pressKey(key, amt, Event{}, &group)
 		if len(group.Events) > 0 {
 			groups = append(groups, group)
-			for _, listener := range input.listeners {
-				listener.HandleEventGroup(group)
-			}
+			input.sendEventGroup(group)
 		}
 	}
 
-	for _, listener := range input.listeners {
+	input.sendDeviceEvents(false)
+	input.device_events = input.device_events[0:0]
+
+	for _, listener := range input.allListeners() {
 		listener.Think()
 	}
 	return groups
@@ -1,7 +1,6 @@
 package gin_test
 
 import (
-	"fmt"
 	"github.com/orfjackal/gospec/src/gospec"
 	. "github.com/orfjackal/gospec/src/gospec"
 	"github.com/runningwild/glop/gin"
@@ -824,6 +823,44 @@ func AxisSpec(c gospec.Context) {
 	})
 }
 
+func WheelSpec(c gospec.Context) {
+	input := gin.Make()
+	wheel := input.GetKeyFlat(gin.MouseWheelVertical, gin.DeviceTypeMouse, 1)
+	hwheel := input.GetKeyFlat(gin.MouseWheelHorizontal, gin.DeviceTypeMouse, 1)
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Wheels sum the distance scrolled during a frame.", func() {
+		injectEvent(&events, gin.MouseWheelVertical, 1, gin.DeviceTypeMouse, 1, 5)
+		injectEvent(&events, gin.MouseWheelVertical, 1, gin.DeviceTypeMouse, 1, 6)
+		injectEvent(&events, gin.MouseWheelVertical, 1, gin.DeviceTypeMouse, 2, 7)
+		injectEvent(&events, gin.MouseWheelHorizontal, 1, gin.DeviceTypeMouse, -1, 8)
+		input.Think(10, true, events)
+		c.Expect(wheel.FramePressSum(), Equals, 4.0)
+		c.Expect(wheel.FramePressAmt(), Equals, 2.0)
+		c.Expect(wheel.FramePressCount(), Equals, 1)
+		c.Expect(hwheel.FramePressSum(), Equals, -1.0)
+		c.Expect(hwheel.FramePressCount(), Equals, 1)
+	})
+
+	c.Specify("Wheels send an event for every scroll and are released after each frame.", func() {
+		var types []gin.EventType
+		injectEvent(&events, gin.MouseWheelVertical, 1, gin.DeviceTypeMouse, 1, 5)
+		injectEvent(&events, gin.MouseWheelVertical, 1, gin.DeviceTypeMouse, 1, 6)
+		for _, group := range input.Think(10, true, events) {
+			if found, event := group.FindEvent(wheel.Id()); found {
+				types = append(types, event.Type)
+			}
+		}
+		c.Expect(types, ContainsInOrder, []gin.EventType{gin.Press, gin.Adjust, gin.Release})
+		c.Expect(wheel.IsDown(), Equals, false)
+
+		events = events[0:0]
+		input.Think(20, true, events)
+		c.Expect(wheel.FramePressSum(), Equals, 0.0)
+		c.Expect(wheel.IsDown(), Equals, false)
+	})
+}
+
 type listener struct {
 	input   *gin.Input
 	key_id  gin.KeyId
@@ -949,4 +986,277 @@ func FocusSpec(c gospec.Context) {
 		c.Expect(keyb.FramePressCount(), Equals, 0)
 		c.Expect(keyb.FrameReleaseCount(), Equals, 1)
 	})
+
+	c.Specify("Losing and regaining focus between frames releases pressed keys.", func() {
+		injectEvent(&events, 'a', 1, gin.DeviceTypeKeyboard, 1, 1)
+		input.Think(10, true, events)
+		events = events[0:0]
+		injectEvent(&events, 'b', 1, gin.DeviceTypeKeyboard, 1, 12)
+		injectEvent(&events, 'b', 1, gin.DeviceTypeKeyboard, 0, 13)
+		injectEvent(&events, 'b', 1, gin.DeviceTypeKeyboard, 1, 14)
+		input.AddWindowEvents([]gin.WindowEvent{
+			{Type: gin.WindowBlur, Timestamp: 15},
+			{Type: gin.WindowFocus, Timestamp: 16},
+		})
+		input.Think(20, true, events)
+		c.Expect(keya.IsDown(), Equals, false)
+		c.Expect(keya.FrameReleaseCount(), Equals, 1)
+		c.Expect(keyb.IsDown(), Equals, false)
+		c.Expect(keyb.FramePressCount(), Equals, 2)
+		c.Expect(keyb.FrameReleaseCount(), Equals, 2)
+	})
+
+	c.Specify("HoldOnBlur leaves keys down without focus.", func() {
+		input.SetFocusPolicy(gin.HoldOnBlur)
+		injectEvent(&events, 'a', 1, gin.DeviceTypeKeyboard, 1, 1)
+		input.Think(10, true, events)
+		events = events[0:0]
+		injectEvent(&events, 'b', 1, gin.DeviceTypeKeyboard, 1, 12)
+		input.AddWindowEvents([]gin.WindowEvent{{Type: gin.WindowBlur, Timestamp: 11}})
+		input.Think(20, false, events)
+		c.Expect(keya.IsDown(), Equals, true)
+		c.Expect(keya.FrameReleaseCount(), Equals, 0)
+		c.Expect(keyb.IsDown(), Equals, false)
+	})
+
+	c.Specify("IgnoreFocus uses input without focus.", func() {
+		input.SetFocusPolicy(gin.IgnoreFocus)
+		c.Expect(input.FocusPolicy(), Equals, gin.IgnoreFocus)
+		injectEvent(&events, 'a', 1, gin.DeviceTypeKeyboard, 1, 1)
+		input.Think(10, false, events)
+		c.Expect(keya.IsDown(), Equals, true)
+		c.Expect(keya.FramePressCount(), Equals, 1)
+	})
+}
+
+func PollSpec(c gospec.Context) {
+	input := gin.Make()
+	keya := gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	anya := gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: gin.DeviceIndexAny}}
+	input.GetKey(anya)
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Presses and releases can be polled for after Think.", func() {
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 1)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 2)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 3)
+		input.Think(10, true, events)
+		events = events[0:0]
+		c.Expect(input.WasPressed(keya), Equals, true)
+		c.Expect(input.WasReleased(keya), Equals, true)
+		c.Expect(input.PressCount(keya), Equals, 2)
+		c.Expect(input.WasPressed(anya), Equals, true)
+
+		input.Think(20, true, events)
+		c.Expect(input.WasPressed(keya), Equals, false)
+		c.Expect(input.WasReleased(keya), Equals, false)
+		c.Expect(input.PressCount(keya), Equals, 0)
+	})
+}
+
+func AxisConfigSpec(c gospec.Context) {
+	input := gin.Make()
+	pos := input.GetKeyFlat(gin.ControllerAxis0Positive, gin.DeviceTypeController, 1)
+	neg := input.GetKeyFlat(gin.ControllerAxis0Negative, gin.DeviceTypeController, 1)
+	mouse_x := input.GetKeyFlat(gin.MouseXAxis, gin.DeviceTypeMouse, 1)
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Dead zones zero out small values and stretch the rest.", func() {
+		input.ConfigureAxis(gin.KeyId{Index: gin.ControllerAxis0Negative, Device: gin.DeviceId{Type: gin.DeviceTypeController, Index: gin.DeviceIndexAny}}, gin.AxisConfig{DeadZone: 0.2})
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.1, 1)
+		input.Think(2, true, events)
+		events = events[0:0]
+		c.Expect(pos.IsDown(), Equals, false)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.6, 3)
+		input.Think(4, true, events)
+		c.Expect(pos.CurPressAmt(), IsWithin(1e-9), 0.5)
+	})
+
+	c.Specify("Curves are applied after the dead zone.", func() {
+		input.ConfigureAxis(pos.Id(), gin.AxisConfig{DeadZone: 0.5, Curve: 2})
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 1, 1)
+		input.Think(2, true, events)
+		events = events[0:0]
+		c.Expect(pos.CurPressAmt(), IsWithin(1e-9), 1.0)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.75, 3)
+		input.Think(4, true, events)
+		c.Expect(pos.CurPressAmt(), IsWithin(1e-9), 0.25)
+	})
+
+	c.Specify("Inverted controller axes swap their halves.", func() {
+		input.ConfigureAxis(pos.Id(), gin.AxisConfig{Invert: true})
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.5, 1)
+		input.Think(2, true, events)
+		c.Expect(pos.IsDown(), Equals, false)
+		c.Expect(neg.CurPressAmt(), Equals, 0.5)
+	})
+
+	c.Specify("Inverted mouse axes are negated.", func() {
+		input.ConfigureAxis(mouse_x.Id(), gin.AxisConfig{Invert: true, DeadZone: 1})
+		injectEvent(&events, gin.MouseXAxis, 1, gin.DeviceTypeMouse, 1, 1)
+		injectEvent(&events, gin.MouseXAxis, 1, gin.DeviceTypeMouse, 5, 2)
+		input.Think(3, true, events)
+		c.Expect(mouse_x.FramePressSum(), Equals, -5.0)
+	})
+
+	c.Specify("Only analog keys can be configured.", func() {
+		panicked := false
+		func() {
+			defer func() {
+				panicked = recover() != nil
+			}()
+			input.ConfigureAxis(gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}, gin.AxisConfig{})
+		}()
+		c.Expect(panicked, Equals, true)
+	})
+}
+
+func TimestampSpec(c gospec.Context) {
+	input := gin.Make()
+	var groups []gin.EventGroup
+	input.RegisterEventListener(&groupRecorder{groups: &groups})
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Events are sent in sorted order.", func() {
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 8)
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 3)
+		injectEvent(&events, gin.KeyC, 1, gin.DeviceTypeKeyboard, 1, 3)
+		input.Think(10, true, events)
+		c.Expect(len(groups), Equals, 3)
+		if len(groups) == 3 {
+			c.Expect(groups[0].Events[0].Key.Id().Index, Equals, gin.KeyIndex(gin.KeyB))
+			c.Expect(groups[1].Events[0].Key.Id().Index, Equals, gin.KeyIndex(gin.KeyC))
+			c.Expect(groups[2].Events[0].Key.Id().Index, Equals, gin.KeyIndex(gin.KeyA))
+		}
+	})
+
+	c.Specify("Timestamps never go back past the previous horizon.", func() {
+		input.Think(10, true, nil)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 4)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 25)
+		input.Think(20, true, events)
+		c.Expect(len(groups), Equals, 2)
+		if len(groups) == 2 {
+			c.Expect(groups[0].Timestamp, Equals, int64(10))
+			c.Expect(groups[1].Timestamp, Equals, int64(20))
+		}
+
+		events = events[0:0]
+		groups = nil
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 12)
+		input.Think(15, true, events)
+		c.Expect(len(groups), Equals, 1)
+		if len(groups) == 1 {
+			c.Expect(groups[0].Timestamp, Equals, int64(20))
+		}
+	})
+}
+
+type groupRecorder struct {
+	groups *[]gin.EventGroup
+}
+
+func (gr *groupRecorder) HandleEventGroup(group gin.EventGroup) {
+	*gr.groups = append(*gr.groups, group)
+}
+func (gr *groupRecorder) Think() {}
+
+func ListenerPrioritySpec(c gospec.Context) {
+	input := gin.Make()
+	var order []string
+	low := &priorityListener{name: "low", order: &order}
+	mid := &priorityListener{name: "mid", order: &order}
+	high := &priorityListener{name: "high", order: &order}
+	input.RegisterEventListenerWithPriority(low, -1)
+	input.RegisterEventListener(mid)
+	input.RegisterEventListenerWithPriority(high, 10)
+	events := make([]gin.OsEvent, 0)
+	injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 1)
+
+	c.Specify("Listeners are sent events in order of priority.", func() {
+		input.Think(2, true, events)
+		c.Expect(order, ContainsInOrder, []string{"high", "mid", "low"})
+	})
+
+	c.Specify("Consumed event groups aren't sent to lower priority listeners.", func() {
+		high.consume = true
+		groups := input.Think(2, true, events)
+		c.Expect(order, ContainsInOrder, []string{"high"})
+		c.Expect(len(groups), Equals, 1)
+		c.Expect(input.GetKeyFlat(gin.KeyA, gin.DeviceTypeKeyboard, 1).IsDown(), Equals, true)
+	})
+
+	c.Specify("Unregistered listeners aren't sent events.", func() {
+		input.UnregisterEventListener(mid)
+		input.Think(2, true, events)
+		c.Expect(order, ContainsInOrder, []string{"high", "low"})
+	})
+}
+
+type priorityListener struct {
+	name    string
+	order   *[]string
+	consume bool
+}
+
+func (pl *priorityListener) HandleEventGroup(group gin.EventGroup) {}
+func (pl *priorityListener) Think()                                {}
+func (pl *priorityListener) ConsumeEventGroup(group gin.EventGroup) bool {
+	*pl.order = append(*pl.order, pl.name)
+	return pl.consume
+}
+
+func ContextSpec(c gospec.Context) {
+	input := gin.Make()
+	var order []string
+	global := &priorityListener{name: "global", order: &order}
+	game := &priorityListener{name: "game", order: &order}
+	menu := &priorityListener{name: "menu", order: &order}
+	input.RegisterEventListener(global)
+	input.PushContext("gameplay").RegisterEventListener(game)
+	input.Context("menu").RegisterEventListener(menu)
+	keya := gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	game_bindings := input.Context("gameplay").MakeBindings()
+	game_bindings.Bind("jump", keya)
+	events := make([]gin.OsEvent, 0)
+	injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 1)
+
+	c.Specify("Only the top context receives events.", func() {
+		input.Think(2, true, events)
+		c.Expect(order, ContainsInOrder, []string{"game", "global"})
+		c.Expect(game_bindings.IsDown("jump"), Equals, true)
+
+		order = nil
+		input.PushContext("menu")
+		c.Expect(input.CurrentContext().Name(), Equals, "menu")
+		c.Expect(input.Context("gameplay").IsActive(), Equals, false)
+		c.Expect(game_bindings.IsDown("jump"), Equals, false)
+		input.Think(3, true, []gin.OsEvent{events[0]})
+		c.Expect(order, ContainsInOrder, []string{"menu", "global"})
+	})
+
+	c.Specify("Pass-through contexts let events through to the context beneath them.", func() {
+		input.PushContext("menu").SetPassThrough(true)
+		input.Think(2, true, events)
+		c.Expect(order, ContainsInOrder, []string{"menu", "game", "global"})
+		c.Expect(game_bindings.IsDown("jump"), Equals, true)
+	})
+
+	c.Specify("Popped contexts stop receiving events.", func() {
+		input.PushContext("menu")
+		c.Expect(input.PopContext().Name(), Equals, "menu")
+		c.Expect(input.PopContext().Name(), Equals, "gameplay")
+		c.Expect(input.CurrentContext() == nil, Equals, true)
+		input.Think(2, true, events)
+		c.Expect(order, ContainsInOrder, []string{"global"})
+	})
+
+	c.Specify("Contexts can't be on the stack twice.", func() {
+		panicked := false
+		func() {
+			defer func() { panicked = recover() != nil }()
+			input.PushContext("gameplay")
+		}()
+		c.Expect(panicked, Equals, true)
+	})
 }
@@ -176,13 +176,18 @@ func (aa *axisAggregator) Think(ms int64) (bool, float64) {
 	return false, 0
 }
 
-// A wheelAggregator is just like a standardAggregator except for two things:
+// A wheelAggregator is used for mouse wheels, which only report how far they
+// were scrolled.  It differs from the standardAggregator in the following ways:
 // - It sends Adjust events for *all* non-zero press amounts
-// - If a frame goes by without it receiving any input it creates a Release
-// event
+// - Its sum is the sum of all press amounts specified by SetPressAmt(), so
+// FramePressSum() is how far the wheel was scrolled during the last frame
+// - It creates a Release event at the end of any frame in which it was down
 type wheelAggregator struct {
-	standardAggregator
-	event_received bool
+	baseAggregator
+}
+
+func (wa *wheelAggregator) IsDown() bool {
+	return wa.this.press_amt != 0
 }
 
 func (wa *wheelAggregator) SendAllNonZero() bool {
@@ -190,21 +195,19 @@ func (wa *wheelAggregator) SendAllNonZero() bool {
 }
 
 func (wa *wheelAggregator) SetPressAmt(amt float64, ms int64, event_type EventType) {
-	wa.event_received = wa.last_press < wa.last_think
-	wa.standardAggregator.SetPressAmt(amt, ms, event_type)
+	wa.this.press_sum += amt
+	wa.this.press_amt = amt
+	wa.handleEventType(event_type)
 }
 
 func (wa *wheelAggregator) Think(ms int64) (bool, float64) {
-	if b, _ := wa.standardAggregator.Think(ms); b {
-		panic("standardAggregator should not generate an event on Think()")
+	wa.prev = wa.this
+	wa.prev.press_avg = wa.prev.press_sum
+	wa.this = keyStats{
+		press_amt: wa.prev.press_amt,
 	}
-	if wa.CurPressAmt() != 0 {
-		if wa.event_received {
-			wa.event_received = false
-			return true, wa.CurPressAmt()
-		} else {
-			return true, 0
-		}
+	if wa.this.press_amt != 0 {
+		return true, 0
 	}
 	return false, 0
 }
@@ -256,6 +259,7 @@ const (
 	DeviceTypeMouse
 	DeviceTypeController
 	DeviceTypeDerived
+	DeviceTypeTouch
 	DeviceTypeMax
 )
 
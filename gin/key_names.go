@@ -0,0 +1,124 @@
+package gin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var device_type_names = map[DeviceType]string{
+	DeviceTypeAny:        "Any",
+	DeviceTypeKeyboard:   "Keyboard",
+	DeviceTypeMouse:      "Mouse",
+	DeviceTypeController: "Controller",
+	DeviceTypeDerived:    "Derived",
+	DeviceTypeTouch:      "Touch",
+}
+
+// KeyName returns a human readable name for the key specified by id, suitable
+// for showing in a keybinding menu or storing in a config file.  The name has
+// the form "<device type> <device index>:<key name>", for example
+// "Keyboard 1:Key A" or "Controller 2:Button 3".  The device index is left out
+// if it is DeviceIndexAny, so "Mouse:MouseLButton" is the left button on any
+// mouse.  Indices that were never given a name are written as "#<index>".
+// ParseKeyName() converts a name back into a KeyId.
+func (input *Input) KeyName(id KeyId) string {
+	device, ok := device_type_names[id.Device.Type]
+	if !ok {
+		device = fmt.Sprintf("#%d", id.Device.Type)
+	}
+	if id.Device.Index != DeviceIndexAny {
+		device = fmt.Sprintf("%s %d", device, id.Device.Index)
+	}
+	return device + ":" + input.keyIndexName(id)
+}
+
+func (input *Input) keyIndexName(id KeyId) string {
+	if name, ok := input.index_to_name[id.Index]; ok {
+		return name
+	}
+	if family, ok := input.index_to_family[id.Index]; ok {
+		return family.name
+	}
+	if key, ok := input.key_map[id]; ok && key.Name() != "" {
+		return key.Name()
+	}
+	return fmt.Sprintf("#%d", id.Index)
+}
+
+// ParseKeyName returns the KeyId of the key named by name, which should have
+// been returned by KeyName().  An error is returned if name is malformed or
+// does not specify a valid key.
+func (input *Input) ParseKeyName(name string) (KeyId, error) {
+	var id KeyId
+	parts := strings.SplitN(name, ":", 2)
+	if len(parts) != 2 {
+		return id, fmt.Errorf("Key name '%s' should have the form '<device>:<key>'.", name)
+	}
+	device := strings.Fields(parts[0])
+	if len(device) == 0 || len(device) > 2 {
+		return id, fmt.Errorf("Key name '%s' has an invalid device, '%s'.", name, parts[0])
+	}
+	found := false
+	for device_type, device_name := range device_type_names {
+		if device_name == device[0] {
+			id.Device.Type = device_type
+			found = true
+		}
+	}
+	if !found {
+		return id, fmt.Errorf("Key name '%s' has an unknown device type, '%s'.", name, device[0])
+	}
+	id.Device.Index = DeviceIndexAny
+	if len(device) == 2 {
+		index, err := strconv.Atoi(device[1])
+		if err != nil || index < 0 {
+			return id, fmt.Errorf("Key name '%s' has an invalid device index, '%s'.", name, device[1])
+		}
+		id.Device.Index = DeviceIndex(index)
+	}
+	index, ok := input.parseKeyIndexName(parts[1], id.Device)
+	if !ok {
+		return id, fmt.Errorf("Key name '%s' has an unknown key, '%s'.", name, parts[1])
+	}
+	id.Index = index
+	if err := input.validKeyId(id); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func (input *Input) parseKeyIndexName(name string, device DeviceId) (KeyIndex, bool) {
+	if strings.HasPrefix(name, "#") {
+		index, err := strconv.Atoi(name[1:])
+		return KeyIndex(index), err == nil && index >= 0
+	}
+	for index, index_name := range input.index_to_name {
+		if index_name == name {
+			return index, true
+		}
+	}
+	for index, family := range input.index_to_family {
+		if family.name == name {
+			return index, true
+		}
+	}
+	// Derived keys made with BindDerivedKey(), MakeChordKey(), and the like are
+	// only known by their names.
+	for id, key := range input.key_map {
+		if id.Device == device && key.Name() == name {
+			return id.Index, true
+		}
+	}
+	return 0, false
+}
+
+// KeyName calls KeyName() on the default Input.
+func KeyName(id KeyId) string {
+	return input_obj.KeyName(id)
+}
+
+// ParseKeyName calls ParseKeyName() on the default Input.
+func ParseKeyName(name string) (KeyId, error) {
+	return input_obj.ParseKeyName(name)
+}
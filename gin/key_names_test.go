@@ -0,0 +1,43 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func KeyNameSpec(c gospec.Context) {
+	input := gin.Make()
+	keyboard := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+	c.Specify("Names are human readable.", func() {
+		c.Expect(input.KeyName(gin.KeyId{Index: gin.KeyA, Device: keyboard}), Equals, "Keyboard 1:Key A")
+		mouse := gin.DeviceId{Type: gin.DeviceTypeMouse, Index: gin.DeviceIndexAny}
+		c.Expect(input.KeyName(gin.KeyId{Index: gin.MouseLButton, Device: mouse}), Equals, "Mouse:MouseLButton")
+		c.Expect(input.KeyName(gin.AnyAnyKey), Equals, "Any:AnyKey")
+	})
+
+	c.Specify("Names parse back into the same KeyId.", func() {
+		controller := gin.DeviceId{Type: gin.DeviceTypeController, Index: 2}
+		chord := input.MakeChordKey(gin.KeyId{Index: gin.KeyA, Device: keyboard}, gin.KeyId{Index: gin.KeyB, Device: keyboard})
+		ids := []gin.KeyId{
+			gin.KeyId{Index: gin.Space, Device: keyboard},
+			gin.KeyId{Index: gin.EitherShift, Device: keyboard},
+			gin.KeyId{Index: gin.ControllerButton0 + 3, Device: controller},
+			gin.KeyId{Index: gin.ControllerAxis0Negative + 1, Device: controller},
+			gin.AnyAnyKey,
+			chord.Id(),
+		}
+		for _, id := range ids {
+			parsed, err := input.ParseKeyName(input.KeyName(id))
+			c.Expect(err, Equals, nil)
+			c.Expect(parsed, Equals, id)
+		}
+	})
+
+	c.Specify("Invalid names are errors.", func() {
+		for _, name := range []string{"", "Key A", "Keyboard 1:Not A Key", "Keyboard x:Key A", "Gamepad:Key A", "Any 1:AnyKey"} {
+			_, err := input.ParseKeyName(name)
+			c.Expect(err, Not(Equals), nil)
+		}
+	})
+}
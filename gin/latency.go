@@ -0,0 +1,102 @@
+package gin
+
+import (
+	"sort"
+	"sync"
+)
+
+// How many of the most recent events latency is measured over.
+const latencySamples = 1024
+
+// Latency says how long events waited between when the os says they happened
+// and the Think() that sent them to listeners, in milliseconds.  Only events
+// that came from the os are measured, not injected or generated ones, and
+// events are measured before Think() clamps their timestamps, so events
+// that arrived after the previous horizon still count their full delay.
+type Latency struct {
+	// Number of events measured since the stats were last reset.
+	Count int
+
+	// Over the most recent events measured, up to the last 1024.
+	Mean          float64
+	Min, Max      int64
+	P50, P95, P99 int64
+}
+
+type latencyStats struct {
+	mutex   sync.Mutex
+	count   int
+	samples []int64
+	next    int
+}
+
+// Records how long each of os_events waited for the Think() with horizon t.
+func (input *Input) recordLatency(t int64, os_events []OsEvent) {
+	if len(os_events) == 0 {
+		return
+	}
+	ls := &input.latency
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	for _, event := range os_events {
+		delta := t - event.Timestamp
+		if delta < 0 {
+			delta = 0
+		}
+		ls.count++
+		if len(ls.samples) < latencySamples {
+			ls.samples = append(ls.samples, delta)
+			continue
+		}
+		ls.samples[ls.next] = delta
+		ls.next = (ls.next + 1) % latencySamples
+	}
+}
+
+// Returns the latency of recent events, see Latency.  Safe to call from any
+// goroutine, so it can be shown by a debug overlay.
+func (input *Input) LatencyStats() Latency {
+	ls := &input.latency
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	stats := Latency{Count: ls.count}
+	if len(ls.samples) == 0 {
+		return stats
+	}
+	sorted := append([]int64(nil), ls.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum int64
+	for _, delta := range sorted {
+		sum += delta
+	}
+	percentile := func(p int) int64 {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	stats.Mean = float64(sum) / float64(len(sorted))
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	stats.P50 = percentile(50)
+	stats.P95 = percentile(95)
+	stats.P99 = percentile(99)
+	return stats
+}
+
+// Forgets all of the events measured so far.
+func (input *Input) ResetLatencyStats() {
+	ls := &input.latency
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	ls.count = 0
+	ls.samples = nil
+	ls.next = 0
+}
+
+// LatencyStats calls LatencyStats() on the default Input.
+func LatencyStats() Latency {
+	return input_obj.LatencyStats()
+}
+
+// ResetLatencyStats calls ResetLatencyStats() on the default Input.
+func ResetLatencyStats() {
+	input_obj.ResetLatencyStats()
+}
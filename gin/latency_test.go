@@ -0,0 +1,49 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func LatencySpec(c gospec.Context) {
+	input := gin.Make()
+	events := make([]gin.OsEvent, 0)
+
+	c.Specify("Latency is measured from event timestamps to the horizon.", func() {
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 90)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 98)
+		input.Think(100, true, events)
+		events = events[0:0]
+
+		// This one arrived after the previous horizon had already passed.
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 80)
+		input.Think(120, true, events)
+
+		stats := input.LatencyStats()
+		c.Expect(stats.Count, Equals, 3)
+		c.Expect(stats.Min, Equals, int64(2))
+		c.Expect(stats.Max, Equals, int64(40))
+		c.Expect(stats.P50, Equals, int64(10))
+		c.Expect(stats.Mean, Equals, 52.0/3)
+	})
+
+	c.Specify("Injected events and events without focus aren't measured.", func() {
+		input.InjectEvent(gin.OsEvent{
+			KeyId:     gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}},
+			Press_amt: 1,
+			Timestamp: 10,
+		})
+		input.Think(20, true, nil)
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 25)
+		input.Think(30, false, events)
+		c.Expect(input.LatencyStats().Count, Equals, 0)
+	})
+
+	c.Specify("Latency stats can be reset.", func() {
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		input.ResetLatencyStats()
+		c.Expect(input.LatencyStats(), Equals, gin.Latency{})
+	})
+}
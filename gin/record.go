@@ -0,0 +1,161 @@
+package gin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The format written by a Recorder is a header followed by any number of
+// frames, everything is little endian:
+//
+//	header: "GINR" int32(version)
+//	frame:  int64(horizon) uint8(has_focus) uint32(num events) events...
+//	event:  int32(device type) int32(device index) int32(key index)
+//	        float64(press amt) int64(timestamp)
+//	        uint8(has_cursor) int32(cursor x) int32(cursor y)
+//
+// The version should be bumped any time this format changes, or any time the
+// meaning of recorded events changes, like when key indices are renumbered.
+const recordingMagic = "GINR"
+const recordingVersion int32 = 2
+
+type recordedFrame struct {
+	Horizon   int64
+	Has_focus uint8
+	Count     uint32
+}
+
+type recordedEvent struct {
+	Device_type  int32
+	Device_index int32
+	Key_index    int32
+	Press_amt    float64
+	Timestamp    int64
+	Has_cursor   uint8
+	Cursor_x     int32
+	Cursor_y     int32
+}
+
+// A Recorder writes every frame of OsEvents that is passed to Input.Think() to
+// an io.Writer so that they can be played back later with a Replayer.
+type Recorder struct {
+	w   io.Writer
+	err error
+}
+
+// MakeRecorder writes the recording header to w and returns a Recorder that
+// will write frames to w.
+func MakeRecorder(w io.Writer) (*Recorder, error) {
+	if _, err := io.WriteString(w, recordingMagic); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, recordingVersion); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w}, nil
+}
+
+// Record writes a single frame.  The parameters are the same as those that
+// are passed to Input.Think().  Once a write has failed every following call
+// to Record will return the same error without writing anything, so that a
+// recording is never left with a frame missing from the middle.
+func (r *Recorder) Record(horizon int64, has_focus bool, events []OsEvent) error {
+	if r.err != nil {
+		return r.err
+	}
+	frame := recordedFrame{Horizon: horizon, Count: uint32(len(events))}
+	if has_focus {
+		frame.Has_focus = 1
+	}
+	r.err = binary.Write(r.w, binary.LittleEndian, frame)
+	for i := 0; i < len(events) && r.err == nil; i++ {
+		event := recordedEvent{
+			Device_type:  int32(events[i].KeyId.Device.Type),
+			Device_index: int32(events[i].KeyId.Device.Index),
+			Key_index:    int32(events[i].KeyId.Index),
+			Press_amt:    events[i].Press_amt,
+			Timestamp:    events[i].Timestamp,
+			Cursor_x:     int32(events[i].Cursor_x),
+			Cursor_y:     int32(events[i].Cursor_y),
+		}
+		if events[i].Has_cursor {
+			event.Has_cursor = 1
+		}
+		r.err = binary.Write(r.w, binary.LittleEndian, event)
+	}
+	return r.err
+}
+
+// A Replayer reads frames written by a Recorder so that they can be fed back
+// into an Input in place of the events that would have come from the OS.
+type Replayer struct {
+	r io.Reader
+}
+
+// MakeReplayer reads and checks the recording header from r and returns a
+// Replayer that will read frames from r.
+func MakeReplayer(r io.Reader) (*Replayer, error) {
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != recordingMagic {
+		return nil, fmt.Errorf("Not an input recording.")
+	}
+	var version int32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != recordingVersion {
+		return nil, fmt.Errorf("Input recording is version %d, only version %d is supported.", version, recordingVersion)
+	}
+	return &Replayer{r: r}, nil
+}
+
+// Next returns the next recorded frame.  After the last frame it returns
+// io.EOF.
+func (r *Replayer) Next() (horizon int64, has_focus bool, events []OsEvent, err error) {
+	var frame recordedFrame
+	err = binary.Read(r.r, binary.LittleEndian, &frame)
+	if err != nil {
+		return
+	}
+	events = make([]OsEvent, frame.Count)
+	for i := range events {
+		var event recordedEvent
+		err = binary.Read(r.r, binary.LittleEndian, &event)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, false, nil, err
+		}
+		events[i] = OsEvent{
+			KeyId: KeyId{
+				Device: DeviceId{
+					Type:  DeviceType(event.Device_type),
+					Index: DeviceIndex(event.Device_index),
+				},
+				Index: KeyIndex(event.Key_index),
+			},
+			Press_amt:  event.Press_amt,
+			Timestamp:  event.Timestamp,
+			Has_cursor: event.Has_cursor != 0,
+			Cursor_x:   int(event.Cursor_x),
+			Cursor_y:   int(event.Cursor_y),
+		}
+	}
+	return frame.Horizon, frame.Has_focus != 0, events, nil
+}
+
+// Think reads the next recorded frame and passes it to input.Think(), which
+// makes it easy to drive an Input from a recording in tests.  After the last
+// frame it returns io.EOF and does not call input.Think().
+func (r *Replayer) Think(input *Input) ([]EventGroup, error) {
+	horizon, has_focus, events, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	return input.Think(horizon, has_focus, events), nil
+}
@@ -0,0 +1,71 @@
+package gin_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"io"
+)
+
+func RecordSpec(c gospec.Context) {
+	buf := bytes.NewBuffer(nil)
+	recorder, err := gin.MakeRecorder(buf)
+	c.Assume(err, Equals, nil)
+	var frames [][]gin.OsEvent
+	events := make([]gin.OsEvent, 0)
+	injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 3)
+	injectEvent(&events, gin.MouseXAxis, 1, gin.DeviceTypeMouse, -4, 4)
+	events[1].Has_cursor = true
+	events[1].Cursor_x = 12
+	events[1].Cursor_y = 34
+	frames = append(frames, events)
+	events = make([]gin.OsEvent, 0)
+	injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 7)
+	frames = append(frames, events, nil)
+	c.Expect(recorder.Record(5, true, frames[0]), Equals, nil)
+	c.Expect(recorder.Record(10, true, frames[1]), Equals, nil)
+	c.Expect(recorder.Record(15, false, frames[2]), Equals, nil)
+
+	c.Specify("Replayed frames match recorded frames.", func() {
+		replayer, err := gin.MakeReplayer(bytes.NewReader(buf.Bytes()))
+		c.Assume(err, Equals, nil)
+		for i, horizon := range []int64{5, 10, 15} {
+			t, has_focus, replayed, err := replayer.Next()
+			c.Expect(err, Equals, nil)
+			c.Expect(t, Equals, horizon)
+			c.Expect(has_focus, Equals, i < 2)
+			c.Expect(len(replayed), Equals, len(frames[i]))
+			for j := range replayed {
+				c.Expect(replayed[j], Equals, frames[i][j])
+			}
+		}
+		_, _, _, err = replayer.Next()
+		c.Expect(err, Equals, io.EOF)
+	})
+
+	c.Specify("Replayers can drive an Input.", func() {
+		input := gin.Make()
+		key := input.GetKeyFlat(gin.KeyA, gin.DeviceTypeKeyboard, 1)
+		replayer, err := gin.MakeReplayer(bytes.NewReader(buf.Bytes()))
+		c.Assume(err, Equals, nil)
+		_, err = replayer.Think(input)
+		c.Expect(err, Equals, nil)
+		c.Expect(key.IsDown(), Equals, true)
+		_, err = replayer.Think(input)
+		c.Expect(key.IsDown(), Equals, false)
+		c.Expect(key.FrameReleaseCount(), Equals, 1)
+	})
+
+	c.Specify("Truncated recordings are reported.", func() {
+		replayer, err := gin.MakeReplayer(bytes.NewReader(buf.Bytes()[0:20]))
+		c.Assume(err, Equals, nil)
+		_, _, _, err = replayer.Next()
+		c.Expect(err, Equals, io.ErrUnexpectedEOF)
+	})
+
+	c.Specify("Things that aren't recordings are rejected.", func() {
+		_, err := gin.MakeReplayer(bytes.NewReader([]byte("GIF89a and so on")))
+		c.Expect(err, Not(Equals), nil)
+	})
+}
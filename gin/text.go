@@ -0,0 +1,53 @@
+package gin
+
+import (
+	"unicode"
+)
+
+// A TextEvent is a single character of text that was typed.  Unlike key
+// events, text events take the keyboard layout, shift states, dead keys, and
+// the platform's input method into account, so they should be used whenever
+// the user is entering text rather than controlling something.
+type TextEvent struct {
+	Rune      rune
+	Timestamp int64
+}
+
+// Listeners that also implement TextListener will receive TextEvents.  Each
+// TextEvent is sent after the key events with the same or an earlier timestamp,
+// so the key that was pressed to type a character will already be down.
+type TextListener interface {
+	HandleTextEvent(TextEvent)
+}
+
+// AddTextEvents queues up text events that will be sent to listeners during
+// the next call to Think().  Events will be sorted by timestamp if they aren't
+// already.
+// Control characters, like backspace and return, are dropped since they are
+// better handled as key events.
+func (input *Input) AddTextEvents(events []TextEvent) {
+	for _, event := range events {
+		if !unicode.IsGraphic(event.Rune) {
+			continue
+		}
+		input.text_events = append(input.text_events, event)
+	}
+}
+
+// Sends all pending text events with timestamps before t to listeners that
+// are TextListeners.
+func (input *Input) sendTextEvents(t int64) {
+	var n int
+	for n = 0; n < len(input.text_events); n++ {
+		event := input.text_events[n]
+		if event.Timestamp >= t {
+			break
+		}
+		for _, listener := range input.activeListeners() {
+			if tl, ok := listener.(TextListener); ok {
+				tl.HandleTextEvent(event)
+			}
+		}
+	}
+	input.text_events = input.text_events[n:]
+}
@@ -0,0 +1,50 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func TextSpec(c gospec.Context) {
+	input := gin.Make()
+	typist := &textWatcher{input: input}
+	input.RegisterEventListener(typist)
+
+	c.Specify("Text events are sent after the key events that caused them.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.LeftShift, 1, gin.DeviceTypeKeyboard, 1, 1)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 2)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 3)
+		input.AddTextEvents([]gin.TextEvent{{Rune: 'A', Timestamp: 2}, {Rune: 'é', Timestamp: 5}})
+		input.Think(10, true, events)
+		c.Expect(string(typist.text), Equals, "Aé")
+		c.Expect(typist.a_was_down, ContainsInOrder, []bool{true, false})
+	})
+
+	c.Specify("Control characters are dropped.", func() {
+		input.AddTextEvents([]gin.TextEvent{{Rune: '\b', Timestamp: 1}, {Rune: 'x', Timestamp: 2}, {Rune: '\r', Timestamp: 3}})
+		input.Think(10, true, nil)
+		c.Expect(string(typist.text), Equals, "x")
+	})
+
+	c.Specify("Text events are dropped without focus.", func() {
+		input.AddTextEvents([]gin.TextEvent{{Rune: 'x', Timestamp: 2}})
+		input.Think(10, false, nil)
+		input.Think(20, true, nil)
+		c.Expect(len(typist.text), Equals, 0)
+	})
+}
+
+type textWatcher struct {
+	input      *gin.Input
+	text       []rune
+	a_was_down []bool
+}
+
+func (tw *textWatcher) HandleEventGroup(group gin.EventGroup) {}
+func (tw *textWatcher) Think()                                {}
+func (tw *textWatcher) HandleTextEvent(event gin.TextEvent) {
+	tw.text = append(tw.text, event.Rune)
+	tw.a_was_down = append(tw.a_was_down, tw.input.GetKeyFlat(gin.KeyA, gin.DeviceTypeKeyboard, 1).IsDown())
+}
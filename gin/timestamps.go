@@ -0,0 +1,67 @@
+package gin
+
+import (
+	"sort"
+)
+
+// Platforms don't all agree on how timestamps should behave, they may come
+// from a clock that can jump backwards, events may be reported out of order,
+// and events may show up with timestamps from before the horizon of a
+// previous frame.  Think() rebases everything onto a clock that never goes
+// backwards: the horizon never decreases, and every event is given a timestamp
+// no earlier than the previous horizon and no later than the current one.
+// Listeners are therefore always sent events in sorted order.
+
+// Returns the horizon that Think() should use in place of t.
+func (input *Input) normalizeHorizon(t int64) int64 {
+	if input.has_thought && t < input.last_horizon {
+		return input.last_horizon
+	}
+	return t
+}
+
+// Returns ts clamped to the range [last horizon, t].
+func (input *Input) clampTimestamp(t, ts int64) int64 {
+	if input.has_thought && ts < input.last_horizon {
+		ts = input.last_horizon
+	}
+	if ts > t {
+		ts = t
+	}
+	return ts
+}
+
+// Clamps the timestamps of events to the range [last horizon, t], and sorts
+// them by timestamp without changing the order of events with the same
+// timestamp.  Pending text events are clamped and sorted in the same way.
+// Returns the sorted events, os_events itself is not modified.
+func (input *Input) normalizeTimestamps(t int64, os_events []OsEvent) []OsEvent {
+	clamp := func(ts int64) int64 {
+		return input.clampTimestamp(t, ts)
+	}
+	events := make([]OsEvent, len(os_events))
+	copy(events, os_events)
+	for i := range events {
+		events[i].Timestamp = clamp(events[i].Timestamp)
+	}
+	sort.Stable(osEventsByTime(events))
+	for i := range input.text_events {
+		input.text_events[i].Timestamp = clamp(input.text_events[i].Timestamp)
+	}
+	sort.Stable(textEventsByTime(input.text_events))
+	input.last_horizon = t
+	input.has_thought = true
+	return events
+}
+
+type osEventsByTime []OsEvent
+
+func (e osEventsByTime) Len() int           { return len(e) }
+func (e osEventsByTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e osEventsByTime) Less(i, j int) bool { return e[i].Timestamp < e[j].Timestamp }
+
+type textEventsByTime []TextEvent
+
+func (e textEventsByTime) Len() int           { return len(e) }
+func (e textEventsByTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e textEventsByTime) Less(i, j int) bool { return e[i].Timestamp < e[j].Timestamp }
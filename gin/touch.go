@@ -0,0 +1,156 @@
+package gin
+
+import (
+	"math"
+)
+
+// The number of fingers that can be tracked at once on each touch device.
+const maxTouchFingers = 10
+
+// A finger that is lifted within tapMaxMs of touching down, and that didn't
+// move more than tapSlop pixels in either direction, is a tap.  A single
+// finger that moves further than that is a drag.
+const tapMaxMs = 250
+const tapSlop = 10
+
+func isTouchFinger(index KeyIndex) bool {
+	return index >= TouchFinger0 && index < TouchFinger0+maxTouchFingers
+}
+
+// Gestures on touch devices are reported through the following keys, all of
+// which are on the touch device that the gesture happened on:
+//
+//	TouchTap is a wheel that is pressed by 1 for each tap, so FramePressSum()
+//	is the number of taps in the last frame.
+//	TouchDragX and TouchDragY are axes that report how far, in pixels, a
+//	single finger has been dragged.  Like cursors, positive y is up.
+//	TouchPinch is an axis that reports how much, in pixels, the distance
+//	between two fingers has changed.  It is positive when they move apart.
+//	TouchScrollX and TouchScrollY are wheels that report how far, in pixels,
+//	two fingers have been dragged together.
+//
+// The events for a gesture are in the same EventGroup as the finger event that
+// caused them.
+type touchState struct {
+	fingers map[KeyIndex]*touchFinger
+
+	// True while the only finger down might still turn out to be a tap.
+	tap_candidate bool
+	tap_start     int64
+
+	// The distance between the first two fingers and their midpoint, as of the
+	// last pinch and scroll events.
+	spread       float64
+	mid_x, mid_y float64
+}
+
+type touchFinger struct {
+	// When the finger touched down, in the order that fingers touched down.
+	order int
+
+	// Where the finger touched down and where it was when we last reported
+	// a drag for it.
+	start_x, start_y int
+	x, y             int
+}
+
+func (input *Input) recognizeGestures(event OsEvent, group *EventGroup) {
+	id := event.KeyId
+	if id.Device.Type != DeviceTypeTouch || !isTouchFinger(id.Index) {
+		return
+	}
+	ts, ok := input.touches[id.Device]
+	if !ok {
+		ts = &touchState{fingers: make(map[KeyIndex]*touchFinger)}
+		input.touches[id.Device] = ts
+	}
+	x, y := input.getCursor(id).Point()
+	finger, was_down := ts.fingers[id.Index]
+	switch {
+	case event.Press_amt != 0 && !was_down:
+		ts.fingers[id.Index] = &touchFinger{
+			order:   ts.nextOrder(),
+			start_x: x,
+			start_y: y,
+			x:       x,
+			y:       y,
+		}
+		ts.tap_candidate = len(ts.fingers) == 1
+		ts.tap_start = event.Timestamp
+		ts.resetTwoFinger()
+
+	case event.Press_amt != 0 && was_down:
+		switch len(ts.fingers) {
+		case 1:
+			if ts.tap_candidate && (abs(x-finger.start_x) > tapSlop || abs(y-finger.start_y) > tapSlop) {
+				ts.tap_candidate = false
+			}
+			if !ts.tap_candidate {
+				input.pressGesture(id.Device, TouchDragX, float64(x-finger.x), group)
+				input.pressGesture(id.Device, TouchDragY, float64(y-finger.y), group)
+			}
+		default:
+			finger.x, finger.y = x, y
+			spread, mid_x, mid_y := ts.twoFinger()
+			input.pressGesture(id.Device, TouchPinch, spread-ts.spread, group)
+			input.pressGesture(id.Device, TouchScrollX, mid_x-ts.mid_x, group)
+			input.pressGesture(id.Device, TouchScrollY, mid_y-ts.mid_y, group)
+			ts.spread, ts.mid_x, ts.mid_y = spread, mid_x, mid_y
+		}
+		if !ts.tap_candidate {
+			finger.x, finger.y = x, y
+		}
+
+	case event.Press_amt == 0 && was_down:
+		if ts.tap_candidate && event.Timestamp-ts.tap_start <= tapMaxMs {
+			input.pressGesture(id.Device, TouchTap, 1, group)
+		}
+		delete(ts.fingers, id.Index)
+		ts.tap_candidate = false
+		ts.resetTwoFinger()
+	}
+}
+
+// Sends amt to the gesture key with index on device, if it is non-zero.
+func (input *Input) pressGesture(device DeviceId, index KeyIndex, amt float64, group *EventGroup) {
+	if amt == 0 {
+		return
+	}
+	input.pressKey(input.GetKey(KeyId{Device: device, Index: index}), amt, Event{}, group)
+}
+
+func (ts *touchState) nextOrder() int {
+	order := 0
+	for _, finger := range ts.fingers {
+		if finger.order >= order {
+			order = finger.order + 1
+		}
+	}
+	return order
+}
+
+// Returns the spread and midpoint of the two fingers that have been down the
+// longest.
+func (ts *touchState) twoFinger() (spread, mid_x, mid_y float64) {
+	var a, b *touchFinger
+	for _, finger := range ts.fingers {
+		switch {
+		case a == nil || finger.order < a.order:
+			a, b = finger, a
+		case b == nil || finger.order < b.order:
+			b = finger
+		}
+	}
+	if b == nil {
+		return 0, 0, 0
+	}
+	dx := float64(a.x - b.x)
+	dy := float64(a.y - b.y)
+	return math.Sqrt(dx*dx + dy*dy), float64(a.x+b.x) / 2, float64(a.y+b.y) / 2
+}
+
+// Called whenever the set of fingers changes so that pinches and scrolls are
+// measured from where the fingers are now.
+func (ts *touchState) resetTwoFinger() {
+	ts.spread, ts.mid_x, ts.mid_y = ts.twoFinger()
+}
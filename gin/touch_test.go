@@ -0,0 +1,74 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func TouchSpec(c gospec.Context) {
+	input := gin.Make()
+	tap := input.GetKeyFlat(gin.TouchTap, gin.DeviceTypeTouch, 1)
+	drag_x := input.GetKeyFlat(gin.TouchDragX, gin.DeviceTypeTouch, 1)
+	drag_y := input.GetKeyFlat(gin.TouchDragY, gin.DeviceTypeTouch, 1)
+	pinch := input.GetKeyFlat(gin.TouchPinch, gin.DeviceTypeTouch, 1)
+	scroll_y := input.GetKeyFlat(gin.TouchScrollY, gin.DeviceTypeTouch, 1)
+	events := make([]gin.OsEvent, 0)
+	touch := func(finger int, pressure float64, x, y int, t int64) {
+		injectEvent(&events, gin.TouchFinger0+gin.KeyIndex(finger), 1, gin.DeviceTypeTouch, pressure, t)
+		events[len(events)-1].Has_cursor = true
+		events[len(events)-1].Cursor_x = x
+		events[len(events)-1].Cursor_y = y
+	}
+
+	c.Specify("Fingers have positions and pressures.", func() {
+		touch(0, 0.5, 10, 20, 1)
+		touch(1, 1, 30, 40, 2)
+		input.Think(3, true, events)
+		finger := input.GetKeyFlat(gin.TouchFinger0+1, gin.DeviceTypeTouch, 1)
+		c.Expect(finger.IsDown(), Equals, true)
+		x, y := finger.Cursor().Point()
+		c.Expect(x, Equals, 30)
+		c.Expect(y, Equals, 40)
+		c.Expect(input.GetKeyFlat(gin.TouchFinger0, gin.DeviceTypeTouch, 1).CurPressAmt(), Equals, 0.5)
+	})
+
+	c.Specify("Quick touches are taps.", func() {
+		touch(0, 1, 10, 10, 10)
+		touch(0, 1, 12, 9, 20)
+		touch(0, 0, 12, 9, 30)
+		touch(0, 1, 50, 50, 40)
+		touch(0, 0, 50, 50, 400)
+		input.Think(500, true, events)
+		c.Expect(tap.FramePressSum(), Equals, 1.0)
+		c.Expect(drag_x.FramePressSum(), Equals, 0.0)
+	})
+
+	c.Specify("One finger drags.", func() {
+		touch(0, 1, 10, 10, 10)
+		touch(0, 1, 30, 5, 20)
+		touch(0, 1, 35, 0, 30)
+		touch(0, 0, 35, 0, 40)
+		input.Think(50, true, events)
+		c.Expect(tap.FramePressSum(), Equals, 0.0)
+		c.Expect(drag_x.FramePressSum(), Equals, 25.0)
+		c.Expect(drag_y.FramePressSum(), Equals, -10.0)
+	})
+
+	c.Specify("Two fingers pinch and scroll.", func() {
+		touch(0, 1, 0, 0, 10)
+		touch(1, 1, 30, 40, 20)
+		touch(1, 1, 60, 80, 30)
+		input.Think(40, true, events)
+		events = events[0:0]
+		c.Expect(pinch.FramePressSum(), Equals, 50.0)
+		c.Expect(scroll_y.FramePressSum(), Equals, 20.0)
+		c.Expect(drag_x.FramePressSum(), Equals, 0.0)
+
+		touch(0, 1, 0, 10, 50)
+		touch(1, 1, 60, 90, 60)
+		input.Think(70, true, events)
+		c.Expect(pinch.FramePressSum(), Equals, 0.0)
+		c.Expect(scroll_y.FramePressSum(), Equals, 10.0)
+	})
+}
@@ -0,0 +1,136 @@
+package gin
+
+// WindowEventType is the kind of thing that happened to the window in a
+// WindowEvent.
+type WindowEventType int
+
+const (
+	// The user asked to close the window, e.g. with its close button.  The
+	// window is not closed, it is up to the application to decide what to do.
+	WindowClose WindowEventType = iota
+
+	// The window changed size, the new size is in Dx and Dy.
+	WindowResize
+
+	// The window gained or lost focus.
+	WindowFocus
+	WindowBlur
+
+	// The window was minimized, or restored after having been minimized.
+	WindowMinimize
+	WindowRestore
+)
+
+func (t WindowEventType) String() string {
+	switch t {
+	case WindowClose:
+		return "WindowClose"
+	case WindowResize:
+		return "WindowResize"
+	case WindowFocus:
+		return "WindowFocus"
+	case WindowBlur:
+		return "WindowBlur"
+	case WindowMinimize:
+		return "WindowMinimize"
+	case WindowRestore:
+		return "WindowRestore"
+	}
+	return "WindowEventType(?)"
+}
+
+// A WindowEvent is sent to listeners when something happens to the window
+// rather than to an input device.  Dx and Dy are only set for WindowResize
+// events.
+type WindowEvent struct {
+	Type      WindowEventType
+	Dx, Dy    int
+	Timestamp int64
+}
+
+// Listeners that also implement WindowListener will be told about all window
+// events.  Window events are sent before any key events in the same call to
+// Input.Think(), so a game that pauses when its window loses focus can do so
+// before it sees the key releases that losing focus causes.
+type WindowListener interface {
+	HandleWindowEvent(WindowEvent)
+}
+
+// A ResizeEvent is sent to listeners when the window changes size.  Dx and Dy
+// are the new dimensions of the window in pixels.
+type ResizeEvent struct {
+	Dx, Dy    int
+	Timestamp int64
+}
+
+// Listeners that also implement ResizeListener will be told when the window
+// changes size.  Resize events are sent before any key events in the same call
+// to Input.Think(), so cursor positions in those events are already relative
+// to the resized window.
+type ResizeListener interface {
+	HandleResizeEvent(ResizeEvent)
+}
+
+// AddWindowEvents queues up window events that will be sent to listeners
+// during the next call to Think().  WindowResize events are passed on to
+// WindowResized(), so they are only sent if the size actually changed.
+func (input *Input) AddWindowEvents(events []WindowEvent) {
+	for _, event := range events {
+		if event.Type == WindowResize {
+			input.WindowResized(event.Timestamp, event.Dx, event.Dy)
+			continue
+		}
+		input.window_events = append(input.window_events, event)
+	}
+}
+
+// WindowResized tells the Input object that the window is now dx by dy pixels.
+// If that is different from the last size it was given a WindowResize event
+// and a ResizeEvent are sent to listeners on the next call to Think().  The
+// first call only records the size, since the window hasn't been resized from
+// anything.
+func (input *Input) WindowResized(t int64, dx, dy int) {
+	if !input.has_window_dims {
+		input.has_window_dims = true
+		input.window_dx, input.window_dy = dx, dy
+		return
+	}
+	if dx == input.window_dx && dy == input.window_dy {
+		return
+	}
+	input.window_dx, input.window_dy = dx, dy
+	input.window_events = append(input.window_events, WindowEvent{
+		Type:      WindowResize,
+		Dx:        dx,
+		Dy:        dy,
+		Timestamp: t,
+	})
+}
+
+// WindowDims returns the size most recently given to WindowResized().
+func (input *Input) WindowDims() (dx, dy int) {
+	return input.window_dx, input.window_dy
+}
+
+// Sends all pending window events to listeners that are WindowListeners, and
+// resizes to listeners that are ResizeListeners.
+func (input *Input) sendWindowEvents() {
+	for _, window_event := range input.window_events {
+		for _, listener := range input.allListeners() {
+			if wl, ok := listener.(WindowListener); ok {
+				wl.HandleWindowEvent(window_event)
+			}
+			if window_event.Type != WindowResize {
+				continue
+			}
+			if rl, ok := listener.(ResizeListener); ok {
+				rl.HandleResizeEvent(ResizeEvent{
+					Dx:        window_event.Dx,
+					Dy:        window_event.Dy,
+					Timestamp: window_event.Timestamp,
+				})
+			}
+		}
+	}
+	input.window_events = input.window_events[0:0]
+}
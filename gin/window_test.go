@@ -0,0 +1,111 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+type resizeWatcher struct {
+	resizes []gin.ResizeEvent
+	windows []gin.WindowEvent
+	groups  int
+
+	// number of event groups that had been seen when the last WindowBlur arrived
+	groups_at_blur int
+}
+
+func (rw *resizeWatcher) HandleEventGroup(group gin.EventGroup) {
+	rw.groups++
+}
+func (rw *resizeWatcher) Think() {}
+func (rw *resizeWatcher) HandleResizeEvent(event gin.ResizeEvent) {
+	rw.resizes = append(rw.resizes, event)
+}
+func (rw *resizeWatcher) HandleWindowEvent(event gin.WindowEvent) {
+	if event.Type == gin.WindowBlur {
+		rw.groups_at_blur = rw.groups
+	}
+	rw.windows = append(rw.windows, event)
+}
+
+func ResizeSpec(c gospec.Context) {
+	input := gin.Make()
+	listener := &resizeWatcher{}
+	input.RegisterEventListener(listener)
+	input.WindowResized(1, 800, 600)
+	input.Think(1, true, nil)
+
+	c.Specify("The initial window size is not a resize.", func() {
+		c.Expect(len(listener.resizes), Equals, 0)
+		dx, dy := input.WindowDims()
+		c.Expect(dx, Equals, 800)
+		c.Expect(dy, Equals, 600)
+	})
+
+	c.Specify("Changes in size are reported to ResizeListeners once.", func() {
+		input.WindowResized(5, 800, 600)
+		input.WindowResized(6, 1024, 768)
+		input.Think(10, true, nil)
+		input.Think(20, true, nil)
+		c.Expect(len(listener.resizes), Equals, 1)
+		if len(listener.resizes) == 1 {
+			c.Expect(listener.resizes[0].Dx, Equals, 1024)
+			c.Expect(listener.resizes[0].Dy, Equals, 768)
+			c.Expect(listener.resizes[0].Timestamp, Equals, int64(6))
+		}
+		dx, dy := input.WindowDims()
+		c.Expect(dx, Equals, 1024)
+		c.Expect(dy, Equals, 768)
+	})
+}
+
+func WindowEventSpec(c gospec.Context) {
+	input := gin.Make()
+	listener := &resizeWatcher{}
+	input.RegisterEventListener(listener)
+	input.WindowResized(1, 800, 600)
+	input.Think(1, true, nil)
+
+	c.Specify("Window events are sent to WindowListeners.", func() {
+		input.AddWindowEvents([]gin.WindowEvent{
+			{Type: gin.WindowMinimize, Timestamp: 2},
+			{Type: gin.WindowRestore, Timestamp: 3},
+			{Type: gin.WindowClose, Timestamp: 4},
+		})
+		input.Think(10, true, nil)
+		c.Expect(len(listener.windows), Equals, 3)
+		if len(listener.windows) == 3 {
+			c.Expect(listener.windows[0].Type, Equals, gin.WindowMinimize)
+			c.Expect(listener.windows[1].Type, Equals, gin.WindowRestore)
+			c.Expect(listener.windows[2].Type, Equals, gin.WindowClose)
+			c.Expect(listener.windows[2].Timestamp, Equals, int64(4))
+		}
+	})
+
+	c.Specify("Resize window events only go out if the size changed.", func() {
+		input.AddWindowEvents([]gin.WindowEvent{
+			{Type: gin.WindowResize, Dx: 800, Dy: 600, Timestamp: 2},
+			{Type: gin.WindowResize, Dx: 640, Dy: 480, Timestamp: 3},
+		})
+		input.Think(10, true, nil)
+		c.Expect(len(listener.windows), Equals, 1)
+		c.Expect(len(listener.resizes), Equals, 1)
+		if len(listener.windows) == 1 {
+			c.Expect(listener.windows[0].Type, Equals, gin.WindowResize)
+			c.Expect(listener.windows[0].Dx, Equals, 640)
+		}
+	})
+
+	c.Specify("Losing focus is reported before the keys are released.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(listener.groups, Equals, 1)
+		input.AddWindowEvents([]gin.WindowEvent{{Type: gin.WindowBlur, Timestamp: 15}})
+		input.Think(20, false, nil)
+		c.Expect(listener.groups, Equals, 2)
+		c.Expect(len(listener.windows), Equals, 1)
+		c.Expect(listener.groups_at_blur, Equals, 1)
+	})
+}
@@ -0,0 +1,13 @@
+package glog_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(LogSpec)
+	r.AddSpec(RecentSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,246 @@
+// Package glog logs messages at different levels of severity, writing them
+// to stderr and also keeping the most recent ones in memory so that they can
+// be shown in game, see glog/overlay.  The rest of glop logs here for
+// problems that aren't worth failing over, like a frame of animation that
+// can't be decoded.
+//
+// Lines are written like google's glog writes them:
+//
+//	W0102 15:04:05.000000 sheet.go:97] Can't decode 0/walk.png: unexpected EOF
+package glog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warning
+	Error
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "Debug"
+	case Info:
+		return "Info"
+	case Warning:
+		return "Warning"
+	case Error:
+		return "Error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// The letter that lines at this level start with.
+func (l Level) letter() byte {
+	if l >= Debug && l <= Error {
+		return "DIWE"[l]
+	}
+	return '?'
+}
+
+type Entry struct {
+	Time  time.Time
+	Level Level
+
+	// Base name of the file and the line that logged the message.
+	File string
+	Line int
+
+	Message string
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("%c%s %s:%d] %s", e.Level.letter(), e.Time.Format("0102 15:04:05.000000"), e.File, e.Line, e.Message)
+}
+
+// A Logger writes entries to its output and keeps the most recent ones.  It
+// is safe to use from any goroutine.
+type Logger struct {
+	mutex sync.Mutex
+	level Level
+	out   io.Writer
+
+	// The most recent entries, ring[next] is the oldest once it's full.
+	ring []Entry
+	next int
+	full bool
+}
+
+// Makes a Logger that keeps the last capacity entries, writes to stderr, and
+// ignores anything below Info.
+func MakeLogger(capacity int) *Logger {
+	if capacity <= 0 {
+		panic(fmt.Sprintf("Cannot keep %d entries.", capacity))
+	}
+	return &Logger{
+		level: Info,
+		out:   os.Stderr,
+		ring:  make([]Entry, capacity),
+	}
+}
+
+var the_logger = MakeLogger(1000)
+
+// Returns the Logger used by the package level functions.
+func Default() *Logger {
+	return the_logger
+}
+
+// Sets where entries are written, nil discards them.  They are still kept
+// for Recent() either way.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.out = w
+}
+
+// Sets the lowest level that isn't ignored.
+func (l *Logger) SetLevel(level Level) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.level = level
+}
+
+func (l *Logger) Level() Level {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.level
+}
+
+// Logs a message at level, depth is how many stack frames there are between
+// the caller of Log and the code that the message should be attributed to.
+func (l *Logger) Log(level Level, depth int, format string, args ...interface{}) {
+	l.mutex.Lock()
+	ignored := level < l.level
+	l.mutex.Unlock()
+	if ignored {
+		return
+	}
+	e := Entry{Time: time.Now(), Level: level, File: "???", Message: fmt.Sprintf(format, args...)}
+	if _, file, line, ok := runtime.Caller(depth + 1); ok {
+		e.File, e.Line = filepath.Base(file), line
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.ring[l.next] = e
+	l.next++
+	if l.next == len(l.ring) {
+		l.next = 0
+		l.full = true
+	}
+	if l.out != nil {
+		fmt.Fprintln(l.out, e)
+	}
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.Log(Debug, 1, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.Log(Info, 1, format, args...)
+}
+
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.Log(Warning, 1, format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.Log(Error, 1, format, args...)
+}
+
+// Returns up to the last n entries at min or above, oldest first.
+func (l *Logger) Recent(min Level, n int) []Entry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	stored := l.next
+	if l.full {
+		stored = len(l.ring)
+	}
+	var entries []Entry
+	for k := 0; k < stored && len(entries) < n; k++ {
+		e := l.ring[(l.next-1-k+len(l.ring))%len(l.ring)]
+		if e.Level >= min {
+			entries = append(entries, e)
+		}
+	}
+	for a, b := 0, len(entries)-1; a < b; a, b = a+1, b-1 {
+		entries[a], entries[b] = entries[b], entries[a]
+	}
+	return entries
+}
+
+// Forgets all of the entries kept for Recent().
+func (l *Logger) Clear() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i := range l.ring {
+		l.ring[i] = Entry{}
+	}
+	l.next = 0
+	l.full = false
+}
+
+// Anything with a Printf, like a *log.Logger or render.Logger.
+type Printer interface {
+	Printf(format string, args ...interface{})
+}
+
+type printer struct {
+	l     *Logger
+	level Level
+}
+
+func (p printer) Printf(format string, args ...interface{}) {
+	p.l.Log(p.level, 1, format, args...)
+}
+
+// Returns a Printer that logs everything to l at level, for things that take
+// a *log.Logger or something like it.
+func (l *Logger) At(level Level) Printer {
+	return printer{l, level}
+}
+
+func SetOutput(w io.Writer) {
+	the_logger.SetOutput(w)
+}
+
+func SetLevel(level Level) {
+	the_logger.SetLevel(level)
+}
+
+func Debugf(format string, args ...interface{}) {
+	the_logger.Log(Debug, 1, format, args...)
+}
+
+func Infof(format string, args ...interface{}) {
+	the_logger.Log(Info, 1, format, args...)
+}
+
+func Warningf(format string, args ...interface{}) {
+	the_logger.Log(Warning, 1, format, args...)
+}
+
+func Errorf(format string, args ...interface{}) {
+	the_logger.Log(Error, 1, format, args...)
+}
+
+func Recent(min Level, n int) []Entry {
+	return the_logger.Recent(min, n)
+}
+
+func At(level Level) Printer {
+	return the_logger.At(level)
+}
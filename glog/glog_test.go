@@ -0,0 +1,99 @@
+package glog_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/glog"
+	"strings"
+)
+
+func LogSpec(c gospec.Context) {
+	var buf bytes.Buffer
+	l := glog.MakeLogger(10)
+	l.SetOutput(&buf)
+
+	c.Specify("Lines say the level, file, and line they came from", func() {
+		l.Warningf("Something is %s.", "wrong")
+		line := buf.String()
+		c.Expect(line[0], Equals, byte('W'))
+		c.Expect(strings.Contains(line, " glog_test.go:17] Something is wrong.\n"), Equals, true)
+	})
+
+	c.Specify("Levels below the logger's level are ignored", func() {
+		l.Debugf("debug")
+		l.Infof("info")
+		c.Expect(buf.Len(), Not(Equals), 0)
+		c.Expect(strings.Contains(buf.String(), "debug"), Equals, false)
+		l.SetLevel(glog.Error)
+		buf.Reset()
+		l.Warningf("warning")
+		l.Errorf("error")
+		c.Expect(strings.HasPrefix(buf.String(), "E"), Equals, true)
+		c.Expect(strings.Contains(buf.String(), "warning"), Equals, false)
+		c.Expect(len(l.Recent(glog.Debug, 10)), Equals, 2)
+	})
+
+	c.Specify("At logs everything at one level", func() {
+		l.At(glog.Error).Printf("%d problems", 3)
+		entries := l.Recent(glog.Debug, 10)
+		c.Assume(len(entries), Equals, 1)
+		c.Expect(entries[0].Level, Equals, glog.Error)
+		c.Expect(entries[0].Message, Equals, "3 problems")
+		c.Expect(entries[0].File, Equals, "glog_test.go")
+	})
+
+	c.Specify("A nil output still keeps entries", func() {
+		l.SetOutput(nil)
+		l.Infof("quiet")
+		c.Expect(buf.Len(), Equals, 0)
+		c.Expect(len(l.Recent(glog.Debug, 10)), Equals, 1)
+	})
+}
+
+func messages(entries []glog.Entry) []string {
+	var ms []string
+	for _, e := range entries {
+		ms = append(ms, e.Message)
+	}
+	return ms
+}
+
+func RecentSpec(c gospec.Context) {
+	l := glog.MakeLogger(4)
+	l.SetOutput(nil)
+
+	c.Specify("Recent returns the newest entries oldest first", func() {
+		l.Infof("a")
+		l.Infof("b")
+		l.Infof("c")
+		c.Expect(messages(l.Recent(glog.Debug, 10)), ContainsInOrder, []string{"a", "b", "c"})
+		c.Expect(messages(l.Recent(glog.Debug, 2)), ContainsInOrder, []string{"b", "c"})
+	})
+
+	c.Specify("Old entries are dropped once the logger is full", func() {
+		for _, m := range []string{"a", "b", "c", "d", "e", "f"} {
+			l.Infof("%s", m)
+		}
+		c.Expect(messages(l.Recent(glog.Debug, 10)), ContainsInOrder, []string{"c", "d", "e", "f"})
+	})
+
+	c.Specify("Recent skips entries below the level asked for", func() {
+		l.Warningf("a")
+		l.Infof("b")
+		l.Errorf("c")
+		l.Infof("d")
+		l.Infof("e")
+		c.Expect(len(l.Recent(glog.Warning, 10)), Equals, 1)
+		c.Expect(messages(l.Recent(glog.Warning, 10)), ContainsInOrder, []string{"c"})
+		c.Expect(messages(l.Recent(glog.Info, 10)), ContainsInOrder, []string{"b", "c", "d", "e"})
+	})
+
+	c.Specify("Clear forgets everything", func() {
+		l.Infof("a")
+		l.Clear()
+		c.Expect(len(l.Recent(glog.Debug, 10)), Equals, 0)
+		l.Infof("b")
+		c.Expect(messages(l.Recent(glog.Debug, 10)), ContainsInOrder, []string{"b"})
+	})
+}
@@ -0,0 +1,61 @@
+// Package overlay draws recent warnings and errors from a glog.Logger over
+// the top of the game, so that problems are noticed while playing instead of
+// scrolling by in a terminal that nobody is looking at.
+package overlay
+
+import (
+	"github.com/runningwild/glop/glog"
+	"github.com/runningwild/glop/text"
+	"time"
+)
+
+type Overlay struct {
+	logger *glog.Logger
+	dict   *text.Dictionary
+
+	// Entries below Level aren't shown, and at most Lines entries are shown,
+	// each for Duration after it was logged.
+	Level    glog.Level
+	Lines    int
+	Duration time.Duration
+}
+
+// Makes an overlay that shows warnings and errors from logger, drawn with
+// dict, for ten seconds each.
+func Make(logger *glog.Logger, dict *text.Dictionary) *Overlay {
+	return &Overlay{
+		logger:   logger,
+		dict:     dict,
+		Level:    glog.Warning,
+		Lines:    10,
+		Duration: 10 * time.Second,
+	}
+}
+
+// Returns the entries that would be drawn right now, oldest first.
+func (o *Overlay) Entries() []glog.Entry {
+	entries := o.logger.Recent(o.Level, o.Lines)
+	now := time.Now()
+	for len(entries) > 0 && now.Sub(entries[0].Time) > o.Duration {
+		entries = entries[1:]
+	}
+	return entries
+}
+
+// Draws the entries with the newest at the bottom, starting at x, y in screen
+// coordinates with lines that are height tall.  Errors are red and
+// everything else is yellow.  Must be called on the render thread.
+func (o *Overlay) Draw(x, y, height float64) {
+	entries := o.Entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Level >= glog.Error {
+			o.dict.SetFontColor(1, 0.3, 0.3)
+		} else {
+			o.dict.SetFontColor(1, 1, 0.4)
+		}
+		o.dict.RenderString(e.String(), x, y, height)
+		y += height
+	}
+	o.dict.SetFontColor(1, 1, 1)
+}
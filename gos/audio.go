@@ -0,0 +1,60 @@
+package gos
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/sound"
+	"sync"
+	"unsafe"
+)
+
+var audio struct {
+	sync.Mutex
+	open bool
+}
+
+// An audioOutput plays through the backend's audio device.  Only one can be
+// open at a time.
+type audioOutput struct {
+	rate int
+}
+
+// Opens the default audio device for playing through a sound.Mixer at about
+// rate samples per second, see SampleRate() on the returned Output for the
+// rate it actually plays at.  Only one device can be open at a time.  Unlike
+// most of gos this doesn't need to be called on the render thread.
+func OpenAudio(rate int) (sound.Output, error) {
+	audio.Lock()
+	defer audio.Unlock()
+	if audio.open {
+		return nil, fmt.Errorf("Audio is already open.")
+	}
+	actual := openAudio(rate)
+	if actual <= 0 {
+		return nil, fmt.Errorf("Unable to open an audio device.")
+	}
+	audio.open = true
+	return &audioOutput{rate: actual}, nil
+}
+
+func (ao *audioOutput) SampleRate() int {
+	return ao.rate
+}
+
+func (ao *audioOutput) Write(samples []float32) error {
+	if len(samples) < 2 {
+		return nil
+	}
+	if !writeAudio(unsafe.Pointer(&samples[0]), len(samples)/2) {
+		return fmt.Errorf("Audio device failed.")
+	}
+	return nil
+}
+
+func (ao *audioOutput) Close() {
+	audio.Lock()
+	defer audio.Unlock()
+	if audio.open {
+		closeAudio()
+		audio.open = false
+	}
+}
@@ -1,12 +1,14 @@
 package gos
 
-// #cgo LDFLAGS: -Ldarwin/lib -lglop -framework Cocoa -framework IOKit -framework OpenGL -mmacosx-version-min=10.5
+// #cgo LDFLAGS: -Ldarwin/lib -lglop -framework Cocoa -framework IOKit -framework OpenGL -framework AudioToolbox -mmacosx-version-min=10.5
 // #include "darwin/include/glop.h"
 import "C"
 
 import (
+	"fmt"
 	"github.com/runningwild/glop/gin"
 	"github.com/runningwild/glop/system"
+	"image"
 	"sync"
 	"unsafe"
 )
@@ -41,12 +43,85 @@ func (osx *osxSystemObject) Quit() {
 	C.Quit()
 }
 
-func (osx *osxSystemObject) CreateWindow(x, y, width, height int) {
+func (osx *osxSystemObject) GetDisplays() []system.Display {
+	var first_display *C.Display
+	var length C.int
+	globalLock.Lock()
+	C.GetDisplays((*unsafe.Pointer)(unsafe.Pointer(&first_display)), &length)
+	c_displays := (*[100]C.Display)(unsafe.Pointer(first_display))[:length]
+	displays := make([]system.Display, length)
+	for i, d := range c_displays {
+		displays[i] = system.Display{
+			X:           int(d.x),
+			Y:           int(d.y),
+			Dx:          int(d.dx),
+			Dy:          int(d.dy),
+			DPI:         float64(d.dpi),
+			RefreshRate: float64(d.refresh_rate),
+			Primary:     d.primary != 0,
+		}
+	}
+	globalLock.Unlock()
+	return displays
+}
+
+func (osx *osxSystemObject) CreateWindowEx(opts system.WindowOpts) {
+	var resizable C.int
+	if opts.Resizable {
+		resizable = 1
+	}
 	globalLock.Lock()
-	defer globalLock.Unlock()
 	w := (*unsafe.Pointer)(unsafe.Pointer(&osx.window))
 	c := (*unsafe.Pointer)(unsafe.Pointer(&osx.context))
-	C.CreateWindow(w, c, C.int(x), C.int(y), C.int(width), C.int(height))
+	C.CreateWindow(w, c, C.int(opts.X), C.int(opts.Y), C.int(opts.Dx), C.int(opts.Dy), resizable, C.int(opts.MSAA))
+	if opts.Icon != nil {
+		pix, dx, dy := iconPixels(opts.Icon)
+		if len(pix) > 0 {
+			C.SetIcon(unsafe.Pointer(&pix[0]), C.int(dx), C.int(dy))
+		}
+	}
+	globalLock.Unlock()
+	// The window was made with its size in points, this makes it the size
+	// that was asked for in pixels.
+	if scale := osx.GetBackingScale(); scale != 1 {
+		osx.Resize(opts.Dx, opts.Dy)
+	}
+	osx.SetTitle(opts.Title)
+	if opts.Fullscreen {
+		osx.SetFullscreen(true)
+	}
+}
+
+func (osx *osxSystemObject) SetTitle(title string) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	c_title := cString(title)
+	C.SetTitle(unsafe.Pointer(osx.window), unsafe.Pointer(&c_title[0]))
+}
+
+// Fullscreen windows require osx 10.7 or later, on earlier versions this does
+// nothing.
+func (osx *osxSystemObject) SetFullscreen(fullscreen bool) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	var _fullscreen C.int
+	if fullscreen {
+		_fullscreen = 1
+	}
+	C.SetFullscreen(unsafe.Pointer(osx.window), _fullscreen)
+}
+
+func (osx *osxSystemObject) Resize(width, height int) {
+	scale := osx.GetBackingScale()
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	C.ResizeWindow(unsafe.Pointer(osx.window), C.int(float64(width)/scale+0.5), C.int(float64(height)/scale+0.5))
+}
+
+func (osx *osxSystemObject) Minimize() {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	C.MinimizeWindow(unsafe.Pointer(osx.window))
 }
 
 func (osx *osxSystemObject) SwapBuffers() {
@@ -78,10 +153,89 @@ func (osx *osxSystemObject) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIn
 	return ret
 }
 
-// TODO: Make sure that events are given in sorted order (by timestamp)
-// TODO: Adjust timestamp on events so that the oldest timestamp is newer than the
-//       newest timestemp from the events from the previous call to GetInputEvents
-//       Actually that should be in system
+func (osx *osxSystemObject) GetTextEvents() []gin.TextEvent {
+	var first_event *C.TextEvent
+	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
+	var length C.int
+
+	globalLock.Lock()
+	C.GetTextEvents(cp, &length)
+	globalLock.Unlock()
+
+	c_events := (*[1000]C.TextEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.TextEvent, length)
+	for i := range c_events {
+		events[i] = gin.TextEvent{
+			Rune:      rune(c_events[i].rune),
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
+func (osx *osxSystemObject) GetClipboardString() string {
+	var text *C.char
+	var length C.int
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	C.GetClipboard((*unsafe.Pointer)(unsafe.Pointer(&text)), &length)
+	return C.GoStringN(text, length)
+}
+
+func (osx *osxSystemObject) SetClipboardString(s string) {
+	text := cString(s)
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	C.SetClipboard(unsafe.Pointer(&text[0]))
+}
+
+func (osx *osxSystemObject) GetWindowEvents() []gin.WindowEvent {
+	var first_event *C.WindowEvent
+	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
+	var length C.int
+
+	globalLock.Lock()
+	C.GetWindowEvents(cp, &length)
+	globalLock.Unlock()
+
+	c_events := (*[1000]C.WindowEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.WindowEvent, length)
+	for i := range c_events {
+		events[i] = gin.WindowEvent{
+			Type:      gin.WindowEventType(c_events[i]._type),
+			Dx:        int(c_events[i].dx),
+			Dy:        int(c_events[i].dy),
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
+func (osx *osxSystemObject) GetFileDropEvents() []gin.FileDropEvent {
+	var first_event *C.FileDropEvent
+	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
+	var length C.int
+
+	globalLock.Lock()
+	C.GetFileDropEvents(cp, &length)
+	globalLock.Unlock()
+
+	c_events := (*[1000]C.FileDropEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.FileDropEvent, length)
+	for i := range c_events {
+		x, y := osx.toWindowPixels(int(c_events[i].x), int(c_events[i].y))
+		events[i] = gin.FileDropEvent{
+			Paths:     splitPaths(C.GoBytes(unsafe.Pointer(c_events[i].paths), c_events[i].length)),
+			X:         x,
+			Y:         y,
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
+// Events don't need to be sorted, and may have timestamps from before the
+// previous horizon, gin.Input.Think() takes care of both.
 func (osx *osxSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	var first_event *C.KeyEvent
 	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
@@ -127,8 +281,7 @@ func (osx *osxSystemObject) GetCursorPos() (int, int) {
 	var x, y C.int
 	C.GetMousePos(&x, &y)
 	globalLock.Unlock()
-	wx, wy, _, dy := osx.GetWindowDims()
-	return int(x) - wx, dy + wy - int(y)
+	return osx.toWindowPixels(int(x), int(y))
 }
 
 func (osx *osxSystemObject) HideCursor(hide bool) {
@@ -143,24 +296,75 @@ func (osx *osxSystemObject) HideCursor(hide bool) {
 	}
 }
 
-func (osx *osxSystemObject) GetWindowDims() (int, int, int, int) {
+func (osx *osxSystemObject) SetCursorShape(shape system.CursorShape) {
 	globalLock.Lock()
 	defer globalLock.Unlock()
-	var x, y, dx, dy C.int
-	C.GetWindowDims(unsafe.Pointer(osx.window), &x, &y, &dx, &dy)
-	return int(x), int(y), int(dx), int(dy)
+	C.SetCursorShape(C.int(shape))
 }
 
-func (osx *osxSystemObject) EnableVSync(enable bool) {
+func (osx *osxSystemObject) SetCustomCursor(im image.Image, hot_x, hot_y int) {
+	pix, dx, dy := iconPixels(im)
+	if len(pix) == 0 {
+		return
+	}
 	globalLock.Lock()
 	defer globalLock.Unlock()
-	var _enable C.int
-	if enable {
-		_enable = 1
+	C.SetCustomCursor(unsafe.Pointer(&pix[0]), C.int(dx), C.int(dy), C.int(hot_x), C.int(hot_y))
+}
+
+// Dissociating the mouse from the cursor keeps the cursor where it is, while
+// mouse moved events still report how far the mouse moved.
+func (osx *osxSystemObject) SetRelativeMouseMode(relative bool) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	var _relative C.int
+	if relative {
+		_relative = 1
 	}
-	C.EnableVSync(unsafe.Pointer(osx.context), _enable)
+	C.SetRelativeMouseMode(_relative)
+}
+
+// The position of the window is in points, like the bounds of the displays,
+// but its size is in pixels, so that it is the size of the framebuffer on
+// Retina displays the same as it is on other platforms.
+func (osx *osxSystemObject) GetWindowDims() (int, int, int, int) {
+	x, y, dx, dy := osx.windowPoints()
+	scale := osx.GetBackingScale()
+	return x, y, int(float64(dx)*scale + 0.5), int(float64(dy)*scale + 0.5)
 }
 
+// Returns the position and size of the window in points.
+func (osx *osxSystemObject) windowPoints() (x, y, dx, dy int) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	var _x, _y, _dx, _dy C.int
+	C.GetWindowDims(unsafe.Pointer(osx.window), &_x, &_y, &_dx, &_dy)
+	return int(_x), int(_y), int(_dx), int(_dy)
+}
+
+// Converts a point on the desktop to pixels in the window.
+func (osx *osxSystemObject) toWindowPixels(x, y int) (int, int) {
+	wx, wy, _, dy := osx.windowPoints()
+	scale := osx.GetBackingScale()
+	return int(float64(x-wx) * scale), int(float64(dy+wy-y) * scale)
+}
+
+func (osx *osxSystemObject) GetBackingScale() float64 {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	return float64(C.GetBackingScale(unsafe.Pointer(osx.window)))
+}
+
+func (osx *osxSystemObject) SetSwapInterval(n int) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	C.SetSwapInterval(unsafe.Pointer(osx.context), C.int(n))
+}
+
+// TODO: Controllers are read through IOHIDManager, rumbling them needs the
+// ForceFeedback framework.
+func (osx *osxSystemObject) SetRumble(device gin.DeviceId, low, high float64, duration_ms int) {}
+
 func (osx *osxSystemObject) HasFocus() bool {
 	globalLock.Lock()
 	defer globalLock.Unlock()
@@ -168,3 +372,45 @@ func (osx *osxSystemObject) HasFocus() bool {
 	C.HasFocus(&has_focus)
 	return has_focus == 1
 }
+
+// The audio functions don't touch anything that globalLock protects, and
+// WriteAudio() blocks, so they don't take it.
+func openAudio(rate int) int {
+	return int(C.OpenAudio(C.int(rate)))
+}
+
+func writeAudio(samples unsafe.Pointer, frames int) bool {
+	return C.WriteAudio(samples, C.int(frames)) != 0
+}
+
+func closeAudio() {
+	C.CloseAudio()
+}
+
+type osxSharedContext struct {
+	context unsafe.Pointer // NSOpenGLContext*
+}
+
+func (osx *osxSystemObject) CreateSharedContext() (system.SharedContext, error) {
+	if osx.context == 0 {
+		return nil, fmt.Errorf("There is no window to share a GL context with.")
+	}
+	context := C.CreateSharedContext(unsafe.Pointer(osx.context))
+	if context == nil {
+		return nil, fmt.Errorf("Unable to create a shared GL context.")
+	}
+	return &osxSharedContext{context}, nil
+}
+
+func (c *osxSharedContext) MakeCurrent() error {
+	C.MakeContextCurrent(c.context)
+	return nil
+}
+
+func (c *osxSharedContext) Release() {
+	C.MakeContextCurrent(nil)
+}
+
+func (c *osxSharedContext) Destroy() {
+	C.DestroyContext(c.context)
+}
@@ -1,6 +1,8 @@
+// +build !glop_purego
+
 package gos
 
-// #cgo LDFLAGS: -Llinux/lib -lglop -lX11 -lGL
+// #cgo LDFLAGS: -Llinux/lib -lglop -lX11 -lXi -lXrandr -lXcursor -lGL -lasound
 // #include "linux/include/glop.h"
 import "C"
 
@@ -8,11 +10,8 @@ import (
 	"fmt"
 	"github.com/runningwild/glop/gin"
 	"github.com/runningwild/glop/system"
-	"os"
+	"image"
 	"sort"
-	"strings"
-	"sync"
-	"time"
 	"unsafe"
 )
 
@@ -20,176 +19,101 @@ type linuxSystemObject struct {
 	horizon int64
 }
 
-var (
-	linux_system_object linuxSystemObject
-	jsCollect           chan jsInput
-)
+var linux_system_object linuxSystemObject
 
 // Call after runtime.LockOSThread(), *NOT* in an init function
 func (linux *linuxSystemObject) Startup() {
 	C.GlopInit()
-	jsCollect = make(chan jsInput, 100)
-	go trackJoysticks(jsCollect)
+	startJoysticks()
 }
 
 func GetSystemInterface() system.Os {
 	return &linux_system_object
 }
 
+// Run processes window events as they arrive until Quit() is called.
 func (linux *linuxSystemObject) Run() {
-	panic("Not implemented on linux")
+	C.GlopRun()
 }
 
+// Quit makes Run() return, it may be called from any goroutine.
 func (linux *linuxSystemObject) Quit() {
-	panic("Not implemented on linux")
+	C.GlopQuit()
 }
 
-func (linux *linuxSystemObject) CreateWindow(x, y, width, height int) {
-	C.GlopCreateWindow(unsafe.Pointer(&(([]byte("linux window"))[0])), C.int(x), C.int(y), C.int(width), C.int(height))
+func (linux *linuxSystemObject) GetDisplays() []system.Display {
+	var first_display *C.GlopDisplay
+	var length C.int
+	C.GlopGetDisplays((*unsafe.Pointer)(unsafe.Pointer(&first_display)), &length)
+	c_displays := (*[100]C.GlopDisplay)(unsafe.Pointer(first_display))[:length]
+	displays := make([]system.Display, length)
+	for i, d := range c_displays {
+		displays[i] = system.Display{
+			X:           int(d.x),
+			Y:           int(d.y),
+			Dx:          int(d.dx),
+			Dy:          int(d.dy),
+			DPI:         float64(d.dpi),
+			RefreshRate: float64(d.refresh_rate),
+			Primary:     d.primary != 0,
+		}
+	}
+	return displays
 }
 
-func (linux *linuxSystemObject) SwapBuffers() {
-	C.GlopSwapBuffers()
+func (linux *linuxSystemObject) CreateWindowEx(opts system.WindowOpts) {
+	title := cString(opts.Title)
+	var resizable C.int
+	if opts.Resizable {
+		resizable = 1
+	}
+	C.GlopCreateWindow(unsafe.Pointer(&title[0]), C.int(opts.X), C.int(opts.Y), C.int(opts.Dx), C.int(opts.Dy), resizable, C.int(opts.MSAA))
+	if opts.Icon != nil {
+		pix, dx, dy := iconPixels(opts.Icon)
+		if len(pix) > 0 {
+			C.GlopSetIcon(unsafe.Pointer(&pix[0]), C.int(dx), C.int(dy))
+		}
+	}
+	if opts.Fullscreen {
+		linux.SetFullscreen(true)
+	}
 }
 
-func (linux *linuxSystemObject) Think() {
-	C.GlopThink()
+func (linux *linuxSystemObject) SetTitle(title string) {
+	c_title := cString(title)
+	C.GlopSetTitle(unsafe.Pointer(&c_title[0]))
 }
 
-func (linux *linuxSystemObject) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
-	return nil
+func (linux *linuxSystemObject) SetFullscreen(fullscreen bool) {
+	var _fullscreen C.int
+	if fullscreen {
+		_fullscreen = 1
+	}
+	C.GlopSetFullscreen(_fullscreen)
 }
 
-type jsInput struct {
-	TimestampMs uint32
-	Value       int16
-	Type        uint8
-
-	// This is read from an 8-bit value, but glop supports more values so it
-	// gets read into a 32 bit int.
-	Key uint32
-
-	// Since we translate axis values into floats we want this value here for that.
-	FValue float64
-
-	Index int
+func (linux *linuxSystemObject) Resize(width, height int) {
+	C.GlopResizeWindow(C.int(width), C.int(height))
 }
 
-const kControllerButton0 = 500
-const kControllerAxis0Positive = 70000
-const kControllerAxis0Negative = 80000
-const kControllerHatSwitchUp = 90000
-const kControllerHatSwitchUpRight = 90001
-const kControllerHatSwitchRight = 90002
-const kControllerHatSwitchDownRight = 90003
-const kControllerHatSwitchDown = 90004
-const kControllerHatSwitchDownLeft = 90005
-const kControllerHatSwitchLeft = 90006
-const kControllerHatSwitchUpLeft = 90007
-
-func parsejsInput(b []byte) (jsInput, error) {
-	var js jsInput
-	if len(b) != 8 {
-		return js, fmt.Errorf("Expected 8 bytes, got %d.", len(b))
-	}
-	js.TimestampMs = uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
-	js.Value = int16(b[4]) | int16(b[5])<<8
-	js.Type = b[6]
-	js.Key = uint32(b[7])
-	return js, nil
+func (linux *linuxSystemObject) Minimize() {
+	C.GlopMinimizeWindow()
 }
 
-func pollJoysticks(name string, index int, jsCollect chan<- jsInput, onDeath func(string)) {
-	defer onDeath(name)
-
-	f, err := os.Open("/dev/input/by-path/" + name)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	buf := make([]byte, 1024)
-	for {
-		n, err := f.Read(buf)
-		if err != nil {
-			return
-		}
-		tmp := buf[0:n]
-		for len(tmp) >= 8 {
-			js, err := parsejsInput(tmp[0:8])
-			if err != nil {
-				continue
-			}
-			tmp = tmp[8:]
-			switch js.Type {
-			case 1: // A regular button
-				js.Key += kControllerButton0
-				js.FValue = float64(js.Value)
-
-			case 2: // An Axis value
-				if js.Value < 0 {
-					js.Key += kControllerAxis0Negative
-					js.FValue = float64(-js.Value) / 32768
-				} else {
-					js.Key += kControllerAxis0Positive
-					js.FValue = float64(js.Value) / 32768
-				}
-
-			default:
-				continue
-			}
-			js.Index = index
-			jsCollect <- js
-		}
-	}
+func (linux *linuxSystemObject) SwapBuffers() {
+	C.GlopSwapBuffers()
 }
 
-func trackJoysticks(jsCollect chan<- jsInput) error {
-	defer close(jsCollect)
-	var jsMutex sync.Mutex
-	active := make(map[string]bool)
-	onDeath := func(name string) {
-		jsMutex.Lock()
-		delete(active, name)
-		jsMutex.Unlock()
-	}
-	index := 0
-	for {
-		f, err := os.Open("/dev/input/by-path")
-		if err != nil {
-			return err
-		}
-		names, err := f.Readdirnames(0)
-		f.Close()
-		if err != nil {
-			return err
-		}
-		for _, name := range names {
-			if strings.Contains(name, "event") {
-				continue
-			}
-			jsMutex.Lock()
-			if !active[name] {
-				active[name] = true
-				index++
-				go pollJoysticks(name, index, jsCollect, onDeath)
-			}
-			jsMutex.Unlock()
-		}
-		time.Sleep(time.Second * 5)
-	}
-	return nil
+func (linux *linuxSystemObject) Think() {
+	C.GlopThink()
 }
 
-type osEventSlice []gin.OsEvent
-
-func (oes osEventSlice) Len() int           { return len(oes) }
-func (oes osEventSlice) Swap(i, j int)      { oes[i], oes[j] = oes[j], oes[i] }
-func (oes osEventSlice) Less(i, j int) bool { return oes[i].Timestamp < oes[j].Timestamp }
+func (linux *linuxSystemObject) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
+	return systemDevices(activeJoysticks())
+}
 
-// TODO: Make sure that events are given in sorted order (by timestamp)
-// TODO: Adjust timestamp on events so that the oldest timestamp is newer than the
-//       newest timestemp from the events from the previous call to GetInputEvents
-//       Actually that should be in system
+// Events don't need to be sorted, and may have timestamps from before the
+// previous horizon, gin.Input.Think() takes care of both.
 func (linux *linuxSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	var first_event *C.GlopKeyEvent
 	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
@@ -200,43 +124,118 @@ func (linux *linuxSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	c_events := (*[1000]C.GlopKeyEvent)(unsafe.Pointer(first_event))[:length]
 	events := make([]gin.OsEvent, length)
 	for i := range c_events {
+		index := gin.KeyIndex(c_events[i].index)
+		device := gin.DeviceId{
+			Index: systemDeviceIndex,
+			Type:  deviceTypeOf(index),
+		}
+		if device.Type == gin.DeviceTypeTouch {
+			device.Index = gin.DeviceIndex(c_events[i].device)
+		}
 		events[i] = gin.OsEvent{
 			KeyId: gin.KeyId{
-				Device: gin.DeviceId{
-					Index: 5,
-					Type:  gin.DeviceTypeKeyboard,
-				},
-				Index: gin.KeyIndex(c_events[i].index),
+				Device: device,
+				Index:  index,
 			},
 			Press_amt: float64(c_events[i].press_amt),
 			Timestamp: int64(c_events[i].timestamp),
 		}
-	}
-	done := false
-	for !done {
-		select {
-		case event := <-jsCollect:
-			events = append(events, gin.OsEvent{
-				KeyId: gin.KeyId{
-					Device: gin.DeviceId{
-						Index: gin.DeviceIndex(event.Index + 1),
-						Type:  gin.DeviceTypeController,
-					},
-					Index: gin.KeyIndex(event.Key),
-				},
-				Press_amt: event.FValue,
-				Timestamp: int64(event.TimestampMs),
-			})
-		default:
-			done = true
+		if device.Type == gin.DeviceTypeMouse || device.Type == gin.DeviceTypeTouch {
+			events[i].Has_cursor = true
+			events[i].Cursor_x, events[i].Cursor_y = linux.rawCursorToWindowCoords(int(c_events[i].cursor_x), int(c_events[i].cursor_y))
 		}
 	}
+	events = append(events, joystickEvents(linux.horizon)...)
 	sort.Sort(osEventSlice(events))
 	return events, linux.horizon
 	// return nil, 0
 }
 
+func (linux *linuxSystemObject) GetTextEvents() []gin.TextEvent {
+	var first_event *C.GlopTextEvent
+	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
+	var length C.int
+	C.GlopGetTextEvents(cp, unsafe.Pointer(&length))
+	c_events := (*[1000]C.GlopTextEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.TextEvent, length)
+	for i := range c_events {
+		events[i] = gin.TextEvent{
+			Rune:      rune(c_events[i].rune),
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
+func (linux *linuxSystemObject) GetWindowEvents() []gin.WindowEvent {
+	var first_event *C.GlopWindowEvent
+	var length C.int
+	C.GlopGetWindowEvents((*unsafe.Pointer)(unsafe.Pointer(&first_event)), &length)
+	c_events := (*[1000]C.GlopWindowEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.WindowEvent, length)
+	for i := range c_events {
+		events[i] = gin.WindowEvent{
+			Type:      gin.WindowEventType(c_events[i]._type),
+			Dx:        int(c_events[i].dx),
+			Dy:        int(c_events[i].dy),
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
+func (linux *linuxSystemObject) GetFileDropEvents() []gin.FileDropEvent {
+	var first_event *C.GlopFileDropEvent
+	var length C.int
+	C.GlopGetFileDropEvents((*unsafe.Pointer)(unsafe.Pointer(&first_event)), &length)
+	c_events := (*[1000]C.GlopFileDropEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.FileDropEvent, length)
+	for i := range c_events {
+		events[i].Paths = splitPaths(C.GoBytes(unsafe.Pointer(c_events[i].paths), c_events[i].length))
+		events[i].X, events[i].Y = linux.rawCursorToWindowCoords(int(c_events[i].x), int(c_events[i].y))
+		events[i].Timestamp = int64(c_events[i].timestamp)
+	}
+	return events
+}
+
+func (linux *linuxSystemObject) GetClipboardString() string {
+	var text *C.char
+	var length C.int
+	C.GlopGetClipboard((*unsafe.Pointer)(unsafe.Pointer(&text)), &length)
+	return C.GoStringN(text, length)
+}
+
+func (linux *linuxSystemObject) SetClipboardString(s string) {
+	text := cString(s)
+	C.GlopSetClipboard(unsafe.Pointer(&text[0]))
+}
+
 func (linux *linuxSystemObject) HideCursor(hide bool) {
+	var _hide C.int
+	if hide {
+		_hide = 1
+	}
+	C.GlopHideCursor(_hide)
+}
+
+func (linux *linuxSystemObject) SetRelativeMouseMode(relative bool) {
+	var _relative C.int
+	if relative {
+		_relative = 1
+	}
+	C.GlopSetRelativeMouseMode(_relative)
+}
+
+func (linux *linuxSystemObject) SetCursorShape(shape system.CursorShape) {
+	C.GlopSetCursorShape(C.int(shape))
+}
+
+func (linux *linuxSystemObject) SetCustomCursor(im image.Image, hot_x, hot_y int) {
+	pix, dx, dy := iconPixels(im)
+	if len(pix) == 0 {
+		return
+	}
+	C.GlopSetCustomCursor(unsafe.Pointer(&pix[0]), C.int(dx), C.int(dy), C.int(hot_x), C.int(hot_y))
 }
 
 func (linux *linuxSystemObject) rawCursorToWindowCoords(x, y int) (int, int) {
@@ -256,15 +255,59 @@ func (linux *linuxSystemObject) GetWindowDims() (int, int, int, int) {
 	return int(x), int(y), int(dx), int(dy)
 }
 
-func (linux *linuxSystemObject) EnableVSync(enable bool) {
-	var _enable C.int
-	if enable {
-		_enable = 1
-	}
-	C.GlopEnableVSync(_enable)
+func (linux *linuxSystemObject) SetSwapInterval(n int) {
+	C.GlopSetSwapInterval(C.int(n))
+}
+
+// Windows are always the same size in pixels as they are in the coordinates
+// of the display.
+func (linux *linuxSystemObject) GetBackingScale() float64 {
+	return 1
+}
+
+func (linux *linuxSystemObject) SetRumble(device gin.DeviceId, low, high float64, duration_ms int) {
+	setJoystickRumble(device, low, high, duration_ms)
 }
 
 func (linux *linuxSystemObject) HasFocus() bool {
-	// TODO: Implement me!
-	return true
+	return C.GlopHasFocus() != 0
+}
+
+func openAudio(rate int) int {
+	return int(C.GlopOpenAudio(C.int(rate)))
+}
+
+func writeAudio(samples unsafe.Pointer, frames int) bool {
+	return C.GlopWriteAudio(samples, C.int(frames)) != 0
+}
+
+func closeAudio() {
+	C.GlopCloseAudio()
+}
+
+type linuxSharedContext struct {
+	context unsafe.Pointer
+}
+
+func (linux *linuxSystemObject) CreateSharedContext() (system.SharedContext, error) {
+	context := C.GlopCreateSharedContext()
+	if context == nil {
+		return nil, fmt.Errorf("Unable to create a shared GL context.")
+	}
+	return &linuxSharedContext{context}, nil
+}
+
+func (c *linuxSharedContext) MakeCurrent() error {
+	if C.GlopMakeContextCurrent(c.context) == 0 {
+		return fmt.Errorf("Unable to make the shared GL context current.")
+	}
+	return nil
+}
+
+func (c *linuxSharedContext) Release() {
+	C.GlopMakeContextCurrent(nil)
+}
+
+func (c *linuxSharedContext) Destroy() {
+	C.GlopDestroyContext(c.context)
 }
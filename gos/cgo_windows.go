@@ -5,14 +5,27 @@ package gos
 import "C"
 
 import (
+	"fmt"
 	"github.com/runningwild/glop/gin"
 	"github.com/runningwild/glop/system"
+	"image"
+	"sync"
+	"time"
 	"unsafe"
 )
 
 type win32SystemObject struct {
 	horizon int64
 	window  uintptr
+
+	// The last time that we checked for joysticks being connected or disconnected.
+	last_joystick_refresh time.Time
+
+	// XInput controllers rumble until they're told to stop, so each one that
+	// is rumbling for a set duration has a timer here that stops it.  Guarded
+	// by rumble_mutex, since the timers run on their own goroutines.
+	rumble_mutex sync.Mutex
+	rumble_stops map[gin.DeviceIndex]*time.Timer
 }
 
 var (
@@ -29,7 +42,16 @@ func GetSystemInterface() system.Os {
 }
 
 func (win32 *win32SystemObject) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
-	return nil
+	if win32.window == 0 {
+		return nil
+	}
+	// Joysticks are numbered from 1 in the order DirectInput enumerates them.
+	var controllers []gin.DeviceIndex
+	num := int(C.GlopGetNumJoysticks(unsafe.Pointer(win32.window)))
+	for i := 1; i <= num; i++ {
+		controllers = append(controllers, gin.DeviceIndex(i))
+	}
+	return systemDevices(controllers)
 }
 
 func (win32 *win32SystemObject) Run() {
@@ -40,12 +62,82 @@ func (win32 *win32SystemObject) Quit() {
 	//  C.Quit()
 }
 
-func (win32 *win32SystemObject) CreateWindow(x, y, width, height int) {
-	title := []byte("Glop")
-	title = append(title, 0)
+func (win32 *win32SystemObject) GetDisplays() []system.Display {
+	var first_display *C.GlopDisplay
+	var length C.int
+	C.GlopGetDisplays((*unsafe.Pointer)(unsafe.Pointer(&first_display)), &length)
+	c_displays := (*[100]C.GlopDisplay)(unsafe.Pointer(first_display))[:length]
+	displays := make([]system.Display, length)
+	for i, d := range c_displays {
+		displays[i] = system.Display{
+			X:           int(d.x),
+			Y:           int(d.y),
+			Dx:          int(d.dx),
+			Dy:          int(d.dy),
+			DPI:         float64(d.dpi),
+			RefreshRate: float64(d.refresh_rate),
+			Primary:     d.primary != 0,
+		}
+	}
+	return displays
+}
+
+// opts.MSAA is ignored, choosing a multisampled pixel format requires
+// WGL_ARB_pixel_format, which can't be used until there is already a context.
+func (win32 *win32SystemObject) CreateWindowEx(opts system.WindowOpts) {
+	title := cString(opts.Title)
+	var resizable C.int
+	if opts.Resizable {
+		resizable = 1
+	}
 	win32.window = uintptr(unsafe.Pointer(C.GlopCreateWindow(
 		unsafe.Pointer(&title[0]),
-		C.int(x), C.int(y), C.int(width), C.int(height), 0, 8, 0)))
+		C.int(opts.X), C.int(opts.Y), C.int(opts.Dx), C.int(opts.Dy), 0, 8, resizable)))
+	if win32.window == 0 {
+		return
+	}
+	if opts.Icon != nil {
+		pix, dx, dy := iconPixels(opts.Icon)
+		if len(pix) > 0 {
+			C.GlopSetIcon(unsafe.Pointer(win32.window), unsafe.Pointer(&pix[0]), C.int(dx), C.int(dy))
+		}
+	}
+	if opts.Fullscreen {
+		win32.SetFullscreen(true)
+	}
+}
+
+func (win32 *win32SystemObject) SetTitle(title string) {
+	if win32.window == 0 {
+		return
+	}
+	c_title := cString(title)
+	C.GlopSetTitle(unsafe.Pointer(win32.window), unsafe.Pointer(&c_title[0]))
+}
+
+func (win32 *win32SystemObject) SetFullscreen(fullscreen bool) {
+	if win32.window == 0 {
+		return
+	}
+	var _fullscreen C.int
+	if fullscreen {
+		_fullscreen = 1
+	}
+	C.GlopSetFullScreen(unsafe.Pointer(win32.window), _fullscreen)
+}
+
+func (win32 *win32SystemObject) Resize(width, height int) {
+	if win32.window == 0 {
+		return
+	}
+	C.GlopSetWindowSize(unsafe.Pointer(win32.window), C.int(width), C.int(height))
+}
+
+func (win32 *win32SystemObject) Minimize() {
+	if win32.window == 0 {
+		return
+	}
+	C.GlopMinimizeWindow(unsafe.Pointer(win32.window))
 }
 
 func (win32 *win32SystemObject) SwapBuffers() {
@@ -54,12 +146,16 @@ func (win32 *win32SystemObject) SwapBuffers() {
 
 func (win32 *win32SystemObject) Think() {
 	C.GlopThink()
+	// DirectInput doesn't tell us when joysticks are connected or disconnected,
+	// so we have to go looking for them every so often.
+	if win32.window != 0 && time.Since(win32.last_joystick_refresh) > 5*time.Second {
+		C.GlopRefreshJoysticks(unsafe.Pointer(win32.window))
+		win32.last_joystick_refresh = time.Now()
+	}
 }
 
-// TODO: Make sure that events are given in sorted order (by timestamp)
-// TODO: Adjust timestamp on events so that the oldest timestamp is newer than the
-//       newest timestemp from the events from the previous call to GetInputEvents
-//       Actually that should be in system
+// Events don't need to be sorted, and may have timestamps from before the
+// previous horizon, gin.Input.Think() takes care of both.
 func (win32 *win32SystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	var first_event *C.GlopKeyEvent
 	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
@@ -70,22 +166,101 @@ func (win32 *win32SystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	c_events := (*[10000]C.GlopKeyEvent)(unsafe.Pointer(first_event))[:length]
 	events := make([]gin.OsEvent, length)
 	for i := range c_events {
-		// wx, wy := win32.rawCursorToWindowCoords(int(c_events[i].cursor_x), int(c_events[i].cursor_y))
+		index := gin.KeyIndex(c_events[i].index)
+		device := gin.DeviceId{
+			Index: systemDeviceIndex,
+			Type:  deviceTypeOf(index),
+		}
+		if device.Type == gin.DeviceTypeController || device.Type == gin.DeviceTypeTouch {
+			device.Index = gin.DeviceIndex(c_events[i].device)
+		}
 		events[i] = gin.OsEvent{
 			KeyId: gin.KeyId{
-				Device: gin.DeviceId{
-					Index: 5,
-					Type:  gin.DeviceTypeKeyboard,
-				},
-				Index: gin.KeyIndex(c_events[i].index),
+				Device: device,
+				Index:  index,
 			},
 			Press_amt: float64(c_events[i].press_amt),
 			Timestamp: int64(c_events[i].timestamp),
 		}
+		if device.Type == gin.DeviceTypeMouse || device.Type == gin.DeviceTypeTouch {
+			events[i].Has_cursor = true
+			events[i].Cursor_x, events[i].Cursor_y = win32.rawCursorToWindowCoords(int(c_events[i].cursor_x), int(c_events[i].cursor_y))
+		}
 	}
 	return events, win32.horizon
 }
 
+func (win32 *win32SystemObject) GetTextEvents() []gin.TextEvent {
+	var first_event *C.GlopTextEvent
+	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
+	var length C.int
+	C.GlopGetTextEvents(unsafe.Pointer(win32.window), cp, unsafe.Pointer(&length))
+	c_events := (*[10000]C.GlopTextEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.TextEvent, length)
+	for i := range c_events {
+		events[i] = gin.TextEvent{
+			Rune:      rune(c_events[i].rune),
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
+func (win32 *win32SystemObject) GetWindowEvents() []gin.WindowEvent {
+	if win32.window == 0 {
+		return nil
+	}
+	var first_event *C.GlopWindowEvent
+	var length C.int
+	C.GlopGetWindowEvents(unsafe.Pointer(win32.window), (*unsafe.Pointer)(unsafe.Pointer(&first_event)), &length)
+	c_events := (*[10000]C.GlopWindowEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.WindowEvent, length)
+	for i := range c_events {
+		events[i] = gin.WindowEvent{
+			Type:      gin.WindowEventType(c_events[i]._type),
+			Dx:        int(c_events[i].dx),
+			Dy:        int(c_events[i].dy),
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
+func (win32 *win32SystemObject) GetFileDropEvents() []gin.FileDropEvent {
+	if win32.window == 0 {
+		return nil
+	}
+	var first_event *C.GlopFileDropEvent
+	var length C.int
+	C.GlopGetFileDropEvents(unsafe.Pointer(win32.window), (*unsafe.Pointer)(unsafe.Pointer(&first_event)), &length)
+	c_events := (*[10000]C.GlopFileDropEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.FileDropEvent, length)
+	for i := range c_events {
+		events[i].Paths = splitPaths(C.GoBytes(unsafe.Pointer(c_events[i].paths), c_events[i].length))
+		events[i].X, events[i].Y = win32.rawCursorToWindowCoords(int(c_events[i].x), int(c_events[i].y))
+		events[i].Timestamp = int64(c_events[i].timestamp)
+	}
+	return events
+}
+
+func (win32 *win32SystemObject) GetClipboardString() string {
+	if win32.window == 0 {
+		return ""
+	}
+	var text *C.char
+	var length C.int
+	C.GlopGetClipboard(unsafe.Pointer(win32.window), (*unsafe.Pointer)(unsafe.Pointer(&text)), &length)
+	return C.GoStringN(text, length)
+}
+
+func (win32 *win32SystemObject) SetClipboardString(s string) {
+	if win32.window == 0 {
+		return
+	}
+	text := cString(s)
+	C.GlopSetClipboard(unsafe.Pointer(win32.window), unsafe.Pointer(&text[0]))
+}
+
 func (win32 *win32SystemObject) rawCursorToWindowCoords(x, y int) (int, int) {
 	wx, wy, _, wdy := win32.GetWindowDims()
 	return x - wx, wy + wdy - y
@@ -103,18 +278,131 @@ func (win32 *win32SystemObject) GetWindowDims() (int, int, int, int) {
 	return int(x), int(y), int(dx), int(dy)
 }
 
-func (win32 *win32SystemObject) EnableVSync(enable bool) {
-	var _enable C.int
-	if enable {
-		_enable = 1
-	}
-	C.GlopEnableVSync(_enable)
+func (win32 *win32SystemObject) SetSwapInterval(n int) {
+	C.GlopSetSwapInterval(C.int(n))
 }
 
 func (win32 *win32SystemObject) HideCursor(hide bool) {
 }
 
+func (win32 *win32SystemObject) SetRelativeMouseMode(relative bool) {
+	if win32.window == 0 {
+		return
+	}
+	var _relative C.int
+	if relative {
+		_relative = 1
+	}
+	C.GlopSetRelativeMouseMode(unsafe.Pointer(win32.window), _relative)
+}
+
+func (win32 *win32SystemObject) SetCursorShape(shape system.CursorShape) {
+	if win32.window == 0 {
+		return
+	}
+	C.GlopSetCursorShape(unsafe.Pointer(win32.window), C.int(shape))
+}
+
+func (win32 *win32SystemObject) SetCustomCursor(im image.Image, hot_x, hot_y int) {
+	if win32.window == 0 {
+		return
+	}
+	pix, dx, dy := iconPixels(im)
+	if len(pix) == 0 {
+		return
+	}
+	C.GlopSetCustomCursor(unsafe.Pointer(win32.window), unsafe.Pointer(&pix[0]), C.int(dx), C.int(dy), C.int(hot_x), C.int(hot_y))
+}
+
+// Windows are always the same size in pixels as they are in the coordinates
+// of the display.
+func (win32 *win32SystemObject) GetBackingScale() float64 {
+	return 1
+}
+
+// Rumble goes through XInput rather than DirectInput, which can't rumble
+// XInput controllers.  Joystick n is sent to XInput slot n-1, which is the
+// same controller as long as all of them are XInput controllers.
+func (win32 *win32SystemObject) SetRumble(device gin.DeviceId, low, high float64, duration_ms int) {
+	if device.Type != gin.DeviceTypeController {
+		return
+	}
+	low, high = clampRumble(low), clampRumble(high)
+	slot := C.int(device.Index - 1)
+	win32.rumble_mutex.Lock()
+	defer win32.rumble_mutex.Unlock()
+	if stop := win32.rumble_stops[device.Index]; stop != nil {
+		stop.Stop()
+		delete(win32.rumble_stops, device.Index)
+	}
+	if C.GlopSetRumble(slot, C.float(low), C.float(high)) == 0 {
+		return
+	}
+	if duration_ms <= 0 || (low == 0 && high == 0) {
+		return
+	}
+	if win32.rumble_stops == nil {
+		win32.rumble_stops = make(map[gin.DeviceIndex]*time.Timer)
+	}
+	var stop *time.Timer
+	stop = time.AfterFunc(time.Duration(duration_ms)*time.Millisecond, func() {
+		win32.rumble_mutex.Lock()
+		defer win32.rumble_mutex.Unlock()
+		// SetRumble() may have been called again after this timer fired but
+		// before it got the lock.
+		if win32.rumble_stops[device.Index] != stop {
+			return
+		}
+		delete(win32.rumble_stops, device.Index)
+		C.GlopSetRumble(slot, 0, 0)
+	})
+	win32.rumble_stops[device.Index] = stop
+}
+
 func (win32 *win32SystemObject) HasFocus() bool {
 	// TODO: Implement me!
 	return true
 }
+
+func openAudio(rate int) int {
+	return int(C.GlopOpenAudio(C.int(rate)))
+}
+
+func writeAudio(samples unsafe.Pointer, frames int) bool {
+	return C.GlopWriteAudio(samples, C.int(frames)) != 0
+}
+
+func closeAudio() {
+	C.GlopCloseAudio()
+}
+
+type win32SharedContext struct {
+	window  uintptr
+	context unsafe.Pointer
+}
+
+func (win32 *win32SystemObject) CreateSharedContext() (system.SharedContext, error) {
+	if win32.window == 0 {
+		return nil, fmt.Errorf("There is no window to share a GL context with.")
+	}
+	context := C.GlopCreateSharedContext(unsafe.Pointer(win32.window))
+	if context == nil {
+		return nil, fmt.Errorf("Unable to create a shared GL context.")
+	}
+	return &win32SharedContext{win32.window, context}, nil
+}
+
+func (c *win32SharedContext) MakeCurrent() error {
+	if C.GlopMakeContextCurrent(unsafe.Pointer(c.window), c.context) == 0 {
+		return fmt.Errorf("Unable to make the shared GL context current.")
+	}
+	return nil
+}
+
+func (c *win32SharedContext) Release() {
+	C.GlopMakeContextCurrent(unsafe.Pointer(c.window), nil)
+}
+
+func (c *win32SharedContext) Destroy() {
+	C.GlopDestroyContext(c.context)
+}
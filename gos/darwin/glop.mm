@@ -6,6 +6,7 @@
 #include <pthread.h>
 #include <ApplicationServices/ApplicationServices.h>
 #include <IOKit/hid/IOHIDLib.h>
+#include <AudioToolbox/AudioToolbox.h>
 
 // TODO: This requires OSX 10.6 or higher, just for getting uptime.
 // if we bother to fix linking on osx such that 10.5 is acceptable we 
@@ -13,6 +14,7 @@
 #include <Foundation/NSProcessInfo.h>
 
 #include <map>
+#include <string>
 #include <vector>
 using namespace std;
 
@@ -88,6 +90,130 @@ void AddEvent(KeyEventOld* event) {
   pthread_mutex_unlock(&event_group_mutex);
 }
 
+// Characters typed since the last call to GetTextEvents, protected by event_group_mutex.
+vector<TextEvent> text_events;
+TextEvent* text_event_buffer = NULL;
+
+// Adds a text event for each character typed in a key down event.  NSString is
+// utf-16, so surrogate pairs need to be put back together.
+void AddTextEvents(NSEvent* event) {
+  NSString* characters = [event characters];
+  long long timestamp = NSTimeIntervalToMS([event timestamp]);
+  pthread_mutex_lock(&event_group_mutex);
+  for (NSUInteger i = 0; i < [characters length]; i++) {
+    unichar c = [characters characterAtIndex:i];
+    TextEvent text_event;
+    text_event.rune = c;
+    text_event.timestamp = timestamp;
+    if (c >= 0xD800 && c <= 0xDBFF && i + 1 < [characters length]) {
+      unichar low = [characters characterAtIndex:i+1];
+      if (low >= 0xDC00 && low <= 0xDFFF) {
+        text_event.rune = 0x10000 + ((c - 0xD800) << 10) + (low - 0xDC00);
+        i++;
+      }
+    }
+    text_events.push_back(text_event);
+  }
+  pthread_mutex_unlock(&event_group_mutex);
+}
+
+// Window events since the last call to GetWindowEvents, protected by event_group_mutex.
+vector<WindowEvent> window_events;
+WindowEvent* window_event_buffer = NULL;
+
+void AddWindowEvent(int type, int dx, int dy) {
+  WindowEvent window_event;
+  window_event.type = type;
+  window_event.dx = dx;
+  window_event.dy = dy;
+  window_event.timestamp = NSTimeIntervalToMS([[NSProcessInfo processInfo] systemUptime]);
+  pthread_mutex_lock(&event_group_mutex);
+  window_events.push_back(window_event);
+  pthread_mutex_unlock(&event_group_mutex);
+}
+
+// Resize events are in backing pixels, like every other size that the Go side reports.
+void AddBackingResizeEvent(NSWindow* window) {
+  NSRect view = [[window contentView] frame];
+  if ([window respondsToSelector:@selector(convertRectToBacking:)]) {
+    view = [window convertRectToBacking:view];
+  }
+  AddWindowEvent(windowResize, view.size.width, view.size.height);
+}
+
+// Files dropped since the last call to GetFileDropEvents, protected by event_group_mutex.  The
+// paths are kept in the same format as FileDropEvent.paths.
+struct FileDrop {
+  string paths;
+  int x, y;
+  long long timestamp;
+};
+vector<FileDrop> file_drops;
+
+// Adds the files from a drag, which ended at point in screen coordinates.
+void AddFileDrop(NSArray* files, NSPoint point) {
+  FileDrop drop;
+  for (NSUInteger i = 0; i < [files count]; i++) {
+    drop.paths += [[files objectAtIndex:i] UTF8String];
+    drop.paths += '\0';
+  }
+  if (drop.paths.empty()) {
+    return;
+  }
+  drop.x = point.x;
+  drop.y = point.y;
+  drop.timestamp = NSTimeIntervalToMS([[NSProcessInfo processInfo] systemUptime]);
+  pthread_mutex_lock(&event_group_mutex);
+  file_drops.push_back(drop);
+  pthread_mutex_unlock(&event_group_mutex);
+}
+
+@interface GlopWindowDelegate : NSObject
+@end
+
+@implementation GlopWindowDelegate
+// The window stays open, it's up to the application to decide what to do.
+- (BOOL)windowShouldClose:(id)sender {
+  AddWindowEvent(windowClose, 0, 0);
+  return NO;
+}
+- (void)windowDidResize:(NSNotification*)notification {
+  AddBackingResizeEvent([notification object]);
+}
+// Moving the window to a display with a different backing scale changes its size in pixels.
+- (void)windowDidChangeBackingProperties:(NSNotification*)notification {
+  AddBackingResizeEvent([notification object]);
+}
+- (void)windowDidBecomeKey:(NSNotification*)notification {
+  AddWindowEvent(windowFocus, 0, 0);
+}
+- (void)windowDidResignKey:(NSNotification*)notification {
+  AddWindowEvent(windowBlur, 0, 0);
+}
+- (void)windowDidMiniaturize:(NSNotification*)notification {
+  AddWindowEvent(windowMinimize, 0, 0);
+}
+- (void)windowDidDeminiaturize:(NSNotification*)notification {
+  AddWindowEvent(windowRestore, 0, 0);
+}
+// The window passes drags of the NSFilenamesPboardType that it registered for on to us.
+- (NSDragOperation)draggingEntered:(id<NSDraggingInfo>)sender {
+  return NSDragOperationCopy;
+}
+- (NSDragOperation)draggingUpdated:(id<NSDraggingInfo>)sender {
+  return NSDragOperationCopy;
+}
+- (BOOL)performDragOperation:(id<NSDraggingInfo>)sender {
+  NSArray* files = [[sender draggingPasteboard] propertyListForType:NSFilenamesPboardType];
+  if (![files isKindOfClass:[NSArray class]]) {
+    return NO;
+  }
+  NSPoint point = [[sender draggingDestinationWindow] convertBaseToScreen:[sender draggingLocation]];
+  AddFileDrop(files, point);
+  return YES;
+}
+@end
+
 struct deviceStats {
   IOHIDQueueRef queue;
   int device_type;
@@ -634,6 +760,15 @@ int* getInputStateVal(int flag) {
     if (scroll_event.press_amt != 0) {
       AddEvent(&scroll_event);
     }
+    // deltaX is positive when scrolling to the left, but glop uses positive
+    // values for scrolling right.
+    KeyEventOld hscroll_event;
+    ClearEvent(&hscroll_event, event);
+    hscroll_event.press_amt = -[event deltaX];
+    hscroll_event.index = kMouseWheelHorizontal;
+    if (hscroll_event.press_amt != 0) {
+      AddEvent(&hscroll_event);
+    }
   } else if ([event type] == NSMouseMoved ||
              [event type] == NSLeftMouseDragged ||
              [event type] == NSRightMouseDragged ||
@@ -715,6 +850,9 @@ int* getInputStateVal(int flag) {
     if ([event type] == NSApplicationDefined) {
       break;
     }
+    if ([event type] == NSKeyDown) {
+      AddTextEvents(event);
+    }
     if (!(
         [event type] == NSFlagsChanged      ||
         [event type] == NSScrollWheel       ||
@@ -780,6 +918,51 @@ void GetInputEvents(void** _key_events, int* length, long long* horizon) {
   *horizon = int(uptime*1000);
 }
 
+void GetTextEvents(void** _text_events, int* length) {
+  pthread_mutex_lock(&event_group_mutex);
+  text_event_buffer = (TextEvent*)realloc(text_event_buffer, sizeof(TextEvent) * (text_events.size() + 1));
+  for (int i = 0; i < text_events.size(); i++) {
+    text_event_buffer[i] = text_events[i];
+  }
+  *length = text_events.size();
+  text_events.clear();
+  pthread_mutex_unlock(&event_group_mutex);
+  *_text_events = (void*)(text_event_buffer);
+}
+
+void GetWindowEvents(void** _window_events, int* length) {
+  pthread_mutex_lock(&event_group_mutex);
+  window_event_buffer = (WindowEvent*)realloc(window_event_buffer, sizeof(WindowEvent) * (window_events.size() + 1));
+  for (int i = 0; i < window_events.size(); i++) {
+    window_event_buffer[i] = window_events[i];
+  }
+  *length = window_events.size();
+  window_events.clear();
+  pthread_mutex_unlock(&event_group_mutex);
+  *_window_events = (void*)(window_event_buffer);
+}
+
+FileDropEvent* file_drop_buffer = NULL;
+// The drops that file_drop_buffer points into.
+vector<FileDrop> returned_file_drops;
+
+void GetFileDropEvents(void** _file_drop_events, int* length) {
+  pthread_mutex_lock(&event_group_mutex);
+  returned_file_drops.swap(file_drops);
+  file_drops.clear();
+  pthread_mutex_unlock(&event_group_mutex);
+  file_drop_buffer = (FileDropEvent*)realloc(file_drop_buffer, sizeof(FileDropEvent) * (returned_file_drops.size() + 1));
+  for (int i = 0; i < returned_file_drops.size(); i++) {
+    file_drop_buffer[i].paths = (char*)returned_file_drops[i].paths.data();
+    file_drop_buffer[i].length = returned_file_drops[i].paths.size();
+    file_drop_buffer[i].x = returned_file_drops[i].x;
+    file_drop_buffer[i].y = returned_file_drops[i].y;
+    file_drop_buffer[i].timestamp = returned_file_drops[i].timestamp;
+  }
+  *length = returned_file_drops.size();
+  *_file_drop_events = (void*)(file_drop_buffer);
+}
+
 void GetActiveDevices(void** _device_ids, int* length) {
   DeviceId** device_ids = (DeviceId**)_device_ids;
   *device_ids = device_buffer;
@@ -796,13 +979,32 @@ void GetActiveDevices(void** _device_ids, int* length) {
   pthread_mutex_unlock(&glop_hid_manager.mutex);
 }
 
-void CreateWindow(void** _window, void** _context, int x, int y, int width, int height) {
+// The pixel format of the window's context, shared contexts have to use the same one.
+static NSOpenGLPixelFormat* window_pixel_format = nil;
+
+void CreateWindow(void** _window, void** _context, int x, int y, int width, int height, int resizable, int msaa) {
   NSRect windowRect = NSMakeRect(x, y, width, height);
   NSWindow* window = [NSWindow alloc];
   *((NSWindow**)(_window)) = window;
+  NSUInteger style_mask = NSClosableWindowMask | NSTitledWindowMask | NSMiniaturizableWindowMask;
+  if (resizable) {
+    style_mask |= NSResizableWindowMask;
+  }
   [window initWithContentRect:windowRect 
-  styleMask:( NSResizableWindowMask | NSClosableWindowMask | NSTitledWindowMask) 
+  styleMask:style_mask
   backing:NSBackingStoreBuffered defer:NO];
+  // NSWindowCollectionBehaviorFullScreenPrimary, which lets toggleFullScreen: work on windows
+  // that can't be resized, only exists on 10.7 and later.
+  if ([window respondsToSelector:@selector(toggleFullScreen:)]) {
+    [window setCollectionBehavior:[window collectionBehavior] | (1 << 7)];
+  }
+  [window setDelegate:[[GlopWindowDelegate alloc] init]];
+  // Without this Retina displays get a framebuffer at half their resolution that is scaled up.
+  // Only exists on 10.7 and later.
+  if ([[window contentView] respondsToSelector:@selector(setWantsBestResolutionOpenGLSurface:)]) {
+    [[window contentView] setWantsBestResolutionOpenGLSurface:YES];
+  }
+  [window registerForDraggedTypes:[NSArray arrayWithObject:NSFilenamesPboardType]];
   [window makeKeyAndOrderFront:nil];
   [window setAcceptsMouseMovedEvents:YES];
   NSPoint window_cursor = [window mouseLocationOutsideOfEventStream];
@@ -822,12 +1024,31 @@ void CreateWindow(void** _window, void** _context, int x, int y, int width, int
     //    NSOpenGLPFAFullScreen,
     0,
   };
-  NSOpenGLPixelFormat* pixel_format = [[NSOpenGLPixelFormat alloc] initWithAttributes:attributes];
+  NSOpenGLPixelFormat* pixel_format = nil;
+  if (msaa > 0) {
+    NSOpenGLPixelFormatAttribute msaa_attributes[] = {
+      NSOpenGLPFADoubleBuffer,
+      NSOpenGLPFAAccelerated,
+      NSOpenGLPFAColorSize, 32,
+      NSOpenGLPFADepthSize, 32,
+      NSOpenGLPFAStencilSize, 8,
+      NSOpenGLPFAOpenGLProfile, NSOpenGLProfileVersion3_2Core,
+      NSOpenGLPFAMultisample,
+      NSOpenGLPFASampleBuffers, 1,
+      NSOpenGLPFASamples, (NSOpenGLPixelFormatAttribute)msaa,
+      0,
+    };
+    pixel_format = [[NSOpenGLPixelFormat alloc] initWithAttributes:msaa_attributes];
+  }
+  if (pixel_format == nil) {
+    pixel_format = [[NSOpenGLPixelFormat alloc] initWithAttributes:attributes];
+  }
   if (pixel_format == nil) {
     // TODO: How do we signal this properly?
     exit(0);
     return;
   }
+  window_pixel_format = pixel_format;
   NSOpenGLContext* context = [NSOpenGLContext alloc];
   *((NSOpenGLContext**)(_context)) = context;
   [context initWithFormat:pixel_format shareContext:nil];
@@ -837,11 +1058,164 @@ void CreateWindow(void** _window, void** _context, int x, int y, int width, int
   [context flushBuffer];
 }
 
+void SetTitle(void* _window, void* title) {
+  NSWindow* window = (NSWindow*)_window;
+  [window setTitle:[NSString stringWithUTF8String:(const char*)title]];
+}
+
+void SetFullscreen(void* _window, int fullscreen) {
+  NSWindow* window = (NSWindow*)_window;
+  if (![window respondsToSelector:@selector(toggleFullScreen:)]) {
+    return;
+  }
+  // NSFullScreenWindowMask
+  bool is_fullscreen = ([window styleMask] & (1 << 14)) != 0;
+  if (is_fullscreen != (fullscreen != 0)) {
+    [window toggleFullScreen:nil];
+  }
+}
+
+void ResizeWindow(void* _window, int width, int height) {
+  NSWindow* window = (NSWindow*)_window;
+  [window setContentSize:NSMakeSize(width, height)];
+}
+
+void MinimizeWindow(void* _window) {
+  NSWindow* window = (NSWindow*)_window;
+  [window miniaturize:nil];
+}
+
+// OSX doesn't have window icons, so this sets the icon in the dock.  pixels
+// are dx*dy RGBA, rows from top to bottom.
+// Returns an image made from dx*dy RGBA pixels, which the caller must release.
+static NSImage* ImageFromPixels(void* pixels, int dx, int dy) {
+  NSBitmapImageRep* rep = [[NSBitmapImageRep alloc]
+      initWithBitmapDataPlanes:NULL
+      pixelsWide:dx
+      pixelsHigh:dy
+      bitsPerSample:8
+      samplesPerPixel:4
+      hasAlpha:YES
+      isPlanar:NO
+      colorSpaceName:NSDeviceRGBColorSpace
+      bitmapFormat:NSAlphaNonpremultipliedBitmapFormat
+      bytesPerRow:dx * 4
+      bitsPerPixel:32];
+  memcpy([rep bitmapData], pixels, dx * dy * 4);
+  NSImage* image = [[NSImage alloc] initWithSize:NSMakeSize(dx, dy)];
+  [image addRepresentation:rep];
+  [rep release];
+  return image;
+}
+
+void SetIcon(void* pixels, int dx, int dy) {
+  NSImage* image = ImageFromPixels(pixels, dx, dy);
+  [glop_app setApplicationIconImage:image];
+  [image release];
+}
+
+// The cursor set by SetCursorShape() or SetCustomCursor().
+static NSCursor* glop_cursor = nil;
+
+static void SetGlopCursor(NSCursor* cursor) {
+  [glop_cursor release];
+  glop_cursor = [cursor retain];
+  [glop_cursor set];
+}
+
+void SetCursorShape(int shape) {
+  switch (shape) {
+    case cursorHand: SetGlopCursor([NSCursor pointingHandCursor]); break;
+    case cursorIBeam: SetGlopCursor([NSCursor IBeamCursor]); break;
+    case cursorCrosshair: SetGlopCursor([NSCursor crosshairCursor]); break;
+    default: SetGlopCursor([NSCursor arrowCursor]); break;
+  }
+}
+
+void SetCustomCursor(void* pixels, int dx, int dy, int hot_x, int hot_y) {
+  NSImage* image = ImageFromPixels(pixels, dx, dy);
+  NSCursor* cursor = [[NSCursor alloc] initWithImage:image hotSpot:NSMakePoint(hot_x, hot_y)];
+  SetGlopCursor(cursor);
+  [cursor release];
+  [image release];
+}
+
+static Display* display_buffer = 0;
+
+// The first screen in [NSScreen screens] is the one with the menu bar, which is
+// what osx considers the primary display.
+void GetDisplays(void** _displays, int* length) {
+  NSArray* screens = [NSScreen screens];
+  display_buffer = (Display*)realloc(display_buffer, sizeof(Display) * ([screens count] + 1));
+  for (int i = 0; i < [screens count]; i++) {
+    NSScreen* screen = [screens objectAtIndex:i];
+    NSRect frame = [screen frame];
+    Display* d = &display_buffer[i];
+    d->x = frame.origin.x;
+    d->y = frame.origin.y;
+    d->dx = frame.size.width;
+    d->dy = frame.size.height;
+    d->primary = (i == 0);
+    NSDictionary* description = [screen deviceDescription];
+    NSSize resolution = [[description objectForKey:NSDeviceResolution] sizeValue];
+    d->dpi = resolution.width;
+    d->refresh_rate = 0;
+    CGDirectDisplayID display_id =
+        [[description objectForKey:@"NSScreenNumber"] unsignedIntValue];
+    CGDisplayModeRef mode = CGDisplayCopyDisplayMode(display_id);
+    if (mode != NULL) {
+      d->refresh_rate = CGDisplayModeGetRefreshRate(mode);
+      CGDisplayModeRelease(mode);
+    }
+  }
+  *((Display**)_displays) = display_buffer;
+  *length = [screens count];
+}
+
+void SetClipboard(void* text) {
+  NSPasteboard* pasteboard = [NSPasteboard generalPasteboard];
+  [pasteboard declareTypes:[NSArray arrayWithObject:NSStringPboardType] owner:nil];
+  [pasteboard setString:[NSString stringWithUTF8String:(const char*)text] forType:NSStringPboardType];
+}
+
+static char* clipboard_buffer = NULL;
+
+void GetClipboard(void** _text, int* length) {
+  NSString* string = [[NSPasteboard generalPasteboard] stringForType:NSStringPboardType];
+  const char* utf8 = "";
+  if (string != nil) {
+    utf8 = [string UTF8String];
+  }
+  *length = strlen(utf8);
+  clipboard_buffer = (char*)realloc(clipboard_buffer, *length + 1);
+  memcpy(clipboard_buffer, utf8, *length + 1);
+  *_text = (void*)clipboard_buffer;
+}
+
 void SwapBuffers(void* _context) {
   NSOpenGLContext* context = (NSOpenGLContext*)(_context);
   [context flushBuffer];
 }
 
+// Shared contexts never draw anything, so they don't need a view.
+void* CreateSharedContext(void* _context) {
+  if (window_pixel_format == nil) return NULL;
+  NSOpenGLContext* context = (NSOpenGLContext*)(_context);
+  return [[NSOpenGLContext alloc] initWithFormat:window_pixel_format shareContext:context];
+}
+
+void MakeContextCurrent(void* _context) {
+  if (_context == NULL) {
+    [NSOpenGLContext clearCurrentContext];
+    return;
+  }
+  [(NSOpenGLContext*)(_context) makeCurrentContext];
+}
+
+void DestroyContext(void* _context) {
+  [(NSOpenGLContext*)(_context) release];
+}
+
 void ShutDown() {
   [pool drain];
 }
@@ -873,6 +1247,11 @@ void HideCursor(int hide) {
   }
 }
 
+void SetRelativeMouseMode(int relative) {
+  CGAssociateMouseAndMouseCursorPosition(relative ? false : true);
+  HideCursor(relative);
+}
+
 void GetWindowDims(void* _window, int* x, int* y, int* dx, int* dy) {
   NSWindow* window = (NSWindow*)_window;
   NSRect view = [[window contentView] frame];
@@ -883,9 +1262,17 @@ void GetWindowDims(void* _window, int* x, int* y, int* dx, int* dy) {
   *dy = view.size.height;
 }
 
-void EnableVSync(void* _context, int set_vsync) {
+float GetBackingScale(void* _window) {
+  NSWindow* window = (NSWindow*)_window;
+  if (window == nil || ![window respondsToSelector:@selector(backingScaleFactor)]) {
+    return 1;
+  }
+  return [window backingScaleFactor];
+}
+
+void SetSwapInterval(void* _context, int interval) {
   NSOpenGLContext* context = (NSOpenGLContext*)(_context);
-  GLint swapInt = set_vsync;
+  GLint swapInt = interval;
   [context setValues:&swapInt forParameter:NSOpenGLCPSwapInterval];
 }
 
@@ -897,4 +1284,95 @@ void HasFocus(int* _has_focus) {
   }
 }
 
+// Audio goes through an AudioQueue, which pulls buffers from its own thread.  WriteAudio() fills a
+// ring of samples that the queue's callback drains, and blocks while the ring is full.
+static const int kAudioBuffers = 3;
+static const int kAudioBufferFrames = 1024;
+static AudioQueueRef audio_queue = NULL;
+static pthread_mutex_t audio_mutex = PTHREAD_MUTEX_INITIALIZER;
+static pthread_cond_t audio_cond = PTHREAD_COND_INITIALIZER;
+static vector<float> audio_ring;
+static int audio_read = 0;
+static int audio_count = 0;
+
+static void AudioCallback(void* data, AudioQueueRef queue, AudioQueueBufferRef buffer) {
+  float* out = (float*)buffer->mAudioData;
+  int samples = 2 * kAudioBufferFrames;
+  pthread_mutex_lock(&audio_mutex);
+  for (int i = 0; i < samples; i++) {
+    if (audio_count > 0) {
+      out[i] = audio_ring[audio_read];
+      audio_read = (audio_read + 1) % (int)audio_ring.size();
+      audio_count--;
+    } else {
+      out[i] = 0;
+    }
+  }
+  pthread_cond_signal(&audio_cond);
+  pthread_mutex_unlock(&audio_mutex);
+  buffer->mAudioDataByteSize = samples * sizeof(float);
+  AudioQueueEnqueueBuffer(queue, buffer, 0, NULL);
+}
+
+int OpenAudio(int rate) {
+  if (audio_queue) return 0;
+  AudioStreamBasicDescription format;
+  memset(&format, 0, sizeof(format));
+  format.mSampleRate = rate;
+  format.mFormatID = kAudioFormatLinearPCM;
+  format.mFormatFlags = kAudioFormatFlagIsFloat | kAudioFormatFlagIsPacked;
+  format.mFramesPerPacket = 1;
+  format.mChannelsPerFrame = 2;
+  format.mBitsPerChannel = 32;
+  format.mBytesPerFrame = 8;
+  format.mBytesPerPacket = 8;
+  if (AudioQueueNewOutput(&format, AudioCallback, NULL, NULL, NULL, 0, &audio_queue) != noErr) {
+    audio_queue = NULL;
+    return 0;
+  }
+  audio_ring.assign(2 * kAudioBuffers * kAudioBufferFrames, 0);
+  audio_read = 0;
+  audio_count = 0;
+  for (int i = 0; i < kAudioBuffers; i++) {
+    AudioQueueBufferRef buffer;
+    if (AudioQueueAllocateBuffer(audio_queue, kAudioBufferFrames * 8, &buffer) != noErr) {
+      CloseAudio();
+      return 0;
+    }
+    AudioCallback(NULL, audio_queue, buffer);
+  }
+  if (AudioQueueStart(audio_queue, NULL) != noErr) {
+    CloseAudio();
+    return 0;
+  }
+  return rate;
+}
+
+int WriteAudio(void* samples, int frames) {
+  if (!audio_queue) return 0;
+  const float* next = (const float*)samples;
+  int remaining = 2 * frames;
+  pthread_mutex_lock(&audio_mutex);
+  while (remaining > 0) {
+    while (audio_count == (int)audio_ring.size()) {
+      pthread_cond_wait(&audio_cond, &audio_mutex);
+    }
+    while (remaining > 0 && audio_count < (int)audio_ring.size()) {
+      audio_ring[(audio_read + audio_count) % (int)audio_ring.size()] = *next;
+      next++;
+      audio_count++;
+      remaining--;
+    }
+  }
+  pthread_mutex_unlock(&audio_mutex);
+  return 1;
+}
+
+void CloseAudio() {
+  if (!audio_queue) return;
+  AudioQueueStop(audio_queue, true);
+  AudioQueueDispose(audio_queue, true);
+  audio_queue = NULL;
+}
+
 } // extern "C"
@@ -117,22 +117,87 @@ typedef struct {
   int Index;
 } DeviceId;
 
+typedef struct {
+  int rune;
+  long long timestamp;
+} TextEvent;
+
+// These match gin.WindowEventType.
+enum { windowClose, windowResize, windowFocus, windowBlur, windowMinimize, windowRestore };
+
+// paths holds length bytes of nul-terminated, utf-8 paths one after another.
+typedef struct {
+  char* paths;
+  int length;
+  int x, y;
+  long long timestamp;
+} FileDropEvent;
+
+// These match system.CursorShape.
+enum { cursorArrow, cursorHand, cursorIBeam, cursorCrosshair };
+
+typedef struct {
+  int type;
+  int dx, dy;
+  long long timestamp;
+} WindowEvent;
+
+typedef struct {
+  int x, y, dx, dy;
+  float dpi;
+  float refresh_rate;
+  int primary;
+} Display;
+
 void Init();
-void CreateWindow(void**, void**, int, int, int, int);
+void CreateWindow(void**, void**, int, int, int, int, int, int);
+void SetTitle(void* _window, void* title);
+void SetFullscreen(void* _window, int fullscreen);
+void ResizeWindow(void* _window, int width, int height);
+void MinimizeWindow(void* _window);
+void SetIcon(void* pixels, int dx, int dy);
 void GetActiveDevices(void** _device_ids, int* length);
 void GetInputEvents(void**, int*, long long*);
+void GetTextEvents(void**, int*);
+void GetWindowEvents(void**, int*);
+void GetFileDropEvents(void**, int*);
 // GetInputEvents(KeyEvent**, length*, horizon*);
 
 void Run();
 void SwapBuffers(void*);
+
+// Shared contexts share textures and buffers with the window's context, so that they can be made
+// on another thread.  CreateSharedContext() returns NULL if it can't make one.
+// MakeContextCurrent() makes a shared context current on the calling thread, or releases the
+// thread's context if context is NULL.
+void* CreateSharedContext(void* _context);
+void MakeContextCurrent(void* _context);
+void DestroyContext(void* _context);
 int Think();
 void Quit();
 
 void GetMousePos(int*, int*);
 void LockCursor(int);
 void HideCursor(int);
+void SetRelativeMouseMode(int);
+void SetCursorShape(int);
+void SetCustomCursor(void* pixels, int dx, int dy, int hot_x, int hot_y);
+// The position and size of the window in points, which are bigger than pixels on Retina displays.
 void GetWindowDims(void* _window, int* x, int* y, int* dx, int* dy);
-void EnableVSync(void* _context, int set_vsync);
+// The number of pixels in the window's framebuffer per point, 1 before 10.7.
+float GetBackingScale(void* _window);
+void SetSwapInterval(void* _context, int interval);
 void HasFocus(int* _has_focus);
+void GetDisplays(void** _displays, int* length);
+void SetClipboard(void* text);
+void GetClipboard(void** _text, int* length);
+
+// Audio is played from whichever thread calls these, not the main thread.  OpenAudio() opens the
+// default device for interleaved stereo float samples at rate samples per second, and returns the
+// rate it actually plays at or 0 if it couldn't open it.  WriteAudio() blocks until frames frames
+// from samples have been queued.
+int OpenAudio(int rate);
+int WriteAudio(void* samples, int frames);
+void CloseAudio();
 
 #endif
@@ -0,0 +1,44 @@
+package gos
+
+import (
+	"github.com/runningwild/glop/gin"
+)
+
+// The linux and windows backends can't tell multiple keyboards or mice apart,
+// so all keyboard and mouse events are reported with this DeviceIndex.
+const systemDeviceIndex gin.DeviceIndex = 5
+
+// Returns the type of device that generates keys with the given index.  This
+// is only needed by backends that don't report the device type themselves.
+func deviceTypeOf(index gin.KeyIndex) gin.DeviceType {
+	switch {
+	case index >= gin.MouseXAxis && index <= gin.MouseMButton:
+		return gin.DeviceTypeMouse
+	case index >= gin.TouchFinger0 && index <= gin.TouchScrollY:
+		return gin.DeviceTypeTouch
+	case index >= gin.ControllerButton0 && index < gin.EitherShift:
+		return gin.DeviceTypeController
+	}
+	return gin.DeviceTypeKeyboard
+}
+
+// Returns the set of active devices for a backend that has a keyboard, a mouse,
+// and the specified controllers.
+func systemDevices(controllers []gin.DeviceIndex) map[gin.DeviceType][]gin.DeviceIndex {
+	return map[gin.DeviceType][]gin.DeviceIndex{
+		gin.DeviceTypeKeyboard:   []gin.DeviceIndex{systemDeviceIndex},
+		gin.DeviceTypeMouse:      []gin.DeviceIndex{systemDeviceIndex},
+		gin.DeviceTypeController: controllers,
+	}
+}
+
+// Clamps a rumble strength passed to SetRumble() to [0, 1].
+func clampRumble(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
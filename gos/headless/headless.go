@@ -0,0 +1,189 @@
+// Package headless is a system.Os with no window, no GL context, and no input
+// devices, for running games and tools where there is nothing to draw to: in
+// CI, in automated tests on servers, and in dedicated servers that Think()
+// sprites without ever rendering them.  It is pure Go and doesn't import gos,
+// so it builds and links without cgo, X11, or libGL.
+//
+// It is meant to be used with render.Init(render.Null), so that nothing tries
+// to make gl calls:
+//
+//	sys := system.Make(headless.GetSystemInterface())
+//	sys.Startup()
+//	render.Init(render.Null)
+//	sys.CreateWindow(0, 0, 1024, 768)
+//
+// Input can still be fed to gin with gin.InjectEvent(), and the window it
+// pretends to have can be resized and made fullscreen like a real one.
+package headless
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/system"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The display that headless windows pretend to be on.
+var display = system.Display{Dx: 1920, Dy: 1080, RefreshRate: 60, Primary: true}
+
+type headlessSystemObject struct {
+	start time.Time
+
+	// Guards the clipboard, which may be used from any goroutine.
+	mutex     sync.Mutex
+	clipboard string
+
+	x, y, dx, dy int
+	restore      [4]int
+	fullscreen   bool
+	title        string
+}
+
+var headless_system_object headlessSystemObject
+
+func GetSystemInterface() system.Os {
+	return &headless_system_object
+}
+
+func (h *headlessSystemObject) Startup() {
+	h.start = time.Now()
+}
+
+func (h *headlessSystemObject) Think() {}
+
+func (h *headlessSystemObject) GetDisplays() []system.Display {
+	return []system.Display{display}
+}
+
+func (h *headlessSystemObject) CreateWindowEx(opts system.WindowOpts) {
+	h.x, h.y, h.dx, h.dy = opts.X, opts.Y, opts.Dx, opts.Dy
+	h.title = opts.Title
+	h.fullscreen = false
+	h.SetFullscreen(opts.Fullscreen)
+}
+
+func (h *headlessSystemObject) SetTitle(title string) {
+	h.title = title
+}
+
+func (h *headlessSystemObject) SetFullscreen(fullscreen bool) {
+	if fullscreen == h.fullscreen {
+		return
+	}
+	h.fullscreen = fullscreen
+	if fullscreen {
+		h.restore = [4]int{h.x, h.y, h.dx, h.dy}
+		h.x, h.y, h.dx, h.dy = display.X, display.Y, display.Dx, display.Dy
+	} else {
+		h.x, h.y, h.dx, h.dy = h.restore[0], h.restore[1], h.restore[2], h.restore[3]
+	}
+}
+
+func (h *headlessSystemObject) Resize(width, height int) {
+	h.dx, h.dy = width, height
+}
+
+func (h *headlessSystemObject) Minimize() {}
+
+func (h *headlessSystemObject) GetCursorPos() (x, y int) {
+	return 0, 0
+}
+
+func (h *headlessSystemObject) HideCursor(bool) {}
+
+func (h *headlessSystemObject) SetRelativeMouseMode(bool) {}
+
+func (h *headlessSystemObject) SetCursorShape(shape system.CursorShape) {}
+
+func (h *headlessSystemObject) SetCustomCursor(im image.Image, hot_x, hot_y int) {}
+
+func (h *headlessSystemObject) GetWindowDims() (x, y, dx, dy int) {
+	return h.x, h.y, h.dx, h.dy
+}
+
+func (h *headlessSystemObject) GetBackingScale() float64 {
+	return 1
+}
+
+func (h *headlessSystemObject) SwapBuffers() {}
+
+// There are no devices, everything comes from gin.InjectEvent().
+func (h *headlessSystemObject) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
+	return nil
+}
+
+// The horizon is wall time, in ms since Startup().
+func (h *headlessSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
+	return nil, int64(time.Since(h.start) / time.Millisecond)
+}
+
+func (h *headlessSystemObject) SetRumble(device gin.DeviceId, low, high float64, duration_ms int) {}
+
+func (h *headlessSystemObject) GetTextEvents() []gin.TextEvent {
+	return nil
+}
+
+func (h *headlessSystemObject) GetWindowEvents() []gin.WindowEvent {
+	return nil
+}
+
+func (h *headlessSystemObject) GetFileDropEvents() []gin.FileDropEvent {
+	return nil
+}
+
+func (h *headlessSystemObject) SetSwapInterval(n int) {}
+
+// The clipboard is only shared within the process.
+func (h *headlessSystemObject) GetClipboardString() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.clipboard
+}
+
+func (h *headlessSystemObject) SetClipboardString(s string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clipboard = s
+}
+
+func (h *headlessSystemObject) HasFocus() bool {
+	return true
+}
+
+// Servers don't have a desktop to follow the conventions of, so data and
+// settings go in os.UserConfigDir() and the cache in os.UserCacheDir().
+func (h *headlessSystemObject) UserDataDir(app string) (string, error) {
+	return userDir(os.UserConfigDir, app, "data")
+}
+
+func (h *headlessSystemObject) UserConfigDir(app string) (string, error) {
+	return userDir(os.UserConfigDir, app, "config")
+}
+
+func (h *headlessSystemObject) UserCacheDir(app string) (string, error) {
+	return userDir(os.UserCacheDir, app)
+}
+
+func userDir(base func() (string, error), app string, sub ...string) (string, error) {
+	if app == "" || app == "." || app == ".." || strings.ContainsAny(app, `/\:`) {
+		return "", fmt.Errorf("'%s' can't be used as a directory name.", app)
+	}
+	dir, err := base()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(append([]string{dir, app}, sub...)...)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func (h *headlessSystemObject) CreateSharedContext() (system.SharedContext, error) {
+	return nil, fmt.Errorf("Headless systems have no GL context to share.")
+}
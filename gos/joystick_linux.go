@@ -0,0 +1,231 @@
+package gos
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/glog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Joysticks are read straight from the kernel's joystick api, so this is
+// shared by both linux backends.
+
+type jsInput struct {
+	TimestampMs uint32
+	Value       int16
+	Type        uint8
+
+	// This is read from an 8-bit value, but glop supports more values so it
+	// gets read into a 32 bit int.
+	Key uint32
+
+	// Since we translate axis values into floats we want this value here for that.
+	FValue float64
+
+	Index gin.DeviceIndex
+
+	// The timestamp in the kernel's event is not on the same clock as the rest
+	// of our events, so we stamp each event with the time that we read it.
+	Timestamp int64
+}
+
+// The kernel sets this bit on the synthetic events it sends when the device is
+// first opened, which report the initial state of all buttons and axes.
+const jsEventInit = 0x80
+
+const (
+	jsEventButton = 1
+	jsEventAxis   = 2
+)
+
+var (
+	// Protects jsActive, which maps the name of each joystick device that we are
+	// polling to the DeviceIndex we've given it.
+	jsMutex  sync.Mutex
+	jsActive map[string]gin.DeviceIndex
+)
+
+func parsejsInput(b []byte) (jsInput, error) {
+	var js jsInput
+	if len(b) != 8 {
+		return js, fmt.Errorf("Expected 8 bytes, got %d.", len(b))
+	}
+	js.TimestampMs = uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+	js.Value = int16(b[4]) | int16(b[5])<<8
+	js.Type = b[6]
+	js.Key = uint32(b[7])
+	return js, nil
+}
+
+func pollJoystick(f *os.File, name string, index gin.DeviceIndex, jsCollect chan<- jsInput) {
+	defer func() {
+		jsMutex.Lock()
+		delete(jsActive, name)
+		forgetRumbler(index)
+		jsMutex.Unlock()
+	}()
+	defer f.Close()
+	buf := make([]byte, 1024)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			glog.Infof("Joystick %d (%s) disconnected: %v", index, name, err)
+			return
+		}
+		now := time.Now().UnixNano() / 1e6
+		tmp := buf[0:n]
+		for len(tmp) >= 8 {
+			js, err := parsejsInput(tmp[0:8])
+			if err != nil {
+				continue
+			}
+			tmp = tmp[8:]
+			js.Index = index
+			js.Timestamp = now
+			switch js.Type &^ jsEventInit {
+			case jsEventButton:
+				js.Key += gin.ControllerButton0
+				js.FValue = float64(js.Value)
+				jsCollect <- js
+
+			case jsEventAxis:
+				// Each axis is two keys, so when one half of the axis is pressed we
+				// also need to make sure the other half has been released.
+				pos, neg := js, js
+				pos.Key += gin.ControllerAxis0Positive
+				neg.Key += gin.ControllerAxis0Negative
+				if js.Value < 0 {
+					neg.FValue = float64(-js.Value) / 32768
+				} else {
+					pos.FValue = float64(js.Value) / 32768
+				}
+				jsCollect <- pos
+				jsCollect <- neg
+			}
+		}
+	}
+}
+
+// Returns the lowest DeviceIndex, starting at 1, that isn't in use by another
+// joystick.  This way a controller that is unplugged and plugged back in will
+// usually get its old index back.  Must be called with jsMutex held.
+func nextJoystickIndex() gin.DeviceIndex {
+	used := make(map[gin.DeviceIndex]bool)
+	for _, index := range jsActive {
+		used[index] = true
+	}
+	index := gin.DeviceIndex(1)
+	for used[index] {
+		index++
+	}
+	return index
+}
+
+func trackJoysticks(jsCollect chan<- jsInput) error {
+	defer close(jsCollect)
+	// Devices that couldn't be opened, so that each one is only warned about
+	// once instead of every time we look.
+	failed := make(map[string]bool)
+	for {
+		f, err := os.Open("/dev/input/by-path")
+		if err != nil {
+			return err
+		}
+		names, err := f.Readdirnames(0)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			// Only the joystick api devices, e.g. pci-...-joystick, give us js
+			// events.  The -event- devices use the evdev api.
+			if strings.Contains(name, "event") || !strings.HasSuffix(name, "joystick") {
+				continue
+			}
+			jsMutex.Lock()
+			if _, ok := jsActive[name]; !ok {
+				if js, err := os.Open("/dev/input/by-path/" + name); err == nil {
+					index := nextJoystickIndex()
+					jsActive[name] = index
+					delete(failed, name)
+					glog.Infof("Joystick %d (%s) connected.", index, name)
+					go pollJoystick(js, name, index, jsCollect)
+				} else if !failed[name] {
+					failed[name] = true
+					glog.Warningf("Can't open joystick %s: %v", name, err)
+				}
+			}
+			jsMutex.Unlock()
+		}
+		time.Sleep(time.Second * 5)
+	}
+	return nil
+}
+
+// Used to send events from all of the joysticks to GetInputEvents().
+var jsCollect chan jsInput
+
+// Starts watching for joysticks, must be called once from Startup().
+func startJoysticks() {
+	jsCollect = make(chan jsInput, 100)
+	jsActive = make(map[string]gin.DeviceIndex)
+	go func() {
+		if err := trackJoysticks(jsCollect); err != nil {
+			glog.Infof("Not watching for joysticks: %v", err)
+		}
+	}()
+}
+
+// Returns the indexes of all of the joysticks we are polling, in order.
+func activeJoysticks() []gin.DeviceIndex {
+	jsMutex.Lock()
+	defer jsMutex.Unlock()
+	var indexes []int
+	for _, index := range jsActive {
+		indexes = append(indexes, int(index))
+	}
+	sort.Ints(indexes)
+	controllers := make([]gin.DeviceIndex, len(indexes))
+	for i := range indexes {
+		controllers[i] = gin.DeviceIndex(indexes[i])
+	}
+	return controllers
+}
+
+// Returns all of the joystick events that have been read so far, none of which
+// will have a timestamp later than horizon.
+func joystickEvents(horizon int64) []gin.OsEvent {
+	var events []gin.OsEvent
+	for {
+		select {
+		case event := <-jsCollect:
+			// This event may have been read after we got the horizon.
+			if event.Timestamp > horizon {
+				event.Timestamp = horizon
+			}
+			events = append(events, gin.OsEvent{
+				KeyId: gin.KeyId{
+					Device: gin.DeviceId{
+						Index: event.Index,
+						Type:  gin.DeviceTypeController,
+					},
+					Index: gin.KeyIndex(event.Key),
+				},
+				Press_amt: event.FValue,
+				Timestamp: event.Timestamp,
+			})
+		default:
+			return events
+		}
+	}
+}
+
+type osEventSlice []gin.OsEvent
+
+func (oes osEventSlice) Len() int           { return len(oes) }
+func (oes osEventSlice) Swap(i, j int)      { oes[i], oes[j] = oes[j], oes[i] }
+func (oes osEventSlice) Less(i, j int) bool { return oes[i].Timestamp < oes[j].Timestamp }
@@ -6,9 +6,18 @@
 #include <cstdio>
 #include <stdio.h>
 #include <sys/time.h>
+#include <unistd.h>
+
+#include <cstring>
 
 #include <X11/Xlib.h>
+#include <X11/Xatom.h>
+#include <X11/extensions/XInput2.h>
+#include <X11/extensions/Xrandr.h>
+#include <X11/Xcursor/Xcursor.h>
+#include <X11/cursorfont.h>
 #include <GL/glx.h>
+#include <alsa/asoundlib.h>
 
 using namespace std;
 
@@ -23,6 +32,9 @@ int screen = 0;
 XIM xim = NULL;
 Atom close_atom;
 
+// The XInput2 extension's opcode, or -1 if it doesn't support touch events.
+int xi_opcode = -1;
+
 Display *get_x_display() { return display; }
 int get_x_screen() { return screen; }
 
@@ -31,14 +43,19 @@ static long long gtm() {
   gettimeofday(&tv, NULL);
   return (long long)tv.tv_sec * 1000000 + tv.tv_usec;
 }
-static int gt() {
-  int val = gtm() / 1000;
-  return val;
+static long long gt() {
+  return gtm() / 1000;
 }
 
 struct OsWindowData {
-  OsWindowData() { window = (Window)NULL; }
+  OsWindowData()
+  : window((Window)NULL), resizable(false), fullscreen(false), width(0), height(0),
+    has_focus(true), is_minimized(false), last_dx(0), last_dy(0),
+    cursor_hidden(false), blank_cursor(None), lock_x(0), lock_y(0), relative_mouse(false),
+    relative_hid_cursor(false), cursor(None) {}
   ~OsWindowData() {
+    if (blank_cursor != None) XFreeCursor(display, blank_cursor);
+    if (cursor != None) XFreeCursor(display, cursor);
     glXDestroyContext(display, context);
     XDestroyIC(inputcontext);
     XDestroyWindow(display, window);
@@ -47,6 +64,36 @@ struct OsWindowData {
   Window window;
   GLXContext context;
   XIC inputcontext;
+
+  // The visual the window and its context were made with, for making shared contexts.
+  XVisualInfo vinfo;
+
+  // A window that isn't resizable has its min and max size hints set to its
+  // size, which are cleared while it is fullscreen.
+  bool resizable;
+  bool fullscreen;
+  int width, height;
+
+  // Updated by FocusIn and FocusOut events.
+  bool has_focus;
+
+  // Used to turn Map, Unmap and Configure events into window events.
+  bool is_minimized;
+  int last_dx, last_dy;
+
+  // While the cursor is hidden it is replaced by blank_cursor and warped back to (lock_x, lock_y),
+  // in root window coordinates, at the end of every GlopThink().
+  bool cursor_hidden;
+  Cursor blank_cursor;
+  int lock_x, lock_y;
+
+  // In relative mouse mode the cursor is hidden and the pointer is grabbed, and mouse motion comes
+  // from XInput2 raw motion events.  relative_hid_cursor is set if the cursor wasn't already
+  // hidden, so that it is shown again when relative mode is turned off.
+  bool relative_mouse;
+  bool relative_hid_cursor;
+  // The cursor set by GlopSetCursorShape() or GlopSetCustomCursor(), or None for the default.
+  Cursor cursor;
 };
 
 void GlopInit() {
@@ -62,6 +109,18 @@ void GlopInit() {
 //  ASSERT(xim);
   
   close_atom = XInternAtom(display, "WM_DELETE_WINDOW", false);
+
+  // Touch events showed up in XInput 2.2.
+  int event, error;
+  if (XQueryExtension(display, "XInputExtension", &xi_opcode, &event, &error)) {
+    int major = 2;
+    int minor = 2;
+    if (XIQueryVersion(display, &major, &minor) != Success || major < 2 || (major == 2 && minor < 2)) {
+      xi_opcode = -1;
+    }
+  } else {
+    xi_opcode = -1;
+  }
 }
 void glopShutDown() {
   XCloseIM(xim);
@@ -69,6 +128,78 @@ void glopShutDown() {
 }
 
 vector<GlopKeyEvent> events;
+vector<GlopTextEvent> text_events;
+vector<GlopWindowEvent> window_events;
+
+// A drop that hasn't been handed to GlopGetFileDropEvents() yet, paths is in the same format as
+// GlopFileDropEvent.paths.
+struct FileDrop {
+  string paths;
+  int x, y;
+  long long timestamp;
+};
+vector<FileDrop> file_drops;
+
+static void AddWindowEvent(int type, int dx, int dy) {
+  GlopWindowEvent event;
+  event.type = type;
+  event.dx = dx;
+  event.dy = dy;
+  event.timestamp = gt();
+  window_events.push_back(event);
+}
+
+// Decodes the utf-8 string in buf, which is len bytes long, and adds a text event for each
+// character in it.
+static void AddTextEvents(const char* buf, int len, long long timestamp) {
+  const unsigned char* s = (const unsigned char*)buf;
+  int i = 0;
+  while (i < len) {
+    int rune = s[i];
+    int extra = 0;
+    if (rune >= 0xf0) {
+      rune &= 0x07;
+      extra = 3;
+    } else if (rune >= 0xe0) {
+      rune &= 0x0f;
+      extra = 2;
+    } else if (rune >= 0xc0) {
+      rune &= 0x1f;
+      extra = 1;
+    }
+    i++;
+    for (; extra > 0 && i < len; extra--, i++) {
+      rune = (rune << 6) | (s[i] & 0x3f);
+    }
+    GlopTextEvent ev;
+    ev.rune = rune;
+    ev.timestamp = timestamp;
+    text_events.push_back(ev);
+  }
+}
+
+// Looks up the text, if any, that the user typed with this key press. This goes through the
+// input context so that dead keys and input methods work.
+static void SynthText(XEvent &event, XIC inputcontext) {
+  char buf[64];
+  KeySym sym;
+  if (inputcontext == NULL) {
+    // Without an input method all we can get is latin-1.
+    int len = XLookupString(&event.xkey, buf, sizeof(buf), &sym, NULL);
+    for (int i = 0; i < len; i++) {
+      GlopTextEvent ev;
+      ev.rune = (unsigned char)buf[i];
+      ev.timestamp = gt();
+      text_events.push_back(ev);
+    }
+    return;
+  }
+  Status status;
+  int len = Xutf8LookupString(inputcontext, &event.xkey, buf, sizeof(buf), &sym, &status);
+  if (status == XLookupChars || status == XLookupBoth) {
+    AddTextEvents(buf, len, gt());
+  }
+}
 static bool SynthKey(const KeySym &sym, bool pushed, const XEvent &event, Window window, GlopKeyEvent *ev) {
   // mostly ignored
   Window root, child;
@@ -202,22 +333,26 @@ static bool SynthButton(int button, bool pushed, const XEvent &event, Window win
   XQueryPointer(display, window, &root, &child, &x, &y, &winx, &winy, &mask);
   
   GlopKey ki;
+  float press_amt = pushed ? 1.0 : 0.0;
   if(button == Button1)
     ki = kMouseLButton;
   else if(button == Button2)
     ki = kMouseMButton;
   else if(button == Button3)
     ki = kMouseRButton;
-  /*
-  else if(button == Button4)
-    ki = kMouseWheelUp;   // these might be inverted so they're disabled for now
-  else if(button == Button5)
-    ki = kMouseWheelDown;*/
+  else if(button >= Button4 && button <= Button4 + 3) {
+    // X reports each click of a mouse wheel as a press and release of a button, 4 and 5 are up
+    // and down, 6 and 7 are left and right.  We only want one event per click.
+    if (!pushed)
+      return false;
+    ki = (button <= Button5) ? kMouseWheelVertical : kMouseWheelHorizontal;
+    press_amt = (button == Button4 || button == Button4 + 3) ? 1.0 : -1.0;
+  }
   else
     return false;
     
   ev->index = ki;
-  ev->press_amt = pushed ? 1.0 : 0.0;
+  ev->press_amt = press_amt;
   ev->timestamp = gt();
   ev->cursor_x = x;
   ev->cursor_y = y;
@@ -252,6 +387,242 @@ static bool SynthMotion(int dx, int dy, const XEvent &event, Window window, Glop
   return true;
 }
 
+// X gives each touch an arbitrary id, these are the ids of the touches that
+// are currently assigned to each finger on each device.
+map<int, map<int, int> > touch_fingers;
+
+// Returns the finger that the touch with the given id on the given device is
+// assigned to, assigning it to the lowest free finger if it is new, or -1 if
+// all of the fingers are in use.
+static int TouchFinger(int device, int touch) {
+  map<int, int>& fingers = touch_fingers[device];
+  set<int> used;
+  for (map<int, int>::iterator it = fingers.begin(); it != fingers.end(); it++) {
+    if (it->second == touch) {
+      return it->first;
+    }
+    used.insert(it->first);
+  }
+  for (int finger = 0; finger < kMaxTouchFingers; finger++) {
+    if (used.count(finger) == 0) {
+      fingers[finger] = touch;
+      return finger;
+    }
+  }
+  return -1;
+}
+
+static bool SynthTouch(XIDeviceEvent* xi_event, GlopKeyEvent *ev) {
+  int finger = TouchFinger(xi_event->sourceid, xi_event->detail);
+  if (finger == -1) {
+    return false;
+  }
+  ev->index = kTouchFinger0 + finger;
+  ev->device = xi_event->sourceid;
+  ev->press_amt = 1.0;
+  ev->timestamp = gt();
+  ev->cursor_x = int(xi_event->root_x);
+  ev->cursor_y = int(xi_event->root_y);
+  if (xi_event->evtype == XI_TouchEnd) {
+    ev->press_amt = 0.0;
+    touch_fingers[xi_event->sourceid].erase(finger);
+  }
+  return true;
+}
+
+// Raw motion events only include the valuators that changed, in order, so the x and y motion are
+// found by walking the valuator mask.  Raw values haven't had pointer acceleration applied.
+static bool SynthRawMotion(XIRawEvent* raw, OsWindowData* data, GlopKeyEvent *ev, GlopKeyEvent *ev2) {
+  double dx = 0, dy = 0;
+  const double* value = raw->raw_values;
+  for (int i = 0; i < raw->valuators.mask_len * 8 && i < 2; i++) {
+    if (XIMaskIsSet(raw->valuators.mask, i)) {
+      if (i == 0) dx = *value;
+      else dy = *value;
+      value++;
+    }
+  }
+  if (dx == 0 && dy == 0) {
+    return false;
+  }
+  ev->index = kMouseXAxis;
+  ev->press_amt = dx;
+  ev->timestamp = gt();
+  ev->cursor_x = data->lock_x;
+  ev->cursor_y = data->lock_y;
+  *ev2 = *ev;
+  ev2->index = kMouseYAxis;
+  ev2->press_amt = dy;
+  return true;
+}
+
+// Keeps the pointer in the window while relative mouse mode is on.
+static void GrabPointer(OsWindowData* data) {
+  XGrabPointer(display, data->window, True, ButtonPressMask | ButtonReleaseMask | PointerMotionMask,
+               GrabModeAsync, GrabModeAsync, data->window, None, CurrentTime);
+}
+
+// The text we've put on the clipboard, which we have to hand out to other programs whenever they
+// ask for it for as long as we own the CLIPBOARD selection.
+static string clipboard_text;
+static bool clipboard_owned = false;
+
+static void AnswerSelectionRequest(const XSelectionRequestEvent& request) {
+  Atom targets = XInternAtom(display, "TARGETS", false);
+  Atom utf8_string = XInternAtom(display, "UTF8_STRING", false);
+  XSelectionEvent reply;
+  memset(&reply, 0, sizeof(reply));
+  reply.type = SelectionNotify;
+  reply.requestor = request.requestor;
+  reply.selection = request.selection;
+  reply.target = request.target;
+  reply.time = request.time;
+  reply.property = None;
+  // Obsolete clients may not specify a property, in which case the target is used instead.
+  Atom property = request.property == None ? request.target : request.property;
+  if (clipboard_owned && request.target == targets) {
+    Atom supported[] = {targets, utf8_string, XA_STRING};
+    XChangeProperty(display, request.requestor, property, XA_ATOM, 32, PropModeReplace,
+                    (const unsigned char*)supported, 3);
+    reply.property = property;
+  } else if (clipboard_owned && (request.target == utf8_string || request.target == XA_STRING)) {
+    XChangeProperty(display, request.requestor, property, request.target, 8, PropModeReplace,
+                    (const unsigned char*)clipboard_text.c_str(), clipboard_text.size());
+    reply.property = property;
+  }
+  XSendEvent(display, request.requestor, false, NoEventMask, (XEvent*)&reply);
+}
+
+// Drag and drop, see freedesktop.org's XDND spec.  Files show up as a text/uri-list of file://
+// uris.
+static const long kXdndVersion = 5;
+static Atom xdnd_aware, xdnd_enter, xdnd_position, xdnd_status, xdnd_leave, xdnd_drop;
+static Atom xdnd_finished, xdnd_selection, xdnd_action_copy, uri_list, glop_drop;
+
+// The window that the current drag is coming from, or None, and where in root coordinates the
+// drag was the last time it moved.
+static Window xdnd_source = None;
+static int xdnd_x = 0;
+static int xdnd_y = 0;
+
+static void InitXdnd() {
+  xdnd_aware = XInternAtom(display, "XdndAware", false);
+  xdnd_enter = XInternAtom(display, "XdndEnter", false);
+  xdnd_position = XInternAtom(display, "XdndPosition", false);
+  xdnd_status = XInternAtom(display, "XdndStatus", false);
+  xdnd_leave = XInternAtom(display, "XdndLeave", false);
+  xdnd_drop = XInternAtom(display, "XdndDrop", false);
+  xdnd_finished = XInternAtom(display, "XdndFinished", false);
+  xdnd_selection = XInternAtom(display, "XdndSelection", false);
+  xdnd_action_copy = XInternAtom(display, "XdndActionCopy", false);
+  uri_list = XInternAtom(display, "text/uri-list", false);
+  glop_drop = XInternAtom(display, "GLOP_DROP", false);
+}
+
+// Sends an XdndStatus or XdndFinished message from window to the source of the current drag.
+static void SendXdndMessage(Window window, Atom type, long l1, long l2, long l4) {
+  XEvent event;
+  memset(&event, 0, sizeof(event));
+  event.type = ClientMessage;
+  event.xclient.window = xdnd_source;
+  event.xclient.message_type = type;
+  event.xclient.format = 32;
+  event.xclient.data.l[0] = window;
+  event.xclient.data.l[1] = l1;
+  event.xclient.data.l[2] = l2;
+  event.xclient.data.l[3] = 0;
+  event.xclient.data.l[4] = l4;
+  XSendEvent(display, xdnd_source, false, NoEventMask, &event);
+}
+
+static int HexDigit(char c) {
+  if (c >= '0' && c <= '9') return c - '0';
+  if (c >= 'a' && c <= 'f') return c - 'a' + 10;
+  if (c >= 'A' && c <= 'F') return c - 'A' + 10;
+  return -1;
+}
+
+// Turns a text/uri-list into nul-terminated paths, skipping anything that isn't a local file.
+static string PathsFromUriList(const string& list) {
+  string paths;
+  size_t start = 0;
+  while (start < list.size()) {
+    size_t end = list.find('\n', start);
+    if (end == string::npos) {
+      end = list.size();
+    }
+    string uri = list.substr(start, end - start);
+    start = end + 1;
+    if (!uri.empty() && uri[uri.size() - 1] == '\r') {
+      uri.erase(uri.size() - 1);
+    }
+    if (uri.compare(0, 7, "file://") != 0) {
+      continue;
+    }
+    // Skip the host, which is usually empty or localhost.
+    size_t slash = uri.find('/', 7);
+    if (slash == string::npos) {
+      continue;
+    }
+    for (size_t i = slash; i < uri.size(); i++) {
+      if (uri[i] == '%' && i + 2 < uri.size() && HexDigit(uri[i + 1]) >= 0 && HexDigit(uri[i + 2]) >= 0) {
+        paths += char(HexDigit(uri[i + 1]) * 16 + HexDigit(uri[i + 2]));
+        i += 2;
+      } else {
+        paths += uri[i];
+      }
+    }
+    paths += '\0';
+  }
+  return paths;
+}
+
+static void HandleXdndMessage(OsWindowData* data, const XClientMessageEvent& message) {
+  if (message.message_type == xdnd_enter) {
+    xdnd_source = message.data.l[0];
+  } else if (message.message_type == xdnd_position) {
+    xdnd_source = message.data.l[0];
+    xdnd_x = (message.data.l[2] >> 16) & 0xffff;
+    xdnd_y = message.data.l[2] & 0xffff;
+    SendXdndMessage(data->window, xdnd_status, 1, 0, xdnd_action_copy);
+  } else if (message.message_type == xdnd_leave) {
+    xdnd_source = None;
+  } else if (message.message_type == xdnd_drop) {
+    xdnd_source = message.data.l[0];
+    XConvertSelection(display, xdnd_selection, uri_list, glop_drop, data->window, message.data.l[2]);
+  }
+}
+
+// Called with the answer to the XConvertSelection() in HandleXdndMessage().
+static void FinishXdnd(OsWindowData* data, const XSelectionEvent& selection) {
+  string paths;
+  if (selection.property != None) {
+    Atom type;
+    int format;
+    unsigned long count, remaining;
+    unsigned char* value = NULL;
+    if (XGetWindowProperty(display, data->window, glop_drop, 0, 1 << 24, true, AnyPropertyType,
+                           &type, &format, &count, &remaining, &value) == Success && value) {
+      if (format == 8) {
+        paths = PathsFromUriList(string((const char*)value, count));
+      }
+      XFree(value);
+    }
+  }
+  if (!paths.empty()) {
+    FileDrop drop;
+    drop.paths = paths;
+    drop.x = xdnd_x;
+    drop.y = xdnd_y;
+    drop.timestamp = gt();
+    file_drops.push_back(drop);
+  }
+  if (xdnd_source != None) {
+    SendXdndMessage(data->window, xdnd_finished, !paths.empty(), paths.empty() ? None : xdnd_action_copy, 0);
+  }
+  xdnd_source = None;
+}
+
 Bool EventTester(Display *display, XEvent *event, XPointer arg) {
   return true; // hurrr
 }
@@ -268,6 +639,13 @@ void GlopThink() {
   int last_botched_release = -1;
   int last_botched_time = -1;
   while(XCheckIfEvent(display, &event, &EventTester, NULL)) {
+    // The input method gets the first look at every event, if it wants the event then it isn't
+    // text yet, but key events are still reported normally.
+    bool filtered = XFilterEvent(&event, None);
+    if (event.type == KeyPress && !filtered) {
+      SynthText(event, data->inputcontext);
+    }
+
     if((event.type == KeyPress || event.type == KeyRelease) && event.xkey.keycode < 256) {
       // X is kind of a cock and likes to send us hardware repeat messages for people holding buttons down. Why do you do this, X? Why do you have to make me hate you?
       
@@ -295,6 +673,23 @@ void GlopThink() {
     
     GlopKeyEvent ev;
     GlopClearKeyEvent(&ev);
+    if (event.type == GenericEvent && event.xcookie.extension == xi_opcode &&
+        XGetEventData(display, &event.xcookie)) {
+      if (event.xcookie.evtype == XI_RawMotion) {
+        if (data->relative_mouse && data->has_focus) {
+          GlopKeyEvent ev2;
+          GlopClearKeyEvent(&ev2);
+          if (SynthRawMotion((XIRawEvent*)event.xcookie.data, data, &ev, &ev2)) {
+            events.push_back(ev);
+            events.push_back(ev2);
+          }
+        }
+      } else if (SynthTouch((XIDeviceEvent*)event.xcookie.data, &ev)) {
+        events.push_back(ev);
+      }
+      XFreeEventData(display, &event.xcookie);
+      continue;
+    }
     switch(event.type) {
       case KeyPress: {
         char buf[2];
@@ -331,6 +726,25 @@ void GlopThink() {
         break;
 
       case MotionNotify:
+        // This is the motion from warping a hidden cursor back to where it was locked.
+        if (data->cursor_hidden && event.xmotion.x_root == data->lock_x &&
+            event.xmotion.y_root == data->lock_y) {
+          break;
+        }
+        // Raw motion events take the place of these in relative mode, but without XInput2 the
+        // best we can do is report how far the cursor moved from where it is locked.
+        if (data->relative_mouse) {
+          if (xi_opcode == -1) {
+            GlopKeyEvent ev2;
+            GlopClearKeyEvent(&ev2);
+            if (SynthMotion(event.xmotion.x_root - data->lock_x, event.xmotion.y_root - data->lock_y,
+                            event, data->window, &ev, &ev2)) {
+              events.push_back(ev);
+              events.push_back(ev2);
+            }
+          }
+          break;
+        }
         GlopKeyEvent ev2;
         GlopClearKeyEvent(&ev2);
         if(SynthMotion(event.xmotion.x, event.xmotion.y, event, data->window, &ev, &ev2)) {
@@ -339,12 +753,53 @@ void GlopThink() {
         }
         break;
       
+      // Keyboard grabs, like the window manager's alt-tab, generate focus events with
+      // NotifyGrab and NotifyUngrab modes, but they don't change which window has focus.
       case FocusIn:
         XSetICFocus(data->inputcontext);
+        if (event.xfocus.mode != NotifyGrab && event.xfocus.mode != NotifyUngrab &&
+            !data->has_focus) {
+          data->has_focus = true;
+          AddWindowEvent(glopWindowFocus, 0, 0);
+          if (data->relative_mouse) {
+            GrabPointer(data);
+          }
+        }
         break;
       
       case FocusOut:
         XUnsetICFocus(data->inputcontext);
+        if (event.xfocus.mode != NotifyGrab && event.xfocus.mode != NotifyUngrab &&
+            event.xfocus.detail != NotifyInferior && data->has_focus) {
+          data->has_focus = false;
+          AddWindowEvent(glopWindowBlur, 0, 0);
+          if (data->relative_mouse) {
+            XUngrabPointer(display, CurrentTime);
+          }
+        }
+        break;
+
+      case ConfigureNotify:
+        if (event.xconfigure.width != data->last_dx || event.xconfigure.height != data->last_dy) {
+          data->last_dx = event.xconfigure.width;
+          data->last_dy = event.xconfigure.height;
+          AddWindowEvent(glopWindowResize, data->last_dx, data->last_dy);
+        }
+        break;
+
+      // Window managers unmap windows when they are iconified.
+      case UnmapNotify:
+        if (!data->is_minimized) {
+          data->is_minimized = true;
+          AddWindowEvent(glopWindowMinimize, 0, 0);
+        }
+        break;
+
+      case MapNotify:
+        if (data->is_minimized) {
+          data->is_minimized = false;
+          AddWindowEvent(glopWindowRestore, 0, 0);
+        }
         break;
       
       case DestroyNotify:
@@ -353,28 +808,330 @@ void GlopThink() {
 //        LOGF("destroed\n");
         return;
     
+      case SelectionRequest:
+        AnswerSelectionRequest(event.xselectionrequest);
+        break;
+
+      case SelectionClear:
+        clipboard_owned = false;
+        break;
+
       case ClientMessage :
+        // The window stays open, it's up to the application to decide what to do.
         if(event.xclient.format == 32 && event.xclient.data.l[0] == static_cast<long>(close_atom)) {
-//            WindowDashDestroy();
-//            LOGF("destroj\n");
-            return;
+          AddWindowEvent(glopWindowClose, 0, 0);
+        }
+        HandleXdndMessage(data, event.xclient);
+        break;
+
+      case SelectionNotify:
+        if (event.xselection.selection == xdnd_selection) {
+          FinishXdnd(data, event.xselection);
+        }
+        break;
+    }
+  }
+
+  if (data->cursor_hidden && data->has_focus) {
+    XWarpPointer(display, None, RootWindow(display, screen), 0, 0, 0, 0, data->lock_x, data->lock_y);
+  }
+}
+
+// Set by GlopQuit() to make GlopRun() return, and the message that GlopQuit() sends to wake it up.
+static volatile int quit_requested = 0;
+static Atom wake_atom = None;
+
+// Processes events as they arrive until GlopQuit() is called.
+void GlopRun() {
+  quit_requested = 0;
+  while (!quit_requested) {
+    if (windowdata) {
+      XEvent event;
+      XPeekEvent(display, &event);
+    } else {
+      usleep(10000);
+    }
+    GlopThink();
+  }
+}
+
+// Makes GlopRun() return, this may be called from any thread.
+void GlopQuit() {
+  quit_requested = 1;
+  if (!windowdata) return;
+  if (wake_atom == None) {
+    wake_atom = XInternAtom(display, "GLOP_WAKE", false);
+  }
+  XEvent event;
+  memset(&event, 0, sizeof(event));
+  event.type = ClientMessage;
+  event.xclient.window = windowdata->window;
+  event.xclient.message_type = wake_atom;
+  event.xclient.format = 32;
+  XSendEvent(display, windowdata->window, false, NoEventMask, &event);
+  XFlush(display);
+}
+
+// Takes ownership of the CLIPBOARD selection, other programs get the text through
+// AnswerSelectionRequest() when GlopThink() sees them asking for it.
+void GlopSetClipboard(void* text) {
+  if (!windowdata) return;
+  clipboard_text = (const char*)text;
+  Atom clipboard = XInternAtom(display, "CLIPBOARD", false);
+  XSetSelectionOwner(display, clipboard, windowdata->window, CurrentTime);
+  clipboard_owned = (XGetSelectionOwner(display, clipboard) == windowdata->window);
+  XFlush(display);
+}
+
+static char* glop_clipboard_buffer = 0;
+
+// Asks the owner of the CLIPBOARD selection for its contents as utf-8 and waits up to a second for
+// them to arrive.  Large selections that are sent incrementally with INCR aren't supported.
+void GlopGetClipboard(void** _text, int* length) {
+  string text;
+  if (clipboard_owned) {
+    text = clipboard_text;
+  } else if (windowdata) {
+    Atom clipboard = XInternAtom(display, "CLIPBOARD", false);
+    Atom utf8_string = XInternAtom(display, "UTF8_STRING", false);
+    Atom property = XInternAtom(display, "GLOP_CLIPBOARD", false);
+    XConvertSelection(display, clipboard, utf8_string, property, windowdata->window, CurrentTime);
+    XFlush(display);
+    XEvent event;
+    bool notified = false;
+    for (int i = 0; i < 1000 && !notified; i++) {
+      if (XCheckTypedWindowEvent(display, windowdata->window, SelectionNotify, &event)) {
+        notified = true;
+      } else {
+        usleep(1000);
+      }
+    }
+    if (notified && event.xselection.property != None) {
+      Atom type;
+      int format;
+      unsigned long num_items, bytes_after;
+      unsigned char* data = NULL;
+      if (XGetWindowProperty(display, windowdata->window, property, 0, 1 << 24, true,
+                             AnyPropertyType, &type, &format, &num_items, &bytes_after,
+                             &data) == Success && data != NULL) {
+        if (format == 8 && type != XInternAtom(display, "INCR", false)) {
+          text = string((const char*)data, num_items);
         }
+        XFree(data);
+      }
     }
   }
+  glop_clipboard_buffer = (char*)realloc(glop_clipboard_buffer, text.size() + 1);
+  memcpy(glop_clipboard_buffer, text.c_str(), text.size() + 1);
+  *((char**)_text) = glop_clipboard_buffer;
+  *length = text.size();
+}
+
+int GlopHasFocus() {
+  if (!windowdata) return 0;
+  return windowdata->has_focus ? 1 : 0;
+}
+
+// Hides the cursor and locks it where it is, see GlopThink().
+void GlopHideCursor(int hide) {
+  if (!windowdata) return;
+  OsWindowData* data = windowdata;
+  if ((hide != 0) == data->cursor_hidden) return;
+  if (hide) {
+    if (data->blank_cursor == None) {
+      static char empty[] = {0};
+      XColor black;
+      memset(&black, 0, sizeof(black));
+      Pixmap pixmap = XCreateBitmapFromData(display, data->window, empty, 1, 1);
+      data->blank_cursor = XCreatePixmapCursor(display, pixmap, pixmap, &black, &black, 0, 0);
+      XFreePixmap(display, pixmap);
+    }
+    Window root, child;
+    int winx, winy;
+    unsigned int mask;
+    XQueryPointer(display, data->window, &root, &child, &data->lock_x, &data->lock_y, &winx, &winy, &mask);
+    XDefineCursor(display, data->window, data->blank_cursor);
+  } else if (data->cursor != None) {
+    XDefineCursor(display, data->window, data->cursor);
+  } else {
+    XUndefineCursor(display, data->window);
+  }
+  data->cursor_hidden = (hide != 0);
+  XFlush(display);
+}
+
+// Replaces the window's cursor, which isn't shown until the cursor is unhidden if it's hidden.
+static void SetCursor(OsWindowData* data, Cursor cursor) {
+  if (data->cursor != None) {
+    XFreeCursor(display, data->cursor);
+  }
+  data->cursor = cursor;
+  if (!data->cursor_hidden) {
+    XDefineCursor(display, data->window, cursor);
+  }
+  XFlush(display);
+}
+
+void GlopSetCursorShape(int shape) {
+  if (!windowdata) return;
+  unsigned int glyph = XC_left_ptr;
+  switch (shape) {
+    case glopCursorHand: glyph = XC_hand2; break;
+    case glopCursorIBeam: glyph = XC_xterm; break;
+    case glopCursorCrosshair: glyph = XC_crosshair; break;
+  }
+  SetCursor(windowdata, XCreateFontCursor(display, glyph));
+}
+
+// Makes a cursor from dx*dy RGBA pixels.  Xcursor wants premultiplied ARGB.
+void GlopSetCustomCursor(void* _pixels, int dx, int dy, int hot_x, int hot_y) {
+  if (!windowdata) return;
+  const unsigned char* pixels = (const unsigned char*)_pixels;
+  XcursorImage* image = XcursorImageCreate(dx, dy);
+  if (!image) return;
+  image->xhot = hot_x;
+  image->yhot = hot_y;
+  for (int i = 0; i < dx * dy; i++) {
+    const unsigned char* p = pixels + 4 * i;
+    unsigned int a = p[3];
+    image->pixels[i] = (a << 24) | ((p[0] * a / 255) << 16) | ((p[1] * a / 255) << 8) | (p[2] * a / 255);
+  }
+  SetCursor(windowdata, XcursorImageLoadCursor(display, image));
+  XcursorImageDestroy(image);
+}
+
+// Raw motion is always delivered to the root window, no matter which window has focus.
+static void SelectRawMotion(bool select) {
+  if (xi_opcode == -1) return;
+  unsigned char mask_bits[XIMaskLen(XI_LASTEVENT)] = {0};
+  XIEventMask mask;
+  mask.deviceid = XIAllMasterDevices;
+  mask.mask_len = sizeof(mask_bits);
+  mask.mask = mask_bits;
+  if (select) {
+    XISetMask(mask_bits, XI_RawMotion);
+  }
+  XISelectEvents(display, RootWindow(display, screen), &mask, 1);
+}
+
+void GlopSetRelativeMouseMode(int relative) {
+  if (!windowdata) return;
+  OsWindowData* data = windowdata;
+  if ((relative != 0) == data->relative_mouse) return;
+  if (relative) {
+    data->relative_hid_cursor = !data->cursor_hidden;
+    GlopHideCursor(1);
+    SelectRawMotion(true);
+    if (data->has_focus) {
+      GrabPointer(data);
+    }
+  } else {
+    SelectRawMotion(false);
+    XUngrabPointer(display, CurrentTime);
+    if (data->relative_hid_cursor) {
+      GlopHideCursor(0);
+    }
+  }
+  data->relative_mouse = (relative != 0);
+  XFlush(display);
+}
+
+static void SetTitle(OsWindowData* data, const char* title) {
+  XStoreName(display, data->window, title);
+  // XStoreName is only guaranteed to handle latin-1, _NET_WM_NAME is utf-8.
+  Atom net_wm_name = XInternAtom(display, "_NET_WM_NAME", false);
+  Atom utf8_string = XInternAtom(display, "UTF8_STRING", false);
+  XChangeProperty(display, data->window, net_wm_name, utf8_string, 8, PropModeReplace,
+                  (const unsigned char*)title, strlen(title));
+}
+
+void GlopSetTitle(void* title) {
+  if (!windowdata) return;
+  SetTitle(windowdata, (const char*)title);
+}
+
+// Tells the window manager that the window can't be resized by pinning its
+// minimum and maximum sizes to its current size, or removes that restriction.
+static void SetSizeHints(OsWindowData* data) {
+  XSizeHints hints;
+  hints.flags = 0;
+  if (!data->resizable && !data->fullscreen) {
+    hints.flags      = PMinSize | PMaxSize;
+    hints.min_width  = hints.max_width  = data->width;
+    hints.min_height = hints.max_height = data->height;
+  }
+  XSetWMNormalHints(display, data->window, &hints);
+}
+
+// Asks the window manager to make the window fullscreen, or to restore it.  The
+// window manager remembers the size and position to restore it to.
+void GlopSetFullscreen(int fullscreen) {
+  if (!windowdata) return;
+  OsWindowData* data = windowdata;
+  data->fullscreen = fullscreen != 0;
+  SetSizeHints(data);
+  XEvent event;
+  memset(&event, 0, sizeof(event));
+  event.type = ClientMessage;
+  event.xclient.window = data->window;
+  event.xclient.message_type = XInternAtom(display, "_NET_WM_STATE", false);
+  event.xclient.format = 32;
+  event.xclient.data.l[0] = fullscreen ? 1 : 0;  // _NET_WM_STATE_ADD or _NET_WM_STATE_REMOVE
+  event.xclient.data.l[1] = XInternAtom(display, "_NET_WM_STATE_FULLSCREEN", false);
+  event.xclient.data.l[2] = 0;
+  event.xclient.data.l[3] = 1;  // normal application
+  XSendEvent(display, RootWindow(display, screen), false,
+             SubstructureRedirectMask | SubstructureNotifyMask, &event);
+  XFlush(display);
+}
+
+void GlopResizeWindow(int width, int height) {
+  if (!windowdata) return;
+  windowdata->width = width;
+  windowdata->height = height;
+  SetSizeHints(windowdata);
+  XResizeWindow(display, windowdata->window, width, height);
+  XFlush(display);
+}
+
+void GlopMinimizeWindow() {
+  if (!windowdata) return;
+  XIconifyWindow(display, windowdata->window, screen);
+  XFlush(display);
 }
 
-void GlopSetTitle(OsWindowData* data, const string& title) {
-  XStoreName(display, data->window, title.c_str());
+// Sets the window's icon from dx*dy RGBA pixels.  _NET_WM_ICON wants the width,
+// the height, and then ARGB pixels, each packed into a long.
+void GlopSetIcon(void* _pixels, int dx, int dy) {
+  if (!windowdata) return;
+  const unsigned char* pixels = (const unsigned char*)_pixels;
+  vector<unsigned long> icon(2 + dx * dy);
+  icon[0] = dx;
+  icon[1] = dy;
+  for (int i = 0; i < dx * dy; i++) {
+    const unsigned char* p = pixels + 4 * i;
+    icon[2 + i] = ((unsigned long)p[3] << 24) | ((unsigned long)p[0] << 16) |
+                  ((unsigned long)p[1] << 8) | (unsigned long)p[2];
+  }
+  Atom net_wm_icon = XInternAtom(display, "_NET_WM_ICON", false);
+  XChangeProperty(display, windowdata->window, net_wm_icon, XA_CARDINAL, 32, PropModeReplace,
+                  (const unsigned char*)&icon[0], icon.size());
+  XFlush(display);
 }
 
 void glopSetCurrentContext(OsWindowData* data) {
   glXMakeCurrent(display, data->window, data->context);
 }
 
-void* GlopCreateWindow(void* title, int x, int y, int width, int height) {
+void* GlopCreateWindow(void* title, int x, int y, int width, int height, int resizable, int msaa) {
   OsWindowData *nw = new OsWindowData();
 //  ASSERT(!windowdata);
   windowdata = nw;
+  nw->resizable = resizable != 0;
+  nw->width = width;
+  nw->last_dx = width;
+  nw->height = height;
+  nw->last_dy = height;
      
   // this is bad
   if(x == -1) x = 100;
@@ -389,10 +1146,22 @@ void* GlopCreateWindow(void* title, int x, int y, int width, int height) {
     GLX_DOUBLEBUFFER,
     GLX_DEPTH_SIZE, 1,
     GLX_STENCIL_SIZE, 8,
+    GLX_SAMPLE_BUFFERS, 1,
+    GLX_SAMPLES, msaa,
     None
   };
-  XVisualInfo *vinfo = glXChooseVisual(display, screen, glxcv_params);
+  XVisualInfo *vinfo = NULL;
+  if (msaa > 0) {
+    vinfo = glXChooseVisual(display, screen, glxcv_params);
+  }
+  if (!vinfo) {
+    // Either we didn't want multisampling or it isn't available, so cut the
+    // parameters off before GLX_SAMPLE_BUFFERS.
+    glxcv_params[12] = None;
+    vinfo = glXChooseVisual(display, screen, glxcv_params);
+  }
 //  ASSERT(vinfo);
+  nw->vinfo = *vinfo;
   
   // Define the window attributes
   XSetWindowAttributes attribs;
@@ -405,7 +1174,18 @@ void* GlopCreateWindow(void* title, int x, int y, int width, int height) {
   
 
   nw->window = XCreateWindow(display, RootWindow(display, screen), x, y, width, height, 0, vinfo->depth, InputOutput, vinfo->visual, CWColormap | CWEventMask, &attribs); // I don't know if I need anything further here
-  
+
+  if (xi_opcode != -1) {
+    unsigned char mask_bits[XIMaskLen(XI_LASTEVENT)] = {0};
+    XIEventMask mask;
+    mask.deviceid = XIAllMasterDevices;
+    mask.mask_len = sizeof(mask_bits);
+    mask.mask = mask_bits;
+    XISetMask(mask_bits, XI_TouchBegin);
+    XISetMask(mask_bits, XI_TouchUpdate);
+    XISetMask(mask_bits, XI_TouchEnd);
+    XISelectEvents(display, nw->window, &mask, 1);
+  }
 
   
   {
@@ -448,7 +1228,7 @@ void* GlopCreateWindow(void* title, int x, int y, int width, int height) {
           Hints.Decorations |= MWM_DECOR_BORDER | MWM_DECOR_TITLE | MWM_DECOR_MINIMIZE /*| MWM_DECOR_MENU*/;
           Hints.Functions   |= MWM_FUNC_MOVE | MWM_FUNC_MINIMIZE;
       }
-      if (false)
+      if (resizable)
       {
           Hints.Decorations |= MWM_DECOR_MAXIMIZE | MWM_DECOR_RESIZEH;
           Hints.Functions   |= MWM_FUNC_MAXIMIZE | MWM_FUNC_RESIZE;
@@ -464,19 +1244,15 @@ void* GlopCreateWindow(void* title, int x, int y, int width, int height) {
     }
     
     // This is a hack to force some windows managers to disable resizing
-    if(true)
-    {
-        XSizeHints XSizeHints;
-        XSizeHints.flags      = PMinSize | PMaxSize;
-        XSizeHints.min_width  = XSizeHints.max_width  = width;
-        XSizeHints.min_height = XSizeHints.max_height = height;
-        XSetWMNormalHints(display, nw->window, &XSizeHints); 
-    }
+    SetSizeHints(nw);
   }
 
-  GlopSetTitle(nw, string((char*)(title)));
+  SetTitle(nw, (const char*)title);
   
   XSetWMProtocols(display, nw->window, &close_atom, 1);
+  InitXdnd();
+  XChangeProperty(display, nw->window, xdnd_aware, XA_ATOM, 32, PropModeReplace,
+                  (const unsigned char*)&kXdndVersion, 1);
   // I think in here is where we're meant to set window styles and stuff
   
   nw->inputcontext = XCreateIC(xim, XNInputStyle, XIMPreeditNothing | XIMStatusNothing, XNClientWindow, nw->window, XNFocusWindow, nw->window, NULL);
@@ -559,6 +1335,56 @@ void GlopGetInputEvents(void** _events_ret, void* _num_events, void* _horizon) {
   }
 }
 
+static GlopTextEvent* glop_text_event_buffer = 0;
+static GlopWindowEvent* glop_window_event_buffer = 0;
+
+void GlopGetWindowEvents(void** _events_ret, int* length) {
+  glop_window_event_buffer = (GlopWindowEvent*)realloc(
+      glop_window_event_buffer, sizeof(GlopWindowEvent) * (window_events.size() + 1));
+  for (int i = 0; i < window_events.size(); i++) {
+    glop_window_event_buffer[i] = window_events[i];
+  }
+  *((GlopWindowEvent**)_events_ret) = glop_window_event_buffer;
+  *length = window_events.size();
+  window_events.clear();
+}
+
+static GlopFileDropEvent* glop_file_drop_buffer = 0;
+// The paths that glop_file_drop_buffer points into.
+static vector<FileDrop> returned_file_drops;
+
+void GlopGetFileDropEvents(void** _events_ret, int* length) {
+  returned_file_drops.swap(file_drops);
+  file_drops.clear();
+  glop_file_drop_buffer = (GlopFileDropEvent*)realloc(
+      glop_file_drop_buffer, sizeof(GlopFileDropEvent) * (returned_file_drops.size() + 1));
+  for (int i = 0; i < returned_file_drops.size(); i++) {
+    glop_file_drop_buffer[i].paths = (char*)returned_file_drops[i].paths.data();
+    glop_file_drop_buffer[i].length = returned_file_drops[i].paths.size();
+    glop_file_drop_buffer[i].x = returned_file_drops[i].x;
+    glop_file_drop_buffer[i].y = returned_file_drops[i].y;
+    glop_file_drop_buffer[i].timestamp = returned_file_drops[i].timestamp;
+  }
+  *((GlopFileDropEvent**)_events_ret) = glop_file_drop_buffer;
+  *length = returned_file_drops.size();
+}
+
+void GlopGetTextEvents(void** _events_ret, void* _num_events) {
+  vector<GlopTextEvent> ret;
+  ret.swap(text_events);
+
+  if (glop_text_event_buffer != 0) {
+    free(glop_text_event_buffer);
+  }
+
+  glop_text_event_buffer = (GlopTextEvent*)malloc(sizeof(GlopTextEvent) * ret.size());
+  *((GlopTextEvent**)_events_ret) = glop_text_event_buffer;
+  *((int*)_num_events) = ret.size();
+  for (int i = 0; i < ret.size(); i++) {
+    glop_text_event_buffer[i] = ret[i];
+  }
+}
+
 void GlopGetMousePosition(int* x, int* y) { // TBI
   Window root, child;
   int childx, childy;
@@ -582,12 +1408,168 @@ long long glopGetTimeMicro() {
 }
 
 
+// Returns the dpi of a display that is width_px pixels and width_mm millimeters
+// wide, or 0 if the width in millimeters isn't known.
+static float Dpi(int width_px, unsigned long width_mm) {
+  if (width_mm == 0) return 0;
+  return width_px * 25.4f / width_mm;
+}
+
+static GlopDisplay* glop_display_buffer = 0;
+
+// Lists the displays with RandR, which reports each crtc that is driving an output as a separate
+// display.  Without RandR the whole screen is reported as a single display.
+void GlopGetDisplays(void** _displays_ret, int* length) {
+  vector<GlopDisplay> displays;
+  Window root = RootWindow(display, screen);
+  int event_base, error_base, major = 1, minor = 3;
+  if (XRRQueryExtension(display, &event_base, &error_base) &&
+      XRRQueryVersion(display, &major, &minor) &&
+      (major > 1 || (major == 1 && minor >= 3))) {
+    XRRScreenResources* resources = XRRGetScreenResourcesCurrent(display, root);
+    RROutput primary = XRRGetOutputPrimary(display, root);
+    set<RRCrtc> seen;
+    for (int i = 0; resources && i < resources->noutput; i++) {
+      XRROutputInfo* output = XRRGetOutputInfo(display, resources, resources->outputs[i]);
+      if (!output) continue;
+      // Mirrored outputs share a crtc, they only count as one display.
+      if (output->connection != RR_Connected || output->crtc == 0 || seen.count(output->crtc)) {
+        XRRFreeOutputInfo(output);
+        continue;
+      }
+      seen.insert(output->crtc);
+      XRRCrtcInfo* crtc = XRRGetCrtcInfo(display, resources, output->crtc);
+      if (crtc) {
+        GlopDisplay d;
+        d.x = crtc->x;
+        d.y = crtc->y;
+        d.dx = crtc->width;
+        d.dy = crtc->height;
+        d.dpi = Dpi(crtc->width, output->mm_width);
+        d.refresh_rate = 0;
+        for (int j = 0; j < resources->nmode; j++) {
+          const XRRModeInfo& mode = resources->modes[j];
+          if (mode.id == crtc->mode && mode.hTotal != 0 && mode.vTotal != 0) {
+            d.refresh_rate = (float)mode.dotClock / ((float)mode.hTotal * (float)mode.vTotal);
+          }
+        }
+        d.primary = (resources->outputs[i] == primary);
+        displays.push_back(d);
+        XRRFreeCrtcInfo(crtc);
+      }
+      XRRFreeOutputInfo(output);
+    }
+    if (resources) XRRFreeScreenResources(resources);
+  }
+  if (displays.size() == 0) {
+    GlopDisplay d;
+    d.x = 0;
+    d.y = 0;
+    d.dx = DisplayWidth(display, screen);
+    d.dy = DisplayHeight(display, screen);
+    d.dpi = Dpi(d.dx, DisplayWidthMM(display, screen));
+    d.refresh_rate = 0;
+    d.primary = 1;
+    displays.push_back(d);
+  }
+
+  glop_display_buffer = (GlopDisplay*)realloc(glop_display_buffer, sizeof(GlopDisplay) * displays.size());
+  for (int i = 0; i < displays.size(); i++) {
+    glop_display_buffer[i] = displays[i];
+  }
+  *((GlopDisplay**)_displays_ret) = glop_display_buffer;
+  *length = displays.size();
+}
+
 void GlopSwapBuffers() {
   glXSwapBuffers(display, windowdata->window);
 }
 
-void GlopEnableVSync(int enable) {
-  // TODO: Implement
+void* GlopCreateSharedContext() {
+  if (!windowdata) return NULL;
+  return glXCreateContext(display, &windowdata->vinfo, windowdata->context, True);
+}
+
+// GLX lets contexts on different threads be current on the same drawable, so shared contexts are
+// made current on the window rather than needing a pbuffer of their own.
+int GlopMakeContextCurrent(void* context) {
+  if (context == NULL) {
+    return glXMakeCurrent(display, None, NULL) ? 1 : 0;
+  }
+  if (!windowdata) return 0;
+  return glXMakeCurrent(display, windowdata->window, (GLXContext)context) ? 1 : 0;
+}
+
+void GlopDestroyContext(void* context) {
+  glXDestroyContext(display, (GLXContext)context);
+}
+
+typedef int (*SwapIntervalFunc)(int);
+typedef void (*SwapIntervalEXTFunc)(Display*, GLXDrawable, int);
+
+// Uses whichever swap control extension is available, if any.
+void GlopSetSwapInterval(int interval) {
+  if (!windowdata) return;
+  SwapIntervalEXTFunc swap_ext =
+      (SwapIntervalEXTFunc)glXGetProcAddressARB((const GLubyte*)"glXSwapIntervalEXT");
+  if (swap_ext) {
+    swap_ext(display, windowdata->window, interval);
+    return;
+  }
+  SwapIntervalFunc swap_mesa =
+      (SwapIntervalFunc)glXGetProcAddressARB((const GLubyte*)"glXSwapIntervalMESA");
+  if (swap_mesa) {
+    swap_mesa(interval);
+    return;
+  }
+  // glXSwapIntervalSGI doesn't accept 0, so it can't turn vsync off.
+  SwapIntervalFunc swap_sgi =
+      (SwapIntervalFunc)glXGetProcAddressARB((const GLubyte*)"glXSwapIntervalSGI");
+  if (swap_sgi && interval > 0) {
+    swap_sgi(interval);
+  }
+}
+
+static snd_pcm_t* audio_pcm = NULL;
+
+int GlopOpenAudio(int rate) {
+  if (audio_pcm) return 0;
+  if (snd_pcm_open(&audio_pcm, "default", SND_PCM_STREAM_PLAYBACK, 0) < 0) {
+    audio_pcm = NULL;
+    return 0;
+  }
+  // Lets alsa resample if the device can't play at rate.  50ms of buffering is enough to ride out
+  // the mixer's goroutine not getting scheduled for a little while.
+  if (snd_pcm_set_params(audio_pcm, SND_PCM_FORMAT_FLOAT_LE, SND_PCM_ACCESS_RW_INTERLEAVED, 2,
+                         rate, 1, 50000) < 0) {
+    snd_pcm_close(audio_pcm);
+    audio_pcm = NULL;
+    return 0;
+  }
+  return rate;
+}
+
+int GlopWriteAudio(void* samples, int frames) {
+  if (!audio_pcm) return 0;
+  float* next = (float*)samples;
+  while (frames > 0) {
+    snd_pcm_sframes_t written = snd_pcm_writei(audio_pcm, next, frames);
+    if (written < 0) {
+      // Recovers from underruns and from the system suspending.
+      if (snd_pcm_recover(audio_pcm, written, 1) < 0) return 0;
+      continue;
+    }
+    next += 2 * written;
+    frames -= written;
+  }
+  return 1;
+}
+
+void GlopCloseAudio() {
+  if (!audio_pcm) return;
+  snd_pcm_drop(audio_pcm);
+  snd_pcm_close(audio_pcm);
+  audio_pcm = NULL;
 }
 
 } // extern "C"
@@ -74,14 +74,17 @@
 
 #define kMouseXAxis  300
 #define kMouseYAxis  301
-#define kMouseWheelUp  302
-#define kMouseWheelDown  303
+#define kMouseWheelVertical  302
+#define kMouseWheelHorizontal  303
 #define kMouseLButton  304
 #define kMouseRButton  305
 #define kMouseMButton  306
 
+#define kTouchFinger0  95000
+#define kMaxTouchFingers  10
+
 typedef struct {
-  short index;
+  int index;
   short device;
   float press_amt;
   long long timestamp;
@@ -101,20 +104,96 @@ void GlopClearKeyEvent(GlopKeyEvent* event) {
   event->caps_lock = 0;
 }
 
+typedef struct {
+  int rune;
+  long long timestamp;
+} GlopTextEvent;
+
+// These match gin.WindowEventType.
+#define glopWindowClose     0
+#define glopWindowResize    1
+#define glopWindowFocus     2
+#define glopWindowBlur      3
+#define glopWindowMinimize  4
+#define glopWindowRestore   5
+
+typedef struct {
+  int type;
+  int dx, dy;
+  long long timestamp;
+} GlopWindowEvent;
+
+// paths holds length bytes of nul-terminated, utf-8 paths one after another.
+typedef struct {
+  char* paths;
+  int length;
+  int x, y;
+  long long timestamp;
+} GlopFileDropEvent;
+
+// These match system.CursorShape.
+#define glopCursorArrow      0
+#define glopCursorHand       1
+#define glopCursorIBeam      2
+#define glopCursorCrosshair  3
+
+typedef struct {
+  int x, y, dx, dy;
+  float dpi;
+  float refresh_rate;
+  int primary;
+} GlopDisplay;
+
 void GlopInit();
 void* GlopCreateWindow(
     void* title,
     int x,
     int y,
     int width,
-    int height);
+    int height,
+    int resizable,
+    int msaa);
+void GlopSetTitle(void* title);
+void GlopSetFullscreen(int fullscreen);
+void GlopResizeWindow(int width, int height);
+void GlopMinimizeWindow();
+void GlopSetIcon(void* pixels, int dx, int dy);
 void GlopThink();
 void GlopSwapBuffers();
 
 void GlopGetMousePosition(int* x, int* y);
 void GlopGetWindowDims(int* x, int* y, int* dx, int* dy);
 void GlopGetInputEvents(void** _events_ret, void* _num_events, void* _horizon);
-void GlopEnableVSync(int enable);
+void GlopGetTextEvents(void** _events_ret, void* _num_events);
+void GlopGetWindowEvents(void** _events_ret, int* length);
+void GlopGetFileDropEvents(void** _events_ret, int* length);
+void GlopSetSwapInterval(int interval);
+void GlopGetDisplays(void** _displays_ret, int* length);
+void GlopRun();
+void GlopQuit();
+int GlopHasFocus();
+void GlopHideCursor(int hide);
+void GlopSetRelativeMouseMode(int relative);
+void GlopSetCursorShape(int shape);
+void GlopSetCustomCursor(void* pixels, int dx, int dy, int hot_x, int hot_y);
+void GlopSetClipboard(void* text);
+void GlopGetClipboard(void** _text, int* length);
+
+// Shared contexts share textures and buffers with the window's context, so that they can be made
+// on another thread.  GlopCreateSharedContext() returns NULL if it can't make one.
+// GlopMakeContextCurrent() makes a shared context current on the calling thread, or releases the
+// thread's context if context is NULL, and returns 0 if it fails.
+void* GlopCreateSharedContext();
+int GlopMakeContextCurrent(void* context);
+void GlopDestroyContext(void* context);
+
+// Audio is played from whichever thread calls these, not the render thread.  GlopOpenAudio() opens
+// the default device for interleaved stereo float samples at about rate samples per second, and
+// returns the rate it actually plays at or 0 if it couldn't open it.  GlopWriteAudio() blocks until
+// frames frames from samples have been queued, and returns 0 if the device failed.
+int GlopOpenAudio(int rate);
+int GlopWriteAudio(void* samples, int frames);
+void GlopCloseAudio();
 
 
 /*
@@ -0,0 +1,864 @@
+// +build glop_purego
+
+package gos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/gos/x11"
+	"github.com/runningwild/glop/system"
+	"image"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// This backend talks to the X server directly with the x11 package instead of
+// going through the C glop library and Xlib, so it can be built without cgo.
+// It is an input-only backend: creating a GL context needs libGL or libEGL, so
+// it never makes one.  SwapBuffers() and SetSwapInterval() do nothing,
+// CreateSharedContext() always fails, and nothing drawn with render will show
+// up, use render.Null or render.Software with it.  It is meant for
+// tools and tests that need a window and input but not drawing.
+//
+// TODO: Set up a GL context through EGL, loading libEGL with dlopen so that
+// cgo still isn't needed, and then SwapBuffers(), SetSwapInterval() and
+// CreateSharedContext() can be implemented for real.
+type linuxSystemObject struct {
+	conn   *x11.Conn
+	window x11.Window
+	atoms  map[string]x11.Atom
+
+	// keysyms[keycode] is the list of keysyms for that keycode.
+	keysyms [][]uint32
+
+	horizon       int64
+	events        []gin.OsEvent
+	text_events   []gin.TextEvent
+	window_events []gin.WindowEvent
+	file_drops    []gin.FileDropEvent
+
+	// The window that the current drag is coming from, or 0, and where in root
+	// coordinates the drag was the last time it moved.
+	xdnd_source    x11.Window
+	xdnd_x, xdnd_y int
+
+	resizable, fullscreen bool
+	width, height         int
+	has_focus             bool
+	is_minimized          bool
+	last_dx, last_dy      int
+
+	cursor_hidden  bool
+	blank_cursor   uint32
+	lock_x, lock_y int
+
+	// The cursor set by SetCursorShape() or SetCustomCursor(), 0 for the default.
+	cursor uint32
+
+	// See SetRelativeMouseMode().
+	relative_mouse      bool
+	relative_hid_cursor bool
+
+	clipboard_text   string
+	clipboard_owned  bool
+	selection_notify chan x11.Event
+
+	// Run() waits on wake for events to arrive, quit is set by Quit().
+	wake chan struct{}
+	quit int32
+}
+
+var linux_system_object linuxSystemObject
+
+// The atoms that we use, they are all interned in Startup().
+var atom_names = []string{
+	"WM_PROTOCOLS", "WM_DELETE_WINDOW", "WM_CHANGE_STATE", "_NET_WM_NAME", "_NET_WM_ICON",
+	"_NET_WM_STATE", "_NET_WM_STATE_FULLSCREEN", "UTF8_STRING", "CLIPBOARD", "TARGETS",
+	"GLOP_CLIPBOARD", "INCR", "XdndAware", "XdndEnter", "XdndPosition", "XdndStatus",
+	"XdndLeave", "XdndDrop", "XdndFinished", "XdndSelection", "XdndActionCopy", "text/uri-list",
+	"GLOP_DROP",
+}
+
+// The version of the XDND drag and drop protocol that we speak.
+const xdndVersion = 5
+
+// Call after runtime.LockOSThread(), *NOT* in an init function
+func (linux *linuxSystemObject) Startup() {
+	conn, err := x11.Dial("")
+	if err != nil {
+		panic(err)
+	}
+	linux.conn = conn
+	linux.atoms = make(map[string]x11.Atom)
+	for _, name := range atom_names {
+		atom, err := conn.InternAtom(name)
+		if err != nil {
+			panic(err)
+		}
+		linux.atoms[name] = atom
+	}
+	linux.keysyms, err = conn.GetKeyboardMapping()
+	if err != nil {
+		panic(err)
+	}
+	linux.has_focus = true
+	linux.selection_notify = make(chan x11.Event, 1)
+	linux.wake = make(chan struct{}, 1)
+	conn.SetEventFilter(linux.filterEvent)
+	startJoysticks()
+}
+
+func GetSystemInterface() system.Os {
+	return &linux_system_object
+}
+
+// Called by the x11 package as each event arrives.  Wakes up Run(), and hands
+// SelectionNotify events straight to GetClipboardString(), which is waiting on
+// them.  Dropped files also come in a SelectionNotify, those go to Think().
+func (linux *linuxSystemObject) filterEvent(event x11.Event) bool {
+	select {
+	case linux.wake <- struct{}{}:
+	default:
+	}
+	if event.Code() == x11.SelectionNotify && x11.Atom(le32(event.Data[12:])) != linux.atoms["XdndSelection"] {
+		select {
+		case linux.selection_notify <- event:
+		default:
+		}
+		return false
+	}
+	return true
+}
+
+// Run processes window events as they arrive until Quit() is called.
+func (linux *linuxSystemObject) Run() {
+	atomic.StoreInt32(&linux.quit, 0)
+	for atomic.LoadInt32(&linux.quit) == 0 {
+		<-linux.wake
+		linux.Think()
+	}
+}
+
+// Quit makes Run() return, it may be called from any goroutine.
+func (linux *linuxSystemObject) Quit() {
+	atomic.StoreInt32(&linux.quit, 1)
+	select {
+	case linux.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Without RandR all we know about is the screen, so it is reported as a single
+// display.
+func (linux *linuxSystemObject) GetDisplays() []system.Display {
+	screen := linux.conn.Screen
+	display := system.Display{
+		Dx:      screen.Width,
+		Dy:      screen.Height,
+		Primary: true,
+	}
+	if screen.WidthMm > 0 {
+		display.DPI = float64(screen.Width) * 25.4 / float64(screen.WidthMm)
+	}
+	return []system.Display{display}
+}
+
+func (linux *linuxSystemObject) CreateWindowEx(opts system.WindowOpts) {
+	mask := uint32(x11.KeyPressMask | x11.KeyReleaseMask | x11.ButtonPressMask |
+		x11.ButtonReleaseMask | x11.PointerMotionMask | x11.StructureNotifyMask |
+		x11.FocusChangeMask)
+	linux.window = linux.conn.CreateWindow(opts.X, opts.Y, opts.Dx, opts.Dy, mask)
+	linux.resizable = opts.Resizable
+	linux.width, linux.height = opts.Dx, opts.Dy
+	linux.conn.ChangeProperty32(linux.window, linux.atoms["WM_PROTOCOLS"], x11.AtomAtom,
+		[]uint32{uint32(linux.atoms["WM_DELETE_WINDOW"])})
+	linux.conn.ChangeProperty32(linux.window, linux.atoms["XdndAware"], x11.AtomAtom, []uint32{xdndVersion})
+	linux.setSizeHints()
+	linux.SetTitle(opts.Title)
+	if opts.Icon != nil {
+		pix, dx, dy := iconPixels(opts.Icon)
+		icon := make([]uint32, 2+dx*dy)
+		icon[0], icon[1] = uint32(dx), uint32(dy)
+		for i := 0; i < dx*dy; i++ {
+			p := pix[4*i:]
+			icon[2+i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		}
+		linux.conn.ChangeProperty32(linux.window, linux.atoms["_NET_WM_ICON"], x11.AtomCardinal, icon)
+	}
+	linux.conn.MapWindow(linux.window)
+	if opts.Fullscreen {
+		linux.SetFullscreen(true)
+	}
+}
+
+func (linux *linuxSystemObject) SetTitle(title string) {
+	if linux.window == 0 {
+		return
+	}
+	linux.conn.ChangeProperty(linux.window, x11.AtomWmName, x11.AtomString, 8, []byte(title))
+	linux.conn.ChangeProperty(linux.window, linux.atoms["_NET_WM_NAME"], linux.atoms["UTF8_STRING"], 8, []byte(title))
+}
+
+// Tells the window manager that the window can't be resized by pinning its
+// minimum and maximum sizes to its current size, or removes that restriction.
+func (linux *linuxSystemObject) setSizeHints() {
+	hints := make([]uint32, 18)
+	if !linux.resizable && !linux.fullscreen {
+		hints[0] = 16 | 32 // PMinSize | PMaxSize
+		hints[5], hints[6] = uint32(linux.width), uint32(linux.height)
+		hints[7], hints[8] = uint32(linux.width), uint32(linux.height)
+	}
+	linux.conn.ChangeProperty32(linux.window, x11.AtomWmNormalHints, x11.AtomWmSizeHints, hints)
+}
+
+func (linux *linuxSystemObject) SetFullscreen(fullscreen bool) {
+	if linux.window == 0 {
+		return
+	}
+	linux.fullscreen = fullscreen
+	linux.setSizeHints()
+	var action uint32 // _NET_WM_STATE_REMOVE
+	if fullscreen {
+		action = 1 // _NET_WM_STATE_ADD
+	}
+	linux.conn.SendClientMessage(linux.window, linux.atoms["_NET_WM_STATE"],
+		[5]uint32{action, uint32(linux.atoms["_NET_WM_STATE_FULLSCREEN"]), 0, 1, 0})
+}
+
+func (linux *linuxSystemObject) Resize(width, height int) {
+	if linux.window == 0 {
+		return
+	}
+	linux.width, linux.height = width, height
+	linux.setSizeHints()
+	linux.conn.ResizeWindow(linux.window, width, height)
+}
+
+func (linux *linuxSystemObject) Minimize() {
+	if linux.window == 0 {
+		return
+	}
+	// This is what XIconifyWindow() does, 3 is IconicState.
+	linux.conn.SendClientMessage(linux.window, linux.atoms["WM_CHANGE_STATE"], [5]uint32{3})
+}
+
+// There is no GL context to swap or sync yet, see the TODO on
+// linuxSystemObject.
+func (linux *linuxSystemObject) SwapBuffers() {}
+
+func (linux *linuxSystemObject) SetSwapInterval(n int) {}
+
+func milliseconds(t time.Time) int64 {
+	return t.UnixNano() / 1e6
+}
+
+func (linux *linuxSystemObject) addWindowEvent(event_type gin.WindowEventType, dx, dy int, timestamp int64) {
+	linux.window_events = append(linux.window_events, gin.WindowEvent{
+		Type:      event_type,
+		Dx:        dx,
+		Dy:        dy,
+		Timestamp: timestamp,
+	})
+}
+
+func (linux *linuxSystemObject) Think() {
+	if linux.window == 0 {
+		return
+	}
+	events := linux.conn.Events()
+	for i := 0; i < len(events); i++ {
+		event := events[i]
+		data := event.Data
+		timestamp := milliseconds(event.Read)
+		switch event.Code() {
+		case x11.KeyPress, x11.KeyRelease:
+			// Holding a key down makes X send a release and a press with the same
+			// time for every repeat, we want to ignore both of them.
+			if event.Code() == x11.KeyRelease && i+1 < len(events) {
+				next := events[i+1].Data
+				if next[0]&0x7f == x11.KeyPress && next[1] == data[1] && string(next[4:8]) == string(data[4:8]) {
+					i++
+					continue
+				}
+			}
+			linux.synthKey(data, timestamp)
+
+		case x11.ButtonPress, x11.ButtonRelease:
+			linux.synthButton(data, timestamp)
+
+		case x11.MotionNotify:
+			root_x, root_y := int(int16(le16(data[20:]))), int(int16(le16(data[22:])))
+			// This is the motion from warping a hidden cursor back to where it was
+			// locked.
+			if linux.cursor_hidden && root_x == linux.lock_x && root_y == linux.lock_y {
+				break
+			}
+			// Without XInput2 the best we can do in relative mode is report how far
+			// the cursor moved from where it is locked.
+			if linux.relative_mouse {
+				linux.addMouseEvent(gin.MouseXAxis, float64(root_x-linux.lock_x), linux.lock_x, linux.lock_y, timestamp)
+				linux.addMouseEvent(gin.MouseYAxis, float64(root_y-linux.lock_y), linux.lock_x, linux.lock_y, timestamp)
+				break
+			}
+			x, y := int(int16(le16(data[24:]))), int(int16(le16(data[26:])))
+			linux.addMouseEvent(gin.MouseXAxis, float64(x), root_x, root_y, timestamp)
+			linux.addMouseEvent(gin.MouseYAxis, float64(y), root_x, root_y, timestamp)
+
+		// Keyboard grabs, like the window manager's alt-tab, generate focus events
+		// with NotifyGrab and NotifyUngrab modes, but they don't change which
+		// window has focus.
+		case x11.FocusIn:
+			if mode := data[8]; mode != 1 && mode != 2 && !linux.has_focus {
+				linux.has_focus = true
+				linux.addWindowEvent(gin.WindowFocus, 0, 0, timestamp)
+				if linux.relative_mouse {
+					linux.grabPointer()
+				}
+			}
+
+		case x11.FocusOut:
+			// A detail of 2 is NotifyInferior.
+			if mode := data[8]; mode != 1 && mode != 2 && data[1] != 2 && linux.has_focus {
+				linux.has_focus = false
+				linux.addWindowEvent(gin.WindowBlur, 0, 0, timestamp)
+				if linux.relative_mouse {
+					linux.conn.UngrabPointer()
+				}
+			}
+
+		case x11.ConfigureNotify:
+			dx, dy := int(le16(data[20:])), int(le16(data[22:]))
+			if dx != linux.last_dx || dy != linux.last_dy {
+				linux.last_dx, linux.last_dy = dx, dy
+				linux.addWindowEvent(gin.WindowResize, dx, dy, timestamp)
+			}
+
+		// Window managers unmap windows when they are iconified.
+		case x11.UnmapNotify:
+			if !linux.is_minimized {
+				linux.is_minimized = true
+				linux.addWindowEvent(gin.WindowMinimize, 0, 0, timestamp)
+			}
+
+		case x11.MapNotify:
+			if linux.is_minimized {
+				linux.is_minimized = false
+				linux.addWindowEvent(gin.WindowRestore, 0, 0, timestamp)
+			}
+
+		case x11.SelectionRequest:
+			linux.answerSelectionRequest(data)
+
+		case x11.SelectionClear:
+			linux.clipboard_owned = false
+
+		case x11.ClientMessage:
+			// The window stays open, it's up to the application to decide what to do.
+			if data[1] == 32 && x11.Atom(le32(data[12:])) == linux.atoms["WM_DELETE_WINDOW"] {
+				linux.addWindowEvent(gin.WindowClose, 0, 0, timestamp)
+			}
+			linux.handleXdndMessage(data)
+
+		case x11.SelectionNotify:
+			linux.finishXdnd(data, timestamp)
+		}
+	}
+
+	if linux.cursor_hidden && linux.has_focus {
+		linux.conn.WarpPointer(linux.lock_x, linux.lock_y)
+	}
+}
+
+// The x11 package talks to the server in little endian.
+func le16(b []byte) uint16 {
+	return binary.LittleEndian.Uint16(b)
+}
+
+func le32(b []byte) uint32 {
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (linux *linuxSystemObject) addMouseEvent(index gin.KeyIndex, press_amt float64, root_x, root_y int, timestamp int64) {
+	linux.events = append(linux.events, gin.OsEvent{
+		KeyId: gin.KeyId{
+			Device: gin.DeviceId{Index: systemDeviceIndex, Type: gin.DeviceTypeMouse},
+			Index:  index,
+		},
+		Press_amt:  press_amt,
+		Timestamp:  timestamp,
+		Has_cursor: true,
+	})
+	event := &linux.events[len(linux.events)-1]
+	event.Cursor_x, event.Cursor_y = linux.rawCursorToWindowCoords(root_x, root_y)
+}
+
+// Returns the keysym in the given column for a key event, falling back to the
+// first column if that one is empty.
+func (linux *linuxSystemObject) keysym(keycode byte, column int) uint32 {
+	if int(keycode) >= len(linux.keysyms) {
+		return 0
+	}
+	syms := linux.keysyms[keycode]
+	if column < len(syms) && syms[column] != 0 {
+		return syms[column]
+	}
+	if len(syms) > 0 {
+		return syms[0]
+	}
+	return 0
+}
+
+// Keysyms for keys that aren't printable characters.
+var keysym_keys = map[uint32]gin.KeyIndex{
+	0xffbe: gin.F1, 0xffbf: gin.F2, 0xffc0: gin.F3, 0xffc1: gin.F4,
+	0xffc2: gin.F5, 0xffc3: gin.F6, 0xffc4: gin.F7, 0xffc5: gin.F8,
+	0xffc6: gin.F9, 0xffc7: gin.F10, 0xffc8: gin.F11, 0xffc9: gin.F12,
+
+	0xffb0: gin.KeyPad0, 0xffb1: gin.KeyPad1, 0xffb2: gin.KeyPad2, 0xffb3: gin.KeyPad3,
+	0xffb4: gin.KeyPad4, 0xffb5: gin.KeyPad5, 0xffb6: gin.KeyPad6, 0xffb7: gin.KeyPad7,
+	0xffb8: gin.KeyPad8, 0xffb9: gin.KeyPad9,
+
+	0xff51: gin.Left, 0xff53: gin.Right, 0xff52: gin.Up, 0xff54: gin.Down,
+
+	0xff08: gin.Backspace, 0xff09: gin.Tab, 0xff8d: gin.KeyPadEnter, 0xff0d: gin.Return,
+	0xff1b: gin.Escape,
+
+	0xffe1: gin.LeftShift, 0xffe2: gin.RightShift, 0xffe3: gin.LeftControl,
+	0xffe4: gin.RightControl, 0xffe9: gin.LeftAlt, 0xffea: gin.RightAlt,
+	0xffeb: gin.LeftGui, 0xffec: gin.RightGui,
+
+	0xffaf: gin.KeyPadDivide, 0xffaa: gin.KeyPadMultiply, 0xffad: gin.KeyPadSubtract,
+	0xffab: gin.KeyPadAdd,
+
+	0xfe50: '`', 0xfe51: '\'',
+}
+
+// Returns the gin key for a keysym, or 0 if there isn't one.  This covers the
+// same keys as SynthKey() in the C backend.
+func keysymToKey(sym uint32) gin.KeyIndex {
+	switch {
+	case sym >= 'a' && sym <= 'z', sym >= '0' && sym <= '9':
+		return gin.KeyIndex(sym)
+	case sym >= 'A' && sym <= 'Z':
+		return gin.KeyIndex(sym - 'A' + 'a')
+	}
+	switch sym {
+	case '`', '-', '=', '[', ']', '\\', ';', '\'', ',', '.', '/', ' ':
+		return gin.KeyIndex(sym)
+	}
+	return keysym_keys[sym]
+}
+
+// Returns the character typed by a keysym, or -1 if it isn't one.  Keysyms for
+// latin-1 characters are the same as the character, and other unicode
+// characters are 0x01000000 plus the code point.
+func keysymToRune(sym uint32) rune {
+	switch {
+	case sym >= 0x20 && sym <= 0x7e, sym >= 0xa0 && sym <= 0xff:
+		return rune(sym)
+	case sym&0xff000000 == 0x01000000:
+		if r := rune(sym & 0x00ffffff); utf8.ValidRune(r) {
+			return r
+		}
+	}
+	return -1
+}
+
+func (linux *linuxSystemObject) synthKey(data []byte, timestamp int64) {
+	keycode := data[1]
+	state := le16(data[28:])
+	pressed := data[0]&0x7f == x11.KeyPress
+	if key := keysymToKey(linux.keysym(keycode, 0)); key != 0 {
+		var press_amt float64
+		if pressed {
+			press_amt = 1
+		}
+		linux.events = append(linux.events, gin.OsEvent{
+			KeyId: gin.KeyId{
+				Device: gin.DeviceId{Index: systemDeviceIndex, Type: gin.DeviceTypeKeyboard},
+				Index:  key,
+			},
+			Press_amt: press_amt,
+			Timestamp: timestamp,
+		})
+	}
+
+	// There's no input method here, so text is just the shifted or unshifted
+	// keysym.  Nothing is typed while control is held.
+	if !pressed || state&0x4 != 0 {
+		return
+	}
+	column := 0
+	shift, caps_lock := state&0x1 != 0, state&0x2 != 0
+	sym := linux.keysym(keycode, 0)
+	if shift != (caps_lock && sym >= 'a' && sym <= 'z') {
+		column = 1
+	}
+	if r := keysymToRune(linux.keysym(keycode, column)); r != -1 {
+		linux.text_events = append(linux.text_events, gin.TextEvent{Rune: r, Timestamp: timestamp})
+	}
+}
+
+func (linux *linuxSystemObject) synthButton(data []byte, timestamp int64) {
+	button := data[1]
+	pressed := data[0]&0x7f == x11.ButtonPress
+	var index gin.KeyIndex
+	var press_amt float64
+	if pressed {
+		press_amt = 1
+	}
+	switch button {
+	case 1:
+		index = gin.MouseLButton
+	case 2:
+		index = gin.MouseMButton
+	case 3:
+		index = gin.MouseRButton
+	case 4, 5, 6, 7:
+		// X reports each click of a mouse wheel as a press and release of a
+		// button, 4 and 5 are up and down, 6 and 7 are left and right.  We only
+		// want one event per click.
+		if !pressed {
+			return
+		}
+		index = gin.MouseWheelVertical
+		if button >= 6 {
+			index = gin.MouseWheelHorizontal
+		}
+		press_amt = -1
+		if button == 4 || button == 7 {
+			press_amt = 1
+		}
+	default:
+		return
+	}
+	root_x, root_y := int(int16(le16(data[20:]))), int(int16(le16(data[22:])))
+	linux.addMouseEvent(index, press_amt, root_x, root_y, timestamp)
+}
+
+// Events don't need to be sorted, and may have timestamps from before the
+// previous horizon, gin.Input.Think() takes care of both.
+func (linux *linuxSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
+	linux.horizon = milliseconds(time.Now())
+	events := linux.events
+	linux.events = nil
+	events = append(events, joystickEvents(linux.horizon)...)
+	sort.Sort(osEventSlice(events))
+	return events, linux.horizon
+}
+
+func (linux *linuxSystemObject) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
+	return systemDevices(activeJoysticks())
+}
+
+func (linux *linuxSystemObject) GetTextEvents() []gin.TextEvent {
+	events := linux.text_events
+	linux.text_events = nil
+	return events
+}
+
+func (linux *linuxSystemObject) GetWindowEvents() []gin.WindowEvent {
+	events := linux.window_events
+	linux.window_events = nil
+	return events
+}
+
+func (linux *linuxSystemObject) GetFileDropEvents() []gin.FileDropEvent {
+	drops := linux.file_drops
+	linux.file_drops = nil
+	return drops
+}
+
+// Handles the ClientMessages that make up a drag onto the window, see
+// freedesktop.org's XDND spec.  We accept anything and ask for a
+// text/uri-list when the drag is dropped.
+func (linux *linuxSystemObject) handleXdndMessage(data []byte) {
+	typ := x11.Atom(le32(data[8:]))
+	source := x11.Window(le32(data[12:]))
+	switch typ {
+	case linux.atoms["XdndEnter"]:
+		linux.xdnd_source = source
+	case linux.atoms["XdndPosition"]:
+		linux.xdnd_source = source
+		position := le32(data[20:])
+		linux.xdnd_x, linux.xdnd_y = int(position>>16), int(position&0xffff)
+		linux.conn.SendClientMessageTo(source, source, linux.atoms["XdndStatus"],
+			[5]uint32{uint32(linux.window), 1, 0, 0, uint32(linux.atoms["XdndActionCopy"])})
+	case linux.atoms["XdndLeave"]:
+		linux.xdnd_source = 0
+	case linux.atoms["XdndDrop"]:
+		linux.xdnd_source = source
+		linux.conn.ConvertSelection(linux.window, linux.atoms["XdndSelection"], linux.atoms["text/uri-list"],
+			linux.atoms["GLOP_DROP"], le32(data[20:]))
+	}
+}
+
+// Called with the SelectionNotify that answers the ConvertSelection() in
+// handleXdndMessage().
+func (linux *linuxSystemObject) finishXdnd(data []byte, timestamp int64) {
+	var paths []string
+	if x11.Atom(le32(data[20:])) != x11.AtomNone {
+		_, format, list, err := linux.conn.GetProperty(linux.window, linux.atoms["GLOP_DROP"], true)
+		if err == nil && format == 8 {
+			paths = pathsFromUriList(string(list))
+		}
+	}
+	if len(paths) > 0 {
+		x, y := linux.rawCursorToWindowCoords(linux.xdnd_x, linux.xdnd_y)
+		linux.file_drops = append(linux.file_drops, gin.FileDropEvent{
+			Paths:     paths,
+			X:         x,
+			Y:         y,
+			Timestamp: timestamp,
+		})
+	}
+	if linux.xdnd_source != 0 {
+		var accepted, action uint32
+		if len(paths) > 0 {
+			accepted, action = 1, uint32(linux.atoms["XdndActionCopy"])
+		}
+		linux.conn.SendClientMessageTo(linux.xdnd_source, linux.xdnd_source, linux.atoms["XdndFinished"],
+			[5]uint32{uint32(linux.window), accepted, action, 0, 0})
+	}
+	linux.xdnd_source = 0
+}
+
+// Returns the local files in a text/uri-list, which has one uri per line.
+func pathsFromUriList(list string) []string {
+	var paths []string
+	for _, line := range strings.Split(list, "\n") {
+		u, err := url.Parse(strings.TrimRight(line, "\r"))
+		if err != nil || u.Scheme != "file" || u.Path == "" {
+			continue
+		}
+		paths = append(paths, u.Path)
+	}
+	return paths
+}
+
+func (linux *linuxSystemObject) answerSelectionRequest(data []byte) {
+	requestor := x11.Window(le32(data[12:]))
+	target := x11.Atom(le32(data[20:]))
+	property := x11.Atom(le32(data[24:]))
+	// Obsolete clients may not specify a property, in which case the target is
+	// used instead.
+	if property == x11.AtomNone {
+		property = target
+	}
+	utf8_string := linux.atoms["UTF8_STRING"]
+	reply_property := x11.AtomNone
+	switch {
+	case linux.clipboard_owned && target == linux.atoms["TARGETS"]:
+		supported := []uint32{uint32(linux.atoms["TARGETS"]), uint32(utf8_string), uint32(x11.AtomString)}
+		linux.conn.ChangeProperty32(requestor, property, x11.AtomAtom, supported)
+		reply_property = property
+	case linux.clipboard_owned && (target == utf8_string || target == x11.AtomString):
+		linux.conn.ChangeProperty(requestor, property, target, 8, []byte(linux.clipboard_text))
+		reply_property = property
+	}
+	// The time, requestor, selection, and target are the same as the request.
+	reply := make([]byte, 32)
+	reply[0] = x11.SelectionNotify
+	copy(reply[4:8], data[4:8])
+	copy(reply[8:20], data[12:24])
+	binary.LittleEndian.PutUint32(reply[20:], uint32(reply_property))
+	linux.conn.SendEvent(requestor, 0, reply)
+}
+
+// Asks the owner of the CLIPBOARD selection for its contents as utf-8 and waits
+// up to a second for them to arrive.  Large selections that are sent
+// incrementally with INCR aren't supported.
+func (linux *linuxSystemObject) GetClipboardString() string {
+	if linux.clipboard_owned {
+		return linux.clipboard_text
+	}
+	if linux.window == 0 {
+		return ""
+	}
+	select {
+	case <-linux.selection_notify:
+	default:
+	}
+	property := linux.atoms["GLOP_CLIPBOARD"]
+	linux.conn.ConvertSelection(linux.window, linux.atoms["CLIPBOARD"], linux.atoms["UTF8_STRING"], property, 0)
+	var event x11.Event
+	select {
+	case event = <-linux.selection_notify:
+	case <-time.After(time.Second):
+		return ""
+	}
+	if x11.Atom(le32(event.Data[20:])) == x11.AtomNone {
+		return ""
+	}
+	typ, format, data, err := linux.conn.GetProperty(linux.window, property, true)
+	if err != nil || format != 8 || typ == linux.atoms["INCR"] {
+		return ""
+	}
+	return string(data)
+}
+
+// Takes ownership of the CLIPBOARD selection, other programs get the text
+// through answerSelectionRequest() when Think() sees them asking for it.
+func (linux *linuxSystemObject) SetClipboardString(s string) {
+	if linux.window == 0 {
+		return
+	}
+	linux.clipboard_text = s
+	linux.conn.SetSelectionOwner(linux.window, linux.atoms["CLIPBOARD"])
+	linux.clipboard_owned = true
+}
+
+// Hides the cursor and locks it where it is, see Think().
+func (linux *linuxSystemObject) HideCursor(hide bool) {
+	if linux.window == 0 || hide == linux.cursor_hidden {
+		return
+	}
+	if hide {
+		if linux.blank_cursor == 0 {
+			linux.blank_cursor = linux.conn.BlankCursor(linux.window)
+		}
+		x, y, _, _, err := linux.conn.QueryPointer(linux.window)
+		if err != nil {
+			return
+		}
+		linux.lock_x, linux.lock_y = x, y
+		linux.conn.SetCursor(linux.window, linux.blank_cursor)
+	} else {
+		linux.conn.SetCursor(linux.window, linux.cursor)
+	}
+	linux.cursor_hidden = hide
+}
+
+func (linux *linuxSystemObject) grabPointer() {
+	linux.conn.GrabPointer(linux.window, x11.ButtonPressMask|x11.ButtonReleaseMask|x11.PointerMotionMask)
+}
+
+// Relative mode hides and locks the cursor like HideCursor(), and grabs the
+// pointer so that it stays in the window.
+func (linux *linuxSystemObject) SetRelativeMouseMode(relative bool) {
+	if linux.window == 0 || relative == linux.relative_mouse {
+		return
+	}
+	if relative {
+		linux.relative_hid_cursor = !linux.cursor_hidden
+		linux.HideCursor(true)
+		if linux.has_focus {
+			linux.grabPointer()
+		}
+	} else {
+		linux.conn.UngrabPointer()
+		if linux.relative_hid_cursor {
+			linux.HideCursor(false)
+		}
+	}
+	linux.relative_mouse = relative
+}
+
+// Replaces the window's cursor, which isn't shown until the cursor is unhidden
+// if it's hidden.
+func (linux *linuxSystemObject) setCursor(cursor uint32) {
+	if linux.cursor != 0 {
+		linux.conn.FreeCursor(linux.cursor)
+	}
+	linux.cursor = cursor
+	if !linux.cursor_hidden {
+		linux.conn.SetCursor(linux.window, cursor)
+	}
+}
+
+func (linux *linuxSystemObject) SetCursorShape(shape system.CursorShape) {
+	if linux.window == 0 {
+		return
+	}
+	// These are XC_left_ptr, XC_hand2, XC_xterm and XC_crosshair.
+	glyph := uint16(68)
+	switch shape {
+	case system.CursorHand:
+		glyph = 60
+	case system.CursorIBeam:
+		glyph = 152
+	case system.CursorCrosshair:
+		glyph = 34
+	}
+	linux.setCursor(linux.conn.FontCursor(glyph))
+}
+
+// Without the RENDER extension cursors only have two colors, so each pixel is
+// either transparent, black, or white.
+func (linux *linuxSystemObject) SetCustomCursor(im image.Image, hot_x, hot_y int) {
+	if linux.window == 0 {
+		return
+	}
+	pix, dx, dy := iconPixels(im)
+	if len(pix) == 0 {
+		return
+	}
+	source := make([]bool, dx*dy)
+	mask := make([]bool, dx*dy)
+	for i := range mask {
+		p := pix[4*i:]
+		mask[i] = p[3] >= 128
+		source[i] = mask[i] && int(p[0])+int(p[1])+int(p[2]) < 3*128
+	}
+	black, white := [3]uint16{}, [3]uint16{0xffff, 0xffff, 0xffff}
+	linux.setCursor(linux.conn.BitmapCursor(linux.window, dx, dy, source, mask, black, white, hot_x, hot_y))
+}
+
+func (linux *linuxSystemObject) rawCursorToWindowCoords(x, y int) (int, int) {
+	wx, wy, _, _ := linux.GetWindowDims()
+	return x - wx, y - wy
+}
+
+func (linux *linuxSystemObject) GetCursorPos() (int, int) {
+	if linux.window == 0 {
+		return 0, 0
+	}
+	_, _, x, y, _ := linux.conn.QueryPointer(linux.window)
+	return x, y
+}
+
+func (linux *linuxSystemObject) GetWindowDims() (int, int, int, int) {
+	if linux.window == 0 {
+		return 0, 0, 0, 0
+	}
+	x, y, _ := linux.conn.RootPosition(linux.window)
+	dx, dy, _ := linux.conn.GetGeometry(linux.window)
+	return x, y, dx, dy
+}
+
+// Windows are always the same size in pixels as they are in the coordinates
+// of the display.
+func (linux *linuxSystemObject) GetBackingScale() float64 {
+	return 1
+}
+
+func (linux *linuxSystemObject) SetRumble(device gin.DeviceId, low, high float64, duration_ms int) {
+	setJoystickRumble(device, low, high, duration_ms)
+}
+
+func (linux *linuxSystemObject) HasFocus() bool {
+	return linux.window != 0 && linux.has_focus
+}
+
+// This backend is input-only and has no GL context to share.
+func (linux *linuxSystemObject) CreateSharedContext() (system.SharedContext, error) {
+	return nil, fmt.Errorf("The purego backend is input-only and has no GL context.")
+}
+
+// This backend has no audio, OpenAudio() always fails so callers can fall back
+// on sound.NullOutput.
+func openAudio(rate int) int {
+	return 0
+}
+
+func writeAudio(samples unsafe.Pointer, frames int) bool {
+	return false
+}
+
+func closeAudio() {}
@@ -0,0 +1,151 @@
+package gos
+
+import (
+	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/glog"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Joysticks are read with the joystick api, but force feedback is only in the
+// evdev api, so rumble is sent to the evdev device for the same joystick,
+// which is next to it in /dev/input/by-path.  Like the joysticks this is
+// shared by both linux backends.
+
+// struct ff_effect from linux/input.h, with only the rumble member of its
+// union.  The padding makes the union as big as its largest member,
+// ff_periodic_effect, which ends in a pointer.
+type ffEffect struct {
+	Type            uint16
+	Id              int16
+	Direction       uint16
+	TriggerButton   uint16
+	TriggerInterval uint16
+	ReplayLength    uint16
+	ReplayDelay     uint16
+	Rumble          struct {
+		StrongMagnitude uint16
+		WeakMagnitude   uint16
+		_               [20]byte
+		_               uintptr
+	}
+}
+
+// struct input_event from linux/input.h.
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+const (
+	evFF     = 0x15
+	ffRumble = 0x50
+
+	// _IOW('E', 0x80, struct ff_effect)
+	eviocsff = 1<<30 | uintptr(unsafe.Sizeof(ffEffect{}))<<16 | 'E'<<8 | 0x80
+)
+
+// The evdev device that rumbles a joystick, and the effect that has been
+// uploaded to it.
+type rumbler struct {
+	f  *os.File
+	id int16
+}
+
+// Maps the index of each joystick that has been rumbled to its rumbler, or to
+// nil if it can't be rumbled.  Protected by jsMutex.
+var jsRumblers = make(map[gin.DeviceIndex]*rumbler)
+
+// Returns the rumbler for the joystick with the given index, or nil if it
+// can't be rumbled.  jsMutex must be held.
+func rumblerFor(index gin.DeviceIndex) *rumbler {
+	if r, ok := jsRumblers[index]; ok {
+		return r
+	}
+	var name string
+	for n, i := range jsActive {
+		if i == index {
+			name = n
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	// e.g. pci-0000:00:14.0-usb-0:2:1.0-joystick and
+	// pci-0000:00:14.0-usb-0:2:1.0-event-joystick
+	event := strings.TrimSuffix(name, "joystick") + "event-joystick"
+	f, err := os.OpenFile("/dev/input/by-path/"+event, os.O_RDWR, 0)
+	if err != nil {
+		glog.Infof("Joystick %d (%s) can't rumble: %v", index, name, err)
+		jsRumblers[index] = nil
+		return nil
+	}
+	r := &rumbler{f: f, id: -1}
+	jsRumblers[index] = r
+	return r
+}
+
+// Forgets the rumbler for a joystick that was disconnected.  jsMutex must be
+// held.
+func forgetRumbler(index gin.DeviceIndex) {
+	if r := jsRumblers[index]; r != nil {
+		r.f.Close()
+	}
+	delete(jsRumblers, index)
+}
+
+func (r *rumbler) rumble(low, high float64, duration_ms int) error {
+	if low == 0 && high == 0 {
+		return r.play(0)
+	}
+	var effect ffEffect
+	effect.Type = ffRumble
+	effect.Id = r.id
+	if duration_ms > 0xffff {
+		duration_ms = 0xffff
+	}
+	// A length of 0 plays until the effect is stopped.
+	effect.ReplayLength = uint16(duration_ms)
+	effect.Rumble.StrongMagnitude = uint16(low * 0xffff)
+	effect.Rumble.WeakMagnitude = uint16(high * 0xffff)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, r.f.Fd(), eviocsff, uintptr(unsafe.Pointer(&effect)))
+	if errno != 0 {
+		return errno
+	}
+	// The kernel gives the effect an id the first time it's uploaded, after
+	// that uploading it again updates it.
+	r.id = effect.Id
+	return r.play(1)
+}
+
+// Starts or stops the effect, value is how many times to play it.
+func (r *rumbler) play(value int32) error {
+	if r.id < 0 {
+		return nil
+	}
+	event := inputEvent{Type: evFF, Code: uint16(r.id), Value: value}
+	_, err := r.f.Write((*[unsafe.Sizeof(inputEvent{})]byte)(unsafe.Pointer(&event))[:])
+	return err
+}
+
+// Rumbles a joystick, for Os.SetRumble().
+func setJoystickRumble(device gin.DeviceId, low, high float64, duration_ms int) {
+	if device.Type != gin.DeviceTypeController {
+		return
+	}
+	jsMutex.Lock()
+	defer jsMutex.Unlock()
+	r := rumblerFor(device.Index)
+	if r == nil {
+		return
+	}
+	if err := r.rumble(clampRumble(low), clampRumble(high), duration_ms); err != nil {
+		glog.Infof("Joystick %d can't rumble: %v", device.Index, err)
+		forgetRumbler(device.Index)
+		jsRumblers[device.Index] = nil
+	}
+}
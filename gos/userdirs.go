@@ -0,0 +1,34 @@
+package gos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns app's directory in base, creating it if it doesn't exist.
+func userDir(base, app string) (string, error) {
+	if app == "" || app == "." || app == ".." || strings.ContainsAny(app, `/\:`) {
+		return "", fmt.Errorf("'%s' can't be used as a directory name.", app)
+	}
+	dir := filepath.Join(base, app)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Returns the value of the environment variable name if it is an absolute
+// path, otherwise filepath.Join(home, def...).  Relative paths in these
+// variables are supposed to be ignored.
+func envDir(name string, def ...string) (string, error) {
+	if dir := os.Getenv(name); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{home}, def...)...), nil
+}
@@ -0,0 +1,29 @@
+package gos
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Data and config both go in Application Support, Preferences is only meant
+// for the plists that NSUserDefaults writes.
+
+func (osx *osxSystemObject) UserDataDir(app string) (string, error) {
+	return libraryDir(app, "Application Support")
+}
+
+func (osx *osxSystemObject) UserConfigDir(app string) (string, error) {
+	return libraryDir(app, "Application Support")
+}
+
+func (osx *osxSystemObject) UserCacheDir(app string) (string, error) {
+	return libraryDir(app, "Caches")
+}
+
+func libraryDir(app, dir string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return userDir(filepath.Join(home, "Library", dir), app)
+}
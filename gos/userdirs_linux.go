@@ -0,0 +1,23 @@
+package gos
+
+// Directories follow the XDG base directory spec.
+
+func (linux *linuxSystemObject) UserDataDir(app string) (string, error) {
+	return xdgDir("XDG_DATA_HOME", app, ".local", "share")
+}
+
+func (linux *linuxSystemObject) UserConfigDir(app string) (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", app, ".config")
+}
+
+func (linux *linuxSystemObject) UserCacheDir(app string) (string, error) {
+	return xdgDir("XDG_CACHE_HOME", app, ".cache")
+}
+
+func xdgDir(env, app string, def ...string) (string, error) {
+	base, err := envDir(env, def...)
+	if err != nil {
+		return "", err
+	}
+	return userDir(base, app)
+}
@@ -0,0 +1,28 @@
+package gos
+
+// Data and config go in the roaming AppData so that they follow the user
+// between machines on a domain, and the cache stays local.
+
+func (win32 *win32SystemObject) UserDataDir(app string) (string, error) {
+	return appDataDir("APPDATA", app, "AppData", "Roaming")
+}
+
+func (win32 *win32SystemObject) UserConfigDir(app string) (string, error) {
+	return appDataDir("APPDATA", app, "AppData", "Roaming")
+}
+
+func (win32 *win32SystemObject) UserCacheDir(app string) (string, error) {
+	dir, err := appDataDir("LOCALAPPDATA", app, "AppData", "Local")
+	if err != nil {
+		return "", err
+	}
+	return userDir(dir, "Cache")
+}
+
+func appDataDir(env, app string, def ...string) (string, error) {
+	base, err := envDir(env, def...)
+	if err != nil {
+		return "", err
+	}
+	return userDir(base, app)
+}
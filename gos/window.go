@@ -0,0 +1,42 @@
+package gos
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+)
+
+// Returns the pixels of im as non-premultiplied RGBA, four bytes per pixel,
+// with rows from top to bottom.  This is the format that the backends expect
+// window icons and custom cursors to be in.
+func iconPixels(im image.Image) (pix []byte, dx, dy int) {
+	bounds := im.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), im, bounds.Min, draw.Src)
+	return nrgba.Pix, bounds.Dx(), bounds.Dy()
+}
+
+// Returns s as a nul-terminated byte slice that can be passed to C.
+func cString(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// Splits b, which holds nul-terminated paths one after another, into
+// separate paths.  This is how the backends hand dropped files back to Go.
+func splitPaths(b []byte) []string {
+	var paths []string
+	for len(b) > 0 {
+		end := bytes.IndexByte(b, 0)
+		if end == -1 {
+			end = len(b)
+		}
+		if end > 0 {
+			paths = append(paths, string(b[:end]))
+		}
+		if end == len(b) {
+			break
+		}
+		b = b[end+1:]
+	}
+	return paths
+}
@@ -1,9 +1,17 @@
 #define DIRECTINPUT_VERSION 0x0700
+// WM_TOUCH is only available on windows 7 and later.
+#ifndef _WIN32_WINNT
+#define _WIN32_WINNT 0x0601
+#endif
 #include "dinput.h"
 #include <process.h>
 #include <windows.h>
+#include <shellapi.h>
+#include <mmdeviceapi.h>
+#include <audioclient.h>
 #include <map>
 #include <set>
+#include <string>
 #include <vector>
 #include <windows.h>
 using namespace std;
@@ -167,8 +175,10 @@ class InputPollingThread;
 struct OsWindowData {
   OsWindowData()
   : icon_handle(0), window_handle(0), device_context(0), rendering_context(0), direct_input(0),
-    keyboard_device(0), mouse_device(0), input_polling_thread(0), is_full_screen(0), x(0), y(0),
-    width(0), height(0), is_in_focus(false), focus_changed(false), is_minimized(false) {}
+    keyboard_device(0), mouse_device(0), input_polling_thread(0), is_full_screen(0),
+    is_borderless_full_screen(false), windowed_style(0), x(0), y(0),
+    width(0), height(0), is_in_focus(false), focus_changed(false), is_minimized(false),
+    relative_mouse(false), cursor_handle(0), owns_cursor(false), high_surrogate(0) {}
 
   // Operating system values and handles. icon_handle is only non-zero if it will need to be
   // deleted eventually.
@@ -182,17 +192,46 @@ struct OsWindowData {
   InputPollingThread *input_polling_thread;
   Mutex input_mutex;
 
+  // Characters typed since the last call to GlopGetTextEvents. WM_CHAR gives us utf-16, so we
+  // hang on to the first half of a surrogate pair until the second half arrives.
+  vector<GlopTextEvent> text_events;
+  wchar_t high_surrogate;
+
+  // Window events since the last call to GlopGetWindowEvents.
+  vector<GlopWindowEvent> window_events;
+
+  // Files dropped since the last call to GlopGetFileDropEvents.  paths has the paths from every
+  // drop one after another, each drop's paths field is an offset into it until they are returned.
+  vector<GlopFileDropEvent> file_drops;
+  string file_drop_paths;
+
   // Queriable window properties
   bool is_full_screen;
+
+  // Set by GlopSetFullScreen, which uses a borderless window that covers the monitor rather
+  // than changing the display mode.  The style and position to restore are kept here.
+  bool is_borderless_full_screen;
+  LONG windowed_style;
+  RECT windowed_rect;
   int x, y;
   int width, height;
   bool is_in_focus, focus_changed, is_minimized;
+
+  // Set by GlopSetRelativeMouseMode.  Mouse motion comes from WM_INPUT instead of the cursor
+  // position while this is set.
+  bool relative_mouse;
+
+  // Set by GlopSetCursorShape and GlopSetCustomCursor, 0 for the class cursor.
+  HCURSOR cursor_handle;
+  bool owns_cursor;
 };
 
 // Constants
 const int kBpp = 32;
 const int kDirectInputBufferSize = 50;
 const int kJoystickAxisRange = 10000;
+const int kNumJoystickAxes = 6;
+const int kNumJoystickButtons = 128;
 const int kDIToGlopKeyIndex[] = {0,
   27, '1', '2', '3', '4',
   '5', '6', '7', '8', '9',
@@ -248,6 +287,31 @@ const int kDIToGlopKeyIndex[] = {0,
 
 // Globals
 static map<HWND, OsWindowData*> gWindowMap;
+
+// Windows identifies touch devices by handle, these are assigned small device indices in the
+// order that we first see them.  Each touch is given an arbitrary id, which we assign to the
+// lowest free finger on its device.
+static map<HANDLE, int> gTouchDevices;
+static map<int, map<int, DWORD> > gTouchFingers;
+
+// Returns the finger that the touch with the given id on the given device is assigned to,
+// assigning it if it is new, or -1 if all of the fingers are in use.
+static int TouchFinger(int device, DWORD touch) {
+  map<int, DWORD>& fingers = gTouchFingers[device];
+  set<int> used;
+  for (map<int, DWORD>::iterator it = fingers.begin(); it != fingers.end(); it++) {
+    if (it->second == touch)
+      return it->first;
+    used.insert(it->first);
+  }
+  for (int finger = 0; finger < kMaxTouchFingers; finger++) {
+    if (used.count(finger) == 0) {
+      fingers[finger] = touch;
+      return finger;
+    }
+  }
+  return -1;
+}
 static OsWindowData *gLocked;
 
 HWND get_first_handle() {
@@ -289,6 +353,13 @@ class InputPollingThread: public Thread {
   InputPollingThread(OsWindowData *window): window_(window) {}
 
   // Returns all events since the last call to GetData.
+  // Adds an event that was generated outside of this thread, e.g. by HandleMessage.
+  void AddEvent(const GlopKeyEvent &event) {
+    window_->input_mutex.Acquire();
+    data_.push_back(event);
+    window_->input_mutex.Release();
+  }
+
   vector<GlopKeyEvent> GetData() {
     // Get the data
     window_->input_mutex.Acquire();
@@ -367,7 +438,7 @@ class InputPollingThread: public Thread {
       }
       if (!FAILED(hr)) {
         // TODO: GlopKeyEvent
-        if (cursor_pos.x != prev_pos.x) {
+        if (cursor_pos.x != prev_pos.x && !window_->relative_mouse) {
           GlopKeyEvent e = base;
           e.index = kMouseXAxis;
           e.press_amt = cursor_pos.x - prev_pos.x;
@@ -375,7 +446,7 @@ class InputPollingThread: public Thread {
           e.cursor_y = cursor_pos.y;
           data_.push_back(e);
         }
-        if (cursor_pos.y != prev_pos.y) {
+        if (cursor_pos.y != prev_pos.y && !window_->relative_mouse) {
           GlopKeyEvent e = base;
           e.index = kMouseYAxis;
           e.press_amt = cursor_pos.y - prev_pos.y;
@@ -383,14 +454,15 @@ class InputPollingThread: public Thread {
           e.cursor_y = cursor_pos.y;
           data_.push_back(e);
         }
-/*
-        data_.push_back(GlopKeyEvent(mouse_state.lX, mouse_state.lY, timestamp, cursor_pos.x,
-                                     cursor_pos.y, is_num_lock_set, is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(kMouseWheelDown, mouse_state.lZ < 0, timestamp, cursor_pos.x,
-                                     cursor_pos.y, is_num_lock_set, is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(kMouseWheelUp, mouse_state.lZ > 0, timestamp, cursor_pos.x,
-                                     cursor_pos.y, is_num_lock_set, is_caps_lock_set));
-*/
+        // lZ is the distance the wheel has moved since we last polled it, in units of
+        // WHEEL_DELTA per click. DirectInput doesn't report the horizontal wheel, that comes
+        // from WM_MOUSEHWHEEL instead.
+        if (mouse_state.lZ != 0) {
+          GlopKeyEvent e = base;
+          e.index = kMouseWheelVertical;
+          e.press_amt = float(mouse_state.lZ) / WHEEL_DELTA;
+          data_.push_back(e);
+        }
 //        ASSERT(kNumMouseButtons == 8);  // Update section if this changes
         for (int i = 0; i < 3; i++) {
           GlopKeyEvent e = base;
@@ -413,8 +485,15 @@ class InputPollingThread: public Thread {
 */
       }
 
-      // Read the joystick states
-/*
+      // Read the joystick states. DirectInput doesn't give us events for joysticks, so we keep
+      // the previous state of each one around and generate events for anything that changed.
+      if (joystick_states_.size() != window_->joystick_devices.size()) {
+        joystick_states_.resize(window_->joystick_devices.size());
+        for (int i = 0; i < (int)joystick_states_.size(); i++) {
+          memset(&joystick_states_[i], 0, sizeof(joystick_states_[i]));
+          joystick_states_[i].rgdwPOV[0] = 0xFFFF;
+        }
+      }
       DIJOYSTATE2 joy_state;
       for (int i = 0; i < (int)window_->joystick_devices.size(); i++) {
         // Try to poll the device
@@ -425,101 +504,78 @@ class InputPollingThread: public Thread {
         }
         if (FAILED(window_->joystick_devices[i]->GetDeviceState(sizeof(joy_state), &joy_state)))
           continue;
+        DIJOYSTATE2 &prev = joystick_states_[i];
+
+        // Joysticks are numbered from 1 to match the indexes reported by GetActiveDevices().
+        GlopKeyEvent joy_base = base;
+        joy_base.device = i + 1;
+
+        // Read axis data. Each axis is split into a positive and a negative key.
+        LONG axes[] = {joy_state.lX, joy_state.lY, joy_state.lZ,
+                       joy_state.lRx, joy_state.lRy, joy_state.lRz};
+        LONG prev_axes[] = {prev.lX, prev.lY, prev.lZ, prev.lRx, prev.lRy, prev.lRz};
+        for (int j = 0; j < kNumJoystickAxes; j++) {
+          if (axes[j] == prev_axes[j]) continue;
+          GlopKeyEvent pos = joy_base, neg = joy_base;
+          pos.index = kControllerAxis0Positive + j;
+          pos.press_amt = axes[j] > 0 ? float(axes[j]) / kJoystickAxisRange : 0;
+          neg.index = kControllerAxis0Negative + j;
+          neg.press_amt = axes[j] < 0 ? float(-axes[j]) / kJoystickAxisRange : 0;
+          data_.push_back(pos);
+          data_.push_back(neg);
+        }
 
-        // Read axis data
-//        ASSERT(kNumJoystickAxes == 6);  // Update section if this changes
-        // TODO: GlopKeyEvent stuff
-        data_.push_back(GlopKeyEvent(GetJoystickRight(i), float(joy_state.lX)/kJoystickAxisRange,
-        data_.push_back(GlopKeyEvent(GetJoystickLeft(i), float(-joy_state.lX)/kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickUp(i), float(-joy_state.lY)/kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickDown(i), float(joy_state.lY)/kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisPos(2, i),
-                                     float(joy_state.lZ) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisNeg(2, i),
-                                     float(-joy_state.lZ) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisPos(3, i),
-                                     float(joy_state.lRz) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisNeg(3, i),
-                                     float(-joy_state.lRz) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisPos(4, i),
-                                     float(joy_state.lRx) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisNeg(4, i),
-                                     float(-joy_state.lRx) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisPos(5, i),
-                                     float(joy_state.lRy) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-        data_.push_back(GlopKeyEvent(GetJoystickAxisNeg(5, i),
-                                     float(-joy_state.lRy) / kJoystickAxisRange,
-                                     timestamp, cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                     is_caps_lock_set));
-    
-        // Read hat data
-//        ASSERT(kNumJoystickHats <= 4);  // Update section if this changes
-        for (int j = 0; j < kNumJoystickHats; j++) {
-          int angle = joy_state.rgdwPOV[j];
-          float hx = 0, hy = 0;
-          if (LOWORD(angle) != 0xFFFF) {
-            if (angle < 4500) hx = float(angle) / 4500;
-            else if (angle <= 13500) hx = 1;
-            else if (angle < 22500) hx = 1 - float(angle-13500) / 4500;
-            else if (angle <= 31500) hx = -1;
-            else hx = -1 + float(angle-31500) / 4500;
-            if (angle < 4500) hy = 1;
-            else if (angle <= 13500) hy = 1 - float(angle-4500) / 4500;
-            else if (angle < 22500) hy = -1;
-            else if (angle <= 31500) hy = -1 + float(angle-22500) / 4500;
-            else hy = 1;
+        // Read hat data. Only the first hat is supported, and it is reported as one of eight
+        // directional keys.
+        int hat = JoystickHatKey(joy_state.rgdwPOV[0]);
+        int prev_hat = JoystickHatKey(prev.rgdwPOV[0]);
+        if (hat != prev_hat) {
+          if (prev_hat != -1) {
+            GlopKeyEvent e = joy_base;
+            e.index = prev_hat;
+            e.press_amt = 0;
+            data_.push_back(e);
+          }
+          if (hat != -1) {
+            GlopKeyEvent e = joy_base;
+            e.index = hat;
+            e.press_amt = 1;
+            data_.push_back(e);
           }
-          data_.push_back(GlopKeyEvent(GetJoystickHatUp(j, i), hy, timestamp, cursor_pos.x,
-                                       cursor_pos.y, is_num_lock_set, is_caps_lock_set));
-          data_.push_back(GlopKeyEvent(GetJoystickHatRight(j, i), hx, timestamp, cursor_pos.x,
-                                       cursor_pos.y, is_num_lock_set, is_caps_lock_set));
-          data_.push_back(GlopKeyEvent(GetJoystickHatDown(j, i), -hy, timestamp, cursor_pos.x,
-                                       cursor_pos.y, is_num_lock_set, is_caps_lock_set));
-          data_.push_back(GlopKeyEvent(GetJoystickHatLeft(j, i), -hx, timestamp, cursor_pos.x,
-                                       cursor_pos.y, is_num_lock_set, is_caps_lock_set));
         }
 
         // Read button data
-//        ASSERT(kNumJoystickButtons <= 128);  // Update section if this changes
         for (int j = 0; j < kNumJoystickButtons; j++) {
           bool is_pressed = ((joy_state.rgbButtons[j] & 0x80) > 0);
-          data_.push_back(Os::KeyEvent(GetJoystickButton(j, i), is_pressed, timestamp,
-                                        cursor_pos.x, cursor_pos.y, is_num_lock_set,
-                                        is_caps_lock_set));
+          bool was_pressed = ((prev.rgbButtons[j] & 0x80) > 0);
+          if (is_pressed == was_pressed) continue;
+          GlopKeyEvent e = joy_base;
+          e.index = kControllerButton0 + j;
+          e.press_amt = is_pressed;
+          data_.push_back(e);
         }
+        prev = joy_state;
       }
-*/
 
       window_->input_mutex.Release();
       GlopSleep(10);
     }
   }
 
+  // Returns the key index of the hat switch key that corresponds to the given POV value, or -1
+  // if the hat is centered.
+  static int JoystickHatKey(DWORD pov) {
+    if (LOWORD(pov) == 0xFFFF)
+      return -1;
+    return kControllerHatSwitchUp + ((pov + 2250) / 4500) % 8;
+  }
+
   // TODO: This needs to be replaced with some sort of something or other
   vector<GlopKeyEvent> data_;
 
+  // The state of each joystick as of the last time it was polled.
+  vector<DIJOYSTATE2> joystick_states_;
+
   OsWindowData *window_;
 };
 
@@ -554,8 +610,50 @@ void GlopThink() {
   }
 }
 
+#ifndef WM_MOUSEHWHEEL
+#define WM_MOUSEHWHEEL 0x020E
+#endif
+
 // Handles window messages that arrive by any means, message queue or by direct notification.
 // However, key events are ignored, as input is handled by DirectInput in WindowThink().
+static void AddWindowEvent(OsWindowData *window, int type, int dx, int dy) {
+  GlopWindowEvent e;
+  e.type = type;
+  e.dx = dx;
+  e.dy = dy;
+  e.timestamp = GlopGetTime();
+  window->window_events.push_back(e);
+}
+
+// Adds the files in a WM_DROPFILES message as a file drop, at the drop point in screen
+// coordinates.
+static void AddFileDrop(OsWindowData *window, HDROP drop) {
+  GlopFileDropEvent e;
+  e.paths = (char*)window->file_drop_paths.size();
+  UINT count = DragQueryFileW(drop, 0xFFFFFFFF, NULL, 0);
+  for (UINT i = 0; i < count; i++) {
+    UINT wide_length = DragQueryFileW(drop, i, NULL, 0);
+    vector<wchar_t> wide(wide_length + 1);
+    DragQueryFileW(drop, i, &wide[0], wide_length + 1);
+    int size = WideCharToMultiByte(CP_UTF8, 0, &wide[0], -1, NULL, 0, NULL, NULL);
+    if (size <= 0)
+      continue;
+    vector<char> path(size);
+    WideCharToMultiByte(CP_UTF8, 0, &wide[0], -1, &path[0], size, NULL, NULL);
+    // size includes the nul.
+    window->file_drop_paths.append(&path[0], size);
+  }
+  e.length = window->file_drop_paths.size() - (size_t)e.paths;
+  POINT point;
+  DragQueryPoint(drop, &point);
+  ClientToScreen(window->window_handle, &point);
+  e.x = point.x;
+  e.y = point.y;
+  e.timestamp = GlopGetTime();
+  if (e.length > 0)
+    window->file_drops.push_back(e);
+}
+
 LRESULT CALLBACK HandleMessage(HWND window_handle, UINT message, WPARAM wparam, LPARAM lparam) {
   // Extract information from the parameters
   if (!gWindowMap.count(window_handle))
@@ -564,9 +662,6 @@ LRESULT CALLBACK HandleMessage(HWND window_handle, UINT message, WPARAM wparam,
   unsigned short wparam1 = LOWORD(wparam), wparam2 = HIWORD(wparam);
   unsigned short lparam1 = LOWORD(lparam), lparam2 = HIWORD(lparam);
 
-  // TODO: Only here for crashing later
-  int* x = 0;
-
 	// Handle each message
   switch (message) {
     case WM_SYSCOMMAND:
@@ -578,9 +673,12 @@ LRESULT CALLBACK HandleMessage(HWND window_handle, UINT message, WPARAM wparam,
         return 0;
       break;
     case WM_CLOSE:
-      // TODO: Need to figure out what is supposed to happen here.  It shouldn't actually crash.
-      *x = 0;
-//      window()->Destroy();  // this is certainly not going to fail hideously
+      // The window stays open, it's up to the application to decide what to do.
+      AddWindowEvent(os_window, glopWindowClose, 0, 0);
+      return 0;
+    case WM_DROPFILES:
+      AddFileDrop(os_window, (HDROP)wparam);
+      DragFinish((HDROP)wparam);
       return 0;
     case WM_MOVE:
       os_window->x = (signed short)lparam1;
@@ -602,8 +700,12 @@ LRESULT CALLBACK HandleMessage(HWND window_handle, UINT message, WPARAM wparam,
           ChangeDisplaySettings(&screen_settings, CDS_FULLSCREEN);
         }
       }
+      if (os_window->is_minimized != (wparam == SIZE_MINIMIZED))
+        AddWindowEvent(os_window, wparam == SIZE_MINIMIZED ? glopWindowMinimize : glopWindowRestore, 0, 0);
       os_window->is_minimized = (wparam == SIZE_MINIMIZED);
       if (!os_window->is_minimized) {
+        if (os_window->width != lparam1 || os_window->height != lparam2)
+          AddWindowEvent(os_window, glopWindowResize, lparam1, lparam2);
         os_window->width = lparam1;
         os_window->height = lparam2;
       }
@@ -611,9 +713,113 @@ LRESULT CALLBACK HandleMessage(HWND window_handle, UINT message, WPARAM wparam,
     case WM_SIZING:
       os_window->focus_changed = true;
       break;
-	  case WM_ACTIVATE:
+    case WM_CHAR: {
+      // Since TranslateMessage is called from GlopThink, this comes in on the main thread.
+      wchar_t c = (wchar_t)wparam;
+      if (c >= 0xD800 && c <= 0xDBFF) {
+        os_window->high_surrogate = c;
+        return 0;
+      }
+      GlopTextEvent e;
+      e.rune = c;
+      e.timestamp = GlopGetTime();
+      if (c >= 0xDC00 && c <= 0xDFFF) {
+        if (os_window->high_surrogate == 0)
+          return 0;
+        e.rune = 0x10000 + ((os_window->high_surrogate - 0xD800) << 10) + (c - 0xDC00);
+      }
+      os_window->high_surrogate = 0;
+      os_window->text_events.push_back(e);
+      return 0;
+    }
+    case WM_MOUSEHWHEEL:
+      if (os_window->input_polling_thread != 0) {
+        POINT cursor_pos;
+        GetCursorPos(&cursor_pos);
+        GlopKeyEvent e;
+        GlopClearKeyEvent(&e);
+        e.index = kMouseWheelHorizontal;
+        e.press_amt = float((short)wparam2) / WHEEL_DELTA;
+        e.timestamp = GlopGetTime();
+        e.cursor_x = cursor_pos.x;
+        e.cursor_y = cursor_pos.y;
+        os_window->input_polling_thread->AddEvent(e);
+      }
+      return 0;
+    case WM_TOUCH: {
+      int num_inputs = wparam1;
+      vector<TOUCHINPUT> inputs(num_inputs);
+      if (num_inputs > 0 && os_window->input_polling_thread != 0 &&
+          GetTouchInputInfo((HTOUCHINPUT)lparam, num_inputs, &inputs[0], sizeof(TOUCHINPUT))) {
+        for (int i = 0; i < num_inputs; i++) {
+          if (!gTouchDevices.count(inputs[i].hSource)) {
+            int index = gTouchDevices.size() + 1;
+            gTouchDevices[inputs[i].hSource] = index;
+          }
+          int device = gTouchDevices[inputs[i].hSource];
+          int finger = TouchFinger(device, inputs[i].dwID);
+          if (finger == -1)
+            continue;
+          GlopKeyEvent e;
+          GlopClearKeyEvent(&e);
+          e.index = kTouchFinger0 + finger;
+          e.device = device;
+          e.press_amt = 1.0;
+          e.timestamp = GlopGetTime();
+          // Touch positions are in hundredths of a pixel.
+          e.cursor_x = inputs[i].x / 100;
+          e.cursor_y = inputs[i].y / 100;
+          if (inputs[i].dwFlags & TOUCHEVENTF_UP) {
+            e.press_amt = 0.0;
+            gTouchFingers[device].erase(finger);
+          }
+          os_window->input_polling_thread->AddEvent(e);
+        }
+        CloseTouchInputHandle((HTOUCHINPUT)lparam);
+        return 0;
+      }
+      break;
+    }
+    case WM_SETCURSOR:
+      if (lparam1 == HTCLIENT && os_window->cursor_handle != 0) {
+        SetCursor(os_window->cursor_handle);
+        return TRUE;
+      }
+      break;
+    case WM_INPUT: {
+      // Raw mouse motion is in device units and hasn't been clipped or accelerated.
+      RAWINPUT raw;
+      UINT size = sizeof(raw);
+      if (os_window->relative_mouse && os_window->input_polling_thread != 0 &&
+          GetRawInputData((HRAWINPUT)lparam, RID_INPUT, &raw, &size, sizeof(RAWINPUTHEADER)) != (UINT)-1 &&
+          raw.header.dwType == RIM_TYPEMOUSE && !(raw.data.mouse.usFlags & MOUSE_MOVE_ABSOLUTE)) {
+        POINT cursor_pos;
+        GetCursorPos(&cursor_pos);
+        GlopKeyEvent e;
+        GlopClearKeyEvent(&e);
+        e.timestamp = GlopGetTime();
+        e.cursor_x = cursor_pos.x;
+        e.cursor_y = cursor_pos.y;
+        if (raw.data.mouse.lLastX != 0) {
+          e.index = kMouseXAxis;
+          e.press_amt = raw.data.mouse.lLastX;
+          os_window->input_polling_thread->AddEvent(e);
+        }
+        if (raw.data.mouse.lLastY != 0) {
+          e.index = kMouseYAxis;
+          e.press_amt = raw.data.mouse.lLastY;
+          os_window->input_polling_thread->AddEvent(e);
+        }
+      }
+      // DefWindowProc has to see WM_INPUT to clean up after it.
+      break;
+    }
+	  case WM_ACTIVATE: {
+      bool was_in_focus = os_window->is_in_focus;
       os_window->is_in_focus = (wparam1 == WA_ACTIVE || wparam1 == WA_CLICKACTIVE);
       os_window->focus_changed = true;
+      if (was_in_focus != os_window->is_in_focus)
+        AddWindowEvent(os_window, os_window->is_in_focus ? glopWindowFocus : glopWindowBlur, 0, 0);
       // If the user alt-tabs out of a fullscreen window, the window will keep drawing and will
       // remain in full-screen mode. Here, we minimize the window, which fixes the drawing problem,
       // and then the WM_SIZE event fixes the full-screen problem.
@@ -624,6 +830,7 @@ LRESULT CALLBACK HandleMessage(HWND window_handle, UINT message, WPARAM wparam,
       if (!os_window->is_in_focus)
         UnlockCursorNow();
       break;
+    }
   }
 
   // Pass on remaining messages to the default handler
@@ -693,8 +900,262 @@ HICON CreateIcon(OsWindowData *data, const Image *image) {
 }
 */
 
-void GlopSetTitle(OsWindowData* window, char* title) {
-  SetWindowText(window->window_handle, title);
+void GlopSetTitle(void* _window, void* _title) {
+  OsWindowData* window = (OsWindowData*)_window;
+  // The title is utf-8, so it has to be converted to utf-16 for SetWindowTextW.
+  const char* title = (const char*)_title;
+  int length = MultiByteToWideChar(CP_UTF8, 0, title, -1, NULL, 0);
+  if (length <= 0) {
+    SetWindowTextA(window->window_handle, title);
+    return;
+  }
+  vector<wchar_t> wide(length);
+  MultiByteToWideChar(CP_UTF8, 0, title, -1, &wide[0], length);
+  SetWindowTextW(window->window_handle, &wide[0]);
+}
+
+// Switches to or from a borderless window that covers the monitor the window is on.
+void GlopSetFullScreen(void* _window, int full_screen) {
+  OsWindowData* window = (OsWindowData*)_window;
+  if ((full_screen != 0) == window->is_borderless_full_screen)
+    return;
+  HWND handle = window->window_handle;
+  if (full_screen) {
+    MONITORINFO monitor_info;
+    monitor_info.cbSize = sizeof(monitor_info);
+    if (!GetMonitorInfo(MonitorFromWindow(handle, MONITOR_DEFAULTTONEAREST), &monitor_info))
+      return;
+    window->windowed_style = GetWindowLong(handle, GWL_STYLE);
+    GetWindowRect(handle, &window->windowed_rect);
+    SetWindowLong(handle, GWL_STYLE, (window->windowed_style & ~WS_OVERLAPPEDWINDOW) | WS_POPUP);
+    RECT rect = monitor_info.rcMonitor;
+    SetWindowPos(handle, HWND_TOP, rect.left, rect.top, rect.right - rect.left,
+                 rect.bottom - rect.top, SWP_NOOWNERZORDER | SWP_FRAMECHANGED);
+  } else {
+    SetWindowLong(handle, GWL_STYLE, window->windowed_style);
+    RECT rect = window->windowed_rect;
+    SetWindowPos(handle, NULL, rect.left, rect.top, rect.right - rect.left,
+                 rect.bottom - rect.top, SWP_NOZORDER | SWP_NOOWNERZORDER | SWP_FRAMECHANGED);
+  }
+  window->is_borderless_full_screen = (full_screen != 0);
+}
+
+static BOOL CALLBACK GlopMonitorCallback(HMONITOR monitor, HDC dc, LPRECT rect, LPARAM data) {
+  vector<GlopDisplay>* displays = (vector<GlopDisplay>*)data;
+  MONITORINFOEX info;
+  info.cbSize = sizeof(info);
+  if (!GetMonitorInfo(monitor, &info))
+    return TRUE;
+  GlopDisplay display;
+  display.x = info.rcMonitor.left;
+  display.y = info.rcMonitor.top;
+  display.dx = info.rcMonitor.right - info.rcMonitor.left;
+  display.dy = info.rcMonitor.bottom - info.rcMonitor.top;
+  display.primary = (info.dwFlags & MONITORINFOF_PRIMARY) ? 1 : 0;
+  display.refresh_rate = 0;
+  display.dpi = 0;
+  DEVMODE mode;
+  memset(&mode, 0, sizeof(mode));
+  mode.dmSize = sizeof(mode);
+  // A frequency of 0 or 1 means the hardware's default rate, which we don't know.
+  if (EnumDisplaySettings(info.szDevice, ENUM_CURRENT_SETTINGS, &mode) && mode.dmDisplayFrequency > 1)
+    display.refresh_rate = (float)mode.dmDisplayFrequency;
+  HDC monitor_dc = CreateDC(info.szDevice, info.szDevice, NULL, NULL);
+  if (monitor_dc) {
+    display.dpi = (float)GetDeviceCaps(monitor_dc, LOGPIXELSX);
+    DeleteDC(monitor_dc);
+  }
+  displays->push_back(display);
+  return TRUE;
+}
+
+static GlopDisplay* glop_display_buffer = 0;
+static GlopWindowEvent* glop_window_event_buffer = 0;
+
+void GlopGetWindowEvents(void* _window, void** _events_ret, int* length) {
+  OsWindowData* window = (OsWindowData*)_window;
+  vector<GlopWindowEvent>& events = window->window_events;
+  glop_window_event_buffer = (GlopWindowEvent*)realloc(
+      glop_window_event_buffer, sizeof(GlopWindowEvent) * (events.size() + 1));
+  for (int i = 0; i < events.size(); i++) {
+    glop_window_event_buffer[i] = events[i];
+  }
+  *((GlopWindowEvent**)_events_ret) = glop_window_event_buffer;
+  *length = events.size();
+  events.clear();
+}
+
+static GlopFileDropEvent* glop_file_drop_buffer = 0;
+static char* glop_file_drop_paths = 0;
+
+void GlopGetFileDropEvents(void* _window, void** _events_ret, int* length) {
+  OsWindowData* window = (OsWindowData*)_window;
+  vector<GlopFileDropEvent>& drops = window->file_drops;
+  string& paths = window->file_drop_paths;
+  glop_file_drop_paths = (char*)realloc(glop_file_drop_paths, paths.size() + 1);
+  memcpy(glop_file_drop_paths, paths.data(), paths.size());
+  glop_file_drop_buffer = (GlopFileDropEvent*)realloc(
+      glop_file_drop_buffer, sizeof(GlopFileDropEvent) * (drops.size() + 1));
+  for (int i = 0; i < drops.size(); i++) {
+    glop_file_drop_buffer[i] = drops[i];
+    glop_file_drop_buffer[i].paths = glop_file_drop_paths + (size_t)drops[i].paths;
+  }
+  *((GlopFileDropEvent**)_events_ret) = glop_file_drop_buffer;
+  *length = drops.size();
+  drops.clear();
+  paths.clear();
+}
+
+void GlopGetDisplays(void** _displays_ret, int* length) {
+  vector<GlopDisplay> displays;
+  EnumDisplayMonitors(NULL, NULL, GlopMonitorCallback, (LPARAM)&displays);
+  glop_display_buffer = (GlopDisplay*)realloc(glop_display_buffer, sizeof(GlopDisplay) * (displays.size() + 1));
+  for (int i = 0; i < displays.size(); i++) {
+    glop_display_buffer[i] = displays[i];
+  }
+  *((GlopDisplay**)_displays_ret) = glop_display_buffer;
+  *length = displays.size();
+}
+
+// Puts utf-8 text on the clipboard as CF_UNICODETEXT.
+void GlopSetClipboard(void* _window, void* _text) {
+  OsWindowData* window = (OsWindowData*)_window;
+  const char* text = (const char*)_text;
+  int length = MultiByteToWideChar(CP_UTF8, 0, text, -1, NULL, 0);
+  if (length <= 0)
+    return;
+  HGLOBAL memory = GlobalAlloc(GMEM_MOVEABLE, length * sizeof(wchar_t));
+  if (!memory)
+    return;
+  MultiByteToWideChar(CP_UTF8, 0, text, -1, (wchar_t*)GlobalLock(memory), length);
+  GlobalUnlock(memory);
+  if (!OpenClipboard(window->window_handle)) {
+    GlobalFree(memory);
+    return;
+  }
+  EmptyClipboard();
+  // Once SetClipboardData succeeds the clipboard owns the memory.
+  if (!SetClipboardData(CF_UNICODETEXT, memory))
+    GlobalFree(memory);
+  CloseClipboard();
+}
+
+static char* glop_clipboard_buffer = 0;
+
+// Gets the CF_UNICODETEXT on the clipboard as utf-8.
+void GlopGetClipboard(void* _window, void** _text, int* length) {
+  OsWindowData* window = (OsWindowData*)_window;
+  *length = 0;
+  glop_clipboard_buffer = (char*)realloc(glop_clipboard_buffer, 1);
+  glop_clipboard_buffer[0] = 0;
+  *((char**)_text) = glop_clipboard_buffer;
+  if (!OpenClipboard(window->window_handle))
+    return;
+  HANDLE memory = GetClipboardData(CF_UNICODETEXT);
+  const wchar_t* wide = memory ? (const wchar_t*)GlobalLock(memory) : NULL;
+  if (wide) {
+    int size = WideCharToMultiByte(CP_UTF8, 0, wide, -1, NULL, 0, NULL, NULL);
+    if (size > 0) {
+      glop_clipboard_buffer = (char*)realloc(glop_clipboard_buffer, size);
+      WideCharToMultiByte(CP_UTF8, 0, wide, -1, glop_clipboard_buffer, size, NULL, NULL);
+      *((char**)_text) = glop_clipboard_buffer;
+      *length = size - 1;
+    }
+    GlobalUnlock(memory);
+  }
+  CloseClipboard();
+}
+
+void GlopMinimizeWindow(void* _window) {
+  OsWindowData* window = (OsWindowData*)_window;
+  ShowWindow(window->window_handle, SW_MINIMIZE);
+}
+
+// Sets the window's icon from dx*dy RGBA pixels, rows from top to bottom.
+// Makes an icon or cursor from dx*dy RGBA pixels, or returns 0 if it fails.
+static HICON CreateIconFromPixels(const unsigned char* pixels, int dx, int dy, bool is_icon,
+                                  int hot_x, int hot_y) {
+  BITMAPV5HEADER header;
+  memset(&header, 0, sizeof(header));
+  header.bV5Size = sizeof(header);
+  header.bV5Width = dx;
+  header.bV5Height = -dy;  // top-down
+  header.bV5Planes = 1;
+  header.bV5BitCount = 32;
+  header.bV5Compression = BI_BITFIELDS;
+  header.bV5RedMask = 0x00ff0000;
+  header.bV5GreenMask = 0x0000ff00;
+  header.bV5BlueMask = 0x000000ff;
+  header.bV5AlphaMask = 0xff000000;
+  unsigned char* bits = NULL;
+  HDC dc = GetDC(NULL);
+  HBITMAP color = CreateDIBSection(dc, (BITMAPINFO*)&header, DIB_RGB_COLORS, (void**)&bits, NULL, 0);
+  ReleaseDC(NULL, dc);
+  if (!color)
+    return 0;
+  for (int i = 0; i < dx * dy; i++) {
+    bits[4 * i + 0] = pixels[4 * i + 2];
+    bits[4 * i + 1] = pixels[4 * i + 1];
+    bits[4 * i + 2] = pixels[4 * i + 0];
+    bits[4 * i + 3] = pixels[4 * i + 3];
+  }
+  // The mask is ignored since the color bitmap has an alpha channel, but it has to exist.
+  HBITMAP mask = CreateBitmap(dx, dy, 1, 1, NULL);
+  ICONINFO icon_info;
+  icon_info.fIcon = is_icon ? TRUE : FALSE;
+  icon_info.xHotspot = hot_x;
+  icon_info.yHotspot = hot_y;
+  icon_info.hbmMask = mask;
+  icon_info.hbmColor = color;
+  HICON icon = CreateIconIndirect(&icon_info);
+  DeleteObject(color);
+  DeleteObject(mask);
+  return icon;
+}
+
+void GlopSetIcon(void* _window, void* _pixels, int dx, int dy) {
+  OsWindowData* window = (OsWindowData*)_window;
+  HICON icon = CreateIconFromPixels((const unsigned char*)_pixels, dx, dy, true, 0, 0);
+  if (!icon)
+    return;
+  SendMessage(window->window_handle, WM_SETICON, ICON_BIG, (LPARAM)icon);
+  SendMessage(window->window_handle, WM_SETICON, ICON_SMALL, (LPARAM)icon);
+  if (window->icon_handle != 0)
+    DestroyIcon(window->icon_handle);
+  window->icon_handle = icon;
+}
+
+// Replaces the window's cursor, which WM_SETCURSOR shows whenever the mouse is over the client
+// area.  Only custom cursors are owned by the window and need to be destroyed.
+static void SetWindowCursor(OsWindowData* window, HCURSOR cursor, bool owned) {
+  if (window->cursor_handle != 0 && window->owns_cursor)
+    DestroyCursor(window->cursor_handle);
+  window->cursor_handle = cursor;
+  window->owns_cursor = owned;
+  POINT cursor_pos;
+  GetCursorPos(&cursor_pos);
+  if (WindowFromPoint(cursor_pos) == window->window_handle)
+    SetCursor(cursor);
+}
+
+void GlopSetCursorShape(void* _window, int shape) {
+  OsWindowData* window = (OsWindowData*)_window;
+  LPCTSTR name = IDC_ARROW;
+  switch (shape) {
+    case glopCursorHand: name = IDC_HAND; break;
+    case glopCursorIBeam: name = IDC_IBEAM; break;
+    case glopCursorCrosshair: name = IDC_CROSS; break;
+  }
+  SetWindowCursor(window, LoadCursor(NULL, name), false);
+}
+
+void GlopSetCustomCursor(void* _window, void* _pixels, int dx, int dy, int hot_x, int hot_y) {
+  OsWindowData* window = (OsWindowData*)_window;
+  HCURSOR cursor = (HCURSOR)CreateIconFromPixels((const unsigned char*)_pixels, dx, dy, false,
+                                                 hot_x, hot_y);
+  if (!cursor)
+    return;
+  SetWindowCursor(window, cursor, true);
 }
 
 // Registers a new joystick with a window.
@@ -729,11 +1190,52 @@ BOOL CALLBACK GlopJoystickCallback(const DIDEVICEINSTANCE *device_instance, void
   return DIENUM_CONTINUE;
 }
 
-int GlopGetNumJoysticks(OsWindowData *window) {
-  return (int)window->joystick_devices.size();
+int GlopGetNumJoysticks(void* _window) {
+  OsWindowData* window = (OsWindowData*)_window;
+  window->input_mutex.Acquire();
+  int num_joysticks = (int)window->joystick_devices.size();
+  window->input_mutex.Release();
+  return num_joysticks;
+}
+
+// XInput is loaded the first time it's needed rather than linked, so that glop still runs on systems
+// that don't have it.  These match XINPUT_VIBRATION and XInputSetState() from xinput.h.
+struct GlopXInputVibration {
+  WORD left_motor_speed;
+  WORD right_motor_speed;
+};
+typedef DWORD (WINAPI *XInputSetStateFunc)(DWORD, GlopXInputVibration*);
+
+static XInputSetStateFunc LoadXInputSetState() {
+  static bool loaded = false;
+  static XInputSetStateFunc set_state = NULL;
+  if (!loaded) {
+    loaded = true;
+    const char* dlls[] = {"xinput1_4.dll", "xinput1_3.dll", "xinput9_1_0.dll"};
+    for (int i = 0; i < 3 && set_state == NULL; i++) {
+      HMODULE module = LoadLibraryA(dlls[i]);
+      if (module) {
+        set_state = (XInputSetStateFunc)GetProcAddress(module, "XInputSetState");
+      }
+    }
+  }
+  return set_state;
+}
+
+int GlopSetRumble(int user_index, float low, float high) {
+  XInputSetStateFunc set_state = LoadXInputSetState();
+  if (set_state == NULL || user_index < 0 || user_index > 3) {
+    return 0;
+  }
+  // The left motor is the low frequency one.
+  GlopXInputVibration vibration;
+  vibration.left_motor_speed = (WORD)(low * 65535);
+  vibration.right_motor_speed = (WORD)(high * 65535);
+  return set_state(user_index, &vibration) == ERROR_SUCCESS;
 }
 
-void GlopRefreshJoysticks(OsWindowData *window) {
+void GlopRefreshJoysticks(void* _window) {
+  OsWindowData* window = (OsWindowData*)_window;
   window->input_mutex.Acquire();
 
   // Get the current joystick devices
@@ -861,6 +1363,8 @@ void* GlopCreateWindow(void* _title, int x, int y,
   GlopSetTitle(result, title);
   
   gWindowMap[result->window_handle] = result;
+  RegisterTouchWindow(result->window_handle, 0);
+  DragAcceptFiles(result->window_handle, TRUE);
 
   // Set the icon
 //  if (icon != 0) {
@@ -1007,7 +1511,8 @@ void GlopSetIcon(OsWindowData *window, const Image *icon) {
 }
 */
 
-void GlopSetWindowSize(OsWindowData *window, int width, int height) {
+void GlopSetWindowSize(void* _window, int width, int height) {
+  OsWindowData* window = (OsWindowData*)_window;
   RECT rect;
   GetWindowRect(window->window_handle, &rect);
   rect.right += width - window->width;
@@ -1046,6 +1551,25 @@ void GlopGetInputEvents(void* _window, void** _events_ret, void* _num_events, vo
   }
 }
 
+static GlopTextEvent* glop_text_event_buffer = 0;
+
+void GlopGetTextEvents(void* _window, void** _events_ret, void* _num_events) {
+  OsWindowData* window = (OsWindowData*)_window;
+  if (glop_text_event_buffer != 0) {
+    free(glop_text_event_buffer);
+  }
+  vector<GlopTextEvent> events;
+  if (_window != 0) {
+    events.swap(window->text_events);
+  }
+  glop_text_event_buffer = (GlopTextEvent*)malloc(sizeof(GlopTextEvent) * events.size());
+  *((GlopTextEvent**)_events_ret) = glop_text_event_buffer;
+  *((int*)_num_events) = events.size();
+  for (int i = 0; i < events.size(); i++) {
+    glop_text_event_buffer[i] = events[i];
+  }
+}
+
 void GlopGetMousePosition(int* x, int* y) {
   POINT cursor_pos;
   GetCursorPos(&cursor_pos);
@@ -1073,6 +1597,22 @@ void GlopLockMouseCursor(OsWindowData *locked) {
   }
 }
 
+// Registers the window for raw mouse input, which arrives as WM_INPUT messages, and hides the
+// cursor and keeps it in the window.
+void GlopSetRelativeMouseMode(void* _window, int relative) {
+  OsWindowData* window = (OsWindowData*)_window;
+  if (window == 0 || (relative != 0) == window->relative_mouse) return;
+  RAWINPUTDEVICE device;
+  device.usUsagePage = 0x01;  // generic desktop controls
+  device.usUsage = 0x02;      // mouse
+  device.dwFlags = relative ? 0 : RIDEV_REMOVE;
+  device.hwndTarget = relative ? window->window_handle : NULL;
+  RegisterRawInputDevices(&device, 1, sizeof(device));
+  window->relative_mouse = (relative != 0);
+  GlopLockMouseCursor(relative ? window : 0);
+  GlopShowMouseCursor(!relative);
+}
+
 
 // Miscellaneous functions
 // =======================
@@ -1100,8 +1640,14 @@ int GlopGetRefreshRate() {
   return dev_mode.dmDisplayFrequency;
 }
 
-void GlopEnableVSync(int is_enabled) {
-  // TODO: Stub
+typedef BOOL (WINAPI *SwapIntervalEXTFunc)(int);
+
+// Needs the window's rendering context to be current, so this is called on the render thread.
+void GlopSetSwapInterval(int interval) {
+  SwapIntervalEXTFunc swap_ext = (SwapIntervalEXTFunc)wglGetProcAddress("wglSwapIntervalEXT");
+  if (swap_ext) {
+    swap_ext(interval);
+  }
 }
 
 void GlopSwapBuffers(void* _window) {
@@ -1109,4 +1655,118 @@ void GlopSwapBuffers(void* _window) {
   ::SwapBuffers(window->device_context);
 }
 
-} // extern "C"
\ No newline at end of file
+// wglShareLists() needs the new context to not have made anything yet, so this is done before it
+// is ever made current.
+void* GlopCreateSharedContext(void* _window) {
+  OsWindowData* window = (OsWindowData*)_window;
+  HGLRC context = wglCreateContext(window->device_context);
+  if (context == NULL) return NULL;
+  if (!wglShareLists(window->rendering_context, context)) {
+    wglDeleteContext(context);
+    return NULL;
+  }
+  return context;
+}
+
+int GlopMakeContextCurrent(void* _window, void* context) {
+  if (context == NULL) {
+    return wglMakeCurrent(NULL, NULL) ? 1 : 0;
+  }
+  OsWindowData* window = (OsWindowData*)_window;
+  return wglMakeCurrent(window->device_context, (HGLRC)context) ? 1 : 0;
+}
+
+void GlopDestroyContext(void* context) {
+  wglDeleteContext((HGLRC)context);
+}
+
+// Plays through WASAPI in shared mode.  Shared mode normally only takes the device's own mix
+// format, so this asks WASAPI to convert from float stereo at whatever rate the mixer uses.
+static IAudioClient* audio_client = NULL;
+static IAudioRenderClient* audio_render = NULL;
+static UINT32 audio_buffer_frames = 0;
+static int audio_rate = 0;
+
+static void ReleaseAudio() {
+  if (audio_render) audio_render->Release();
+  if (audio_client) audio_client->Release();
+  audio_render = NULL;
+  audio_client = NULL;
+}
+
+int GlopOpenAudio(int rate) {
+  if (audio_client) return 0;
+  // The mixer writes from whatever thread its goroutine is on, and threads that never initialized
+  // COM themselves can use objects from the multithreaded apartment.
+  CoInitializeEx(NULL, COINIT_MULTITHREADED);
+  IMMDeviceEnumerator* enumerator = NULL;
+  if (FAILED(CoCreateInstance(__uuidof(MMDeviceEnumerator), NULL, CLSCTX_ALL,
+                              __uuidof(IMMDeviceEnumerator), (void**)&enumerator))) {
+    return 0;
+  }
+  IMMDevice* device = NULL;
+  HRESULT hr = enumerator->GetDefaultAudioEndpoint(eRender, eConsole, &device);
+  enumerator->Release();
+  if (FAILED(hr)) return 0;
+  hr = device->Activate(__uuidof(IAudioClient), CLSCTX_ALL, NULL, (void**)&audio_client);
+  device->Release();
+  if (FAILED(hr)) {
+    audio_client = NULL;
+    return 0;
+  }
+
+  WAVEFORMATEX format;
+  ZeroMemory(&format, sizeof(format));
+  format.wFormatTag = WAVE_FORMAT_IEEE_FLOAT;
+  format.nChannels = 2;
+  format.nSamplesPerSec = rate;
+  format.wBitsPerSample = 32;
+  format.nBlockAlign = 8;
+  format.nAvgBytesPerSec = rate * 8;
+  // 50ms of buffering, in 100ns units.
+  hr = audio_client->Initialize(
+      AUDCLNT_SHAREMODE_SHARED,
+      AUDCLNT_STREAMFLAGS_AUTOCONVERTPCM | AUDCLNT_STREAMFLAGS_SRC_DEFAULT_QUALITY,
+      500000, 0, &format, NULL);
+  if (FAILED(hr) ||
+      FAILED(audio_client->GetBufferSize(&audio_buffer_frames)) ||
+      FAILED(audio_client->GetService(__uuidof(IAudioRenderClient), (void**)&audio_render)) ||
+      FAILED(audio_client->Start())) {
+    ReleaseAudio();
+    return 0;
+  }
+  audio_rate = rate;
+  return rate;
+}
+
+int GlopWriteAudio(void* samples, int frames) {
+  if (!audio_render) return 0;
+  const float* next = (const float*)samples;
+  while (frames > 0) {
+    UINT32 padding;
+    if (FAILED(audio_client->GetCurrentPadding(&padding))) return 0;
+    UINT32 available = audio_buffer_frames - padding;
+    if (available == 0) {
+      // Wait for about a quarter of the buffer to play.
+      DWORD wait = audio_buffer_frames * 250 / audio_rate;
+      Sleep(wait > 0 ? wait : 1);
+      continue;
+    }
+    UINT32 count = available < (UINT32)frames ? available : (UINT32)frames;
+    BYTE* buffer;
+    if (FAILED(audio_render->GetBuffer(count, &buffer))) return 0;
+    memcpy(buffer, next, count * 8);
+    audio_render->ReleaseBuffer(count, 0);
+    next += 2 * count;
+    frames -= count;
+  }
+  return 1;
+}
+
+void GlopCloseAudio() {
+  if (!audio_client) return;
+  audio_client->Stop();
+  ReleaseAudio();
+}
+
+} // extern "C"
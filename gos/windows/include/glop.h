@@ -74,14 +74,57 @@
 
 #define kMouseXAxis  300
 #define kMouseYAxis  301
-#define kMouseWheelUp  302
-#define kMouseWheelDown  303
+#define kMouseWheelVertical  302
+#define kMouseWheelHorizontal  303
 #define kMouseLButton  304
 #define kMouseRButton  305
 #define kMouseMButton  306
 
+#define kTouchFinger0  95000
+#define kMaxTouchFingers  10
+
+#define kControllerButton0  500
+#define kControllerAxis0Positive  70000
+#define kControllerAxis0Negative  80000
+#define kControllerHatSwitchUp  90000
+
 
 //void Init();
+// These match gin.WindowEventType.
+#define glopWindowClose     0
+#define glopWindowResize    1
+#define glopWindowFocus     2
+#define glopWindowBlur      3
+#define glopWindowMinimize  4
+#define glopWindowRestore   5
+
+typedef struct {
+  int type;
+  int dx, dy;
+  long long timestamp;
+} GlopWindowEvent;
+
+// paths holds length bytes of nul-terminated, utf-8 paths one after another.
+typedef struct {
+  char* paths;
+  int length;
+  int x, y;
+  long long timestamp;
+} GlopFileDropEvent;
+
+// These match system.CursorShape.
+#define glopCursorArrow      0
+#define glopCursorHand       1
+#define glopCursorIBeam      2
+#define glopCursorCrosshair  3
+
+typedef struct {
+  int x, y, dx, dy;
+  float dpi;
+  float refresh_rate;
+  int primary;
+} GlopDisplay;
+
 void GlopInit();
 
 //void CreateWindow(void**, void**, int, int, int, int);
@@ -94,13 +137,26 @@ void* GlopCreateWindow(
     int full_screen,
     int stencil_bits,
     int is_resizable);
+void GlopSetTitle(void* window, void* title);
+void GlopSetFullScreen(void* window, int full_screen);
+void GlopSetWindowSize(void* window, int width, int height);
+void GlopMinimizeWindow(void* window);
+void GlopSetIcon(void* window, void* pixels, int dx, int dy);
 
 void GlopSwapBuffers(void*);
 
+// Shared contexts share textures and buffers with the window's context, so that they can be made
+// on another thread.  GlopCreateSharedContext() returns NULL if it can't make one.
+// GlopMakeContextCurrent() makes a shared context current on the calling thread, or releases the
+// thread's context if context is NULL, and returns 0 if it fails.
+void* GlopCreateSharedContext(void* _window);
+int GlopMakeContextCurrent(void* _window, void* context);
+void GlopDestroyContext(void* context);
+
 void GlopThink();
 
 typedef struct {
-  short index;
+  int index;
   short device;
   float press_amt;
   long long timestamp;
@@ -120,12 +176,41 @@ void GlopClearKeyEvent(GlopKeyEvent* event) {
   event->caps_lock = 0;
 }
 
+typedef struct {
+  int rune;
+  long long timestamp;
+} GlopTextEvent;
+
 void GlopGetInputEvents(void* _window, void** _events_ret, void* _num_events, void* _horizon);
+void GlopGetTextEvents(void* _window, void** _events_ret, void* _num_events);
+void GlopGetWindowEvents(void* _window, void** _events_ret, int* length);
+void GlopGetFileDropEvents(void* _window, void** _events_ret, int* length);
+
+int GlopGetNumJoysticks(void* _window);
+void GlopRefreshJoysticks(void* _window);
+
+// Runs the motors of the XInput controller in slot user_index, from 0 to 3, with low and high from
+// 0 to 1.  Returns 0 if there is no such controller or XInput isn't available.
+int GlopSetRumble(int user_index, float low, float high);
 
 void GlopGetMousePosition(int* x,int* y);
 void GlopGetWindowDims(void* _window, int* x, int* y, int* dx, int* dy);
 
-void GlopEnableVSync(int);
+void GlopSetSwapInterval(int);
+void GlopGetDisplays(void** _displays_ret, int* length);
+void GlopSetClipboard(void* _window, void* text);
+void GlopGetClipboard(void* _window, void** _text, int* length);
+void GlopSetRelativeMouseMode(void* _window, int relative);
+void GlopSetCursorShape(void* _window, int shape);
+void GlopSetCustomCursor(void* _window, void* pixels, int dx, int dy, int hot_x, int hot_y);
+
+// Audio is played from whichever thread calls these, not the render thread.  GlopOpenAudio() opens
+// the default device for interleaved stereo float samples at about rate samples per second, and
+// returns the rate it actually plays at or 0 if it couldn't open it.  GlopWriteAudio() blocks until
+// frames frames from samples have been queued, and returns 0 if the device failed.
+int GlopOpenAudio(int rate);
+int GlopWriteAudio(void* samples, int frames);
+void GlopCloseAudio();
 
 // GetInputEvents(KeyEvent**, length*, horizon*);
 
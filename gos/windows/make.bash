@@ -1,5 +1,5 @@
 g++ -o glop.o -m32 -c -Iinclude glop.cpp
-g++ -o libglop.dll glop.o -shared -lopengl32 -lgdi32 -ldxguid -lwinmm -ldinput
+g++ -o libglop.dll glop.o -shared -lopengl32 -lgdi32 -ldxguid -lwinmm -ldinput -lshell32 -lole32
 rm glop.o
 
 mkdir -p lib
@@ -0,0 +1,15 @@
+package x11_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(SetupSpec)
+	r.AddSpec(RequestSpec)
+	r.AddSpec(ReplySpec)
+	r.AddSpec(EventSpec)
+	gospec.MainGoTest(r, t)
+}
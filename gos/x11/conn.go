@@ -0,0 +1,354 @@
+// Package x11 is a small client for the X11 wire protocol, written in pure Go
+// so that the glop_purego backend can open windows and read input without cgo
+// or Xlib.  It only implements the requests and events that glop uses.
+package x11
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Everything is sent and received little endian, the server converts for us.
+var order = binary.LittleEndian
+
+type Window uint32
+type Atom uint32
+
+// Screen is the part of a screen's description from the connection setup that
+// glop needs.
+type Screen struct {
+	Root                Window
+	RootVisual          uint32
+	RootDepth           byte
+	Width, Height       int
+	WidthMm, HeightMm   int
+	WhitePixel          uint32
+	BlackPixel          uint32
+	DefaultColormap     uint32
+	MinKeycode          byte
+	MaxKeycode          byte
+	ResourceIdBase      uint32
+	ResourceIdMask      uint32
+	MaximumRequestBytes int
+}
+
+// An Event is the raw 32 bytes of an event from the server along with the
+// time, on the same clock as time.Now(), that it was read.  GenericEvents can
+// be longer than 32 bytes.
+type Event struct {
+	Data []byte
+	Read time.Time
+}
+
+func (e Event) Code() byte {
+	return e.Data[0] & 0x7f
+}
+
+// Conn is a connection to an X server.  Requests may be made from any
+// goroutine.
+type Conn struct {
+	conn   net.Conn
+	Screen Screen
+
+	// Protects everything below, including writes to conn.
+	mutex   sync.Mutex
+	seq     uint16
+	next_id uint32
+	replies map[uint16]chan reply
+	events  []Event
+	err     error
+
+	// Called from the reading goroutine for each event as it arrives, before it
+	// is queued.  If it returns false the event is not queued.
+	filter func(Event) bool
+}
+
+type reply struct {
+	data []byte
+	err  error
+}
+
+// Dial connects to the X server named by display, which is in the same format
+// as the DISPLAY environment variable, e.g. ":0" or "host:1.0".  If display is
+// empty $DISPLAY is used.
+func Dial(display string) (*Conn, error) {
+	if display == "" {
+		display = os.Getenv("DISPLAY")
+	}
+	colon := strings.LastIndex(display, ":")
+	if colon == -1 {
+		return nil, fmt.Errorf("Bad display name '%s'.", display)
+	}
+	host := display[:colon]
+	number := display[colon+1:]
+	if dot := strings.Index(number, "."); dot != -1 {
+		number = number[:dot]
+	}
+	if _, err := strconv.Atoi(number); err != nil {
+		return nil, fmt.Errorf("Bad display name '%s'.", display)
+	}
+	var conn net.Conn
+	var err error
+	if host == "" || host == "unix" {
+		conn, err = net.Dial("unix", "/tmp/.X11-unix/X"+number)
+	} else {
+		port, _ := strconv.Atoi(number)
+		conn, err = net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(6000+port)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	auth_name, auth_data := readXauthority(host, number)
+	c, err := NewConn(conn, auth_name, auth_data)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewConn does the connection setup over conn, which must already be connected
+// to an X server, with the given authorization protocol, which can be empty.
+func NewConn(conn net.Conn, auth_name string, auth_data []byte) (*Conn, error) {
+	c := &Conn{
+		conn:    conn,
+		replies: make(map[uint16]chan reply),
+	}
+	if err := c.setup(auth_name, auth_data); err != nil {
+		return nil, err
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Returns the MIT-MAGIC-COOKIE-1 for the display, if there is one in the
+// Xauthority file.
+func readXauthority(host, number string) (name string, data []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		path = filepath.Join(os.Getenv("HOME"), ".Xauthority")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil
+	}
+	defer f.Close()
+	hostname, _ := os.Hostname()
+	r := bufio.NewReader(f)
+	readString := func() ([]byte, error) {
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		b := make([]byte, length)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	for {
+		var family uint16
+		if binary.Read(r, binary.BigEndian, &family) != nil {
+			return "", nil
+		}
+		address, err1 := readString()
+		display, err2 := readString()
+		auth_name, err3 := readString()
+		auth_data, err4 := readString()
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return "", nil
+		}
+		// 256 is FamilyLocal, 65535 is FamilyWild.
+		local := family == 256 && (host == "" || host == "unix") && string(address) == hostname
+		if (local || family == 65535) && string(display) == number && string(auth_name) == "MIT-MAGIC-COOKIE-1" {
+			return string(auth_name), auth_data
+		}
+	}
+}
+
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+func (c *Conn) setup(auth_name string, auth_data []byte) error {
+	buf := make([]byte, 12+len(auth_name)+pad(len(auth_name))+len(auth_data)+pad(len(auth_data)))
+	buf[0] = 'l'
+	order.PutUint16(buf[2:], 11)
+	order.PutUint16(buf[4:], 0)
+	order.PutUint16(buf[6:], uint16(len(auth_name)))
+	order.PutUint16(buf[8:], uint16(len(auth_data)))
+	copy(buf[12:], auth_name)
+	copy(buf[12+len(auth_name)+pad(len(auth_name)):], auth_data)
+	if _, err := c.conn.Write(buf); err != nil {
+		return err
+	}
+
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(c.conn, head); err != nil {
+		return err
+	}
+	body := make([]byte, 4*int(order.Uint16(head[6:])))
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		return err
+	}
+	switch head[0] {
+	case 0:
+		return fmt.Errorf("X server refused the connection: %s", string(body[:head[1]]))
+	case 2:
+		return fmt.Errorf("X server requires further authentication.")
+	}
+
+	// body starts at byte 8 of the setup reply.
+	s := &c.Screen
+	s.ResourceIdBase = order.Uint32(body[4:])
+	s.ResourceIdMask = order.Uint32(body[8:])
+	vendor_length := int(order.Uint16(body[16:]))
+	s.MaximumRequestBytes = 4 * int(order.Uint16(body[18:]))
+	num_formats := int(body[21])
+	s.MinKeycode = body[26]
+	s.MaxKeycode = body[27]
+	screen := body[32+vendor_length+pad(vendor_length)+8*num_formats:]
+	s.Root = Window(order.Uint32(screen[0:]))
+	s.DefaultColormap = order.Uint32(screen[4:])
+	s.WhitePixel = order.Uint32(screen[8:])
+	s.BlackPixel = order.Uint32(screen[12:])
+	s.Width = int(order.Uint16(screen[20:]))
+	s.Height = int(order.Uint16(screen[22:]))
+	s.WidthMm = int(order.Uint16(screen[24:]))
+	s.HeightMm = int(order.Uint16(screen[26:]))
+	s.RootVisual = order.Uint32(screen[32:])
+	s.RootDepth = screen[38]
+	return nil
+}
+
+// NewId returns an unused resource id for a window, pixmap, or other resource.
+func (c *Conn) NewId() uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	mask := c.Screen.ResourceIdMask
+	id := c.next_id & mask
+	c.next_id += mask & -mask
+	return c.Screen.ResourceIdBase | id
+}
+
+// SetEventFilter sets a function that sees every event as soon as it is read,
+// see Conn.filter.
+func (c *Conn) SetEventFilter(filter func(Event) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.filter = filter
+}
+
+// Events returns all events received since the last call to Events.
+func (c *Conn) Events() []Event {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	events := c.events
+	c.events = nil
+	return events
+}
+
+// Err returns the error that closed the connection, if it has been closed.
+func (c *Conn) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+func (c *Conn) readLoop() {
+	head := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(c.conn, head); err != nil {
+			c.close(err)
+			return
+		}
+		data := append([]byte(nil), head...)
+		// Replies and GenericEvents can be longer than 32 bytes.
+		if head[0] == 1 || head[0]&0x7f == 35 {
+			extra := make([]byte, 4*int(order.Uint32(head[4:])))
+			if _, err := io.ReadFull(c.conn, extra); err != nil {
+				c.close(err)
+				return
+			}
+			data = append(data, extra...)
+		}
+		seq := order.Uint16(head[2:])
+		c.mutex.Lock()
+		switch head[0] {
+		case 0:
+			if ch, ok := c.replies[seq]; ok {
+				delete(c.replies, seq)
+				ch <- reply{err: fmt.Errorf("X error %d for request %d.", head[1], head[10])}
+			}
+		case 1:
+			if ch, ok := c.replies[seq]; ok {
+				delete(c.replies, seq)
+				ch <- reply{data: data}
+			}
+		default:
+			event := Event{Data: data, Read: time.Now()}
+			filter := c.filter
+			c.mutex.Unlock()
+			keep := filter == nil || filter(event)
+			c.mutex.Lock()
+			if keep {
+				c.events = append(c.events, event)
+			}
+		}
+		c.mutex.Unlock()
+	}
+}
+
+func (c *Conn) close(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.err = err
+	for seq, ch := range c.replies {
+		ch <- reply{err: err}
+		delete(c.replies, seq)
+	}
+}
+
+// Close closes the connection to the server.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Sends a request, which must already be padded to a multiple of 4 bytes, and
+// fills in its length.  If want_reply is set the returned channel gets the
+// reply or an error.
+func (c *Conn) send(req []byte, want_reply bool) chan reply {
+	order.PutUint16(req[2:], uint16(len(req)/4))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var ch chan reply
+	if c.err != nil {
+		if want_reply {
+			ch = make(chan reply, 1)
+			ch <- reply{err: c.err}
+		}
+		return ch
+	}
+	c.seq++
+	if want_reply {
+		ch = make(chan reply, 1)
+		c.replies[c.seq] = ch
+	}
+	if _, err := c.conn.Write(req); err != nil {
+		c.err = err
+	}
+	return ch
+}
+
+// Sends a request and waits for its reply.
+func (c *Conn) roundTrip(req []byte) ([]byte, error) {
+	r := <-c.send(req, true)
+	return r.data, r.err
+}
@@ -0,0 +1,443 @@
+package x11
+
+import (
+	"fmt"
+)
+
+// Event masks
+const (
+	KeyPressMask             = 1 << 0
+	KeyReleaseMask           = 1 << 1
+	ButtonPressMask          = 1 << 2
+	ButtonReleaseMask        = 1 << 3
+	PointerMotionMask        = 1 << 6
+	StructureNotifyMask      = 1 << 17
+	SubstructureNotifyMask   = 1 << 19
+	SubstructureRedirectMask = 1 << 20
+	FocusChangeMask          = 1 << 21
+	PropertyChangeMask       = 1 << 22
+)
+
+// Event codes
+const (
+	KeyPress         = 2
+	KeyRelease       = 3
+	ButtonPress      = 4
+	ButtonRelease    = 5
+	MotionNotify     = 6
+	FocusIn          = 9
+	FocusOut         = 10
+	UnmapNotify      = 18
+	MapNotify        = 19
+	ConfigureNotify  = 22
+	SelectionClear   = 29
+	SelectionRequest = 30
+	SelectionNotify  = 31
+	ClientMessage    = 33
+)
+
+// Predefined atoms
+const (
+	AtomNone          Atom = 0
+	AtomAtom          Atom = 4
+	AtomCardinal      Atom = 6
+	AtomString        Atom = 31
+	AtomWmName        Atom = 39
+	AtomWmNormalHints Atom = 40
+	AtomWmSizeHints   Atom = 41
+)
+
+// Makes a request with the given opcode and detail byte and room for n more
+// bytes after the 4 byte header, rounded up to a multiple of 4.
+func request(opcode, detail byte, n int) []byte {
+	req := make([]byte, 4+n+pad(n))
+	req[0] = opcode
+	req[1] = detail
+	return req
+}
+
+// CreateWindow creates an InputOutput window on the root window with the root
+// visual, a black background, and the given event mask.
+func (c *Conn) CreateWindow(x, y, width, height int, event_mask uint32) Window {
+	id := Window(c.NewId())
+	req := request(1, c.Screen.RootDepth, 36)
+	order.PutUint32(req[4:], uint32(id))
+	order.PutUint32(req[8:], uint32(c.Screen.Root))
+	order.PutUint16(req[12:], uint16(int16(x)))
+	order.PutUint16(req[14:], uint16(int16(y)))
+	order.PutUint16(req[16:], uint16(width))
+	order.PutUint16(req[18:], uint16(height))
+	order.PutUint16(req[20:], 0) // border width
+	order.PutUint16(req[22:], 1) // InputOutput
+	order.PutUint32(req[24:], c.Screen.RootVisual)
+	order.PutUint32(req[28:], 0x2|0x800) // CWBackPixel | CWEventMask
+	order.PutUint32(req[32:], c.Screen.BlackPixel)
+	order.PutUint32(req[36:], event_mask)
+	c.send(req, false)
+	return id
+}
+
+func (c *Conn) MapWindow(w Window) {
+	req := request(8, 0, 4)
+	order.PutUint32(req[4:], uint32(w))
+	c.send(req, false)
+}
+
+// ResizeWindow sets the size of w with ConfigureWindow.
+func (c *Conn) ResizeWindow(w Window, width, height int) {
+	req := request(12, 0, 16)
+	order.PutUint32(req[4:], uint32(w))
+	order.PutUint16(req[8:], 0x4|0x8) // width | height
+	order.PutUint32(req[12:], uint32(width))
+	order.PutUint32(req[16:], uint32(height))
+	c.send(req, false)
+}
+
+// InternAtom returns the atom with the given name, creating it if necessary.
+func (c *Conn) InternAtom(name string) (Atom, error) {
+	req := request(16, 0, 4+len(name))
+	order.PutUint16(req[4:], uint16(len(name)))
+	copy(req[8:], name)
+	data, err := c.roundTrip(req)
+	if err != nil {
+		return AtomNone, err
+	}
+	return Atom(order.Uint32(data[8:])), nil
+}
+
+// ChangeProperty replaces the property on w with data, which is made up of
+// format-bit items.
+func (c *Conn) ChangeProperty(w Window, property, typ Atom, format byte, data []byte) {
+	req := request(18, 0, 20+len(data))
+	order.PutUint32(req[4:], uint32(w))
+	order.PutUint32(req[8:], uint32(property))
+	order.PutUint32(req[12:], uint32(typ))
+	req[16] = format
+	order.PutUint32(req[20:], uint32(len(data)/int(format/8)))
+	copy(req[24:], data)
+	c.send(req, false)
+}
+
+// ChangeProperty32 is ChangeProperty with 32-bit items.
+func (c *Conn) ChangeProperty32(w Window, property, typ Atom, values []uint32) {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		order.PutUint32(data[4*i:], v)
+	}
+	c.ChangeProperty(w, property, typ, 32, data)
+}
+
+// GetProperty returns the value of a property on w and deletes it if delete
+// is set.
+func (c *Conn) GetProperty(w Window, property Atom, delete bool) (typ Atom, format byte, data []byte, err error) {
+	req := request(20, 0, 20)
+	if delete {
+		req[1] = 1
+	}
+	order.PutUint32(req[4:], uint32(w))
+	order.PutUint32(req[8:], uint32(property))
+	order.PutUint32(req[12:], 0)     // AnyPropertyType
+	order.PutUint32(req[16:], 0)     // long-offset
+	order.PutUint32(req[20:], 1<<22) // long-length
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return AtomNone, 0, nil, err
+	}
+	format = reply[1]
+	typ = Atom(order.Uint32(reply[8:]))
+	n := int(order.Uint32(reply[16:]))
+	if format == 0 {
+		return typ, format, nil, nil
+	}
+	size := n * int(format/8)
+	if 32+size > len(reply) {
+		return AtomNone, 0, nil, fmt.Errorf("Short GetProperty reply.")
+	}
+	return typ, format, reply[32 : 32+size], nil
+}
+
+func (c *Conn) SetSelectionOwner(owner Window, selection Atom) {
+	req := request(22, 0, 12)
+	order.PutUint32(req[4:], uint32(owner))
+	order.PutUint32(req[8:], uint32(selection))
+	order.PutUint32(req[12:], 0) // CurrentTime
+	c.send(req, false)
+}
+
+// ConvertSelection asks the owner of selection to store it in property on
+// requestor as target, a SelectionNotify event says when it is done.  t is a
+// server timestamp, 0 means CurrentTime.
+func (c *Conn) ConvertSelection(requestor Window, selection, target, property Atom, t uint32) {
+	req := request(24, 0, 20)
+	order.PutUint32(req[4:], uint32(requestor))
+	order.PutUint32(req[8:], uint32(selection))
+	order.PutUint32(req[12:], uint32(target))
+	order.PutUint32(req[16:], uint32(property))
+	order.PutUint32(req[20:], t)
+	c.send(req, false)
+}
+
+// SendEvent sends a 32 byte event to destination.
+func (c *Conn) SendEvent(destination Window, event_mask uint32, event []byte) {
+	req := request(25, 0, 40)
+	order.PutUint32(req[4:], uint32(destination))
+	order.PutUint32(req[8:], event_mask)
+	copy(req[12:], event)
+	c.send(req, false)
+}
+
+// SendClientMessageTo sends a ClientMessage about w with 32-bit data straight to
+// destination, which is how drag and drop messages are passed around.
+func (c *Conn) SendClientMessageTo(destination, w Window, typ Atom, data [5]uint32) {
+	c.SendEvent(destination, 0, clientMessage(w, typ, data))
+}
+
+// SendClientMessage sends a ClientMessage about w with 32-bit data to the root
+// window, which is how window manager hints like _NET_WM_STATE are changed.
+func (c *Conn) SendClientMessage(w Window, typ Atom, data [5]uint32) {
+	c.SendEvent(c.Screen.Root, SubstructureNotifyMask|SubstructureRedirectMask, clientMessage(w, typ, data))
+}
+
+func clientMessage(w Window, typ Atom, data [5]uint32) []byte {
+	event := make([]byte, 32)
+	event[0] = ClientMessage
+	event[1] = 32
+	order.PutUint32(event[4:], uint32(w))
+	order.PutUint32(event[8:], uint32(typ))
+	for i, d := range data {
+		order.PutUint32(event[12+4*i:], d)
+	}
+	return event
+}
+
+// QueryPointer returns the position of the pointer relative to the root
+// window and to w.
+func (c *Conn) QueryPointer(w Window) (root_x, root_y, win_x, win_y int, err error) {
+	req := request(38, 0, 4)
+	order.PutUint32(req[4:], uint32(w))
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	root_x = int(int16(order.Uint16(reply[16:])))
+	root_y = int(int16(order.Uint16(reply[18:])))
+	win_x = int(int16(order.Uint16(reply[20:])))
+	win_y = int(int16(order.Uint16(reply[22:])))
+	return root_x, root_y, win_x, win_y, nil
+}
+
+// WarpPointer moves the pointer to (x, y) on the root window.
+func (c *Conn) WarpPointer(x, y int) {
+	req := request(41, 0, 20)
+	order.PutUint32(req[4:], 0) // src-window None
+	order.PutUint32(req[8:], uint32(c.Screen.Root))
+	order.PutUint16(req[20:], uint16(int16(x)))
+	order.PutUint16(req[22:], uint16(int16(y)))
+	c.send(req, false)
+}
+
+// GetGeometry returns the size of w.
+func (c *Conn) GetGeometry(w Window) (width, height int, err error) {
+	req := request(14, 0, 4)
+	order.PutUint32(req[4:], uint32(w))
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(order.Uint16(reply[16:])), int(order.Uint16(reply[18:])), nil
+}
+
+// RootPosition returns the position of (0, 0) on w relative to the
+// root window.
+func (c *Conn) RootPosition(w Window) (x, y int, err error) {
+	req := request(40, 0, 12)
+	order.PutUint32(req[4:], uint32(w))
+	order.PutUint32(req[8:], uint32(c.Screen.Root))
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(int16(order.Uint16(reply[12:]))), int(int16(order.Uint16(reply[14:]))), nil
+}
+
+// GetKeyboardMapping returns the keysyms for every keycode from
+// Screen.MinKeycode to Screen.MaxKeycode, indexed by keycode.
+func (c *Conn) GetKeyboardMapping() ([][]uint32, error) {
+	first := c.Screen.MinKeycode
+	count := int(c.Screen.MaxKeycode) - int(first) + 1
+	req := request(101, 0, 4)
+	req[4] = first
+	req[5] = byte(count)
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	per := int(reply[1])
+	keysyms := make([][]uint32, int(first)+count)
+	for i := 0; i < count; i++ {
+		syms := make([]uint32, per)
+		for j := range syms {
+			offset := 32 + 4*(i*per+j)
+			if offset+4 <= len(reply) {
+				syms[j] = order.Uint32(reply[offset:])
+			}
+		}
+		keysyms[int(first)+i] = syms
+	}
+	return keysyms, nil
+}
+
+// BlankCursor makes a cursor that is completely transparent.
+func (c *Conn) BlankCursor(w Window) uint32 {
+	return c.BitmapCursor(w, 1, 1, []bool{false}, []bool{false}, [3]uint16{}, [3]uint16{}, 0, 0)
+}
+
+// Makes a width by height bitmap on w with the given pixels set.
+func (c *Conn) bitmap(w Window, width, height int, bits []bool) uint32 {
+	pixmap := c.NewId()
+	req := request(53, 1, 12) // CreatePixmap, depth 1
+	order.PutUint32(req[4:], pixmap)
+	order.PutUint32(req[8:], uint32(w))
+	order.PutUint16(req[12:], uint16(width))
+	order.PutUint16(req[14:], uint16(height))
+	c.send(req, false)
+
+	// The contents of a new pixmap are undefined, so clear it first.
+	gc := c.NewId()
+	req = request(55, 0, 16) // CreateGC
+	order.PutUint32(req[4:], gc)
+	order.PutUint32(req[8:], pixmap)
+	order.PutUint32(req[12:], 0x4) // GCForeground
+	order.PutUint32(req[16:], 0)
+	c.send(req, false)
+	req = request(70, 0, 16) // PolyFillRectangle
+	order.PutUint32(req[4:], pixmap)
+	order.PutUint32(req[8:], gc)
+	order.PutUint16(req[16:], uint16(width))
+	order.PutUint16(req[18:], uint16(height))
+	c.send(req, false)
+
+	var points []int
+	for i, bit := range bits {
+		if bit {
+			points = append(points, i)
+		}
+	}
+	if len(points) > 0 {
+		req = request(56, 0, 8) // ChangeGC
+		order.PutUint32(req[4:], gc)
+		order.PutUint32(req[8:], 0x4) // GCForeground
+		order.PutUint32(req[12:], 1)
+		c.send(req, false)
+	}
+	// Keep each PolyPoint well under the maximum request length.
+	for len(points) > 0 {
+		n := len(points)
+		if n > 8192 {
+			n = 8192
+		}
+		req = request(64, 0, 8+4*n) // PolyPoint, CoordModeOrigin
+		order.PutUint32(req[4:], pixmap)
+		order.PutUint32(req[8:], gc)
+		for i, p := range points[:n] {
+			order.PutUint16(req[12+4*i:], uint16(p%width))
+			order.PutUint16(req[14+4*i:], uint16(p/width))
+		}
+		c.send(req, false)
+		points = points[n:]
+	}
+
+	req = request(60, 0, 4) // FreeGC
+	order.PutUint32(req[4:], gc)
+	c.send(req, false)
+	return pixmap
+}
+
+func (c *Conn) freePixmap(pixmap uint32) {
+	req := request(54, 0, 4)
+	order.PutUint32(req[4:], pixmap)
+	c.send(req, false)
+}
+
+// BitmapCursor makes a two color cursor.  Pixels set in source are drawn in the
+// fore color, other pixels set in mask are drawn in the back color, and the
+// rest are transparent.  Colors are 16-bit RGB.
+func (c *Conn) BitmapCursor(w Window, width, height int, source, mask []bool, fore, back [3]uint16, hot_x, hot_y int) uint32 {
+	source_pixmap := c.bitmap(w, width, height, source)
+	mask_pixmap := c.bitmap(w, width, height, mask)
+	cursor := c.NewId()
+	req := request(93, 0, 28) // CreateCursor
+	order.PutUint32(req[4:], cursor)
+	order.PutUint32(req[8:], source_pixmap)
+	order.PutUint32(req[12:], mask_pixmap)
+	for i := 0; i < 3; i++ {
+		order.PutUint16(req[16+2*i:], fore[i])
+		order.PutUint16(req[22+2*i:], back[i])
+	}
+	order.PutUint16(req[28:], uint16(hot_x))
+	order.PutUint16(req[30:], uint16(hot_y))
+	c.send(req, false)
+	c.freePixmap(source_pixmap)
+	c.freePixmap(mask_pixmap)
+	return cursor
+}
+
+// FontCursor makes one of the standard cursors from the cursor font, glyph is
+// one of the XC_ values from X11/cursorfont.h.
+func (c *Conn) FontCursor(glyph uint16) uint32 {
+	font := c.NewId()
+	req := request(45, 0, 8+len("cursor")) // OpenFont
+	order.PutUint32(req[4:], font)
+	order.PutUint16(req[8:], uint16(len("cursor")))
+	copy(req[12:], "cursor")
+	c.send(req, false)
+
+	cursor := c.NewId()
+	req = request(94, 0, 28) // CreateGlyphCursor
+	order.PutUint32(req[4:], cursor)
+	order.PutUint32(req[8:], font)
+	order.PutUint32(req[12:], font)
+	order.PutUint16(req[16:], glyph)
+	order.PutUint16(req[18:], glyph+1) // each glyph's mask follows it
+	order.PutUint16(req[26:], 0xffff)  // black on white
+	order.PutUint16(req[28:], 0xffff)
+	order.PutUint16(req[30:], 0xffff)
+	c.send(req, false)
+
+	req = request(46, 0, 4) // CloseFont
+	order.PutUint32(req[4:], font)
+	c.send(req, false)
+	return cursor
+}
+
+func (c *Conn) FreeCursor(cursor uint32) {
+	req := request(95, 0, 4)
+	order.PutUint32(req[4:], cursor)
+	c.send(req, false)
+}
+
+// SetCursor sets the cursor shown over w, 0 restores the parent's cursor.
+func (c *Conn) SetCursor(w Window, cursor uint32) {
+	req := request(2, 0, 12) // ChangeWindowAttributes
+	order.PutUint32(req[4:], uint32(w))
+	order.PutUint32(req[8:], 0x4000) // CWCursor
+	order.PutUint32(req[12:], cursor)
+	c.send(req, false)
+}
+
+// GrabPointer sends all pointer events to w and keeps the pointer inside it.
+// The server's reply, which says whether the grab worked, is ignored.
+func (c *Conn) GrabPointer(w Window, event_mask uint16) {
+	req := request(26, 1, 20) // owner-events
+	order.PutUint32(req[4:], uint32(w))
+	order.PutUint16(req[8:], event_mask)
+	req[10] = 1 // pointer-mode Asynchronous
+	req[11] = 1 // keyboard-mode Asynchronous
+	order.PutUint32(req[12:], uint32(w))
+	c.send(req, false)
+}
+
+func (c *Conn) UngrabPointer() {
+	req := request(27, 0, 4)
+	c.send(req, false)
+}
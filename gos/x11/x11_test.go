@@ -0,0 +1,400 @@
+package x11_test
+
+import (
+	"encoding/binary"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gos/x11"
+	"io"
+	"net"
+	"time"
+)
+
+var le = binary.LittleEndian
+
+// A setup reply for a single 1920x1080 screen with a root window of 0x100.
+func setupReply() []byte {
+	body := make([]byte, 76)
+	le.PutUint32(body[4:], 0x04000000) // resource-id-base
+	le.PutUint32(body[8:], 0x001fffff) // resource-id-mask
+	le.PutUint16(body[16:], 4)         // length of vendor
+	le.PutUint16(body[18:], 0xffff)    // maximum-request-length
+	body[26] = 8                       // min-keycode
+	body[27] = 255                     // max-keycode
+	copy(body[32:], "glop")
+	screen := body[36:]
+	le.PutUint32(screen[0:], 0x100)    // root
+	le.PutUint32(screen[4:], 0x20)     // default-colormap
+	le.PutUint32(screen[8:], 0xffffff) // white-pixel
+	le.PutUint32(screen[12:], 0)       // black-pixel
+	le.PutUint16(screen[20:], 1920)    // width-in-pixels
+	le.PutUint16(screen[22:], 1080)    // height-in-pixels
+	le.PutUint16(screen[24:], 508)     // width-in-millimeters
+	le.PutUint16(screen[26:], 286)     // height-in-millimeters
+	le.PutUint32(screen[32:], 0x21)    // root-visual
+	screen[38] = 24                    // root-depth
+	head := make([]byte, 8)
+	head[0] = 1
+	le.PutUint16(head[2:], 11)
+	le.PutUint16(head[6:], uint16(len(body)/4))
+	return append(head, body...)
+}
+
+// An X server on the other end of a net.Pipe.  Every request it reads is sent
+// on requests, and if reply is set whatever it returns is written back.
+type fakeServer struct {
+	conn     net.Conn
+	setup    chan []byte
+	requests chan []byte
+}
+
+func startServer(setup_reply []byte, reply func(seq uint16, req []byte) []byte) (net.Conn, *fakeServer) {
+	client, server := net.Pipe()
+	s := &fakeServer{
+		conn:     server,
+		setup:    make(chan []byte, 1),
+		requests: make(chan []byte, 100),
+	}
+	go func() {
+		head := make([]byte, 12)
+		if _, err := io.ReadFull(server, head); err != nil {
+			return
+		}
+		n := int(le.Uint16(head[6:]))
+		d := int(le.Uint16(head[8:]))
+		rest := make([]byte, (n+3)/4*4+(d+3)/4*4)
+		if _, err := io.ReadFull(server, rest); err != nil {
+			return
+		}
+		s.setup <- append(head, rest...)
+		if _, err := server.Write(setup_reply); err != nil {
+			return
+		}
+		var seq uint16
+		for {
+			head := make([]byte, 4)
+			if _, err := io.ReadFull(server, head); err != nil {
+				return
+			}
+			req := make([]byte, 4*int(le.Uint16(head[2:])))
+			copy(req, head)
+			if _, err := io.ReadFull(server, req[4:]); err != nil {
+				return
+			}
+			seq++
+			s.requests <- req
+			if reply != nil {
+				if data := reply(seq, req); data != nil {
+					server.Write(data)
+				}
+			}
+		}
+	}()
+	return client, s
+}
+
+func dialFake(c gospec.Context, reply func(seq uint16, req []byte) []byte) (*x11.Conn, *fakeServer) {
+	client, s := startServer(setupReply(), reply)
+	x, err := x11.NewConn(client, "", nil)
+	c.Assume(err, IsNil)
+	return x, s
+}
+
+func (s *fakeServer) next() []byte {
+	select {
+	case req := <-s.requests:
+		return req
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+// Makes a 32 byte reply to seq, followed by extra.
+func makeReply(seq uint16, detail byte, extra []byte) []byte {
+	data := make([]byte, 32+len(extra))
+	data[0] = 1
+	data[1] = detail
+	le.PutUint16(data[2:], seq)
+	le.PutUint32(data[4:], uint32(len(extra)/4))
+	copy(data[32:], extra)
+	return data
+}
+
+// Waits up to a second for n events to show up on x.
+func waitForEvents(x *x11.Conn, n int) []x11.Event {
+	var events []x11.Event
+	deadline := time.Now().Add(time.Second)
+	for len(events) < n && time.Now().Before(deadline) {
+		events = append(events, x.Events()...)
+		time.Sleep(time.Millisecond)
+	}
+	return events
+}
+
+func SetupSpec(c gospec.Context) {
+	c.Specify("The setup request names the byte order, version and authorization.", func() {
+		client, s := startServer(setupReply(), nil)
+		cookie := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+		x, err := x11.NewConn(client, "MIT-MAGIC-COOKIE-1", cookie)
+		c.Assume(err, IsNil)
+		defer x.Close()
+		expected := []byte{'l', 0, 11, 0, 0, 0, 18, 0, 16, 0, 0, 0}
+		expected = append(expected, "MIT-MAGIC-COOKIE-1"...)
+		expected = append(expected, 0, 0)
+		expected = append(expected, cookie...)
+		c.Expect(<-s.setup, ContainsInOrder, expected)
+	})
+	c.Specify("The screen is read from the setup reply.", func() {
+		x, _ := dialFake(c, nil)
+		defer x.Close()
+		c.Expect(x.Screen.Root, Equals, x11.Window(0x100))
+		c.Expect(x.Screen.DefaultColormap, Equals, uint32(0x20))
+		c.Expect(x.Screen.WhitePixel, Equals, uint32(0xffffff))
+		c.Expect(x.Screen.Width, Equals, 1920)
+		c.Expect(x.Screen.Height, Equals, 1080)
+		c.Expect(x.Screen.WidthMm, Equals, 508)
+		c.Expect(x.Screen.HeightMm, Equals, 286)
+		c.Expect(x.Screen.RootVisual, Equals, uint32(0x21))
+		c.Expect(x.Screen.RootDepth, Equals, byte(24))
+		c.Expect(x.Screen.MinKeycode, Equals, byte(8))
+		c.Expect(x.Screen.MaxKeycode, Equals, byte(255))
+		c.Expect(x.Screen.MaximumRequestBytes, Equals, 4*0xffff)
+	})
+	c.Specify("Resource ids come from the id base and mask.", func() {
+		x, _ := dialFake(c, nil)
+		defer x.Close()
+		c.Expect(x.NewId(), Equals, uint32(0x04000000))
+		c.Expect(x.NewId(), Equals, uint32(0x04000001))
+	})
+	c.Specify("A refused connection returns the server's reason.", func() {
+		reason := "No protocol specified"
+		refused := make([]byte, 8+24)
+		refused[1] = byte(len(reason))
+		le.PutUint16(refused[2:], 11)
+		le.PutUint16(refused[6:], 6)
+		copy(refused[8:], reason)
+		client, _ := startServer(refused, nil)
+		_, err := x11.NewConn(client, "", nil)
+		c.Assume(err, Not(IsNil))
+		c.Expect(err.Error(), Equals, "X server refused the connection: No protocol specified")
+	})
+}
+
+func RequestSpec(c gospec.Context) {
+	x, s := dialFake(c, nil)
+	defer x.Close()
+	c.Specify("MapWindow is a single word after the header.", func() {
+		x.MapWindow(0x1234)
+		c.Expect(s.next(), ContainsInOrder, []byte{8, 0, 2, 0, 0x34, 0x12, 0, 0})
+	})
+	c.Specify("CreateWindow uses the root window, visual and depth.", func() {
+		w := x.CreateWindow(10, -20, 640, 480, x11.KeyPressMask|x11.StructureNotifyMask)
+		c.Expect(w, Equals, x11.Window(0x04000000))
+		c.Expect(s.next(), ContainsInOrder, []byte{
+			1, 24, 10, 0,
+			0, 0, 0, 4, // wid
+			0, 1, 0, 0, // parent
+			10, 0, 0xec, 0xff, // x, y
+			0x80, 2, 0xe0, 1, // width, height
+			0, 0, 1, 0, // border-width, InputOutput
+			0x21, 0, 0, 0, // visual
+			2, 8, 0, 0, // CWBackPixel | CWEventMask
+			0, 0, 0, 0, // background-pixel
+			1, 0, 2, 0, // event-mask
+		})
+	})
+	c.Specify("ChangeProperty32 sends its values as 32-bit items.", func() {
+		x.ChangeProperty32(0x1234, x11.Atom(300), x11.AtomCardinal, []uint32{1, 2})
+		c.Expect(s.next(), ContainsInOrder, []byte{
+			18, 0, 8, 0,
+			0x34, 0x12, 0, 0,
+			0x2c, 1, 0, 0,
+			6, 0, 0, 0,
+			32, 0, 0, 0,
+			2, 0, 0, 0,
+			1, 0, 0, 0,
+			2, 0, 0, 0,
+		})
+	})
+	c.Specify("ChangeProperty pads 8-bit data to a whole word.", func() {
+		x.ChangeProperty(0x1234, x11.AtomWmName, x11.AtomString, 8, []byte("glop!"))
+		c.Expect(s.next(), ContainsInOrder, []byte{
+			18, 0, 8, 0,
+			0x34, 0x12, 0, 0,
+			39, 0, 0, 0,
+			31, 0, 0, 0,
+			8, 0, 0, 0,
+			5, 0, 0, 0,
+			'g', 'l', 'o', 'p',
+			'!', 0, 0, 0,
+		})
+	})
+	c.Specify("WarpPointer sends signed coordinates relative to the root.", func() {
+		x.WarpPointer(-5, 7)
+		c.Expect(s.next(), ContainsInOrder, []byte{
+			41, 0, 6, 0,
+			0, 0, 0, 0,
+			0, 1, 0, 0,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+			0xfb, 0xff, 7, 0,
+		})
+	})
+	c.Specify("SendClientMessage sends a ClientMessage event to the root.", func() {
+		x.SendClientMessage(0x1234, x11.Atom(300), [5]uint32{1, 2, 3, 4, 5})
+		c.Expect(s.next(), ContainsInOrder, []byte{
+			25, 0, 11, 0,
+			0, 1, 0, 0,
+			0, 0, 0x18, 0,
+			33, 32, 0, 0,
+			0x34, 0x12, 0, 0,
+			0x2c, 1, 0, 0,
+			1, 0, 0, 0,
+			2, 0, 0, 0,
+			3, 0, 0, 0,
+			4, 0, 0, 0,
+			5, 0, 0, 0,
+		})
+	})
+}
+
+func ReplySpec(c gospec.Context) {
+	c.Specify("InternAtom reads the atom out of the reply.", func() {
+		x, s := dialFake(c, func(seq uint16, req []byte) []byte {
+			reply := makeReply(seq, 0, nil)
+			le.PutUint32(reply[8:], 0x99)
+			return reply
+		})
+		defer x.Close()
+		atom, err := x.InternAtom("WM_NAME")
+		c.Expect(err, IsNil)
+		c.Expect(atom, Equals, x11.Atom(0x99))
+		c.Expect(s.next(), ContainsInOrder, []byte{
+			16, 0, 4, 0,
+			7, 0, 0, 0,
+			'W', 'M', '_', 'N',
+			'A', 'M', 'E', 0,
+		})
+	})
+	c.Specify("Replies are matched to requests by sequence number.", func() {
+		x, _ := dialFake(c, func(seq uint16, req []byte) []byte {
+			if req[0] != 16 {
+				return nil
+			}
+			stale := makeReply(seq+10, 0, nil)
+			le.PutUint32(stale[8:], 0x66)
+			reply := makeReply(seq, 0, nil)
+			le.PutUint32(reply[8:], uint32(seq))
+			return append(stale, reply...)
+		})
+		defer x.Close()
+		x.MapWindow(0x1234)
+		x.MapWindow(0x1234)
+		atom, err := x.InternAtom("WM_NAME")
+		c.Expect(err, IsNil)
+		c.Expect(atom, Equals, x11.Atom(3))
+	})
+	c.Specify("GetProperty reads format-bit items after the reply header.", func() {
+		x, _ := dialFake(c, func(seq uint16, req []byte) []byte {
+			reply := makeReply(seq, 8, []byte{'h', 'e', 'l', 'l', 'o', 0, 0, 0})
+			le.PutUint32(reply[8:], uint32(x11.AtomString))
+			le.PutUint32(reply[16:], 5)
+			return reply
+		})
+		defer x.Close()
+		typ, format, data, err := x.GetProperty(0x1234, x11.AtomWmName, false)
+		c.Expect(err, IsNil)
+		c.Expect(typ, Equals, x11.AtomString)
+		c.Expect(format, Equals, byte(8))
+		c.Expect(string(data), Equals, "hello")
+	})
+	c.Specify("GetProperty fails if the reply is shorter than it says.", func() {
+		x, _ := dialFake(c, func(seq uint16, req []byte) []byte {
+			reply := makeReply(seq, 32, []byte{1, 0, 0, 0})
+			le.PutUint32(reply[16:], 2)
+			return reply
+		})
+		defer x.Close()
+		_, _, _, err := x.GetProperty(0x1234, x11.AtomWmName, false)
+		c.Expect(err, Not(IsNil))
+	})
+	c.Specify("QueryPointer reads signed positions.", func() {
+		x, _ := dialFake(c, func(seq uint16, req []byte) []byte {
+			reply := makeReply(seq, 1, nil)
+			le.PutUint16(reply[16:], uint16(0xfffd)) // -3
+			le.PutUint16(reply[18:], 40)
+			le.PutUint16(reply[20:], uint16(0xff9c)) // -100
+			le.PutUint16(reply[22:], 7)
+			return reply
+		})
+		defer x.Close()
+		root_x, root_y, win_x, win_y, err := x.QueryPointer(0x1234)
+		c.Expect(err, IsNil)
+		c.Expect(root_x, Equals, -3)
+		c.Expect(root_y, Equals, 40)
+		c.Expect(win_x, Equals, -100)
+		c.Expect(win_y, Equals, 7)
+	})
+	c.Specify("X errors are returned from the request that caused them.", func() {
+		x, _ := dialFake(c, func(seq uint16, req []byte) []byte {
+			data := make([]byte, 32)
+			data[1] = 3 // BadWindow
+			le.PutUint16(data[2:], seq)
+			data[10] = req[0]
+			return data
+		})
+		defer x.Close()
+		_, _, err := x.GetGeometry(0x1234)
+		c.Assume(err, Not(IsNil))
+		c.Expect(err.Error(), Equals, "X error 3 for request 14.")
+	})
+	c.Specify("Requests fail once the connection is lost.", func() {
+		x, s := dialFake(c, nil)
+		defer x.Close()
+		s.conn.Close()
+		_, err := x.InternAtom("WM_NAME")
+		c.Expect(err, Not(IsNil))
+		c.Expect(x.Err(), Not(IsNil))
+	})
+}
+
+func EventSpec(c gospec.Context) {
+	x, s := dialFake(c, nil)
+	defer x.Close()
+	c.Specify("Events are queued with their raw bytes.", func() {
+		event := make([]byte, 32)
+		event[0] = x11.KeyPress | 0x80 // sent with SendEvent
+		event[1] = 38
+		s.conn.Write(event)
+		events := waitForEvents(x, 1)
+		c.Assume(len(events), Equals, 1)
+		c.Expect(events[0].Code(), Equals, byte(x11.KeyPress))
+		c.Expect(events[0].Data, ContainsInOrder, event)
+		c.Expect(len(x.Events()), Equals, 0)
+	})
+	c.Specify("GenericEvents are read past the first 32 bytes.", func() {
+		event := make([]byte, 40)
+		event[0] = 35
+		le.PutUint32(event[4:], 2)
+		event[39] = 0xaa
+		s.conn.Write(event)
+		events := waitForEvents(x, 1)
+		c.Assume(len(events), Equals, 1)
+		c.Expect(len(events[0].Data), Equals, 40)
+		c.Expect(events[0].Data[39], Equals, byte(0xaa))
+	})
+	c.Specify("The event filter can keep events out of the queue.", func() {
+		x.SetEventFilter(func(e x11.Event) bool {
+			return e.Code() != x11.KeyRelease
+		})
+		release := make([]byte, 32)
+		release[0] = x11.KeyRelease
+		press := make([]byte, 32)
+		press[0] = x11.KeyPress
+		s.conn.Write(release)
+		s.conn.Write(press)
+		events := waitForEvents(x, 1)
+		c.Assume(len(events), Equals, 1)
+		c.Expect(events[0].Code(), Equals, byte(x11.KeyPress))
+	})
+}
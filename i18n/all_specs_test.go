@@ -0,0 +1,14 @@
+package i18n_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(PluralSpec)
+	r.AddSpec(TranslateSpec)
+	r.AddSpec(LoadSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,289 @@
+// Package i18n translates text shown to players.  Translations are loaded
+// from JSON or gettext .po files, one per locale, and looked up by key with
+// T():
+//
+//	i18n.LoadDir(assets, "lang")   // lang/en.json, lang/fr.po, ...
+//	i18n.SetLocale("fr")
+//	label := i18n.T("apples", n)   // "%d pommes"
+//
+// Messages can have plural forms, which are chosen by the first argument to
+// T() if it is an integer, using the rules for the language.  If a key isn't
+// translated for the current locale it falls back to the locale's language,
+// then to the fallback locale, and finally to the key itself.
+//
+// Anything that lays out translated text, like a text widget that wraps its
+// lines, should register a LocaleListener so that it can lay itself out
+// again when the locale changes.
+package i18n
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/glog"
+	"strings"
+	"sync"
+)
+
+// A message in one locale.  Messages without plural forms only have "other".
+type message map[string]string
+
+// The translations for one locale.
+type Catalog struct {
+	locale   string
+	messages map[string]message
+}
+
+func MakeCatalog(locale string) *Catalog {
+	return &Catalog{locale: normalize(locale), messages: make(map[string]message)}
+}
+
+func (c *Catalog) Locale() string {
+	return c.locale
+}
+
+func (c *Catalog) Len() int {
+	return len(c.messages)
+}
+
+// Sets the translation of key.
+func (c *Catalog) Set(key, text string) {
+	c.messages[key] = message{"other": text}
+}
+
+// Sets the plural forms of key, forms maps plural categories, like "one" and
+// "other", to text.
+func (c *Catalog) SetPlural(key string, forms map[string]string) {
+	m := make(message)
+	for category, text := range forms {
+		m[category] = text
+	}
+	c.messages[key] = m
+}
+
+// Locales are compared in lower case with - between the language and region,
+// so "pt_BR" and "pt-br" are the same.
+func normalize(locale string) string {
+	return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+// Anything that needs to know when the locale changes, like a widget showing
+// translated text.
+type LocaleListener interface {
+	LocaleChanged(locale string)
+}
+
+// A Translator holds catalogs for any number of locales and translates into
+// one of them at a time.  It is safe to use from any goroutine, and listeners
+// are called from whichever goroutine calls SetLocale().
+type Translator struct {
+	mutex     sync.Mutex
+	catalogs  map[string]*Catalog
+	locale    string
+	fallback  string
+	listeners []LocaleListener
+
+	// Keys that have already been warned about, so that each missing key is
+	// only logged once per locale.
+	missing map[string]bool
+}
+
+// Makes a Translator whose current locale and fallback are both fallback.
+func MakeTranslator(fallback string) *Translator {
+	return &Translator{
+		catalogs: make(map[string]*Catalog),
+		locale:   normalize(fallback),
+		fallback: normalize(fallback),
+		missing:  make(map[string]bool),
+	}
+}
+
+var the_translator = MakeTranslator("en")
+
+// Returns the Translator used by the package level functions.
+func Default() *Translator {
+	return the_translator
+}
+
+// Adds the messages in c to the translator, replacing any that were already
+// there for the same keys in the same locale.
+func (t *Translator) Add(c *Catalog) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	existing, ok := t.catalogs[c.locale]
+	if !ok {
+		existing = MakeCatalog(c.locale)
+		t.catalogs[c.locale] = existing
+	}
+	for key, m := range c.messages {
+		existing.messages[key] = m
+	}
+}
+
+// Returns the locales that have catalogs.
+func (t *Translator) Locales() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var locales []string
+	for locale := range t.catalogs {
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+func (t *Translator) Locale() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.locale
+}
+
+// Changes the current locale and tells the listeners about it, if it
+// actually changed.
+func (t *Translator) SetLocale(locale string) {
+	locale = normalize(locale)
+	t.mutex.Lock()
+	if locale == t.locale {
+		t.mutex.Unlock()
+		return
+	}
+	t.locale = locale
+	listeners := append([]LocaleListener(nil), t.listeners...)
+	t.mutex.Unlock()
+	for _, l := range listeners {
+		l.LocaleChanged(locale)
+	}
+}
+
+func (t *Translator) RegisterListener(l LocaleListener) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.listeners = append(t.listeners, l)
+}
+
+func (t *Translator) UnregisterListener(l LocaleListener) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i := range t.listeners {
+		if t.listeners[i] == l {
+			t.listeners = append(t.listeners[:i], t.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// Finds key in the locales to look in, in order.  Must be called with the
+// mutex held.
+func (t *Translator) lookup(key string) (m message, locale string, ok bool) {
+	for _, locale := range []string{t.locale, language(t.locale), t.fallback} {
+		if c, found := t.catalogs[locale]; found {
+			if m, ok := c.messages[key]; ok {
+				return m, locale, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// Returns true iff key is translated in the current locale or one of the ones
+// it falls back to.
+func (t *Translator) Has(key string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, _, ok := t.lookup(key)
+	return ok
+}
+
+// Returns the count to choose a plural form with, if args starts with one.
+func count(args []interface{}) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	switch n := args[0].(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// Translates key into the current locale and formats it with args like
+// fmt.Sprintf.  If args starts with an integer it picks the plural form.
+// Missing keys are logged to glog once each and come back as the key itself.
+func (t *Translator) T(key string, args ...interface{}) string {
+	t.mutex.Lock()
+	m, locale, ok := t.lookup(key)
+	if !ok {
+		warn := !t.missing[t.locale+"\x00"+key]
+		t.missing[t.locale+"\x00"+key] = true
+		current := t.locale
+		t.mutex.Unlock()
+		if warn {
+			glog.Warningf("No translation of '%s' for %s.", key, current)
+		}
+		return key
+	}
+	t.mutex.Unlock()
+
+	text, ok := m["other"]
+	if n, counted := count(args); counted {
+		if form, found := m[PluralCategory(locale, n)]; found {
+			text, ok = form, true
+		}
+	}
+	if !ok {
+		// A plural message that is missing the form we need and "other".
+		for _, category := range pluralRule(locale).Categories {
+			if form, found := m[category]; found {
+				text = form
+				break
+			}
+		}
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+func Add(c *Catalog) {
+	the_translator.Add(c)
+}
+
+func Locale() string {
+	return the_translator.Locale()
+}
+
+func SetLocale(locale string) {
+	the_translator.SetLocale(locale)
+}
+
+func RegisterListener(l LocaleListener) {
+	the_translator.RegisterListener(l)
+}
+
+func UnregisterListener(l LocaleListener) {
+	the_translator.UnregisterListener(l)
+}
+
+func Has(key string) bool {
+	return the_translator.Has(key)
+}
+
+func T(key string, args ...interface{}) string {
+	return the_translator.T(key, args...)
+}
@@ -0,0 +1,141 @@
+package i18n_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/glog"
+	"github.com/runningwild/glop/i18n"
+	"strings"
+	"testing/fstest"
+)
+
+func PluralSpec(c gospec.Context) {
+	c.Specify("Languages pick plural categories by their own rules", func() {
+		var en, fr, ru, ja []string
+		for _, n := range []int{0, 1, 2, 5, 11, 21, 22, 25} {
+			en = append(en, i18n.PluralCategory("en", n))
+			fr = append(fr, i18n.PluralCategory("fr_CA", n))
+			ru = append(ru, i18n.PluralCategory("ru", n))
+			ja = append(ja, i18n.PluralCategory("ja", n))
+		}
+		c.Expect(strings.Join(en, " "), Equals, "other one other other other other other other")
+		c.Expect(strings.Join(fr, " "), Equals, "one one other other other other other other")
+		c.Expect(strings.Join(ru, " "), Equals, "many one few many many one few many")
+		c.Expect(strings.Join(ja, " "), Equals, "other other other other other other other other")
+	})
+}
+
+type listener struct {
+	locales []string
+}
+
+func (l *listener) LocaleChanged(locale string) {
+	l.locales = append(l.locales, locale)
+}
+
+func TranslateSpec(c gospec.Context) {
+	glog.SetOutput(nil)
+	t := i18n.MakeTranslator("en")
+	en := i18n.MakeCatalog("en")
+	en.Set("title", "Glop")
+	en.Set("hello", "Hello, %s!")
+	en.SetPlural("apples", map[string]string{"one": "%d apple", "other": "%d apples"})
+	en.Set("quit", "Quit")
+	t.Add(en)
+	fr := i18n.MakeCatalog("fr")
+	fr.Set("hello", "Bonjour, %s !")
+	fr.SetPlural("apples", map[string]string{"one": "%d pomme", "other": "%d pommes"})
+	t.Add(fr)
+	frCA := i18n.MakeCatalog("fr-CA")
+	frCA.Set("quit", "Quitter")
+	t.Add(frCA)
+
+	c.Specify("Messages are formatted with their args", func() {
+		c.Expect(t.T("title"), Equals, "Glop")
+		c.Expect(t.T("hello", "Bob"), Equals, "Hello, Bob!")
+	})
+
+	c.Specify("The first integer arg picks the plural form", func() {
+		c.Expect(t.T("apples", 1), Equals, "1 apple")
+		c.Expect(t.T("apples", 3), Equals, "3 apples")
+		c.Expect(t.T("apples", int64(0)), Equals, "0 apples")
+		t.SetLocale("fr")
+		c.Expect(t.T("apples", 0), Equals, "0 pomme")
+		c.Expect(t.T("apples", 2), Equals, "2 pommes")
+	})
+
+	c.Specify("Lookups fall back to the language, then the fallback, then the key", func() {
+		t.SetLocale("fr_CA")
+		c.Expect(t.Locale(), Equals, "fr-ca")
+		c.Expect(t.T("quit"), Equals, "Quitter")
+		c.Expect(t.T("hello", "Marie"), Equals, "Bonjour, Marie !")
+		c.Expect(t.T("title"), Equals, "Glop")
+		c.Expect(t.T("missing.key"), Equals, "missing.key")
+		c.Expect(t.Has("missing.key"), Equals, false)
+		c.Expect(t.Has("quit"), Equals, true)
+	})
+
+	c.Specify("Listeners hear about changes to the locale", func() {
+		var l listener
+		t.RegisterListener(&l)
+		t.SetLocale("fr")
+		t.SetLocale("FR")
+		t.SetLocale("en")
+		t.UnregisterListener(&l)
+		t.SetLocale("fr")
+		c.Expect(l.locales, ContainsInOrder, []string{"fr", "en"})
+		c.Expect(len(l.locales), Equals, 2)
+	})
+}
+
+func LoadSpec(c gospec.Context) {
+	fsys := fstest.MapFS{
+		"lang/en.json": &fstest.MapFile{Data: []byte(`{
+      "title": "Glop",
+      "files": {"one": "%d file", "other": "%d files"}
+    }`)},
+		"lang/ru.po": &fstest.MapFile{Data: []byte(`
+# Russian
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+msgid "title"
+msgstr "Глоп"
+
+msgid "files"
+msgid_plural "%d files"
+msgstr[0] "%d файл"
+msgstr[1] "%d файла"
+msgstr[2] ""
+"%d файлов"
+
+msgid "untranslated"
+msgstr ""
+`)},
+		"lang/readme.txt": &fstest.MapFile{Data: []byte("not a translation")},
+	}
+
+	c.Specify("Directories of JSON and gettext files load by locale", func() {
+		t := i18n.MakeTranslator("en")
+		c.Assume(t.LoadDir(fsys, "lang"), Equals, nil)
+		c.Expect(t.T("files", 2), Equals, "2 files")
+		t.SetLocale("ru")
+		c.Expect(t.T("title"), Equals, "Глоп")
+		c.Expect(t.T("files", 1), Equals, "1 файл")
+		c.Expect(t.T("files", 3), Equals, "3 файла")
+		c.Expect(t.T("files", 5), Equals, "5 файлов")
+		c.Expect(t.Has("untranslated"), Equals, false)
+	})
+
+	c.Specify("Bad files say what's wrong with them", func() {
+		_, err := i18n.LoadFS(fstest.MapFS{"de.json": &fstest.MapFile{Data: []byte(`{"x": {"single": "y"}}`)}}, "de.json")
+		c.Assume(err, Not(Equals), nil)
+		c.Expect(err.Error(), Equals, "de.json: 'x' has a form called 'single', which isn't a plural category.")
+		_, err = i18n.LoadFS(fstest.MapFS{"ja.po": &fstest.MapFile{Data: []byte("msgid \"a\"\nmsgid_plural \"b\"\nmsgstr[1] \"c\"\n")}}, "ja.po")
+		c.Assume(err, Not(Equals), nil)
+		c.Expect(err.Error(), Equals, "ja.po: Line 3: 'a' has msgstr[1] but ja only has 1 plural forms.")
+		_, err = i18n.LoadFS(fstest.MapFS{"en.txt": &fstest.MapFile{}}, "en.txt")
+		c.Assume(err, Not(Equals), nil)
+	})
+}
@@ -0,0 +1,224 @@
+package i18n
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Reads a catalog from JSON that maps keys to either a string or an object of
+// plural forms:
+//
+//	{
+//	  "title": "Glop",
+//	  "apples": {"one": "%d apple", "other": "%d apples"}
+//	}
+func LoadJSON(locale string, r io.Reader) (*Catalog, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	c := MakeCatalog(locale)
+	for key, value := range raw {
+		var text string
+		if err := json.Unmarshal(value, &text); err == nil {
+			c.Set(key, text)
+			continue
+		}
+		var forms map[string]string
+		if err := json.Unmarshal(value, &forms); err != nil {
+			return nil, fmt.Errorf("'%s' must be a string or an object of plural forms.", key)
+		}
+		for category := range forms {
+			if !isCategory(category) {
+				return nil, fmt.Errorf("'%s' has a form called '%s', which isn't a plural category.", key, category)
+			}
+		}
+		c.SetPlural(key, forms)
+	}
+	return c, nil
+}
+
+func isCategory(s string) bool {
+	switch s {
+	case "zero", "one", "two", "few", "many", "other":
+		return true
+	}
+	return false
+}
+
+// Reads a catalog from a gettext .po file.  msgids are the keys, msgstr[n]
+// are matched to plural categories using the plural rule for locale, and
+// entries that haven't been translated yet are skipped.  Contexts and the
+// header's Plural-Forms aren't supported.
+func LoadPO(locale string, r io.Reader) (*Catalog, error) {
+	c := MakeCatalog(locale)
+	categories := pluralRule(c.locale).Categories
+
+	// The entry being read, and which of its strings the next continuation
+	// line goes on.
+	var id, plural string
+	strs := make(map[int]*string)
+	var target *string
+	line_num := 0
+	flush := func() error {
+		defer func() {
+			id, plural, target = "", "", nil
+			strs = make(map[int]*string)
+		}()
+		if id == "" {
+			// The header, or nothing at all.
+			return nil
+		}
+		if plural == "" {
+			if s, ok := strs[0]; ok && *s != "" {
+				c.Set(id, *s)
+			}
+			return nil
+		}
+		forms := make(map[string]string)
+		for n, s := range strs {
+			if n >= len(categories) {
+				return fmt.Errorf("Line %d: '%s' has msgstr[%d] but %s only has %d plural forms.", line_num, id, n, c.locale, len(categories))
+			}
+			if *s != "" {
+				forms[categories[n]] = *s
+			}
+		}
+		if len(forms) > 0 {
+			c.SetPlural(id, forms)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line_num++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '"' {
+			if target == nil {
+				return nil, fmt.Errorf("Line %d: String without a msgid or msgstr.", line_num)
+			}
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("Line %d: Bad string %s.", line_num, line)
+			}
+			*target += s
+			continue
+		}
+		keyword, rest := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			keyword, rest = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		s, err := strconv.Unquote(rest)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: Bad string %s.", line_num, rest)
+		}
+		switch {
+		case keyword == "msgid":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			id = s
+			target = &id
+		case keyword == "msgid_plural":
+			plural = s
+			target = &plural
+		case keyword == "msgstr":
+			strs[0] = &s
+			target = &s
+		case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+			n, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("Line %d: Bad plural index in %s.", line_num, keyword)
+			}
+			strs[n] = &s
+			target = &s
+		case keyword == "msgctxt":
+			return nil, fmt.Errorf("Line %d: msgctxt isn't supported.", line_num)
+		default:
+			return nil, fmt.Errorf("Line %d: Unknown keyword '%s'.", line_num, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Loads a catalog from a .json or .po file, the locale is the name of the
+// file without its extension, like "pt-BR" for pt-BR.po.
+func LoadFile(filename string) (*Catalog, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return load(filepath.Base(filename), f)
+}
+
+// Like LoadFile() but reads name from fsys, like an assets.FS.
+func LoadFS(fsys fs.FS, name string) (*Catalog, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return load(path.Base(name), f)
+}
+
+func load(base string, r io.Reader) (*Catalog, error) {
+	ext := path.Ext(base)
+	locale := strings.TrimSuffix(base, ext)
+	var c *Catalog
+	var err error
+	switch strings.ToLower(ext) {
+	case ".json":
+		c, err = LoadJSON(locale, r)
+	case ".po":
+		c, err = LoadPO(locale, r)
+	default:
+		return nil, fmt.Errorf("Don't know how to load translations from a %s file.", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", base, err)
+	}
+	return c, nil
+}
+
+// Loads every .json and .po file in dir in fsys and adds them to t.
+func (t *Translator) LoadDir(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		ext := strings.ToLower(path.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".json" && ext != ".po") {
+			continue
+		}
+		c, err := LoadFS(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		t.Add(c)
+	}
+	return nil
+}
+
+func LoadDir(fsys fs.FS, dir string) error {
+	return the_translator.LoadDir(fsys, dir)
+}
@@ -0,0 +1,148 @@
+package i18n
+
+import "strings"
+
+// A PluralRule picks which form of a message to use for a count.  Forms are
+// named with CLDR's plural categories: "zero", "one", "two", "few", "many",
+// and "other".
+type PluralRule struct {
+	// The categories that the language uses, in the order that gettext
+	// numbers them in msgstr[n].
+	Categories []string
+
+	// Returns the category for n, which is never negative.
+	Category func(n int) string
+}
+
+var (
+	oneOther = PluralRule{
+		Categories: []string{"one", "other"},
+		Category: func(n int) string {
+			if n == 1 {
+				return "one"
+			}
+			return "other"
+		},
+	}
+	zeroOneOther = PluralRule{
+		Categories: []string{"one", "other"},
+		Category: func(n int) string {
+			if n == 0 || n == 1 {
+				return "one"
+			}
+			return "other"
+		},
+	}
+	otherOnly = PluralRule{
+		Categories: []string{"other"},
+		Category:   func(n int) string { return "other" },
+	}
+	slavic = PluralRule{
+		Categories: []string{"one", "few", "many"},
+		Category: func(n int) string {
+			switch {
+			case n%10 == 1 && n%100 != 11:
+				return "one"
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return "few"
+			}
+			return "many"
+		},
+	}
+	polish = PluralRule{
+		Categories: []string{"one", "few", "many"},
+		Category: func(n int) string {
+			switch {
+			case n == 1:
+				return "one"
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return "few"
+			}
+			return "many"
+		},
+	}
+	czech = PluralRule{
+		Categories: []string{"one", "few", "other"},
+		Category: func(n int) string {
+			switch {
+			case n == 1:
+				return "one"
+			case n >= 2 && n <= 4:
+				return "few"
+			}
+			return "other"
+		},
+	}
+	arabic = PluralRule{
+		Categories: []string{"zero", "one", "two", "few", "many", "other"},
+		Category: func(n int) string {
+			switch {
+			case n == 0:
+				return "zero"
+			case n == 1:
+				return "one"
+			case n == 2:
+				return "two"
+			case n%100 >= 3 && n%100 <= 10:
+				return "few"
+			case n%100 >= 11:
+				return "many"
+			}
+			return "other"
+		},
+	}
+)
+
+// Plural rules by language, anything not in here uses oneOther, which is
+// right for English and most of western Europe.
+var plural_rules = map[string]PluralRule{
+	"fr": zeroOneOther,
+	"pt": zeroOneOther,
+	"hi": zeroOneOther,
+	"ja": otherOnly,
+	"zh": otherOnly,
+	"ko": otherOnly,
+	"th": otherOnly,
+	"vi": otherOnly,
+	"id": otherOnly,
+	"tr": otherOnly,
+	"ru": slavic,
+	"uk": slavic,
+	"be": slavic,
+	"pl": polish,
+	"cs": czech,
+	"sk": czech,
+	"ar": arabic,
+}
+
+// Returns the language part of a locale, "pt" for "pt-br".
+func language(locale string) string {
+	if i := strings.IndexByte(locale, '-'); i >= 0 {
+		return locale[:i]
+	}
+	return locale
+}
+
+// Sets the plural rule for a language, or for one locale if it has a region,
+// like "pt-br".  This should be done before loading gettext files for it.
+func SetPluralRule(locale string, rule PluralRule) {
+	plural_rules[normalize(locale)] = rule
+}
+
+func pluralRule(locale string) PluralRule {
+	if rule, ok := plural_rules[locale]; ok {
+		return rule
+	}
+	if rule, ok := plural_rules[language(locale)]; ok {
+		return rule
+	}
+	return oneOther
+}
+
+// Returns the plural category that locale uses for n.
+func PluralCategory(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	return pluralRule(normalize(locale)).Category(n)
+}
@@ -0,0 +1,15 @@
+package net_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(LockstepSpec)
+	r.AddSpec(RollbackSpec)
+	r.AddSpec(DesyncSpec)
+	r.AddSpec(ReplicateSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,598 @@
+// Package net runs deterministic lockstep multiplayer sessions over UDP.
+// Every player sends only their input, the actions from their gin.Bindings
+// for each frame, and every player simulates every frame with everyone's
+// input.  As long as the simulation is deterministic, like Sprites that are
+// all thought with the same dt every frame, every player sees the same game.
+//
+// One player hosts and the rest join, all input goes through the host.
+//
+// Games that can't be made deterministic can use a Replicator instead, which
+// sends snapshots of where everything is to Replicas that interpolate
+// between them.
+package net
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"net"
+	"sync"
+	"time"
+)
+
+// An Action is what gets sent over the network for a gin.ActionEvent.
+type Action struct {
+	Name     string
+	Type     gin.EventType
+	PressAmt float64
+}
+
+// Converts the events from gin.Bindings.Translate() into Actions.
+func MakeActions(events []gin.ActionEvent) []Action {
+	actions := make([]Action, len(events))
+	for i, event := range events {
+		actions[i] = Action{
+			Name:     event.Action,
+			Type:     event.Type,
+			PressAmt: event.Event.Key.CurPressAmt(),
+		}
+	}
+	return actions
+}
+
+type Options struct {
+	// Input added with AddInput() is used this many frames later, which gives
+	// it time to get to the other players so that nobody has to wait for it.
+	// Only the host's setting is used.
+	InputDelay int
+
+	// If this is more than zero then Advance() doesn't wait for other players'
+	// input, it guesses that they did nothing for up to this many frames, and
+	// Rollback() says when a guess was wrong.  Only the host's setting is
+	// used.
+	MaxRollback int
+
+	// How long to wait for everyone to join, and how long a player can go
+	// without being heard from before the session fails.  Defaults to 10
+	// seconds.
+	Timeout time.Duration
+}
+
+const defaultTimeout = 10 * time.Second
+
+// How often packets are sent, even if no new input has been added.  Packets
+// include everything that the other side hasn't acknowledged, so lost packets
+// are made up for by the next one.
+const resendInterval = 20 * time.Millisecond
+
+// Limits on how much goes in a single packet, to stay well under the size
+// that UDP packets get fragmented at.
+const maxFramesPerBlock = 32
+const maxHashesPerPacket = 8
+
+// How many frames of hashes are kept around to compare with other players.
+const hashHistory = 256
+
+// A Session is one player's end of a lockstep game.
+type Session struct {
+	conn        net.PacketConn
+	player      int
+	num_players int
+	delay       int
+	rollback    int
+	timeout     time.Duration
+
+	// The host has the address of every player, with nil for itself, clients
+	// only have the host's address.
+	peers []net.Addr
+
+	mutex sync.Mutex
+
+	// inputs[p][f] is player p's input for frame f, received[p] is the last
+	// frame that we have all of player p's input up to.
+	inputs   []map[int64][]Action
+	received []int64
+
+	// acks[p][q] is the last frame of player q's input that peer p has
+	// acknowledged having.
+	acks [][]int64
+
+	// heard[p] is set once peer p has sent its first frames packet, until
+	// then the host keeps sending it welcome packets.
+	heard      []bool
+	last_heard []time.Time
+
+	// The next frame that Advance() will return.
+	current int64
+
+	// The first frame that was simulated with a wrong guess about someone's
+	// input, or -1.
+	mispredict int64
+
+	// Hashes set with SetHash(), and hashes that peers have sent us.
+	hashes        map[int64]uint64
+	remote_hashes []map[int64]uint64
+	desync        int64
+
+	err    error
+	closed bool
+	wg     sync.WaitGroup
+	quit   chan struct{}
+}
+
+func makeSession(conn net.PacketConn, player, num_players, delay, rollback int, timeout time.Duration) *Session {
+	s := &Session{
+		conn:          conn,
+		player:        player,
+		num_players:   num_players,
+		delay:         delay,
+		rollback:      rollback,
+		timeout:       timeout,
+		inputs:        make([]map[int64][]Action, num_players),
+		received:      make([]int64, num_players),
+		acks:          make([][]int64, num_players),
+		heard:         make([]bool, num_players),
+		last_heard:    make([]time.Time, num_players),
+		mispredict:    -1,
+		hashes:        make(map[int64]uint64),
+		remote_hashes: make([]map[int64]uint64, num_players),
+		desync:        -1,
+		quit:          make(chan struct{}),
+	}
+	// Everyone starts out with empty input for the first delay frames, since
+	// nobody had a chance to add any.
+	now := time.Now()
+	for p := range s.inputs {
+		s.inputs[p] = make(map[int64][]Action)
+		s.received[p] = int64(delay) - 1
+		s.acks[p] = make([]int64, num_players)
+		for q := range s.acks[p] {
+			s.acks[p][q] = int64(delay) - 1
+		}
+		s.last_heard[p] = now
+		s.remote_hashes[p] = make(map[int64]uint64)
+	}
+	return s
+}
+
+func defaultOptions(opts Options) Options {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.InputDelay < 0 {
+		opts.InputDelay = 0
+	}
+	if opts.MaxRollback < 0 {
+		opts.MaxRollback = 0
+	}
+	return opts
+}
+
+// Hosts a session for num_players players, including the host, on conn,
+// which is usually from net.ListenPacket("udp", ":port").  Blocks until
+// everyone has joined or opts.Timeout passes.  The session owns conn and
+// closes it when it is closed.
+func Host(conn net.PacketConn, num_players int, opts Options) (*Session, error) {
+	if num_players < 1 || num_players > 255 {
+		return nil, fmt.Errorf("Cannot host %d players.", num_players)
+	}
+	opts = defaultOptions(opts)
+	s := makeSession(conn, 0, num_players, opts.InputDelay, opts.MaxRollback, opts.Timeout)
+	s.peers = make([]net.Addr, num_players)
+	s.heard[0] = true
+	joined := 1
+	deadline := time.Now().Add(opts.Timeout)
+	buf := make([]byte, 65536)
+	for joined < num_players {
+		conn.SetReadDeadline(deadline)
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Only %d of %d players joined: %v", joined, num_players, err)
+		}
+		if msg, _, err := readHeader(buf[:n]); err != nil || msg != msgHello || s.peerIndex(from) != -1 {
+			continue
+		}
+		s.peers[joined] = from
+		s.last_heard[joined] = time.Now()
+		joined++
+	}
+	conn.SetReadDeadline(time.Time{})
+	s.start()
+	return s, nil
+}
+
+// Joins the session hosted at host, sending and receiving on conn, which is
+// usually from net.ListenPacket("udp", ":0").  Blocks until everyone has
+// joined or opts.Timeout passes.  The session owns conn and closes it when
+// it is closed.
+func Join(conn net.PacketConn, host net.Addr, opts Options) (*Session, error) {
+	opts = defaultOptions(opts)
+	hello := makePacket(msgHello).Bytes()
+	deadline := time.Now().Add(opts.Timeout)
+	buf := make([]byte, 65536)
+	for {
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("Timed out joining %v.", host)
+		}
+		conn.WriteTo(hello, host)
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil || from.String() != host.String() {
+			continue
+		}
+		msg, r, err := readHeader(buf[:n])
+		if err != nil || msg != msgWelcome {
+			continue
+		}
+		w, err := decodeWelcome(r)
+		if err != nil {
+			continue
+		}
+		conn.SetReadDeadline(time.Time{})
+		s := makeSession(conn, int(w.Player), int(w.Num_players), int(w.Delay), int(w.Rollback), opts.Timeout)
+		s.peers = []net.Addr{host}
+		s.heard[0] = true
+		s.start()
+		return s, nil
+	}
+}
+
+func (s *Session) peerIndex(addr net.Addr) int {
+	for i, peer := range s.peers {
+		if peer != nil && peer.String() == addr.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Session) start() {
+	s.wg.Add(2)
+	go s.receive()
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(resendInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.send()
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Session) receive() {
+	defer s.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			s.mutex.Lock()
+			if !s.closed && s.err == nil {
+				s.err = err
+			}
+			s.mutex.Unlock()
+			return
+		}
+		msg, r, err := readHeader(buf[:n])
+		if err != nil || msg != msgFrames {
+			continue
+		}
+		frames, err := decodeFrames(r)
+		if err != nil {
+			continue
+		}
+		s.mutex.Lock()
+		s.handleFrames(from, frames)
+		s.mutex.Unlock()
+	}
+}
+
+// Takes in a frames packet, the session's mutex must be held.
+func (s *Session) handleFrames(from net.Addr, f *framesMsg) {
+	peer := s.peerIndex(from)
+	if peer == -1 || len(f.acks) != s.num_players || f.sender < 0 || f.sender >= s.num_players {
+		return
+	}
+	if (s.player == 0 && f.sender != peer) || (s.player != 0 && f.sender != 0) {
+		return
+	}
+	s.heard[peer] = true
+	s.last_heard[peer] = time.Now()
+	for q, ack := range f.acks {
+		if ack > s.acks[peer][q] {
+			s.acks[peer][q] = ack
+		}
+	}
+	for _, block := range f.blocks {
+		// Clients only get to send their own input, and nobody else gets to
+		// send ours.
+		if block.player == s.player || block.player >= s.num_players {
+			continue
+		}
+		if s.player == 0 && block.player != f.sender {
+			continue
+		}
+		s.addInputs(block.player, block.first, block.inputs)
+	}
+	for _, h := range f.hashes {
+		s.remote_hashes[peer][h.frame] = h.hash
+	}
+}
+
+// Adds inputs for player p starting at frame first.  Input has to arrive in
+// order, anything past a gap is dropped and will be sent again.
+func (s *Session) addInputs(p int, first int64, inputs [][]Action) {
+	for i, input := range inputs {
+		frame := first + int64(i)
+		if frame <= s.received[p] {
+			continue
+		}
+		if frame != s.received[p]+1 {
+			break
+		}
+		s.inputs[p][frame] = input
+		s.received[p] = frame
+		// We already simulated this frame guessing that p did nothing.
+		if frame < s.current && len(input) > 0 && (s.mispredict == -1 || frame < s.mispredict) {
+			s.mispredict = frame
+		}
+	}
+}
+
+// Returns the last frame that we have everyone's input up to.
+func (s *Session) confirmed() int64 {
+	least := s.received[0]
+	for _, r := range s.received {
+		if r < least {
+			least = r
+		}
+	}
+	return least
+}
+
+// Returns the last frame whose hash won't change, because it was simulated
+// with everyone's actual input.
+func (s *Session) finalFrame() int64 {
+	final := s.confirmed()
+	if s.current-1 < final {
+		final = s.current - 1
+	}
+	if s.mispredict != -1 && s.mispredict-1 < final {
+		final = s.mispredict - 1
+	}
+	return final
+}
+
+// Returns the input from player p that peer hasn't acknowledged yet.
+func (s *Session) unacked(peer, p int) inputBlock {
+	block := inputBlock{player: p, first: s.acks[peer][p] + 1}
+	for f := block.first; f <= s.received[p] && len(block.inputs) < maxFramesPerBlock; f++ {
+		block.inputs = append(block.inputs, s.inputs[p][f])
+	}
+	return block
+}
+
+func (s *Session) send() {
+	type packet struct {
+		data []byte
+		to   net.Addr
+	}
+	var packets []packet
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	final := s.finalFrame()
+	var hashes []frameHash
+	for f := final; f > final-hashHistory && len(hashes) < maxHashesPerPacket; f-- {
+		if h, ok := s.hashes[f]; ok {
+			hashes = append(hashes, frameHash{f, h})
+		}
+	}
+	for peer, addr := range s.peers {
+		if addr == nil {
+			continue
+		}
+		if s.err == nil && now.Sub(s.last_heard[peer]) > s.timeout {
+			s.err = fmt.Errorf("Lost contact with player %d.", peer)
+		}
+		if !s.heard[peer] {
+			welcome := welcomeMsg{
+				Player:      uint8(peer),
+				Num_players: uint8(s.num_players),
+				Delay:       uint16(s.delay),
+				Rollback:    uint16(s.rollback),
+			}
+			packets = append(packets, packet{encodeWelcome(welcome), addr})
+			continue
+		}
+		msg := framesMsg{
+			sender: s.player,
+			acks:   append([]int64(nil), s.received...),
+			hashes: hashes,
+		}
+		for p := range s.inputs {
+			// The host relays everyone's input to each client, clients just send
+			// their own.
+			if p == peer || (s.player != 0 && p != s.player) {
+				continue
+			}
+			if block := s.unacked(peer, p); len(block.inputs) > 0 {
+				msg.blocks = append(msg.blocks, block)
+			}
+		}
+		packets = append(packets, packet{encodeFrames(&msg), addr})
+	}
+	s.checkHashes(final)
+	s.prune()
+	s.mutex.Unlock()
+
+	for _, p := range packets {
+		s.conn.WriteTo(p.data, p.to)
+	}
+}
+
+// Compares the hashes that peers have sent with ours, up to frame final.
+func (s *Session) checkHashes(final int64) {
+	for _, remote := range s.remote_hashes {
+		for frame, h := range remote {
+			if frame > final {
+				continue
+			}
+			if local, ok := s.hashes[frame]; ok && local != h && (s.desync == -1 || frame < s.desync) {
+				s.desync = frame
+			}
+			delete(remote, frame)
+		}
+	}
+}
+
+// Forgets inputs that nobody needs any more, and old hashes.
+func (s *Session) prune() {
+	floor := s.confirmed()
+	if s.current-1 < floor {
+		floor = s.current - 1
+	}
+	for peer, addr := range s.peers {
+		if addr == nil {
+			continue
+		}
+		for _, ack := range s.acks[peer] {
+			if ack < floor {
+				floor = ack
+			}
+		}
+	}
+	for _, inputs := range s.inputs {
+		for f := range inputs {
+			if f < floor {
+				delete(inputs, f)
+			}
+		}
+	}
+	for f := range s.hashes {
+		if f < floor-hashHistory {
+			delete(s.hashes, f)
+		}
+	}
+}
+
+// Adds this player's input for the next frame that doesn't have any, which
+// is InputDelay frames after the next frame that Advance() will return.
+// Returns false, and does nothing, if that frame already has input, so it's
+// fine to call this every time through the game loop.
+func (s *Session) AddInput(actions []Action) bool {
+	s.mutex.Lock()
+	frame := s.received[s.player] + 1
+	if frame > s.current+int64(s.delay) {
+		s.mutex.Unlock()
+		return false
+	}
+	s.inputs[s.player][frame] = append([]Action(nil), actions...)
+	s.received[s.player] = frame
+	s.mutex.Unlock()
+	s.send()
+	return true
+}
+
+// Returns the next frame to simulate and every player's input for it, indexed
+// by player.  Returns false if the frame can't be simulated yet because
+// someone's input hasn't arrived, or because AddInput() hasn't been called for
+// it.  With MaxRollback set, input that hasn't arrived is guessed to be empty.
+func (s *Session) Advance() (frame int64, inputs [][]Action, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil || s.closed || s.received[s.player] < s.current {
+		return 0, nil, false
+	}
+	if s.current > s.confirmed() && s.current-s.confirmed() > int64(s.rollback) {
+		return 0, nil, false
+	}
+	inputs = make([][]Action, s.num_players)
+	for p := range inputs {
+		if s.received[p] >= s.current {
+			inputs[p] = s.inputs[p][s.current]
+		}
+	}
+	frame = s.current
+	s.current++
+	return frame, inputs, true
+}
+
+// If a frame was simulated with a wrong guess about someone's input, returns
+// the first such frame.  The game should go back to its state from before it
+// simulated that frame, and simulate it again along with every frame after it
+// using the inputs from Advance(), which will start from that frame again.
+// Only happens when MaxRollback is set.
+func (s *Session) Rollback() (frame int64, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.mispredict == -1 {
+		return 0, false
+	}
+	frame = s.mispredict
+	s.mispredict = -1
+	s.current = frame
+	for f := range s.hashes {
+		if f >= frame {
+			delete(s.hashes, f)
+		}
+	}
+	return frame, true
+}
+
+// Records a hash of the game's state after simulating frame.  Hashes are
+// compared between players once everyone's input for the frame is known, and
+// if any don't match Desync() says so.
+func (s *Session) SetHash(frame int64, hash uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if frame < s.current {
+		s.hashes[frame] = hash
+	}
+}
+
+// Returns the first frame that this player's state was found to differ from
+// another player's on.
+func (s *Session) Desync() (frame int64, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.desync, s.desync != -1
+}
+
+// Returns the player number of this end of the session, the host is 0.
+func (s *Session) Player() int {
+	return s.player
+}
+
+func (s *Session) NumPlayers() int {
+	return s.num_players
+}
+
+// Returns the error that stopped the session, if any.
+func (s *Session) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+func (s *Session) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mutex.Unlock()
+	close(s.quit)
+	err := s.conn.Close()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,222 @@
+package net_test
+
+import (
+	"fmt"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	glopnet "github.com/runningwild/glop/net"
+	"net"
+	"time"
+)
+
+// Starts a session with num_players players on the loopback interface and
+// returns each player's end of it, indexed by player.
+func makeSessions(num_players int, opts glopnet.Options) []*glopnet.Session {
+	host_conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	sessions := make([]*glopnet.Session, num_players)
+	errs := make(chan error, num_players)
+	for i := 1; i < num_players; i++ {
+		go func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				errs <- err
+				return
+			}
+			s, err := glopnet.Join(conn, host_conn.LocalAddr(), glopnet.Options{Timeout: 5 * time.Second})
+			if err == nil {
+				sessions[s.Player()] = s
+			}
+			errs <- err
+		}()
+	}
+	opts.Timeout = 5 * time.Second
+	host, err := glopnet.Host(host_conn, num_players, opts)
+	if err != nil {
+		panic(err)
+	}
+	sessions[0] = host
+	for i := 1; i < num_players; i++ {
+		if err := <-errs; err != nil {
+			panic(err)
+		}
+	}
+	return sessions
+}
+
+func closeSessions(sessions []*glopnet.Session) {
+	for _, s := range sessions {
+		s.Close()
+	}
+}
+
+// Calls f until it returns true or a couple of seconds pass.
+func eventually(f func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if f() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func action(name string) []glopnet.Action {
+	return []glopnet.Action{{Name: name, Type: 1, PressAmt: 1}}
+}
+
+func LockstepSpec(c gospec.Context) {
+	sessions := makeSessions(3, glopnet.Options{InputDelay: 2})
+	defer closeSessions(sessions)
+
+	c.Specify("Everyone gets everyone's input for every frame", func() {
+		for _, s := range sessions {
+			c.Expect(s.NumPlayers(), Equals, 3)
+		}
+		// seen[p][f] is what player p saw for frame f.
+		seen := make([][]string, len(sessions))
+		for frame := 0; frame < 20; frame++ {
+			for p, s := range sessions {
+				s.AddInput(action(fmt.Sprintf("%d-%d", p, frame)))
+			}
+			for p, s := range sessions {
+				var inputs [][]glopnet.Action
+				var f int64
+				c.Assume(eventually(func() bool {
+					var ok bool
+					f, inputs, ok = s.Advance()
+					return ok
+				}), IsTrue)
+				c.Expect(f, Equals, int64(frame))
+				str := ""
+				for _, input := range inputs {
+					for _, a := range input {
+						str += a.Name + " "
+					}
+				}
+				seen[p] = append(seen[p], str)
+			}
+		}
+		for p := range sessions {
+			c.Expect(seen[p], ContainsInOrder, seen[0])
+		}
+		c.Expect(seen[0][0], Equals, "")
+		c.Expect(seen[0][1], Equals, "")
+		c.Expect(seen[0][2], Equals, "0-0 1-0 2-0 ")
+		c.Expect(seen[0][19], Equals, "0-17 1-17 2-17 ")
+	})
+
+	c.Specify("Input can only be added up to the input delay", func() {
+		c.Expect(sessions[0].AddInput(nil), IsTrue)
+		c.Expect(sessions[0].AddInput(nil), Equals, false)
+		_, _, ok := sessions[0].Advance()
+		c.Expect(ok, IsTrue)
+		c.Expect(sessions[0].AddInput(nil), IsTrue)
+	})
+
+	c.Specify("Frames wait for everyone's input", func() {
+		for _, s := range sessions {
+			s.AddInput(nil)
+		}
+		for frame := 0; frame < 3; frame++ {
+			c.Expect(eventually(func() bool {
+				_, _, ok := sessions[1].Advance()
+				return ok
+			}), IsTrue)
+		}
+		sessions[1].AddInput(nil)
+		time.Sleep(50 * time.Millisecond)
+		_, _, ok := sessions[1].Advance()
+		c.Expect(ok, Equals, false)
+	})
+}
+
+func RollbackSpec(c gospec.Context) {
+	sessions := makeSessions(2, glopnet.Options{MaxRollback: 3})
+	defer closeSessions(sessions)
+	host, client := sessions[0], sessions[1]
+
+	c.Specify("Missing input is guessed, and wrong guesses are rolled back", func() {
+		for frame := int64(0); frame < 3; frame++ {
+			host.AddInput(nil)
+			f, inputs, ok := host.Advance()
+			c.Assume(ok, IsTrue)
+			c.Expect(f, Equals, frame)
+			c.Expect(len(inputs[1]), Equals, 0)
+		}
+		host.AddInput(nil)
+		_, _, ok := host.Advance()
+		c.Expect(ok, Equals, false)
+
+		client.AddInput(nil)
+		_, _, ok = client.Advance()
+		c.Assume(ok, IsTrue)
+		client.AddInput(action("jump"))
+		var frame int64
+		c.Expect(eventually(func() bool {
+			frame, ok = host.Rollback()
+			return ok
+		}), IsTrue)
+		c.Expect(frame, Equals, int64(1))
+		f, inputs, ok := host.Advance()
+		c.Assume(ok, IsTrue)
+		c.Expect(f, Equals, int64(1))
+		c.Expect(inputs[1][0].Name, Equals, "jump")
+	})
+}
+
+func DesyncSpec(c gospec.Context) {
+	sessions := makeSessions(2, glopnet.Options{})
+	defer closeSessions(sessions)
+
+	c.Specify("Different hashes for the same frame are a desync", func() {
+		for frame := uint64(0); frame < 5; frame++ {
+			for _, s := range sessions {
+				s.AddInput(nil)
+			}
+			for p, s := range sessions {
+				c.Assume(eventually(func() bool {
+					_, _, ok := s.Advance()
+					return ok
+				}), IsTrue)
+				hash := frame
+				if frame >= 3 {
+					hash += uint64(p)
+				}
+				s.SetHash(int64(frame), hash)
+			}
+		}
+		for _, s := range sessions {
+			var frame int64
+			c.Expect(eventually(func() bool {
+				var ok bool
+				frame, ok = s.Desync()
+				return ok
+			}), IsTrue)
+			c.Expect(frame, Equals, int64(3))
+		}
+	})
+
+	c.Specify("Matching hashes are not a desync", func() {
+		for frame := int64(0); frame < 5; frame++ {
+			for _, s := range sessions {
+				s.AddInput(nil)
+			}
+			for _, s := range sessions {
+				eventually(func() bool {
+					_, _, ok := s.Advance()
+					return ok
+				})
+				s.SetHash(frame, uint64(frame))
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		for _, s := range sessions {
+			_, ok := s.Desync()
+			c.Expect(ok, Equals, false)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"io"
+)
+
+// Every packet starts with packetMagic, protocolVersion, and one of the msg
+// types.  Everything is little endian:
+//
+//	hello:    nothing else
+//	welcome:  uint8(player) uint8(num players) uint16(input delay) uint16(max rollback)
+//	frames:   uint8(sender) uint8(n) int64(ack)*n
+//	          uint8(n) block*n
+//	          uint8(n) [int64(frame) uint64(hash)]*n
+//	block:    uint8(player) int64(first frame) uint16(n) input*n
+//	input:    uint8(n) action*n
+//	action:   uint8(len) name uint8(type) float64(press amt)
+//	snapshot: gob encoded snapshotMsg
+//	ack:      int64(tick)
+//
+// The version should be bumped any time this format changes.
+const packetMagic = "GLLS"
+const protocolVersion uint16 = 2
+
+const (
+	msgHello uint8 = iota + 1
+	msgWelcome
+	msgFrames
+	msgSnapshot
+	msgAck
+)
+
+type welcomeMsg struct {
+	Player      uint8
+	Num_players uint8
+	Delay       uint16
+	Rollback    uint16
+}
+
+type inputBlock struct {
+	player int
+	first  int64
+	inputs [][]Action
+}
+
+type frameHash struct {
+	frame int64
+	hash  uint64
+}
+
+type framesMsg struct {
+	sender int
+
+	// acks[p] is the last frame that the sender has every input up to from
+	// player p.
+	acks   []int64
+	blocks []inputBlock
+	hashes []frameHash
+}
+
+// Changes to entities since the snapshot at tick Base, which the replica
+// has acknowledged, or since nothing if Base is -1.
+type snapshotMsg struct {
+	Tick    int64
+	Base    int64
+	Time    int64
+	Changes []entityChange
+	Removed []uint32
+}
+
+type entityChange struct {
+	Id    uint32
+	Moved bool
+	X, Y  float64
+
+	// Only set if the entity's data changed.
+	Data_changed bool
+	Data         []byte
+}
+
+func makePacket(msg uint8) *bytes.Buffer {
+	b := bytes.NewBufferString(packetMagic)
+	binary.Write(b, binary.LittleEndian, protocolVersion)
+	b.WriteByte(msg)
+	return b
+}
+
+// Checks the header on packet and returns the message type and the rest of
+// the packet.
+func readHeader(packet []byte) (uint8, *bytes.Reader, error) {
+	if len(packet) < len(packetMagic)+3 || string(packet[:len(packetMagic)]) != packetMagic {
+		return 0, nil, fmt.Errorf("Not a glop packet.")
+	}
+	packet = packet[len(packetMagic):]
+	if version := binary.LittleEndian.Uint16(packet); version != protocolVersion {
+		return 0, nil, fmt.Errorf("Peer speaks protocol version %d, expected %d.", version, protocolVersion)
+	}
+	return packet[2], bytes.NewReader(packet[3:]), nil
+}
+
+func encodeWelcome(w welcomeMsg) []byte {
+	b := makePacket(msgWelcome)
+	binary.Write(b, binary.LittleEndian, w)
+	return b.Bytes()
+}
+
+func decodeWelcome(r io.Reader) (welcomeMsg, error) {
+	var w welcomeMsg
+	err := binary.Read(r, binary.LittleEndian, &w)
+	return w, err
+}
+
+func encodeFrames(f *framesMsg) []byte {
+	b := makePacket(msgFrames)
+	le := binary.LittleEndian
+	b.WriteByte(uint8(f.sender))
+	b.WriteByte(uint8(len(f.acks)))
+	binary.Write(b, le, f.acks)
+	b.WriteByte(uint8(len(f.blocks)))
+	for _, block := range f.blocks {
+		b.WriteByte(uint8(block.player))
+		binary.Write(b, le, block.first)
+		binary.Write(b, le, uint16(len(block.inputs)))
+		for _, input := range block.inputs {
+			b.WriteByte(uint8(len(input)))
+			for _, action := range input {
+				b.WriteByte(uint8(len(action.Name)))
+				b.WriteString(action.Name)
+				b.WriteByte(uint8(action.Type))
+				binary.Write(b, le, action.PressAmt)
+			}
+		}
+	}
+	b.WriteByte(uint8(len(f.hashes)))
+	for _, h := range f.hashes {
+		binary.Write(b, le, h.frame)
+		binary.Write(b, le, h.hash)
+	}
+	return b.Bytes()
+}
+
+func decodeFrames(r *bytes.Reader) (*framesMsg, error) {
+	le := binary.LittleEndian
+	var f framesMsg
+	var err error
+	readByte := func() int {
+		if err != nil {
+			return 0
+		}
+		var v byte
+		v, err = r.ReadByte()
+		return int(v)
+	}
+	read := func(v interface{}) {
+		if err == nil {
+			err = binary.Read(r, le, v)
+		}
+	}
+	f.sender = readByte()
+	f.acks = make([]int64, readByte())
+	read(f.acks)
+	f.blocks = make([]inputBlock, readByte())
+	for i := range f.blocks {
+		block := &f.blocks[i]
+		block.player = readByte()
+		read(&block.first)
+		var n uint16
+		read(&n)
+		if err != nil {
+			break
+		}
+		block.inputs = make([][]Action, n)
+		for j := range block.inputs {
+			if num := readByte(); num > 0 {
+				block.inputs[j] = make([]Action, num)
+			}
+			for k := range block.inputs[j] {
+				action := &block.inputs[j][k]
+				name := make([]byte, readByte())
+				read(name)
+				action.Name = string(name)
+				action.Type = gin.EventType(readByte())
+				read(&action.PressAmt)
+			}
+		}
+	}
+	f.hashes = make([]frameHash, readByte())
+	for i := range f.hashes {
+		read(&f.hashes[i].frame)
+		read(&f.hashes[i].hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Malformed frames packet: %v", err)
+	}
+	return &f, nil
+}
+
+func encodeSnapshot(m *snapshotMsg) []byte {
+	b := makePacket(msgSnapshot)
+	gob.NewEncoder(b).Encode(m)
+	return b.Bytes()
+}
+
+func decodeSnapshot(r io.Reader) (*snapshotMsg, error) {
+	var m snapshotMsg
+	if err := gob.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("Malformed snapshot packet: %v", err)
+	}
+	return &m, nil
+}
+
+func encodeAck(tick int64) []byte {
+	b := makePacket(msgAck)
+	binary.Write(b, binary.LittleEndian, tick)
+	return b.Bytes()
+}
+
+func decodeAck(r io.Reader) (int64, error) {
+	var tick int64
+	err := binary.Read(r, binary.LittleEndian, &tick)
+	return tick, err
+}
@@ -0,0 +1,497 @@
+package net
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EntityState is what a Replicator sends about each entity.
+type EntityState struct {
+	X, Y float64
+
+	// Anything else about the entity, usually a gob encoded sprite.SpriteState
+	// so that replicas can SetSpriteState() on their copy of the sprite.  It is
+	// only sent when it changes, so it should be the same bytes whenever the
+	// entity is in the same state.
+	Data []byte
+}
+
+// How many snapshots each side keeps around.  The replicator sends changes
+// since the last snapshot the replica acknowledged, if that has fallen out of
+// its history then it sends everything.
+const snapshotHistory = 64
+
+// How long a replica can go without being heard from before the replicator
+// stops sending to it, and the other way around.
+const replicaTimeout = 10 * time.Second
+
+// How often replicas acknowledge the latest snapshot even if they haven't
+// gotten a new one, which is also how they first ask for snapshots.
+const ackInterval = 100 * time.Millisecond
+
+type snapshot struct {
+	tick int64
+
+	// When the snapshot was taken, according to the replicator's clock.
+	time     time.Duration
+	entities map[uint32]EntityState
+}
+
+// Returns the changes from base to s, base can be nil.
+func (s *snapshot) delta(base *snapshot) *snapshotMsg {
+	msg := &snapshotMsg{Tick: s.tick, Base: -1, Time: int64(s.time)}
+	var old map[uint32]EntityState
+	if base != nil {
+		msg.Base = base.tick
+		old = base.entities
+	}
+	for id, e := range s.entities {
+		prev, ok := old[id]
+		change := entityChange{Id: id}
+		if !ok || prev.X != e.X || prev.Y != e.Y {
+			change.Moved = true
+			change.X, change.Y = e.X, e.Y
+		}
+		if !ok || !bytes.Equal(prev.Data, e.Data) {
+			change.Data_changed = true
+			change.Data = e.Data
+		}
+		if change.Moved || change.Data_changed {
+			msg.Changes = append(msg.Changes, change)
+		}
+	}
+	for id := range old {
+		if _, ok := s.entities[id]; !ok {
+			msg.Removed = append(msg.Removed, id)
+		}
+	}
+	return msg
+}
+
+// Returns the snapshot that msg describes, base must be the snapshot at
+// msg.Base or nil if it is -1.
+func applyDelta(base *snapshot, msg *snapshotMsg) *snapshot {
+	s := &snapshot{
+		tick:     msg.Tick,
+		time:     time.Duration(msg.Time),
+		entities: make(map[uint32]EntityState),
+	}
+	if base != nil {
+		for id, e := range base.entities {
+			s.entities[id] = e
+		}
+	}
+	for _, id := range msg.Removed {
+		delete(s.entities, id)
+	}
+	for _, change := range msg.Changes {
+		e := s.entities[change.Id]
+		if change.Moved {
+			e.X, e.Y = change.X, change.Y
+		}
+		if change.Data_changed {
+			e.Data = change.Data
+		}
+		s.entities[change.Id] = e
+	}
+	return s
+}
+
+// A Replicator sends snapshots of entities to every Replica that asks for
+// them, tick_rate times a second.  This is for games that aren't
+// deterministic enough for lockstep, the replicator is the authority on where
+// everything is and replicas just show it.  Each snapshot only includes what
+// changed since the last one that the replica got, so entities that sit still
+// cost almost nothing.
+type Replicator struct {
+	conn  net.PacketConn
+	start time.Time
+
+	mutex    sync.Mutex
+	entities map[uint32]EntityState
+	tick     int64
+
+	// The last snapshotHistory snapshots, oldest first.
+	history []*snapshot
+
+	replicas map[string]*replicaPeer
+
+	err    error
+	closed bool
+	wg     sync.WaitGroup
+	quit   chan struct{}
+}
+
+type replicaPeer struct {
+	addr       net.Addr
+	acked      int64
+	last_heard time.Time
+}
+
+// Makes a replicator that sends snapshots on conn, which is usually from
+// net.ListenPacket("udp", ":port").  The replicator owns conn and closes it
+// when it is closed.
+func MakeReplicator(conn net.PacketConn, tick_rate int) *Replicator {
+	if tick_rate <= 0 {
+		panic(fmt.Sprintf("Cannot replicate at %d ticks per second.", tick_rate))
+	}
+	r := &Replicator{
+		conn:     conn,
+		start:    time.Now(),
+		entities: make(map[uint32]EntityState),
+		replicas: make(map[string]*replicaPeer),
+		quit:     make(chan struct{}),
+	}
+	r.wg.Add(2)
+	go r.receive()
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(time.Second / time.Duration(tick_rate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.send()
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// Sets the state of entity id, adding it if it is new.  The change goes out
+// with the next snapshot.  state.Data must not be modified afterwards.
+func (r *Replicator) Set(id uint32, state EntityState) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entities[id] = state
+}
+
+// Removes entity id, replicas stop seeing it after the next snapshot.
+func (r *Replicator) Remove(id uint32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.entities, id)
+}
+
+// Returns the number of replicas that snapshots are being sent to.
+func (r *Replicator) NumReplicas() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.replicas)
+}
+
+func (r *Replicator) receive() {
+	defer r.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			r.mutex.Lock()
+			if !r.closed && r.err == nil {
+				r.err = err
+			}
+			r.mutex.Unlock()
+			return
+		}
+		msg, rd, err := readHeader(buf[:n])
+		if err != nil || msg != msgAck {
+			continue
+		}
+		tick, err := decodeAck(rd)
+		if err != nil {
+			continue
+		}
+		r.mutex.Lock()
+		peer, ok := r.replicas[from.String()]
+		if !ok {
+			peer = &replicaPeer{addr: from, acked: -1}
+			r.replicas[from.String()] = peer
+		}
+		if tick > peer.acked && tick <= r.tick {
+			peer.acked = tick
+		}
+		peer.last_heard = time.Now()
+		r.mutex.Unlock()
+	}
+}
+
+// Takes a snapshot and sends each replica what changed since the last
+// snapshot it acknowledged.
+func (r *Replicator) send() {
+	type packet struct {
+		data []byte
+		to   net.Addr
+	}
+	var packets []packet
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return
+	}
+	r.tick++
+	snap := &snapshot{
+		tick:     r.tick,
+		time:     time.Since(r.start),
+		entities: make(map[uint32]EntityState, len(r.entities)),
+	}
+	for id, e := range r.entities {
+		snap.entities[id] = e
+	}
+	r.history = append(r.history, snap)
+	if len(r.history) > snapshotHistory {
+		r.history[0] = nil
+		r.history = r.history[1:]
+	}
+	now := time.Now()
+	for key, peer := range r.replicas {
+		if now.Sub(peer.last_heard) > replicaTimeout {
+			delete(r.replicas, key)
+			continue
+		}
+		var base *snapshot
+		if first := r.history[0].tick; peer.acked >= first {
+			base = r.history[peer.acked-first]
+		}
+		packets = append(packets, packet{encodeSnapshot(snap.delta(base)), peer.addr})
+	}
+	r.mutex.Unlock()
+	for _, p := range packets {
+		r.conn.WriteTo(p.data, p.to)
+	}
+}
+
+// Returns the error that stopped the replicator, if any.
+func (r *Replicator) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+func (r *Replicator) Close() error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mutex.Unlock()
+	close(r.quit)
+	err := r.conn.Close()
+	r.wg.Wait()
+	return err
+}
+
+// A Replica receives snapshots from a Replicator and interpolates between
+// them, so that entities move smoothly even though snapshots only come a few
+// times a second and sometimes get lost.
+type Replica struct {
+	conn   net.PacketConn
+	server net.Addr
+	delay  time.Duration
+	start  time.Time
+
+	mutex sync.Mutex
+
+	// The last snapshotHistory snapshots, oldest first.
+	snapshots []*snapshot
+
+	// Our clock minus the replicator's clock, plus the quickest that a
+	// snapshot has gotten here.
+	offset     time.Duration
+	synced     bool
+	last_heard time.Time
+
+	err    error
+	closed bool
+	wg     sync.WaitGroup
+	quit   chan struct{}
+}
+
+// Makes a replica that gets snapshots from the replicator at server,
+// sending and receiving on conn, which is usually from
+// net.ListenPacket("udp", ":0").  Entities are shown as they were delay ago,
+// which should be at least a couple of the replicator's ticks so that there
+// is usually a newer snapshot to interpolate towards.  The replica owns conn
+// and closes it when it is closed.
+func MakeReplica(conn net.PacketConn, server net.Addr, delay time.Duration) *Replica {
+	r := &Replica{
+		conn:       conn,
+		server:     server,
+		delay:      delay,
+		start:      time.Now(),
+		last_heard: time.Now(),
+		quit:       make(chan struct{}),
+	}
+	r.wg.Add(2)
+	go r.receive()
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(ackInterval)
+		defer ticker.Stop()
+		r.ack()
+		for {
+			select {
+			case <-ticker.C:
+				r.ack()
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// Tells the replicator the latest snapshot we have.
+func (r *Replica) ack() {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return
+	}
+	if r.err == nil && time.Since(r.last_heard) > replicaTimeout {
+		r.err = fmt.Errorf("Lost contact with %v.", r.server)
+	}
+	tick := r.latest()
+	r.mutex.Unlock()
+	r.conn.WriteTo(encodeAck(tick), r.server)
+}
+
+// Returns the tick of the newest snapshot, or -1, the replica's mutex must be
+// held.
+func (r *Replica) latest() int64 {
+	if len(r.snapshots) == 0 {
+		return -1
+	}
+	return r.snapshots[len(r.snapshots)-1].tick
+}
+
+func (r *Replica) receive() {
+	defer r.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			r.mutex.Lock()
+			if !r.closed && r.err == nil {
+				r.err = err
+			}
+			r.mutex.Unlock()
+			return
+		}
+		if from.String() != r.server.String() {
+			continue
+		}
+		msg, rd, err := readHeader(buf[:n])
+		if err != nil || msg != msgSnapshot {
+			continue
+		}
+		snap, err := decodeSnapshot(rd)
+		if err != nil {
+			continue
+		}
+		r.mutex.Lock()
+		ok := r.handleSnapshot(snap)
+		tick := r.latest()
+		r.mutex.Unlock()
+		if ok {
+			r.conn.WriteTo(encodeAck(tick), r.server)
+		}
+	}
+}
+
+// Takes in a snapshot packet, returns false if it was out of date or its base
+// is gone.  The replica's mutex must be held.
+func (r *Replica) handleSnapshot(msg *snapshotMsg) bool {
+	if msg.Tick <= r.latest() {
+		return false
+	}
+	var base *snapshot
+	if msg.Base != -1 {
+		for _, s := range r.snapshots {
+			if s.tick == msg.Base {
+				base = s
+			}
+		}
+		if base == nil {
+			return false
+		}
+	}
+	s := applyDelta(base, msg)
+	now := time.Since(r.start)
+	if offset := now - s.time; !r.synced || offset < r.offset {
+		r.offset = offset
+		r.synced = true
+	}
+	r.last_heard = time.Now()
+	r.snapshots = append(r.snapshots, s)
+	if len(r.snapshots) > snapshotHistory {
+		r.snapshots[0] = nil
+		r.snapshots = r.snapshots[1:]
+	}
+	return true
+}
+
+// Returns every entity as it was delay ago.  Positions are interpolated
+// between the snapshots on either side of that time, and everything else
+// comes from the older one.  The Data in the returned states must not be
+// modified.
+func (r *Replica) Entities() map[uint32]EntityState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entities := make(map[uint32]EntityState)
+	if len(r.snapshots) == 0 {
+		return entities
+	}
+	at := time.Since(r.start) - r.offset - r.delay
+	i := sort.Search(len(r.snapshots), func(i int) bool {
+		return r.snapshots[i].time > at
+	})
+	if i == 0 || i == len(r.snapshots) {
+		// Nothing to interpolate with, we either just started or the
+		// replicator has fallen behind.
+		if i > 0 {
+			i--
+		}
+		for id, e := range r.snapshots[i].entities {
+			entities[id] = e
+		}
+		return entities
+	}
+	prev, next := r.snapshots[i-1], r.snapshots[i]
+	t := float64(at-prev.time) / float64(next.time-prev.time)
+	for id, e := range prev.entities {
+		if n, ok := next.entities[id]; ok {
+			e.X += (n.X - e.X) * t
+			e.Y += (n.Y - e.Y) * t
+		}
+		entities[id] = e
+	}
+	return entities
+}
+
+// Returns the error that stopped the replica, if any.
+func (r *Replica) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+func (r *Replica) Close() error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mutex.Unlock()
+	close(r.quit)
+	err := r.conn.Close()
+	r.wg.Wait()
+	return err
+}
@@ -0,0 +1,72 @@
+package net_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	glopnet "github.com/runningwild/glop/net"
+	"net"
+	"time"
+)
+
+func makeReplication(tick_rate int, delay time.Duration) (*glopnet.Replicator, *glopnet.Replica) {
+	server_conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	client_conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	return glopnet.MakeReplicator(server_conn, tick_rate), glopnet.MakeReplica(client_conn, server_conn.LocalAddr(), delay)
+}
+
+func ReplicateSpec(c gospec.Context) {
+	c.Specify("Replicas see the replicator's entities", func() {
+		server, replica := makeReplication(100, 0)
+		defer server.Close()
+		defer replica.Close()
+		server.Set(1, glopnet.EntityState{X: 1, Y: 2, Data: []byte("idle")})
+		server.Set(2, glopnet.EntityState{X: 3, Y: 4})
+		c.Expect(eventually(func() bool { return len(replica.Entities()) == 2 }), Equals, true)
+		c.Expect(server.NumReplicas(), Equals, 1)
+		entities := replica.Entities()
+		c.Expect(entities[1].X, Equals, 1.0)
+		c.Expect(entities[1].Y, Equals, 2.0)
+		c.Expect(string(entities[1].Data), Equals, "idle")
+		c.Expect(entities[2].X, Equals, 3.0)
+		c.Expect(entities[2].Y, Equals, 4.0)
+
+		c.Specify("and changes to them", func() {
+			server.Set(1, glopnet.EntityState{X: 1, Y: 2, Data: []byte("walk")})
+			c.Expect(eventually(func() bool { return string(replica.Entities()[1].Data) == "walk" }), Equals, true)
+			c.Expect(replica.Entities()[1].X, Equals, 1.0)
+
+			server.Set(2, glopnet.EntityState{X: 5, Y: 6})
+			c.Expect(eventually(func() bool { return replica.Entities()[2].X == 5 }), Equals, true)
+			c.Expect(replica.Entities()[2].Y, Equals, 6.0)
+			c.Expect(string(replica.Entities()[1].Data), Equals, "walk")
+		})
+
+		c.Specify("and when they're removed", func() {
+			server.Remove(1)
+			c.Expect(eventually(func() bool { return len(replica.Entities()) == 1 }), Equals, true)
+			_, ok := replica.Entities()[2]
+			c.Expect(ok, Equals, true)
+		})
+	})
+
+	c.Specify("Replicas interpolate between snapshots", func() {
+		server, replica := makeReplication(20, 100*time.Millisecond)
+		defer server.Close()
+		defer replica.Close()
+		server.Set(1, glopnet.EntityState{X: 0})
+		c.Expect(eventually(func() bool { return len(replica.Entities()) == 1 }), Equals, true)
+		server.Set(1, glopnet.EntityState{X: 100})
+		between := eventually(func() bool {
+			x := replica.Entities()[1].X
+			return x > 0 && x < 100
+		})
+		c.Expect(between, Equals, true)
+		c.Expect(eventually(func() bool { return replica.Entities()[1].X == 100 }), Equals, true)
+	})
+}
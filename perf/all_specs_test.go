@@ -0,0 +1,13 @@
+package perf_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(FrameSpec)
+	r.AddSpec(TraceSpec)
+	gospec.MainGoTest(r, t)
+}
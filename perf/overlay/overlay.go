@@ -0,0 +1,112 @@
+// Package overlay draws a graph of recent frame times from a perf.Profiler
+// over the top of the game.  Each frame is a bar split up by phase, so spikes
+// and what caused them stand out while playing.
+package overlay
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/perf"
+	"github.com/runningwild/glop/render"
+	"github.com/runningwild/glop/text"
+	"time"
+)
+
+type Overlay struct {
+	profiler *perf.Profiler
+	dict     *text.Dictionary
+	batch    render.QuadBatch
+
+	// How many frames are graphed, and how long a frame has to be to reach
+	// the top of the graph.  A line is drawn across the graph at Target.
+	Frames int
+	Scale  time.Duration
+	Target time.Duration
+
+	// The phases to show, from the bottom of each bar up, and their colors.
+	// Time in a frame that isn't in any of them is drawn in Other.
+	Phases []string
+	Colors map[string][4]float32
+	Other  [4]float32
+}
+
+// Makes an overlay that graphs the last 120 frames from profiler, scaled for
+// a game that runs at 60 frames per second.  If dict isn't nil it is used to
+// label the graph with the average frame time and time in each phase.
+func Make(profiler *perf.Profiler, dict *text.Dictionary) *Overlay {
+	return &Overlay{
+		profiler: profiler,
+		dict:     dict,
+		Frames:   120,
+		Scale:    time.Second / 30,
+		Target:   time.Second / 60,
+		Phases:   []string{perf.Input, perf.Think, perf.Render, perf.Swap},
+		Colors: map[string][4]float32{
+			perf.Input:  {0.3, 0.6, 1, 0.8},
+			perf.Think:  {0.3, 1, 0.3, 0.8},
+			perf.Render: {1, 0.6, 0.2, 0.8},
+			perf.Swap:   {0.8, 0.3, 1, 0.8},
+		},
+		Other: [4]float32{0.5, 0.5, 0.5, 0.8},
+	}
+}
+
+// Draws the graph in the rectangle from x, y to x+dx, y+dy in screen
+// coordinates, with the newest frame on the right.  Must be called on the
+// render thread.
+func (o *Overlay) Draw(x, y, dx, dy float64) error {
+	render.MustRunOnRenderThread()
+	frames := o.profiler.Frames(o.Frames)
+	height := func(d time.Duration) float64 {
+		h := dy * float64(d) / float64(o.Scale)
+		if h > dy {
+			return dy
+		}
+		return h
+	}
+	width := dx / float64(o.Frames)
+	left := x + dx - width*float64(len(frames))
+	o.batch.Add(float32(x), float32(y), float32(x+dx), float32(y+dy), 0, 0, 1, 1, [4]float32{0, 0, 0, 0.5})
+	var total time.Duration
+	phases := make(map[string]time.Duration)
+	for i, f := range frames {
+		x0, x1 := float32(left+width*float64(i)), float32(left+width*float64(i+1))
+		var bottom time.Duration
+		for _, name := range o.Phases {
+			d := f.Phases[name]
+			phases[name] += d
+			o.batch.Add(x0, float32(y+height(bottom)), x1, float32(y+height(bottom+d)), 0, 0, 1, 1, o.Colors[name])
+			bottom += d
+		}
+		if f.Duration > bottom {
+			o.batch.Add(x0, float32(y+height(bottom)), x1, float32(y+height(f.Duration)), 0, 0, 1, 1, o.Other)
+		}
+		total += f.Duration
+	}
+	if o.Target > 0 && o.Target <= o.Scale {
+		ty := float32(y + height(o.Target))
+		o.batch.Add(float32(x), ty, float32(x+dx), ty+1, 0, 0, 1, 1, [4]float32{1, 0.2, 0.2, 1})
+	}
+	if err := o.batch.Draw(nil); err != nil {
+		return err
+	}
+	if o.dict == nil || len(frames) == 0 {
+		return nil
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond) / float64(len(frames))
+	}
+	label := fmt.Sprintf("frame %.1fms", ms(total))
+	for _, name := range o.Phases {
+		label += fmt.Sprintf("  %s %.1fms", name, ms(phases[name]))
+	}
+	o.dict.SetFontColor(1, 1, 1)
+	o.dict.RenderString(label, x, y+dy, 12)
+	return nil
+}
+
+// Frees the gl objects used to draw the graph.  Must be called on the render
+// thread.
+func (o *Overlay) Delete() {
+	render.MustRunOnRenderThread()
+	o.batch.Delete()
+}
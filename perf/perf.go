@@ -0,0 +1,274 @@
+// Package perf measures how long the phases of each frame take, so that it's
+// easy to see whether a slow frame was spent on input, game logic, drawing, or
+// waiting for the swap.  Code marks a phase with Begin() and End(), and
+// EndFrame() is called once per frame to total them up.  system.Run() marks
+// the Input, Think, Render, and Swap phases itself.
+//
+// Recent frames are kept for perf/overlay to graph on screen, and while a
+// trace is running every span is also recorded so that it can be written out
+// in Chrome's trace format and loaded into chrome://tracing or Perfetto.
+//
+// Profiling is off until SetEnabled(true) is called, and costs next to nothing
+// while it is off.
+package perf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The phases that glop marks.
+const (
+	Input  = "input"
+	Think  = "think"
+	Render = "render"
+	Swap   = "swap"
+)
+
+// Timings for one frame.
+type Frame struct {
+	Start    time.Time
+	Duration time.Duration
+
+	// Total time spent in spans with each name during the frame.  Spans that
+	// are inside of other spans count towards both.
+	Phases map[string]time.Duration
+}
+
+// One span, for traces.  Start is relative to when the trace was started.
+type Event struct {
+	Name     string
+	Thread   int64
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// A Profiler is safe to use from any goroutine, so spans can be marked on the
+// render thread as well as the main thread.
+type Profiler struct {
+	mutex   sync.Mutex
+	enabled bool
+
+	// The frame in progress.
+	cur Frame
+
+	// Recent frames, frames[next] is the oldest once it's full.
+	frames []Frame
+	next   int
+	full   bool
+
+	// Set between StartTrace() and StopTrace().
+	tracing     bool
+	trace_start time.Time
+	events      []Event
+	max_events  int
+}
+
+// Makes a Profiler that keeps the last history frames.
+func MakeProfiler(history int) *Profiler {
+	if history <= 0 {
+		panic(fmt.Sprintf("Cannot keep %d frames.", history))
+	}
+	return &Profiler{frames: make([]Frame, history)}
+}
+
+var the_profiler = MakeProfiler(240)
+
+// Returns the Profiler used by the package level functions.
+func Default() *Profiler {
+	return the_profiler
+}
+
+func SetEnabled(enabled bool) {
+	the_profiler.SetEnabled(enabled)
+}
+
+func Begin(name string) Span {
+	return the_profiler.Begin(name)
+}
+
+func EndFrame() {
+	the_profiler.EndFrame()
+}
+
+func Frames(n int) []Frame {
+	return the_profiler.Frames(n)
+}
+
+func StartTrace(max_events int) {
+	the_profiler.StartTrace(max_events)
+}
+
+func StopTrace() []Event {
+	return the_profiler.StopTrace()
+}
+
+func (p *Profiler) SetEnabled(enabled bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.enabled = enabled
+	p.cur = Frame{}
+}
+
+func (p *Profiler) Enabled() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.enabled
+}
+
+// A Span is returned by Begin() and times everything until End() is called
+// on it.
+type Span struct {
+	p      *Profiler
+	name   string
+	thread int64
+	start  time.Time
+}
+
+// Starts timing a span called name, usually one of the phases:
+//
+//	defer perf.Begin(perf.Think).End()
+func (p *Profiler) Begin(name string) Span {
+	p.mutex.Lock()
+	enabled, tracing := p.enabled, p.tracing
+	p.mutex.Unlock()
+	if !enabled {
+		return Span{}
+	}
+	s := Span{p: p, name: name, start: time.Now()}
+	if tracing {
+		s.thread = goroutineId()
+	}
+	return s
+}
+
+// Stops timing s.  A span that ends after EndFrame() counts towards the frame
+// that it ends in.
+func (s Span) End() {
+	if s.p == nil {
+		return
+	}
+	d := time.Since(s.start)
+	p := s.p
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.enabled {
+		return
+	}
+	if p.cur.Phases == nil {
+		p.cur.Phases = make(map[string]time.Duration)
+	}
+	p.cur.Phases[s.name] += d
+	if p.tracing && len(p.events) < p.max_events && !s.start.Before(p.trace_start) {
+		thread := s.thread
+		if thread == 0 {
+			// The trace started while this span was in progress.
+			thread = goroutineId()
+		}
+		p.events = append(p.events, Event{s.name, thread, s.start.Sub(p.trace_start), d})
+	}
+}
+
+// Finishes the current frame and starts the next one.  Should be called once
+// per frame, from the same place in the main loop every time.
+func (p *Profiler) EndFrame() {
+	now := time.Now()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.enabled {
+		return
+	}
+	if !p.cur.Start.IsZero() {
+		p.cur.Duration = now.Sub(p.cur.Start)
+		p.frames[p.next] = p.cur
+		p.next++
+		if p.next == len(p.frames) {
+			p.next = 0
+			p.full = true
+		}
+	}
+	p.cur = Frame{Start: now}
+}
+
+// Returns up to the last n frames, oldest first.
+func (p *Profiler) Frames(n int) []Frame {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stored := p.next
+	if p.full {
+		stored = len(p.frames)
+	}
+	if n > stored {
+		n = stored
+	}
+	frames := make([]Frame, n)
+	for i := range frames {
+		frames[i] = p.frames[(p.next-n+i+len(p.frames))%len(p.frames)]
+	}
+	return frames
+}
+
+// Starts recording every span, up to max_events of them, for a trace.  Any
+// trace that was already running is thrown away.
+func (p *Profiler) StartTrace(max_events int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.tracing = true
+	p.trace_start = time.Now()
+	p.events = nil
+	p.max_events = max_events
+}
+
+// Stops recording spans and returns the ones recorded since StartTrace(), in
+// the order that they ended.
+func (p *Profiler) StopTrace() []Event {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.tracing = false
+	events := p.events
+	p.events = nil
+	return events
+}
+
+type traceEvent struct {
+	Name  string  `json:"name"`
+	Phase string  `json:"ph"`
+	Ts    float64 `json:"ts"`
+	Dur   float64 `json:"dur"`
+	Pid   int     `json:"pid"`
+	Tid   int64   `json:"tid"`
+}
+
+// Writes events in Chrome's trace event format, each one as a complete event
+// on the goroutine it happened on.
+func WriteTrace(w io.Writer, events []Event) error {
+	var trace struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}
+	trace.TraceEvents = make([]traceEvent, 0, len(events))
+	trace.DisplayTimeUnit = "ms"
+	micro := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Microsecond)
+	}
+	for _, e := range events {
+		trace.TraceEvents = append(trace.TraceEvents, traceEvent{e.Name, "X", micro(e.Start), micro(e.Duration), 1, e.Thread})
+	}
+	return json.NewEncoder(w).Encode(trace)
+}
+
+// Returns the id of the current goroutine, the same way that render does.
+func goroutineId() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	b = b[:bytes.IndexByte(b, ' ')]
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
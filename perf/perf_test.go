@@ -0,0 +1,109 @@
+package perf_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/perf"
+	"time"
+)
+
+func FrameSpec(c gospec.Context) {
+	p := perf.MakeProfiler(3)
+
+	c.Specify("Nothing is recorded until the profiler is enabled", func() {
+		p.Begin(perf.Think).End()
+		p.EndFrame()
+		p.EndFrame()
+		c.Expect(len(p.Frames(10)), Equals, 0)
+	})
+
+	c.Specify("Spans are totalled by name for each frame", func() {
+		p.SetEnabled(true)
+		p.EndFrame()
+		s := p.Begin(perf.Think)
+		time.Sleep(2 * time.Millisecond)
+		s.End()
+		s = p.Begin(perf.Think)
+		time.Sleep(2 * time.Millisecond)
+		s.End()
+		p.Begin(perf.Swap).End()
+		p.EndFrame()
+		frames := p.Frames(10)
+		c.Assume(len(frames), Equals, 1)
+		f := frames[0]
+		c.Expect(f.Phases[perf.Think] >= 4*time.Millisecond, Equals, true)
+		c.Expect(f.Phases[perf.Swap] < f.Phases[perf.Think], Equals, true)
+		c.Expect(f.Duration >= f.Phases[perf.Think], Equals, true)
+		c.Expect(f.Phases[perf.Input], Equals, time.Duration(0))
+	})
+
+	c.Specify("Only the most recent frames are kept, oldest first", func() {
+		p.SetEnabled(true)
+		p.EndFrame()
+		for i := 1; i <= 5; i++ {
+			for j := 0; j < i; j++ {
+				p.Begin(perf.Render).End()
+			}
+			p.EndFrame()
+		}
+		frames := p.Frames(10)
+		c.Assume(len(frames), Equals, 3)
+		c.Expect(frames[0].Start.Before(frames[1].Start), Equals, true)
+		c.Expect(frames[1].Start.Before(frames[2].Start), Equals, true)
+		c.Expect(len(p.Frames(2)), Equals, 2)
+		c.Expect(p.Frames(2)[1].Start, Equals, frames[2].Start)
+	})
+}
+
+func TraceSpec(c gospec.Context) {
+	p := perf.MakeProfiler(10)
+	p.SetEnabled(true)
+
+	c.Specify("Spans are only traced between StartTrace and StopTrace", func() {
+		p.Begin("before").End()
+		p.StartTrace(100)
+		p.Begin(perf.Think).End()
+		done := make(chan bool)
+		go func() {
+			p.Begin(perf.Render).End()
+			close(done)
+		}()
+		<-done
+		events := p.StopTrace()
+		p.Begin("after").End()
+		c.Assume(len(events), Equals, 2)
+		c.Expect(events[0].Name, Equals, perf.Think)
+		c.Expect(events[1].Name, Equals, perf.Render)
+		c.Expect(events[0].Thread, Not(Equals), events[1].Thread)
+		c.Expect(events[0].Start <= events[1].Start, Equals, true)
+	})
+
+	c.Specify("Traces stop recording at their limit", func() {
+		p.StartTrace(2)
+		for i := 0; i < 5; i++ {
+			p.Begin(perf.Think).End()
+		}
+		c.Expect(len(p.StopTrace()), Equals, 2)
+	})
+
+	c.Specify("Traces are written in Chrome's format", func() {
+		events := []perf.Event{
+			{Name: perf.Think, Thread: 7, Start: 1500 * time.Microsecond, Duration: 2 * time.Millisecond},
+		}
+		var buf bytes.Buffer
+		c.Assume(perf.WriteTrace(&buf, events), Equals, nil)
+		var trace struct {
+			TraceEvents []map[string]interface{}
+		}
+		c.Assume(json.Unmarshal(buf.Bytes(), &trace), Equals, nil)
+		c.Assume(len(trace.TraceEvents), Equals, 1)
+		e := trace.TraceEvents[0]
+		c.Expect(e["name"], Equals, perf.Think)
+		c.Expect(e["ph"], Equals, "X")
+		c.Expect(e["ts"], Equals, 1500.0)
+		c.Expect(e["dur"], Equals, 2000.0)
+		c.Expect(e["tid"], Equals, 7.0)
+	})
+}
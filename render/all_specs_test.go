@@ -0,0 +1,18 @@
+package render_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	// Every spec shares the one render thread, the Software pipeline lets
+	// them draw without a GL context.
+	render.Init(render.Software)
+	r := gospec.NewRunner()
+	r.AddSpec(QueueSpec)
+	r.AddSpec(AtlasSpec)
+	r.AddSpec(SoftwareSpec)
+	gospec.MainGoTest(r, t)
+}
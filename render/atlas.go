@@ -0,0 +1,397 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// An Atlas packs lots of small images, like glyphs, icons, and particles,
+// into a few shared textures, so that drawing them doesn't need a texture
+// each and can be done in one QuadBatch per page.  Images can be added at any
+// time from any goroutine.  Pages start small and double in size as they
+// fill up, and once there are as many pages as allowed and they are all as
+// big as allowed, the page that was used the longest ago is emptied to make
+// room, see AtlasImage.Valid().
+type Atlas struct {
+	mutex     sync.Mutex
+	max_size  int
+	max_pages int
+	pages     []*atlasPage
+	images    map[string]*AtlasImage
+
+	// Bumped by Add() and Get() so that pages know when they were last used.
+	clock int64
+}
+
+// The size that pages start at, unless the atlas has a smaller max_size.
+const atlasStartSize = 256
+
+// Space left around each image so that filtering doesn't bleed neighbors
+// into it.
+const atlasPadding = 1
+
+type atlasPage struct {
+	// Pixels of the page, dx*dy RGBA starting from the top left.
+	pix    []byte
+	dx, dy int
+	sky    skyline
+	images []*AtlasImage
+
+	// The texture that is drawn with, and the one replacing it once it is
+	// uploaded, see Flush().
+	texture *Texture
+	pending *Texture
+	dirty   bool
+}
+
+// An image in an Atlas.  Its page and place in the page can change when the
+// page grows, so it should be drawn with whatever UV() and Texture() return
+// at the time.
+type AtlasImage struct {
+	atlas  *Atlas
+	key    string
+	page   *atlasPage
+	x, y   int
+	dx, dy int
+	used   int64
+}
+
+// Makes an atlas with up to max_pages pages that are each at most max_size
+// pixels on a side.
+func MakeAtlas(max_size, max_pages int) *Atlas {
+	if max_size <= 0 || max_pages <= 0 {
+		panic(fmt.Sprintf("Cannot make an atlas of %d pages of size %d.", max_pages, max_size))
+	}
+	return &Atlas{
+		max_size:  max_size,
+		max_pages: max_pages,
+		images:    make(map[string]*AtlasImage),
+	}
+}
+
+// Adds im to a with key, or returns the image already added with key.  Takes
+// effect once Flush() is called.  Returns an error if im is too big to fit
+// on a page.
+func (a *Atlas) Add(key string, im image.Image) (*AtlasImage, error) {
+	bounds := im.Bounds()
+	rgba, ok := im.(*image.RGBA)
+	if !ok || rgba.Stride != 4*bounds.Dx() {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Rect, im, bounds.Min, draw.Src)
+	}
+	return a.AddRGBA(key, rgba.Pix, bounds.Dx(), bounds.Dy())
+}
+
+// Like Add() but with dx*dy pixels of tightly packed RGBA.  pix is copied, so
+// it can be changed as soon as this returns.
+func (a *Atlas) AddRGBA(key string, pix []byte, dx, dy int) (*AtlasImage, error) {
+	if len(pix) != 4*dx*dy {
+		panic("render.Atlas.AddRGBA() requires exactly 4*dx*dy bytes of pixel data.")
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clock++
+	if img, ok := a.images[key]; ok {
+		img.used = a.clock
+		return img, nil
+	}
+	w, h := dx+2*atlasPadding, dy+2*atlasPadding
+	if w > a.max_size || h > a.max_size {
+		return nil, fmt.Errorf("A %dx%d image can't fit in an atlas with %dx%d pages.", dx, dy, a.max_size, a.max_size)
+	}
+	page, x, y := a.place(w, h)
+	img := &AtlasImage{atlas: a, key: key, page: page, x: x + atlasPadding, y: y + atlasPadding, dx: dx, dy: dy, used: a.clock}
+	for row := 0; row < dy; row++ {
+		start := 4 * ((img.y+row)*page.dx + img.x)
+		copy(page.pix[start:start+4*dx], pix[4*row*dx:4*(row+1)*dx])
+	}
+	page.images = append(page.images, img)
+	page.dirty = true
+	a.images[key] = img
+	return img, nil
+}
+
+// Finds room for a w by h rectangle, growing, adding, or emptying pages until
+// there is some.
+func (a *Atlas) place(w, h int) (page *atlasPage, x, y int) {
+	for {
+		for _, page := range a.pages {
+			if x, y, ok := page.sky.insert(w, h); ok {
+				return page, x, y
+			}
+		}
+		if page := a.growable(); page != nil {
+			page.grow()
+			continue
+		}
+		if len(a.pages) < a.max_pages {
+			size := atlasStartSize
+			for size < w || size < h {
+				size *= 2
+			}
+			if size > a.max_size {
+				size = a.max_size
+			}
+			a.pages = append(a.pages, makeAtlasPage(size))
+			continue
+		}
+		a.evict()
+	}
+}
+
+// Returns the smallest page that can still grow, or nil if they're all full
+// size.
+func (a *Atlas) growable() *atlasPage {
+	var best *atlasPage
+	for _, page := range a.pages {
+		if page.dx < a.max_size || page.dy < a.max_size {
+			if best == nil || page.dx*page.dy < best.dx*best.dy {
+				best = page
+			}
+		}
+	}
+	return best
+}
+
+// Empties the page that was used the longest ago.
+func (a *Atlas) evict() {
+	var oldest *atlasPage
+	var oldest_used int64
+	for _, page := range a.pages {
+		var used int64
+		for _, img := range page.images {
+			if img.used > used {
+				used = img.used
+			}
+		}
+		if oldest == nil || used < oldest_used {
+			oldest, oldest_used = page, used
+		}
+	}
+	for _, img := range oldest.images {
+		img.page = nil
+		delete(a.images, img.key)
+	}
+	oldest.images = nil
+	for i := range oldest.pix {
+		oldest.pix[i] = 0
+	}
+	oldest.sky = makeSkyline(oldest.dx, oldest.dy)
+	oldest.dirty = true
+}
+
+func makeAtlasPage(size int) *atlasPage {
+	return &atlasPage{
+		pix: make([]byte, 4*size*size),
+		dx:  size,
+		dy:  size,
+		sky: makeSkyline(size, size),
+	}
+}
+
+// Doubles the size of the page, keeping its images where they are.
+func (page *atlasPage) grow() {
+	dx, dy := 2*page.dx, 2*page.dy
+	pix := make([]byte, 4*dx*dy)
+	for row := 0; row < page.dy; row++ {
+		copy(pix[4*row*dx:], page.pix[4*row*page.dx:4*(row+1)*page.dx])
+	}
+	page.pix = pix
+	page.sky.grow(dx, dy)
+	page.dx, page.dy = dx, dy
+	page.dirty = true
+}
+
+// Returns the image added with key, or nil if there isn't one or it has been
+// evicted.
+func (a *Atlas) Get(key string) *AtlasImage {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	img := a.images[key]
+	if img != nil {
+		a.clock++
+		img.used = a.clock
+	}
+	return img
+}
+
+// Uploads the pages that have changed since the last call.  Pages keep being
+// drawn with their old textures until the new ones are ready, so this never
+// makes anything disappear for a frame.  Call it once a frame after adding
+// images and before drawing them.
+func (a *Atlas) Flush() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, page := range a.pages {
+		if !page.dirty {
+			continue
+		}
+		page.dirty = false
+		if page.pending != nil {
+			page.pending.Delete()
+		}
+		pix := make([]byte, len(page.pix))
+		copy(pix, page.pix)
+		// Mipmaps would blend neighboring images together.
+		page.pending = Textures().loadRGBA(pix, page.dx, page.dy, false)
+	}
+}
+
+// Returns the number of pages in a.
+func (a *Atlas) NumPages() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return len(a.pages)
+}
+
+// Deletes the textures of a.  Images that were in it are no longer valid.
+func (a *Atlas) Delete() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, page := range a.pages {
+		for _, img := range page.images {
+			img.page = nil
+		}
+		if page.texture != nil {
+			page.texture.Delete()
+		}
+		if page.pending != nil {
+			page.pending.Delete()
+		}
+	}
+	a.pages = nil
+	a.images = make(map[string]*AtlasImage)
+}
+
+// Returns false once img's page has been emptied to make room for other
+// images, after which it should be added again.
+func (img *AtlasImage) Valid() bool {
+	img.atlas.mutex.Lock()
+	defer img.atlas.mutex.Unlock()
+	return img.page != nil
+}
+
+func (img *AtlasImage) Dims() (dx, dy int) {
+	return img.dx, img.dy
+}
+
+// Returns the texture coordinates of img in its page, with v for the bottom
+// of the image and v2 for the top, the same way QuadBatch.Add() takes them.
+func (img *AtlasImage) UV() (u, v, u2, v2 float32) {
+	img.atlas.mutex.Lock()
+	defer img.atlas.mutex.Unlock()
+	if img.page == nil {
+		return
+	}
+	dx, dy := float32(img.page.dx), float32(img.page.dy)
+	u = float32(img.x) / dx
+	u2 = float32(img.x+img.dx) / dx
+	// The top of the page is at v = 0.
+	v = float32(img.y+img.dy) / dy
+	v2 = float32(img.y) / dy
+	return
+}
+
+// Returns the texture that img is drawn from, which is nil until its page has
+// been uploaded by Flush(), or if img isn't valid.
+func (img *AtlasImage) Texture() *Texture {
+	img.atlas.mutex.Lock()
+	defer img.atlas.mutex.Unlock()
+	page := img.page
+	if page == nil {
+		return nil
+	}
+	if page.pending != nil && page.pending.Ready() {
+		if page.texture != nil {
+			page.texture.Delete()
+		}
+		page.texture, page.pending = page.pending, nil
+	}
+	return page.texture
+}
+
+// A skyline packer, which keeps track of the highest point filled in each
+// column of the page as a list of flat segments, and puts each rectangle as
+// low down as it can go.
+type skyline struct {
+	dx, dy   int
+	segments []skySegment
+}
+
+type skySegment struct {
+	x, y, dx int
+}
+
+func makeSkyline(dx, dy int) skyline {
+	return skyline{dx: dx, dy: dy, segments: []skySegment{{0, 0, dx}}}
+}
+
+// Returns how low a w by h rectangle can go with its left edge at segment i.
+func (s *skyline) fit(i, w, h int) (y int, ok bool) {
+	if s.segments[i].x+w > s.dx {
+		return 0, false
+	}
+	for left := w; left > 0; i++ {
+		if s.segments[i].y > y {
+			y = s.segments[i].y
+		}
+		if y+h > s.dy {
+			return 0, false
+		}
+		left -= s.segments[i].dx
+	}
+	return y, true
+}
+
+// Finds a place for a w by h rectangle and marks it as used.
+func (s *skyline) insert(w, h int) (x, y int, ok bool) {
+	best := -1
+	var best_y, best_dx int
+	for i := range s.segments {
+		y, ok := s.fit(i, w, h)
+		if !ok {
+			continue
+		}
+		if best == -1 || y < best_y || (y == best_y && s.segments[i].dx < best_dx) {
+			best, best_y, best_dx = i, y, s.segments[i].dx
+		}
+	}
+	if best == -1 {
+		return 0, 0, false
+	}
+	x, y = s.segments[best].x, best_y
+	segments := append([]skySegment(nil), s.segments[:best]...)
+	segments = append(segments, skySegment{x, y + h, w})
+	for _, seg := range s.segments[best:] {
+		if end := x + w; seg.x < end {
+			if seg.x+seg.dx <= end {
+				continue
+			}
+			seg.dx -= end - seg.x
+			seg.x = end
+		}
+		segments = append(segments, seg)
+	}
+	// Neighbors at the same height are merged so that the list stays short.
+	s.segments = segments[:1]
+	for _, seg := range segments[1:] {
+		last := &s.segments[len(s.segments)-1]
+		if last.y == seg.y {
+			last.dx += seg.dx
+		} else {
+			s.segments = append(s.segments, seg)
+		}
+	}
+	return x, y, true
+}
+
+// Makes the skyline cover a dx by dy page, which is at least as big as the
+// one it covered before.
+func (s *skyline) grow(dx, dy int) {
+	if dx > s.dx {
+		s.segments = append(s.segments, skySegment{s.dx, 0, dx - s.dx})
+	}
+	s.dx, s.dy = dx, dy
+}
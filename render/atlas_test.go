@@ -0,0 +1,163 @@
+package render_test
+
+import (
+	"fmt"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"image"
+	"image/color"
+)
+
+// Returns dx*dy pixels of c as tightly packed RGBA.
+func solid(dx, dy int, c color.RGBA) []byte {
+	pix := make([]byte, 4*dx*dy)
+	for i := 0; i < len(pix); i += 4 {
+		pix[i], pix[i+1], pix[i+2], pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return pix
+}
+
+// Returns the rectangle that img covers in a page that is size pixels on a
+// side.
+func atlasRect(img *render.AtlasImage, size int) image.Rectangle {
+	u, v, u2, v2 := img.UV()
+	s := float32(size)
+	return image.Rect(int(u*s+0.5), int(v2*s+0.5), int(u2*s+0.5), int(v*s+0.5))
+}
+
+func AtlasSpec(c gospec.Context) {
+	red := color.RGBA{255, 0, 0, 255}
+	c.Specify("Images are packed into a page without overlapping.", func() {
+		atlas := render.MakeAtlas(256, 1)
+		var imgs []*render.AtlasImage
+		for i := 0; i < 20; i++ {
+			img, err := atlas.AddRGBA(fmt.Sprintf("%d", i), solid(30, 20, red), 30, 20)
+			c.Assume(err, IsNil)
+			imgs = append(imgs, img)
+		}
+		c.Expect(atlas.NumPages(), Equals, 1)
+		page := image.Rect(0, 0, 256, 256)
+		for i, img := range imgs {
+			dx, dy := img.Dims()
+			c.Expect(dx, Equals, 30)
+			c.Expect(dy, Equals, 20)
+			r := atlasRect(img, 256)
+			c.Expect(r.Dx(), Equals, 30)
+			c.Expect(r.Dy(), Equals, 20)
+			c.Expect(r.In(page), Equals, true)
+			for _, other := range imgs[i+1:] {
+				c.Expect(r.Overlaps(atlasRect(other, 256)), Equals, false)
+			}
+		}
+	})
+	c.Specify("Adding a key again returns the image already there.", func() {
+		atlas := render.MakeAtlas(256, 1)
+		img, err := atlas.AddRGBA("a", solid(4, 4, red), 4, 4)
+		c.Assume(err, IsNil)
+		again, err := atlas.AddRGBA("a", solid(8, 8, red), 8, 8)
+		c.Assume(err, IsNil)
+		c.Expect(again, Equals, img)
+		c.Expect(atlas.Get("a"), Equals, img)
+		c.Expect(atlas.Get("b"), IsNil)
+	})
+	c.Specify("Images bigger than a page can't be added.", func() {
+		atlas := render.MakeAtlas(64, 4)
+		_, err := atlas.AddRGBA("big", solid(64, 10, red), 64, 10)
+		c.Expect(err, Not(IsNil))
+		c.Expect(atlas.NumPages(), Equals, 0)
+	})
+	c.Specify("A full page doubles in size and keeps its images.", func() {
+		atlas := render.MakeAtlas(512, 1)
+		var imgs []*render.AtlasImage
+		for _, key := range []string{"a", "b", "c", "d"} {
+			img, err := atlas.AddRGBA(key, solid(120, 120, red), 120, 120)
+			c.Assume(err, IsNil)
+			imgs = append(imgs, img)
+		}
+		var before []image.Rectangle
+		for _, img := range imgs {
+			before = append(before, atlasRect(img, 256))
+		}
+		e, err := atlas.AddRGBA("e", solid(120, 120, red), 120, 120)
+		c.Assume(err, IsNil)
+		c.Expect(atlas.NumPages(), Equals, 1)
+		for i, img := range imgs {
+			c.Expect(img.Valid(), Equals, true)
+			c.Expect(atlasRect(img, 512), Equals, before[i])
+			c.Expect(atlasRect(e, 512).Overlaps(before[i]), Equals, false)
+		}
+	})
+	c.Specify("Once pages can't grow any more, new pages are added.", func() {
+		atlas := render.MakeAtlas(256, 2)
+		for i := 0; i < 5; i++ {
+			_, err := atlas.AddRGBA(fmt.Sprintf("%d", i), solid(120, 120, red), 120, 120)
+			c.Assume(err, IsNil)
+		}
+		c.Expect(atlas.NumPages(), Equals, 2)
+	})
+	c.Specify("When every page is full the least recently used one is emptied and reused.", func() {
+		atlas := render.MakeAtlas(256, 2)
+		imgs := make(map[string]*render.AtlasImage)
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			img, err := atlas.AddRGBA(key, solid(120, 120, red), 120, 120)
+			c.Assume(err, IsNil)
+			imgs[key] = img
+		}
+		c.Assume(atlas.NumPages(), Equals, 2)
+		e_rect := atlasRect(imgs["e"], 256)
+		// a is on the first page, so now the second page was used longest ago.
+		c.Assume(atlas.Get("a"), Equals, imgs["a"])
+		i, err := atlas.AddRGBA("i", solid(120, 120, red), 120, 120)
+		c.Assume(err, IsNil)
+		c.Expect(atlas.NumPages(), Equals, 2)
+		c.Expect(i.Valid(), Equals, true)
+		for _, key := range []string{"a", "b", "c", "d"} {
+			c.Expect(imgs[key].Valid(), Equals, true)
+		}
+		for _, key := range []string{"e", "f", "g", "h"} {
+			c.Expect(imgs[key].Valid(), Equals, false)
+			c.Expect(imgs[key].Texture(), IsNil)
+			c.Expect(atlas.Get(key), IsNil)
+		}
+		c.Expect(atlasRect(i, 256), Equals, e_rect)
+
+		e, err := atlas.AddRGBA("e", solid(120, 120, red), 120, 120)
+		c.Assume(err, IsNil)
+		c.Expect(e, Not(Equals), imgs["e"])
+		c.Expect(e.Valid(), Equals, true)
+		c.Expect(atlas.NumPages(), Equals, 2)
+	})
+	c.Specify("Images can be drawn from their page once it is flushed.", func() {
+		atlas := render.MakeAtlas(256, 1)
+		defer atlas.Delete()
+		blue := color.RGBA{0, 0, 255, 255}
+		_, err := atlas.AddRGBA("red", solid(4, 4, red), 4, 4)
+		c.Assume(err, IsNil)
+		img, err := atlas.AddRGBA("blue", solid(4, 4, blue), 4, 4)
+		c.Assume(err, IsNil)
+		c.Expect(img.Texture(), IsNil)
+		atlas.Flush()
+		render.Sync()
+		texture := img.Texture()
+		c.Assume(texture, Not(IsNil))
+		dx, dy := texture.Dims()
+		c.Expect(dx, Equals, 256)
+		c.Expect(dy, Equals, 256)
+
+		target := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		render.Queue(func() {
+			render.SetSoftwareTarget(target)
+			var qb render.QuadBatch
+			u, v, u2, v2 := img.UV()
+			qb.Add(0, 0, 4, 4, u, v, u2, v2, [4]float32{1, 1, 1, 1})
+			qb.Draw(texture)
+		})
+		render.Sync()
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				c.Expect(target.RGBAAt(x, y), Equals, blue)
+			}
+		}
+	})
+}
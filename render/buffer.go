@@ -0,0 +1,134 @@
+package render
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"unsafe"
+)
+
+// A VertexAttrib is one of the attributes of each vertex in a VertexBuffer.
+type VertexAttrib struct {
+	// Name of the attribute in the shader.
+	Name string
+
+	// Number of floats in the attribute, 1 through 4.
+	Size int32
+}
+
+// A VertexBuffer holds interleaved float vertex data for a particular shader.
+// On the GL33 pipeline the attribute layout is recorded in a vertex array
+// object, on GL21 it is set up each time the buffer is drawn.  All methods
+// must be called on the render thread.
+type VertexBuffer struct {
+	shader    string
+	attribs   []VertexAttrib
+	locations []uint32
+	stride    int32
+
+	vao, vbo uint32
+
+	// number of vertices in the buffer
+	count int32
+}
+
+// MakeVertexBuffer returns an empty VertexBuffer whose vertices are made up of
+// attribs, in order, for use with the shader registered as shader.
+func MakeVertexBuffer(shader string, attribs ...VertexAttrib) (*VertexBuffer, error) {
+	MustRunOnRenderThread()
+	vb := &VertexBuffer{shader: shader, attribs: attribs}
+	for _, attrib := range attribs {
+		if attrib.Size < 1 || attrib.Size > 4 {
+			return nil, fmt.Errorf("Vertex attribute '%s' has size %d, it must be between 1 and 4.", attrib.Name, attrib.Size)
+		}
+		location, err := GetAttribLocation(shader, attrib.Name)
+		if err != nil {
+			return nil, err
+		}
+		if location < 0 {
+			return nil, fmt.Errorf("Shader '%s' has no active attribute '%s'.", shader, attrib.Name)
+		}
+		vb.locations = append(vb.locations, uint32(location))
+		vb.stride += attrib.Size * 4
+	}
+	if !HasGl() {
+		return vb, nil
+	}
+	gl.GenBuffers(1, &vb.vbo)
+	if pipeline == GL33 {
+		gl.GenVertexArrays(1, &vb.vao)
+		gl.BindVertexArray(vb.vao)
+		vb.bindAttribs()
+		gl.BindVertexArray(0)
+	}
+	return vb, nil
+}
+
+func (vb *VertexBuffer) bindAttribs() {
+	gl.BindBuffer(gl.ARRAY_BUFFER, vb.vbo)
+	offset := 0
+	for i, attrib := range vb.attribs {
+		gl.EnableVertexAttribArray(vb.locations[i])
+		gl.VertexAttribPointer(vb.locations[i], attrib.Size, gl.FLOAT, false, vb.stride, gl.PtrOffset(offset))
+		offset += int(attrib.Size) * 4
+	}
+}
+
+// SetData replaces the contents of the buffer with data, which must be a whole
+// number of vertices.
+func (vb *VertexBuffer) SetData(data []float32) {
+	MustRunOnRenderThread()
+	floats := int(vb.stride / 4)
+	if floats == 0 || len(data)%floats != 0 {
+		panic(fmt.Sprintf("VertexBuffer.SetData() given %d floats, which is not a multiple of the %d floats per vertex.", len(data), floats))
+	}
+	vb.count = int32(len(data) / floats)
+	if !HasGl() {
+		return
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, vb.vbo)
+	if len(data) == 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, 0, nil, gl.DYNAMIC_DRAW)
+		return
+	}
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*int(unsafe.Sizeof(data[0])), gl.Ptr(&data[0]), gl.DYNAMIC_DRAW)
+}
+
+// Len returns the number of vertices in the buffer.
+func (vb *VertexBuffer) Len() int {
+	return int(vb.count)
+}
+
+// Draw draws every vertex in the buffer as primitives of type mode, like
+// gl.TRIANGLES, using the buffer's shader.  Uniforms must already be set.
+func (vb *VertexBuffer) Draw(mode uint32) error {
+	MustRunOnRenderThread()
+	if err := EnableShader(vb.shader); err != nil {
+		return err
+	}
+	if !HasGl() {
+		return nil
+	}
+	if vb.vao != 0 {
+		gl.BindVertexArray(vb.vao)
+		gl.DrawArrays(mode, 0, vb.count)
+		gl.BindVertexArray(0)
+		return nil
+	}
+	vb.bindAttribs()
+	gl.DrawArrays(mode, 0, vb.count)
+	return nil
+}
+
+// Delete frees the gl objects used by the buffer, it must not be used again
+// afterwards.
+func (vb *VertexBuffer) Delete() {
+	MustRunOnRenderThread()
+	if !HasGl() {
+		return
+	}
+	gl.DeleteBuffers(1, &vb.vbo)
+	if vb.vao != 0 {
+		gl.DeleteVertexArrays(1, &vb.vao)
+	}
+	vb.vbo, vb.vao = 0, 0
+}
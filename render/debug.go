@@ -0,0 +1,112 @@
+package render
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/runningwild/glop/glog"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// A Logger receives the messages logged in debug mode.  *log.Logger satisfies
+// this interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+var (
+	debug_mutex sync.Mutex
+	debug_mode  bool
+	trace_mode  bool
+	logger      Logger = glog.At(glog.Warning)
+)
+
+// SetDebug turns debug mode on or off.  In debug mode every function passed to
+// Queue() is checked with glGetError() after it runs, and any errors are
+// logged along with the file and line that queued it.  This is slow, so it
+// should only be turned on while tracking down a problem.
+func SetDebug(enable bool) {
+	debug_mutex.Lock()
+	defer debug_mutex.Unlock()
+	debug_mode = enable
+}
+
+// SetTrace turns trace mode on or off.  In trace mode, which only has an
+// effect in debug mode, the file and line that queued each function is logged
+// just before that function runs.
+func SetTrace(enable bool) {
+	debug_mutex.Lock()
+	defer debug_mutex.Unlock()
+	trace_mode = enable
+}
+
+// SetLogger sets the Logger used by debug mode, which logs warnings to glog by
+// default.  A nil Logger discards everything.
+func SetLogger(l Logger) {
+	debug_mutex.Lock()
+	defer debug_mutex.Unlock()
+	logger = l
+}
+
+func debugSettings() (debug, trace bool, l Logger) {
+	debug_mutex.Lock()
+	defer debug_mutex.Unlock()
+	return debug_mode, trace_mode, logger
+}
+
+// Returns f wrapped with error checking, and tracing if it's on, if debug mode
+// is on, otherwise returns f.  skip is the number of stack frames between
+// the caller of debugWrap and the code that queued f.
+func debugWrap(f func(), skip int) func() {
+	debug, trace, l := debugSettings()
+	if !debug || l == nil {
+		return f
+	}
+	where := "unknown location"
+	if _, file, line, ok := runtime.Caller(skip + 1); ok {
+		where = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	return func() {
+		// Errors left over from code that wasn't queued, or that ran before debug
+		// mode was turned on, would otherwise be blamed on f.
+		logGlErrors(l, "before function queued at "+where)
+		if trace {
+			l.Printf("Running function queued at %s", where)
+		}
+		f()
+		logGlErrors(l, "in function queued at "+where)
+	}
+}
+
+func logGlErrors(l Logger, context string) {
+	// glGetError() only returns one error at a time, but there can be several
+	// pending.  The limit keeps us from spinning forever without a context,
+	// where some drivers always return an error.
+	if !HasGl() {
+		return
+	}
+	for i := 0; i < 10; i++ {
+		code := gl.GetError()
+		if code == gl.NO_ERROR {
+			return
+		}
+		l.Printf("GL error %s %s", glErrorName(code), context)
+	}
+}
+
+func glErrorName(code uint32) string {
+	switch code {
+	case gl.INVALID_ENUM:
+		return "GL_INVALID_ENUM"
+	case gl.INVALID_VALUE:
+		return "GL_INVALID_VALUE"
+	case gl.INVALID_OPERATION:
+		return "GL_INVALID_OPERATION"
+	case gl.OUT_OF_MEMORY:
+		return "GL_OUT_OF_MEMORY"
+	case gl.INVALID_FRAMEBUFFER_OPERATION:
+		return "GL_INVALID_FRAMEBUFFER_OPERATION"
+	}
+	return fmt.Sprintf("0x%x", code)
+}
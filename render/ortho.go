@@ -0,0 +1,198 @@
+package render
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// The shader used by QuadBatch, it is registered the first time a QuadBatch is
+// drawn.
+const ortho_shader = "glop.ortho2d"
+
+const ortho_vshader_330 = `#version 330
+uniform mat4 projection;
+in vec2 position;
+in vec2 texCoord;
+in vec4 color;
+out vec2 theTexCoord;
+out vec4 theColor;
+void main() {
+  gl_Position = projection * vec4(position, 0, 1);
+  theTexCoord = texCoord;
+  theColor = color;
+}
+`
+
+const ortho_fshader_330 = `#version 330
+uniform sampler2D tex;
+uniform int useTexture;
+in vec2 theTexCoord;
+in vec4 theColor;
+out vec4 fragColor;
+void main() {
+  fragColor = theColor;
+  if (useTexture != 0) {
+    fragColor *= texture(tex, theTexCoord);
+  }
+}
+`
+
+const ortho_vshader_120 = `#version 120
+uniform mat4 projection;
+attribute vec2 position;
+attribute vec2 texCoord;
+attribute vec4 color;
+varying vec2 theTexCoord;
+varying vec4 theColor;
+void main() {
+  gl_Position = projection * vec4(position, 0, 1);
+  theTexCoord = texCoord;
+  theColor = color;
+}
+`
+
+const ortho_fshader_120 = `#version 120
+uniform sampler2D tex;
+uniform int useTexture;
+varying vec2 theTexCoord;
+varying vec4 theColor;
+void main() {
+  gl_FragColor = theColor;
+  if (useTexture != 0) {
+    gl_FragColor *= texture2D(tex, theTexCoord);
+  }
+}
+`
+
+func registerOrthoShader() error {
+	if IsShaderRegistered(ortho_shader) {
+		return nil
+	}
+	if pipeline == GL33 {
+		return RegisterShader(ortho_shader, []byte(ortho_vshader_330), []byte(ortho_fshader_330))
+	}
+	return RegisterShader(ortho_shader, []byte(ortho_vshader_120), []byte(ortho_fshader_120))
+}
+
+// Ortho returns a column-major orthographic projection matrix that maps the
+// given rectangle onto the whole viewport.
+func Ortho(left, right, bottom, top float32) [16]float32 {
+	return [16]float32{
+		2 / (right - left), 0, 0, 0,
+		0, 2 / (top - bottom), 0, 0,
+		0, 0, -1, 0,
+		-(right + left) / (right - left), -(top + bottom) / (top - bottom), 0, 1,
+	}
+}
+
+// A QuadBatch collects textured, colored quads in screen coordinates and draws
+// them all at once with a single draw call.  Screen coordinates have their
+// origin at the bottom left of the viewport, just like text.RenderString().
+// The zero value is an empty batch that is ready to use.
+type QuadBatch struct {
+	verts  []float32
+	buffer *VertexBuffer
+}
+
+// Add adds the quad with corners (x, y) and (x2, y2), texture coordinates (u,
+// v) and (u2, v2), and color c, which is RGBA and multiplied with the texture.
+// Add may be called from any goroutine, but not concurrently with Draw.
+func (qb *QuadBatch) Add(x, y, x2, y2, u, v, u2, v2 float32, c [4]float32) {
+	corner := func(x, y, u, v float32) {
+		qb.verts = append(qb.verts, x, y, u, v, c[0], c[1], c[2], c[3])
+	}
+	corner(x, y, u, v)
+	corner(x2, y, u2, v)
+	corner(x2, y2, u2, v2)
+	corner(x, y, u, v)
+	corner(x2, y2, u2, v2)
+	corner(x, y2, u, v2)
+}
+
+// Len returns the number of quads waiting to be drawn.
+func (qb *QuadBatch) Len() int {
+	return len(qb.verts) / (6 * 8)
+}
+
+// Draw draws all of the quads in the batch with texture, which may be nil to
+// draw untextured quads, and then empties the batch.  Must be called on the
+// render thread.
+func (qb *QuadBatch) Draw(texture *Texture) error {
+	MustRunOnRenderThread()
+	if pipeline == Software {
+		dx, dy := software_target.dims()
+		return qb.DrawWithProjection(texture, Ortho(0, float32(dx), 0, float32(dy)))
+	}
+	if !HasGl() {
+		qb.verts = qb.verts[0:0]
+		return nil
+	}
+	var viewport [4]int32
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+	return qb.DrawWithProjection(texture, Ortho(0, float32(viewport[2]), 0, float32(viewport[3])))
+}
+
+// DrawWithProjection is like Draw, but the quads are transformed by
+// projection, a column-major matrix, instead of being in screen coordinates.
+// This lets quads be added in world coordinates and drawn with something like
+// camera.Camera.Matrix().  Must be called on the render thread.
+func (qb *QuadBatch) DrawWithProjection(texture *Texture, projection [16]float32) error {
+	MustRunOnRenderThread()
+	if pipeline == Software {
+		drawSoftware(qb.verts, texture, projection)
+		qb.verts = qb.verts[0:0]
+		return nil
+	}
+	if !HasGl() {
+		qb.verts = qb.verts[0:0]
+		return nil
+	}
+	if err := registerOrthoShader(); err != nil {
+		return err
+	}
+	if qb.buffer == nil {
+		buffer, err := MakeVertexBuffer(
+			ortho_shader,
+			VertexAttrib{Name: "position", Size: 2},
+			VertexAttrib{Name: "texCoord", Size: 2},
+			VertexAttrib{Name: "color", Size: 4})
+		if err != nil {
+			return err
+		}
+		qb.buffer = buffer
+	}
+	if len(qb.verts) == 0 {
+		return nil
+	}
+	qb.buffer.SetData(qb.verts)
+	qb.verts = qb.verts[0:0]
+
+	if err := EnableShader(ortho_shader); err != nil {
+		return err
+	}
+	defer EnableShader("")
+	location, _ := GetUniformLocation(ortho_shader, "projection")
+	gl.UniformMatrix4fv(location, 1, false, &projection[0])
+	location, _ = GetUniformLocation(ortho_shader, "useTexture")
+	if texture != nil {
+		gl.ActiveTexture(gl.TEXTURE0)
+		texture.Bind()
+		gl.Uniform1i(location, 1)
+	} else {
+		gl.Uniform1i(location, 0)
+	}
+	location, _ = GetUniformLocation(ortho_shader, "tex")
+	gl.Uniform1i(location, 0)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	return qb.buffer.Draw(gl.TRIANGLES)
+}
+
+// Delete frees the gl objects used by the batch.  Must be called on the render
+// thread.
+func (qb *QuadBatch) Delete() {
+	if qb.buffer != nil {
+		qb.buffer.Delete()
+		qb.buffer = nil
+	}
+	qb.verts = nil
+}
@@ -0,0 +1,56 @@
+package render
+
+// A Pipeline is the version of OpenGL that render helpers target.
+type Pipeline int
+
+const (
+	// GL21 targets OpenGL 2.1 and GLSL 1.20, which works almost everywhere but
+	// doesn't have vertex array objects.
+	GL21 Pipeline = iota
+
+	// GL33 targets an OpenGL 3.3 core profile and GLSL 3.30.
+	GL33
+
+	// Null makes no gl calls at all, for running without a GL context, like
+	// on a server or in CI, see gos/headless.  Textures become ready without
+	// being uploaded anywhere, shaders always register, and drawing does
+	// nothing.
+	Null
+
+	// Software makes no gl calls either, but QuadBatches are drawn by a
+	// rasterizer written in Go into the image given to SetSoftwareTarget(),
+	// or into a bound RenderTarget.  It is slow, but it gives the same pixels
+	// on every machine, so it is good for golden image tests, and it works
+	// where there is no OpenGL 2.1.  Other drawing, like VertexBuffers with
+	// custom shaders and text, does nothing.
+	Software
+)
+
+// The pipeline passed to Init(), GL21 until then.
+var pipeline Pipeline
+
+// CurrentPipeline returns the pipeline that was passed to Init().
+func CurrentPipeline() Pipeline {
+	return pipeline
+}
+
+// HasGl returns false if the current pipeline has no GL context to make gl
+// calls to.  Code outside of render that makes its own gl calls should skip
+// them when this is false.
+func HasGl() bool {
+	return pipeline != Null && pipeline != Software
+}
+
+func (p Pipeline) String() string {
+	switch p {
+	case GL21:
+		return "GL21"
+	case GL33:
+		return "GL33"
+	case Null:
+		return "Null"
+	case Software:
+		return "Software"
+	}
+	return "Unknown pipeline"
+}
@@ -1,45 +1,214 @@
+// Package render owns the thread that all OpenGL calls must be made from.
+//
+// OpenGL contexts are bound to a single OS thread, so once Init() has been
+// called every gl call must be made from a function passed to Queue().  Queued
+// functions are run in the order that they were queued, one at a time, on a
+// goroutine that is locked to its OS thread.
+//
+// The rules that keep this from deadlocking are:
+//   - Queue() never blocks the render thread.  If the queue is full when the
+//     render thread calls Queue() the function is run immediately, rather than
+//     waiting for room behind work that can't run until the caller returns.
+//   - Purge() and Sync() wait for the render thread, so they panic if they are
+//     called from it.
+//   - Functions that make gl calls should call MustRunOnRenderThread() so that
+//     mistakes are caught immediately rather than showing up as a crash in the
+//     driver.
+//
+// A game loop typically looks like this:
+//
+//	for {
+//	  render.StartFrame()  // waits if the render thread is too far behind
+//	  ... game logic, render.Queue() drawing ...
+//	  render.Queue(sys.SwapBuffers)
+//	  render.EndFrame()
+//	}
 package render
 
 import (
+	"bytes"
 	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 var (
 	render_funcs chan func()
 	purge        chan bool
 	init_once    sync.Once
+
+	// id of the goroutine that runs queued functions, or 0 before Init(), read
+	// and written with sync/atomic
+	render_goroutine int64
+
+	// frame fencing state, see StartFrame() and EndFrame()
+	frame_mutex          sync.Mutex
+	frame_cond           *sync.Cond
+	frames_started       int64
+	frames_ended         int64
+	frames_completed     int64
+	max_frames_in_flight int64
 )
 
 func init() {
 	render_funcs = make(chan func(), 1000)
 	purge = make(chan bool)
+	frame_cond = sync.NewCond(&frame_mutex)
+	max_frames_in_flight = 2
 }
 
-// Queues a function to run on the render thread
+// Queue queues f to be run on the render thread.  If the queue is full this
+// blocks until there is room, unless it is called from the render thread
+// itself, in which case f is run immediately.  See SetDebug() for help
+// tracking down gl errors in queued functions.
 func Queue(f func()) {
+	f = debugWrap(f, 1)
+	select {
+	case render_funcs <- f:
+		return
+	default:
+	}
+	if OnRenderThread() {
+		f()
+		return
+	}
 	render_funcs <- f
 }
 
-// Waits until all render thread functions have been run
+// Purge waits until all render thread functions have been run, including any
+// that are queued while it is waiting.  It panics if called from the render
+// thread.
 func Purge() {
+	mustNotRunOnRenderThread("Purge")
 	purge <- true
 	<-purge
 }
 
-func Init() {
+// Sync waits until every function that was queued before Sync was called has
+// been run.  Unlike Purge(), functions queued by other goroutines after Sync
+// was called are not waited on.  It panics if called from the render thread.
+func Sync() {
+	mustNotRunOnRenderThread("Sync")
+	done := make(chan bool)
+	render_funcs <- func() { close(done) }
+	<-done
+}
+
+// OnRenderThread returns true iff it is called from the render thread.
+func OnRenderThread() bool {
+	id := atomic.LoadInt64(&render_goroutine)
+	return id != 0 && goroutineId() == id
+}
+
+// MustRunOnRenderThread panics if it is not called from the render thread.
+// Anything that makes gl calls should start with this.
+func MustRunOnRenderThread() {
+	if !OnRenderThread() {
+		panic("This function must be called from the render thread, use render.Queue().")
+	}
+}
+
+func mustNotRunOnRenderThread(name string) {
+	if OnRenderThread() {
+		panic("render." + name + "() would deadlock if called from the render thread.")
+	}
+}
+
+// SetMaxFramesInFlight sets how many frames may have been ended with
+// EndFrame() without all of their queued work having been run before
+// StartFrame() blocks.  The default is 2.  Values less than 1 are treated as 1.
+func SetMaxFramesInFlight(n int) {
+	if n < 1 {
+		n = 1
+	}
+	frame_mutex.Lock()
+	max_frames_in_flight = int64(n)
+	frame_mutex.Unlock()
+	frame_cond.Broadcast()
+}
+
+// StartFrame marks the start of a new frame.  If too many earlier frames still
+// have work waiting on the render thread it blocks until enough of them have
+// finished, which keeps game logic from getting arbitrarily far ahead of
+// rendering.  Returns the number of the new frame, starting at 1.  It panics if
+// called from the render thread.
+func StartFrame() int64 {
+	mustNotRunOnRenderThread("StartFrame")
+	frame_mutex.Lock()
+	for frames_ended-frames_completed >= max_frames_in_flight {
+		frame_cond.Wait()
+	}
+	frames_started++
+	frame := frames_started
+	frame_mutex.Unlock()
+	if gpuTiming() {
+		Queue(func() { beginGpuQuery(frame) })
+	}
+	return frame
+}
+
+// EndFrame marks the end of the frame started by the last call to
+// StartFrame().  It does not block, it queues a fence that marks the frame as
+// completed once everything queued before it has been run.
+func EndFrame() {
+	frame_mutex.Lock()
+	if frames_ended >= frames_started {
+		frame_mutex.Unlock()
+		panic("render.EndFrame() called without a matching call to render.StartFrame().")
+	}
+	frames_ended++
+	frame := frames_ended
+	frame_mutex.Unlock()
+	Queue(func() {
+		completeFrame(frame)
+		frame_mutex.Lock()
+		frames_completed = frame
+		frame_mutex.Unlock()
+		frame_cond.Broadcast()
+	})
+}
+
+// CompletedFrame returns the number of the most recent frame whose queued work
+// has all been run, or 0 if no frame has been completed.
+func CompletedFrame() int64 {
+	frame_mutex.Lock()
+	defer frame_mutex.Unlock()
+	return frames_completed
+}
+
+// Returns the id of the current goroutine.  Go deliberately doesn't expose
+// this, but the first line of a stack trace is always "goroutine <id> [...".
+func goroutineId() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	b = b[:bytes.IndexByte(b, ' ')]
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// Init starts the render thread and selects the pipeline that helpers like
+// VertexBuffer and QuadBatch will target, which must match the version of the
+// OpenGL context that the system creates.  Functions queued before Init is
+// called are run once it starts.  Calling Init more than once has no effect.
+func Init(p Pipeline) {
 	init_once.Do(func() {
+		pipeline = p
+		started := make(chan bool)
 		go func() {
 			runtime.LockOSThread()
+			atomic.StoreInt64(&render_goroutine, goroutineId())
+			close(started)
 			for {
 				select {
 				case f := <-render_funcs:
-					f()
+					runTimed(f)
 				case <-purge:
 					for {
 						select {
 						case f := <-render_funcs:
-							f()
+							runTimed(f)
 						default:
 							goto purged
 						}
@@ -49,5 +218,6 @@ func Init() {
 				}
 			}
 		}()
+		<-started
 	})
 }
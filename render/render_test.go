@@ -0,0 +1,41 @@
+package render_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+)
+
+func QueueSpec(c gospec.Context) {
+	c.Specify("Only queued functions are on the render thread.", func() {
+		on := make(chan bool, 1)
+		render.Queue(func() { on <- render.OnRenderThread() })
+		c.Expect(<-on, Equals, true)
+		c.Expect(render.OnRenderThread(), Equals, false)
+	})
+	c.Specify("Functions queued from the render thread run after what was already queued.", func() {
+		var order []string
+		gate := make(chan bool)
+		done := make(chan bool)
+		render.Queue(func() {
+			<-gate
+			render.Queue(func() {
+				order = append(order, "inner")
+				close(done)
+			})
+			order = append(order, "outer")
+		})
+		render.Queue(func() { order = append(order, "next") })
+		close(gate)
+		<-done
+		c.Expect(order, ContainsInOrder, []string{"outer", "next", "inner"})
+	})
+	c.Specify("Sync waits for everything queued before it.", func() {
+		n := 0
+		for i := 0; i < 100; i++ {
+			render.Queue(func() { n++ })
+		}
+		render.Sync()
+		c.Expect(n, Equals, 100)
+	})
+}
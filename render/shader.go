@@ -3,6 +3,7 @@ package render
 import (
 	"fmt"
 	"github.com/go-gl/gl/v3.3-core/gl"
+	"io/ioutil"
 	"unsafe"
 )
 
@@ -14,14 +15,18 @@ func init() {
 
 func EnableShader(name string) error {
 	if name == "" {
-		gl.UseProgram(0)
+		if HasGl() {
+			gl.UseProgram(0)
+		}
 		return nil
 	}
 	prog_obj, ok := shader_progs[name]
 	if !ok {
 		return fmt.Errorf("Tried to use unknown shader '%s'", name)
 	}
-	gl.UseProgram(prog_obj)
+	if HasGl() {
+		gl.UseProgram(prog_obj)
+	}
 	return nil
 }
 
@@ -30,6 +35,9 @@ func SetUniformI(shader, variable string, n int32) error {
 	if !ok {
 		return fmt.Errorf("Tried to set a uniform in an unknown shader '%s'", shader)
 	}
+	if !HasGl() {
+		return nil
+	}
 	bvariable := []byte(fmt.Sprintf("%s\x00", variable))
 	loc := gl.GetUniformLocation(prog, (*uint8)(unsafe.Pointer(&bvariable[0])))
 	gl.Uniform1i(loc, n)
@@ -41,6 +49,9 @@ func SetUniformF(shader, variable string, f float32) error {
 	if !ok {
 		return fmt.Errorf("Tried to set a uniform in an unknown shader '%s'", shader)
 	}
+	if !HasGl() {
+		return nil
+	}
 	bvariable := []byte(fmt.Sprintf("%s\x00", variable))
 	loc := gl.GetUniformLocation(prog, (*uint8)(unsafe.Pointer(&bvariable[0])))
 	gl.Uniform1f(loc, f)
@@ -52,16 +63,31 @@ func SetUniform4F(shader, variable string, vs []float32) error {
 	if !ok {
 		return fmt.Errorf("Tried to set a uniform in an unknown shader '%s'", shader)
 	}
+	if !HasGl() {
+		return nil
+	}
 	bvariable := []byte(fmt.Sprintf("%s\x00", variable))
 	loc := gl.GetUniformLocation(prog, (*uint8)(unsafe.Pointer(&bvariable[0])))
 	gl.Uniform4f(loc, vs[0], vs[1], vs[2], vs[3])
 	return nil
 }
 
+// RegisterShader compiles and links vertex and fragment, which are GLSL
+// source, into a shader program that can be enabled with EnableShader(name).
+// Compile and link errors are returned along with the driver's info log.  Must
+// be called on the render thread.
 func RegisterShader(name string, vertex, fragment []byte) error {
+	MustRunOnRenderThread()
 	if _, ok := shader_progs[name]; ok {
 		return fmt.Errorf("Tried to register a shader called '%s' twice", name)
 	}
+	if len(vertex) == 0 || len(fragment) == 0 {
+		return fmt.Errorf("Tried to register a shader called '%s' with empty source", name)
+	}
+	if !HasGl() {
+		shader_progs[name] = 0
+		return nil
+	}
 
 	vertex_id := gl.CreateShader(gl.VERTEX_SHADER)
 	pointer := &vertex[0]
@@ -105,18 +131,49 @@ func RegisterShader(name string, vertex, fragment []byte) error {
 	gl.LinkProgram(program_id)
 	gl.GetProgramiv(program_id, gl.LINK_STATUS, &param)
 	if param == 0 {
-		return fmt.Errorf("Failed to link shader '%s': %v", name, param)
+		buf := make([]byte, 5*1024)
+		var length int32
+		gl.GetProgramInfoLog(program_id, int32(len(buf)), &length, (*uint8)(unsafe.Pointer(&buf[0])))
+		if length > 0 {
+			length--
+		}
+		return fmt.Errorf("Failed to link shader %q: %q", name, buf[0:int(length)])
 	}
 
 	shader_progs[name] = program_id
 	return nil
 }
 
+// LoadShader reads GLSL source from vertex_path and fragment_path and
+// registers it with RegisterShader(name, ...).  Must be called on the render
+// thread.
+func LoadShader(name, vertex_path, fragment_path string) error {
+	vertex, err := ioutil.ReadFile(vertex_path)
+	if err != nil {
+		return err
+	}
+	fragment, err := ioutil.ReadFile(fragment_path)
+	if err != nil {
+		return err
+	}
+	return RegisterShader(name, vertex, fragment)
+}
+
+// IsShaderRegistered returns true iff a shader called name has been
+// registered.
+func IsShaderRegistered(name string) bool {
+	_, ok := shader_progs[name]
+	return ok
+}
+
 func GetAttribLocation(shaderName, attribName string) (int32, error) {
 	prog, ok := shader_progs[shaderName]
 	if !ok {
 		return -1, fmt.Errorf("No shader named '%s'", shaderName)
 	}
+	if !HasGl() {
+		return 0, nil
+	}
 	return gl.GetAttribLocation(prog, gl.Str(fmt.Sprintf("%s\x00", attribName))), nil
 }
 
@@ -125,5 +182,8 @@ func GetUniformLocation(shaderName, uniformName string) (int32, error) {
 	if !ok {
 		return -1, fmt.Errorf("No shader named '%s'", shaderName)
 	}
+	if !HasGl() {
+		return 0, nil
+	}
 	return gl.GetUniformLocation(prog, gl.Str(fmt.Sprintf("%s\x00", uniformName))), nil
 }
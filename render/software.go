@@ -0,0 +1,204 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// Where the Software pipeline draws.  Rows of im go from the top down, like
+// any other image, unless up is set, in which case row 0 is the bottom row
+// like it is in a gl framebuffer.  Render targets are drawn into that way so
+// that their textures have the same orientation as they would with gl.
+type softwareTarget struct {
+	im *image.RGBA
+	up bool
+}
+
+// The target that the Software pipeline is drawing into, only touched on the
+// render thread.
+var software_target softwareTarget
+
+// SetSoftwareTarget makes the Software pipeline draw into im, which is also
+// the viewport used by QuadBatch.Draw().  Pixels are blended into im the same
+// way gl blends them, so they aren't premultiplied the way that image.RGBA
+// usually is, which only matters where im isn't opaque.  Read im after
+// Sync() to see everything drawn before.  Must be called on the render
+// thread.
+func SetSoftwareTarget(im *image.RGBA) {
+	MustRunOnRenderThread()
+	software_target = softwareTarget{im: im}
+}
+
+// SoftwareTarget returns the image passed to SetSoftwareTarget().
+func SoftwareTarget() *image.RGBA {
+	return software_target.im
+}
+
+func (st softwareTarget) dims() (dx, dy int) {
+	if st.im == nil {
+		return 0, 0
+	}
+	return st.im.Rect.Dx(), st.im.Rect.Dy()
+}
+
+// Blends c into the pixel (x, y), with y going up from the bottom of the
+// target, the same as glBlendFunc(GL_SRC_ALPHA, GL_ONE_MINUS_SRC_ALPHA).
+func (st softwareTarget) blend(x, y int, c [4]float32) {
+	if !st.up {
+		y = st.im.Rect.Dy() - 1 - y
+	}
+	p := st.im.Pix[st.im.PixOffset(st.im.Rect.Min.X+x, st.im.Rect.Min.Y+y):]
+	a := clamp01(c[3])
+	for k := 0; k < 4; k++ {
+		dst := float32(p[k]) / 255
+		p[k] = uint8((clamp01(c[k])*a+dst*(1-a))*255 + 0.5)
+	}
+}
+
+func clamp01(f float32) float32 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// A vertex in target pixels, with the rest of its attributes in the order
+// that QuadBatch lays them out: u, v, r, g, b, a.
+type softwareVertex struct {
+	x, y float32
+	attr [6]float32
+}
+
+// Draws verts, which are laid out the way QuadBatch lays them out, as
+// triangles into the software target.
+func drawSoftware(verts []float32, texture *Texture, projection [16]float32) {
+	target := software_target
+	if target.im == nil {
+		return
+	}
+	dx, dy := target.dims()
+	p := projection
+	var tri [3]softwareVertex
+	for i := 0; i+3*8 <= len(verts); i += 3 * 8 {
+		for k := range tri {
+			v := verts[i+8*k : i+8*(k+1)]
+			x := p[0]*v[0] + p[4]*v[1] + p[12]
+			y := p[1]*v[0] + p[5]*v[1] + p[13]
+			w := p[3]*v[0] + p[7]*v[1] + p[15]
+			tri[k].x = (x/w + 1) / 2 * float32(dx)
+			tri[k].y = (y/w + 1) / 2 * float32(dy)
+			copy(tri[k].attr[:], v[2:])
+		}
+		rasterize(target, tri, texture)
+	}
+}
+
+// Returns twice the signed area of the triangle a, b, (x, y), which is
+// positive if they go counter-clockwise.
+func edge(a, b softwareVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// Pixels whose centers are exactly on an edge are only drawn if it is a top
+// or left edge, so pixels on the diagonal of a quad aren't drawn twice.
+// This is the same rule that gl uses.
+func covers(w float32, a, b softwareVertex) bool {
+	if w != 0 {
+		return w > 0
+	}
+	return b.y < a.y || (b.y == a.y && b.x < a.x)
+}
+
+func rasterize(target softwareTarget, tri [3]softwareVertex, texture *Texture) {
+	a, b, c := tri[0], tri[1], tri[2]
+	area := edge(a, b, c.x, c.y)
+	if area == 0 {
+		return
+	}
+	if area < 0 {
+		b, c = c, b
+		area = -area
+	}
+	dx, dy := target.dims()
+	min_x := int(math.Floor(float64(minf(a.x, b.x, c.x))))
+	max_x := int(math.Ceil(float64(maxf(a.x, b.x, c.x))))
+	min_y := int(math.Floor(float64(minf(a.y, b.y, c.y))))
+	max_y := int(math.Ceil(float64(maxf(a.y, b.y, c.y))))
+	if min_x < 0 {
+		min_x = 0
+	}
+	if min_y < 0 {
+		min_y = 0
+	}
+	if max_x > dx {
+		max_x = dx
+	}
+	if max_y > dy {
+		max_y = dy
+	}
+	for y := min_y; y < max_y; y++ {
+		for x := min_x; x < max_x; x++ {
+			cx, cy := float32(x)+0.5, float32(y)+0.5
+			wa, wb, wc := edge(b, c, cx, cy), edge(c, a, cx, cy), edge(a, b, cx, cy)
+			if !covers(wa, b, c) || !covers(wb, c, a) || !covers(wc, a, b) {
+				continue
+			}
+			var attr [6]float32
+			for k := range attr {
+				attr[k] = (wa*a.attr[k] + wb*b.attr[k] + wc*c.attr[k]) / area
+			}
+			color := [4]float32{attr[2], attr[3], attr[4], attr[5]}
+			if texture != nil {
+				texel := texture.sample(attr[0], attr[1])
+				for k := range color {
+					color[k] *= texel[k]
+				}
+			}
+			target.blend(x, y, color)
+		}
+	}
+}
+
+// Returns the texel at (u, v), with nearest filtering and wrapping like
+// GL_REPEAT.  A texture that isn't ready samples as opaque black, like an
+// incomplete texture does in gl.
+func (t *Texture) sample(u, v float32) [4]float32 {
+	if t.pix == nil {
+		return [4]float32{0, 0, 0, 1}
+	}
+	x := wrap(int(math.Floor(float64(u*float32(t.dx)))), t.dx)
+	y := wrap(int(math.Floor(float64(v*float32(t.dy)))), t.dy)
+	p := t.pix[4*(y*t.dx+x):]
+	return [4]float32{float32(p[0]) / 255, float32(p[1]) / 255, float32(p[2]) / 255, float32(p[3]) / 255}
+}
+
+func wrap(n, size int) int {
+	n %= size
+	if n < 0 {
+		n += size
+	}
+	return n
+}
+
+func minf(a, b, c float32) float32 {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func maxf(a, b, c float32) float32 {
+	if b > a {
+		a = b
+	}
+	if c > a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,91 @@
+package render_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Draws whatever add puts in a QuadBatch into a dx by dy target, which starts
+// out opaque black, and returns the target.
+func drawToImage(dx, dy int, texture *render.Texture, add func(qb *render.QuadBatch)) *image.RGBA {
+	target := image.NewRGBA(image.Rect(0, 0, dx, dy))
+	draw.Draw(target, target.Rect, image.NewUniform(color.RGBA{0, 0, 0, 255}), image.Point{}, draw.Src)
+	render.Queue(func() {
+		render.SetSoftwareTarget(target)
+		var qb render.QuadBatch
+		add(&qb)
+		qb.Draw(texture)
+	})
+	render.Sync()
+	return target
+}
+
+func SoftwareSpec(c gospec.Context) {
+	black := color.RGBA{0, 0, 0, 255}
+	red := color.RGBA{255, 0, 0, 255}
+	c.Specify("Quads cover the pixels whose centers are inside them, from the bottom left.", func() {
+		target := drawToImage(8, 8, nil, func(qb *render.QuadBatch) {
+			qb.Add(2, 1, 6, 3, 0, 0, 0, 0, [4]float32{1, 0, 0, 1})
+		})
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				expected := black
+				// Rows go down from the top of the image, so y = 1 and 2 up
+				// from the bottom are rows 6 and 5.
+				if x >= 2 && x < 6 && (y == 5 || y == 6) {
+					expected = red
+				}
+				c.Expect(target.RGBAAt(x, y), Equals, expected)
+			}
+		}
+	})
+	c.Specify("Colors are blended the way gl blends them, once per pixel.", func() {
+		target := drawToImage(4, 4, nil, func(qb *render.QuadBatch) {
+			qb.Add(0, 0, 4, 4, 0, 0, 0, 0, [4]float32{1, 1, 1, 0.5})
+		})
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				c.Expect(target.RGBAAt(x, y), Equals, color.RGBA{128, 128, 128, 191})
+			}
+		}
+	})
+	c.Specify("Textures are sampled with v = 0 at the top of the image.", func() {
+		pix := []byte{
+			255, 0, 0, 255, 0, 255, 0, 255,
+			0, 0, 255, 255, 255, 255, 255, 255,
+		}
+		texture := render.Textures().LoadRGBA(pix, 2, 2)
+		texture.Wait()
+		defer texture.Delete()
+		target := drawToImage(2, 2, texture, func(qb *render.QuadBatch) {
+			qb.Add(0, 0, 2, 2, 0, 1, 1, 0, [4]float32{1, 1, 1, 1})
+		})
+		c.Expect(target.RGBAAt(0, 0), Equals, red)
+		c.Expect(target.RGBAAt(1, 0), Equals, color.RGBA{0, 255, 0, 255})
+		c.Expect(target.RGBAAt(0, 1), Equals, color.RGBA{0, 0, 255, 255})
+		c.Expect(target.RGBAAt(1, 1), Equals, color.RGBA{255, 255, 255, 255})
+	})
+	c.Specify("DrawWithProjection maps the quads through the projection.", func() {
+		target := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		render.Queue(func() {
+			render.SetSoftwareTarget(target)
+			var qb render.QuadBatch
+			qb.Add(-1, -1, 0, 1, 0, 0, 0, 0, [4]float32{1, 0, 0, 1})
+			qb.DrawWithProjection(nil, render.Ortho(-1, 1, -1, 1))
+		})
+		render.Sync()
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				expected := color.RGBA{}
+				if x < 2 {
+					expected = red
+				}
+				c.Expect(target.RGBAAt(x, y), Equals, expected)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package render
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"sync"
+	"time"
+)
+
+// RenderStats describes how much work the render thread is doing.  Everything
+// other than Queued is measured per frame, where frames are marked with
+// StartFrame() and EndFrame().
+type RenderStats struct {
+	// Number of functions waiting to be run on the render thread right now.
+	Queued int
+
+	// The most recently completed frame, the number of queued functions that
+	// were run for it, and how long they took to run.
+	Frame int64
+	Funcs int
+	CPU   time.Duration
+
+	// Number of frames so far that took more than half again as long as the
+	// expected frame time to complete, see SetExpectedFrameTime().
+	SwapMisses int64
+
+	// The most recent frame whose GPU time is known, and how long the GPU spent
+	// on it.  GPU results lag a few frames behind, and GPUFrame is 0 unless GPU
+	// timing has been turned on with SetGPUTiming().
+	GPUFrame int64
+	GPU      time.Duration
+}
+
+var (
+	stats_mutex sync.Mutex
+	stats       RenderStats
+
+	// stats for the frame in progress on the render thread
+	cur_funcs int
+	cur_cpu   time.Duration
+
+	// when the last frame completed, used to detect swap misses
+	last_frame_end      time.Time
+	expected_frame_time time.Duration
+	gpu_timing          bool
+)
+
+// Stats returns statistics about the render thread.
+func Stats() RenderStats {
+	stats_mutex.Lock()
+	defer stats_mutex.Unlock()
+	s := stats
+	s.Queued = len(render_funcs)
+	return s
+}
+
+// SetExpectedFrameTime sets how long a frame is expected to take, usually the
+// refresh interval of the display when vsync is on.  Frames that take more than
+// half again as long count as swap misses.  The default, 0, turns off swap miss
+// detection.
+func SetExpectedFrameTime(d time.Duration) {
+	stats_mutex.Lock()
+	defer stats_mutex.Unlock()
+	expected_frame_time = d
+}
+
+// SetGPUTiming turns GPU timer queries on or off.  They are only supported on
+// the GL33 pipeline, and they can't be used while timing each frame on the GPU,
+// so this should be left off if the game runs its own GL_TIME_ELAPSED queries.
+func SetGPUTiming(enable bool) {
+	stats_mutex.Lock()
+	defer stats_mutex.Unlock()
+	gpu_timing = enable && pipeline == GL33
+}
+
+// Runs f on the render thread, keeping track of how long it takes.
+func runTimed(f func()) {
+	start := time.Now()
+	f()
+	stats_mutex.Lock()
+	cur_funcs++
+	cur_cpu += time.Since(start)
+	stats_mutex.Unlock()
+}
+
+// Called on the render thread by the fence queued in EndFrame().
+func completeFrame(frame int64) {
+	now := time.Now()
+	stats_mutex.Lock()
+	stats.Frame = frame
+	stats.Funcs = cur_funcs
+	stats.CPU = cur_cpu
+	cur_funcs = 0
+	cur_cpu = 0
+	if expected_frame_time > 0 && !last_frame_end.IsZero() {
+		if now.Sub(last_frame_end) > expected_frame_time*3/2 {
+			stats.SwapMisses++
+		}
+	}
+	last_frame_end = now
+	stats_mutex.Unlock()
+	endGpuQuery(frame)
+}
+
+func gpuTiming() bool {
+	stats_mutex.Lock()
+	defer stats_mutex.Unlock()
+	return gpu_timing
+}
+
+// GPU timer query state, only touched on the render thread.
+type gpuQuery struct {
+	frame int64
+	id    uint32
+}
+
+var (
+	gpu_free_queries []uint32
+	gpu_pending      []gpuQuery
+	gpu_active       *gpuQuery
+)
+
+// Called on the render thread at the start of a frame.
+func beginGpuQuery(frame int64) {
+	if !gpuTiming() || gpu_active != nil {
+		return
+	}
+	var id uint32
+	if len(gpu_free_queries) > 0 {
+		id = gpu_free_queries[len(gpu_free_queries)-1]
+		gpu_free_queries = gpu_free_queries[:len(gpu_free_queries)-1]
+	} else {
+		gl.GenQueries(1, &id)
+	}
+	gl.BeginQuery(gl.TIME_ELAPSED, id)
+	gpu_active = &gpuQuery{frame: frame, id: id}
+}
+
+// Called on the render thread at the end of a frame, ends the active query
+// and collects the results of any earlier queries that have finished.
+func endGpuQuery(frame int64) {
+	if gpu_active != nil && gpu_active.frame == frame {
+		gl.EndQuery(gl.TIME_ELAPSED)
+		gpu_pending = append(gpu_pending, *gpu_active)
+		gpu_active = nil
+	}
+	for len(gpu_pending) > 0 {
+		query := gpu_pending[0]
+		var available int32
+		gl.GetQueryObjectiv(query.id, gl.QUERY_RESULT_AVAILABLE, &available)
+		if available == 0 {
+			break
+		}
+		var ns uint64
+		gl.GetQueryObjectui64v(query.id, gl.QUERY_RESULT, &ns)
+		gpu_pending = gpu_pending[1:]
+		gpu_free_queries = append(gpu_free_queries, query.id)
+		stats_mutex.Lock()
+		stats.GPUFrame = query.frame
+		stats.GPU = time.Duration(ns)
+		stats_mutex.Unlock()
+	}
+}
@@ -0,0 +1,139 @@
+package render
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"image"
+)
+
+// A RenderTarget is an offscreen framebuffer with a color texture and a
+// depth/stencil buffer.  While it is bound everything drawn goes into its
+// texture, which can then be drawn like any other Texture.  All methods must
+// be called on the render thread.
+type RenderTarget struct {
+	dx, dy  int
+	fbo     uint32
+	depth   uint32
+	texture *Texture
+
+	// framebuffer and viewport to restore in Unbind()
+	bound         bool
+	prev_fbo      int32
+	prev_viewport [4]int32
+	prev_software softwareTarget
+}
+
+// NewRenderTarget returns a RenderTarget that is dx by dy pixels.  An error is
+// returned if the driver reports that the framebuffer is incomplete, which
+// usually means that dx or dy is too large.  Must be called on the render
+// thread.
+func NewRenderTarget(dx, dy int) (*RenderTarget, error) {
+	MustRunOnRenderThread()
+	if dx <= 0 || dy <= 0 {
+		return nil, fmt.Errorf("Cannot make a %dx%d render target.", dx, dy)
+	}
+	rt := &RenderTarget{dx: dx, dy: dy}
+	// Textures without pixels are made immediately.
+	rt.texture = Textures().loadRGBA(nil, dx, dy, false)
+	if !HasGl() {
+		return rt, nil
+	}
+
+	var prev int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &prev)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(prev))
+
+	gl.GenFramebuffers(1, &rt.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, rt.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, rt.texture.id, 0)
+	gl.GenRenderbuffers(1, &rt.depth)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, rt.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH24_STENCIL8, int32(dx), int32(dy))
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.RENDERBUFFER, rt.depth)
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		rt.Delete()
+		return nil, fmt.Errorf("Framebuffer for a %dx%d render target is incomplete: %s.", dx, dy, framebufferStatusName(status))
+	}
+	return rt, nil
+}
+
+func framebufferStatusName(status uint32) string {
+	switch status {
+	case gl.FRAMEBUFFER_INCOMPLETE_ATTACHMENT:
+		return "GL_FRAMEBUFFER_INCOMPLETE_ATTACHMENT"
+	case gl.FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT:
+		return "GL_FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT"
+	case gl.FRAMEBUFFER_UNSUPPORTED:
+		return "GL_FRAMEBUFFER_UNSUPPORTED"
+	}
+	return fmt.Sprintf("0x%x", status)
+}
+
+// Bind makes rt the target of all drawing and sets the viewport to cover all
+// of it.  The previous framebuffer and viewport are restored by Unbind().
+// Binding a target that is already bound has no effect.
+func (rt *RenderTarget) Bind() {
+	MustRunOnRenderThread()
+	if rt.bound {
+		return
+	}
+	rt.bound = true
+	if pipeline == Software {
+		rt.prev_software = software_target
+		t := rt.texture
+		software_target = softwareTarget{
+			im: &image.RGBA{Pix: t.pix, Stride: 4 * t.dx, Rect: image.Rect(0, 0, t.dx, t.dy)},
+			up: true,
+		}
+	}
+	if !HasGl() {
+		return
+	}
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &rt.prev_fbo)
+	gl.GetIntegerv(gl.VIEWPORT, &rt.prev_viewport[0])
+	gl.BindFramebuffer(gl.FRAMEBUFFER, rt.fbo)
+	gl.Viewport(0, 0, int32(rt.dx), int32(rt.dy))
+}
+
+// Unbind restores the framebuffer and viewport that were in use when Bind()
+// was called.
+func (rt *RenderTarget) Unbind() {
+	MustRunOnRenderThread()
+	if !rt.bound {
+		return
+	}
+	rt.bound = false
+	if pipeline == Software {
+		software_target = rt.prev_software
+	}
+	if !HasGl() {
+		return
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(rt.prev_fbo))
+	v := rt.prev_viewport
+	gl.Viewport(v[0], v[1], v[2], v[3])
+}
+
+// Texture returns the texture that rt draws into.  It should not be drawn
+// while rt is bound.
+func (rt *RenderTarget) Texture() *Texture {
+	return rt.texture
+}
+
+func (rt *RenderTarget) Dims() (dx, dy int) {
+	return rt.dx, rt.dy
+}
+
+// Delete frees rt and its texture, it must not be used afterwards.
+func (rt *RenderTarget) Delete() {
+	MustRunOnRenderThread()
+	rt.Unbind()
+	if HasGl() {
+		gl.DeleteFramebuffers(1, &rt.fbo)
+		gl.DeleteRenderbuffers(1, &rt.depth)
+	}
+	rt.texture.Delete()
+	rt.fbo, rt.depth = 0, 0
+}
@@ -0,0 +1,241 @@
+package render
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"image"
+	"image/draw"
+	"sync"
+	"unsafe"
+)
+
+// A TextureManager uploads textures on the render thread and keeps track of
+// how much video memory they are using.  Most code should just use the
+// TextureManager returned by Textures().
+type TextureManager struct {
+	mutex    sync.Mutex
+	textures map[*Texture]bool
+	vram     int64
+
+	// Uploads are sent here while they are being done on a shared context,
+	// see UploadOn().  Guarded by upload_mutex, which is held while sending so
+	// that it can't be closed out from under a send.
+	upload_mutex sync.Mutex
+	uploads      chan func()
+}
+
+// A Texture is a handle to an OpenGL texture created by a TextureManager.  The
+// texture is uploaded asynchronously, until it has been uploaded binding it is
+// the same as binding texture 0.
+type Texture struct {
+	manager *TextureManager
+	dx, dy  int
+	bytes   int64
+
+	// id of the gl texture, 0 until it has been uploaded and after it has been
+	// deleted.  This and deleted are only touched on the render thread.
+	id      uint32
+	deleted bool
+
+	// closed once the texture has been uploaded
+	ready chan bool
+
+	// On the Software pipeline this is the texture itself, with v = 0 in row
+	// 0, instead of there being a gl texture.  Only touched on the render
+	// thread.
+	pix []byte
+}
+
+var textures *TextureManager
+
+func init() {
+	textures = MakeTextureManager()
+}
+
+// Textures returns the standard TextureManager.
+func Textures() *TextureManager {
+	return textures
+}
+
+func MakeTextureManager() *TextureManager {
+	return &TextureManager{textures: make(map[*Texture]bool)}
+}
+
+// LoadImage queues im to be uploaded as a texture and returns a handle to it
+// immediately.  im is converted to RGBA on the calling goroutine, so it may be
+// modified as soon as LoadImage returns.
+func (tm *TextureManager) LoadImage(im image.Image) *Texture {
+	bounds := im.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, im, bounds.Min, draw.Src)
+	return tm.LoadRGBA(rgba.Pix, bounds.Dx(), bounds.Dy())
+}
+
+// LoadRGBA queues pix, which must be dx*dy pixels of tightly packed 8-bit RGBA
+// data, to be uploaded as a texture and returns a handle to it immediately.
+// pix must not be modified until the texture is ready, see Texture.Wait().
+func (tm *TextureManager) LoadRGBA(pix []byte, dx, dy int) *Texture {
+	if len(pix) != 4*dx*dy {
+		panic("render.LoadRGBA() requires exactly 4*dx*dy bytes of pixel data.")
+	}
+	return tm.loadRGBA(pix, dx, dy, true)
+}
+
+// Does the work for LoadRGBA().  pix may be nil, in which case the contents of
+// the texture are undefined and it is made right away, which has to be on the
+// render thread, this is used for render targets.
+func (tm *TextureManager) loadRGBA(pix []byte, dx, dy int, mipmap bool) *Texture {
+	t := &Texture{
+		manager: tm,
+		dx:      dx,
+		dy:      dy,
+		ready:   make(chan bool),
+	}
+	t.bytes = int64(4 * dx * dy)
+	if mipmap {
+		// A full chain of mipmaps takes up a third again as much space as the
+		// base level.
+		t.bytes = t.bytes * 4 / 3
+	}
+	tm.mutex.Lock()
+	tm.textures[t] = true
+	tm.vram += t.bytes
+	tm.mutex.Unlock()
+	// Render targets are drawn to right away, so they're made right away on
+	// the render thread.
+	if pix == nil {
+		MustRunOnRenderThread()
+		t.upload(nil, mipmap)
+		close(t.ready)
+		return t
+	}
+	if tm.uploadShared(t, pix, mipmap) {
+		return t
+	}
+	Queue(func() {
+		t.upload(pix, mipmap)
+		close(t.ready)
+	})
+	return t
+}
+
+func (t *Texture) upload(pix []byte, mipmap bool) {
+	if t.deleted {
+		return
+	}
+	t.id = t.create(pix, mipmap)
+}
+
+// Makes a gl texture out of pix on whatever context is current and returns
+// its id.
+func (t *Texture) create(pix []byte, mipmap bool) uint32 {
+	if !HasGl() {
+		if pipeline == Software {
+			t.pix = make([]byte, 4*t.dx*t.dy)
+			copy(t.pix, pix)
+		}
+		return 0
+	}
+	var id uint32
+	gl.GenTextures(1, &id)
+	gl.BindTexture(gl.TEXTURE_2D, id)
+	if mipmap {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	} else {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	var ptr unsafe.Pointer
+	if len(pix) > 0 {
+		ptr = gl.Ptr(&pix[0])
+	}
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(t.dx), int32(t.dy), 0, gl.RGBA, gl.UNSIGNED_BYTE, ptr)
+	if mipmap {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
+	return id
+}
+
+// VRAM returns an estimate of the number of bytes of video memory used by all
+// of the textures that have been loaded and not yet deleted, including their
+// mipmaps.
+func (tm *TextureManager) VRAM() int64 {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return tm.vram
+}
+
+// NumTextures returns the number of textures that have been loaded and not yet
+// deleted.
+func (tm *TextureManager) NumTextures() int {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return len(tm.textures)
+}
+
+// Bind binds the texture to GL_TEXTURE_2D.  Binding a nil Texture, or one that
+// hasn't been uploaded yet, binds texture 0.  Must be called on the render
+// thread.
+func (t *Texture) Bind() {
+	if !HasGl() {
+		return
+	}
+	var id uint32
+	if t != nil {
+		id = t.id
+	}
+	gl.BindTexture(gl.TEXTURE_2D, id)
+}
+
+// Id returns the gl texture id, which is 0 until the texture is ready.  Must be
+// called on the render thread.
+func (t *Texture) Id() uint32 {
+	return t.id
+}
+
+func (t *Texture) Dims() (dx, dy int) {
+	return t.dx, t.dy
+}
+
+// Ready returns true iff the texture has been uploaded.
+func (t *Texture) Ready() bool {
+	select {
+	case <-t.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until the texture has been uploaded.  It must not be called from
+// the render thread.
+func (t *Texture) Wait() {
+	mustNotRunOnRenderThread("Texture.Wait")
+	<-t.ready
+}
+
+// Delete queues the texture to be deleted once it has been uploaded.  Deleting
+// a texture more than once has no effect.
+func (t *Texture) Delete() {
+	tm := t.manager
+	tm.mutex.Lock()
+	if !tm.textures[t] {
+		tm.mutex.Unlock()
+		return
+	}
+	delete(tm.textures, t)
+	tm.vram -= t.bytes
+	tm.mutex.Unlock()
+	Queue(func() {
+		// If the upload hasn't happened yet, which is possible if Delete was
+		// called from the render thread, this keeps it from happening at all.
+		t.deleted = true
+		t.pix = nil
+		if t.id != 0 {
+			gl.DeleteTextures(1, &t.id)
+			t.id = 0
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"runtime"
+)
+
+// A GL context that shares textures with the render thread's context, like
+// the system.SharedContext returned by System.CreateSharedContext().
+type Context interface {
+	MakeCurrent() error
+	Release()
+}
+
+// Makes tm upload textures on ctx, from a thread of its own, instead of on
+// the render thread, so that big uploads like sprite sheets don't hold up
+// drawing.  Textures still become ready on the render thread, so Ready(),
+// Wait(), and Id() work the same way.  Calling it again moves uploads to the
+// new context once the uploads already sent to the old one are done.  Returns
+// an error, and leaves uploads where they were, if ctx can't be made current.
+func (tm *TextureManager) UploadOn(ctx Context) error {
+	if !HasGl() {
+		return fmt.Errorf("Can't upload textures on another context with the %v pipeline.", pipeline)
+	}
+	uploads := make(chan func(), 100)
+	started := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if err := ctx.MakeCurrent(); err != nil {
+			started <- err
+			return
+		}
+		started <- nil
+		for f := range uploads {
+			f()
+		}
+		ctx.Release()
+	}()
+	if err := <-started; err != nil {
+		return err
+	}
+	tm.upload_mutex.Lock()
+	defer tm.upload_mutex.Unlock()
+	if tm.uploads != nil {
+		close(tm.uploads)
+	}
+	tm.uploads = uploads
+	return nil
+}
+
+// Moves uploads back to the render thread and releases the context given to
+// UploadOn() once the uploads already sent to it are done.
+func (tm *TextureManager) StopUploads() {
+	tm.upload_mutex.Lock()
+	defer tm.upload_mutex.Unlock()
+	if tm.uploads != nil {
+		close(tm.uploads)
+		tm.uploads = nil
+	}
+}
+
+// Uploads pix as t on the shared context if there is one, and returns false
+// if there isn't.  The id is handed to t on the render thread, where a
+// texture that was deleted in the meantime is deleted.
+func (tm *TextureManager) uploadShared(t *Texture, pix []byte, mipmap bool) bool {
+	tm.upload_mutex.Lock()
+	defer tm.upload_mutex.Unlock()
+	if tm.uploads == nil {
+		return false
+	}
+	tm.uploads <- func() {
+		id := t.create(pix, mipmap)
+		// Finish rather than Flush, the texture has to be complete before
+		// the render thread's context can use it.
+		gl.Finish()
+		Queue(func() {
+			if t.deleted {
+				gl.DeleteTextures(1, &id)
+			} else {
+				t.id = id
+			}
+			close(t.ready)
+		})
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+package save_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(SaveSpec)
+	r.AddSpec(MigrationSpec)
+	gospec.MainGoTest(r, t)
+}
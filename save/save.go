@@ -0,0 +1,333 @@
+// Package save writes and reads save games.  Every type that goes in a save
+// is registered with a name and a version number, and when a type changes
+// its version is bumped and a migration is registered that converts saves
+// from the old version, so that old saves keep working.  sprite.SpriteState
+// is registered by the sprite package.
+//
+// Save files are compressed and checksummed, and they are written to a
+// temporary file that is renamed over the old save once it's complete, so a
+// crash while saving never loses the last save.
+package save
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// A Migration converts an object saved at one version of its type to the
+// next version.  old is the object as it was saved, Decode() can decode it
+// into a copy of the old type and Encode() can encode the new one.
+type Migration func(old []byte) ([]byte, error)
+
+// Encodes v the same way that objects in saves are encoded.
+func Encode(v interface{}) ([]byte, error) {
+	// gob only uses a GobEncode() with a pointer receiver, like
+	// sprite.SpriteState's, if it can take the address of the value, so
+	// values are encoded through a pointer to a copy.
+	if rv := reflect.ValueOf(v); rv.IsValid() && rv.Kind() != reflect.Ptr {
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		v = p.Interface()
+	}
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(v)
+	return b.Bytes(), err
+}
+
+// Decodes data from Encode(), or from a Migration, into v, which must be a
+// pointer.
+func Decode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// A Game is everything in a save, by whatever keys the game wants to use.
+// Every value must be of a registered type.
+type Game map[string]interface{}
+
+type registered struct {
+	name       string
+	version    int
+	typ        reflect.Type
+	migrations map[int]Migration
+}
+
+// A Registry knows the types that can be saved and how to migrate old
+// versions of them.  Most games just use the package level functions, which
+// use a default Registry.
+type Registry struct {
+	mutex   sync.Mutex
+	by_name map[string]*registered
+	by_type map[reflect.Type]*registered
+}
+
+func MakeRegistry() *Registry {
+	return &Registry{
+		by_name: make(map[string]*registered),
+		by_type: make(map[reflect.Type]*registered),
+	}
+}
+
+var the_registry = MakeRegistry()
+
+// Anything that knows where the user's data directory is, like a
+// system.System.
+type DataDirer interface {
+	UserDataDir(app string) (string, error)
+}
+
+// Returns where the save called name should go, in the saves directory in
+// app's data directory, which is created if it doesn't exist:
+//
+//	path, err := save.UserPath(sys, "mygame", "slot1.sav")
+func UserPath(dirs DataDirer, app, name string) (string, error) {
+	dir, err := dirs.UserDataDir(app)
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "saves")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func Register(name string, version int, v interface{}) {
+	the_registry.Register(name, version, v)
+}
+
+func RegisterMigration(name string, from int, m Migration) {
+	the_registry.RegisterMigration(name, from, m)
+}
+
+func Write(path string, game Game) error {
+	return the_registry.Write(path, game)
+}
+
+func Read(path string) (Game, error) {
+	return the_registry.Read(path)
+}
+
+func WriteGame(w io.Writer, game Game) error {
+	return the_registry.WriteGame(w, game)
+}
+
+func ReadGame(r io.Reader) (Game, error) {
+	return the_registry.ReadGame(r)
+}
+
+// Registers the type of v, which is saved under name at version.  Names are
+// what saves refer to types by, so they must never change once a game has
+// shipped, and versions start at 1.
+func (r *Registry) Register(name string, version int, v interface{}) {
+	if version < 1 {
+		panic(fmt.Sprintf("Cannot register %s at version %d.", name, version))
+	}
+	t := reflect.TypeOf(v)
+	if t == nil {
+		panic(fmt.Sprintf("Cannot register nil as %s.", name))
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.by_name[name]; ok {
+		panic(fmt.Sprintf("%s has already been registered.", name))
+	}
+	if prev, ok := r.by_type[t]; ok {
+		panic(fmt.Sprintf("%v has already been registered as %s.", t, prev.name))
+	}
+	reg := &registered{name: name, version: version, typ: t, migrations: make(map[int]Migration)}
+	r.by_name[name] = reg
+	r.by_type[t] = reg
+}
+
+// Registers a migration for the type registered as name from version from to
+// version from+1.  Saves from any older version are migrated one version at
+// a time until they're current.
+func (r *Registry) RegisterMigration(name string, from int, m Migration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	reg, ok := r.by_name[name]
+	if !ok {
+		panic(fmt.Sprintf("Cannot register a migration for %s, it isn't registered.", name))
+	}
+	if from < 1 || from >= reg.version {
+		panic(fmt.Sprintf("Cannot register a migration for %s from version %d, it's at version %d.", name, from, reg.version))
+	}
+	if _, ok := reg.migrations[from]; ok {
+		panic(fmt.Sprintf("%s already has a migration from version %d.", name, from))
+	}
+	reg.migrations[from] = m
+}
+
+// Every save file starts with saveMagic, saveFormat, and a checksum of the
+// rest of the file, which is a compressed gob encoded []record.  Everything
+// is little endian.
+const saveMagic = "GLSV"
+const saveFormat uint32 = 1
+
+type record struct {
+	Key     string
+	Type    string
+	Version int
+	Data    []byte
+}
+
+// Writes game to path.  Nothing is written unless everything in game can be
+// saved, and the old file at path, if there is one, is only replaced once
+// the new one has been completely written.
+func (r *Registry) Write(path string, game Game) error {
+	var b bytes.Buffer
+	if err := r.WriteGame(&b, game); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b.Bytes())
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Writes game to w in the same format as Write().
+func (r *Registry) WriteGame(w io.Writer, game Game) error {
+	keys := make([]string, 0, len(game))
+	for key := range game {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	records := make([]record, 0, len(game))
+	r.mutex.Lock()
+	for _, key := range keys {
+		v := game[key]
+		reg, ok := r.by_type[reflect.TypeOf(v)]
+		if !ok {
+			r.mutex.Unlock()
+			return fmt.Errorf("Cannot save %s, %T isn't registered.", key, v)
+		}
+		records = append(records, record{Key: key, Type: reg.name, Version: reg.version})
+	}
+	r.mutex.Unlock()
+	for i := range records {
+		data, err := Encode(game[records[i].Key])
+		if err != nil {
+			return fmt.Errorf("Cannot save %s: %v", records[i].Key, err)
+		}
+		records[i].Data = data
+	}
+
+	var payload bytes.Buffer
+	fw, _ := flate.NewWriter(&payload, flate.DefaultCompression)
+	if err := gob.NewEncoder(fw).Encode(records); err != nil {
+		return err
+	}
+	fw.Close()
+	var header bytes.Buffer
+	header.WriteString(saveMagic)
+	binary.Write(&header, binary.LittleEndian, saveFormat)
+	binary.Write(&header, binary.LittleEndian, crc32.ChecksumIEEE(payload.Bytes()))
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+// Reads the save at path, migrating anything in it that was saved at an old
+// version.  Each value in the returned Game has the type that was
+// registered, not a pointer to it.
+func (r *Registry) Read(path string) (Game, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	game, err := r.ReadGame(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return game, nil
+}
+
+// Reads a save written by WriteGame() from rd.
+func (r *Registry) ReadGame(rd io.Reader) (Game, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(saveMagic)+8 || string(data[:len(saveMagic)]) != saveMagic {
+		return nil, fmt.Errorf("Not a save file.")
+	}
+	data = data[len(saveMagic):]
+	if format := binary.LittleEndian.Uint32(data); format != saveFormat {
+		return nil, fmt.Errorf("Save file is format %d, expected %d.", format, saveFormat)
+	}
+	if binary.LittleEndian.Uint32(data[4:]) != crc32.ChecksumIEEE(data[8:]) {
+		return nil, fmt.Errorf("Save file is corrupt.")
+	}
+	var records []record
+	fr := flate.NewReader(bytes.NewReader(data[8:]))
+	defer fr.Close()
+	if err := gob.NewDecoder(fr).Decode(&records); err != nil {
+		return nil, fmt.Errorf("Save file is corrupt: %v", err)
+	}
+
+	game := make(Game, len(records))
+	for _, rec := range records {
+		v, err := r.load(rec)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot load %s: %v", rec.Key, err)
+		}
+		game[rec.Key] = v
+	}
+	return game, nil
+}
+
+// Migrates rec to the current version of its type and decodes it.
+func (r *Registry) load(rec record) (interface{}, error) {
+	r.mutex.Lock()
+	reg, ok := r.by_name[rec.Type]
+	r.mutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s isn't registered.", rec.Type)
+	}
+	if rec.Version > reg.version {
+		return nil, fmt.Errorf("It was saved at version %d of %s, which is newer than this version, %d.", rec.Version, rec.Type, reg.version)
+	}
+	data := rec.Data
+	for version := rec.Version; version < reg.version; version++ {
+		r.mutex.Lock()
+		m, ok := reg.migrations[version]
+		r.mutex.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("%s has no migration from version %d.", rec.Type, version)
+		}
+		var err error
+		if data, err = m(data); err != nil {
+			return nil, fmt.Errorf("Migrating %s from version %d: %v", rec.Type, version, err)
+		}
+	}
+	v := reflect.New(reg.typ)
+	if err := Decode(data, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,173 @@
+package save_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/save"
+	"os"
+	"path/filepath"
+)
+
+type playerV1 struct {
+	Name string
+	Hp   int
+}
+
+type playerV2 struct {
+	Name   string
+	Hp     int
+	Max_hp int
+}
+
+type playerV3 struct {
+	Name   string
+	Health float64
+}
+
+type level struct {
+	Number int
+	Seen   []bool
+}
+
+// Only has unexported fields, so gob can only save it with GobEncode().
+type sealed struct {
+	secret string
+}
+
+func (s *sealed) GobEncode() ([]byte, error) {
+	return []byte(s.secret), nil
+}
+
+func (s *sealed) GobDecode(data []byte) error {
+	s.secret = string(data)
+	return nil
+}
+
+// Puts every app's data directory in dir.
+type dataDirs string
+
+func (d dataDirs) UserDataDir(app string) (string, error) {
+	return filepath.Join(string(d), app), nil
+}
+
+func SaveSpec(c gospec.Context) {
+	tmp, err := os.MkdirTemp("", "save")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "slot1.sav")
+	r := save.MakeRegistry()
+	r.Register("player", 1, playerV1{})
+	r.Register("level", 3, level{})
+
+	c.Specify("Saves read back what was written", func() {
+		game := save.Game{
+			"player": playerV1{"thunderdome", 10},
+			"level":  level{4, []bool{true, false, true}},
+		}
+		c.Assume(r.Write(path, game), Equals, nil)
+		loaded, err := r.Read(path)
+		c.Assume(err, Equals, nil)
+		c.Expect(len(loaded), Equals, 2)
+		c.Expect(loaded["player"], Equals, playerV1{"thunderdome", 10})
+		lvl, ok := loaded["level"].(level)
+		c.Assume(ok, Equals, true)
+		c.Expect(lvl.Number, Equals, 4)
+		c.Expect(lvl.Seen, ContainsInOrder, []bool{true, false, true})
+	})
+
+	c.Specify("Values whose GobEncode has a pointer receiver can be saved", func() {
+		r.Register("sealed", 1, sealed{})
+		var b bytes.Buffer
+		c.Assume(r.WriteGame(&b, save.Game{"s": sealed{"thunderdome"}}), Equals, nil)
+		loaded, err := r.ReadGame(&b)
+		c.Assume(err, Equals, nil)
+		c.Expect(loaded["s"], Equals, sealed{"thunderdome"})
+	})
+
+	c.Specify("Unregistered types aren't saved, and the old save is kept", func() {
+		c.Assume(r.Write(path, save.Game{"player": playerV1{"old", 1}}), Equals, nil)
+		err := r.Write(path, save.Game{"player": playerV2{"new", 2, 3}})
+		c.Expect(err, Not(Equals), nil)
+		loaded, err := r.Read(path)
+		c.Assume(err, Equals, nil)
+		c.Expect(loaded["player"], Equals, playerV1{"old", 1})
+		entries, _ := os.ReadDir(tmp)
+		c.Expect(len(entries), Equals, 1)
+	})
+
+	c.Specify("Corrupt saves are caught", func() {
+		var b bytes.Buffer
+		c.Assume(r.WriteGame(&b, save.Game{"level": level{Number: 12}}), Equals, nil)
+		data := b.Bytes()
+		data[len(data)-2] ^= 0x10
+		_, err := r.ReadGame(bytes.NewReader(data))
+		c.Expect(err, Not(Equals), nil)
+		_, err = r.ReadGame(bytes.NewReader([]byte("GLSV")))
+		c.Expect(err, Not(Equals), nil)
+	})
+
+	c.Specify("Saves go in a directory in the app's data directory", func() {
+		user, err := save.UserPath(dataDirs(tmp), "game", "slot2.sav")
+		c.Assume(err, Equals, nil)
+		c.Expect(user, Equals, filepath.Join(tmp, "game", "saves", "slot2.sav"))
+		c.Assume(r.Write(user, save.Game{"level": level{Number: 2}}), Equals, nil)
+	})
+
+	c.Specify("Registering a name or type twice panics", func() {
+		panicked := func(f func()) (p bool) {
+			defer func() { p = recover() != nil }()
+			f()
+			return
+		}
+		c.Expect(panicked(func() { r.Register("player", 2, playerV2{}) }), Equals, true)
+		c.Expect(panicked(func() { r.Register("hero", 1, playerV1{}) }), Equals, true)
+		c.Expect(panicked(func() { r.Register("hero", 0, playerV2{}) }), Equals, true)
+		c.Expect(panicked(func() { r.RegisterMigration("level", 3, nil) }), Equals, true)
+	})
+}
+
+func MigrationSpec(c gospec.Context) {
+	var old bytes.Buffer
+	r1 := save.MakeRegistry()
+	r1.Register("player", 1, playerV1{})
+	if err := r1.WriteGame(&old, save.Game{"p1": playerV1{"thunderdome", 10}}); err != nil {
+		panic(err)
+	}
+
+	r3 := save.MakeRegistry()
+	r3.Register("player", 3, playerV3{})
+	c.Specify("Old saves are migrated one version at a time", func() {
+		r3.RegisterMigration("player", 1, func(data []byte) ([]byte, error) {
+			var p playerV1
+			if err := save.Decode(data, &p); err != nil {
+				return nil, err
+			}
+			return save.Encode(playerV2{p.Name, p.Hp, 20})
+		})
+		r3.RegisterMigration("player", 2, func(data []byte) ([]byte, error) {
+			var p playerV2
+			if err := save.Decode(data, &p); err != nil {
+				return nil, err
+			}
+			return save.Encode(playerV3{p.Name, float64(p.Hp) / float64(p.Max_hp)})
+		})
+		game, err := r3.ReadGame(bytes.NewReader(old.Bytes()))
+		c.Assume(err, Equals, nil)
+		c.Expect(game["p1"], Equals, playerV3{"thunderdome", 0.5})
+	})
+
+	c.Specify("Saves can't be loaded without every migration", func() {
+		_, err := r3.ReadGame(bytes.NewReader(old.Bytes()))
+		c.Expect(err, Not(Equals), nil)
+	})
+
+	c.Specify("Saves from newer versions can't be loaded", func() {
+		var b bytes.Buffer
+		c.Assume(r3.WriteGame(&b, save.Game{"p1": playerV3{"new", 1}}), Equals, nil)
+		_, err := r1.ReadGame(&b)
+		c.Expect(err, Not(Equals), nil)
+	})
+}
@@ -0,0 +1,14 @@
+package script_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(CompileSpec)
+	r.AddSpec(RunSpec)
+	r.AddSpec(ClockSpec)
+	gospec.MainGoTest(r, t)
+}
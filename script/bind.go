@@ -0,0 +1,196 @@
+package script
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/clock"
+	"github.com/runningwild/glop/glog"
+	"github.com/runningwild/glop/sprite"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Returns an error unless there are exactly n args.
+func NumArgs(args []Value, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("Takes %d arguments, not %d.", n, len(args))
+	}
+	return nil
+}
+
+func Number(v Value) (float64, error) {
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("Expected a number, not %s.", describe(v))
+	}
+	return f, nil
+}
+
+func String(v Value) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("Expected a string, not %s.", describe(v))
+	}
+	return s, nil
+}
+
+// Makes a Func out of a function of one number.
+func numberFunc(f func(float64) float64) Func {
+	return func(env *Env, args []Value) (Value, error) {
+		if err := NumArgs(args, 1); err != nil {
+			return nil, err
+		}
+		n, err := Number(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(n), nil
+	}
+}
+
+// Returns the functions that every Env starts with:
+//
+//	print(...)  logs its arguments to glog
+//	str(v)      formats v as a string
+//	num(s)      parses s as a number, nil if it isn't one
+//	floor(n), ceil(n), abs(n)
+func Builtins() map[string]Func {
+	return map[string]Func{
+		"print": func(env *Env, args []Value) (Value, error) {
+			var parts []string
+			for _, v := range args {
+				parts = append(parts, Format(v))
+			}
+			glog.Infof("%s: %s", env.script.name, strings.Join(parts, " "))
+			return nil, nil
+		},
+		"str": func(env *Env, args []Value) (Value, error) {
+			if err := NumArgs(args, 1); err != nil {
+				return nil, err
+			}
+			return Format(args[0]), nil
+		},
+		"num": func(env *Env, args []Value) (Value, error) {
+			if err := NumArgs(args, 1); err != nil {
+				return nil, err
+			}
+			if f, ok := args[0].(float64); ok {
+				return f, nil
+			}
+			s, err := String(args[0])
+			if err != nil {
+				return nil, err
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, nil
+			}
+			return f, nil
+		},
+		"floor": numberFunc(math.Floor),
+		"ceil":  numberFunc(math.Ceil),
+		"abs":   numberFunc(math.Abs),
+	}
+}
+
+// Binds functions that command and query s:
+//
+//	command(cmd)  calls s.Command(cmd)
+//	state()       returns s.State()
+//	anim()        returns s.Anim()
+//	facing()      returns s.Facing()
+//	idle()        returns s.Idle()
+func BindSprite(env *Env, s *sprite.Sprite) {
+	env.Bind("command", func(env *Env, args []Value) (Value, error) {
+		if err := NumArgs(args, 1); err != nil {
+			return nil, err
+		}
+		cmd, err := String(args[0])
+		if err != nil {
+			return nil, err
+		}
+		s.Command(cmd)
+		return nil, nil
+	})
+	query := func(f func() Value) Func {
+		return func(env *Env, args []Value) (Value, error) {
+			if err := NumArgs(args, 0); err != nil {
+				return nil, err
+			}
+			return f(), nil
+		}
+	}
+	env.Bind("state", query(func() Value { return s.State() }))
+	env.Bind("anim", query(func() Value { return s.Anim() }))
+	env.Bind("facing", query(func() Value { return float64(s.Facing()) }))
+	env.Bind("idle", query(func() Value { return s.Idle() }))
+}
+
+// Binds functions that run handlers later, on c's time:
+//
+//	after(ms, handler)  runs handler once ms from now
+//	every(ms, handler)  runs handler every ms
+//	cancel(timer)       cancels a timer returned by after() or every()
+//
+// Errors from handlers run this way are logged to glog.
+func BindClock(env *Env, c *clock.Clock) {
+	schedule := func(every bool) Func {
+		return func(env *Env, args []Value) (Value, error) {
+			if err := NumArgs(args, 2); err != nil {
+				return nil, err
+			}
+			ms, err := Number(args[0])
+			if err != nil {
+				return nil, err
+			}
+			name, err := String(args[1])
+			if err != nil {
+				return nil, err
+			}
+			if !env.Has(name) {
+				return nil, fmt.Errorf("There is no handler called '%s'.", name)
+			}
+			if every && ms < 1 {
+				return nil, fmt.Errorf("Cannot run something every %v ms.", ms)
+			}
+			run := func() {
+				if err := env.Run(name); err != nil {
+					glog.Errorf("%v", err)
+				}
+			}
+			if every {
+				return c.Every(int64(ms), run), nil
+			}
+			return c.After(int64(ms), run), nil
+		}
+	}
+	env.Bind("after", schedule(false))
+	env.Bind("every", schedule(true))
+	env.Bind("cancel", func(env *Env, args []Value) (Value, error) {
+		if err := NumArgs(args, 1); err != nil {
+			return nil, err
+		}
+		t, ok := args[0].(*clock.Timer)
+		if !ok {
+			return nil, fmt.Errorf("Expected a timer, not %s.", describe(args[0]))
+		}
+		t.Cancel()
+		return nil, nil
+	})
+}
+
+// Runs the handler called name, if there is one, and logs any error to glog.
+// It is a sprite.TriggerFunc, so with an Env for each sprite
+//
+//	s.SetTriggerFunc(env.Trigger)
+//
+// makes every func:name tag in the sprite's anim graph run the handler called
+// name.
+func (env *Env) Trigger(s *sprite.Sprite, name string) {
+	if !env.Has(name) {
+		return
+	}
+	if err := env.Run(name); err != nil {
+		glog.Errorf("%v", err)
+	}
+}
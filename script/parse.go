@@ -0,0 +1,381 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of file"
+	}
+	return t.text
+}
+
+// Operators, longest first so that "<=" isn't read as "<" and "=".
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "=", "(", ")", "{", "}", ",", ";"}
+
+func lex(name, src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case unicode.IsSpace(c):
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' && src[end] != '\n' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) || src[end] != '"' {
+				return nil, fmt.Errorf("%s:%d: String is missing its closing quote.", name, line)
+			}
+			s, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: Bad string %s.", name, line, src[i:end+1])
+			}
+			tokens = append(tokens, token{tokString, s, line})
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '.':
+			end := i
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokNumber, src[i:end], line})
+			i = end
+		case c == '_' || unicode.IsLetter(c):
+			end := i
+			for end < len(src) && (src[end] == '_' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{tokIdent, src[i:end], line})
+			i = end
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokOp, op, line})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%s:%d: Unexpected character '%c'.", name, line, c)
+			}
+		}
+	}
+	return append(tokens, token{tokEOF, "", line}), nil
+}
+
+// The nodes of a parsed script.
+type expr interface{}
+
+type literal struct {
+	value Value
+}
+
+type variable struct {
+	name string
+	line int
+}
+
+type call struct {
+	name string
+	args []expr
+	line int
+}
+
+type unary struct {
+	op   string
+	x    expr
+	line int
+}
+
+type binary struct {
+	op   string
+	x, y expr
+	line int
+}
+
+type stmt interface{}
+
+type assign struct {
+	name string
+	x    expr
+}
+
+type ifStmt struct {
+	cond      expr
+	then, els []stmt
+}
+
+type exprStmt struct {
+	x expr
+}
+
+type parser struct {
+	name   string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokOp || t.kind == tokIdent) && t.text == text
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.peek().line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		return p.errorf("Expected '%s' but found '%v'.", text, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	if p.peek().kind != tokIdent || keywords[p.peek().text] {
+		return "", p.errorf("Expected a name but found '%v'.", p.peek())
+	}
+	return p.next().text, nil
+}
+
+var keywords = map[string]bool{"on": true, "if": true, "else": true, "true": true, "false": true, "nil": true}
+
+func (p *parser) handlers() (map[string][]stmt, error) {
+	handlers := make(map[string][]stmt)
+	for p.peek().kind != tokEOF {
+		if err := p.expect("on"); err != nil {
+			return nil, err
+		}
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := handlers[name]; ok {
+			return nil, p.errorf("There is already a handler called '%s'.", name)
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		handlers[name] = body
+	}
+	return handlers, nil
+}
+
+func (p *parser) block() ([]stmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	stmts := []stmt{}
+	for !p.is("}") {
+		if p.peek().kind == tokEOF {
+			return nil, p.errorf("Block is missing its closing '}'.")
+		}
+		if p.is(";") {
+			p.next()
+			continue
+		}
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	p.next()
+	return stmts, nil
+}
+
+func (p *parser) stmt() (stmt, error) {
+	if p.is("if") {
+		p.next()
+		return p.ifStmt()
+	}
+	if p.peek().kind == tokIdent && p.tokens[p.pos+1].text == "=" && p.tokens[p.pos+1].kind == tokOp {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		p.next()
+		x, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		return &assign{name, x}, nil
+	}
+	x, err := p.expr(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := x.(*call); !ok {
+		return nil, p.errorf("Only assignments, function calls, and ifs can be statements.")
+	}
+	return &exprStmt{x}, nil
+}
+
+// Parses the rest of an if after the "if".
+func (p *parser) ifStmt() (stmt, error) {
+	cond, err := p.expr(0)
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{cond: cond}
+	if s.then, err = p.block(); err != nil {
+		return nil, err
+	}
+	if !p.is("else") {
+		return s, nil
+	}
+	p.next()
+	if p.is("if") {
+		p.next()
+		elif, err := p.ifStmt()
+		if err != nil {
+			return nil, err
+		}
+		s.els = []stmt{elif}
+		return s, nil
+	}
+	s.els, err = p.block()
+	return s, err
+}
+
+// Binary operators and their precedence, higher binds tighter.
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// Parses an expression made of operators that bind tighter than min.
+func (p *parser) expr(min int) (expr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec, ok := precedence[t.text]
+		if t.kind != tokOp || !ok || prec <= min {
+			return x, nil
+		}
+		p.next()
+		y, err := p.expr(prec)
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{t.text, x, y, t.line}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.is("!") || p.is("-") {
+		t := p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{t.text, x, t.line}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokNumber:
+		p.next()
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: Bad number '%s'.", p.name, t.line, t.text)
+		}
+		return &literal{f}, nil
+	case t.kind == tokString:
+		p.next()
+		return &literal{t.text}, nil
+	case p.is("true"), p.is("false"):
+		p.next()
+		return &literal{t.text == "true"}, nil
+	case p.is("nil"):
+		p.next()
+		return &literal{nil}, nil
+	case p.is("("):
+		p.next()
+		x, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case t.kind == tokIdent && !keywords[t.text]:
+		p.next()
+		if !p.is("(") {
+			return &variable{t.text, t.line}, nil
+		}
+		p.next()
+		c := &call{name: t.text, line: t.line}
+		for !p.is(")") {
+			if len(c.args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+		}
+		p.next()
+		return c, nil
+	}
+	return nil, p.errorf("Unexpected '%v'.", t)
+}
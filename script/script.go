@@ -0,0 +1,343 @@
+// Package script runs small scripts that designers can change without
+// recompiling the game.  A script is a set of handlers, each a list of
+// statements that runs when the game asks for it by name:
+//
+//	# Runs when the anim graph reaches a frame with func:land
+//	on land {
+//	  if state() == "falling" {
+//	    command("stand")
+//	  }
+//	  after(500, "settle")
+//	}
+//
+//	on settle {
+//	  landed = landed + 1
+//	}
+//
+// Values are numbers, strings, booleans, nil, or values that the game passes
+// in from Go.  The only statements are assignments, function calls, and
+// if/else, and the only functions are the ones that the game binds into an
+// Env, along with the ones in bind.go.  Variables belong to the Env, so they
+// keep their values from one handler to the next.
+package script
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+)
+
+// A number (always a float64), string, bool, nil, or anything from Go.
+type Value interface{}
+
+// A compiled script, which can be shared by any number of Envs.
+type Script struct {
+	name     string
+	handlers map[string][]stmt
+}
+
+// Compiles src, name is used in error messages.
+func Compile(name, src string) (*Script, error) {
+	tokens, err := lex(name, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{name: name, tokens: tokens}
+	handlers, err := p.handlers()
+	if err != nil {
+		return nil, err
+	}
+	return &Script{name: name, handlers: handlers}, nil
+}
+
+// Loads and compiles the script at path.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(path, string(data))
+}
+
+// Like LoadScript() but reads name from fsys, like an assets.FS.
+func LoadScriptFS(fsys fs.FS, name string) (*Script, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(name, string(data))
+}
+
+// Returns the names of the script's handlers in sorted order.
+func (s *Script) Handlers() []string {
+	var names []string
+	for name := range s.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A function that scripts can call.
+type Func func(env *Env, args []Value) (Value, error)
+
+// An Env runs handlers from a script and holds their variables and the
+// functions they can call.  Usually there is one Env for each thing in the
+// game that runs a script, like each sprite.  An Env isn't safe to use from
+// more than one goroutine.
+type Env struct {
+	script *Script
+	vars   map[string]Value
+	funcs  map[string]Func
+}
+
+// Makes an Env for s with the functions from Builtins() bound.
+func MakeEnv(s *Script) *Env {
+	env := &Env{
+		script: s,
+		vars:   make(map[string]Value),
+		funcs:  make(map[string]Func),
+	}
+	for name, f := range Builtins() {
+		env.Bind(name, f)
+	}
+	return env
+}
+
+func (env *Env) Script() *Script {
+	return env.script
+}
+
+// Makes f available to the script as name, replacing anything already bound
+// to that name.
+func (env *Env) Bind(name string, f Func) {
+	env.funcs[name] = f
+}
+
+func (env *Env) Get(name string) Value {
+	return env.vars[name]
+}
+
+func (env *Env) Set(name string, v Value) {
+	env.vars[name] = v
+}
+
+// Returns true iff the script has a handler called name.
+func (env *Env) Has(name string) bool {
+	_, ok := env.script.handlers[name]
+	return ok
+}
+
+// Runs the handler called name.  It's an error if there isn't one.
+func (env *Env) Run(name string) error {
+	body, ok := env.script.handlers[name]
+	if !ok {
+		return fmt.Errorf("%s: There is no handler called '%s'.", env.script.name, name)
+	}
+	return env.exec(body)
+}
+
+func (env *Env) exec(stmts []stmt) error {
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *assign:
+			v, err := env.eval(s.x)
+			if err != nil {
+				return err
+			}
+			env.vars[s.name] = v
+		case *exprStmt:
+			if _, err := env.eval(s.x); err != nil {
+				return err
+			}
+		case *ifStmt:
+			cond, err := env.eval(s.cond)
+			if err != nil {
+				return err
+			}
+			if Truthy(cond) {
+				err = env.exec(s.then)
+			} else {
+				err = env.exec(s.els)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (env *Env) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", env.script.name, line, fmt.Sprintf(format, args...))
+}
+
+func (env *Env) eval(x expr) (Value, error) {
+	switch x := x.(type) {
+	case *literal:
+		return x.value, nil
+	case *variable:
+		v, ok := env.vars[x.name]
+		if !ok {
+			return nil, env.errorf(x.line, "'%s' hasn't been set.", x.name)
+		}
+		return v, nil
+	case *call:
+		f, ok := env.funcs[x.name]
+		if !ok {
+			return nil, env.errorf(x.line, "There is no function called '%s'.", x.name)
+		}
+		args := make([]Value, len(x.args))
+		for i := range x.args {
+			v, err := env.eval(x.args[i])
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		v, err := f(env, args)
+		if err != nil {
+			return nil, env.errorf(x.line, "%s(): %v", x.name, err)
+		}
+		return v, nil
+	case *unary:
+		v, err := env.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		if x.op == "!" {
+			return !Truthy(v), nil
+		}
+		f, ok := v.(float64)
+		if !ok {
+			return nil, env.errorf(x.line, "Cannot negate %s.", describe(v))
+		}
+		return -f, nil
+	case *binary:
+		return env.binary(x)
+	}
+	panic(fmt.Sprintf("Unknown expression %T.", x))
+}
+
+func (env *Env) binary(x *binary) (Value, error) {
+	a, err := env.eval(x.x)
+	if err != nil {
+		return nil, err
+	}
+	// && and || only evaluate their right side if they need to.
+	switch x.op {
+	case "&&":
+		if !Truthy(a) {
+			return false, nil
+		}
+		b, err := env.eval(x.y)
+		return Truthy(b), err
+	case "||":
+		if Truthy(a) {
+			return true, nil
+		}
+		b, err := env.eval(x.y)
+		return Truthy(b), err
+	}
+	b, err := env.eval(x.y)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	}
+	if x.op == "+" {
+		if s, ok := a.(string); ok {
+			return s + Format(b), nil
+		}
+		if s, ok := b.(string); ok {
+			return Format(a) + s, nil
+		}
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			switch x.op {
+			case "<":
+				return as < bs, nil
+			case "<=":
+				return as <= bs, nil
+			case ">":
+				return as > bs, nil
+			case ">=":
+				return as >= bs, nil
+			}
+		}
+	}
+	af, aok := a.(float64)
+	bf, bok := b.(float64)
+	if !aok || !bok {
+		return nil, env.errorf(x.line, "Cannot use '%s' on %s and %s.", x.op, describe(a), describe(b))
+	}
+	switch x.op {
+	case "+":
+		return af + bf, nil
+	case "-":
+		return af - bf, nil
+	case "*":
+		return af * bf, nil
+	case "/":
+		return af / bf, nil
+	case "%":
+		return math.Mod(af, bf), nil
+	case "<":
+		return af < bf, nil
+	case "<=":
+		return af <= bf, nil
+	case ">":
+		return af > bf, nil
+	case ">=":
+		return af >= bf, nil
+	}
+	panic(fmt.Sprintf("Unknown operator %s.", x.op))
+}
+
+// Returns false for false, nil, 0, and "", and true for everything else.
+func Truthy(v Value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+// Formats v the way that scripts see it, numbers without trailing zeros.
+func Format(v Value) string {
+	if v == nil {
+		return "nil"
+	}
+	if f, ok := v.(float64); ok {
+		return fmt.Sprint(f)
+	}
+	return fmt.Sprint(v)
+}
+
+// Describes the type of v for error messages.
+func describe(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("a %T", v)
+}
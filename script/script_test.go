@@ -0,0 +1,154 @@
+package script_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/clock"
+	"github.com/runningwild/glop/script"
+	"strings"
+)
+
+func CompileSpec(c gospec.Context) {
+	c.Specify("Scripts are made of named handlers", func() {
+		s, err := script.Compile("test", `
+      # comments are ignored
+      on b { x = 1 }
+      on a {
+        if x > 0 { x = x - 1 } else if x < 0 { x = 0 } else { y = "zero" }
+      }
+    `)
+		c.Assume(err, Equals, nil)
+		c.Expect(s.Handlers(), ContainsInOrder, []string{"a", "b"})
+	})
+
+	c.Specify("Errors say where they are", func() {
+		bad := map[string]string{
+			"on a { x = }":           "test:1: Unexpected '}'.",
+			"on a {\n x = \"abc }":   "test:2: String is missing its closing quote.",
+			"on a { x }":             "test:1: Only assignments, function calls, and ifs can be statements.",
+			"on a { f(1 2) }":        "test:1: Expected ',' but found '2'.",
+			"x = 1":                  "test:1: Expected 'on' but found 'x'.",
+			"on a {}\non a {}":       "test:2: There is already a handler called 'a'.",
+			"on a { x = 1":           "test:1: Block is missing its closing '}'.",
+			"on a {\n\n x = 1 @ 2 }": "test:3: Unexpected character '@'.",
+			"on if { }":              "test:1: Expected a name but found 'if'.",
+		}
+		for src, msg := range bad {
+			_, err := script.Compile("test", src)
+			c.Assume(err, Not(Equals), nil)
+			c.Expect(err.Error(), Equals, msg)
+		}
+	})
+}
+
+func run(c gospec.Context, src string) *script.Env {
+	s, err := script.Compile("test", src)
+	c.Assume(err, Equals, nil)
+	env := script.MakeEnv(s)
+	c.Assume(env.Run("main"), Equals, nil)
+	return env
+}
+
+func RunSpec(c gospec.Context) {
+	c.Specify("Arithmetic follows the usual precedence", func() {
+		env := run(c, `on main { x = 1 + 2 * 3 - -4 / 2; y = (1 + 2) * 3 % 5; z = floor(7 / 2) }`)
+		c.Expect(env.Get("x"), Equals, script.Value(9.0))
+		c.Expect(env.Get("y"), Equals, script.Value(4.0))
+		c.Expect(env.Get("z"), Equals, script.Value(3.0))
+	})
+
+	c.Specify("Strings concatenate and compare", func() {
+		env := run(c, `on main { s = "n=" + 2.5 + "!"; lt = "abc" < "abd"; eq = str(3) == "3" }`)
+		c.Expect(env.Get("s"), Equals, script.Value("n=2.5!"))
+		c.Expect(env.Get("lt"), Equals, script.Value(true))
+		c.Expect(env.Get("eq"), Equals, script.Value(true))
+	})
+
+	c.Specify("&& and || only evaluate what they need", func() {
+		env := run(c, `on main { a = false && missing(); b = true || missing(); c = !nil && 0 == 0 }`)
+		c.Expect(env.Get("a"), Equals, script.Value(false))
+		c.Expect(env.Get("b"), Equals, script.Value(true))
+		c.Expect(env.Get("c"), Equals, script.Value(true))
+	})
+
+	c.Specify("Variables last from one handler to the next", func() {
+		s, err := script.Compile("test", `
+      on reset { n = 0 }
+      on count { if n < 2 { n = n + 1 } else { done = true } }
+    `)
+		c.Assume(err, Equals, nil)
+		env := script.MakeEnv(s)
+		c.Assume(env.Run("reset"), Equals, nil)
+		for i := 0; i < 3; i++ {
+			c.Assume(env.Run("count"), Equals, nil)
+		}
+		c.Expect(env.Get("n"), Equals, script.Value(2.0))
+		c.Expect(env.Get("done"), Equals, script.Value(true))
+	})
+
+	c.Specify("Bound functions can be called", func() {
+		s, err := script.Compile("test", `on main { hit(2, "head"); hit(num("3"), "arm") }`)
+		c.Assume(err, Equals, nil)
+		env := script.MakeEnv(s)
+		var hits []string
+		env.Bind("hit", func(env *script.Env, args []script.Value) (script.Value, error) {
+			if err := script.NumArgs(args, 2); err != nil {
+				return nil, err
+			}
+			n, err := script.Number(args[0])
+			if err != nil {
+				return nil, err
+			}
+			where, err := script.String(args[1])
+			if err != nil {
+				return nil, err
+			}
+			hits = append(hits, strings.Repeat(where, int(n)))
+			return nil, nil
+		})
+		c.Assume(env.Run("main"), Equals, nil)
+		c.Expect(hits, ContainsInOrder, []string{"headhead", "armarmarm"})
+	})
+
+	c.Specify("Runtime errors say where they are", func() {
+		bad := map[string]string{
+			"on main {\n x = y }":          "test:2: 'y' hasn't been set.",
+			"on main { x = 1 + true }":     "test:1: Cannot use '+' on a number and a boolean.",
+			"on main { x = -\"a\" }":       "test:1: Cannot negate a string.",
+			"on main { nope() }":           "test:1: There is no function called 'nope'.",
+			"on main { x = floor(\"a\") }": "test:1: floor(): Expected a number, not a string.",
+			"on main { x = abs(1, 2) }":    "test:1: abs(): Takes 1 arguments, not 2.",
+		}
+		for src, msg := range bad {
+			s, err := script.Compile("test", src)
+			c.Assume(err, Equals, nil)
+			err = script.MakeEnv(s).Run("main")
+			c.Assume(err, Not(Equals), nil)
+			c.Expect(err.Error(), Equals, msg)
+		}
+		s, _ := script.Compile("test", "on main {}")
+		c.Expect(script.MakeEnv(s).Run("other").Error(), Equals, "test: There is no handler called 'other'.")
+	})
+}
+
+func ClockSpec(c gospec.Context) {
+	s, err := script.Compile("test", `
+    on main { n = 0; t = every(100, "tick"); after(250, "stop") }
+    on tick { n = n + 1 }
+    on stop { cancel(t) }
+  `)
+	c.Assume(err, Equals, nil)
+	clk := clock.MakeClock()
+	env := script.MakeEnv(s)
+	script.BindClock(env, clk)
+	c.Assume(env.Run("main"), Equals, nil)
+
+	c.Specify("Handlers run on the clock's time", func() {
+		clk.Think(99)
+		c.Expect(env.Get("n"), Equals, script.Value(0.0))
+		clk.Think(1)
+		c.Expect(env.Get("n"), Equals, script.Value(1.0))
+		clk.Think(1000)
+		c.Expect(env.Get("n"), Equals, script.Value(2.0))
+	})
+}
@@ -0,0 +1,15 @@
+package sound_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(WavSpec)
+	r.AddSpec(MixerSpec)
+	r.AddSpec(MusicSpec)
+	r.AddSpec(PositionalSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,448 @@
+package sound
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Number of frames mixed at a time.  At 44.1kHz this is about 12ms, which
+// is about how late a sound can start after Play() is called.
+const mixFrames = 512
+
+// Group that PlayMusic() puts music in.
+const MusicGroup = "music"
+
+// A Mixer mixes any number of voices together on its own goroutine and
+// writes the result to an Output.  All of its methods, and all of the
+// methods on the Voices that it returns, are safe to call from any goroutine.
+type Mixer struct {
+	mutex  sync.Mutex
+	out    Output
+	rate   int
+	volume float64
+
+	voices []*Voice
+	groups map[string]float64
+	music  *Voice
+
+	err    error
+	closed bool
+	done   chan struct{}
+}
+
+// Makes a mixer that writes to out and starts mixing.  Call Close() when
+// done with it.
+func MakeMixer(out Output) *Mixer {
+	m := &Mixer{
+		out:    out,
+		rate:   out.SampleRate(),
+		volume: 1,
+		groups: make(map[string]float64),
+		done:   make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+func (m *Mixer) run() {
+	buf := make([]float32, 2*mixFrames)
+	for {
+		m.mutex.Lock()
+		if m.closed {
+			m.mutex.Unlock()
+			break
+		}
+		m.mix(buf)
+		m.mutex.Unlock()
+		if err := m.out.Write(buf); err != nil {
+			m.mutex.Lock()
+			m.err = err
+			m.mutex.Unlock()
+			break
+		}
+	}
+	m.mutex.Lock()
+	for _, v := range m.voices {
+		v.finish()
+	}
+	m.voices = nil
+	m.mutex.Unlock()
+	close(m.done)
+}
+
+// Mixes the next len(buf)/2 frames into buf.
+func (m *Mixer) mix(buf []float32) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	for _, v := range m.voices {
+		v.mix(buf, m.volume*m.groupVolume(v.group))
+	}
+	for i, s := range buf {
+		if s > 1 {
+			buf[i] = 1
+		} else if s < -1 {
+			buf[i] = -1
+		}
+	}
+	playing := m.voices[:0]
+	for _, v := range m.voices {
+		if v.done {
+			v.finish()
+		} else {
+			playing = append(playing, v)
+		}
+	}
+	for i := len(playing); i < len(m.voices); i++ {
+		m.voices[i] = nil
+	}
+	m.voices = playing
+}
+
+func (m *Mixer) groupVolume(group string) float64 {
+	if v, ok := m.groups[group]; ok {
+		return v
+	}
+	return 1
+}
+
+// Stops mixing, stops all voices, and closes the output.  Returns the error
+// that stopped the mixer early, if there was one.
+func (m *Mixer) Close() error {
+	m.mutex.Lock()
+	m.closed = true
+	m.mutex.Unlock()
+	<-m.done
+	m.out.Close()
+	return m.Err()
+}
+
+// Returns the error from the output that stopped the mixer, if it has
+// stopped.
+func (m *Mixer) Err() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.err
+}
+
+// Sets the volume that everything is played at, 1 is full volume.
+func (m *Mixer) SetVolume(volume float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.volume = volume
+}
+
+// Sets the volume of every voice in group, this is on top of each voice's
+// own volume.  Groups start out at full volume, 1.
+func (m *Mixer) SetGroupVolume(group string, volume float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.groups[group] = volume
+}
+
+func (m *Mixer) GroupVolume(group string) float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.groupVolume(group)
+}
+
+func (m *Mixer) makeVoice(src source, group string, volume, pan, pitch float64) *Voice {
+	return &Voice{
+		mixer:   m,
+		src:     src,
+		group:   group,
+		volume:  volume,
+		pan:     pan,
+		pitch:   pitch,
+		gain:    1,
+		fade_to: 1,
+		pos:     2,
+	}
+}
+
+func (m *Mixer) start(v *Voice) *Voice {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		v.finish()
+		return v
+	}
+	m.voices = append(m.voices, v)
+	return v
+}
+
+// Plays s once.  pan goes from -1, only the left speaker, to 1, only the
+// right speaker.  pitch scales the playback rate, 1 plays s as is, 2 plays
+// it an octave higher and twice as fast.  The returned voice can be ignored
+// if s doesn't need to be changed or stopped while it plays.
+func (m *Mixer) Play(s *Sound, group string, volume, pan, pitch float64) *Voice {
+	return m.start(m.makeVoice(&soundSource{sound: s}, group, volume, pan, pitch))
+}
+
+// Like Play() but plays s over and over until the returned voice is stopped.
+func (m *Mixer) PlayLoop(s *Sound, group string, volume, pan, pitch float64) *Voice {
+	return m.start(m.makeVoice(&soundSource{sound: s, loop: true}, group, volume, pan, pitch))
+}
+
+// Plays s as it is read, which is what long sounds like music should use.
+// s is closed when it's done playing if it is an io.Closer.
+func (m *Mixer) PlayStream(s Stream, group string, volume float64) *Voice {
+	return m.start(m.makeVoice(makeStreamSource(s), group, volume, 0, 1))
+}
+
+// Plays s in MusicGroup, replacing whatever music is already playing.  The
+// old music fades out while the new music fades in over fade.
+func (m *Mixer) PlayMusic(s Stream, volume float64, fade time.Duration) *Voice {
+	m.StopMusic(fade)
+	v := m.makeVoice(makeStreamSource(s), MusicGroup, volume, 0, 1)
+	v.gain = 0
+	v.setFade(1, fade, false)
+	m.start(v)
+	m.mutex.Lock()
+	m.music = v
+	m.mutex.Unlock()
+	return v
+}
+
+// Fades out the music started by PlayMusic() over fade.
+func (m *Mixer) StopMusic(fade time.Duration) {
+	m.mutex.Lock()
+	music := m.music
+	m.music = nil
+	m.mutex.Unlock()
+	if music != nil {
+		music.FadeOut(fade)
+	}
+}
+
+// A source provides frames to a voice one at a time.
+type source interface {
+	sampleRate() int
+
+	// Returns the next frame, ok is false at the end.
+	frame() (left, right float32, ok bool)
+
+	close()
+}
+
+type soundSource struct {
+	sound *Sound
+	loop  bool
+	pos   int
+}
+
+func (ss *soundSource) sampleRate() int {
+	return ss.sound.rate
+}
+
+func (ss *soundSource) frame() (float32, float32, bool) {
+	if ss.pos >= len(ss.sound.samples) {
+		if !ss.loop || len(ss.sound.samples) == 0 {
+			return 0, 0, false
+		}
+		ss.pos = 0
+	}
+	ss.pos += 2
+	return ss.sound.samples[ss.pos-2], ss.sound.samples[ss.pos-1], true
+}
+
+func (ss *soundSource) close() {}
+
+// Reads a stream on its own goroutine a little ahead of the mixer so that the
+// mixer never waits on a slow disk.
+type streamSource struct {
+	rate   int
+	chunks chan []float32
+	chunk  []float32
+	quit   chan struct{}
+}
+
+func makeStreamSource(s Stream) *streamSource {
+	ss := &streamSource{
+		rate:   s.SampleRate(),
+		chunks: make(chan []float32, 8),
+		quit:   make(chan struct{}),
+	}
+	go ss.read(s)
+	return ss
+}
+
+func (ss *streamSource) read(s Stream) {
+	defer close(ss.chunks)
+	if c, ok := s.(io.Closer); ok {
+		defer c.Close()
+	}
+	channels := s.Channels()
+	if channels <= 0 {
+		return
+	}
+	buf := make([]float32, 4096*channels)
+	for {
+		n, err := s.Read(buf)
+		if n > 0 {
+			select {
+			case ss.chunks <- appendStereo(nil, buf[:n], channels):
+			case <-ss.quit:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (ss *streamSource) sampleRate() int {
+	return ss.rate
+}
+
+func (ss *streamSource) frame() (float32, float32, bool) {
+	for len(ss.chunk) == 0 {
+		select {
+		case chunk, ok := <-ss.chunks:
+			if !ok {
+				return 0, 0, false
+			}
+			ss.chunk = chunk
+		default:
+			// The reader has fallen behind, play silence until it catches up.
+			return 0, 0, true
+		}
+	}
+	left, right := ss.chunk[0], ss.chunk[1]
+	ss.chunk = ss.chunk[2:]
+	return left, right, true
+}
+
+func (ss *streamSource) close() {
+	close(ss.quit)
+}
+
+// A Voice is one sound playing on a Mixer.
+type Voice struct {
+	mixer *Mixer
+	src   source
+	group string
+
+	volume, pan, pitch float64
+
+	// gain moves towards fade_to by fade_step every frame, and the voice stops
+	// when it gets there if stop is set.
+	gain, fade_to, fade_step float64
+	stop                     bool
+
+	// Position between prev and next, which are consecutive frames from src.
+	pos        float64
+	prev, next [2]float32
+
+	done     bool
+	finished bool
+}
+
+// Mixes len(buf)/2 frames of the voice into buf.  Resamples by linearly
+// interpolating between frames, which is cheap and good enough for games.
+func (v *Voice) mix(buf []float32, volume float64) {
+	if v.done {
+		return
+	}
+	step := float64(v.src.sampleRate()) / float64(v.mixer.rate) * v.pitch
+	if step <= 0 {
+		return
+	}
+	left_pan, right_pan := 1.0, 1.0
+	if v.pan > 0 {
+		left_pan = 1 - v.pan
+	} else if v.pan < 0 {
+		right_pan = 1 + v.pan
+	}
+	for i := 0; i < len(buf); i += 2 {
+		for v.pos >= 1 {
+			l, r, ok := v.src.frame()
+			if !ok {
+				v.done = true
+				return
+			}
+			v.prev = v.next
+			v.next = [2]float32{l, r}
+			v.pos--
+		}
+		t := float32(v.pos)
+		gain := float32(volume * v.volume * v.gain)
+		buf[i] += (v.prev[0] + (v.next[0]-v.prev[0])*t) * gain * float32(left_pan)
+		buf[i+1] += (v.prev[1] + (v.next[1]-v.prev[1])*t) * gain * float32(right_pan)
+		v.pos += step
+
+		if v.gain != v.fade_to {
+			v.gain += v.fade_step
+			if (v.fade_step > 0 && v.gain >= v.fade_to) || (v.fade_step < 0 && v.gain <= v.fade_to) {
+				v.gain = v.fade_to
+			}
+		}
+		if v.stop && v.gain == v.fade_to {
+			v.done = true
+			return
+		}
+	}
+}
+
+func (v *Voice) finish() {
+	if !v.finished {
+		v.finished = true
+		v.done = true
+		v.src.close()
+	}
+}
+
+// Starts fading the voice from its current gain to to over d, the mixer's
+// mutex must be held if the voice has been started.
+func (v *Voice) setFade(to float64, d time.Duration, stop bool) {
+	v.fade_to = to
+	v.stop = stop
+	frames := d.Seconds() * float64(v.mixer.rate)
+	if frames < 1 {
+		v.gain = to
+		v.fade_step = 0
+		return
+	}
+	v.fade_step = (to - v.gain) / frames
+}
+
+func (v *Voice) SetVolume(volume float64) {
+	v.mixer.mutex.Lock()
+	defer v.mixer.mutex.Unlock()
+	v.volume = volume
+}
+
+func (v *Voice) SetPan(pan float64) {
+	v.mixer.mutex.Lock()
+	defer v.mixer.mutex.Unlock()
+	v.pan = pan
+}
+
+func (v *Voice) SetPitch(pitch float64) {
+	v.mixer.mutex.Lock()
+	defer v.mixer.mutex.Unlock()
+	v.pitch = pitch
+}
+
+// Stops the voice right away.
+func (v *Voice) Stop() {
+	v.mixer.mutex.Lock()
+	defer v.mixer.mutex.Unlock()
+	v.done = true
+}
+
+// Fades the voice out over d and then stops it.
+func (v *Voice) FadeOut(d time.Duration) {
+	v.mixer.mutex.Lock()
+	defer v.mixer.mutex.Unlock()
+	v.setFade(0, d, true)
+}
+
+// Returns true until the voice has finished playing or been stopped.
+func (v *Voice) Playing() bool {
+	v.mixer.mutex.Lock()
+	defer v.mixer.mutex.Unlock()
+	return !v.done
+}
@@ -0,0 +1,181 @@
+package sound_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sound"
+	"io"
+	"time"
+)
+
+// An output that hands each block it is given to the test and waits for the
+// test to ask for the next one, so that tests can change things between
+// blocks.
+type captureOutput struct {
+	rate   int
+	blocks chan []float32
+	resume chan bool
+}
+
+func makeCaptureOutput(rate int) *captureOutput {
+	return &captureOutput{rate: rate, blocks: make(chan []float32), resume: make(chan bool)}
+}
+
+func (co *captureOutput) SampleRate() int {
+	return co.rate
+}
+
+func (co *captureOutput) Write(samples []float32) error {
+	co.blocks <- append([]float32(nil), samples...)
+	<-co.resume
+	return nil
+}
+
+func (co *captureOutput) Close() {}
+
+// Returns the block that the mixer is currently waiting on, and lets the
+// mixer go on to mix the next one once the test is done with it.
+func (co *captureOutput) next() []float32 {
+	co.resume <- true
+	return <-co.blocks
+}
+
+func (co *captureOutput) close(m *sound.Mixer) {
+	go func() {
+		for range co.blocks {
+		}
+	}()
+	co.resume <- true
+	m.Close()
+}
+
+// A stream of a constant mono value.
+type constStream struct {
+	rate   int
+	value  float32
+	frames int
+}
+
+func (cs *constStream) SampleRate() int { return cs.rate }
+func (cs *constStream) Channels() int   { return 1 }
+func (cs *constStream) Read(buf []float32) (int, error) {
+	if cs.frames == 0 {
+		return 0, io.EOF
+	}
+	n := len(buf)
+	if n > cs.frames {
+		n = cs.frames
+	}
+	for i := 0; i < n; i++ {
+		buf[i] = cs.value
+	}
+	cs.frames -= n
+	return n, nil
+}
+
+func makeConstSound(rate int, value float32, frames int) *sound.Sound {
+	s, err := sound.MakeSound(&constStream{rate: rate, value: value, frames: frames})
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func MixerSpec(c gospec.Context) {
+	out := makeCaptureOutput(1000)
+	m := sound.MakeMixer(out)
+	<-out.blocks
+	defer out.close(m)
+
+	c.Specify("Sounds are mixed at their volume and pan", func() {
+		m.Play(makeConstSound(1000, 0.5, 10000), "", 0.5, 0.5, 1)
+		block := out.next()
+		c.Expect(block[0], Equals, float32(0.125))
+		c.Expect(block[1], Equals, float32(0.25))
+		c.Expect(block[len(block)-1], Equals, float32(0.25))
+	})
+	c.Specify("Sounds stop when they are done", func() {
+		v := m.Play(makeConstSound(1000, 0.5, 100), "", 1, 0, 1)
+		block := out.next()
+		c.Expect(block[2*50], Equals, float32(0.5))
+		c.Expect(block[2*200], Equals, float32(0))
+		out.next()
+		c.Expect(v.Playing(), Equals, false)
+	})
+	c.Specify("Group volumes apply to every sound in the group", func() {
+		m.SetGroupVolume("sfx", 0.5)
+		m.Play(makeConstSound(1000, 0.25, 10000), "sfx", 1, 0, 1)
+		m.Play(makeConstSound(1000, 0.25, 10000), "sfx", 1, 0, 1)
+		m.Play(makeConstSound(1000, 0.25, 10000), "", 1, 0, 1)
+		block := out.next()
+		c.Expect(block[0], Equals, float32(0.5))
+		c.Expect(m.GroupVolume("sfx"), Equals, 0.5)
+		c.Expect(m.GroupVolume("other"), Equals, 1.0)
+	})
+	c.Specify("Sounds are resampled to the output rate", func() {
+		v := m.Play(makeConstSound(500, 0.5, 400), "", 1, 0, 1)
+		out.next()
+		c.Expect(v.Playing(), IsTrue)
+		out.next()
+		c.Expect(v.Playing(), Equals, false)
+	})
+	c.Specify("Pitch changes how fast sounds play", func() {
+		v := m.Play(makeConstSound(1000, 0.5, 800), "", 1, 0, 2)
+		out.next()
+		c.Expect(v.Playing(), Equals, false)
+	})
+	c.Specify("Looping sounds play until stopped", func() {
+		v := m.PlayLoop(makeConstSound(1000, 0.5, 10), "", 1, 0, 1)
+		block := out.next()
+		c.Expect(block[len(block)-1], Equals, float32(0.5))
+		v.Stop()
+		block = out.next()
+		c.Expect(block[0], Equals, float32(0))
+	})
+	c.Specify("Output is clipped", func() {
+		m.Play(makeConstSound(1000, 0.75, 1000), "", 1, 0, 1)
+		m.Play(makeConstSound(1000, 0.75, 1000), "", 1, 0, 1)
+		c.Expect(out.next()[0], Equals, float32(1))
+	})
+}
+
+func MusicSpec(c gospec.Context) {
+	out := makeCaptureOutput(1000)
+	m := sound.MakeMixer(out)
+	<-out.blocks
+	defer out.close(m)
+
+	// Waits until the stream's reader has gotten started so that the mixer
+	// doesn't mix silence while it waits.
+	settle := func() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Specify("Streams play", func() {
+		v := m.PlayStream(&constStream{rate: 1000, value: 0.5, frames: 600}, "", 1)
+		settle()
+		block := out.next()
+		c.Expect(block[0], Equals, float32(0.5))
+		out.next()
+		out.next()
+		c.Expect(v.Playing(), Equals, false)
+	})
+	c.Specify("Music crossfades", func() {
+		first := m.PlayMusic(&constStream{rate: 1000, value: 0.5, frames: 100000}, 1, 0)
+		settle()
+		c.Expect(out.next()[0], Equals, float32(0.5))
+		second := m.PlayMusic(&constStream{rate: 1000, value: 0.25, frames: 100000}, 1, 1024*time.Millisecond)
+		settle()
+		block := out.next()
+		c.Expect(block[0], Equals, float32(0.5))
+		c.Expect(block[len(block)-2] < 0.5, IsTrue)
+		c.Expect(block[len(block)-2] > 0.25, IsTrue)
+		out.next()
+		out.next()
+		c.Expect(first.Playing(), Equals, false)
+		c.Expect(out.next()[0], Equals, float32(0.25))
+		m.StopMusic(0)
+		out.next()
+		c.Expect(second.Playing(), Equals, false)
+	})
+}
@@ -0,0 +1,29 @@
+package sound
+
+import (
+	"time"
+)
+
+// NullOutput plays nothing, but takes as long to do it as a real device
+// would.  Useful for servers, tests, and machines without audio.
+type NullOutput struct {
+	Rate int
+
+	next time.Time
+}
+
+func (n *NullOutput) SampleRate() int {
+	return n.Rate
+}
+
+func (n *NullOutput) Write(samples []float32) error {
+	now := time.Now()
+	if n.next.Before(now) {
+		n.next = now
+	}
+	n.next = n.next.Add(time.Duration(len(samples)/2) * time.Second / time.Duration(n.Rate))
+	time.Sleep(n.next.Sub(now))
+	return nil
+}
+
+func (n *NullOutput) Close() {}
@@ -0,0 +1,211 @@
+package sound
+
+import (
+	"math"
+	"sync"
+)
+
+// A Listener is where sounds are heard from in a 2d world.  Sounds played
+// through an Emitter get quieter the further the emitter is from the
+// listener, and are panned towards the side of the listener that they are
+// on.  Like the Mixer, all of the methods on Listeners and Emitters are safe
+// to call from any goroutine.
+type Listener struct {
+	mixer *Mixer
+	mutex sync.Mutex
+	x, y  float64
+
+	// Sounds closer than near are at full volume, sounds further than far
+	// can't be heard at all, and in between they fade linearly.
+	near, far float64
+
+	emitters map[*Emitter]bool
+}
+
+// Makes a listener at 0, 0 that plays through m.  See SetRange() for near
+// and far.
+func MakeListener(m *Mixer, near, far float64) *Listener {
+	l := &Listener{
+		mixer:    m,
+		emitters: make(map[*Emitter]bool),
+	}
+	l.SetRange(near, far)
+	return l
+}
+
+// Sets how far away sounds can be heard.  Sounds closer than near are at
+// full volume and sounds further than far are silent.
+func (l *Listener) SetRange(near, far float64) {
+	if near < 0 {
+		near = 0
+	}
+	if far < near {
+		far = near
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.near, l.far = near, far
+	l.updateAll()
+}
+
+func (l *Listener) SetPosition(x, y float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.x, l.y = x, y
+	l.updateAll()
+}
+
+func (l *Listener) Position() (x, y float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.x, l.y
+}
+
+// Returns the volume and pan for a sound at x, y.
+func (l *Listener) place(x, y float64) (volume, pan float64) {
+	dx, dy := x-l.x, y-l.y
+	dist := math.Sqrt(dx*dx + dy*dy)
+	switch {
+	case dist <= l.near:
+		volume = 1
+	case dist >= l.far:
+		volume = 0
+	default:
+		volume = (l.far - dist) / (l.far - l.near)
+	}
+	// Pan by the direction to the sound, but keep sounds that are nearly on
+	// top of the listener close to the center.
+	if d := math.Max(dist, l.near); d > 0 {
+		pan = dx / d
+	}
+	return volume, pan
+}
+
+func (l *Listener) updateAll() {
+	for e := range l.emitters {
+		e.update()
+		l.forget(e)
+	}
+}
+
+// Stops keeping track of e once it is closed and its sounds are done.
+func (l *Listener) forget(e *Emitter) {
+	if e.closed && len(e.voices) == 0 {
+		delete(l.emitters, e)
+	}
+}
+
+// Makes an emitter at x, y whose sounds are played in group.  Call Close()
+// on it when it isn't needed any more.
+func (l *Listener) MakeEmitter(x, y float64, group string) *Emitter {
+	e := &Emitter{listener: l, x: x, y: y, group: group}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.emitters[e] = true
+	return e
+}
+
+// Plays s once at x, y, for sounds that don't need to follow anything
+// around.
+func (l *Listener) PlayAt(s *Sound, x, y float64, group string, volume, pitch float64) *Voice {
+	e := l.MakeEmitter(x, y, group)
+	v := e.Play(s, volume, pitch)
+	e.Close()
+	return v
+}
+
+// An Emitter is a place in the world that sounds come from.
+type Emitter struct {
+	listener *Listener
+	x, y     float64
+	group    string
+	closed   bool
+
+	voices []emitterVoice
+}
+
+type emitterVoice struct {
+	voice  *Voice
+	volume float64
+	loop   bool
+}
+
+// Updates the volume and pan of all of the emitter's voices, the listener's
+// mutex must be held.
+func (e *Emitter) update() {
+	volume, pan := e.listener.place(e.x, e.y)
+	playing := e.voices[:0]
+	for _, ev := range e.voices {
+		if ev.voice.Playing() {
+			ev.voice.SetVolume(ev.volume * volume)
+			ev.voice.SetPan(pan)
+			playing = append(playing, ev)
+		}
+	}
+	for i := len(playing); i < len(e.voices); i++ {
+		e.voices[i] = emitterVoice{}
+	}
+	e.voices = playing
+}
+
+func (e *Emitter) SetPosition(x, y float64) {
+	e.listener.mutex.Lock()
+	defer e.listener.mutex.Unlock()
+	e.x, e.y = x, y
+	e.update()
+	e.listener.forget(e)
+}
+
+func (e *Emitter) Position() (x, y float64) {
+	e.listener.mutex.Lock()
+	defer e.listener.mutex.Unlock()
+	return e.x, e.y
+}
+
+func (e *Emitter) play(s *Sound, volume, pitch float64, loop bool) *Voice {
+	e.listener.mutex.Lock()
+	defer e.listener.mutex.Unlock()
+	e.update()
+	placed, pan := e.listener.place(e.x, e.y)
+	src := &soundSource{sound: s, loop: loop}
+	v := e.listener.mixer.start(e.listener.mixer.makeVoice(src, e.group, volume*placed, pan, pitch))
+	e.voices = append(e.voices, emitterVoice{voice: v, volume: volume, loop: loop})
+	return v
+}
+
+// Plays s from the emitter.  Its volume and pan follow the emitter and the
+// listener around until it is done, even if the emitter is closed first.
+func (e *Emitter) Play(s *Sound, volume, pitch float64) *Voice {
+	return e.play(s, volume, pitch, false)
+}
+
+// Like Play() but plays s over and over until it is stopped or the emitter
+// is closed.
+func (e *Emitter) PlayLoop(s *Sound, volume, pitch float64) *Voice {
+	return e.play(s, volume, pitch, true)
+}
+
+// Stops all of the sounds playing from the emitter.
+func (e *Emitter) Stop() {
+	e.listener.mutex.Lock()
+	defer e.listener.mutex.Unlock()
+	for _, ev := range e.voices {
+		ev.voice.Stop()
+	}
+	e.voices = nil
+}
+
+// Stops any looping sounds on the emitter and stops keeping it up to date
+// once the rest of its sounds are done.
+func (e *Emitter) Close() {
+	e.listener.mutex.Lock()
+	defer e.listener.mutex.Unlock()
+	e.closed = true
+	for _, ev := range e.voices {
+		if ev.loop {
+			ev.voice.Stop()
+		}
+	}
+	e.update()
+	e.listener.forget(e)
+}
@@ -0,0 +1,58 @@
+package sound_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sound"
+)
+
+func PositionalSpec(c gospec.Context) {
+	out := makeCaptureOutput(1000)
+	m := sound.MakeMixer(out)
+	<-out.blocks
+	defer out.close(m)
+	l := sound.MakeListener(m, 10, 110)
+	snd := makeConstSound(1000, 0.5, 10000)
+
+	c.Specify("Sounds get quieter with distance", func() {
+		l.PlayAt(snd, 0, 5, "", 1, 1)
+		block := out.next()
+		c.Expect(block[0], Equals, float32(0.5))
+		c.Expect(block[1], Equals, float32(0.5))
+		l.SetPosition(0, -55)
+		block = out.next()
+		c.Expect(block[0], Equals, float32(0.25))
+		l.SetPosition(0, -200)
+		block = out.next()
+		c.Expect(block[0]+block[1], Equals, float32(0))
+	})
+	c.Specify("Sounds are panned towards their side", func() {
+		l.PlayAt(snd, 50, 0, "", 1, 1)
+		block := out.next()
+		c.Expect(block[0], Equals, float32(0))
+		c.Expect(block[1] > 0, IsTrue)
+		l.SetPosition(50, 50)
+		block = out.next()
+		c.Expect(block[0], Equals, block[1])
+	})
+	c.Specify("Sounds follow their emitters", func() {
+		e := l.MakeEmitter(0, 0, "")
+		e.PlayLoop(snd, 1, 1)
+		c.Expect(out.next()[0], Equals, float32(0.5))
+		e.SetPosition(-110, 0)
+		c.Expect(out.next()[0], Equals, float32(0))
+		e.SetPosition(-60, 0)
+		block := out.next()
+		c.Expect(block[0], Equals, float32(0.25))
+		c.Expect(block[1], Equals, float32(0))
+		e.Close()
+		c.Expect(out.next()[0], Equals, float32(0))
+	})
+	c.Specify("Stopping an emitter stops its sounds", func() {
+		e := l.MakeEmitter(0, 0, "")
+		v := e.Play(snd, 1, 1)
+		e.Stop()
+		out.next()
+		c.Expect(v.Playing(), Equals, false)
+	})
+}
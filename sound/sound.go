@@ -0,0 +1,197 @@
+// Package sound plays sound effects and streams music.  Everything is mixed
+// in software on a goroutine owned by a Mixer, which writes the result to an
+// Output.  gos.OpenAudio() returns an Output for the platform's audio device,
+// NullOutput can be used when there isn't one.
+package sound
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// An Output plays interleaved stereo samples at a fixed sample rate.
+type Output interface {
+	SampleRate() int
+
+	// Blocks until samples, which are pairs of left and right values from -1
+	// to 1, have been queued to be played.  Output should block for about as
+	// long as it takes to play samples so that the Mixer doesn't get ahead of
+	// it by more than a little.
+	Write(samples []float32) error
+
+	Close()
+}
+
+// A Stream is a source of decoded audio.  Streams are read from a single
+// goroutine at a time.
+type Stream interface {
+	SampleRate() int
+	Channels() int
+
+	// Reads interleaved samples from -1 to 1 into buf and returns how many were
+	// read, which is always a multiple of Channels().  Returns io.EOF at the
+	// end of the stream.
+	Read(buf []float32) (int, error)
+}
+
+// A Decoder decodes audio in some format from r.
+type Decoder func(r io.Reader) (Stream, error)
+
+var decoders = struct {
+	sync.Mutex
+	by_ext map[string]Decoder
+}{
+	by_ext: map[string]Decoder{
+		".wav": DecodeWav,
+	},
+}
+
+// Registers a decoder for files with the extension ext, like ".ogg".  WAV is
+// supported without registering anything, other formats like OGG Vorbis need
+// a decoder from a package that handles them.
+func RegisterDecoder(ext string, decoder Decoder) {
+	decoders.Lock()
+	defer decoders.Unlock()
+	decoders.by_ext[strings.ToLower(ext)] = decoder
+}
+
+type fileStream struct {
+	Stream
+	file io.Closer
+}
+
+func (f *fileStream) Close() error {
+	return f.file.Close()
+}
+
+// Opens the audio file at path for streaming, picking a decoder based on its
+// extension.  The returned Stream is also an io.Closer.  Mixers close streams
+// when they are done playing them.
+func OpenStream(path string) (Stream, error) {
+	decoder, err := findDecoder(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFile(decoder, f)
+}
+
+// Like OpenStream() but opens name in fsys, like an assets.FS.
+func OpenStreamFS(fsys fs.FS, name string) (Stream, error) {
+	decoder, err := findDecoder(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFile(decoder, f)
+}
+
+func findDecoder(path string) (Decoder, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	decoders.Lock()
+	decoder, ok := decoders.by_ext[ext]
+	decoders.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("No decoder registered for '%s' files.", ext)
+	}
+	return decoder, nil
+}
+
+func decodeFile(decoder Decoder, f io.ReadCloser) (Stream, error) {
+	s, err := decoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileStream{Stream: s, file: f}, nil
+}
+
+// A Sound is decoded audio held entirely in memory, which is what effects
+// that are played over and over should use.  Sounds are safe to play on any
+// number of voices at once.
+type Sound struct {
+	rate int
+
+	// Interleaved stereo samples.
+	samples []float32
+}
+
+// Reads all of s into a Sound.  Mono streams are played on both channels,
+// only the first two channels of streams with more are used.
+func MakeSound(s Stream) (*Sound, error) {
+	channels := s.Channels()
+	if channels <= 0 {
+		return nil, fmt.Errorf("Stream has %d channels.", channels)
+	}
+	sound := &Sound{rate: s.SampleRate()}
+	buf := make([]float32, 4096*channels)
+	for {
+		n, err := s.Read(buf)
+		sound.samples = appendStereo(sound.samples, buf[:n], channels)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sound, nil
+}
+
+// Loads the audio file at path into a Sound.
+func LoadSound(path string) (*Sound, error) {
+	s, err := OpenStream(path)
+	if err != nil {
+		return nil, err
+	}
+	defer s.(io.Closer).Close()
+	return MakeSound(s)
+}
+
+// Like LoadSound() but loads name from fsys, like an assets.FS.
+func LoadSoundFS(fsys fs.FS, name string) (*Sound, error) {
+	s, err := OpenStreamFS(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer s.(io.Closer).Close()
+	return MakeSound(s)
+}
+
+func (s *Sound) SampleRate() int {
+	return s.rate
+}
+
+// Returns the number of samples per channel in s.
+func (s *Sound) Len() int {
+	return len(s.samples) / 2
+}
+
+// Appends the samples in src, which have the given number of channels, to dst
+// as stereo.
+func appendStereo(dst, src []float32, channels int) []float32 {
+	switch channels {
+	case 1:
+		for _, v := range src {
+			dst = append(dst, v, v)
+		}
+	case 2:
+		dst = append(dst, src...)
+	default:
+		for i := 0; i+1 < len(src); i += channels {
+			dst = append(dst, src[i], src[i+1])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,152 @@
+package sound
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+)
+
+type wavStream struct {
+	r        io.Reader
+	rate     int
+	channels int
+	format   int
+	bits     int
+
+	// Bytes left in the data chunk.
+	remaining int64
+	raw       []byte
+}
+
+// Decodes a WAV file with 8, 16, 24, or 32 bit integer samples, or 32 or 64
+// bit floating point samples.  The samples are read from r as the stream is
+// read.
+func DecodeWav(r io.Reader) (Stream, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("Not a WAV file.")
+	}
+	ws := &wavStream{r: r}
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("WAV file has no data chunk.")
+			}
+			return nil, err
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("WAV fmt chunk is too short.")
+			}
+			fmt_chunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, fmt_chunk); err != nil {
+				return nil, err
+			}
+			ws.format = int(binary.LittleEndian.Uint16(fmt_chunk[0:]))
+			ws.channels = int(binary.LittleEndian.Uint16(fmt_chunk[2:]))
+			ws.rate = int(binary.LittleEndian.Uint32(fmt_chunk[4:]))
+			ws.bits = int(binary.LittleEndian.Uint16(fmt_chunk[14:]))
+			if ws.format == wavFormatExtensible && size >= 26 {
+				// The real format is the start of the subformat guid.
+				ws.format = int(binary.LittleEndian.Uint16(fmt_chunk[24:]))
+			}
+
+		case "data":
+			if ws.channels == 0 {
+				return nil, fmt.Errorf("WAV data chunk comes before the fmt chunk.")
+			}
+			if err := ws.check(); err != nil {
+				return nil, err
+			}
+			ws.remaining = size
+			return ws, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (ws *wavStream) check() error {
+	if ws.channels <= 0 || ws.rate <= 0 {
+		return fmt.Errorf("WAV file has %d channels at %dHz.", ws.channels, ws.rate)
+	}
+	switch {
+	case ws.format == wavFormatPCM && (ws.bits == 8 || ws.bits == 16 || ws.bits == 24 || ws.bits == 32):
+	case ws.format == wavFormatFloat && (ws.bits == 32 || ws.bits == 64):
+	default:
+		return fmt.Errorf("Unsupported WAV format %d with %d bit samples.", ws.format, ws.bits)
+	}
+	return nil
+}
+
+func (ws *wavStream) SampleRate() int {
+	return ws.rate
+}
+
+func (ws *wavStream) Channels() int {
+	return ws.channels
+}
+
+func (ws *wavStream) Read(buf []float32) (int, error) {
+	bytes := ws.bits / 8
+	frame := bytes * ws.channels
+	frames := len(buf) / ws.channels
+	if max := int(ws.remaining / int64(frame)); frames > max {
+		frames = max
+	}
+	if frames == 0 {
+		return 0, io.EOF
+	}
+	if cap(ws.raw) < frames*frame {
+		ws.raw = make([]byte, frames*frame)
+	}
+	raw := ws.raw[:frames*frame]
+	n, err := io.ReadFull(ws.r, raw)
+	if err == io.ErrUnexpectedEOF {
+		// Files that were cut short are common enough, just play what is there.
+		err = nil
+		ws.remaining = 0
+	} else {
+		ws.remaining -= int64(n)
+	}
+	n -= n % frame
+	samples := n / bytes
+	for i := 0; i < samples; i++ {
+		b := raw[i*bytes:]
+		switch {
+		case ws.format == wavFormatFloat && bytes == 4:
+			buf[i] = math.Float32frombits(binary.LittleEndian.Uint32(b))
+		case ws.format == wavFormatFloat:
+			buf[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		case bytes == 1:
+			buf[i] = (float32(b[0]) - 128) / 128
+		case bytes == 2:
+			buf[i] = float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+		case bytes == 3:
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			buf[i] = float32(v) / (1 << 23)
+		default:
+			buf[i] = float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+		}
+	}
+	if samples == 0 && err == nil {
+		err = io.EOF
+	}
+	return samples, err
+}
@@ -0,0 +1,109 @@
+package sound_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sound"
+	"io"
+	"testing/fstest"
+)
+
+// Makes a WAV file with the given format and raw sample data.
+func makeWav(format, channels, rate, bits int, data []byte) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("RIFF")
+	binary.Write(&b, le, uint32(36+len(data)))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, le, uint32(16))
+	binary.Write(&b, le, uint16(format))
+	binary.Write(&b, le, uint16(channels))
+	binary.Write(&b, le, uint32(rate))
+	binary.Write(&b, le, uint32(rate*channels*bits/8))
+	binary.Write(&b, le, uint16(channels*bits/8))
+	binary.Write(&b, le, uint16(bits))
+	b.WriteString("LIST")
+	binary.Write(&b, le, uint32(3))
+	b.WriteString("abc\x00")
+	b.WriteString("data")
+	binary.Write(&b, le, uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func readAll(s sound.Stream) []float32 {
+	var all []float32
+	buf := make([]float32, 3*s.Channels())
+	for {
+		n, err := s.Read(buf)
+		all = append(all, buf[:n]...)
+		if err != nil {
+			return all
+		}
+	}
+}
+
+func WavSpec(c gospec.Context) {
+	c.Specify("16 bit stereo WAVs decode", func() {
+		var data bytes.Buffer
+		for _, v := range []int16{0, 16384, -16384, -32768, 8192, 0} {
+			binary.Write(&data, binary.LittleEndian, v)
+		}
+		s, err := sound.DecodeWav(bytes.NewReader(makeWav(1, 2, 22050, 16, data.Bytes())))
+		c.Assume(err, Equals, nil)
+		c.Expect(s.SampleRate(), Equals, 22050)
+		c.Expect(s.Channels(), Equals, 2)
+		c.Expect(readAll(s), ContainsInOrder, []float32{0, 0.5, -0.5, -1, 0.25, 0})
+	})
+	c.Specify("8 and 24 bit WAVs decode", func() {
+		s, err := sound.DecodeWav(bytes.NewReader(makeWav(1, 1, 8000, 8, []byte{128, 192, 0})))
+		c.Assume(err, Equals, nil)
+		c.Expect(readAll(s), ContainsInOrder, []float32{0, 0.5, -1})
+		s, err = sound.DecodeWav(bytes.NewReader(makeWav(1, 1, 8000, 24, []byte{0, 0, 0x40, 0, 0, 0xc0})))
+		c.Assume(err, Equals, nil)
+		c.Expect(readAll(s), ContainsInOrder, []float32{0.5, -0.5})
+	})
+	c.Specify("Float WAVs decode", func() {
+		var data bytes.Buffer
+		binary.Write(&data, binary.LittleEndian, []float32{0.25, -0.75})
+		s, err := sound.DecodeWav(bytes.NewReader(makeWav(3, 1, 8000, 32, data.Bytes())))
+		c.Assume(err, Equals, nil)
+		c.Expect(readAll(s), ContainsInOrder, []float32{0.25, -0.75})
+	})
+	c.Specify("Truncated WAVs play what is there", func() {
+		wav := makeWav(1, 1, 8000, 8, []byte{128, 192, 0})
+		s, err := sound.DecodeWav(bytes.NewReader(wav[:len(wav)-1]))
+		c.Assume(err, Equals, nil)
+		c.Expect(readAll(s), ContainsInOrder, []float32{0, 0.5})
+	})
+	c.Specify("Bad WAVs are rejected", func() {
+		_, err := sound.DecodeWav(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI ")))
+		c.Expect(err, Not(Equals), nil)
+		_, err = sound.DecodeWav(bytes.NewReader(makeWav(2, 1, 8000, 4, nil)))
+		c.Expect(err, Not(Equals), nil)
+		_, err = sound.DecodeWav(bytes.NewReader(nil))
+		c.Expect(err, Equals, io.EOF)
+	})
+	c.Specify("Sounds are converted to stereo", func() {
+		s, _ := sound.DecodeWav(bytes.NewReader(makeWav(1, 1, 8000, 8, []byte{128, 192, 0})))
+		snd, err := sound.MakeSound(s)
+		c.Assume(err, Equals, nil)
+		c.Expect(snd.Len(), Equals, 3)
+		c.Expect(snd.SampleRate(), Equals, 8000)
+	})
+	c.Specify("Sounds load from any fs.FS", func() {
+		fsys := fstest.MapFS{
+			"sfx/blip.WAV": &fstest.MapFile{Data: makeWav(1, 1, 8000, 8, []byte{128, 192, 0})},
+			"sfx/blip.mp3": &fstest.MapFile{},
+		}
+		snd, err := sound.LoadSoundFS(fsys, "sfx/blip.WAV")
+		c.Assume(err, Equals, nil)
+		c.Expect(snd.Len(), Equals, 3)
+		_, err = sound.LoadSoundFS(fsys, "sfx/blip.mp3")
+		c.Expect(err, Not(Equals), nil)
+		_, err = sound.LoadSoundFS(fsys, "sfx/missing.wav")
+		c.Expect(err, Not(Equals), nil)
+	})
+}
@@ -10,5 +10,19 @@ func TestAllSpecs(t *testing.T) {
   r.AddSpec(LoadSpriteSpec)
   r.AddSpec(CommandNSpec)
   r.AddSpec(SyncSpec)
+  r.AddSpec(DecodePolicySpec)
+  r.AddSpec(CommandAllSpec)
+  r.AddSpec(ForceSpec)
+  r.AddSpec(TimelineSpec)
+  r.AddSpec(AnchorSpec)
+  r.AddSpec(FacingSpec)
+  r.AddSpec(IdleSpec)
+  r.AddSpec(TintSpec)
+  r.AddSpec(DebugInfoSpec)
+  r.AddSpec(CacheSpec)
+  r.AddSpec(GraphsSpec)
+  r.AddSpec(EdgeWeightSpec)
+  r.AddSpec(CatchUpSpec)
+  r.AddSpec(ConcurrencySpec)
   gospec.MainGoTest(r, t)
 }
@@ -0,0 +1,97 @@
+package sprite
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/runningwild/yedparse"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// Anchors are named points on frames, like where a hand or the muzzle of a
+// gun is, so that other things can be drawn attached to them as the sprite
+// animates.  They are listed in an anchors.json file in each facing
+// directory, by frame and then by name, in pixels from the top left corner
+// of the frame's image:
+//
+//	{
+//	  "ready_01": {"hand": [62, 80], "head": [50, 12]},
+//	  "walk_01":  {"hand": [60, 84]}
+//	}
+//
+// Frames without an entry have no anchors in that facing.
+type anchors map[frameId]map[string][2]int
+
+// Reads anchors.json from each facing directory that has one, and checks
+// that every anchor is on a frame that has an image and is inside of it.
+// rects has the rectangles of all of the frames in all of the sheets.
+func loadAnchors(fsys fs.FS, anim *yed.Graph, num_facings int, rects map[frameId]FrameRect) (anchors, error) {
+	ids := make(map[string]int)
+	for i := 0; i < anim.NumNodes(); i++ {
+		ids[anim.Node(i).Line(0)] = anim.Node(i).Id()
+	}
+	all := make(anchors)
+	for facing := 0; facing < num_facings; facing++ {
+		name := path.Join(fmt.Sprintf("%d", facing), "anchors.json")
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			// Facings don't need to have any anchors.
+			continue
+		}
+		var frames map[string]map[string][2]int
+		if err := json.Unmarshal(data, &frames); err != nil {
+			return nil, &spriteError{fmt.Sprintf("Can't read %s: %v", name, err)}
+		}
+		var names []string
+		for frame := range frames {
+			names = append(names, frame)
+		}
+		sort.Strings(names)
+		for _, frame := range names {
+			id, ok := ids[frame]
+			if !ok {
+				return nil, &spriteError{fmt.Sprintf("%s has anchors for '%s', which isn't in the anim graph", name, frame)}
+			}
+			fid := frameId{facing: facing, node: id}
+			rect, ok := rects[fid]
+			if !ok {
+				return nil, &spriteError{fmt.Sprintf("%s has anchors for '%s', which has no image in facing %d", name, frame, facing)}
+			}
+			dx, dy := rect.X2-rect.X, rect.Y2-rect.Y
+			for anchor, p := range frames[frame] {
+				if p[0] < 0 || p[1] < 0 || p[0] >= dx || p[1] >= dy {
+					return nil, &spriteError{fmt.Sprintf("%s has anchor '%s' on '%s' at %v, outside of its %dx%d image", name, anchor, frame, p, dx, dy)}
+				}
+			}
+			all[fid] = frames[frame]
+		}
+	}
+	return all, nil
+}
+
+// Returns where the anchor called name is on the frame that s is showing, in
+// pixels from the bottom left corner of the frame, which is the same space
+// that Dims() is in.  Anchors on mirrored facings are mirrored too.  ok is false if the frame has no such anchor.
+func (s *Sprite) Anchor(name string) (x, y int, ok bool) {
+	p, ok := s.shared.anchors[s.frameId()][name]
+	if !ok {
+		return 0, 0, false
+	}
+	dx, dy := s.Dims()
+	if s.facingAsset(s.facing).Mirror {
+		p[0] = dx - 1 - p[0]
+	}
+	return p[0], dy - 1 - p[1], true
+}
+
+// Returns the names of the anchors on the frame that s is showing, sorted
+// alphabetically so that the order doesn't change from one call to the next.
+func (s *Sprite) Anchors() []string {
+	var names []string
+	for name := range s.shared.anchors[s.frameId()] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,73 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Copies the test sprite somewhere that it can be changed.
+func copyTestSprite(dst string) error {
+	return filepath.WalkDir("test_sprite", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel("test_sprite", path)
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		if filepath.Ext(path) == ".gob" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+}
+
+func AnchorSpec(c gospec.Context) {
+	c.Specify("Anchors are relative to the bottom left of the current frame.", func() {
+		s, err := sprite.LoadSprite("test_sprite")
+		c.Assume(err, Equals, nil)
+		c.Assume(s.Anim(), Equals, "ready_01")
+		x, y, ok := s.Anchor("hand")
+		c.Expect(ok, Equals, true)
+		c.Expect(x, Equals, 70)
+		c.Expect(y, Equals, 59)
+		c.Expect(s.Anchors(), ContainsInOrder, []string{"hand", "head"})
+		_, _, ok = s.Anchor("foot")
+		c.Expect(ok, Equals, false)
+	})
+	c.Specify("Anchors change with the facing.", func() {
+		s, err := sprite.LoadSprite("test_sprite")
+		c.Assume(err, Equals, nil)
+		s.Command("turn_right")
+		for i := 0; i < 100 && (s.Facing() != 1 || s.Anim() != "ready_01"); i++ {
+			s.Think(50)
+		}
+		c.Assume(s.Facing(), Equals, 1)
+		c.Assume(s.Anim(), Equals, "ready_01")
+		x, _, ok := s.Anchor("hand")
+		c.Expect(ok, Equals, true)
+		c.Expect(x, Equals, 30)
+		_, _, ok = s.Anchor("head")
+		c.Expect(ok, Equals, false)
+	})
+	c.Specify("Anchors outside of their frame are an error.", func() {
+		dir, err := os.MkdirTemp("", "anchors")
+		c.Assume(err, Equals, nil)
+		defer os.RemoveAll(dir)
+		c.Assume(copyTestSprite(dir), Equals, nil)
+		c.Assume(os.WriteFile(filepath.Join(dir, "0", "anchors.json"), []byte(`{"ready_01": {"hand": [100, 0]}}`), 0644), Equals, nil)
+		_, err = sprite.LoadSprite(dir)
+		c.Expect(err, Not(Equals), nil)
+		c.Assume(os.WriteFile(filepath.Join(dir, "0", "anchors.json"), []byte(`{"nothing_01": {"hand": [0, 0]}}`), 0644), Equals, nil)
+		_, err = sprite.MakeManager().LoadSprite(dir)
+		c.Expect(err, Not(Equals), nil)
+	})
+}
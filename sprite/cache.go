@@ -0,0 +1,127 @@
+package sprite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/runningwild/memory"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sheets cached on disk start with this magic and version, followed by the
+// dimensions of the sheet and then its pixels.  The version must be bumped
+// whenever the layout of the pixels changes, so that caches written by older
+// loaders are regenerated instead of being drawn wrong.
+const cacheMagic = "glss"
+const cacheVersion = 1
+
+type cacheHeader struct {
+	Magic   [4]byte
+	Version uint32
+	Dx, Dy  int32
+}
+
+// Returns the pixels of s from the cache in the sprite's directory.  Caches
+// that are missing, truncated, were written by another version, or are for a
+// sheet of a different size, return an error and should be regenerated.
+func (s *sheet) readCache() ([]byte, error) {
+	f, err := s.fsys.Open(s.name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var header cacheHeader
+	if err := binary.Read(f, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != cacheMagic || header.Version != cacheVersion {
+		return nil, fmt.Errorf("%s is not a version %d sheet cache.", s.name, cacheVersion)
+	}
+	if int(header.Dx) != s.dx || int(header.Dy) != s.dy {
+		return nil, fmt.Errorf("%s is %dx%d, expected %dx%d.", s.name, header.Dx, header.Dy, s.dx, s.dy)
+	}
+	b := memory.GetBlock(4 * s.dx * s.dy)
+	if _, err := io.ReadFull(f, b); err != nil {
+		memory.FreeBlock(b)
+		return nil, err
+	}
+	return b, nil
+}
+
+// Caches pix in the sprite's directory.  It's written to a temporary file
+// first so that a crash part way through can't leave a truncated cache.  The
+// temporary file is hidden, so loading the sprite ignores it even if another
+// loader is part way through writing it or a crash left it behind.
+// CreateTemp makes files only the owner can read, so the cache is given the
+// permissions os.Create would give it under the usual umask before it's
+// renamed into place.
+func (s *sheet) writeCache(pix []byte) {
+	filename := filepath.Join(s.dir, s.name)
+	f, err := os.CreateTemp(s.dir, "."+s.name+".*")
+	if err != nil {
+		return
+	}
+	tmp := f.Name()
+	var header cacheHeader
+	copy(header.Magic[:], cacheMagic)
+	header.Version = cacheVersion
+	header.Dx, header.Dy = int32(s.dx), int32(s.dy)
+	err = binary.Write(f, binary.LittleEndian, header)
+	if err == nil {
+		_, err = f.Write(pix)
+	}
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filename)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// Removes the sheets cached in the directory of the sprite at path, so that
+// they are decoded from the frames again the next time they are loaded.
+// Sheets that m's sprites are keeping decoded, see DecodePolicy, are freed
+// as well.  This is only needed when frames are changed in ways that don't
+// change their size or modification time, since caches are regenerated
+// automatically otherwise.  Textures are loaded in the background, so a sheet
+// that is still loading when this is called may be decoded and cached again.
+func (m *Manager) InvalidateCache(path string) error {
+	path = filepath.Clean(path)
+	m.mutex.Lock()
+	for key, ss := range m.shared {
+		if key.fsys != nil || key.path != path {
+			continue
+		}
+		for _, sh := range ss.sheets() {
+			sh.mutex.Lock()
+			sh.pixels = nil
+			sh.mutex.Unlock()
+		}
+	}
+	m.mutex.Unlock()
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".gob") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func InvalidateCache(path string) error {
+	return the_manager.InvalidateCache(path)
+}
@@ -0,0 +1,87 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func cachedSheets(dir string) []string {
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".gob") {
+			names = append(names, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return names
+}
+
+func CacheSpec(c gospec.Context) {
+	dir, err := os.MkdirTemp("", "cache")
+	c.Assume(err, Equals, nil)
+	defer os.RemoveAll(dir)
+	c.Assume(copyTestSprite(dir), Equals, nil)
+	m := sprite.MakeManager()
+	m.SetDecodePolicy(sprite.Eager)
+	_, err = m.LoadSprite(dir)
+	c.Assume(err, Equals, nil)
+	caches := cachedSheets(dir)
+	c.Assume(len(caches) > 0, Equals, true)
+	c.Specify("Corrupt caches are regenerated from the frames.", func() {
+		for _, name := range caches {
+			c.Assume(os.WriteFile(name, []byte{4, 0, 0, 0, 1, 2}, 0644), Equals, nil)
+		}
+		m2 := sprite.MakeManager()
+		m2.SetDecodePolicy(sprite.Eager)
+		_, err := m2.LoadSprite(dir)
+		c.Assume(err, Equals, nil)
+		pixels, _ := m2.ResidentBytes()
+		c.Expect(pixels > 0, Equals, true)
+		for _, name := range caches {
+			info, err := os.Stat(name)
+			c.Assume(err, Equals, nil)
+			c.Expect(info.Size() > 6, Equals, true)
+		}
+	})
+	c.Specify("InvalidateCache removes the caches and frees decoded sheets.", func() {
+		// The connector sheet's texture loads in the background, and if that
+		// starts after InvalidateCache it decodes and caches the sheet again,
+		// so keep invalidating until the load is out of the way.
+		var pixels int64
+		for i := 0; i < 100; i++ {
+			c.Assume(m.InvalidateCache(dir), Equals, nil)
+			time.Sleep(time.Millisecond)
+			pixels, _ = m.ResidentBytes()
+			if pixels == 0 && len(cachedSheets(dir)) == 0 {
+				break
+			}
+		}
+		c.Expect(len(cachedSheets(dir)), Equals, 0)
+		c.Expect(pixels, Equals, int64(0))
+	})
+	c.Specify("A sprite can be loaded while its caches are being written.", func() {
+		c.Assume(m.InvalidateCache(dir), Equals, nil)
+		errs := make(chan error, 8)
+		for i := 0; i < cap(errs); i++ {
+			go func() {
+				m := sprite.MakeManager()
+				m.SetDecodePolicy(sprite.Eager)
+				_, err := m.LoadSprite(dir)
+				errs <- err
+			}()
+		}
+		for i := 0; i < cap(errs); i++ {
+			c.Expect(<-errs, Equals, nil)
+		}
+		c.Expect(len(cachedSheets(dir)), Equals, len(caches))
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			c.Expect(strings.HasPrefix(entry.Name(), "."), Equals, false)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package sprite
+
+// A CatchUpPolicy says what Think() does when it is given more time than the
+// sprite's current frame has left, which happens a frame at a time, and can
+// be a lot of frames after a long pause like the game being in the
+// background or stopped in a debugger.  The zero value goes through every
+// frame and fires every trigger along the way, which is the default.
+type CatchUpPolicy struct {
+	// If this is more than 0 then Think() never moves the sprite along more
+	// than this many milliseconds, the rest of dt is dropped.
+	MaxDt int64
+
+	// If this is more than 0 then Think() never goes through more than this
+	// many frames, the rest of dt is dropped once the sprite gets to the last
+	// one.
+	MaxFrames int
+
+	// If this is true then when a Think() goes through more than one frame
+	// only the trigger and sound for the frame that the sprite ends up on are
+	// fired.
+	SkipTriggers bool
+}
+
+// Sets what Think() does when it is given more time than the current frame
+// has left, starting with the next call to Think().
+func (s *Sprite) SetCatchUpPolicy(policy CatchUpPolicy) {
+	s.catch_up = policy
+}
+
+func (s *Sprite) CatchUpPolicy() CatchUpPolicy {
+	return s.catch_up
+}
+
+// Called each time think() moves the sprite to a new frame.  Fires the
+// frame's trigger, unless SkipTriggers says to wait until Think() is done.
+func (s *Sprite) enterFrame() {
+	s.frames++
+	if s.catch_up.SkipTriggers {
+		s.skipped_trigger = true
+		return
+	}
+	s.doTrigger()
+}
+
+// Returns true if think() has gone through as many frames as the catch up
+// policy allows for this Think().
+func (s *Sprite) caughtUp() bool {
+	return s.catch_up.MaxFrames > 0 && s.frames >= s.catch_up.MaxFrames
+}
@@ -0,0 +1,31 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+)
+
+func CatchUpSpec(c gospec.Context) {
+	s, err := sprite.LoadSprite("test_sprite")
+	c.Assume(err, Equals, nil)
+	s.Think(0)
+	// Returns how many frames s goes through in a Think(dt).
+	frames := func(dt int64) int {
+		before := len(s.Trace())
+		s.Think(dt)
+		return len(s.Trace()) - before
+	}
+	c.Specify("Sprites go through every frame by default.", func() {
+		c.Expect(frames(1000) > 3, Equals, true)
+	})
+	c.Specify("MaxFrames limits how many frames one Think() goes through.", func() {
+		s.SetCatchUpPolicy(sprite.CatchUpPolicy{MaxFrames: 3})
+		c.Expect(frames(1000), Equals, 3)
+		c.Expect(frames(1000), Equals, 3)
+	})
+	c.Specify("MaxDt limits how much time one Think() goes through.", func() {
+		s.SetCatchUpPolicy(sprite.CatchUpPolicy{MaxDt: 50})
+		c.Expect(frames(1000) < 2, Equals, true)
+	})
+}
@@ -0,0 +1,109 @@
+package sprite
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// How many anim nodes each sprite remembers for Trace().
+const traceLength = 32
+
+// An anim node that a sprite went to, and when, in milliseconds of Think()
+// since the sprite was loaded.
+type TraceStep struct {
+	Node string
+	Time int64
+}
+
+// What DebugInfo() knows about a sheet.
+type SheetInfo struct {
+	// The facing directory that the sheet is for, or -1 for the connector
+	// sheet, which has the frames that change facing.
+	Facing int
+
+	// Size of the sheet in pixels.
+	Dx, Dy int
+
+	// How many sprites are using the sheet, it is uploaded while this is
+	// more than zero.
+	References int
+
+	Uploaded bool
+
+	// Bytes of decoded pixels that the sheet is keeping, see DecodePolicy.
+	DecodedBytes int
+}
+
+// What DebugInfo() knows about the sprites loaded from one path.
+type SpriteInfo struct {
+	Path string
+
+	// The facing directories that have their sheets loaded right now.
+	LoadedFacings []int
+
+	// The connector sheet and then the sheet for each facing.
+	Sheets []SheetInfo
+
+	// The most recent anim nodes of each sprite from this path that is
+	// registered with the manager, oldest first, see Sprite.Trace().
+	Traces [][]TraceStep
+}
+
+// Returns what m's sprites have loaded, sorted by path, for figuring out why
+// memory use is high or why an animation is stuck.  Traces are read from the
+// registered sprites without locking them, so this should be called from the
+// goroutine that thinks them.
+func (m *Manager) DebugInfo() []SpriteInfo {
+	registered := m.Registered(nil)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var infos []SpriteInfo
+	for _, ss := range m.shared {
+		info := SpriteInfo{Path: ss.path}
+		for i, sh := range ss.sheets() {
+			sh.mutex.Lock()
+			sheet_info := SheetInfo{
+				Facing:       i - 1,
+				Dx:           sh.dx,
+				Dy:           sh.dy,
+				References:   int(atomic.LoadInt32(&sh.references)),
+				Uploaded:     sh.uploaded,
+				DecodedBytes: len(sh.pixels),
+			}
+			sh.mutex.Unlock()
+			if sheet_info.Facing >= 0 && sheet_info.References > 0 {
+				info.LoadedFacings = append(info.LoadedFacings, sheet_info.Facing)
+			}
+			info.Sheets = append(info.Sheets, sheet_info)
+		}
+		for _, s := range registered {
+			if s.shared == ss {
+				info.Traces = append(info.Traces, s.Trace())
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Path < infos[j].Path })
+	return infos
+}
+
+func DebugInfo() []SpriteInfo {
+	return the_manager.DebugInfo()
+}
+
+// Returns the last few anim nodes that s went to, oldest first, starting
+// with the node it was loaded on.
+func (s *Sprite) Trace() []TraceStep {
+	return append([]TraceStep(nil), s.trace...)
+}
+
+// Remembers that s just went to its current anim node.
+func (s *Sprite) traceStep() {
+	step := TraceStep{Node: s.anim_node.Line(0), Time: s.clock}
+	if len(s.trace) < traceLength {
+		s.trace = append(s.trace, step)
+		return
+	}
+	copy(s.trace, s.trace[1:])
+	s.trace[len(s.trace)-1] = step
+}
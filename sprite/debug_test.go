@@ -0,0 +1,30 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+)
+
+func DebugInfoSpec(c gospec.Context) {
+	m := sprite.MakeManager()
+	s, err := m.LoadSprite("test_sprite")
+	c.Assume(err, Equals, nil)
+	c.Specify("Traces start with the node the sprite was loaded on.", func() {
+		trace := s.Trace()
+		c.Assume(len(trace), Equals, 1)
+		c.Expect(trace[0].Node, Equals, "ready_01")
+		c.Expect(trace[0].Time, Equals, int64(0))
+	})
+	c.Specify("DebugInfo lists each path with the traces of its registered sprites.", func() {
+		c.Expect(len(m.DebugInfo()), Equals, 1)
+		c.Expect(len(m.DebugInfo()[0].Traces), Equals, 0)
+		m.Register(s)
+		infos := m.DebugInfo()
+		c.Assume(len(infos), Equals, 1)
+		c.Expect(infos[0].Path, Equals, "test_sprite")
+		c.Expect(len(infos[0].Traces), Equals, 1)
+		c.Expect(len(infos[0].Sheets) > 1, Equals, true)
+		c.Expect(infos[0].Sheets[0].Facing, Equals, -1)
+	})
+}
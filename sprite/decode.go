@@ -0,0 +1,100 @@
+package sprite
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Says when the frames of a sprite are decoded and how long they are kept in
+// memory.  Either way sheets are only uploaded as textures while they're
+// needed, this is about the decoded pixels that the textures are made from.
+type DecodePolicy int32
+
+const (
+	// Sheets are decoded each time they are loaded and the pixels are freed
+	// as soon as they are uploaded, so only the facings that sprites are
+	// using take up any memory.  This is the default, and is best for very
+	// large sprites.
+	Lazy DecodePolicy = iota
+
+	// Every sheet of a sprite is decoded when it is loaded and the pixels are
+	// kept, so changing facings only has to upload a texture.  This uses a
+	// lot more memory, but never stutters.
+	Eager
+)
+
+// Sets how sprites loaded by m from now on decode their frames.  Setting it
+// to Lazy also frees the pixels that sprites already loaded are keeping.
+func (m *Manager) SetDecodePolicy(policy DecodePolicy) {
+	atomic.StoreInt32(&m.policy, int32(policy))
+	if policy != Lazy {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, ss := range m.shared {
+		for _, sh := range ss.sheets() {
+			sh.mutex.Lock()
+			sh.pixels = nil
+			sh.mutex.Unlock()
+		}
+	}
+}
+
+func (m *Manager) DecodePolicy() DecodePolicy {
+	return DecodePolicy(atomic.LoadInt32(&m.policy))
+}
+
+// Returns how many bytes of decoded frames m's sprites are keeping in
+// memory, and how many bytes of sheets are uploaded as textures.
+func (m *Manager) ResidentBytes() (pixels, textures int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, ss := range m.shared {
+		for _, sh := range ss.sheets() {
+			sh.mutex.Lock()
+			pixels += int64(len(sh.pixels))
+			if sh.uploaded {
+				textures += int64(4 * sh.dx * sh.dy)
+			}
+			sh.mutex.Unlock()
+		}
+	}
+	return
+}
+
+func SetDecodePolicy(policy DecodePolicy) {
+	the_manager.SetDecodePolicy(policy)
+}
+
+func ResidentBytes() (pixels, textures int64) {
+	return the_manager.ResidentBytes()
+}
+
+// Returns the connector sheet and the sheet for each facing.
+func (ss *sharedSprite) sheets() []*sheet {
+	return append([]*sheet{ss.connector}, ss.facings...)
+}
+
+// Decodes every sheet that isn't already decoded and keeps the pixels, for
+// the Eager policy.
+func (ss *sharedSprite) decodeAll() {
+	var wg sync.WaitGroup
+	for _, sh := range ss.sheets() {
+		wg.Add(1)
+		go func(sh *sheet) {
+			defer wg.Done()
+			sh.mutex.Lock()
+			decoded := sh.pixels != nil
+			sh.mutex.Unlock()
+			if decoded {
+				return
+			}
+			pixels := sh.decode()
+			sh.mutex.Lock()
+			sh.pixels = pixels
+			sh.mutex.Unlock()
+		}(sh)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,85 @@
+package sprite
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+)
+
+// Says how a sprite draws one of the facings that the game uses.  A sprite
+// with four facings of art can be used in a game with eight facings, for
+// example, by drawing some of them with the same art, and by mirroring the
+// art for facing left to get facing right.
+type Facing struct {
+	// The facing directory that frames are drawn from.
+	Asset int
+
+	// If true frames are drawn flipped horizontally.
+	Mirror bool
+}
+
+// Loads the sprite in path for a game that uses len(facings) facings, where
+// facings[i] says how to draw the sprite when Facing() is i.  facing tags on
+// edges turn the sprite through the game's facings rather than the ones in
+// the sprite's directory.
+func (m *Manager) LoadSpriteWithFacings(path string, facings []Facing) (*Sprite, error) {
+	return m.loadSpriteWithFacings(spriteKey{path: filepath.Clean(path)}, facings)
+}
+
+// Like LoadSpriteWithFacings() but loads from fsys like LoadSpriteFS().
+func (m *Manager) LoadSpriteFSWithFacings(fsys fs.FS, dir string, facings []Facing) (*Sprite, error) {
+	return m.loadSpriteWithFacings(spriteKey{fsys: fsys, path: path.Clean(dir)}, facings)
+}
+
+func (m *Manager) loadSpriteWithFacings(key spriteKey, facings []Facing) (*Sprite, error) {
+	if len(facings) == 0 {
+		return nil, fmt.Errorf("Sprite %s needs at least one facing.", key.path)
+	}
+	s, err := m.loadSprite(key)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range facings {
+		if f.Asset < 0 || f.Asset >= len(s.shared.facings) {
+			return nil, fmt.Errorf("Facing %d of sprite %s uses facing %d, but it only has %d.", i, key.path, f.Asset, len(s.shared.facings))
+		}
+	}
+	s.facing_map = append([]Facing(nil), facings...)
+	return s, nil
+}
+
+func LoadSpriteWithFacings(path string, facings []Facing) (*Sprite, error) {
+	return the_manager.LoadSpriteWithFacings(path, facings)
+}
+
+func LoadSpriteFSWithFacings(fsys fs.FS, dir string, facings []Facing) (*Sprite, error) {
+	return the_manager.LoadSpriteFSWithFacings(fsys, dir, facings)
+}
+
+// Returns the number of facings that s turns through.
+func (s *Sprite) NumFacings() int {
+	if s.facing_map != nil {
+		return len(s.facing_map)
+	}
+	return len(s.shared.facings)
+}
+
+// Returns how s draws facing.
+func (s *Sprite) facingAsset(facing int) Facing {
+	if s.facing_map != nil {
+		return s.facing_map[facing]
+	}
+	return Facing{Asset: facing}
+}
+
+// Returns the sheet with the frames for facing, that aren't in the connector
+// sheet.
+func (s *Sprite) facingSheet(facing int) *sheet {
+	return s.shared.facings[s.facingAsset(facing).Asset]
+}
+
+// Returns the id of the frame that s is showing.
+func (s *Sprite) frameId() frameId {
+	return frameId{facing: s.facingAsset(s.facing).Asset, node: s.anim_node.Id()}
+}
@@ -0,0 +1,36 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+)
+
+func FacingSpec(c gospec.Context) {
+	facings := []sprite.Facing{{Asset: 0, Mirror: true}, {Asset: 0}, {Asset: 1}, {Asset: 1, Mirror: true}}
+	c.Specify("Sprites turn through the facings they were loaded with.", func() {
+		s, err := sprite.LoadSpriteWithFacings("test_sprite", facings)
+		c.Assume(err, Equals, nil)
+		c.Expect(s.NumFacings(), Equals, 4)
+		s.Command("turn_left")
+		for i := 0; i < 100 && s.Facing() == 0; i++ {
+			s.Think(50)
+		}
+		c.Expect(s.Facing(), Equals, 3)
+	})
+	c.Specify("Anchors are mirrored on mirrored facings.", func() {
+		s, err := sprite.LoadSpriteWithFacings("test_sprite", facings)
+		c.Assume(err, Equals, nil)
+		c.Assume(s.Anim(), Equals, "ready_01")
+		x, y, ok := s.Anchor("hand")
+		c.Expect(ok, Equals, true)
+		c.Expect(x, Equals, 29)
+		c.Expect(y, Equals, 59)
+	})
+	c.Specify("Facings have to be in the sprite.", func() {
+		_, err := sprite.LoadSpriteWithFacings("test_sprite", []sprite.Facing{{Asset: 2}})
+		c.Expect(err, Not(Equals), nil)
+		_, err = sprite.LoadSpriteWithFacings("test_sprite", nil)
+		c.Expect(err, Not(Equals), nil)
+	})
+}
@@ -0,0 +1,100 @@
+package sprite
+
+import (
+	"fmt"
+	"github.com/runningwild/yedparse"
+)
+
+// Throws away the sprite's path and pending commands.  Synced commands stop
+// waiting for it.
+func (s *Sprite) dropCommands() {
+	for _, cmd := range s.pending_cmds {
+		if cmd.group == nil || cmd.group.was_ready {
+			continue
+		}
+		sprites := cmd.group.sprites[:0]
+		for _, sp := range cmd.group.sprites {
+			if sp != s {
+				sprites = append(sprites, sp)
+			}
+		}
+		cmd.group.sprites = sprites
+	}
+	s.path = nil
+	s.pending_cmds = nil
+	s.idle = 0
+}
+
+// Puts s on anim and state right away, facing the same way.
+func (s *Sprite) force(anim, state *yed.Node) {
+	s.dropCommands()
+	s.anim_node = anim
+	s.state_node = state
+	s.state_facing = s.facing
+	s.togo = s.shared.node_data[anim].time
+	s.traceStep()
+	if s.thinks > 0 {
+		s.settleFacing()
+	}
+	s.show()
+}
+
+// Jumps s straight to the frame labeled name in the anim graph, and to the
+// state that frame is in, throwing away anything it was going to do.  The
+// frame's func: and sound: tags aren't run.  This is for cutscenes and for
+// scrubbing through animations in an editor, SetSpriteState() is for putting
+// a sprite back how it was.
+func (s *Sprite) ForceAnimNode(name string) error {
+	anim := findStateNode(s.shared.anim, -1, name)
+	if anim == nil {
+		return fmt.Errorf("Sprite %s has no anim node '%s'.", s.shared.path, name)
+	}
+	state := s.state_node
+	if label := s.shared.node_data[anim].state; label != "" {
+		if node := findStateNode(s.shared.state, -1, label); node != nil {
+			state = node
+		}
+	}
+	s.force(anim, state)
+	return nil
+}
+
+// Jumps s straight to the state labeled name in the state graph, and to the
+// first frame of it in the anim graph, throwing away anything it was going
+// to do.  The first frame of a state is one that frames from other states
+// lead to, or the start frame for the start state.
+func (s *Sprite) ForceState(name string) error {
+	state := findStateNode(s.shared.state, -1, name)
+	if state == nil {
+		return fmt.Errorf("Sprite %s has no state '%s'.", s.shared.path, name)
+	}
+	anim := s.shared.firstFrame(name)
+	if anim == nil {
+		return fmt.Errorf("Sprite %s has no frames in state '%s'.", s.shared.path, name)
+	}
+	s.force(anim, state)
+	return nil
+}
+
+// Returns the frame that the state labeled name starts on, see ForceState().
+func (ss *sharedSprite) firstFrame(name string) *yed.Node {
+	if ss.node_data[ss.anim_start].state == name {
+		return ss.anim_start
+	}
+	var any *yed.Node
+	for i := 0; i < ss.anim.NumNodes(); i++ {
+		node := ss.anim.Node(i)
+		if ss.node_data[node].state != name || node.NumChildren() > 0 {
+			continue
+		}
+		if any == nil {
+			any = node
+		}
+		for j := 0; j < node.NumGroupInputs(); j++ {
+			if ss.node_data[node.GroupInput(j).Src()].state != name {
+				return node
+			}
+		}
+	}
+	return any
+}
@@ -0,0 +1,39 @@
+package sprite
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Loads the sprite whose art is in image_dir and whose anim.xgml and
+// state.xgml are in graph_dir.  Sprites that use the same graph_dir share
+// one copy of the parsed graphs and everything worked out from them, so a
+// game with lots of characters that only differ in palette or armor only
+// parses their graphs once.  The frames in image_dir must be named for the
+// nodes in graph_dir's anim graph, and sheets are cached in image_dir.
+// Sprites loaded with LoadSprite() from graph_dir share the graphs too.
+func (m *Manager) LoadSpriteWithGraphs(image_dir, graph_dir string) (*Sprite, error) {
+	key := spriteKey{path: filepath.Clean(image_dir), graphs: filepath.Clean(graph_dir)}
+	if key.graphs == key.path {
+		key.graphs = ""
+	}
+	return m.loadSprite(key)
+}
+
+func LoadSpriteWithGraphs(image_dir, graph_dir string) (*Sprite, error) {
+	return the_manager.LoadSpriteWithGraphs(image_dir, graph_dir)
+}
+
+// Returns the graphs in dir on disk, loading them if no sprite has used them
+// yet.  m.mutex must be held.
+func (m *Manager) loadGraphs(dir string) (*spriteGraphs, error) {
+	if g, ok := m.graphs[dir]; ok {
+		return g, nil
+	}
+	g, err := loadGraphs(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	m.graphs[dir] = g
+	return g, nil
+}
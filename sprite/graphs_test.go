@@ -0,0 +1,43 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+	"os"
+	"path/filepath"
+)
+
+func GraphsSpec(c gospec.Context) {
+	dir, err := os.MkdirTemp("", "graphs")
+	c.Assume(err, Equals, nil)
+	defer os.RemoveAll(dir)
+	c.Assume(copyTestSprite(dir), Equals, nil)
+	c.Assume(os.Remove(filepath.Join(dir, "anim.xgml")), Equals, nil)
+	c.Assume(os.Remove(filepath.Join(dir, "state.xgml")), Equals, nil)
+	c.Specify("Sprites without graphs of their own can use another sprite's.", func() {
+		m := sprite.MakeManager()
+		_, err := m.LoadSprite(dir)
+		c.Expect(err, Not(Equals), nil)
+		s, err := m.LoadSpriteWithGraphs(dir, "test_sprite")
+		c.Assume(err, Equals, nil)
+		c.Expect(s.Anim(), Equals, "ready_01")
+		c.Expect(s.State(), Equals, "ready")
+		s.Command("defend")
+		for i := 0; i < 100 && s.AnimState() != "defending"; i++ {
+			s.Think(50)
+		}
+		c.Expect(s.AnimState(), Equals, "defending")
+	})
+	c.Specify("Loading with a sprite's own graphs is the same as LoadSprite().", func() {
+		m := sprite.MakeManager()
+		_, err := m.LoadSprite("test_sprite")
+		c.Assume(err, Equals, nil)
+		_, err = m.LoadSpriteWithGraphs("test_sprite", "./test_sprite")
+		c.Assume(err, Equals, nil)
+		c.Expect(len(m.DebugInfo()), Equals, 1)
+		_, err = m.LoadSpriteWithGraphs(dir, "test_sprite")
+		c.Assume(err, Equals, nil)
+		c.Expect(len(m.DebugInfo()), Equals, 2)
+	})
+}
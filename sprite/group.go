@@ -0,0 +1,102 @@
+package sprite
+
+// Adds s to the sprites that m commands with CommandAll(), with tags that
+// filters can check with HasTag().  Registering a sprite again adds to its
+// tags.
+func (m *Manager) Register(s *Sprite, tags ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.tags == nil {
+		m.tags = make(map[*Sprite]map[string]bool)
+	}
+	if _, ok := m.tags[s]; !ok {
+		m.registered = append(m.registered, s)
+		m.tags[s] = make(map[string]bool)
+	}
+	for _, tag := range tags {
+		m.tags[s][tag] = true
+	}
+}
+
+// Removes s from the sprites that m commands.  Sprites that are done with
+// should be unregistered, or m will keep them around forever.
+func (m *Manager) Unregister(s *Sprite) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.tags[s]; !ok {
+		return
+	}
+	delete(m.tags, s)
+	for i := range m.registered {
+		if m.registered[i] == s {
+			m.registered = append(m.registered[:i], m.registered[i+1:]...)
+			break
+		}
+	}
+}
+
+// Returns true iff s is registered with m with tag.
+func (m *Manager) HasTag(s *Sprite, tag string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.tags[s][tag]
+}
+
+// Returns the sprites registered with m that filter returns true for, in the
+// order they were registered.  A nil filter returns all of them.
+func (m *Manager) Registered(filter func(*Sprite) bool) []*Sprite {
+	m.mutex.Lock()
+	sprites := append([]*Sprite(nil), m.registered...)
+	m.mutex.Unlock()
+	if filter == nil {
+		return sprites
+	}
+	var matched []*Sprite
+	for _, s := range sprites {
+		if filter(s) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// Returns a filter for sprites registered with m with tag.
+func (m *Manager) Tagged(tag string) func(*Sprite) bool {
+	return func(s *Sprite) bool {
+		return m.HasTag(s, tag)
+	}
+}
+
+// Gives cmd to every sprite registered with m that filter returns true for,
+// like "all soldiers salute", and returns how many of them could do it.  A
+// nil filter commands all of them.  Like Command() this must be called from
+// the goroutine that calls Think() on the sprites.
+func (m *Manager) CommandAll(filter func(*Sprite) bool, cmd string) int {
+	n := 0
+	for _, s := range m.Registered(filter) {
+		if s.baseCommand(command{names: []string{cmd}}) {
+			n++
+		}
+	}
+	return n
+}
+
+func Register(s *Sprite, tags ...string) {
+	the_manager.Register(s, tags...)
+}
+
+func Unregister(s *Sprite) {
+	the_manager.Unregister(s)
+}
+
+func HasTag(s *Sprite, tag string) bool {
+	return the_manager.HasTag(s, tag)
+}
+
+func Tagged(tag string) func(*Sprite) bool {
+	return the_manager.Tagged(tag)
+}
+
+func CommandAll(filter func(*Sprite) bool, cmd string) int {
+	return the_manager.CommandAll(filter, cmd)
+}
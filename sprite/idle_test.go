@@ -0,0 +1,70 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Copies the test sprite to a temporary directory with the given tags added
+// to the ready state.
+func idleSprite(tags string) (string, error) {
+	dir, err := os.MkdirTemp("", "idle")
+	if err != nil {
+		return "", err
+	}
+	if err := copyTestSprite(dir); err != nil {
+		return dir, err
+	}
+	name := filepath.Join(dir, "state.xgml")
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return dir, err
+	}
+	graph := strings.Replace(string(data), `type="String">ready`, `type="String">ready`+"\n"+tags, 1)
+	return dir, os.WriteFile(name, []byte(graph), 0644)
+}
+
+func IdleSpec(c gospec.Context) {
+	c.Specify("Sprites are given idle_cmd after idle_after ms of being idle.", func() {
+		dir, err := idleSprite("idle_after:1000\nidle_cmd:turn_right")
+		defer os.RemoveAll(dir)
+		c.Assume(err, Equals, nil)
+		s, err := sprite.LoadSprite(dir)
+		c.Assume(err, Equals, nil)
+		for i := 0; i < 19; i++ {
+			s.Think(50)
+		}
+		c.Expect(s.NumPendingCmds(), Equals, 0)
+		c.Expect(s.StateFacing(), Equals, 0)
+		s.Think(50)
+		c.Expect(s.StateFacing(), Equals, 1)
+	})
+	c.Specify("Commands restart the idle timer.", func() {
+		dir, err := idleSprite("idle_after:1000\nidle_cmd:turn_right")
+		defer os.RemoveAll(dir)
+		c.Assume(err, Equals, nil)
+		s, err := sprite.LoadSprite(dir)
+		c.Assume(err, Equals, nil)
+		for i := 0; i < 15; i++ {
+			s.Think(50)
+		}
+		s.Command("turn_left")
+		for i := 0; i < 15; i++ {
+			s.Think(50)
+		}
+		c.Expect(s.StateFacing(), Equals, 1)
+	})
+	c.Specify("Idle tags are checked when the sprite is loaded.", func() {
+		for _, tags := range []string{"idle_after:1000", "idle_after:0\nidle_cmd:turn_right", "idle_after:1000\nidle_cmd:salute"} {
+			dir, err := idleSprite(tags)
+			c.Assume(err, Equals, nil)
+			_, err = sprite.LoadSprite(dir)
+			c.Expect(err, Not(Equals), nil)
+			os.RemoveAll(dir)
+		}
+	})
+}
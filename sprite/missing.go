@@ -0,0 +1,58 @@
+package sprite
+
+import (
+	"github.com/runningwild/glop/glog"
+	"github.com/runningwild/glop/render"
+	"image"
+)
+
+// Sets the texture that Bind() binds for frames that have no image, which is
+// a single magenta pixel by default.  The texture is stretched over the whole
+// frame.
+func SetErrorTexture(img image.Image) {
+	setupRendering()
+	texture := render.Textures().LoadImage(img)
+	render.Queue(func() {
+		error_texture.Delete()
+		error_texture = texture
+	})
+}
+
+// Sets a function that is called the first time that each missing frame of
+// each sprite loaded by m is bound, with the path that the sprite was loaded
+// from, the label of the frame's node in the anim graph, and the facing
+// directory that the image isn't in.  It is called from whatever goroutine
+// calls Bind(), which is usually the render thread.  With no function, or a
+// nil one, missing frames are logged as warnings.
+func (m *Manager) SetOnMissingFrame(f func(sprite_path, node string, facing int)) {
+	m.missing_mutex.Lock()
+	defer m.missing_mutex.Unlock()
+	m.on_missing = f
+}
+
+func SetOnMissingFrame(f func(sprite_path, node string, facing int)) {
+	the_manager.SetOnMissingFrame(f)
+}
+
+// Reports fid as missing, unless it has already been reported.
+func (ss *sharedSprite) reportMissing(fid frameId) {
+	m := ss.manager
+	m.missing_mutex.Lock()
+	if ss.missing[fid] {
+		m.missing_mutex.Unlock()
+		return
+	}
+	if ss.missing == nil {
+		ss.missing = make(map[frameId]bool)
+	}
+	ss.missing[fid] = true
+	f := m.on_missing
+	m.missing_mutex.Unlock()
+
+	node := ss.anim.Node(fid.node).Line(0)
+	if f == nil {
+		glog.Warningf("Sprite %s has no image for '%s' in facing %d.", ss.path, node, fid.facing)
+		return
+	}
+	f(ss.path, node, fid.facing)
+}
@@ -4,7 +4,9 @@ import (
   "fmt"
   "image"
   _ "image/png"
+  "io/fs"
   "os"
+  "path"
   "path/filepath"
   "sort"
   "strconv"
@@ -13,24 +15,66 @@ import (
   "github.com/runningwild/yedparse"
 )
 
-type sharedSprite struct {
-  path string
+// The parsed anim and state graphs of a sprite and everything worked out from
+// them.  None of this changes once it's loaded, so sprites that have the same
+// graphs but different art share one spriteGraphs, see
+// Manager.LoadSpriteWithGraphs().
+type spriteGraphs struct {
+  // The directory that anim.xgml and state.xgml were read from.
+  graph_fsys fs.FS
 
   anim, state *yed.Graph
   anim_start  *yed.Node
   state_start *yed.Node
 
-  node_data map[*yed.Node]nodeData
-  edge_data map[*yed.Edge]edgeData
+  node_data  map[*yed.Node]nodeData
+  edge_data  map[*yed.Edge]edgeData
+  state_data map[*yed.Node]stateData
+
+  // Frames that go in the connector sheet, see figureConnectors().
+  connectors []*yed.Node
+
+  // Paths through the anim graph for each command from each node, the graph
+  // never changes once it's loaded so these never go stale.
+  paths *algorithm.PathCache
+}
+
+type sharedSprite struct {
+  *spriteGraphs
+
+  // Where the sprite was loaded from, only used in messages.
+  path string
+
+  // The sprite's directory, and the same directory on disk if it was loaded
+  // from disk so that sheets can be cached next to it.
+  fsys fs.FS
+  dir  string
 
   connector *sheet
   facings   []*sheet
 
+  // Named points on frames, see Sprite.Anchor().
+  anchors anchors
+
+  // Frames that Bind() has found to be missing, guarded by the manager's
+  // missing_mutex.
+  missing map[frameId]bool
+
   manager *Manager
 }
 
-func loadSharedSprite(path string) (*sharedSprite, error) {
-  state, err := yed.ParseFromFile(filepath.Join(path, "state.xgml"))
+func parseGraph(fsys fs.FS, name string) (*yed.Document, error) {
+  f, err := fsys.Open(name)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  return yed.Parse(f)
+}
+
+// Loads the anim and state graphs in the root of fsys.
+func loadGraphs(fsys fs.FS) (*spriteGraphs, error) {
+  state, err := parseGraph(fsys, "state.xgml")
   if err != nil {
     return nil, err
   }
@@ -40,7 +84,7 @@ func loadSharedSprite(path string) (*sharedSprite, error) {
     return nil, err
   }
 
-  anim, err := yed.ParseFromFile(filepath.Join(path, "anim.xgml"))
+  anim, err := parseGraph(fsys, "anim.xgml")
   if err != nil {
     return nil, err
   }
@@ -53,7 +97,35 @@ func loadSharedSprite(path string) (*sharedSprite, error) {
   // TODO: Verify both graphs at the same time - they both need to respond to
   // the same commands in the same way.
 
-  num_facings, filenames, err := verifyDirectoryStructure(path, &anim.Graph)
+  var g spriteGraphs
+  g.graph_fsys = fsys
+  g.anim = &anim.Graph
+  g.state = &state.Graph
+  g.anim_start = getStartNode(g.anim)
+  g.state_start = getStartNode(g.state)
+  g.paths = algorithm.MakePathCache()
+  g.process()
+
+  // Connectors are all frames that can be reached within a certain number of
+  // milliseconds of any change in facing
+  g.connectors = figureConnectors(g.anim, 150)
+  return &g, nil
+}
+
+// Loads the sprite whose directory is the root of fsys for m.  dir is that
+// same directory on disk, or "" if it isn't on disk.
+func loadSharedSprite(m *Manager, fsys fs.FS, dir, name string) (*sharedSprite, error) {
+  g, err := loadGraphs(fsys)
+  if err != nil {
+    return nil, err
+  }
+  return loadSharedSpriteWithGraphs(m, g, fsys, dir, name)
+}
+
+// Like loadSharedSprite() but the sprite's graphs have already been loaded,
+// possibly from another directory, so only its art is read from fsys.
+func loadSharedSpriteWithGraphs(m *Manager, g *spriteGraphs, fsys fs.FS, dir, name string) (*sharedSprite, error) {
+  num_facings, filenames, err := verifyDirectoryStructure(fsys, g.anim)
   if err != nil {
     return nil, err
   }
@@ -61,9 +133,11 @@ func loadSharedSprite(path string) (*sharedSprite, error) {
   // If we've made it this far then the sprite is probably well formed so we
   // can start putting all of the data together
   var ss sharedSprite
-  ss.path = path
-  ss.anim = &anim.Graph
-  ss.state = &state.Graph
+  ss.spriteGraphs = g
+  ss.path = name
+  ss.manager = m
+  ss.fsys = fsys
+  ss.dir = dir
 
   // Read through all of the files and figure out how much space we'll need
   // to arrange them all into one sprite sheet
@@ -71,7 +145,7 @@ func loadSharedSprite(path string) (*sharedSprite, error) {
   height := 0
   for facing := 0; facing < num_facings; facing++ {
     for _, filename := range filenames {
-      file, err := os.Open(filepath.Join(path, fmt.Sprintf("%d", facing), filename))
+      file, err := fsys.Open(path.Join(fmt.Sprintf("%d", facing), filename))
       // if a file isn't there that's ok
       if err != nil {
         continue
@@ -91,19 +165,15 @@ func loadSharedSprite(path string) (*sharedSprite, error) {
     }
   }
 
-  // Connectors are all frames that can be reached within a certain number of
-  // milliseconds of any change in facing
-  conn := figureConnectors(&anim.Graph, 150)
-
   // Arrange them all into one sprite sheet
   var fids []frameId
-  for _, con := range conn {
+  for _, con := range g.connectors {
     for facing := 0; facing < num_facings; facing++ {
       fids = append(fids, frameId{facing: facing, node: con.Id()})
     }
   }
   sort.Sort(frameIdArray(fids))
-  ss.connector, err = makeSheet(path, &anim.Graph, fids)
+  ss.connector, err = makeSheet(&ss, g.anim, fids)
   if err != nil {
     return nil, err
   }
@@ -111,34 +181,67 @@ func loadSharedSprite(path string) (*sharedSprite, error) {
   // Now we make a sheet for each facing, but don't include any of the frames
   // that are in the connctor sheet
   used := make(map[*yed.Node]bool)
-  for _, con := range conn {
+  for _, con := range g.connectors {
     used[con] = true
   }
   for facing := 0; facing < num_facings; facing++ {
     var facing_fids []frameId
-    for i := 0; i < anim.Graph.NumNodes(); i++ {
-      node := anim.Graph.Node(i)
+    for i := 0; i < g.anim.NumNodes(); i++ {
+      node := g.anim.Node(i)
       if !used[node] {
         facing_fids = append(facing_fids, frameId{facing: facing, node: node.Id()})
       }
     }
     sort.Sort(frameIdArray(facing_fids))
-    sh, err := makeSheet(path, &anim.Graph, facing_fids)
+    sh, err := makeSheet(&ss, g.anim, facing_fids)
     if err != nil {
       return nil, err
     }
     ss.facings = append(ss.facings, sh)
   }
 
-  ss.connector.Load()
-  ss.anim_start = getStartNode(ss.anim)
-  ss.state_start = getStartNode(ss.state)
+  rects := make(map[frameId]FrameRect)
+  for _, sh := range ss.sheets() {
+    for fid, rect := range sh.rects {
+      rects[fid] = rect
+    }
+  }
+  ss.anchors, err = loadAnchors(fsys, g.anim, num_facings, rects)
+  if err != nil {
+    return nil, err
+  }
 
-  ss.process()
+  ss.removeStaleSheets()
+  if m.DecodePolicy() == Eager {
+    ss.decodeAll()
+  }
+  ss.connector.Load()
 
   return &ss, nil
 }
 
+// Removes sheets cached in the sprite's directory on disk that aren't any of
+// its current sheets, because the graph or frames have changed since they
+// were made.
+func (ss *sharedSprite) removeStaleSheets() {
+  if ss.dir == "" {
+    return
+  }
+  current := map[string]bool{ss.connector.name: true}
+  for _, sh := range ss.facings {
+    current[sh.name] = true
+  }
+  entries, err := os.ReadDir(ss.dir)
+  if err != nil {
+    return
+  }
+  for _, entry := range entries {
+    if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".gob") && !current[entry.Name()] {
+      os.Remove(filepath.Join(ss.dir, entry.Name()))
+    }
+  }
+}
+
 // Given the anim graph for a sprite, determines the frames that must always
 // be loaded such that the remaining facings can be loaded only when the
 // sprite facing changes, so long as the facings sprite sheet can be loaded
@@ -163,7 +266,7 @@ func figureConnectors(anim *yed.Graph, limit int) []*yed.Node {
   return ret
 }
 
-func (ss *sharedSprite) markNodesWithState(node *yed.Node, state string) {
+func (ss *spriteGraphs) markNodesWithState(node *yed.Node, state string) {
   used := make(map[*yed.Node]bool)
   unused := make(map[*yed.Node]bool)
   unused[node] = true
@@ -192,7 +295,7 @@ func (ss *sharedSprite) markNodesWithState(node *yed.Node, state string) {
   }
 }
 
-func (ss *sharedSprite) findCmdFromAnimNode(node *yed.Node, cmd string) *yed.Node {
+func (ss *spriteGraphs) findCmdFromAnimNode(node *yed.Node, cmd string) *yed.Node {
   used := make(map[*yed.Node]bool)
   unused := make(map[*yed.Node]bool)
   unused[node] = true
@@ -216,7 +319,7 @@ func (ss *sharedSprite) findCmdFromAnimNode(node *yed.Node, cmd string) *yed.Nod
   return nil
 }
 
-func (ss *sharedSprite) markAnimFramesWithState(anim, state *yed.Node) {
+func (ss *spriteGraphs) markAnimFramesWithState(anim, state *yed.Node) {
   if ss.node_data[anim].state != "" {
     return
   }
@@ -232,7 +335,7 @@ func (ss *sharedSprite) markAnimFramesWithState(anim, state *yed.Node) {
   }
 }
 
-func (ss *sharedSprite) process() {
+func (ss *spriteGraphs) process() {
   ss.node_data = make(map[*yed.Node]nodeData)
   for i := 0; i < ss.anim.NumNodes(); i++ {
     node := ss.anim.Node(i)
@@ -244,6 +347,14 @@ func (ss *sharedSprite) process() {
     ss.node_data[node] = data
   }
 
+  ss.state_data = make(map[*yed.Node]stateData)
+  for i := 0; i < ss.state.NumNodes(); i++ {
+    node := ss.state.Node(i)
+    // These were checked by verifyStateGraph().
+    after, _ := strconv.ParseInt(node.Tag("idle_after"), 10, 64)
+    ss.state_data[node] = stateData{idle_after: after, idle_cmd: node.Tag("idle_cmd")}
+  }
+
   ss.edge_data = make(map[*yed.Edge]edgeData)
   proc_graph := func(graph *yed.Graph) {
     for i := 0; i < graph.NumEdges(); i++ {
@@ -3,15 +3,17 @@ package sprite
 import (
 	"encoding/binary"
 	"fmt"
-	gl "github.com/chsc/gogl/gl21"
+	"github.com/runningwild/glop/glog"
 	"github.com/runningwild/glop/render"
 	"github.com/runningwild/memory"
 	"github.com/runningwild/yedparse"
 	"hash/fnv"
 	"image"
 	"image/draw"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
+	"sync"
+	"sync/atomic"
 )
 
 // An id that specifies a specific frame along with its facing.  This is used
@@ -40,16 +42,42 @@ type sheet struct {
 	rects  map[frameId]FrameRect
 	dx, dy int
 	path   string
+	fsys   fs.FS
+	dir    string
 	anim   *yed.Graph
 
-	// Unique name that is based on the path of the sprite and the list of
-	// frameIds used to generate this sheet.  This name is used to store the
+	// Unique name that is based on the list of frameIds used to generate this
+	// sheet and the files they came from.  This name is used to store the
 	// sheet on disk when not in use.
 	name string
 
 	reference_chan chan int
 	load_chan      chan bool
-	texture        gl.Uint
+	texture        *render.Texture
+
+	// How many times the sheet has been loaded and not unloaded, only
+	// changed by routine() and read with sync/atomic.
+	references int32
+
+	// The sprite the sheet is part of, for its manager's DecodePolicy.
+	shared *sharedSprite
+
+	// Guards pixels and uploaded.
+	mutex sync.Mutex
+
+	// The decoded sheet, kept between loads if it was decoded while the
+	// manager's DecodePolicy was Eager.
+	pixels []byte
+
+	// True while the sheet is uploaded as a texture.
+	uploaded bool
+}
+
+// Pixels sent from compose() to makeTexture(), which frees them unless they
+// are being kept by the sheet.
+type sheetPixels struct {
+	data []byte
+	kept bool
 }
 
 func (s *sheet) Load() {
@@ -60,30 +88,35 @@ func (s *sheet) Unload() {
 	s.reference_chan <- -1
 }
 
-func (s *sheet) compose(pixer chan<- []byte) {
-	filename := filepath.Join(s.path, s.name)
-	f, err := os.Open(filename)
-	if err == nil {
-		var length int32
-		err := binary.Read(f, binary.LittleEndian, &length)
-		if err != nil {
-			f.Close()
-		} else {
-			b := memory.GetBlock(int(length))
-			// b := make([]byte, length)
-			_, err := f.Read(b)
-			f.Close()
-			if err == nil {
-				pixer <- b
-				return
-			}
-		}
+func (s *sheet) compose(pixer chan<- sheetPixels) {
+	s.mutex.Lock()
+	kept := s.pixels
+	s.mutex.Unlock()
+	if kept != nil {
+		pixer <- sheetPixels{data: kept, kept: true}
+		return
+	}
+	data := s.decode()
+	if s.shared.manager.DecodePolicy() == Eager {
+		s.mutex.Lock()
+		s.pixels = data
+		s.mutex.Unlock()
+		pixer <- sheetPixels{data: data, kept: true}
+		return
+	}
+	pixer <- sheetPixels{data: data}
+}
+
+// Returns the pixels of the sheet, from the cache in the sprite's directory
+// if it's there and valid, and by decoding all of its frames if it isn't.
+func (s *sheet) decode() []byte {
+	if b, err := s.readCache(); err == nil {
+		return b
 	}
 	rect := image.Rect(0, 0, s.dx, s.dy)
 	canvas := &image.RGBA{memory.GetBlock(4 * s.dx * s.dy), 4 * s.dx, rect}
 	for fid, rect := range s.rects {
-		name := s.anim.Node(fid.node).Line(0) + ".png"
-		file, err := os.Open(filepath.Join(s.path, fmt.Sprintf("%d", fid.facing), name))
+		file, err := s.fsys.Open(framePath(s.anim, fid))
 		// if a file isn't there that's ok
 		if err != nil {
 			continue
@@ -91,22 +124,19 @@ func (s *sheet) compose(pixer chan<- []byte) {
 
 		im, _, err := image.Decode(file)
 		file.Close()
-		// if a file can't be read that is *not* ok, TODO: Log an error or something
+		// if a file can't be read that is *not* ok
 		if err != nil {
+			glog.Errorf("Can't decode %s: %v", framePath(s.anim, fid), err)
 			continue
 		}
 		draw.Draw(canvas, image.Rect(rect.X, s.dy-rect.Y, rect.X2, s.dy-rect.Y2), im, image.Point{}, draw.Src)
 	}
-	f, err = os.Create(filename)
-	if err == nil {
-		binary.Write(f, binary.LittleEndian, int32(len(canvas.Pix)))
-		_, err := f.Write(canvas.Pix)
-		f.Close()
-		if err != nil {
-			os.Remove(filename)
-		}
+	// Sprites that didn't come from disk, like ones in an archive, don't have
+	// anywhere to cache their sheets.
+	if s.dir != "" {
+		s.writeCache(canvas.Pix)
 	}
-	pixer <- canvas.Pix
+	return canvas.Pix
 }
 
 // TODO: This was copied from the gui package, probably should just have some basic
@@ -124,47 +154,42 @@ func nextPowerOf2(n uint32) uint32 {
 	return 0
 }
 
-func (s *sheet) makeTexture(pixer <-chan []byte) {
-	gl.Enable(gl.TEXTURE_2D)
-	gl.GenTextures(1, &s.texture)
-	gl.BindTexture(gl.TEXTURE_2D, s.texture)
-	gl.TexEnvf(gl.TEXTURE_ENV, gl.TEXTURE_ENV_MODE, gl.MODULATE)
-	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	data := <-pixer
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		gl.Sizei(s.dx),
-		gl.Sizei(s.dy),
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_INT,
-		gl.Pointer(&data[0]))
-	memory.FreeBlock(data)
+// Uploads the pixels read from pixer as this sheet's texture and waits until
+// the upload is finished.  This must not be called on the render thread.
+func (s *sheet) makeTexture(pixer <-chan sheetPixels) {
+	pixels := <-pixer
+	texture := render.Textures().LoadRGBA(pixels.data, s.dx, s.dy)
+	render.Queue(func() {
+		s.texture = texture
+	})
+	texture.Wait()
+	s.mutex.Lock()
+	s.uploaded = true
+	s.mutex.Unlock()
+	if !pixels.kept {
+		memory.FreeBlock(pixels.data)
+	}
 }
 
 func (s *sheet) loadRoutine() {
 	ready := make(chan bool, 1)
-	pixer := make(chan []byte)
+	pixer := make(chan sheetPixels)
 	for load := range s.load_chan {
 		if load {
 			go s.compose(pixer)
 			go func() {
-				render.Queue(func() {
-					s.makeTexture(pixer)
-					ready <- true
-				})
+				s.makeTexture(pixer)
+				ready <- true
 			}()
 		} else {
 			go func() {
 				<-ready
+				s.mutex.Lock()
+				s.uploaded = false
+				s.mutex.Unlock()
 				render.Queue(func() {
-					gl.DeleteTextures(1, &s.texture)
-					s.texture = 0
+					s.texture.Delete()
+					s.texture = nil
 				})
 			}()
 		}
@@ -182,6 +207,7 @@ func (s *sheet) routine() {
 				panic(fmt.Sprintf("Tried to unload a sprite (%s/%s) sheet more times than it was loaded.", s.name, s.path))
 			}
 			references--
+			atomic.StoreInt32(&s.references, int32(references))
 			if references == 0 {
 				s.load_chan <- false
 			}
@@ -190,25 +216,43 @@ func (s *sheet) routine() {
 				s.load_chan <- true
 			}
 			references++
+			atomic.StoreInt32(&s.references, int32(references))
 		} else {
 			panic("value of 0 should never be sent along load_chan")
 		}
 	}
 }
 
-func uniqueName(fids []frameId) string {
-	var b []byte
+// Returns the path of the image for a frame, relative to the sprite's
+// directory.
+func framePath(anim *yed.Graph, fid frameId) string {
+	return path.Join(fmt.Sprintf("%d", fid.facing), anim.Node(fid.node).Line(0)+".png")
+}
+
+// Unique name for a sheet made of fids.  It includes the size and
+// modification time of the anim graph, which is in graph_fsys, and of every
+// frame in the sheet, which are in fsys, so that a cached sheet is never used
+// after anything it was made from has changed.
+func uniqueName(graph_fsys, fsys fs.FS, anim *yed.Graph, fids []frameId) string {
+	h := fnv.New64()
 	for i := range fids {
-		b = append(b, byte(fids[i].facing))
-		b = append(b, byte(fids[i].node))
+		h.Write([]byte{byte(fids[i].facing), byte(fids[i].node)})
+	}
+	stamp := func(fsys fs.FS, name string) {
+		if info, err := fs.Stat(fsys, name); err == nil {
+			binary.Write(h, binary.LittleEndian, info.Size())
+			binary.Write(h, binary.LittleEndian, info.ModTime().UnixNano())
+		}
+	}
+	stamp(graph_fsys, "anim.xgml")
+	for _, fid := range fids {
+		stamp(fsys, framePath(anim, fid))
 	}
-	h := fnv.New64()
-	h.Write(b)
 	return fmt.Sprintf("%x.gob", h.Sum64())
 }
 
-func makeSheet(path string, anim *yed.Graph, fids []frameId) (*sheet, error) {
-	s := sheet{path: path, anim: anim, name: uniqueName(fids)}
+func makeSheet(ss *sharedSprite, anim *yed.Graph, fids []frameId) (*sheet, error) {
+	s := sheet{path: ss.path, fsys: ss.fsys, dir: ss.dir, anim: anim, name: uniqueName(ss.graph_fsys, ss.fsys, anim, fids), shared: ss}
 	s.rects = make(map[frameId]FrameRect)
 	cy := 0
 	cx := 0
@@ -216,8 +260,7 @@ func makeSheet(path string, anim *yed.Graph, fids []frameId) (*sheet, error) {
 	tdx := 0
 	max_width := 2048
 	for _, fid := range fids {
-		name := anim.Node(fid.node).Line(0) + ".png"
-		file, err := os.Open(filepath.Join(path, fmt.Sprintf("%d", fid.facing), name))
+		file, err := ss.fsys.Open(framePath(anim, fid))
 		// if a file isn't there that's ok
 		if err != nil {
 			continue
@@ -0,0 +1,25 @@
+package sprite
+
+import (
+	"github.com/runningwild/yedparse"
+)
+
+// The frame that the render thread draws a sprite with.  Think() and
+// everything else that moves the sprite to another frame publish this once
+// they're done so that Bind() never sees a frame that is only half set.
+type shownFrame struct {
+	node   *yed.Node
+	facing int
+}
+
+// Publishes the frame that s is on for the render thread.
+func (s *Sprite) show() {
+	s.shown.Store(shownFrame{node: s.anim_node, facing: s.facing})
+}
+
+// Returns the frame that was last published with show(), and the facing it
+// was shown with.
+func (s *Sprite) shownFrame() (frameId, int) {
+	f := s.shown.Load().(shownFrame)
+	return frameId{facing: s.facingAsset(f.facing).Asset, node: f.node.Id()}, f.facing
+}
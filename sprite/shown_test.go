@@ -0,0 +1,36 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+)
+
+func ConcurrencySpec(c gospec.Context) {
+	s, err := sprite.LoadSprite("test_sprite")
+	c.Assume(err, Equals, nil)
+	c.Specify("Sprites can be drawn while they think.", func() {
+		dx, dy := s.Dims()
+		done := make(chan bool)
+		go func() {
+			for i := 0; i < 1000; i++ {
+				s.Think(10)
+				s.SetTint(1, 1, 1, 1)
+			}
+			done <- true
+		}()
+		for running := true; running; {
+			select {
+			case <-done:
+				running = false
+			default:
+				s.Dims()
+				s.Color()
+			}
+		}
+		s.ForceAnimNode("ready_01")
+		ndx, ndy := s.Dims()
+		c.Expect(ndx, Equals, dx)
+		c.Expect(ndy, Equals, dy)
+	})
+}
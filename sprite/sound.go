@@ -0,0 +1,54 @@
+package sprite
+
+import (
+	"github.com/runningwild/glop/sound"
+)
+
+// Sounds says how sprites play the sounds named by lines like
+// "sound:footstep" in their anim graphs.  When a sprite reaches a frame with
+// such a line it plays the named sound from its position, as set by
+// SetPosition().
+type Sounds struct {
+	Listener *sound.Listener
+
+	// Group that the sounds are played in.
+	Group string
+
+	// Returns the sound with the given name, or nil if nothing should play.
+	Sound func(name string) *sound.Sound
+}
+
+// Makes s play the sounds in its anim graph through sounds.  Call with nil
+// when done with s so that it stops tracking its position.
+func (s *Sprite) SetSounds(sounds *Sounds) {
+	if s.emitter != nil {
+		s.emitter.Close()
+		s.emitter = nil
+	}
+	s.sounds = sounds
+	if sounds != nil {
+		s.emitter = sounds.Listener.MakeEmitter(s.x, s.y, sounds.Group)
+	}
+}
+
+// Sets where s is in the world, which is where its sounds come from.
+func (s *Sprite) SetPosition(x, y float64) {
+	s.x, s.y = x, y
+	if s.emitter != nil {
+		s.emitter.SetPosition(x, y)
+	}
+}
+
+func (s *Sprite) Position() (x, y float64) {
+	return s.x, s.y
+}
+
+func (s *Sprite) doSound() {
+	name := s.anim_node.Tag("sound")
+	if s.emitter == nil || name == "" {
+		return
+	}
+	if snd := s.sounds.Sound(name); snd != nil {
+		s.emitter.Play(snd, 1, 1)
+	}
+}
@@ -6,16 +6,22 @@ import (
 	"errors"
 	"fmt"
 	gl "github.com/chsc/gogl/gl21"
+	"github.com/runningwild/glop/glog"
 	"github.com/runningwild/glop/render"
+	"github.com/runningwild/glop/save"
+	"github.com/runningwild/glop/sound"
 	"github.com/runningwild/glop/util/algorithm"
 	"github.com/runningwild/yedparse"
+	"io/fs"
 	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -30,15 +36,6 @@ func (e *spriteError) Error() string {
 	return e.Msg
 }
 
-// attempt to make a relative path, otherwise leaves it alone
-func tryRelPath(base, path string) string {
-	rel, err := filepath.Rel(base, path)
-	if err == nil {
-		return rel
-	}
-	return path
-}
-
 // utility function since we need to find the start node on any graph we use
 func getStartNode(g *yed.Graph) *yed.Node {
 	for i := 0; i < g.NumNodes(); i++ {
@@ -152,10 +149,12 @@ func verifyAnyGraph(graph *yed.Graph, node_tags, edge_tags []string) error {
 // specified in verifyAnyGraph():
 // * All output edges from the start node have labels
 // * No node has more than one unlabeled output edge
-// * There are no tags on any nodes except for the start node
+// * The only node tags are idle_after and idle_cmd, and mark on the start node
+// * Nodes with either idle tag have both of them
+// * idle_after is positive and idle_cmd has an output edge from its node
 // * There are no groups
 func verifyStateGraph(graph *yed.Graph) error {
-	err := verifyAnyGraph(graph, []string{}, []string{"facing"})
+	err := verifyAnyGraph(graph, []string{"idle_after", "idle_cmd"}, []string{"facing"})
 	if err != nil {
 		return &spriteError{fmt.Sprintf("State graph: %v", err)}
 	}
@@ -193,16 +192,104 @@ func verifyStateGraph(graph *yed.Graph) error {
 		}
 	}
 
+	// Check that idle tags make sense
+	for i := 0; i < graph.NumNodes(); i++ {
+		node := graph.Node(i)
+		after, cmd := node.Tag("idle_after"), node.Tag("idle_cmd")
+		if after == "" && cmd == "" {
+			continue
+		}
+		if after == "" || cmd == "" {
+			return &spriteError{fmt.Sprintf("State graph: node '%s' needs both idle_after and idle_cmd", node.Line(0))}
+		}
+		if t, err := strconv.ParseInt(after, 10, 64); err != nil || t <= 0 {
+			return &spriteError{fmt.Sprintf("State graph: node '%s' has an idle_after of '%s', which isn't a positive number", node.Line(0), after)}
+		}
+		found := false
+		for j := 0; j < node.NumOutputs(); j++ {
+			edge := node.Output(j)
+			if edge.NumLines() > 0 && edge.Line(0) == cmd {
+				found = true
+			}
+		}
+		if !found {
+			return &spriteError{fmt.Sprintf("State graph: node '%s' has an idle_cmd of '%s', but no edge for it", node.Line(0), cmd)}
+		}
+	}
+
 	return nil
 }
 
-// A valid anim graph has the properties specified in verifyAnyGraph()
+// The part of an anim graph that a sprite can move through without any time
+// passing: frames with a time of 0 and the edges between them that can be
+// followed without a command.  A sprite that gets into a loop in this graph
+// never leaves it.
+type zeroTimeGraph struct {
+	anim *yed.Graph
+}
+
+func isZeroTime(node *yed.Node) bool {
+	t, err := strconv.ParseFloat(node.Tag("time"), 64)
+	return err == nil && t == 0
+}
+
+func (z zeroTimeGraph) NumVertex() int {
+	return z.anim.NumNodes()
+}
+func (z zeroTimeGraph) Adjacent(n int) (adj []int, cost []float64) {
+	node := z.anim.Node(n)
+	if !isZeroTime(node) {
+		return
+	}
+	for i := 0; i < node.NumOutputs(); i++ {
+		edge := node.Output(i)
+		if edge.NumLines() > 0 && !strings.Contains(edge.Line(0), ":") {
+			continue
+		}
+		// Edges with a weight of 0 are never followed on their own.
+		if w, err := strconv.ParseFloat(edge.Tag("weight"), 64); err == nil && w == 0 {
+			continue
+		}
+		if isZeroTime(edge.Dst()) {
+			adj = append(adj, edge.Dst().Id())
+			cost = append(cost, 0)
+		}
+	}
+	return
+}
+
+// A valid anim graph has the properties specified in verifyAnyGraph() in
+// addition to the following:
+// * No loop can be followed forever without any time passing
 func verifyAnimGraph(graph *yed.Graph) error {
-	err := verifyAnyGraph(graph, []string{"time", "sync", "func", "state"}, []string{"facing", "weight"})
+	err := verifyAnyGraph(graph, []string{"time", "sync", "func", "sound", "state"}, []string{"facing", "weight"})
 	if err != nil {
 		return &spriteError{fmt.Sprintf("Anim graph: %v", err)}
 	}
 
+	for i := 0; i < graph.NumNodes(); i++ {
+		node := graph.Node(i)
+		if node.Tag("time") == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(node.Tag("time"), 64); err != nil {
+			glog.Warningf("Anim graph: frame '%s' has a time of '%s' that isn't a number, using %v instead.", node.Line(0), node.Tag("time"), defaultFrameTime)
+		}
+	}
+
+	cycles := algorithm.FindCycles(zeroTimeGraph{graph})
+	if len(cycles) > 0 {
+		var loops []string
+		for _, cycle := range cycles {
+			var names []string
+			for _, id := range append(cycle, cycle[0]) {
+				names = append(names, fmt.Sprintf("'%s'", graph.Node(id).Line(0)))
+			}
+			loops = append(loops, strings.Join(names, " -> "))
+		}
+		return &spriteError{fmt.Sprintf("Anim graph: these loops take no time, each one needs a frame with a non-zero time or an edge with a weight of 0: %s", strings.Join(loops, ", "))}
+	}
+
 	return nil
 }
 
@@ -211,39 +298,30 @@ func verifyAnimGraph(graph *yed.Graph) error {
 // * There is at most 1 other file immediately within path - a thumb.png
 // * All of the directories have names that are integers 0 - (n-1)
 // * No image is present in any facing that isn't present in the anim graph
-func verifyDirectoryStructure(path string, graph *yed.Graph) (num_facings int, filenames []string, err error) {
-	filepath.Walk(path, func(cpath string, info os.FileInfo, _err error) error {
-		if _err != nil {
-			err = _err
-			return err
-		}
-		if cpath == path {
-			return nil
-		}
-
+func verifyDirectoryStructure(fsys fs.FS, graph *yed.Graph) (num_facings int, filenames []string, err error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
 		// skip hidden files
-		if _, file := filepath.Split(cpath); file[0] == '.' {
-			return nil
+		if entry.Name()[0] == '.' {
+			continue
 		}
 
-		if info.IsDir() {
+		if entry.IsDir() {
 			num_facings++
-			return filepath.SkipDir
 		} else {
 			switch {
-			case info.Name() == "anim.xgml":
-			case info.Name() == "state.xgml":
-			case info.Name() == "thumb.png":
-			case strings.HasSuffix(info.Name(), ".gob"):
+			case entry.Name() == "anim.xgml":
+			case entry.Name() == "state.xgml":
+			case entry.Name() == "thumb.png":
+			case strings.HasSuffix(entry.Name(), ".gob"):
 			default:
-				err = &spriteError{fmt.Sprintf("Unexpected file found in sprite directory, %s", tryRelPath(path, cpath))}
-				return err
+				err = &spriteError{fmt.Sprintf("Unexpected file found in sprite directory, %s", entry.Name())}
+				return
 			}
 		}
-		return nil
-	})
-	if err != nil {
-		return
 	}
 	if num_facings == 0 {
 		err = &spriteError{"Found no facings in the sprite directory"}
@@ -260,36 +338,31 @@ func verifyDirectoryStructure(path string, graph *yed.Graph) (num_facings int, f
 
 	filenames_map := make(map[string]bool)
 	for facing := 0; facing < num_facings; facing++ {
-		cur := filepath.Join(path, fmt.Sprintf("%d", facing))
-		filepath.Walk(cur, func(cpath string, info os.FileInfo, _err error) error {
-			if _err != nil {
-				err = _err
-				return err
-			}
-			if cpath == cur {
-				return nil
-			}
-
+		cur := fmt.Sprintf("%d", facing)
+		entries, err = fs.ReadDir(fsys, cur)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
 			// skip hidden files
-			if _, file := filepath.Split(cpath); file[0] == '.' {
-				return nil
+			if entry.Name()[0] == '.' {
+				continue
 			}
 
-			if info.IsDir() {
-				err = &spriteError{fmt.Sprintf("Found a directory inside facing directory %d, %s", facing, tryRelPath(path, cpath))}
-				return err
+			cpath := path.Join(cur, entry.Name())
+			if entry.IsDir() {
+				err = &spriteError{fmt.Sprintf("Found a directory inside facing directory %d, %s", facing, cpath)}
+				return
 			}
-			if filepath.Ext(cpath) == ".png" {
-				base := filepath.Base(cpath)
-				if valid_names[base] {
-					filenames_map[base] = true
+			if path.Ext(cpath) == ".png" {
+				if valid_names[entry.Name()] {
+					filenames_map[entry.Name()] = true
 				} else {
-					err = &spriteError{fmt.Sprintf("Found an unused .png file: %s", tryRelPath(path, cpath))}
+					err = &spriteError{fmt.Sprintf("Found an unused .png file: %s", cpath)}
+					return
 				}
-				return err
 			}
-			return nil
-		})
+		}
 	}
 
 	for filename := range filenames_map {
@@ -315,10 +388,9 @@ func (cg *animAlgoGraph) Adjacent(n int) (adj []int, cost []float64) {
 	var delay float64 = defaultFrameTime
 	if node.Tag("time") != "" {
 		t, err := strconv.ParseFloat(node.Tag("time"), 64)
+		// Bad times were already warned about by verifyAnimGraph().
 		if err == nil {
 			delay = t
-		} else {
-			// TODO: Should log this as a warning or something
 		}
 	}
 	for i := 0; i < node.NumGroupOutputs(); i++ {
@@ -354,14 +426,31 @@ func (s *Sprite) Wait(states []string) {
 	<-w.c
 }
 
+// A Sprite is thought about on one goroutine and drawn on another.  Think(),
+// Command() and everything else that changes or asks about what the sprite
+// is doing must be called from the same goroutine, or otherwise kept from
+// running at the same time, which is usually the game's goroutine.  Bind(),
+// Render(), Dims() and Color() can be called from the render thread while
+// that goroutine is thinking, they draw the frame that the sprite was on when
+// the last Think(), Force*() or SetSpriteState() returned.  Wait() can be
+// called from any goroutine.
 type Sprite struct {
 	shared     *sharedSprite
 	anim_node  *yed.Node
 	state_node *yed.Node
 
+	// The shownFrame that Bind() draws, see show().
+	shown atomic.Value
+
 	// Used to run callbacks when certain frames of animations are hit.
 	trigger TriggerFunc
 
+	// Where the sprite is and what it plays "sound:" lines through, see
+	// SetSounds().
+	x, y    float64
+	sounds  *Sounds
+	emitter *sound.Emitter
+
 	// number of times Think() has been called.  This is mostly so that we can
 	// run some code the very first time that Think() is called.
 	thinks int
@@ -369,6 +458,10 @@ type Sprite struct {
 	// current facing - needed to index into the appropriate sheet in shared
 	facing int
 
+	// How each facing is drawn, nil if facings are the facing directories of
+	// the sprite, see LoadSpriteWithFacings().
+	facing_map []Facing
+
 	// previous facing - tracking this lets us prevent having to load/unload
 	// lots of facings if a sprite changes facings multiple times between thinks
 	prev_facing int
@@ -381,6 +474,31 @@ type Sprite struct {
 	// Time remaining on the current frame of animation
 	togo int64
 
+	// How long the sprite has been idle, for idle_after tags.
+	idle int64
+
+	// Milliseconds of Think() so far, and the last few anim nodes that the
+	// sprite went to, see Trace().
+	clock int64
+	trace []TraceStep
+
+	// Weights set with SetEdgeWeight(), these take the place of the weights in
+	// edge_data.
+	edge_weights map[*yed.Edge]float64
+
+	// See SetCatchUpPolicy().  frames is how many frames the current Think()
+	// has gone through, and skipped_trigger is set if the trigger for the
+	// last of them hasn't been fired yet.
+	catch_up        CatchUpPolicy
+	frames          int
+	skipped_trigger bool
+
+	// See SetTint() and SetLighting(), read by Color() on the render thread.
+	color_mutex sync.Mutex
+	tint        [4]float64
+	has_tint    bool
+	lighting    func(facing int) (r, g, b float64)
+
 	// If len(path) > 0 then this is the series of animation frames that will be
 	// used next
 	path []*yed.Node
@@ -499,8 +617,8 @@ func connectedByGroupEdge(n1, n2 *yed.Node) bool {
 
 // selects an outgoing edge from node random among those outgoing edges that
 // have cmd listed in cmds.  The random choice is weighted by the weights
-// found in edge_data
-func selectAnEdge(node *yed.Node, edge_data map[*yed.Edge]edgeData, cmds []string) *yed.Edge {
+// found in edge_data, or set with SetEdgeWeight()
+func (s *Sprite) selectAnEdge(node *yed.Node, cmds []string) *yed.Edge {
 	cmd_map := make(map[string]bool)
 	for _, cmd := range cmds {
 		cmd_map[cmd] = true
@@ -509,20 +627,20 @@ func selectAnEdge(node *yed.Node, edge_data map[*yed.Edge]edgeData, cmds []strin
 	total := 0.0
 	for i := 0; i < node.NumOutputs(); i++ {
 		edge := node.Output(i)
-		if _, ok := cmd_map[edge_data[edge].cmd]; !ok {
+		if _, ok := cmd_map[s.shared.edge_data[edge].cmd]; !ok {
 			continue
 		}
-		total += edge_data[edge].weight
+		total += s.edgeWeight(edge)
 	}
 	if total > 0 {
 		pick := rand.Float64() * total
 		total = 0.0
 		for i := 0; i < node.NumOutputs(); i++ {
 			edge := node.Output(i)
-			if _, ok := cmd_map[edge_data[edge].cmd]; !ok {
+			if _, ok := cmd_map[s.shared.edge_data[edge].cmd]; !ok {
 				continue
 			}
-			total += edge_data[edge].weight
+			total += s.edgeWeight(edge)
 			if total >= pick {
 				return edge
 			}
@@ -564,20 +682,20 @@ func CommandSync(ss []*Sprite, cmds [][]string, sync_tag string) {
 func (s *Sprite) baseCommand(cmd command) bool {
 	state_node := s.state_node
 	for _, name := range cmd.names {
-		state_edge := selectAnEdge(state_node, s.shared.edge_data, []string{name})
+		state_edge := s.selectAnEdge(state_node, []string{name})
 		if state_edge == nil {
 			return false
 		}
 		state_node = state_edge.Dst()
 	}
 	for _, name := range cmd.names {
-		edge := selectAnEdge(s.state_node, s.shared.edge_data, []string{name})
+		edge := s.selectAnEdge(s.state_node, []string{name})
 		s.state_node = edge.Dst()
 		face := s.shared.edge_data[edge].facing
-		s.state_facing = (s.state_facing + face + len(s.shared.facings)) % len(s.shared.facings)
+		s.state_facing = (s.state_facing + face + s.NumFacings()) % s.NumFacings()
 	}
 
-	state_edge := selectAnEdge(s.state_node, s.shared.edge_data, []string{""})
+	state_edge := s.selectAnEdge(s.state_node, []string{""})
 	for state_edge != nil {
 		// If this command is synced then we first need to make sure that we'll
 		// be able to get to the appropriate sync tag
@@ -586,7 +704,7 @@ func (s *Sprite) baseCommand(cmd command) bool {
 		//   s.shared.node_data
 		// }
 		s.state_node = state_edge.Dst()
-		state_edge = selectAnEdge(s.state_node, s.shared.edge_data, []string{""})
+		state_edge = s.selectAnEdge(s.state_node, []string{""})
 	}
 
 	s.pending_cmds = append(s.pending_cmds, cmd)
@@ -665,7 +783,7 @@ func (s *Sprite) findPathForSyncedCmd(cmd command, anim_node *yed.Node) []*yed.N
 	var extra []*yed.Node
 	adds := make(map[*yed.Node]bool)
 	tail := path[len(path)-1]
-	edge := selectAnEdge(tail, s.shared.edge_data, []string{""})
+	edge := s.selectAnEdge(tail, []string{""})
 	for !adds[tail] && edge != nil {
 		adds[tail] = true
 		tail = edge.Dst()
@@ -673,7 +791,7 @@ func (s *Sprite) findPathForSyncedCmd(cmd command, anim_node *yed.Node) []*yed.N
 		if tail.Tag("sync") == cmd.group.sync_tag {
 			break
 		}
-		edge = selectAnEdge(tail, s.shared.edge_data, []string{""})
+		edge = s.selectAnEdge(tail, []string{""})
 	}
 	if len(extra) > 0 && extra[len(extra)-1].Tag("sync") == cmd.group.sync_tag {
 		for _, node := range extra {
@@ -688,14 +806,20 @@ func (s *Sprite) findPathForCmd(cmd command, anim_node *yed.Node) []*yed.Node {
 	var node_path []*yed.Node
 	for _, name := range cmd.names {
 		g := pathingGraph{shared: s.shared, start: anim_node, cmd: name}
-		var end []int
-		for i := 0; i < s.shared.anim.NumEdges(); i++ {
-			edge := s.shared.anim.Edge(i)
-			if s.shared.edge_data[edge].cmd == name {
-				end = append(end, edge.Dst().Id())
+		key := algorithm.PathKey{Start: anim_node.Id(), End: -1, Tag: name}
+		_, path := s.shared.paths.Path(key, func() (float64, []int) {
+			var end []int
+			for i := 0; i < s.shared.anim.NumEdges(); i++ {
+				edge := s.shared.anim.Edge(i)
+				if s.shared.edge_data[edge].cmd == name {
+					end = append(end, edge.Dst().Id())
+				}
 			}
+			return algorithm.Dijkstra(g, []int{s.shared.anim.NumNodes()}, end)
+		})
+		if len(path) == 0 {
+			return nil
 		}
-		_, path := algorithm.Dijkstra(g, []int{s.shared.anim.NumNodes()}, end)
 		for _, id := range path[1:] {
 			node_path = append(node_path, s.shared.anim.Node(id))
 		}
@@ -716,10 +840,10 @@ func (s *Sprite) applyPath(path []*yed.Node) {
 func (s *Sprite) Dims() (dx, dy int) {
 	var rect FrameRect
 	var ok bool
-	fid := frameId{facing: s.facing, node: s.anim_node.Id()}
+	fid, facing := s.shownFrame()
 	rect, ok = s.shared.connector.rects[fid]
 	if !ok {
-		rect, ok = s.shared.facings[s.facing].rects[fid]
+		rect, ok = s.facingSheet(facing).rects[fid]
 		if !ok {
 			return 0, 0
 		}
@@ -729,27 +853,43 @@ func (s *Sprite) Dims() (dx, dy int) {
 	return
 }
 
+// Binds the texture with the frame that s is showing and returns where the
+// frame is in it.  Bind() doesn't apply the sprite's tint or lighting, code
+// that draws with it should use Color().
 func (s *Sprite) Bind() (x, y, x2, y2 float64) {
+	texture, x, y, x2, y2 := s.frame()
+	texture.Bind()
+	return
+}
+
+// Returns the texture with the frame that s is showing and where the frame is
+// in it, with y at the top of the frame.  Frames that have no image are in the
+// error texture.
+func (s *Sprite) frame() (texture *render.Texture, x, y, x2, y2 float64) {
 	var rect FrameRect
 	var sh *sheet
 	var ok bool
-	fid := frameId{facing: s.facing, node: s.anim_node.Id()}
+	fid, facing := s.shownFrame()
 	var dx, dy float64
 	if rect, ok = s.shared.connector.rects[fid]; ok {
 		sh = s.shared.connector
-	} else if rect, ok = s.shared.facings[s.facing].rects[fid]; ok {
-		sh = s.shared.facings[s.facing]
+	} else if rect, ok = s.facingSheet(facing).rects[fid]; ok {
+		sh = s.facingSheet(facing)
 	} else {
-		gl.BindTexture(gl.TEXTURE_2D, error_texture)
+		s.shared.reportMissing(fid)
+		texture = error_texture
 		return
 	}
-	gl.BindTexture(gl.TEXTURE_2D, sh.texture)
+	texture = sh.texture
 	dx = float64(sh.dx)
 	dy = float64(sh.dy)
 	x = float64(rect.X) / dx
 	y = float64(rect.Y) / dy
 	x2 = float64(rect.X2) / dx
 	y2 = float64(rect.Y2) / dy
+	if s.facingAsset(facing).Mirror {
+		x, x2 = x2, x
+	}
 	return
 }
 func (s *Sprite) Facing() int {
@@ -763,17 +903,24 @@ func (s *Sprite) doTrigger() {
 		s.anim_node.Tag("func") != "" {
 		s.trigger(s, s.anim_node.Tag("func"))
 	}
+	s.doSound()
 }
 
 type spriteStateInternal struct {
 	Facing        int
 	State_node_id int
 	Anim_node_id  int
+
+	// The labels of the nodes, so that a state still works after the graphs
+	// have been edited and the ids have moved around.  States from before
+	// these were added don't have them and just use the ids.
+	State_node string
+	Anim_node  string
 }
 
 // An opaque object that contains everything necessary to start a sprite from
 // a particular point.  Useful when rewinding something, for example.
-// Gobbable.
+// Gobbable, and registered with the save package.
 type SpriteState struct {
 	internals spriteStateInternal
 }
@@ -798,35 +945,113 @@ func (s *Sprite) GetSpriteState() SpriteState {
 			Facing:        s.facing,
 			State_node_id: s.state_node.Id(),
 			Anim_node_id:  s.anim_node.Id(),
+			State_node:    s.state_node.Line(0),
+			Anim_node:     s.anim_node.Line(0),
 		},
 	}
 }
 
+// Finds the node that a SpriteState refers to by id and label.  The id is
+// checked first since labels don't have to be unique.
+func findStateNode(graph *yed.Graph, id int, label string) *yed.Node {
+	if id >= 0 && id < graph.NumNodes() {
+		if node := graph.Node(id); label == "" || node.Line(0) == label {
+			return node
+		}
+	}
+	if label == "" {
+		return nil
+	}
+	for i := 0; i < graph.NumNodes(); i++ {
+		if graph.Node(i).Line(0) == label {
+			return graph.Node(i)
+		}
+	}
+	return nil
+}
+
 func (s *Sprite) SetSpriteState(state SpriteState) error {
-	if len(s.waiters) != 0 {
+	s.waiter_mutex.Lock()
+	waiters := len(s.waiters)
+	s.waiter_mutex.Unlock()
+	if waiters != 0 {
 		return errors.New("Can't SetSpriteState while there are pending waiters.")
 	}
+	anim_node := findStateNode(s.shared.anim, state.internals.Anim_node_id, state.internals.Anim_node)
+	state_node := findStateNode(s.shared.state, state.internals.State_node_id, state.internals.State_node)
+	if anim_node == nil || state_node == nil {
+		return fmt.Errorf("Sprite %s has no anim node '%s' or state node '%s'.", s.shared.path, state.internals.Anim_node, state.internals.State_node)
+	}
+	if state.internals.Facing < 0 || state.internals.Facing >= s.NumFacings() {
+		return fmt.Errorf("Sprite %s has no facing %d.", s.shared.path, state.internals.Facing)
+	}
 	if s.thinks == 0 {
 		s.prev_facing = s.facing
 		s.facing = state.internals.Facing
 		s.state_facing = s.facing
-		s.shared.facings[s.facing].Load()
+		s.facingSheet(s.facing).Load()
 	} else if state.internals.Facing != s.facing {
 		// s.shared.facings[s.facing].Unload()
 		s.facing = state.internals.Facing
 		s.state_facing = s.facing
-		s.shared.facings[s.facing].Load()
+		s.facingSheet(s.facing).Load()
 	}
-	s.anim_node = s.shared.anim.Node(state.internals.Anim_node_id)
-	s.state_node = s.shared.state.Node(state.internals.State_node_id)
+	s.anim_node = anim_node
+	s.state_node = state_node
 	s.path = nil
 	s.pending_cmds = nil
+	s.idle = 0
+	s.traceStep()
+	s.show()
 	return nil
 }
 
 func (s *Sprite) Think(dt int64) {
+	if s.catch_up.MaxDt > 0 && dt > s.catch_up.MaxDt {
+		s.clock += dt - s.catch_up.MaxDt
+		dt = s.catch_up.MaxDt
+	}
+	s.thinkIdle(dt)
+	s.frames = 0
+	s.skipped_trigger = false
+	s.think(dt)
+	if s.skipped_trigger {
+		s.skipped_trigger = false
+		s.doTrigger()
+	}
+	s.show()
+}
+
+// Gives the sprite its state's idle_cmd once it has been idle in that state
+// for idle_after milliseconds.
+func (s *Sprite) thinkIdle(dt int64) {
+	if !s.Idle() || dt < 0 {
+		s.idle = 0
+		return
+	}
+	s.idle += dt
+	data := s.shared.state_data[s.state_node]
+	if data.idle_after > 0 && s.idle >= data.idle_after {
+		s.idle = 0
+		s.Command(data.idle_cmd)
+	}
+}
+
+// Loads the sheet for the current facing and unloads the one for the facing
+// it was on the last time this was called.
+func (s *Sprite) settleFacing() {
+	if s.facing != s.prev_facing {
+		if prev, cur := s.facingSheet(s.prev_facing), s.facingSheet(s.facing); prev != cur {
+			prev.Unload()
+			cur.Load()
+		}
+		s.prev_facing = s.facing
+	}
+}
+
+func (s *Sprite) think(dt int64) {
 	if s.thinks == 0 {
-		s.shared.facings[0].Load()
+		s.facingSheet(0).Load()
 		s.togo = s.shared.node_data[s.anim_node].time
 	}
 	s.thinks++
@@ -840,6 +1065,8 @@ func (s *Sprite) Think(dt int64) {
 		if s.NumPendingCmds() > 0 {
 			return
 		}
+		s.waiter_mutex.Lock()
+		defer s.waiter_mutex.Unlock()
 		for i := range s.waiters {
 			for _, state := range s.waiters[i].states {
 				if state == s.AnimState() {
@@ -847,10 +1074,10 @@ func (s *Sprite) Think(dt int64) {
 					s.waiters[i].states = nil
 				}
 			}
-			algorithm.Choose(&s.waiters, func(w *waiter) bool {
-				return w.states != nil
-			})
 		}
+		s.waiters = algorithm.Filter(s.waiters, func(w *waiter) bool {
+			return w.states != nil
+		})
 	}()
 
 	var path []*yed.Node
@@ -863,7 +1090,8 @@ func (s *Sprite) Think(dt int64) {
 			if t <= 0 {
 				path = s.pending_cmds[0].group.paths[s]
 				s.anim_node = path[0]
-				s.doTrigger()
+				s.traceStep()
+				s.enterFrame()
 				s.togo = s.shared.node_data[s.anim_node].time
 				path = path[1:]
 			}
@@ -891,20 +1119,18 @@ func (s *Sprite) Think(dt int64) {
 	}
 	if s.togo >= dt {
 		s.togo -= dt
-		if s.facing != s.prev_facing {
-			s.shared.facings[s.prev_facing].Unload()
-			s.shared.facings[s.facing].Load()
-			s.prev_facing = s.facing
-		}
+		s.clock += dt
+		s.settleFacing()
 		return
 	}
 	dt -= s.togo
+	s.clock += s.togo
 	var next *yed.Node
 	if len(s.path) > 0 {
 		next = s.path[0]
 		s.path = s.path[1:]
 	} else {
-		edge := selectAnEdge(s.anim_node, s.shared.edge_data, []string{""})
+		edge := s.selectAnEdge(s.anim_node, []string{""})
 		if edge != nil {
 			next = edge.Dst()
 		} else {
@@ -916,13 +1142,19 @@ func (s *Sprite) Think(dt int64) {
 		edge = edgeTo(s.anim_node, next)
 		face := s.shared.edge_data[edge].facing
 		if face != 0 {
-			s.facing = (s.facing + face + len(s.shared.facings)) % len(s.shared.facings)
+			s.facing = (s.facing + face + s.NumFacings()) % s.NumFacings()
 		}
 	}
 	s.anim_node = next
-	s.doTrigger()
+	s.traceStep()
+	s.enterFrame()
 	s.togo = s.shared.node_data[s.anim_node].time
-	s.Think(dt)
+	if s.caughtUp() {
+		s.clock += dt
+		s.settleFacing()
+		return
+	}
+	s.think(dt)
 }
 
 type nodeData struct {
@@ -932,6 +1164,12 @@ type nodeData struct {
 	// The state that this frame of animation belongs to
 	state string
 }
+type stateData struct {
+	// From the idle_after and idle_cmd tags, 0 and "" if the state doesn't
+	// have them.
+	idle_after int64
+	idle_cmd   string
+}
 type edgeData struct {
 	facing int
 	weight float64
@@ -954,80 +1192,132 @@ type FrameRect struct {
 type TriggerFunc func(*Sprite, string)
 
 type Manager struct {
-	shared map[string]*sharedSprite
+	shared map[spriteKey]*sharedSprite
 	mutex  sync.Mutex
+
+	// Graphs loaded from directories on disk, by directory, so that sprites
+	// that use the same graphs share them.  Guarded by mutex.
+	graphs map[string]*spriteGraphs
+
+	// A DecodePolicy, read with sync/atomic since sheets check it from their
+	// own goroutines.
+	policy int32
+
+	// Guards on_missing and the missing frames of every sprite, separately
+	// from mutex so that Bind() doesn't wait on sprites that are loading.
+	missing_mutex sync.Mutex
+	on_missing    func(sprite_path, node string, facing int)
+
+	// Sprites added with Register(), in the order they were added, and their
+	// tags.  Guarded by mutex.
+	registered []*Sprite
+	tags       map[*Sprite]map[string]bool
+}
+
+// Sprites loaded from disk have a nil fsys.  graphs is the directory that
+// their graphs come from, if it isn't path, see LoadSpriteWithGraphs().
+type spriteKey struct {
+	fsys   fs.FS
+	path   string
+	graphs string
 }
 
 func MakeManager() *Manager {
 	var m Manager
-	m.shared = make(map[string]*sharedSprite)
+	m.shared = make(map[spriteKey]*sharedSprite)
+	m.graphs = make(map[string]*spriteGraphs)
 	return &m
 }
 
 var the_manager *Manager
-var error_texture gl.Uint
+var error_texture *render.Texture
 var gen_tex_once sync.Once
 
 func init() {
 	the_manager = MakeManager()
+	save.Register("glop/sprite.SpriteState", 1, SpriteState{})
 }
 func LoadSprite(path string) (*Sprite, error) {
 	return the_manager.LoadSprite(path)
 }
-func (m *Manager) loadSharedSprite(path string) error {
+
+func LoadSpriteFS(fsys fs.FS, dir string) (*Sprite, error) {
+	return the_manager.LoadSpriteFS(fsys, dir)
+}
+
+func (m *Manager) loadSharedSprite(key spriteKey) (*sharedSprite, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	if _, ok := m.shared[path]; ok {
-		return nil
+	if ss, ok := m.shared[key]; ok {
+		return ss, nil
 	}
 
-	ss, err := loadSharedSprite(path)
+	var ss *sharedSprite
+	var err error
+	if key.fsys == nil {
+		graphs := key.graphs
+		if graphs == "" {
+			graphs = key.path
+		}
+		var g *spriteGraphs
+		g, err = m.loadGraphs(graphs)
+		if err == nil {
+			ss, err = loadSharedSpriteWithGraphs(m, g, os.DirFS(key.path), key.path, key.path)
+		}
+	} else {
+		var sub fs.FS
+		sub, err = fs.Sub(key.fsys, key.path)
+		if err == nil {
+			ss, err = loadSharedSprite(m, sub, "", key.path)
+		}
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	m.shared[path] = ss
-	ss.manager = m
-	return nil
+	m.shared[key] = ss
+	return ss, nil
 }
 
 func (m *Manager) LoadSprite(path string) (*Sprite, error) {
-	// We can't run this during an init() function because it will get queued to
-	// run before the opengl context is created, so we just check here and run
-	// it if we haven't run it before.
+	return m.loadSprite(spriteKey{path: filepath.Clean(path)})
+}
+
+// Loads the sprite in the directory dir in fsys, which could be an
+// assets.FS or an embed.FS.  Sprites loaded this way don't cache their
+// sprite sheets, since there is nowhere to write them, but if the sheets
+// were already cached in the sprite's directory they are used.  fsys must
+// be comparable, like a pointer, an os.DirFS, or an embed.FS.
+func (m *Manager) LoadSpriteFS(fsys fs.FS, dir string) (*Sprite, error) {
+	return m.loadSprite(spriteKey{fsys: fsys, path: path.Clean(dir)})
+}
+
+// We can't run this during an init() function because it will get queued to
+// run before the opengl context is created, so we just check here and run it
+// if we haven't run it before.
+func setupRendering() {
 	gen_tex_once.Do(func() {
 		render.Queue(func() {
-			gl.Enable(gl.TEXTURE_2D)
-			gl.GenTextures(1, &error_texture)
-			gl.BindTexture(gl.TEXTURE_2D, error_texture)
-			gl.TexEnvf(gl.TEXTURE_ENV, gl.TEXTURE_ENV_MODE, gl.MODULATE)
-			gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-			gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-			gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-			gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-			pink := []byte{255, 0, 255, 255}
-			gl.TexImage2D(
-				gl.TEXTURE_2D,
-				0,
-				gl.RGBA,
-				1,
-				1,
-				0,
-				gl.RGBA,
-				gl.UNSIGNED_INT,
-				gl.Pointer(&pink[0]))
+			if render.HasGl() {
+				gl.Enable(gl.TEXTURE_2D)
+				gl.TexEnvf(gl.TEXTURE_ENV, gl.TEXTURE_ENV_MODE, gl.MODULATE)
+			}
+			error_texture = render.Textures().LoadRGBA([]byte{255, 0, 255, 255}, 1, 1)
 		})
 	})
+}
 
-	path = filepath.Clean(path)
-	err := m.loadSharedSprite(path)
+func (m *Manager) loadSprite(key spriteKey) (*Sprite, error) {
+	setupRendering()
+
+	ss, err := m.loadSharedSprite(key)
 	if err != nil {
 		return nil, err
 	}
 	var s Sprite
-	m.mutex.Lock()
-	s.shared = m.shared[path]
-	m.mutex.Unlock()
+	s.shared = ss
 	s.anim_node = s.shared.anim_start
 	s.state_node = s.shared.state_start
+	s.traceStep()
+	s.show()
 	return &s, nil
 }
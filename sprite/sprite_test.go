@@ -1,6 +1,9 @@
 package sprite_test
 
 import (
+  "bytes"
+  "github.com/runningwild/glop/assets"
+  "github.com/runningwild/glop/save"
   "github.com/runningwild/glop/sprite"
   . "github.com/orfjackal/gospec/src/gospec"
   "github.com/orfjackal/gospec/src/gospec"
@@ -39,6 +42,36 @@ func LoadSpriteSpec(c gospec.Context) {
     }
     c.Expect(s.Facing(), Equals, 1)
   })
+  c.Specify("Sample sprite loads from an assets.FS", func() {
+    v := assets.MakeFS()
+    defer v.Close()
+    c.Assume(v.MountDir("sprites", "."), Equals, nil)
+    s, err := sprite.LoadSpriteFS(v, "sprites/test_sprite")
+    c.Expect(err, Equals, nil)
+    s.Command("defend")
+    for i := 0; i < 100; i++ {
+      s.Think(50)
+    }
+    _, err = sprite.LoadSpriteFS(v, "sprites/no_sprite")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Sprite states can be saved", func() {
+    s, err := sprite.LoadSprite("test_sprite")
+    c.Assume(err, Equals, nil)
+    s.Command("turn_right")
+    for i := 0; i < 100; i++ {
+      s.Think(50)
+    }
+    var b bytes.Buffer
+    c.Assume(save.WriteGame(&b, save.Game{"guy": s.GetSpriteState()}), Equals, nil)
+    game, err := save.ReadGame(&b)
+    c.Assume(err, Equals, nil)
+    s2, err := sprite.LoadSprite("test_sprite")
+    c.Assume(err, Equals, nil)
+    c.Expect(s2.SetSpriteState(game["guy"].(sprite.SpriteState)), Equals, nil)
+    c.Expect(s2.Facing(), Equals, s.Facing())
+    c.Expect(s2.Anim(), Equals, s.Anim())
+  })
 }
 
 func CommandNSpec(c gospec.Context) {
@@ -88,3 +121,83 @@ func SyncSpec(c gospec.Context) {
     c.Expect(hit, Equals, true)
   })
 }
+
+func DecodePolicySpec(c gospec.Context) {
+  c.Specify("Eager sprites keep their frames decoded until the policy is Lazy", func() {
+    m := sprite.MakeManager()
+    c.Expect(m.DecodePolicy(), Equals, sprite.Lazy)
+    m.SetDecodePolicy(sprite.Eager)
+    _, err := m.LoadSprite("test_sprite")
+    c.Assume(err, Equals, nil)
+    pixels, _ := m.ResidentBytes()
+    c.Expect(pixels > 0, Equals, true)
+    m.SetDecodePolicy(sprite.Lazy)
+    pixels, _ = m.ResidentBytes()
+    c.Expect(pixels, Equals, int64(0))
+  })
+}
+
+func CommandAllSpec(c gospec.Context) {
+  c.Specify("CommandAll only commands the registered sprites that match", func() {
+    m := sprite.MakeManager()
+    var sprites []*sprite.Sprite
+    for i := 0; i < 3; i++ {
+      s, err := m.LoadSprite("test_sprite")
+      c.Assume(err, Equals, nil)
+      sprites = append(sprites, s)
+    }
+    m.Register(sprites[0], "soldier")
+    m.Register(sprites[1], "soldier", "officer")
+    m.Register(sprites[2])
+    c.Expect(m.CommandAll(m.Tagged("soldier"), "defend"), Equals, 2)
+    c.Expect(sprites[0].NumPendingCmds(), Equals, 1)
+    c.Expect(sprites[1].NumPendingCmds(), Equals, 1)
+    c.Expect(sprites[2].NumPendingCmds(), Equals, 0)
+    m.Unregister(sprites[0])
+    c.Expect(m.CommandAll(nil, "undamaged"), Equals, 1)
+    c.Expect(sprites[0].NumPendingCmds(), Equals, 1)
+    c.Expect(sprites[1].NumPendingCmds(), Equals, 2)
+    c.Expect(sprites[2].NumPendingCmds(), Equals, 0)
+    c.Expect(len(m.Registered(m.Tagged("officer"))), Equals, 1)
+  })
+}
+
+func ForceSpec(c gospec.Context) {
+  c.Specify("Sprites can be forced onto frames and states", func() {
+    s, err := sprite.LoadSprite("test_sprite")
+    c.Assume(err, Equals, nil)
+    s.Command("defend")
+    c.Expect(s.ForceAnimNode("melee_02"), Equals, nil)
+    c.Expect(s.Anim(), Equals, "melee_02")
+    c.Expect(s.State(), Equals, s.AnimState())
+    c.Expect(s.NumPendingCmds(), Equals, 0)
+    c.Expect(s.ForceState("defending"), Equals, nil)
+    c.Expect(s.State(), Equals, "defending")
+    c.Expect(s.AnimState(), Equals, "defending")
+    s.Command("undamaged")
+    c.Expect(s.NumPendingCmds(), Equals, 1)
+    c.Expect(s.ForceAnimNode("nothing_01"), Not(Equals), nil)
+    c.Expect(s.ForceState("nothing"), Not(Equals), nil)
+  })
+}
+
+func TimelineSpec(c gospec.Context) {
+  c.Specify("Timelines follow a command without changing the sprite", func() {
+    s, err := sprite.LoadSprite("test_sprite")
+    c.Assume(err, Equals, nil)
+    frames := s.Timeline("melee", 1)
+    c.Expect(len(frames) > 0, Equals, true)
+    melee := false
+    for _, frame := range frames {
+      c.Expect(frame.Facing, Equals, 1)
+      c.Expect(frame.Image, Equals, "1/"+frame.Anim+".png")
+      if frame.Anim == "melee_01" {
+        melee = true
+      }
+    }
+    c.Expect(melee, Equals, true)
+    c.Expect(s.Anim(), Equals, "ready_01")
+    c.Expect(s.NumPendingCmds(), Equals, 0)
+    c.Expect(len(s.Timeline("undamaged", 0)), Equals, 0)
+  })
+}
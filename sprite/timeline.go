@@ -0,0 +1,90 @@
+package sprite
+
+import (
+	"github.com/runningwild/yedparse"
+)
+
+// One frame of a Timeline().
+type ScrubFrame struct {
+	// The label of the frame's node in the anim graph.
+	Anim string
+
+	// The frame's image, relative to the sprite's directory, like
+	// "0/walk_01.png".  The image might not exist, in which case the sprite
+	// shows its error texture for this frame.
+	Image string
+
+	// The sprite's facing while it's on this frame, which is the facing that
+	// the game sees, like Facing() is.
+	Facing int
+
+	// How many milliseconds the frame is shown for.
+	Time int64
+}
+
+// Returns the frames that s would most likely go through if it was given cmd
+// right now while facing facing, for tools that preview animations without
+// running sprites.  The path to cmd is followed and then whichever edge
+// without a command has the most weight is, until a frame comes up again.
+// An empty cmd just follows edges from the current frame.  Returns nil if s
+// can't do cmd from the frame it's on.  s isn't changed.
+func (s *Sprite) Timeline(cmd string, facing int) []ScrubFrame {
+	var path []*yed.Node
+	if cmd != "" {
+		path = s.findPathForCmd(command{names: []string{cmd}}, s.anim_node)
+		if len(path) == 0 {
+			return nil
+		}
+	}
+	seen := make(map[*yed.Node]bool)
+	for _, node := range path {
+		seen[node] = true
+	}
+	tail := s.anim_node
+	if len(path) > 0 {
+		tail = path[len(path)-1]
+	}
+	for len(path) < s.shared.anim.NumNodes()+1 {
+		edge := s.likeliestEdge(tail)
+		if edge == nil || seen[edge.Dst()] {
+			break
+		}
+		tail = edge.Dst()
+		seen[tail] = true
+		path = append(path, tail)
+	}
+
+	var frames []ScrubFrame
+	prev := s.anim_node
+	for _, node := range path {
+		if edge := edgeTo(prev, node); edge != nil {
+			facing = (facing + s.shared.edge_data[edge].facing + s.NumFacings()) % s.NumFacings()
+		}
+		fid := frameId{facing: s.facingAsset(facing).Asset, node: node.Id()}
+		frames = append(frames, ScrubFrame{
+			Anim:   node.Line(0),
+			Image:  framePath(s.shared.anim, fid),
+			Facing: facing,
+			Time:   s.shared.node_data[node].time,
+		})
+		prev = node
+	}
+	return frames
+}
+
+// Returns the edge without a command that leaves node with the most weight,
+// or nil if there isn't one.
+func (s *Sprite) likeliestEdge(node *yed.Node) *yed.Edge {
+	var best *yed.Edge
+	for i := 0; i < node.NumGroupOutputs(); i++ {
+		edge := node.GroupOutput(i)
+		weight := s.edgeWeight(edge)
+		if s.shared.edge_data[edge].cmd != "" || weight <= 0 {
+			continue
+		}
+		if best == nil || weight > s.edgeWeight(best) {
+			best = edge
+		}
+	}
+	return best
+}
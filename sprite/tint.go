@@ -0,0 +1,72 @@
+package sprite
+
+import (
+	"github.com/runningwild/glop/render"
+)
+
+// Multiplies the color of the sprite by r, g, b, and a, which are usually
+// between 0 and 1, for things like flashing red when damaged or fading out.
+// The default is 1, 1, 1, 1.
+func (s *Sprite) SetTint(r, g, b, a float64) {
+	s.color_mutex.Lock()
+	defer s.color_mutex.Unlock()
+	s.tint = [4]float64{r, g, b, a}
+	s.has_tint = true
+}
+
+// Sets a function that gives the color of the light falling on the sprite
+// from the direction it is facing, for things like day and night or a torch
+// on one side.  It is called with Facing() each time the sprite is drawn, and
+// its color is multiplied with the tint.  nil turns lighting off.
+func (s *Sprite) SetLighting(lighting func(facing int) (r, g, b float64)) {
+	s.color_mutex.Lock()
+	defer s.color_mutex.Unlock()
+	s.lighting = lighting
+}
+
+// Returns the color that the sprite should be drawn with, which is its tint
+// multiplied by its lighting, in the form that render.QuadBatch.Add() takes.
+// Render() uses this, code that draws with Bind() should too.
+func (s *Sprite) Color() [4]float32 {
+	c := [4]float64{1, 1, 1, 1}
+	s.color_mutex.Lock()
+	if s.has_tint {
+		c = s.tint
+	}
+	lighting := s.lighting
+	s.color_mutex.Unlock()
+	if lighting != nil {
+		_, facing := s.shownFrame()
+		r, g, b := lighting(facing)
+		c[0] *= r
+		c[1] *= g
+		c[2] *= b
+	}
+	return [4]float32{float32(c[0]), float32(c[1]), float32(c[2]), float32(c[3])}
+}
+
+// Render-thread only, reused by every call to Render().
+var render_batch render.QuadBatch
+
+// Draws the frame that s is showing with its bottom left corner at (x, y) in
+// screen coordinates, scaled by scale, and colored by Color().  Frames that
+// have no image, and frames whose sheets aren't loaded yet, aren't drawn.
+// Must be called on the render thread.
+func (s *Sprite) Render(x, y, scale float64) error {
+	render.MustRunOnRenderThread()
+	texture, u, v, u2, v2 := s.frame()
+	if texture == nil {
+		return nil
+	}
+	dx, dy := s.Dims()
+	if dx == 0 || dy == 0 {
+		return nil
+	}
+	// v is the top of the frame in its sheet and v2 the bottom, so they are
+	// swapped to draw it upright.
+	render_batch.Add(
+		float32(x), float32(y), float32(x+float64(dx)*scale), float32(y+float64(dy)*scale),
+		float32(u), float32(v2), float32(u2), float32(v),
+		s.Color())
+	return render_batch.Draw(texture)
+}
@@ -0,0 +1,29 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+)
+
+func TintSpec(c gospec.Context) {
+	s, err := sprite.LoadSprite("test_sprite")
+	c.Assume(err, Equals, nil)
+	c.Specify("Sprites are drawn white until they're tinted.", func() {
+		c.Expect(s.Color(), Equals, [4]float32{1, 1, 1, 1})
+		s.SetTint(1, 0.5, 0.5, 0.25)
+		c.Expect(s.Color(), Equals, [4]float32{1, 0.5, 0.5, 0.25})
+	})
+	c.Specify("Lighting is multiplied with the tint for the sprite's facing.", func() {
+		s.SetTint(0.5, 0.5, 1, 1)
+		s.SetLighting(func(facing int) (r, g, b float64) {
+			if facing == 0 {
+				return 1, 0.5, 0.5
+			}
+			return 0, 0, 0
+		})
+		c.Expect(s.Color(), Equals, [4]float32{0.5, 0.25, 0.5, 1})
+		s.SetLighting(nil)
+		c.Expect(s.Color(), Equals, [4]float32{0.5, 0.5, 1, 1})
+	})
+}
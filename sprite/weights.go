@@ -0,0 +1,50 @@
+package sprite
+
+import (
+	"fmt"
+	"github.com/runningwild/yedparse"
+)
+
+// Overrides the weight of the edges from the node labeled from to the node
+// labeled to, in both the anim and the state graph, for just this sprite.
+// Weights decide which edge is taken when more than one could be, so this
+// can bias idles and variations at runtime, like having an injured character
+// favor limping frames.  An edge with a weight of 0 is never picked.  Returns
+// an error if there is no such edge or the weight is negative.
+func (s *Sprite) SetEdgeWeight(from, to string, weight float64) error {
+	if weight < 0 {
+		return fmt.Errorf("Edge weights can't be negative, got %v.", weight)
+	}
+	var edges []*yed.Edge
+	for _, graph := range []*yed.Graph{s.shared.anim, s.shared.state} {
+		for i := 0; i < graph.NumEdges(); i++ {
+			edge := graph.Edge(i)
+			if edge.Src().Line(0) == from && edge.Dst().Line(0) == to {
+				edges = append(edges, edge)
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return fmt.Errorf("Sprite %s has no edge from '%s' to '%s'.", s.shared.path, from, to)
+	}
+	if s.edge_weights == nil {
+		s.edge_weights = make(map[*yed.Edge]float64)
+	}
+	for _, edge := range edges {
+		s.edge_weights[edge] = weight
+	}
+	return nil
+}
+
+// Goes back to the weights in the sprite's graphs for every edge.
+func (s *Sprite) ClearEdgeWeights() {
+	s.edge_weights = nil
+}
+
+// Returns the weight of edge for this sprite.
+func (s *Sprite) edgeWeight(edge *yed.Edge) float64 {
+	if weight, ok := s.edge_weights[edge]; ok {
+		return weight
+	}
+	return s.shared.edge_data[edge].weight
+}
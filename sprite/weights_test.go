@@ -0,0 +1,28 @@
+package sprite_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/sprite"
+)
+
+func EdgeWeightSpec(c gospec.Context) {
+	s, err := sprite.LoadSprite("test_sprite")
+	c.Assume(err, Equals, nil)
+	c.Specify("Edges weighted 0 aren't taken.", func() {
+		c.Expect(s.SetEdgeWeight("ready_01", "ready_02", 0), Equals, nil)
+		for i := 0; i < 20; i++ {
+			s.Think(50)
+		}
+		c.Expect(s.Anim(), Equals, "ready_01")
+		s.ClearEdgeWeights()
+		for i := 0; i < 20 && s.Anim() == "ready_01"; i++ {
+			s.Think(50)
+		}
+		c.Expect(s.Anim(), Not(Equals), "ready_01")
+	})
+	c.Specify("Only edges in the graphs can be weighted.", func() {
+		c.Expect(s.SetEdgeWeight("ready_01", "melee_03", 1), Not(Equals), nil)
+		c.Expect(s.SetEdgeWeight("ready_01", "ready_02", -1), Not(Equals), nil)
+	})
+}
@@ -0,0 +1,14 @@
+package system_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(FixedStepSpec)
+	r.AddSpec(FrameLimiterSpec)
+	r.AddSpec(FlagsSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,25 @@
+package system
+
+// CursorShape is one of the standard cursors provided by the OS.
+type CursorShape int
+
+const (
+	CursorArrow CursorShape = iota
+	CursorHand
+	CursorIBeam
+	CursorCrosshair
+)
+
+func (shape CursorShape) String() string {
+	switch shape {
+	case CursorArrow:
+		return "CursorArrow"
+	case CursorHand:
+		return "CursorHand"
+	case CursorIBeam:
+		return "CursorIBeam"
+	case CursorCrosshair:
+		return "CursorCrosshair"
+	}
+	return "CursorShape(unknown)"
+}
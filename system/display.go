@@ -0,0 +1,49 @@
+package system
+
+import (
+	"sort"
+)
+
+// A Display is one of the monitors attached to the system.
+type Display struct {
+	// Bounds of the display, in the same coordinates as window positions.
+	X, Y, Dx, Dy int
+
+	// Dots per inch, or 0 if the display doesn't report its physical size.
+	DPI float64
+
+	// Refresh rate in Hz, or 0 if it isn't known.
+	RefreshRate float64
+
+	// True for the display that the OS considers the main one.
+	Primary bool
+}
+
+type displaySlice []Display
+
+func (d displaySlice) Len() int           { return len(d) }
+func (d displaySlice) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d displaySlice) Less(i, j int) bool { return d[i].Primary && !d[j].Primary }
+
+// Returns the displays reported by the Os with the primary display first.
+func (sys *sysObj) GetDisplays() []Display {
+	displays := sys.os.GetDisplays()
+	sort.Stable(displaySlice(displays))
+	return displays
+}
+
+// Moves opts.X and opts.Y from the coordinates of the display opts.Display to
+// the coordinates of the whole desktop.
+func (sys *sysObj) placeOnDisplay(opts WindowOpts) WindowOpts {
+	displays := sys.GetDisplays()
+	if len(displays) == 0 {
+		return opts
+	}
+	display := displays[0]
+	if opts.Display >= 0 && opts.Display < len(displays) {
+		display = displays[opts.Display]
+	}
+	opts.X += display.X
+	opts.Y += display.Y
+	return opts
+}
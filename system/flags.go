@@ -0,0 +1,71 @@
+package system
+
+import (
+	"flag"
+	"github.com/runningwild/glop/render"
+	"os"
+	"strconv"
+)
+
+// ApplyFlags registers the standard glop flags with flag.CommandLine and
+// parses the command line, so that every game has the same startup options.
+// It should be called in place of flag.Parse(), after the game has registered
+// its own flags.  The values already in opts are the defaults:
+//
+//	--width, --height  size of the window
+//	--fullscreen       create the window fullscreen
+//	--vsync            turn on vsync
+//	--display          index into GetDisplays() of the display to use
+//	--headless         use the render.Null pipeline, --novid is the same
+//
+// With --headless there is no window to draw to, so the game should run with
+// the headless Os instead of the one for its platform:
+//
+//	opts := system.RunOpts{Window: system.WindowOpts{Dx: 1024, Dy: 768}}
+//	system.ApplyFlags(&opts)
+//	sys := gos.GetSystemInterface()
+//	if opts.Pipeline == render.Null {
+//	  sys = headless.GetSystemInterface()
+//	}
+//	system.Run(sys, game, opts)
+func ApplyFlags(opts *RunOpts) {
+	opts.AddFlags(flag.CommandLine)
+	flag.CommandLine.Parse(os.Args[1:])
+}
+
+// AddFlags registers the flags described in ApplyFlags() with fs, they set
+// the fields of opts when fs is parsed.
+func (opts *RunOpts) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&opts.Window.Dx, "width", opts.Window.Dx, "Width of the window.")
+	fs.IntVar(&opts.Window.Dy, "height", opts.Window.Dy, "Height of the window.")
+	fs.BoolVar(&opts.Window.Fullscreen, "fullscreen", opts.Window.Fullscreen, "Create the window fullscreen.")
+	fs.BoolVar(&opts.Window.VSync, "vsync", opts.Window.VSync, "Turn on vsync.")
+	fs.IntVar(&opts.Window.Display, "display", opts.Window.Display, "Index of the display to create the window on, 0 is the primary display.")
+	headless := headlessFlag{&opts.Pipeline}
+	fs.Var(headless, "headless", "Run without a window or GL context.")
+	fs.Var(headless, "novid", "Same as --headless.")
+}
+
+// A boolean flag that switches a RunOpts to the render.Null pipeline.
+type headlessFlag struct {
+	pipeline *render.Pipeline
+}
+
+func (f headlessFlag) String() string {
+	return strconv.FormatBool(f.pipeline != nil && *f.pipeline == render.Null)
+}
+
+func (f headlessFlag) Set(s string) error {
+	headless, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if headless {
+		*f.pipeline = render.Null
+	}
+	return nil
+}
+
+func (f headlessFlag) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,80 @@
+package system_test
+
+import (
+	"flag"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"github.com/runningwild/glop/system"
+	"io"
+)
+
+// Parses args into a copy of defaults with the standard glop flags.
+func parseFlags(defaults system.RunOpts, args ...string) (system.RunOpts, error) {
+	opts := defaults
+	fs := flag.NewFlagSet("game", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	opts.AddFlags(fs)
+	err := fs.Parse(args)
+	return opts, err
+}
+
+func FlagsSpec(c gospec.Context) {
+	defaults := system.RunOpts{
+		Window:   system.WindowOpts{Dx: 1024, Dy: 768, Display: 1},
+		Pipeline: render.GL33,
+	}
+	c.Specify("Without flags the options keep their defaults.", func() {
+		opts, err := parseFlags(defaults)
+		c.Assume(err, IsNil)
+		c.Expect(opts.Window.Dx, Equals, 1024)
+		c.Expect(opts.Window.Dy, Equals, 768)
+		c.Expect(opts.Window.Fullscreen, Equals, false)
+		c.Expect(opts.Window.VSync, Equals, false)
+		c.Expect(opts.Window.Display, Equals, 1)
+		c.Expect(opts.Pipeline, Equals, render.GL33)
+	})
+	c.Specify("Flags override the defaults.", func() {
+		opts, err := parseFlags(defaults, "--width=640", "--height", "480", "--fullscreen", "--vsync", "--display=0")
+		c.Assume(err, IsNil)
+		c.Expect(opts.Window.Dx, Equals, 640)
+		c.Expect(opts.Window.Dy, Equals, 480)
+		c.Expect(opts.Window.Fullscreen, Equals, true)
+		c.Expect(opts.Window.VSync, Equals, true)
+		c.Expect(opts.Window.Display, Equals, 0)
+		c.Expect(opts.Pipeline, Equals, render.GL33)
+	})
+	c.Specify("Boolean flags can be turned back off.", func() {
+		on := defaults
+		on.Window.Fullscreen = true
+		on.Window.VSync = true
+		opts, err := parseFlags(on, "--fullscreen=false", "--vsync=false")
+		c.Assume(err, IsNil)
+		c.Expect(opts.Window.Fullscreen, Equals, false)
+		c.Expect(opts.Window.VSync, Equals, false)
+	})
+	c.Specify("--headless and --novid switch to the Null pipeline.", func() {
+		for _, arg := range []string{"--headless", "--novid", "-headless=true"} {
+			opts, err := parseFlags(defaults, arg)
+			c.Assume(err, IsNil)
+			c.Expect(opts.Pipeline, Equals, render.Null)
+		}
+		opts, err := parseFlags(defaults, "--headless=false")
+		c.Assume(err, IsNil)
+		c.Expect(opts.Pipeline, Equals, render.GL33)
+	})
+	c.Specify("Invalid values are errors.", func() {
+		for _, args := range [][]string{
+			{"--width=abc"},
+			{"--height=1.5"},
+			{"--display=first"},
+			{"--fullscreen=maybe"},
+			{"--headless=maybe"},
+			{"--novid=2"},
+			{"--widht=640"},
+		} {
+			_, err := parseFlags(defaults, args...)
+			c.Expect(err, Not(IsNil))
+		}
+	})
+}
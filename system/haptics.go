@@ -0,0 +1,81 @@
+package system
+
+import (
+	"github.com/runningwild/glop/gin"
+	"sync"
+	"time"
+)
+
+// Haptics rumbles controllers in response to things happening in a game,
+// scaled by a strength that players can turn down or off in their settings.
+// It is safe to use from any goroutine.
+type Haptics struct {
+	sys System
+
+	mutex    sync.Mutex
+	strength float64
+}
+
+func MakeHaptics(sys System) *Haptics {
+	return &Haptics{sys: sys, strength: 1}
+}
+
+// Sets how strongly every rumble is felt, from 0, which turns rumble off, to
+// 1, the default.
+func (h *Haptics) SetStrength(strength float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.strength = clampUnit(strength)
+}
+
+func (h *Haptics) Strength() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.strength
+}
+
+// Rumbles device for duration, low and high are how hard to run its low and
+// high frequency motors, from 0 to 1, before they are scaled by Strength().
+func (h *Haptics) Rumble(device gin.DeviceId, low, high float64, duration time.Duration) {
+	if device.Type != gin.DeviceTypeController {
+		return
+	}
+	strength := h.Strength()
+	if strength == 0 {
+		return
+	}
+	ms := int(duration / time.Millisecond)
+	if ms <= 0 {
+		return
+	}
+	h.sys.SetRumble(device, clampUnit(low)*strength, clampUnit(high)*strength, ms)
+}
+
+// Rumbles device for a hit with a force from 0 to 1.  Light hits are short
+// buzzes from the high frequency motor, heavier hits last longer and bring
+// in the low frequency motor.
+func (h *Haptics) Hit(device gin.DeviceId, force float64) {
+	force = clampUnit(force)
+	if force == 0 {
+		return
+	}
+	h.Rumble(device, force*force, force, 80*time.Millisecond+time.Duration(170*force)*time.Millisecond)
+}
+
+// Stops device from rumbling.
+func (h *Haptics) Stop(device gin.DeviceId) {
+	if device.Type != gin.DeviceTypeController {
+		return
+	}
+	h.sys.SetRumble(device, 0, 0, 0)
+}
+
+func clampUnit(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
@@ -0,0 +1,222 @@
+package system
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// FrameStats describes how evenly frames have been presented recently, as
+// measured between consecutive calls to SwapBuffers().
+type FrameStats struct {
+	// Number of frame times that the rest of the stats cover, at most the last
+	// few seconds worth.
+	Frames int
+
+	// Mean, fastest, and slowest time between frames.
+	Mean, Min, Max time.Duration
+
+	// Standard deviation of the time between frames.  A steady frame rate has
+	// very little jitter, whatever the rate is.
+	Jitter time.Duration
+}
+
+// Sleeping is only accurate to a millisecond or two on most systems, so the
+// real FrameClock sleeps until this long before a frame is due and then spins.
+const frameSpin = 2 * time.Millisecond
+
+// How many frame times FrameStats covers.
+const frameHistory = 240
+
+// A FrameClock is where a FrameLimiter gets the time and how it waits.
+type FrameClock interface {
+	Now() time.Time
+
+	// Blocks until t, as precisely as it can.
+	WaitUntil(t time.Time)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleeps for most of the wait and spins for the rest so that frames are
+// presented on time without using a whole core.
+func (realClock) WaitUntil(t time.Time) {
+	if d := t.Sub(time.Now()) - frameSpin; d > 0 {
+		time.Sleep(d)
+	}
+	for time.Now().Before(t) {
+		runtime.Gosched()
+	}
+}
+
+// A FrameLimiter keeps frames from being presented faster than a target rate
+// and keeps track of frame times.  Wait() is called on the render thread, the
+// rest may be called from anywhere.
+type FrameLimiter struct {
+	mutex sync.Mutex
+	clock FrameClock
+
+	// Set by SetTargetFPS() and SetSwapInterval().
+	target_fps    int
+	swap_interval int
+
+	// Refresh rate of the primary display, or 0 if it isn't known.
+	refresh_rate float64
+
+	// When the next frame should be presented, zero if the limiter hasn't
+	// presented a frame yet.
+	next time.Time
+
+	// Time of the last swap and the times between recent swaps, as a ring.
+	last  time.Time
+	times []time.Duration
+	pos   int
+}
+
+// Makes a FrameLimiter that doesn't limit anything until it is told to.  If
+// clock is nil it uses the real time.
+func MakeFrameLimiter(clock FrameClock) *FrameLimiter {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &FrameLimiter{clock: clock}
+}
+
+// Sets the highest frame rate the limiter allows, 0 means no limit.
+func (fl *FrameLimiter) SetTargetFPS(fps int) {
+	if fps < 0 {
+		fps = 0
+	}
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+	fl.target_fps = fps
+}
+
+// Tells the limiter about the swap interval and the refresh rate of the
+// display, 0 if it isn't known, see period().
+func (fl *FrameLimiter) SetSwapInterval(n int, refresh_rate float64) {
+	if n < 0 {
+		n = 0
+	}
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+	fl.swap_interval = n
+	fl.refresh_rate = refresh_rate
+}
+
+// Returns the time between frames that the limiter enforces, or 0 if it
+// doesn't limit the frame rate at all.  An explicit target wins, otherwise if
+// vsync is on the limiter holds frames to the rate that vsync would.  In that
+// case a working vsync blocks in the swap for about as long, so the limiter
+// only ever waits when vsync is unavailable or ignored by the driver.
+func (fl *FrameLimiter) period() time.Duration {
+	if fl.target_fps > 0 {
+		return time.Second / time.Duration(fl.target_fps)
+	}
+	if fl.swap_interval > 0 && fl.refresh_rate > 0 {
+		return time.Duration(float64(time.Second) * float64(fl.swap_interval) / fl.refresh_rate)
+	}
+	return 0
+}
+
+// Waits until the next frame is due, then records the frame time.
+func (fl *FrameLimiter) Wait() {
+	fl.mutex.Lock()
+	period := fl.period()
+	deadline := fl.next
+	fl.mutex.Unlock()
+
+	if period > 0 && !deadline.IsZero() && fl.clock.Now().Before(deadline) {
+		fl.clock.WaitUntil(deadline)
+	}
+
+	now := fl.clock.Now()
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+	switch {
+	case period == 0:
+		fl.next = time.Time{}
+	case deadline.IsZero() || now.Sub(deadline) > period:
+		// If we've fallen more than a frame behind don't try to catch up by
+		// presenting a burst of frames, just start over from now.
+		fl.next = now.Add(period)
+	default:
+		fl.next = deadline.Add(period)
+	}
+	if !fl.last.IsZero() {
+		fl.record(now.Sub(fl.last))
+	}
+	fl.last = now
+}
+
+func (fl *FrameLimiter) record(d time.Duration) {
+	if len(fl.times) < frameHistory {
+		fl.times = append(fl.times, d)
+		return
+	}
+	fl.times[fl.pos] = d
+	fl.pos = (fl.pos + 1) % frameHistory
+}
+
+// Returns stats for the last few seconds worth of frames.
+func (fl *FrameLimiter) Stats() FrameStats {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+	var stats FrameStats
+	stats.Frames = len(fl.times)
+	if stats.Frames == 0 {
+		return stats
+	}
+	var sum time.Duration
+	stats.Min = fl.times[0]
+	for _, t := range fl.times {
+		sum += t
+		if t < stats.Min {
+			stats.Min = t
+		}
+		if t > stats.Max {
+			stats.Max = t
+		}
+	}
+	stats.Mean = sum / time.Duration(stats.Frames)
+	var variance float64
+	for _, t := range fl.times {
+		d := float64(t - stats.Mean)
+		variance += d * d
+	}
+	stats.Jitter = time.Duration(math.Sqrt(variance / float64(stats.Frames)))
+	return stats
+}
+
+func (sys *sysObj) SetTargetFPS(fps int) {
+	sys.limiter.SetTargetFPS(fps)
+}
+
+func (sys *sysObj) SetSwapInterval(n int) {
+	if n < 0 {
+		n = 0
+	}
+	sys.os.SetSwapInterval(n)
+	refresh_rate := 0.0
+	if displays := sys.GetDisplays(); len(displays) > 0 {
+		refresh_rate = displays[0].RefreshRate
+	}
+	sys.limiter.SetSwapInterval(n, refresh_rate)
+}
+
+func (sys *sysObj) EnableVSync(enable bool) {
+	if enable {
+		sys.SetSwapInterval(1)
+	} else {
+		sys.SetSwapInterval(0)
+	}
+}
+
+func (sys *sysObj) GetFrameStats() FrameStats {
+	return sys.limiter.Stats()
+}
@@ -0,0 +1,140 @@
+package system_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/system"
+	"time"
+)
+
+// A FrameClock that only moves when it is told to, and remembers how long
+// each wait was.
+type fakeFrameClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func (fc *fakeFrameClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeFrameClock) WaitUntil(t time.Time) {
+	fc.waits = append(fc.waits, t.Sub(fc.now))
+	fc.now = t
+}
+
+// Does work ms worth of work on the clock and then waits for the limiter,
+// returns how long the limiter waited.
+func frame(fl *system.FrameLimiter, fc *fakeFrameClock, work time.Duration) time.Duration {
+	fc.now = fc.now.Add(work)
+	fc.waits = nil
+	fl.Wait()
+	var waited time.Duration
+	for _, w := range fc.waits {
+		waited += w
+	}
+	return waited
+}
+
+func FrameLimiterSpec(c gospec.Context) {
+	ms := time.Millisecond
+	fc := &fakeFrameClock{now: time.Unix(1000, 0)}
+	fl := system.MakeFrameLimiter(fc)
+	c.Specify("Without a target or vsync the limiter never waits.", func() {
+		for _, work := range []time.Duration{0, 1 * ms, 5 * ms, 0} {
+			c.Expect(frame(fl, fc, work), Equals, time.Duration(0))
+		}
+	})
+	c.Specify("The limiter waits for whatever is left of each frame.", func() {
+		fl.SetTargetFPS(50)
+		frames := []struct {
+			work, wait time.Duration
+		}{
+			{0, 0},
+			{5 * ms, 15 * ms},
+			{20 * ms, 0},
+			{0, 20 * ms},
+			{19 * ms, 1 * ms},
+		}
+		for _, f := range frames {
+			c.Expect(frame(fl, fc, f.work), Equals, f.wait)
+		}
+	})
+	c.Specify("A frame that is a little late is made up on the next one.", func() {
+		fl.SetTargetFPS(50)
+		frames := []struct {
+			work, wait time.Duration
+		}{
+			{0, 0},
+			{25 * ms, 0},
+			{5 * ms, 10 * ms},
+			{30 * ms, 0},
+			{5 * ms, 5 * ms},
+		}
+		for _, f := range frames {
+			c.Expect(frame(fl, fc, f.work), Equals, f.wait)
+		}
+	})
+	c.Specify("A frame more than a period late starts the schedule over.", func() {
+		fl.SetTargetFPS(50)
+		frames := []struct {
+			work, wait time.Duration
+		}{
+			{0, 0},
+			{50 * ms, 0},
+			{5 * ms, 15 * ms},
+			{5 * ms, 15 * ms},
+		}
+		for _, f := range frames {
+			c.Expect(frame(fl, fc, f.work), Equals, f.wait)
+		}
+	})
+	c.Specify("With vsync on frames are held to the refresh rate.", func() {
+		fl.SetSwapInterval(2, 100)
+		frame(fl, fc, 0)
+		c.Expect(frame(fl, fc, 5*ms), Equals, 15*ms)
+		c.Specify("unless the refresh rate isn't known.", func() {
+			fl.SetSwapInterval(2, 0)
+			c.Expect(frame(fl, fc, 5*ms), Equals, time.Duration(0))
+		})
+		c.Specify("and a target frame rate takes precedence.", func() {
+			fl.SetTargetFPS(100)
+			frame(fl, fc, 0)
+			c.Expect(frame(fl, fc, 5*ms), Equals, 5*ms)
+		})
+	})
+	c.Specify("Turning the target off stops the limiter from waiting.", func() {
+		fl.SetTargetFPS(50)
+		frame(fl, fc, 0)
+		fl.SetTargetFPS(0)
+		c.Expect(frame(fl, fc, 5*ms), Equals, time.Duration(0))
+		fl.SetTargetFPS(-10)
+		c.Expect(frame(fl, fc, 5*ms), Equals, time.Duration(0))
+	})
+	c.Specify("Stats are measured from one Wait() to the next.", func() {
+		c.Expect(fl.Stats().Frames, Equals, 0)
+		frame(fl, fc, 0)
+		frame(fl, fc, 10*ms)
+		frame(fl, fc, 20*ms)
+		frame(fl, fc, 30*ms)
+		stats := fl.Stats()
+		c.Expect(stats.Frames, Equals, 3)
+		c.Expect(stats.Mean, Equals, 20*ms)
+		c.Expect(stats.Min, Equals, 10*ms)
+		c.Expect(stats.Max, Equals, 30*ms)
+		c.Expect(float64(stats.Jitter), IsWithin(1), float64(8164965))
+	})
+	c.Specify("Stats only cover the most recent frames.", func() {
+		frame(fl, fc, 0)
+		for i := 0; i < 300; i++ {
+			frame(fl, fc, 50*ms)
+		}
+		for i := 0; i < 240; i++ {
+			frame(fl, fc, 10*ms)
+		}
+		stats := fl.Stats()
+		c.Expect(stats.Frames, Equals, 240)
+		c.Expect(stats.Max, Equals, 10*ms)
+		c.Expect(stats.Jitter, Equals, time.Duration(0))
+	})
+}
@@ -0,0 +1,139 @@
+package system
+
+import (
+	"github.com/runningwild/glop/perf"
+	"github.com/runningwild/glop/render"
+	"runtime"
+	"time"
+)
+
+// A Game is driven by Run().
+type Game interface {
+	// Init is called once, after the window has been created and before the
+	// first call to Think().  If it returns an error Run() returns that error
+	// immediately.
+	Init(sys System) error
+
+	// Think advances the game by exactly dt, the timestep given to Run().  All
+	// input events for the frame have already been sent to gin listeners when
+	// Think is called.
+	Think(dt time.Duration)
+
+	// Draw is called on the render thread once per frame.  alpha, in [0, 1), is
+	// how far the current time is between the most recent Think() and the next
+	// one, and can be used to interpolate positions for smooth motion.
+	Draw(alpha float64)
+
+	// Quit is called once per frame, Run() returns once it returns true.
+	Quit() bool
+}
+
+// RunOpts configures Run().
+type RunOpts struct {
+	// The window to create.
+	Window WindowOpts
+
+	// Pipeline passed to render.Init().
+	Pipeline render.Pipeline
+
+	// How much game time passes with each call to Game.Think(), 1/60th of a
+	// second if zero.
+	Timestep time.Duration
+
+	// The most times Game.Think() will be called in a single frame.  If the
+	// game falls further behind than this it slows down rather than spending
+	// more and more time catching up.  5 if zero.
+	MaxSteps int
+}
+
+// A FixedStep splits the real time between frames into steps of game time
+// that are all the same size, the way Run() does, for games that run their own
+// loop.
+type FixedStep struct {
+	// How much game time passes with each step, 1/60th of a second if zero.
+	Timestep time.Duration
+
+	// The most steps that Advance() returns for one frame.  If the game falls
+	// further behind than this it slows down rather than spending more and
+	// more time catching up.  5 if zero.
+	MaxSteps int
+
+	// Time that hasn't been stepped through yet.
+	accumulated time.Duration
+}
+
+// Adds frame, the real time since the last call, and returns how many steps
+// to take for it and alpha, in [0, 1), which is how far the current time is
+// between the last step and the next one.
+func (fs *FixedStep) Advance(frame time.Duration) (steps int, alpha float64) {
+	timestep := fs.Timestep
+	if timestep <= 0 {
+		timestep = time.Second / 60
+	}
+	max_steps := fs.MaxSteps
+	if max_steps <= 0 {
+		max_steps = 5
+	}
+	fs.accumulated += frame
+	if max := timestep * time.Duration(max_steps); fs.accumulated > max {
+		fs.accumulated = max
+	}
+	steps = int(fs.accumulated / timestep)
+	fs.accumulated -= time.Duration(steps) * timestep
+	return steps, float64(fs.accumulated) / float64(timestep)
+}
+
+// Run is the standard glop game loop.  It must be called from the main
+// goroutine, usually directly from main(), with the Os for the current
+// platform, from gos.GetSystemInterface().  It locks the OS thread, starts up
+// the system and the render thread, creates the window, and then every frame
+// it processes input, calls game.Think() as many times as needed to keep game
+// time in step with real time, and queues game.Draw() and a buffer swap on the
+// render thread.  Each of those is marked as a phase for perf, and Run calls
+// perf.EndFrame() at the end of every frame.  Run returns once game.Quit()
+// returns true.
+func Run(os Os, game Game, opts RunOpts) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if opts.Timestep <= 0 {
+		opts.Timestep = time.Second / 60
+	}
+
+	sys := Make(os)
+	sys.Startup()
+	render.Init(opts.Pipeline)
+	render.Queue(func() {
+		sys.CreateWindowEx(opts.Window)
+	})
+	render.Sync()
+	if err := game.Init(sys); err != nil {
+		return err
+	}
+
+	step := FixedStep{Timestep: opts.Timestep, MaxSteps: opts.MaxSteps}
+	last := time.Now()
+	for !game.Quit() {
+		render.StartFrame()
+		sys.Think()
+
+		now := time.Now()
+		steps, alpha := step.Advance(now.Sub(last))
+		last = now
+		think := perf.Begin(perf.Think)
+		for i := 0; i < steps; i++ {
+			game.Think(opts.Timestep)
+		}
+		think.End()
+
+		render.Queue(func() {
+			draw := perf.Begin(perf.Render)
+			game.Draw(alpha)
+			draw.End()
+			sys.SwapBuffers()
+		})
+		render.EndFrame()
+		perf.EndFrame()
+	}
+	render.Purge()
+	return nil
+}
@@ -0,0 +1,70 @@
+package system_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/system"
+	"time"
+)
+
+func FixedStepSpec(c gospec.Context) {
+	ms := time.Millisecond
+	c.Specify("Frames are split into whole steps, the rest carries over.", func() {
+		step := system.FixedStep{Timestep: 10 * ms, MaxSteps: 5}
+		frames := []struct {
+			frame time.Duration
+			steps int
+			alpha float64
+		}{
+			{0, 0, 0},
+			{4 * ms, 0, 0.4},
+			{6 * ms, 1, 0},
+			{25 * ms, 2, 0.5},
+			{5 * ms, 1, 0},
+			{10 * ms, 1, 0},
+			{30 * ms, 3, 0},
+		}
+		for _, f := range frames {
+			steps, alpha := step.Advance(f.frame)
+			c.Expect(steps, Equals, f.steps)
+			c.Expect(alpha, IsWithin(1e-9), f.alpha)
+		}
+	})
+	c.Specify("Long frames are clamped to MaxSteps.", func() {
+		step := system.FixedStep{Timestep: 10 * ms, MaxSteps: 5}
+		frames := []struct {
+			frame time.Duration
+			steps int
+			alpha float64
+		}{
+			{7 * ms, 0, 0.7},
+			{50 * ms, 5, 0},
+			{time.Second, 5, 0},
+			{3 * ms, 0, 0.3},
+			{51 * ms, 5, 0},
+		}
+		for _, f := range frames {
+			steps, alpha := step.Advance(f.frame)
+			c.Expect(steps, Equals, f.steps)
+			c.Expect(alpha, IsWithin(1e-9), f.alpha)
+		}
+	})
+	c.Specify("A zero FixedStep steps 60 times a second, at most 5 steps a frame.", func() {
+		var step system.FixedStep
+		frames := []struct {
+			frame time.Duration
+			steps int
+			alpha float64
+		}{
+			{time.Second / 120, 0, 0.5},
+			{time.Second / 120, 1, 0},
+			{time.Second / 20, 3, 0},
+			{time.Second, 5, 0},
+		}
+		for _, f := range frames {
+			steps, alpha := step.Advance(f.frame)
+			c.Expect(steps, Equals, f.steps)
+			c.Expect(alpha, IsWithin(1e-6), f.alpha)
+		}
+	})
+}
@@ -2,6 +2,9 @@ package system
 
 import (
 	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/perf"
+	"image"
+	"io"
 )
 
 type System interface {
@@ -11,10 +14,35 @@ type System interface {
 	// Call System.Think() every frame
 	Think()
 
+	// Returns all of the displays attached to the system, the primary display
+	// is always first.
+	GetDisplays() []Display
+
+	// Creates the window described by opts and binds an OpenGl context to it.
+	CreateWindowEx(opts WindowOpts)
+
+	// Same as CreateWindowEx() with only the position and size set.
 	CreateWindow(x, y, width, height int)
 	// TODO: implement this:
 	// DestroyWindow(Window)
 
+	// Runtime controls for the window.  Like CreateWindowEx(), these should be
+	// called on the render thread.
+
+	// Changes the text in the window's title bar.
+	SetTitle(title string)
+
+	// Switches the window into or out of fullscreen mode.
+	SetFullscreen(fullscreen bool)
+
+	// Changes the size of the window, not including its border.  Listeners are
+	// sent a gin.ResizeEvent once the window has actually changed size, which
+	// may not happen until a later call to Think().
+	Resize(width, height int)
+
+	// Minimizes the window.
+	Minimize()
+
 	// Gets the cursor position in window coordinates with the cursor at the bottom left
 	// corner of the window
 	GetCursorPos() (x, y int)
@@ -23,14 +51,90 @@ type System interface {
 	// locked.  It should still generate mouse move events.
 	HideCursor(bool)
 
+	// Turns relative mouse mode on or off.  While it is on the cursor is hidden
+	// and captured by the window, and MouseXAxis and MouseYAxis events report raw
+	// relative motion that doesn't stop at the edges of the window or screen,
+	// which is what mouse-look cameras need.
+	SetRelativeMouseMode(bool)
+
+	// Sets the cursor shown while the mouse is over the window to one of the
+	// standard shapes, or to an image with its hotspot at (hot_x, hot_y) in
+	// image coordinates.  The cursor stays hidden if HideCursor(true) was
+	// called, and is shown once HideCursor(false) is called.
+	SetCursorShape(shape CursorShape)
+	SetCustomCursor(im image.Image, hot_x, hot_y int)
+
 	GetWindowDims() (x, y, dx, dy int)
 
+	// Returns how many pixels of the window's framebuffer there are per unit
+	// of the coordinates that the OS positions windows in, 2 on Retina
+	// displays and 1 almost everywhere else.  Window sizes, cursor positions,
+	// and every other coordinate inside the window are in pixels, so this is
+	// only needed for things like scaling a UI up to stay readable.
+	GetBackingScale() float64
+
 	SwapBuffers()
 	GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex
 	GetInputEvents() []gin.EventGroup
 
+	// Returns the window events that happened during the last call to Think().
+	// These have already been sent to any gin.WindowListeners.  Closing the
+	// window only generates a gin.WindowClose event, it is up to the
+	// application to actually quit.
+	GetWindowEvents() []gin.WindowEvent
+
+	// Returns the files that were dropped on the window during the last call to
+	// Think().  These have already been sent to any gin.FileDropListeners.
+	GetFileDropEvents() []gin.FileDropEvent
+
+	// Turns vsync on or off, the same as SetSwapInterval(1) or SetSwapInterval(0).
 	EnableVSync(bool)
 
+	// Sets how many vertical blanks SwapBuffers() waits for, 0 turns vsync off.
+	// While vsync is on and no target frame rate has been set, SwapBuffers()
+	// also holds frames to the display's refresh rate divided by n, so that a
+	// game doesn't spin through frames as fast as it can when the driver
+	// ignores vsync.
+	SetSwapInterval(n int)
+
+	// Makes SwapBuffers() wait so that frames are presented no more than fps
+	// times per second, 0 removes the limit.  The wait is a sleep followed by
+	// a short spin, so it doesn't use a whole core.  This can be combined with
+	// vsync, frames are presented at whichever rate is slower.
+	SetTargetFPS(fps int)
+
+	// Returns statistics about the time between recent calls to
+	// SwapBuffers().
+	GetFrameStats() FrameStats
+
+	// Rumbles the controller device, see Os.SetRumble().  Haptics is a more
+	// convenient way to use this.
+	SetRumble(device gin.DeviceId, low, high float64, duration_ms int)
+
+	// Gets and sets the text on the system clipboard.
+	GetClipboardString() string
+	SetClipboardString(s string)
+
+	// Returns the directories where app should keep save games, its settings,
+	// and files that it can regenerate, see Os.UserDataDir().
+	UserDataDir(app string) (string, error)
+	UserConfigDir(app string) (string, error)
+	UserCacheDir(app string) (string, error)
+
+	// Makes a GL context that shares textures and buffers with the window's,
+	// see Os.CreateSharedContext().  render.TextureManager.UploadOn() uses one
+	// to upload textures without holding up the render thread.
+	CreateSharedContext() (SharedContext, error)
+
+	// Starts recording all input events to w, see gin.Recorder.  Recording stops
+	// if writing to w ever fails.
+	RecordInput(w io.Writer) error
+
+	// Replaces input from the OS with input previously recorded by RecordInput().
+	// Input from the OS is discarded until the recording runs out, after which
+	// input from the OS is used again.
+	ReplayInput(r io.Reader) error
+
 	// These probably shouldn't be here, probably always want to do the Think() approach
 	//  Run()
 	//  Quit()
@@ -47,15 +151,29 @@ type Os interface {
 	// Think() is called on a regular basis and always from main thread.
 	Think()
 
-	// Create a window with the appropriate dimensions and bind an OpenGl contxt to it.
+	// Returns all of the displays attached to the system, in any order.
+	GetDisplays() []Display
+
+	// Create a window as described by opts and bind an OpenGl contxt to it.
 	// Currently glop only supports a single window, but this function could be called
 	// more than once since a window could be destroyed so it can be recreated at different
-	// dimensions or in full sreen mode.
-	CreateWindow(x, y, width, height int)
+	// dimensions or in full sreen mode.  opts.Title will never be empty, and
+	// opts.X and opts.Y have already been moved onto opts.Display, so they are
+	// in the same coordinates as the bounds returned by GetDisplays().  A
+	// fullscreen window covers whichever display it is on.
+	CreateWindowEx(opts WindowOpts)
 
 	// TODO: implement this:
 	// DestroyWindow(Window)
 
+	// Runtime controls for the window created by CreateWindowEx().  A fullscreen
+	// window is borderless and covers the whole display, leaving fullscreen
+	// mode restores the window to the position and size it had before.
+	SetTitle(title string)
+	SetFullscreen(fullscreen bool)
+	Resize(width, height int)
+	Minimize()
+
 	// Gets the cursor position in window coordinates with the cursor at the bottom left
 	// corner of the window
 	GetCursorPos() (x, y int)
@@ -64,13 +182,34 @@ type Os interface {
 	// locked.  It should still generate mouse move events.
 	HideCursor(bool)
 
+	// Turns relative mouse mode on or off.  While it is on the cursor is hidden
+	// and captured by the window, and MouseXAxis and MouseYAxis events report raw
+	// relative motion that doesn't stop at the edges of the window or screen,
+	// which is what mouse-look cameras need.
+	SetRelativeMouseMode(bool)
+
+	// Sets the cursor shown while the mouse is over the window to one of the
+	// standard shapes, or to an image with its hotspot at (hot_x, hot_y) in
+	// image coordinates.  The cursor stays hidden if HideCursor(true) was
+	// called, and is shown once HideCursor(false) is called.
+	SetCursorShape(shape CursorShape)
+	SetCustomCursor(im image.Image, hot_x, hot_y int)
+
 	GetWindowDims() (x, y, dx, dy int)
 
+	// Returns how many pixels of the window's framebuffer there are per unit
+	// of the coordinates that displays and window positions are in.  The size
+	// of the window, and the cursor and other coordinates in the window, must
+	// be in pixels, so an Os where this isn't 1 has to convert them.
+	GetBackingScale() float64
+
 	// Swap the OpenGl buffers on this window
 	SwapBuffers()
 
 	// Returns a mapping from DeviceType to a slice of all of the DeviceIndexes
-	// of active devices of that type.
+	// of active devices of that type.  This is called every frame so that gin
+	// can report devices being connected and disconnected, an Os that cannot
+	// tell which devices are connected should return nil.
 	GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex
 
 	// Returns all of the events in the order that they happened since the last call to
@@ -79,25 +218,101 @@ type Os interface {
 	// horizon, no future events will have a timestamp less than or equal to it.
 	GetInputEvents() ([]gin.OsEvent, int64)
 
-	EnableVSync(bool)
+	// Returns all of the characters typed since the last call to this function, in
+	// the order that they were typed.  Timestamps are on the same clock as those
+	// returned by GetInputEvents(), and no text event will have a timestamp later
+	// than the horizon returned by the most recent call to GetInputEvents().
+	GetTextEvents() []gin.TextEvent
+
+	// Returns all of the window events since the last call to this function, in
+	// the order that they happened, with timestamps on the same clock as those
+	// returned by GetInputEvents().  The close button must not close the
+	// window, it should only generate a gin.WindowClose event.  An Os that
+	// doesn't report resizes doesn't have to, they are also found by watching
+	// GetWindowDims().
+	GetWindowEvents() []gin.WindowEvent
+
+	// Returns the files dropped on the window since the last call to this
+	// function, in the order that they were dropped.  Paths are absolute and
+	// utf-8, the position of each drop is in the same window coordinates as
+	// GetCursorPos(), and timestamps are on the same clock as those returned by
+	// GetInputEvents().
+	GetFileDropEvents() []gin.FileDropEvent
+
+	// Sets how many vertical blanks SwapBuffers() waits for, 0 turns vsync off.
+	// An Os that can only turn vsync on or off should treat anything greater
+	// than 0 as on.
+	SetSwapInterval(n int)
+
+	// Returns the text on the system clipboard, or an empty string if there
+	// is no text on it, and puts text on the system clipboard.  Strings are
+	// utf-8.
+	GetClipboardString() string
+	SetClipboardString(s string)
 
 	// Returns true iff the application currently is in focus.
 	HasFocus() bool
 
+	// Rumbles the controller device, low and high are how hard to run its low
+	// and high frequency motors, from 0 to 1.  The rumble stops after
+	// duration_ms, or when SetRumble() is called for the device again, a
+	// duration of 0 rumbles until then.  Devices that aren't controllers, or
+	// that can't rumble, are ignored.
+	SetRumble(device gin.DeviceId, low, high float64, duration_ms int)
+
+	// Return the directories where app should keep its data, like save
+	// games, its settings, and files it can regenerate, in the right place for
+	// the platform: the XDG base directories on linux, AppData on windows, and
+	// ~/Library on osx.  The directory is named app and is created if it
+	// doesn't exist.
+	UserDataDir(app string) (string, error)
+	UserConfigDir(app string) (string, error)
+	UserCacheDir(app string) (string, error)
+
+	// Makes a GL context that shares textures, buffers, and shaders with the
+	// window's context, so that they can be made on another thread.  This is
+	// called on the render thread after CreateWindowEx().  An Os that can't
+	// make one should return an error.
+	CreateSharedContext() (SharedContext, error)
+
 	// These probably shouldn't be here, probably always want to do the Think() approach
 	//  Run()
 	//  Quit()
 }
 
+// A GL context made by Os.CreateSharedContext().
+type SharedContext interface {
+	// Makes the context current on the calling thread, which must be locked to
+	// its goroutine with runtime.LockOSThread().  A context can only be
+	// current on one thread at a time.
+	MakeCurrent() error
+
+	// Makes the calling thread stop using the context.
+	Release()
+
+	// Frees the context, which must not be current on any thread.
+	Destroy()
+}
+
 type sysObj struct {
 	os       Os
 	events   []gin.EventGroup
+	window   []gin.WindowEvent
+	drops    []gin.FileDropEvent
 	start_ms int64
+	recorder *gin.Recorder
+	replayer *gin.Replayer
+	limiter  *FrameLimiter
+
+	// set once the window has been created, until then there are no window
+	// dimensions to check for resizes
+	has_window bool
 }
 
 func Make(os Os) System {
 	return &sysObj{
-		os: os,
+		os:      os,
+		limiter: MakeFrameLimiter(nil),
 	}
 }
 func (sys *sysObj) Startup() {
@@ -105,15 +320,84 @@ func (sys *sysObj) Startup() {
 	_, sys.start_ms = sys.os.GetInputEvents()
 }
 func (sys *sysObj) thinkInternal() {
+	defer perf.Begin(perf.Input).End()
 	sys.os.Think()
 	events, horizon := sys.os.GetInputEvents()
 	for i := range events {
 		events[i].Timestamp -= sys.start_ms
 	}
-	sys.events = gin.In().Think(horizon-sys.start_ms, sys.os.HasFocus(), events)
+	text := sys.os.GetTextEvents()
+	for i := range text {
+		text[i].Timestamp -= sys.start_ms
+	}
+	window := sys.os.GetWindowEvents()
+	for i := range window {
+		window[i].Timestamp -= sys.start_ms
+	}
+	drops := sys.os.GetFileDropEvents()
+	for i := range drops {
+		drops[i].Timestamp -= sys.start_ms
+	}
+	t := horizon - sys.start_ms
+	has_focus := sys.os.HasFocus()
+	if sys.replayer != nil {
+		rec_t, rec_focus, rec_events, err := sys.replayer.Next()
+		if err == nil {
+			t, has_focus, events, text = rec_t, rec_focus, rec_events, nil
+		} else {
+			sys.replayer = nil
+		}
+	}
+	if sys.recorder != nil && sys.recorder.Record(t, has_focus, events) != nil {
+		sys.recorder = nil
+	}
+	sys.window = window
+	gin.In().AddWindowEvents(window)
+	sys.drops = drops
+	gin.In().AddFileDropEvents(drops)
+	if sys.has_window {
+		_, _, dx, dy := sys.os.GetWindowDims()
+		gin.In().WindowResized(t, dx, dy)
+	}
+	gin.In().AddTextEvents(text)
+	gin.In().UpdateActiveDevices(t, sys.os.GetActiveDevices())
+	sys.events = gin.In().Think(t, has_focus, events)
+}
+func (sys *sysObj) RecordInput(w io.Writer) error {
+	recorder, err := gin.MakeRecorder(w)
+	if err != nil {
+		return err
+	}
+	sys.recorder = recorder
+	return nil
+}
+func (sys *sysObj) ReplayInput(r io.Reader) error {
+	replayer, err := gin.MakeReplayer(r)
+	if err != nil {
+		return err
+	}
+	sys.replayer = replayer
+	return nil
+}
+func (sys *sysObj) CreateWindowEx(opts WindowOpts) {
+	sys.os.CreateWindowEx(sys.placeOnDisplay(opts.withDefaults()))
+	sys.EnableVSync(opts.VSync)
+	sys.has_window = true
 }
 func (sys *sysObj) CreateWindow(x, y, width, height int) {
-	sys.os.CreateWindow(x, y, width, height)
+	sys.CreateWindowEx(WindowOpts{X: x, Y: y, Dx: width, Dy: height})
+}
+func (sys *sysObj) SetTitle(title string) {
+	sys.os.SetTitle(title)
+}
+func (sys *sysObj) SetFullscreen(fullscreen bool) {
+	sys.os.SetFullscreen(fullscreen)
+}
+func (sys *sysObj) Resize(width, height int) {
+	sys.os.Resize(width, height)
+}
+func (sys *sysObj) Minimize() {
+	sys.os.Minimize()
 }
 func (sys *sysObj) GetCursorPos() (int, int) {
 	return sys.os.GetCursorPos()
@@ -121,11 +405,29 @@ func (sys *sysObj) GetCursorPos() (int, int) {
 func (sys *sysObj) HideCursor(hide bool) {
 	sys.os.HideCursor(hide)
 }
+func (sys *sysObj) SetRelativeMouseMode(relative bool) {
+	sys.os.SetRelativeMouseMode(relative)
+}
+func (sys *sysObj) SetRumble(device gin.DeviceId, low, high float64, duration_ms int) {
+	sys.os.SetRumble(device, low, high, duration_ms)
+}
+func (sys *sysObj) SetCursorShape(shape CursorShape) {
+	sys.os.SetCursorShape(shape)
+}
+func (sys *sysObj) SetCustomCursor(im image.Image, hot_x, hot_y int) {
+	sys.os.SetCustomCursor(im, hot_x, hot_y)
+}
 func (sys *sysObj) GetWindowDims() (int, int, int, int) {
 	return sys.os.GetWindowDims()
 }
+func (sys *sysObj) GetBackingScale() float64 {
+	return sys.os.GetBackingScale()
+}
 func (sys *sysObj) SwapBuffers() {
+	swap := perf.Begin(perf.Swap)
 	sys.os.SwapBuffers()
+	swap.End()
+	sys.limiter.Wait()
 }
 func (sys *sysObj) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
 	return sys.os.GetActiveDevices()
@@ -133,6 +435,27 @@ func (sys *sysObj) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
 func (sys *sysObj) GetInputEvents() []gin.EventGroup {
 	return sys.events
 }
-func (sys *sysObj) EnableVSync(enable bool) {
-	sys.os.EnableVSync(enable)
+func (sys *sysObj) GetWindowEvents() []gin.WindowEvent {
+	return sys.window
+}
+func (sys *sysObj) GetFileDropEvents() []gin.FileDropEvent {
+	return sys.drops
+}
+func (sys *sysObj) UserDataDir(app string) (string, error) {
+	return sys.os.UserDataDir(app)
+}
+func (sys *sysObj) UserConfigDir(app string) (string, error) {
+	return sys.os.UserConfigDir(app)
+}
+func (sys *sysObj) UserCacheDir(app string) (string, error) {
+	return sys.os.UserCacheDir(app)
+}
+func (sys *sysObj) CreateSharedContext() (SharedContext, error) {
+	return sys.os.CreateSharedContext()
+}
+func (sys *sysObj) GetClipboardString() string {
+	return sys.os.GetClipboardString()
+}
+func (sys *sysObj) SetClipboardString(s string) {
+	sys.os.SetClipboardString(s)
 }
@@ -0,0 +1,49 @@
+package system
+
+import (
+	"image"
+)
+
+// WindowOpts describes the window created by CreateWindowEx().
+type WindowOpts struct {
+	// Position and size of the window.  On linux and windows x and y are the
+	// position of the top left corner of the window, on osx they are the
+	// position of the bottom left corner.  The position is relative to the
+	// display that the window is created on.
+	X, Y, Dx, Dy int
+
+	// Index into System.GetDisplays() of the display to create the window on.
+	// The default, 0, is the primary display, which is also used if Display is
+	// out of range.
+	Display int
+
+	// Text shown in the title bar, "Glop" if empty.
+	Title string
+
+	// If Fullscreen is set the window covers the whole of its display and has no
+	// border, otherwise it has a title bar and can only be resized by the user
+	// if Resizable is set.
+	Fullscreen bool
+	Resizable  bool
+
+	// Passed to EnableVSync() once the window has been created.
+	VSync bool
+
+	// Number of samples per pixel to use for multisample antialiasing, 0 turns
+	// it off.  If the requested number of samples isn't available the window is
+	// created without it.  Not supported on windows.
+	MSAA int
+
+	// Icon for the window, the platform default is used if this is nil.
+	// Platforms that want a particular size scale it as needed, 32x32 or 64x64
+	// is a reasonable choice.
+	Icon image.Image
+}
+
+// Returns opts with default values filled in.
+func (opts WindowOpts) withDefaults() WindowOpts {
+	if opts.Title == "" {
+		opts.Title = "Glop"
+	}
+	return opts
+}
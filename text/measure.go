@@ -0,0 +1,75 @@
+package text
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StringWidth returns how wide str would be if it were rendered with
+// RenderString() at the given height, in the same units.  str is measured a
+// rune at a time, so any UTF-8 text can be measured, runes that aren't in the
+// dictionary take up no space.  This doesn't need the render thread.
+func (d *Dictionary) StringWidth(str string, height float64) float64 {
+	// This has to match the way bindString() moves the pen.
+	scale := 1.0 / float64(d.GlyphMax.Dy())
+	var width float64
+	var prev rune
+	for _, r := range str {
+		width += float64(d.Runes[r].AdvanceWidth) * scale
+		width += float64(d.Kerning[RunePair{prev, r}]) * scale
+		prev = r
+	}
+	return width * height
+}
+
+// WrapString splits str into lines that are each no wider than width when
+// rendered at height.  Lines are broken at spaces where possible, and
+// between runes otherwise, so text in languages that don't put spaces between
+// words, and words that are too long to fit on a line by themselves, still
+// wrap.  Newlines in str always start a new line.
+func (d *Dictionary) WrapString(str string, height, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(str, "\n") {
+		lines = append(lines, d.wrapParagraph(paragraph, height, width)...)
+	}
+	return lines
+}
+
+func (d *Dictionary) wrapParagraph(str string, height, width float64) []string {
+	var lines []string
+	for {
+		if d.StringWidth(str, height) <= width {
+			return append(lines, str)
+		}
+		// Find the longest prefix that fits, remembering the last space in it.
+		fit, space := 0, -1
+		for i, r := range str {
+			_, size := utf8.DecodeRuneInString(str[i:])
+			next := i + size
+			if d.StringWidth(str[:next], height) > width {
+				break
+			}
+			fit = next
+			if unicode.IsSpace(r) {
+				space = i
+			}
+		}
+		switch {
+		case space > 0:
+			lines = append(lines, strings.TrimRightFunc(str[:space], unicode.IsSpace))
+			str = strings.TrimLeftFunc(str[space:], unicode.IsSpace)
+		case fit > 0:
+			lines = append(lines, str[:fit])
+			str = str[fit:]
+		default:
+			// Not even one rune fits, so put one on each line.
+			_, size := utf8.DecodeRuneInString(str)
+			lines = append(lines, str[:size])
+			str = str[size:]
+		}
+		if str == "" {
+			return lines
+		}
+	}
+}
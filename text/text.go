@@ -164,6 +164,11 @@ func LoadDictionary(r io.Reader) (*Dictionary, error) {
 	}
 
 	render.Queue(func() {
+		if !render.HasGl() {
+			errChan <- nil
+			return
+		}
+
 		// Create the gl texture for the atlas
 		gl.GenTextures(1, &dict.atlas.texture)
 		glerr := gl.GetError()
@@ -299,7 +304,7 @@ func (d *Dictionary) bindString(str string) strData {
 // RenderString must be called on the render thread.  x and y are the initial position of the pen,
 // in screen coordinates, and height is the height of a full line of text, in screen coordinates.
 func (d *Dictionary) RenderString(str string, x, y, height float64) {
-	if str == "" {
+	if str == "" || !render.HasGl() {
 		return
 	}
 	// No synchronization necessary because everything is run serially on the render thread anyway.
@@ -0,0 +1,13 @@
+package tilemap_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(LoadSpec)
+	r.AddSpec(ErrorSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,156 @@
+package tilemap
+
+import (
+	"github.com/runningwild/glop/camera"
+	"github.com/runningwild/glop/render"
+	"math"
+)
+
+// Layers are split into chunkSize by chunkSize chunks of cells so that
+// drawing only has to look at the chunks that are on the screen, and only at
+// the cells in them that have tiles.
+const chunkSize = 16
+
+type chunk struct {
+	// Indices of the cells in this chunk that have tiles, in Tiled's order.
+	cells []int32
+}
+
+func (l *TileLayer) makeChunks() {
+	m := l.m
+	cdx := (m.Width + chunkSize - 1) / chunkSize
+	cdy := (m.Height + chunkSize - 1) / chunkSize
+	l.chunks = make([]chunk, cdx*cdy)
+	for i, t := range l.tiles {
+		if t == nil {
+			continue
+		}
+		x, y := i%m.Width, i/m.Width
+		c := &l.chunks[x/chunkSize+(y/chunkSize)*cdx]
+		c.cells = append(c.cells, int32(i))
+	}
+}
+
+// Returns the texture coordinates of t in its tileset's image.
+func (t *Tile) uv() (u, v, u2, v2 float32) {
+	ts := t.Tileset
+	bounds := ts.img.Bounds()
+	dx, dy := float32(bounds.Dx()), float32(bounds.Dy())
+	px := ts.Margin + (t.Id%ts.Columns)*(ts.TileWidth+ts.Spacing)
+	py := ts.Margin + (t.Id/ts.Columns)*(ts.TileHeight+ts.Spacing)
+	u = float32(px) / dx
+	u2 = float32(px+ts.TileWidth) / dx
+	// The top of the image is at v = 0, and the bottom of the quad gets v.
+	v = float32(py+ts.TileHeight) / dy
+	v2 = float32(py) / dy
+	return
+}
+
+// Adds the tiles of l that might be in the world rectangle given to the
+// batches of their tilesets.
+func (m *Map) addLayer(l *TileLayer, min_x, min_y, max_x, max_y float64) {
+	// Tiles are drawn from the bottom left corner of their cell, so tiles
+	// bigger than a cell can show up from cells below and to the left of the
+	// rectangle.
+	extra_x, extra_y := 0, 0
+	for _, ts := range m.Tilesets {
+		if ts.TileWidth-m.TileWidth > extra_x {
+			extra_x = ts.TileWidth - m.TileWidth
+		}
+		if ts.TileHeight-m.TileHeight > extra_y {
+			extra_y = ts.TileHeight - m.TileHeight
+		}
+	}
+	min_x -= l.OffsetX + float64(extra_x)
+	min_y -= l.OffsetY + float64(extra_y)
+	max_x -= l.OffsetX
+	max_y -= l.OffsetY
+	tw, th := float64(m.TileWidth), float64(m.TileHeight)
+	toChunk := func(cell float64) int {
+		return int(math.Floor(cell / chunkSize))
+	}
+	x0 := toChunk(math.Floor(min_x / tw))
+	x1 := toChunk(math.Floor(max_x / tw))
+	y0 := toChunk(float64(m.Height-1) - math.Floor(max_y/th))
+	y1 := toChunk(float64(m.Height-1) - math.Floor(min_y/th))
+	cdx := (m.Width + chunkSize - 1) / chunkSize
+	cdy := (m.Height + chunkSize - 1) / chunkSize
+	if x1 < 0 || y1 < 0 || x0 >= cdx || y0 >= cdy {
+		return
+	}
+	x0, y0 = clampInt(x0, 0, cdx-1), clampInt(y0, 0, cdy-1)
+	x1, y1 = clampInt(x1, 0, cdx-1), clampInt(y1, 0, cdy-1)
+
+	color := [4]float32{1, 1, 1, float32(l.Opacity)}
+	for cy := y0; cy <= y1; cy++ {
+		for cx := x0; cx <= x1; cx++ {
+			for _, i := range l.chunks[cx+cy*cdx].cells {
+				x, y := int(i)%m.Width, int(i)/m.Width
+				t := l.tiles[i].frame(m.time)
+				ts := t.Tileset
+				left := float32(float64(x)*tw + l.OffsetX)
+				bottom := float32(float64(m.Height-1-y)*th + l.OffsetY)
+				u, v, u2, v2 := t.uv()
+				gid := l.gids[i]
+				if gid&flipH != 0 {
+					u, u2 = u2, u
+				}
+				if gid&flipV != 0 {
+					v, v2 = v2, v
+				}
+				ts.batch.Add(left, bottom, left+float32(ts.TileWidth), bottom+float32(ts.TileHeight), u, v, u2, v2, color)
+			}
+		}
+	}
+}
+
+func clampInt(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// Draws the visible tile layers of m as seen by cam, with animated tiles
+// showing whatever frame they're on.  Tiles that are flipped diagonally are
+// drawn without that flip.  Tileset textures are uploaded the first time
+// they're needed.  Must be called on the render thread.
+func (m *Map) Draw(cam *camera.Camera) error {
+	render.MustRunOnRenderThread()
+	min_x, min_y, max_x, max_y := cam.Visible()
+	projection := cam.Matrix()
+	for _, l := range m.TileLayers {
+		if !l.Visible || l.Opacity <= 0 {
+			continue
+		}
+		m.addLayer(l, min_x, min_y, max_x, max_y)
+		for _, ts := range m.Tilesets {
+			if ts.batch.Len() == 0 {
+				continue
+			}
+			if ts.texture == nil {
+				ts.texture = render.Textures().LoadImage(ts.img)
+			}
+			if err := ts.batch.DrawWithProjection(ts.texture, projection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Frees the textures and gl objects used to draw m.  Must be called on the
+// render thread.
+func (m *Map) Delete() {
+	render.MustRunOnRenderThread()
+	for _, ts := range m.Tilesets {
+		if ts.texture != nil {
+			ts.texture.Delete()
+			ts.texture = nil
+		}
+		ts.batch.Delete()
+	}
+}
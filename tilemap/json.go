@@ -0,0 +1,264 @@
+package tilemap
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type jsonProperties []struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+func (jp jsonProperties) convert() Properties {
+	props := Properties{}
+	for _, p := range jp {
+		props[p.Name] = fmt.Sprint(p.Value)
+	}
+	return props
+}
+
+type jsonMap struct {
+	Orientation string         `json:"orientation"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	TileWidth   int            `json:"tilewidth"`
+	TileHeight  int            `json:"tileheight"`
+	Infinite    bool           `json:"infinite"`
+	Properties  jsonProperties `json:"properties"`
+	Tilesets    []jsonTileset  `json:"tilesets"`
+	Layers      []jsonLayer    `json:"layers"`
+}
+
+type jsonTileset struct {
+	FirstGid   uint32         `json:"firstgid"`
+	Source     string         `json:"source"`
+	Name       string         `json:"name"`
+	TileWidth  int            `json:"tilewidth"`
+	TileHeight int            `json:"tileheight"`
+	Spacing    int            `json:"spacing"`
+	Margin     int            `json:"margin"`
+	TileCount  int            `json:"tilecount"`
+	Columns    int            `json:"columns"`
+	Image      string         `json:"image"`
+	Properties jsonProperties `json:"properties"`
+	Tiles      []struct {
+		Id         int            `json:"id"`
+		Type       string         `json:"type"`
+		Class      string         `json:"class"`
+		Properties jsonProperties `json:"properties"`
+		Animation  []struct {
+			TileId   int `json:"tileid"`
+			Duration int `json:"duration"`
+		} `json:"animation"`
+	} `json:"tiles"`
+}
+
+type jsonLayer struct {
+	Type        string         `json:"type"`
+	Name        string         `json:"name"`
+	Visible     *bool          `json:"visible"`
+	Opacity     *float64       `json:"opacity"`
+	OffsetX     float64        `json:"offsetx"`
+	OffsetY     float64        `json:"offsety"`
+	Properties  jsonProperties `json:"properties"`
+	Encoding    string         `json:"encoding"`
+	Compression string         `json:"compression"`
+
+	// Either an array of gids or a base64 encoded string.
+	Data json.RawMessage `json:"data"`
+
+	Objects []jsonObject `json:"objects"`
+	Layers  []jsonLayer  `json:"layers"`
+}
+
+type jsonPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type jsonObject struct {
+	Id         int            `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Class      string         `json:"class"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Rotation   float64        `json:"rotation"`
+	Gid        uint32         `json:"gid"`
+	Point      bool           `json:"point"`
+	Ellipse    bool           `json:"ellipse"`
+	Polygon    []jsonPoint    `json:"polygon"`
+	Polyline   []jsonPoint    `json:"polyline"`
+	Properties jsonProperties `json:"properties"`
+}
+
+func parseJson(ld loader, name string, data []byte) (*Map, error) {
+	var jm jsonMap
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return nil, err
+	}
+	m := &Map{
+		Width:      jm.Width,
+		Height:     jm.Height,
+		TileWidth:  jm.TileWidth,
+		TileHeight: jm.TileHeight,
+		Properties: jm.Properties.convert(),
+	}
+	if err := m.check(jm.Orientation, jm.Infinite); err != nil {
+		return nil, err
+	}
+	for _, jt := range jm.Tilesets {
+		ts, err := loadTileset(ld, name, jt.FirstGid, jt.Source, func(source string) (*Tileset, error) {
+			return jt.convert(ld, source, jt.FirstGid)
+		})
+		if err != nil {
+			return nil, err
+		}
+		m.Tilesets = append(m.Tilesets, ts)
+	}
+	if err := m.addJsonLayers(jm.Layers, true, 1, 0, 0); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseJsonTileset(ld loader, name string, first_gid uint32, data []byte) (*Tileset, error) {
+	var jt jsonTileset
+	if err := json.Unmarshal(data, &jt); err != nil {
+		return nil, err
+	}
+	return jt.convert(ld, name, first_gid)
+}
+
+// Converts jt, which is in the file at from, to a Tileset.
+func (jt *jsonTileset) convert(ld loader, from string, first_gid uint32) (*Tileset, error) {
+	ts, err := makeTileset(jt.Name, first_gid, jt.TileWidth, jt.TileHeight, jt.Margin, jt.Spacing, jt.Columns, jt.TileCount)
+	if err != nil {
+		return nil, err
+	}
+	ts.Properties = jt.Properties.convert()
+	for _, tile := range jt.Tiles {
+		t, err := ts.tile(tile.Id)
+		if err != nil {
+			return nil, err
+		}
+		t.Type = tile.Type
+		if tile.Class != "" {
+			t.Type = tile.Class
+		}
+		t.Properties = tile.Properties.convert()
+		var frames [][2]int
+		for _, f := range tile.Animation {
+			frames = append(frames, [2]int{f.TileId, f.Duration})
+		}
+		if err := ts.animate(tile.Id, frames); err != nil {
+			return nil, err
+		}
+	}
+	if jt.Image == "" {
+		return nil, fmt.Errorf("Tileset '%s' doesn't have an image, collections of images are not supported.", jt.Name)
+	}
+	if err := ts.loadImage(ld, relativeTo(from, jt.Image)); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func convertPoints(points []jsonPoint) [][2]float64 {
+	converted := make([][2]float64, 0, len(points))
+	for _, p := range points {
+		converted = append(converted, [2]float64{p.X, p.Y})
+	}
+	return converted
+}
+
+// Adds layers, which are in a group with the given visibility, opacity, and
+// offset, to m.
+func (m *Map) addJsonLayers(layers []jsonLayer, visible bool, opacity, dx, dy float64) error {
+	for _, jl := range layers {
+		layer_visible := visible
+		if jl.Visible != nil {
+			layer_visible = visible && *jl.Visible
+		}
+		layer_opacity := opacity
+		if jl.Opacity != nil {
+			layer_opacity *= *jl.Opacity
+		}
+		ox, oy := dx+jl.OffsetX, dy+jl.OffsetY
+		switch jl.Type {
+		case "tilelayer":
+			var gids []uint32
+			if jl.Encoding == "base64" {
+				var text string
+				if err := json.Unmarshal(jl.Data, &text); err != nil {
+					return fmt.Errorf("Layer '%s': %v", jl.Name, err)
+				}
+				var err error
+				if gids, err = decodeGids(jl.Encoding, jl.Compression, text); err != nil {
+					return fmt.Errorf("Layer '%s': %v", jl.Name, err)
+				}
+			} else if err := json.Unmarshal(jl.Data, &gids); err != nil {
+				return fmt.Errorf("Layer '%s': %v", jl.Name, err)
+			}
+			l := &TileLayer{
+				Name:       jl.Name,
+				Properties: jl.Properties.convert(),
+				Visible:    layer_visible,
+				Opacity:    layer_opacity,
+				OffsetX:    ox,
+				OffsetY:    -oy,
+			}
+			if err := m.addTileLayer(l, gids); err != nil {
+				return err
+			}
+
+		case "objectgroup":
+			l := &ObjectLayer{
+				Name:       jl.Name,
+				Properties: jl.Properties.convert(),
+				Visible:    layer_visible,
+			}
+			for _, jo := range jl.Objects {
+				o := &Object{
+					Id:         jo.Id,
+					Name:       jo.Name,
+					Type:       jo.Type,
+					X:          jo.X + ox,
+					Y:          jo.Y + oy,
+					Width:      jo.Width,
+					Height:     jo.Height,
+					Rotation:   jo.Rotation,
+					Point:      jo.Point,
+					Ellipse:    jo.Ellipse,
+					Properties: jo.Properties.convert(),
+				}
+				if jo.Class != "" {
+					o.Type = jo.Class
+				}
+				var err error
+				if o.Tile, err = m.tileForGid(jo.Gid); err != nil {
+					return fmt.Errorf("Object %d: %v", jo.Id, err)
+				}
+				switch {
+				case jo.Polygon != nil:
+					o.Points = convertPoints(jo.Polygon)
+					o.Closed = true
+				case jo.Polyline != nil:
+					o.Points = convertPoints(jo.Polyline)
+				}
+				m.placeObject(o)
+				l.Objects = append(l.Objects, o)
+			}
+			m.ObjectLayers = append(m.ObjectLayers, l)
+
+		case "group":
+			if err := m.addJsonLayers(jl.Layers, layer_visible, layer_opacity, ox, oy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,485 @@
+// Package tilemap loads maps made with the Tiled map editor, in either its
+// .tmx or .json format, and draws them with render.  Only orthogonal maps
+// with a fixed size are supported.
+//
+// Cells are numbered the way Tiled numbers them, with 0, 0 at the top left
+// and y increasing downwards, which is also how they are numbered in the
+// pathing.Grid made by Map.Grid().  World coordinates are in pixels with
+// their origin at the bottom left of the map and y increasing upwards, like
+// everywhere else in glop, so they can be used with a camera.Camera
+// directly.
+package tilemap
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/render"
+	"github.com/runningwild/glop/util/pathing"
+	"image"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Custom properties set on something in Tiled.  Values of every type are
+// kept as strings, the way they are written in .tmx files.
+type Properties map[string]string
+
+// Returns the property name as a number, ok is false if it isn't set or
+// isn't a number.
+func (p Properties) Float(name string) (f float64, ok bool) {
+	s, ok := p[name]
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// Returns true iff the property name is set to true.
+func (p Properties) Bool(name string) bool {
+	b, _ := strconv.ParseBool(p[name])
+	return b
+}
+
+type Map struct {
+	// Size of the map in cells, and the size of each cell in pixels.
+	Width, Height         int
+	TileWidth, TileHeight int
+
+	Properties   Properties
+	Tilesets     []*Tileset
+	TileLayers   []*TileLayer
+	ObjectLayers []*ObjectLayer
+
+	// Milliseconds of Think(), which is what animated tiles go by.
+	time int64
+}
+
+// A Tileset is an image cut up into tiles.  Tile ids in a map are global,
+// FirstGid is the global id of the first tile in this tileset.
+type Tileset struct {
+	Name                  string
+	FirstGid              uint32
+	TileWidth, TileHeight int
+	Margin, Spacing       int
+	Columns               int
+	Properties            Properties
+	Tiles                 []*Tile
+
+	// Path to the image, relative to the filesystem the map was loaded from.
+	Image string
+
+	img     image.Image
+	texture *render.Texture
+	batch   render.QuadBatch
+}
+
+type Tile struct {
+	Tileset    *Tileset
+	Id         int
+	Type       string
+	Properties Properties
+
+	// The frames that the tile cycles through if it is animated, the tile
+	// itself doesn't have to be one of them.
+	Animation []Frame
+	duration  int64
+}
+
+type Frame struct {
+	Tile *Tile
+
+	// In milliseconds
+	Duration int64
+}
+
+// Returns the global id of t.
+func (t *Tile) Gid() uint32 {
+	return t.Tileset.FirstGid + uint32(t.Id)
+}
+
+// Returns the tile that shows for t after time milliseconds.
+func (t *Tile) frame(time int64) *Tile {
+	if t.duration <= 0 {
+		return t
+	}
+	time %= t.duration
+	for _, f := range t.Animation {
+		if time < f.Duration {
+			return f.Tile
+		}
+		time -= f.Duration
+	}
+	return t
+}
+
+// Tiled keeps the flips of each cell in the top bits of its global id.
+const (
+	flipH   uint32 = 0x80000000
+	flipV   uint32 = 0x40000000
+	flipD   uint32 = 0x20000000
+	gidMask uint32 = 0x0fffffff
+)
+
+type TileLayer struct {
+	Name       string
+	Properties Properties
+	Visible    bool
+	Opacity    float64
+
+	// In world coordinates.
+	OffsetX, OffsetY float64
+
+	m      *Map
+	gids   []uint32
+	tiles  []*Tile
+	chunks []chunk
+}
+
+// Returns the tile at x, y, or nil if there isn't one.
+func (l *TileLayer) Tile(x, y int) *Tile {
+	if !l.m.InBounds(x, y) {
+		return nil
+	}
+	return l.tiles[x+y*l.m.Width]
+}
+
+// Returns whether the tile at x, y is flipped horizontally, vertically, or
+// diagonally, which is how Tiled rotates tiles.
+func (l *TileLayer) Flips(x, y int) (h, v, d bool) {
+	if !l.m.InBounds(x, y) {
+		return false, false, false
+	}
+	gid := l.gids[x+y*l.m.Width]
+	return gid&flipH != 0, gid&flipV != 0, gid&flipD != 0
+}
+
+type ObjectLayer struct {
+	Name       string
+	Properties Properties
+	Visible    bool
+	Objects    []*Object
+}
+
+// An Object is a shape placed on the map in Tiled, usually to mark where
+// things like spawn points and triggers go.  X, Y is the bottom left corner
+// of rectangles, ellipses, and tile objects, and the position of points,
+// polygons, and polylines, all in world coordinates.
+type Object struct {
+	Id            int
+	Name, Type    string
+	X, Y          float64
+	Width, Height float64
+
+	// In degrees clockwise, as in Tiled.
+	Rotation float64
+
+	// Set for tile objects.
+	Tile *Tile
+
+	Point, Ellipse bool
+
+	// The corners of polygons and polylines relative to X, Y.  Closed is true
+	// for polygons.
+	Points [][2]float64
+	Closed bool
+
+	Properties Properties
+}
+
+// Loads the map at path on disk.  Tilesets and images are loaded relative to
+// the file that refers to them.
+func LoadMap(path string) (*Map, error) {
+	return loadMap(nil, filepath.ToSlash(path))
+}
+
+// Loads the map at name in fsys.
+func LoadMapFS(fsys fs.FS, name string) (*Map, error) {
+	return loadMap(fsys, name)
+}
+
+// Reads files either from fsys or, if it is nil, from disk.
+type loader struct {
+	fsys fs.FS
+}
+
+func (ld loader) read(name string) ([]byte, error) {
+	if ld.fsys == nil {
+		return os.ReadFile(filepath.FromSlash(name))
+	}
+	return fs.ReadFile(ld.fsys, name)
+}
+
+func (ld loader) open(name string) (io.ReadCloser, error) {
+	if ld.fsys == nil {
+		return os.Open(filepath.FromSlash(name))
+	}
+	return ld.fsys.Open(name)
+}
+
+// Returns the path to name, which was referred to by the file at from.
+func relativeTo(from, name string) string {
+	if path.IsAbs(name) {
+		return name
+	}
+	return path.Join(path.Dir(from), name)
+}
+
+func loadMap(fsys fs.FS, name string) (*Map, error) {
+	ld := loader{fsys}
+	data, err := ld.read(name)
+	if err != nil {
+		return nil, err
+	}
+	var m *Map
+	switch strings.ToLower(path.Ext(name)) {
+	case ".tmx":
+		m, err = parseTmx(ld, name, data)
+	case ".json", ".tmj":
+		m, err = parseJson(ld, name, data)
+	default:
+		return nil, fmt.Errorf("%s is not a .tmx or .json map.", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return m, nil
+}
+
+// Checks the parts of a map that every format has in common.
+func (m *Map) check(orientation string, infinite bool) error {
+	if orientation != "orthogonal" {
+		return fmt.Errorf("Only orthogonal maps are supported, not %s maps.", orientation)
+	}
+	if infinite {
+		return fmt.Errorf("Infinite maps are not supported.")
+	}
+	if m.Width <= 0 || m.Height <= 0 || m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return fmt.Errorf("Map is %dx%d cells of %dx%d pixels.", m.Width, m.Height, m.TileWidth, m.TileHeight)
+	}
+	return nil
+}
+
+// Makes a tileset with count tiles.
+func makeTileset(name string, first_gid uint32, tile_width, tile_height, margin, spacing, columns, count int) (*Tileset, error) {
+	if tile_width <= 0 || tile_height <= 0 || columns <= 0 || count < 0 {
+		return nil, fmt.Errorf("Tileset '%s' has %d columns of %dx%d tiles.", name, columns, tile_width, tile_height)
+	}
+	ts := &Tileset{
+		Name:       name,
+		FirstGid:   first_gid,
+		TileWidth:  tile_width,
+		TileHeight: tile_height,
+		Margin:     margin,
+		Spacing:    spacing,
+		Columns:    columns,
+		Properties: Properties{},
+		Tiles:      make([]*Tile, count),
+	}
+	for i := range ts.Tiles {
+		ts.Tiles[i] = &Tile{Tileset: ts, Id: i, Properties: Properties{}}
+	}
+	return ts, nil
+}
+
+// Returns tile id in ts, or an error if there isn't one.
+func (ts *Tileset) tile(id int) (*Tile, error) {
+	if id < 0 || id >= len(ts.Tiles) {
+		return nil, fmt.Errorf("Tileset '%s' doesn't have a tile %d.", ts.Name, id)
+	}
+	return ts.Tiles[id], nil
+}
+
+// Loads the image for ts from name.
+func (ts *Tileset) loadImage(ld loader, name string) error {
+	f, err := ld.open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	im, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	ts.Image = name
+	ts.img = im
+	return nil
+}
+
+// Makes the tile id in ts cycle through frames, pairs of tile ids and
+// durations.
+func (ts *Tileset) animate(id int, frames [][2]int) error {
+	t, err := ts.tile(id)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		ft, err := ts.tile(f[0])
+		if err != nil {
+			return err
+		}
+		t.Animation = append(t.Animation, Frame{Tile: ft, Duration: int64(f[1])})
+		t.duration += int64(f[1])
+	}
+	return nil
+}
+
+// Returns the tile with global id gid, ignoring flips, or nil if gid is 0.
+func (m *Map) tileForGid(gid uint32) (*Tile, error) {
+	gid &= gidMask
+	if gid == 0 {
+		return nil, nil
+	}
+	var ts *Tileset
+	for _, t := range m.Tilesets {
+		if t.FirstGid <= gid && (ts == nil || t.FirstGid > ts.FirstGid) {
+			ts = t
+		}
+	}
+	if ts == nil || int(gid-ts.FirstGid) >= len(ts.Tiles) {
+		return nil, fmt.Errorf("There is no tile with global id %d.", gid)
+	}
+	return ts.Tiles[gid-ts.FirstGid], nil
+}
+
+// Adds a tile layer with gids, one for each cell, in Tiled's order.
+func (m *Map) addTileLayer(l *TileLayer, gids []uint32) error {
+	if len(gids) != m.Width*m.Height {
+		return fmt.Errorf("Layer '%s' has %d cells, expected %d.", l.Name, len(gids), m.Width*m.Height)
+	}
+	l.m = m
+	l.gids = gids
+	l.tiles = make([]*Tile, len(gids))
+	for i, gid := range gids {
+		t, err := m.tileForGid(gid)
+		if err != nil {
+			return fmt.Errorf("Layer '%s': %v", l.Name, err)
+		}
+		l.tiles[i] = t
+	}
+	l.makeChunks()
+	m.TileLayers = append(m.TileLayers, l)
+	return nil
+}
+
+// Converts o from Tiled's coordinates, where y increases downwards and the
+// position of rectangles is their top left corner, to world coordinates.
+func (m *Map) placeObject(o *Object) {
+	height := float64(m.Height * m.TileHeight)
+	o.Y = height - o.Y
+	if o.Tile == nil && !o.Point && o.Points == nil {
+		o.Y -= o.Height
+	}
+	for i := range o.Points {
+		o.Points[i][1] = -o.Points[i][1]
+	}
+}
+
+// Parses Tiled's "x1,y1 x2,y2 ..." lists of points.
+func parsePoints(s string) ([][2]float64, error) {
+	var points [][2]float64
+	for _, pair := range strings.Fields(s) {
+		var p [2]float64
+		if _, err := fmt.Sscanf(pair, "%g,%g", &p[0], &p[1]); err != nil {
+			return nil, fmt.Errorf("Bad point '%s'.", pair)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func (m *Map) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.Width && y < m.Height
+}
+
+// Returns the cell at the world point x, y, ok is false if that is off the
+// map.
+func (m *Map) CellAt(x, y float64) (cx, cy int, ok bool) {
+	cx = int(math.Floor(x / float64(m.TileWidth)))
+	cy = m.Height - 1 - int(math.Floor(y/float64(m.TileHeight)))
+	return cx, cy, m.InBounds(cx, cy)
+}
+
+// Returns the world rectangle covered by the cell at x, y.
+func (m *Map) CellRect(x, y int) (min_x, min_y, max_x, max_y float64) {
+	tw, th := float64(m.TileWidth), float64(m.TileHeight)
+	min_x = float64(x) * tw
+	min_y = float64(m.Height-1-y) * th
+	return min_x, min_y, min_x + tw, min_y + th
+}
+
+// Returns the layer called name, or nil if there isn't one.
+func (m *Map) Layer(name string) *TileLayer {
+	for _, l := range m.TileLayers {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// Returns the object layer called name, or nil if there isn't one.
+func (m *Map) ObjectLayer(name string) *ObjectLayer {
+	for _, l := range m.ObjectLayers {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// Returns the first object called name in any object layer, or nil if there
+// isn't one.
+func (m *Map) Object(name string) *Object {
+	for _, l := range m.ObjectLayers {
+		for _, o := range l.Objects {
+			if o.Name == name {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the property name of the cell at x, y, which is the property of the
+// tile in the topmost layer whose tile there has it.
+func (m *Map) Property(x, y int, name string) (string, bool) {
+	for i := len(m.TileLayers) - 1; i >= 0; i-- {
+		if t := m.TileLayers[i].Tile(x, y); t != nil {
+			if v, ok := t.Properties[name]; ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Makes a pathing.Grid the size of the map where the cost of each cell is
+// the number in the property cost, see Property(), or 1 for cells that don't
+// have it.  Cells with a negative cost are impassable.
+func (m *Map) Grid(cost string, diagonal bool) *pathing.Grid {
+	g := pathing.MakeGrid(m.Width, m.Height, diagonal)
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			v, ok := m.Property(x, y, cost)
+			if !ok {
+				continue
+			}
+			if c, err := strconv.ParseFloat(v, 64); err == nil {
+				g.SetCost(x, y, c)
+			}
+		}
+	}
+	return g
+}
+
+// Advances animated tiles by dt milliseconds.
+func (m *Map) Think(dt int64) {
+	m.time += dt
+}
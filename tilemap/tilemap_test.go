@@ -0,0 +1,241 @@
+package tilemap_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/tilemap"
+	"image"
+	"image/png"
+	"testing/fstest"
+)
+
+func tilesPng() []byte {
+	var b bytes.Buffer
+	png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 16, 16)))
+	return b.Bytes()
+}
+
+func zlibGids(gids ...uint32) string {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	binary.Write(w, binary.LittleEndian, gids)
+	w.Close()
+	return base64.StdEncoding.EncodeToString(b.Bytes())
+}
+
+const tilesTsx = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="tiles" tilewidth="8" tileheight="8" tilecount="4" columns="2">
+ <image source="tiles.png" width="16" height="16"/>
+ <tile id="1">
+  <properties>
+   <property name="cost" type="int" value="3"/>
+  </properties>
+ </tile>
+ <tile id="2" type="wall">
+  <properties>
+   <property name="cost" type="int" value="-1"/>
+  </properties>
+ </tile>
+ <tile id="3">
+  <animation>
+   <frame tileid="0" duration="100"/>
+   <frame tileid="1" duration="50"/>
+  </animation>
+ </tile>
+</tileset>
+`
+
+var townTmx = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" renderorder="right-down" width="3" height="2" tilewidth="8" tileheight="8" infinite="0">
+ <properties>
+  <property name="music" value="town.ogg"/>
+ </properties>
+ <tileset firstgid="1" source="../tilesets/tiles.tsx"/>
+ <layer id="1" name="ground" width="3" height="2">
+  <data encoding="csv">
+1,1,2,
+3,2147483649,0
+</data>
+ </layer>
+ <group id="2" name="top" offsetx="4">
+  <layer id="3" name="walls" width="3" height="2" opacity="0.5" visible="0">
+   <data encoding="base64" compression="zlib">
+   ` + zlibGids(0, 3, 0, 0, 0, 3) + `
+   </data>
+  </layer>
+ </group>
+ <objectgroup id="4" name="things">
+  <object id="1" name="spawn" x="4" y="4">
+   <point/>
+  </object>
+  <object id="2" name="door" type="trigger" x="8" y="0" width="8" height="8">
+   <properties>
+    <property name="to" value="house.tmx"/>
+   </properties>
+  </object>
+  <object id="3" gid="4" x="0" y="16" width="8" height="8"/>
+  <object id="4" name="path" x="0" y="0">
+   <polyline points="0,0 8,8"/>
+  </object>
+ </objectgroup>
+</map>
+`
+
+var townJson = `{
+ "orientation": "orthogonal", "renderorder": "right-down", "infinite": false,
+ "width": 3, "height": 2, "tilewidth": 8, "tileheight": 8,
+ "properties": [{"name": "music", "type": "string", "value": "town.ogg"}],
+ "tilesets": [{
+  "firstgid": 1, "name": "tiles", "tilewidth": 8, "tileheight": 8, "tilecount": 4, "columns": 2,
+  "image": "../tilesets/tiles.png", "imagewidth": 16, "imageheight": 16,
+  "tiles": [
+   {"id": 1, "properties": [{"name": "cost", "type": "int", "value": 3}]},
+   {"id": 2, "type": "wall", "properties": [{"name": "cost", "type": "int", "value": -1}]},
+   {"id": 3, "animation": [{"tileid": 0, "duration": 100}, {"tileid": 1, "duration": 50}]}
+  ]
+ }],
+ "layers": [
+  {"type": "tilelayer", "name": "ground", "width": 3, "height": 2, "visible": true, "opacity": 1,
+   "data": [1, 1, 2, 3, 2147483649, 0]},
+  {"type": "group", "name": "top", "offsetx": 4, "visible": true, "opacity": 1, "layers": [
+   {"type": "tilelayer", "name": "walls", "width": 3, "height": 2, "visible": false, "opacity": 0.5,
+    "encoding": "base64", "compression": "zlib", "data": "` + zlibGids(0, 3, 0, 0, 0, 3) + `"}
+  ]},
+  {"type": "objectgroup", "name": "things", "visible": true, "opacity": 1, "objects": [
+   {"id": 1, "name": "spawn", "x": 4, "y": 4, "point": true},
+   {"id": 2, "name": "door", "type": "trigger", "x": 8, "y": 0, "width": 8, "height": 8,
+    "properties": [{"name": "to", "type": "string", "value": "house.tmx"}]},
+   {"id": 3, "gid": 4, "x": 0, "y": 16, "width": 8, "height": 8},
+   {"id": 4, "name": "path", "x": 0, "y": 0, "polyline": [{"x": 0, "y": 0}, {"x": 8, "y": 8}]}
+  ]}
+ ]
+}`
+
+func makeFS() fstest.MapFS {
+	return fstest.MapFS{
+		"tilesets/tiles.png": {Data: tilesPng()},
+		"tilesets/tiles.tsx": {Data: []byte(tilesTsx)},
+		"maps/town.tmx":      {Data: []byte(townTmx)},
+		"maps/town.json":     {Data: []byte(townJson)},
+	}
+}
+
+func LoadSpec(c gospec.Context) {
+	fsys := makeFS()
+	for _, name := range []string{"maps/town.tmx", "maps/town.json"} {
+		m, err := tilemap.LoadMapFS(fsys, name)
+		c.Assume(err, Equals, nil)
+
+		c.Specify(name+" has the right size, layers, and tiles", func() {
+			c.Expect(m.Width, Equals, 3)
+			c.Expect(m.Height, Equals, 2)
+			c.Expect(m.Properties["music"], Equals, "town.ogg")
+			c.Expect(len(m.Tilesets), Equals, 1)
+			c.Expect(m.Tilesets[0].Image, Equals, "tilesets/tiles.png")
+			c.Expect(len(m.TileLayers), Equals, 2)
+			ground := m.Layer("ground")
+			c.Expect(ground.Tile(0, 0).Id, Equals, 0)
+			c.Expect(ground.Tile(2, 0).Id, Equals, 1)
+			c.Expect(ground.Tile(0, 1).Type, Equals, "wall")
+			c.Expect(ground.Tile(2, 1) == nil, IsTrue)
+			c.Expect(ground.Tile(5, 5) == nil, IsTrue)
+			h, v, _ := ground.Flips(1, 1)
+			c.Expect(h, Equals, true)
+			c.Expect(v, Equals, false)
+			c.Expect(ground.Tile(1, 1).Gid(), Equals, uint32(1))
+
+			walls := m.Layer("walls")
+			c.Expect(walls.Visible, Equals, false)
+			c.Expect(walls.Opacity, Equals, 0.5)
+			c.Expect(walls.OffsetX, Equals, 4.0)
+			c.Expect(walls.Tile(1, 0).Id, Equals, 2)
+		})
+
+		c.Specify(name+" has animated tiles", func() {
+			anim := m.Tilesets[0].Tiles[3].Animation
+			c.Expect(len(anim), Equals, 2)
+			c.Expect(anim[0].Tile.Id, Equals, 0)
+			c.Expect(anim[1].Tile.Id, Equals, 1)
+			c.Expect(anim[1].Duration, Equals, int64(50))
+		})
+
+		c.Specify(name+" has cell properties for pathing", func() {
+			cost, _ := m.Property(1, 0, "cost")
+			c.Expect(cost, Equals, "-1")
+			cost, _ = m.Property(2, 0, "cost")
+			c.Expect(cost, Equals, "3")
+			_, ok := m.Property(0, 0, "cost")
+			c.Expect(ok, Equals, false)
+			g := m.Grid("cost", false)
+			c.Expect(g.Passable(1, 0), Equals, false)
+			c.Expect(g.Passable(0, 1), Equals, false)
+			c.Expect(g.Cost(2, 0), Equals, 3.0)
+			c.Expect(g.Cost(1, 1), Equals, 1.0)
+			cost_f, path := g.Path(0, 0, 1, 1)
+			c.Expect(cost_f, Equals, -1.0)
+			c.Expect(path == nil, IsTrue)
+		})
+
+		c.Specify(name+" has objects in world coordinates", func() {
+			spawn := m.Object("spawn")
+			c.Expect(spawn.Point, Equals, true)
+			c.Expect(spawn.X, Equals, 4.0)
+			c.Expect(spawn.Y, Equals, 12.0)
+			door := m.Object("door")
+			c.Expect(door.Type, Equals, "trigger")
+			c.Expect(door.Y, Equals, 8.0)
+			c.Expect(door.Properties["to"], Equals, "house.tmx")
+			things := m.ObjectLayer("things")
+			c.Expect(len(things.Objects), Equals, 4)
+			c.Expect(things.Objects[2].Tile.Id, Equals, 3)
+			c.Expect(things.Objects[2].Y, Equals, 0.0)
+			path := m.Object("path")
+			c.Expect(path.Y, Equals, 16.0)
+			c.Expect(path.Closed, Equals, false)
+			c.Expect(path.Points, ContainsInOrder, [][2]float64{{0, 0}, {8, -8}})
+		})
+
+		c.Specify(name+" converts between cells and world coordinates", func() {
+			x, y, ok := m.CellAt(4, 12)
+			c.Expect(ok, Equals, true)
+			c.Expect([2]int{x, y}, Equals, [2]int{0, 0})
+			x, y, ok = m.CellAt(20, 1)
+			c.Expect(ok, Equals, true)
+			c.Expect([2]int{x, y}, Equals, [2]int{2, 1})
+			_, _, ok = m.CellAt(-1, 0)
+			c.Expect(ok, Equals, false)
+			min_x, min_y, max_x, max_y := m.CellRect(2, 0)
+			c.Expect([4]float64{min_x, min_y, max_x, max_y}, Equals, [4]float64{16, 8, 24, 16})
+		})
+	}
+}
+
+func ErrorSpec(c gospec.Context) {
+	fsys := makeFS()
+	c.Specify("Infinite and non-orthogonal maps are rejected", func() {
+		fsys["maps/infinite.json"] = &fstest.MapFile{Data: []byte(`{"orientation": "orthogonal", "infinite": true, "width": 1, "height": 1, "tilewidth": 8, "tileheight": 8}`)}
+		fsys["maps/iso.json"] = &fstest.MapFile{Data: []byte(`{"orientation": "isometric", "width": 1, "height": 1, "tilewidth": 8, "tileheight": 8}`)}
+		_, err := tilemap.LoadMapFS(fsys, "maps/infinite.json")
+		c.Expect(err, Not(Equals), nil)
+		_, err = tilemap.LoadMapFS(fsys, "maps/iso.json")
+		c.Expect(err, Not(Equals), nil)
+	})
+	c.Specify("Missing tilesets and bad tiles are errors", func() {
+		delete(fsys, "tilesets/tiles.tsx")
+		_, err := tilemap.LoadMapFS(fsys, "maps/town.tmx")
+		c.Expect(err, Not(Equals), nil)
+		fsys = makeFS()
+		fsys["maps/bad.json"] = &fstest.MapFile{Data: bytes.Replace([]byte(townJson), []byte("[1, 1, 2,"), []byte("[1, 9, 2,"), 1)}
+		_, err = tilemap.LoadMapFS(fsys, "maps/bad.json")
+		c.Expect(err, Not(Equals), nil)
+	})
+	c.Specify("Layers must be the size of the map", func() {
+		fsys["maps/short.json"] = &fstest.MapFile{Data: bytes.Replace([]byte(townJson), []byte("3, 2147483649, 0]"), []byte("3]"), 1)}
+		_, err := tilemap.LoadMapFS(fsys, "maps/short.json")
+		c.Expect(err, Not(Equals), nil)
+	})
+}
@@ -0,0 +1,362 @@
+package tilemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+
+	// Multi-line strings are kept in the element instead of value.
+	Text string `xml:",chardata"`
+}
+
+type tmxProperties []tmxProperty
+
+func (tp tmxProperties) convert() Properties {
+	props := Properties{}
+	for _, p := range tp {
+		if p.Value == "" {
+			props[p.Name] = p.Text
+		} else {
+			props[p.Name] = p.Value
+		}
+	}
+	return props
+}
+
+type tmxMap struct {
+	Orientation string        `xml:"orientation,attr"`
+	Width       int           `xml:"width,attr"`
+	Height      int           `xml:"height,attr"`
+	TileWidth   int           `xml:"tilewidth,attr"`
+	TileHeight  int           `xml:"tileheight,attr"`
+	Infinite    int           `xml:"infinite,attr"`
+	Properties  tmxProperties `xml:"properties>property"`
+	Tilesets    []tmxTileset  `xml:"tileset"`
+
+	// Layers, object groups, and groups, in the order they're drawn in.
+	Layers []tmxLayer `xml:",any"`
+}
+
+type tmxTileset struct {
+	FirstGid   uint32        `xml:"firstgid,attr"`
+	Source     string        `xml:"source,attr"`
+	Name       string        `xml:"name,attr"`
+	TileWidth  int           `xml:"tilewidth,attr"`
+	TileHeight int           `xml:"tileheight,attr"`
+	Spacing    int           `xml:"spacing,attr"`
+	Margin     int           `xml:"margin,attr"`
+	TileCount  int           `xml:"tilecount,attr"`
+	Columns    int           `xml:"columns,attr"`
+	Properties tmxProperties `xml:"properties>property"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+	} `xml:"image"`
+	Tiles []struct {
+		Id         int           `xml:"id,attr"`
+		Type       string        `xml:"type,attr"`
+		Class      string        `xml:"class,attr"`
+		Properties tmxProperties `xml:"properties>property"`
+		Frames     []struct {
+			TileId   int `xml:"tileid,attr"`
+			Duration int `xml:"duration,attr"`
+		} `xml:"animation>frame"`
+	} `xml:"tile"`
+}
+
+type tmxLayer struct {
+	XMLName    xml.Name
+	Name       string        `xml:"name,attr"`
+	Visible    string        `xml:"visible,attr"`
+	Opacity    string        `xml:"opacity,attr"`
+	OffsetX    float64       `xml:"offsetx,attr"`
+	OffsetY    float64       `xml:"offsety,attr"`
+	Properties tmxProperties `xml:"properties>property"`
+	Data       struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Text        string `xml:",chardata"`
+		Tiles       []struct {
+			Gid uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+	} `xml:"data"`
+	Objects []tmxObject `xml:"object"`
+
+	// The layers in a group.
+	Layers []tmxLayer `xml:",any"`
+}
+
+type tmxObject struct {
+	Id         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Rotation   float64       `xml:"rotation,attr"`
+	Gid        uint32        `xml:"gid,attr"`
+	Properties tmxProperties `xml:"properties>property"`
+	Point      *struct{}     `xml:"point"`
+	Ellipse    *struct{}     `xml:"ellipse"`
+	Polygon    *struct {
+		Points string `xml:"points,attr"`
+	} `xml:"polygon"`
+	Polyline *struct {
+		Points string `xml:"points,attr"`
+	} `xml:"polyline"`
+}
+
+func parseTmx(ld loader, name string, data []byte) (*Map, error) {
+	var tm tmxMap
+	if err := xml.Unmarshal(data, &tm); err != nil {
+		return nil, err
+	}
+	m := &Map{
+		Width:      tm.Width,
+		Height:     tm.Height,
+		TileWidth:  tm.TileWidth,
+		TileHeight: tm.TileHeight,
+		Properties: tm.Properties.convert(),
+	}
+	if err := m.check(tm.Orientation, tm.Infinite != 0); err != nil {
+		return nil, err
+	}
+	for _, tt := range tm.Tilesets {
+		ts, err := loadTileset(ld, name, tt.FirstGid, tt.Source, func(source string) (*Tileset, error) {
+			return tt.convert(ld, source, tt.FirstGid)
+		})
+		if err != nil {
+			return nil, err
+		}
+		m.Tilesets = append(m.Tilesets, ts)
+	}
+	if err := m.addTmxLayers(tm.Layers, true, 1, 0, 0); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Returns the tileset with first_gid from source if it is set, otherwise
+// calls embedded with the path of the map, from, which is what the image is
+// relative to.
+func loadTileset(ld loader, from string, first_gid uint32, source string, embedded func(string) (*Tileset, error)) (*Tileset, error) {
+	if source == "" {
+		return embedded(from)
+	}
+	source = relativeTo(from, source)
+	data, err := ld.read(source)
+	if err != nil {
+		return nil, err
+	}
+	var ts *Tileset
+	switch ext := strings.ToLower(source[strings.LastIndex(source, ".")+1:]); ext {
+	case "tsx":
+		var tt tmxTileset
+		if err = xml.Unmarshal(data, &tt); err == nil {
+			ts, err = tt.convert(ld, source, first_gid)
+		}
+	case "json", "tsj":
+		ts, err = parseJsonTileset(ld, source, first_gid, data)
+	default:
+		return nil, fmt.Errorf("%s is not a .tsx or .json tileset.", source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	return ts, nil
+}
+
+// Converts tt, which is in the file at from, to a Tileset.
+func (tt *tmxTileset) convert(ld loader, from string, first_gid uint32) (*Tileset, error) {
+	ts, err := makeTileset(tt.Name, first_gid, tt.TileWidth, tt.TileHeight, tt.Margin, tt.Spacing, tt.Columns, tt.TileCount)
+	if err != nil {
+		return nil, err
+	}
+	ts.Properties = tt.Properties.convert()
+	for _, tile := range tt.Tiles {
+		t, err := ts.tile(tile.Id)
+		if err != nil {
+			return nil, err
+		}
+		t.Type = tile.Type
+		if tile.Class != "" {
+			t.Type = tile.Class
+		}
+		t.Properties = tile.Properties.convert()
+		var frames [][2]int
+		for _, f := range tile.Frames {
+			frames = append(frames, [2]int{f.TileId, f.Duration})
+		}
+		if err := ts.animate(tile.Id, frames); err != nil {
+			return nil, err
+		}
+	}
+	if tt.Image.Source == "" {
+		return nil, fmt.Errorf("Tileset '%s' doesn't have an image, collections of images are not supported.", tt.Name)
+	}
+	if err := ts.loadImage(ld, relativeTo(from, tt.Image.Source)); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// Parses Tiled's visible and opacity attributes, which default to 1 when
+// they're left out.
+func parseVisible(s string) bool {
+	return s != "0"
+}
+
+func parseOpacity(s string) (float64, error) {
+	if s == "" {
+		return 1, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Adds layers, which are in a group with the given visibility, opacity, and
+// offset, to m.
+func (m *Map) addTmxLayers(layers []tmxLayer, visible bool, opacity, dx, dy float64) error {
+	for _, tl := range layers {
+		layer_opacity, err := parseOpacity(tl.Opacity)
+		if err != nil {
+			return fmt.Errorf("Layer '%s' has a bad opacity.", tl.Name)
+		}
+		layer_visible := visible && parseVisible(tl.Visible)
+		layer_opacity *= opacity
+		ox, oy := dx+tl.OffsetX, dy+tl.OffsetY
+		switch tl.XMLName.Local {
+		case "layer":
+			var gids []uint32
+			if tl.Data.Encoding == "" {
+				for _, t := range tl.Data.Tiles {
+					gids = append(gids, t.Gid)
+				}
+			} else {
+				gids, err = decodeGids(tl.Data.Encoding, tl.Data.Compression, tl.Data.Text)
+				if err != nil {
+					return fmt.Errorf("Layer '%s': %v", tl.Name, err)
+				}
+			}
+			l := &TileLayer{
+				Name:       tl.Name,
+				Properties: tl.Properties.convert(),
+				Visible:    layer_visible,
+				Opacity:    layer_opacity,
+				OffsetX:    ox,
+				OffsetY:    -oy,
+			}
+			if err := m.addTileLayer(l, gids); err != nil {
+				return err
+			}
+
+		case "objectgroup":
+			l := &ObjectLayer{
+				Name:       tl.Name,
+				Properties: tl.Properties.convert(),
+				Visible:    layer_visible,
+			}
+			for _, to := range tl.Objects {
+				o := &Object{
+					Id:         to.Id,
+					Name:       to.Name,
+					Type:       to.Type,
+					X:          to.X + ox,
+					Y:          to.Y + oy,
+					Width:      to.Width,
+					Height:     to.Height,
+					Rotation:   to.Rotation,
+					Point:      to.Point != nil,
+					Ellipse:    to.Ellipse != nil,
+					Properties: to.Properties.convert(),
+				}
+				if to.Class != "" {
+					o.Type = to.Class
+				}
+				if o.Tile, err = m.tileForGid(to.Gid); err != nil {
+					return fmt.Errorf("Object %d: %v", to.Id, err)
+				}
+				switch {
+				case to.Polygon != nil:
+					o.Points, err = parsePoints(to.Polygon.Points)
+					o.Closed = true
+				case to.Polyline != nil:
+					o.Points, err = parsePoints(to.Polyline.Points)
+				}
+				if err != nil {
+					return fmt.Errorf("Object %d: %v", to.Id, err)
+				}
+				m.placeObject(o)
+				l.Objects = append(l.Objects, o)
+			}
+			m.ObjectLayers = append(m.ObjectLayers, l)
+
+		case "group":
+			if err := m.addTmxLayers(tl.Layers, layer_visible, layer_opacity, ox, oy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Decodes the tiles in a layer's data, which is either csv or base64 encoded
+// little endian uint32s, possibly compressed.
+func decodeGids(encoding, compression, text string) ([]uint32, error) {
+	var gids []uint32
+	switch encoding {
+	case "csv":
+		for _, field := range strings.Split(text, ",") {
+			gid, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Bad tile '%s'.", strings.TrimSpace(field))
+			}
+			gids = append(gids, uint32(gid))
+		}
+		return gids, nil
+
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+		var r io.Reader = bytes.NewReader(data)
+		switch compression {
+		case "":
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, err
+			}
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported compression '%s'.", compression)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+		if len(data)%4 != 0 {
+			return nil, fmt.Errorf("Layer data is truncated.")
+		}
+		gids = make([]uint32, len(data)/4)
+		for i := range gids {
+			gids[i] = binary.LittleEndian.Uint32(data[4*i:])
+		}
+		return gids, nil
+	}
+	return nil, fmt.Errorf("Unsupported encoding '%s'.", encoding)
+}
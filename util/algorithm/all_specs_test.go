@@ -8,12 +8,16 @@ import (
 func TestAllSpecs(t *testing.T) {
   r := gospec.NewRunner()
   r.AddSpec(DijkstraSpec)
+  r.AddSpec(TypedDijkstraSpec)
+  r.AddSpec(AStarSpec)
+  r.AddSpec(PathCacheSpec)
   r.AddSpec(ReachableSpec)
   r.AddSpec(ReachableDestinationsSpec)
   r.AddSpec(ChooserSpec)
-  r.AddSpec(Chooser2Spec)
   r.AddSpec(MapperSpec)
   r.AddSpec(Mapper2Spec)
+  r.AddSpec(FilterSpec)
   r.AddSpec(TopoSpec)
+  r.AddSpec(FindCyclesSpec)
   gospec.MainGoTest(r, t)
 }
@@ -0,0 +1,148 @@
+package algorithm
+
+import (
+  "container/heap"
+  "sync"
+)
+
+// A Heuristic estimates the total weight of the cheapest path from a vertex to
+// the nearest destination.  AStar only finds the cheapest path if the
+// estimate is never more than the actual weight.
+type Heuristic func(v int) float64
+
+type aNode struct {
+  dNode
+
+  // Weight of the path from a source to v, dNode.weight is this plus the
+  // heuristic's estimate for v, and is what the heap is ordered by.
+  cost float64
+}
+
+type aArray []aNode
+
+func (aa *aArray) Len() int {
+  return len(*aa)
+}
+func (aa *aArray) Swap(i, j int) {
+  (*aa)[i], (*aa)[j] = (*aa)[j], (*aa)[i]
+}
+func (aa *aArray) Less(i, j int) bool {
+  if (*aa)[i].weight != (*aa)[j].weight {
+    return (*aa)[i].weight < (*aa)[j].weight
+  }
+  return (*aa)[i].count < (*aa)[j].count
+}
+func (aa *aArray) Push(x interface{}) {
+  *aa = append(*aa, x.(aNode))
+}
+func (aa *aArray) Pop() interface{} {
+  val := (*aa)[len(*aa)-1]
+  *aa = (*aa)[0 : len(*aa)-1]
+  return val
+}
+
+// Like Dijkstra, but uses h to search towards the destinations first, which
+// on large graphs like grids visits far fewer vertices.  If h is nil this is
+// the same as Dijkstra.  Returns the weight of the path and the path, or -1
+// and nil if none of the destinations can be reached.
+func AStar(g Graph, src []int, dst []int, h Heuristic) (float64, []int) {
+  if h == nil {
+    h = func(int) float64 { return 0 }
+  }
+  used := make([]bool, g.NumVertex())
+  conn := make([]int, g.NumVertex())
+  a := make(aArray, len(src))
+  for i, s := range src {
+    a[i] = aNode{dNode: dNode{v: s, p: -1, weight: h(s)}}
+  }
+  heap.Init(&a)
+  target := make(map[int]bool, len(dst))
+  for _, d := range dst {
+    target[d] = true
+  }
+
+  node_count := 0
+  for len(a) > 0 {
+    cur := heap.Pop(&a).(aNode)
+    if used[cur.v] {
+      continue
+    }
+    used[cur.v] = true
+    conn[cur.v] = cur.p
+    if target[cur.v] {
+      var path []int
+      for c := cur.v; c != -1; c = conn[c] {
+        path = append(path, c)
+      }
+      for i := 0; i < len(path)/2; i++ {
+        path[i], path[len(path)-i-1] = path[len(path)-i-1], path[i]
+      }
+      return cur.cost, path
+    }
+    adj, weights := g.Adjacent(cur.v)
+    for i := range adj {
+      if used[adj[i]] {
+        continue
+      }
+      cost := cur.cost + weights[i]
+      heap.Push(&a, aNode{
+        dNode: dNode{v: adj[i], p: cur.v, weight: cost + h(adj[i]), count: node_count},
+        cost:  cost,
+      })
+      node_count++
+    }
+  }
+  return -1, nil
+}
+
+// Identifies a path in a PathCache.  End can be any value that identifies the
+// destinations, for example -1 if they are implied by Tag.
+type PathKey struct {
+  Start, End int
+  Tag        string
+}
+
+type cachedPath struct {
+  weight float64
+  path   []int
+}
+
+// A PathCache remembers paths so that repeatedly asking for the same path over
+// a graph that hasn't changed doesn't search the graph every time.  It is
+// safe to use from multiple goroutines.
+type PathCache struct {
+  mutex sync.Mutex
+  paths map[PathKey]cachedPath
+}
+
+func MakePathCache() *PathCache {
+  return &PathCache{paths: make(map[PathKey]cachedPath)}
+}
+
+// Returns the path for key, calling find to get it if it isn't in the cache
+// yet.  Paths that weren't found, where find returned a nil path, are cached
+// too.  The returned path is a copy and can be modified freely.
+func (pc *PathCache) Path(key PathKey, find func() (float64, []int)) (float64, []int) {
+  pc.mutex.Lock()
+  cached, ok := pc.paths[key]
+  pc.mutex.Unlock()
+  if !ok {
+    cached.weight, cached.path = find()
+    pc.mutex.Lock()
+    pc.paths[key] = cached
+    pc.mutex.Unlock()
+  }
+  if cached.path == nil {
+    return cached.weight, nil
+  }
+  path := make([]int, len(cached.path))
+  copy(path, cached.path)
+  return cached.weight, path
+}
+
+// Forgets all cached paths, this must be called whenever the graph changes.
+func (pc *PathCache) Clear() {
+  pc.mutex.Lock()
+  defer pc.mutex.Unlock()
+  pc.paths = make(map[PathKey]cachedPath)
+}
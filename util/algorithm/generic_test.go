@@ -8,61 +8,30 @@ import (
 )
 
 func ChooserSpec(c gospec.Context) {
-  c.Specify("Choose on []int", func() {
-    a := []int{0,1,2,3,4,5,6,7,8,9}
-    var b []int
-    b = algorithm.Choose(a, func(v interface{}) bool { return v.(int) % 2 == 0 }).([]int)
-    c.Expect(b, ContainsInOrder, []int{0, 2, 4, 6, 8})
-
-    b = algorithm.Choose(a, func(v interface{}) bool { return v.(int) % 2 == 1 }).([]int)
-    c.Expect(b, ContainsInOrder, []int{1, 3, 5, 7, 9})
-
-    b = algorithm.Choose(a, func(v interface{}) bool { return true }).([]int)
-    c.Expect(b, ContainsInOrder, a)
-
-    b = algorithm.Choose(a, func(v interface{}) bool { return false }).([]int)
-    c.Expect(b, ContainsInOrder, []int{})
-
-    b = algorithm.Choose([]int{}, func(v interface{}) bool { return false }).([]int)
-    c.Expect(b, ContainsInOrder, []int{})
-  })
-
-  c.Specify("Choose on []string", func() {
-    a := []string{"foo", "bar", "wing", "ding", "monkey", "machine"}
-    var b []string
-    b = algorithm.Choose(a, func(v interface{}) bool { return v.(string) > "foo" }).([]string)
-    c.Expect(b, ContainsInOrder, []string{"wing", "monkey", "machine"})
-
-    b = algorithm.Choose(a, func(v interface{}) bool { return v.(string) < "foo" }).([]string)
-    c.Expect(b, ContainsInOrder, []string{"bar", "ding"})
-  })
-}
-
-func Chooser2Spec(c gospec.Context) {
   c.Specify("Choose on []int", func() {
     a := []int{0,1,2,3,4,5,6,7,8,9}
     b := make([]int, len(a))
     copy(b, a)
-    algorithm.Choose2(&b, func(v int) bool { return v % 2 == 0 })
+    algorithm.Choose(&b, func(v int) bool { return v % 2 == 0 })
     c.Expect(b, ContainsInOrder, []int{0, 2, 4, 6, 8})
 
     b = make([]int, len(a))
     copy(b, a)
-    algorithm.Choose2(&b, func(v int) bool { return v % 2 == 1 })
+    algorithm.Choose(&b, func(v int) bool { return v % 2 == 1 })
     c.Expect(b, ContainsInOrder, []int{1, 3, 5, 7, 9})
 
     b = make([]int, len(a))
     copy(b, a)
-    algorithm.Choose2(&b, func(v int) bool { return true })
+    algorithm.Choose(&b, func(v int) bool { return true })
     c.Expect(b, ContainsInOrder, a)
 
     b = make([]int, len(a))
     copy(b, a)
-    algorithm.Choose2(&b, func(v int) bool { return false })
+    algorithm.Choose(&b, func(v int) bool { return false })
     c.Expect(b, ContainsInOrder, []int{})
 
     b = b[0:0]
-    algorithm.Choose2(&b, func(v int) bool { return false })
+    algorithm.Choose(&b, func(v int) bool { return false })
     c.Expect(b, ContainsInOrder, []int{})
   })
 
@@ -70,17 +39,17 @@ func Chooser2Spec(c gospec.Context) {
     a := []string{"foo", "bar", "wing", "ding", "monkey", "machine"}
     b := make([]string, len(a))
     copy(b, a)
-    algorithm.Choose2(&b, func(v string) bool { return v > "foo" })
+    algorithm.Choose(&b, func(v string) bool { return v > "foo" })
     c.Expect(b, ContainsInOrder, []string{"wing", "monkey", "machine"})
 
     b = make([]string, len(a))
     copy(b, a)
-    algorithm.Choose2(&b, func(v string) bool { return v < "foo" })
+    algorithm.Choose(&b, func(v string) bool { return v < "foo" })
     c.Expect(b, ContainsInOrder, []string{"bar", "ding"})
 
     b = make([]string, len(a))
     copy(b, a)
-    algorithm.Choose2(&b, func(v string) bool { return true })
+    algorithm.Choose(&b, func(v string) bool { return true })
     c.Expect(b, ContainsInOrder, a)
   })
 }
@@ -88,14 +57,12 @@ func Chooser2Spec(c gospec.Context) {
 func MapperSpec(c gospec.Context) {
   c.Specify("Map from []int to []float64", func() {
     a := []int{0,1,2,3,4}
-    var b []float64
-    b = algorithm.Map(a, []float64{}, func(v interface{}) interface{} { return float64(v.(int)) }).([]float64)
+    b := algorithm.Map(a, func(v int) float64 { return float64(v) })
     c.Expect(b, ContainsInOrder, []float64{0,1,2,3,4})
   })
   c.Specify("Map from []int to []string", func() {
     a := []int{0,1,2,3,4}
-    var b []string
-    b = algorithm.Map(a, []string{}, func(v interface{}) interface{} { return fmt.Sprintf("%d", v) }).([]string)
+    b := algorithm.Map(a, func(v int) string { return fmt.Sprintf("%d", v) })
     c.Expect(b, ContainsInOrder, []string{"0", "1", "2", "3", "4"})
   })
 }
@@ -107,10 +74,34 @@ func Mapper2Spec(c gospec.Context) {
     algorithm.Map2(a, &b, func(n int) float64 { return float64(n) })
     c.Expect(b, ContainsInOrder, []float64{0,1,2,3,4})
   })
-  // c.Specify("Map from []int to []string", func() {
-  //   a := []int{0,1,2,3,4}
-  //   var b []string
-  //   b = algorithm.Map(a, []string{}, func(v interface{}) interface{} { return fmt.Sprintf("%d", v) }).([]string)
-  //   c.Expect(b, ContainsInOrder, []string{"0", "1", "2", "3", "4"})
-  // })
+  c.Specify("Map from []int to []string", func() {
+    a := []int{0,1,2,3,4}
+    var b []string
+    algorithm.Map2(a, &b, func(n int) string { return fmt.Sprintf("%d", n) })
+    c.Expect(b, ContainsInOrder, []string{"0", "1", "2", "3", "4"})
+  })
+  c.Specify("Map2 reuses the output slice if it has room", func() {
+    b := make([]float64, 10)
+    b[0] = -1
+    algorithm.Map2([]int{3,4}, &b, func(n int) float64 { return float64(n) })
+    c.Expect(b, ContainsInOrder, []float64{3,4})
+    c.Expect(cap(b), Equals, 10)
+  })
+}
+
+func FilterSpec(c gospec.Context) {
+  c.Specify("Filter on []int", func() {
+    a := []int{0,1,2,3,4,5,6,7,8,9}
+    a = algorithm.Filter(a, func(v int) bool { return v % 2 == 0 })
+    c.Expect(a, ContainsInOrder, []int{0, 2, 4, 6, 8})
+    a = algorithm.Filter(a, func(v int) bool { return false })
+    c.Expect(a, ContainsInOrder, []int{})
+  })
+  c.Specify("Filter clears out the elements it removes", func() {
+    x, y := 1, 2
+    a := []*int{&x, &y}
+    b := algorithm.Filter(a, func(v *int) bool { return *v == 1 })
+    c.Expect(len(b), Equals, 1)
+    c.Expect(a[1] == nil, Equals, true)
+  })
 }
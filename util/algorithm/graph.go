@@ -113,6 +113,92 @@ func ReachableDestinations(g Graph, src []int, dst []int) []int {
   return reachable
 }
 
+// A TypedGraph is a Graph whose vertices are identified by values of some
+// type other than an index, like grid positions or pointers to nodes.
+type TypedGraph[V comparable] interface {
+  Adjacent(V) ([]V, []float64)
+}
+
+type tNode[V comparable] struct {
+  v, p   V
+  has_p  bool
+  weight float64
+  count  int
+}
+
+type tArray[V comparable] []tNode[V]
+
+func (ta *tArray[V]) Len() int {
+  return len(*ta)
+}
+func (ta *tArray[V]) Swap(i, j int) {
+  (*ta)[i], (*ta)[j] = (*ta)[j], (*ta)[i]
+}
+func (ta *tArray[V]) Less(i, j int) bool {
+  if (*ta)[i].weight != (*ta)[j].weight {
+    return (*ta)[i].weight < (*ta)[j].weight
+  }
+  return (*ta)[i].count < (*ta)[j].count
+}
+func (ta *tArray[V]) Push(x interface{}) {
+  *ta = append(*ta, x.(tNode[V]))
+}
+func (ta *tArray[V]) Pop() interface{} {
+  val := (*ta)[len(*ta)-1]
+  *ta = (*ta)[0 : len(*ta)-1]
+  return val
+}
+
+// Like Dijkstra, but on a TypedGraph.  Returns the weight of the cheapest path
+// from any vertex in src to any vertex in dst along with the path, or -1 and
+// nil if there is no such path.
+func TypedDijkstra[V comparable](g TypedGraph[V], src []V, dst []V) (float64, []V) {
+  used := make(map[V]bool)
+  conn := make(map[V]tNode[V])
+  h := make(tArray[V], len(src))
+  for i, s := range src {
+    h[i] = tNode[V]{v: s}
+  }
+  target := make(map[V]bool, len(dst))
+  for _, d := range dst {
+    target[d] = true
+  }
+
+  node_count := 0
+  for len(h) > 0 {
+    cur := heap.Pop(&h).(tNode[V])
+    if used[cur.v] {
+      continue
+    }
+    used[cur.v] = true
+    conn[cur.v] = cur
+    if target[cur.v] {
+      // Extract the path, it comes out backwards so reverse it
+      var path []V
+      for c := cur; ; c = conn[c.p] {
+        path = append(path, c.v)
+        if !c.has_p {
+          break
+        }
+      }
+      for i := 0; i < len(path)/2; i++ {
+        path[i], path[len(path)-i-1] = path[len(path)-i-1], path[i]
+      }
+      return cur.weight, path
+    }
+    adj, weights := g.Adjacent(cur.v)
+    for i := range adj {
+      heap.Push(&h, tNode[V]{v: adj[i], p: cur.v, has_p: true, weight: weights[i] + cur.weight, count: node_count})
+      node_count++
+    }
+  }
+  return -1, nil
+}
+
+// Returns the weight of the cheapest path from any vertex in src to any vertex
+// in dst along with the path, or -1 and nil if there is no such path.  Keeps
+// its bookkeeping in slices indexed by vertex, so it allocates much less than
+// TypedDijkstra does for the same graph.
 func Dijkstra(g Graph, src []int, dst []int) (float64, []int) {
   used := make([]bool, g.NumVertex())
   conn := make([]int, g.NumVertex())
@@ -204,3 +290,135 @@ func TopoSort(dag DiGraph) []int {
   }
   return ordering
 }
+
+// Lets a Graph be used where a DiGraph is needed, ignoring edge weights.
+type graphSuccessors struct {
+  g Graph
+}
+
+func (gs graphSuccessors) NumVertex() int {
+  return gs.g.NumVertex()
+}
+func (gs graphSuccessors) Successors(v int) []int {
+  adj, _ := gs.g.Adjacent(v)
+  return adj
+}
+
+// Same as TopoSort, but on a Graph, edge weights are ignored.
+func TopoSortGraph(g Graph) []int {
+  return TopoSort(graphSuccessors{g})
+}
+
+type tarjanState struct {
+  g       Graph
+  index   []int
+  lowlink []int
+  on      []bool
+  stack   []int
+  next    int
+  sccs    [][]int
+}
+
+func (ts *tarjanState) connect(v int) {
+  ts.next++
+  ts.index[v] = ts.next
+  ts.lowlink[v] = ts.next
+  ts.stack = append(ts.stack, v)
+  ts.on[v] = true
+  adj, _ := ts.g.Adjacent(v)
+  for _, w := range adj {
+    if ts.index[w] == 0 {
+      ts.connect(w)
+      if ts.lowlink[w] < ts.lowlink[v] {
+        ts.lowlink[v] = ts.lowlink[w]
+      }
+    } else if ts.on[w] && ts.index[w] < ts.lowlink[v] {
+      ts.lowlink[v] = ts.index[w]
+    }
+  }
+  if ts.lowlink[v] != ts.index[v] {
+    return
+  }
+  var scc []int
+  for {
+    w := ts.stack[len(ts.stack)-1]
+    ts.stack = ts.stack[0 : len(ts.stack)-1]
+    ts.on[w] = false
+    scc = append(scc, w)
+    if w == v {
+      break
+    }
+  }
+  ts.sccs = append(ts.sccs, scc)
+}
+
+// Returns the shortest cycle through start that stays within in.
+func cycleThrough(g Graph, start int, in map[int]bool) []int {
+  prev := map[int]int{start: -1}
+  cur := []int{start}
+  for len(cur) > 0 {
+    v := cur[0]
+    cur = cur[1:]
+    adj, _ := g.Adjacent(v)
+    for _, w := range adj {
+      if w == start {
+        var cycle []int
+        for c := v; c != -1; c = prev[c] {
+          cycle = append(cycle, c)
+        }
+        for i := 0; i < len(cycle)/2; i++ {
+          cycle[i], cycle[len(cycle)-i-1] = cycle[len(cycle)-i-1], cycle[i]
+        }
+        return cycle
+      }
+      if _, ok := prev[w]; !ok && in[w] {
+        prev[w] = v
+        cur = append(cur, w)
+      }
+    }
+  }
+  return nil
+}
+
+// Returns a cycle from each group of vertices in g that can all reach each
+// other, so if g has any cycles at least one is returned, and every vertex that
+// is on a cycle is in the same group as one of the returned cycles.  Each
+// cycle is the list of vertices on it, starting with the lowest numbered
+// vertex in its group and not repeating it at the end, and the cycles are
+// sorted by their first vertex.  Returns nil if g is acyclic.
+func FindCycles(g Graph) [][]int {
+  ts := tarjanState{
+    g:       g,
+    index:   make([]int, g.NumVertex()),
+    lowlink: make([]int, g.NumVertex()),
+    on:      make([]bool, g.NumVertex()),
+  }
+  for v := 0; v < g.NumVertex(); v++ {
+    if ts.index[v] == 0 {
+      ts.connect(v)
+    }
+  }
+  var cycles [][]int
+  for _, scc := range ts.sccs {
+    in := make(map[int]bool, len(scc))
+    start := scc[0]
+    for _, v := range scc {
+      in[v] = true
+      if v < start {
+        start = v
+      }
+    }
+    // A single vertex is only a cycle if it has an edge to itself.
+    if cycle := cycleThrough(g, start, in); cycle != nil {
+      cycles = append(cycles, cycle)
+    }
+  }
+  sort.Sort(cycleArray(cycles))
+  return cycles
+}
+
+type cycleArray [][]int
+
+func (ca cycleArray) Len() int           { return len(ca) }
+func (ca cycleArray) Swap(i, j int)      { ca[i], ca[j] = ca[j], ca[i] }
+func (ca cycleArray) Less(i, j int) bool { return ca[i][0] < ca[j][0] }
@@ -59,6 +59,115 @@ func DijkstraSpec(c gospec.Context) {
   })
 }
 
+// A board addressed by [2]int{x, y} instead of by index.
+type pointBoard [][]int
+
+func (b pointBoard) Adjacent(p [2]int) ([][2]int, []float64) {
+  var adj [][2]int
+  var weight []float64
+  for _, d := range [][2]int{{-1, 0}, {0, -1}, {1, 0}, {0, 1}} {
+    x, y := p[0]+d[0], p[1]+d[1]
+    if x >= 0 && y >= 0 && y < len(b) && x < len(b[0]) && b[y][x] > 0 {
+      adj = append(adj, [2]int{x, y})
+      weight = append(weight, float64(b[y][x]))
+    }
+  }
+  return adj, weight
+}
+
+func TypedDijkstraSpec(c gospec.Context) {
+  b := [][]int{
+    []int{1, 2, 9, 4, 3, 2, 1},
+    []int{9, 2, 9, 4, 3, 1, 1},
+    []int{2, 1, 5, 5, 5, 2, 1},
+    []int{1, 1, 1, 1, 1, 1, 1},
+  }
+  c.Specify("Check Dijkstra's on a typed graph gives the right path and weight", func() {
+    weight, path := algorithm.TypedDijkstra[[2]int](pointBoard(b), [][2]int{{0, 0}}, [][2]int{{4, 1}})
+    c.Expect(weight, Equals, 16.0)
+    c.Expect(len(path), Equals, 12)
+    if len(path) == 12 {
+      c.Expect(path[0], Equals, [2]int{0, 0})
+      c.Expect(path[4], Equals, [2]int{1, 3})
+      c.Expect(path[11], Equals, [2]int{4, 1})
+    }
+  })
+  c.Specify("Check an unreachable destination on a typed graph", func() {
+    weight, path := algorithm.TypedDijkstra[[2]int](pointBoard(b), [][2]int{{0, 0}}, [][2]int{{9, 9}})
+    c.Expect(weight, Equals, -1.0)
+    c.Expect(len(path), Equals, 0)
+  })
+}
+
+func AStarSpec(c gospec.Context) {
+  b := [][]int{
+    []int{1, 2, 9, 4, 3, 2, 1}, // 0 - 6
+    []int{9, 2, 9, 4, 3, 1, 1}, // 7 - 13
+    []int{2, 1, 5, 5, 5, 2, 1}, // 14 - 20
+    []int{1, 1, 1, 1, 1, 1, 1}, // 21 - 27
+  }
+  // Every step costs at least 1, so the manhattan distance never overestimates.
+  manhattan := func(dst int) algorithm.Heuristic {
+    return func(v int) float64 {
+      dx := v%7 - dst%7
+      dy := v/7 - dst/7
+      if dx < 0 {
+        dx = -dx
+      }
+      if dy < 0 {
+        dy = -dy
+      }
+      return float64(dx + dy)
+    }
+  }
+  c.Specify("Check A* gives the same path and weight as Dijkstra's", func() {
+    weight, path := algorithm.AStar(board(b), []int{0}, []int{11}, manhattan(11))
+    c.Expect(weight, Equals, 16.0)
+    c.Expect(path, ContainsInOrder, []int{0, 1, 8, 15, 22, 23, 24, 25, 26, 19, 12, 11})
+  })
+  c.Specify("Check A* without a heuristic", func() {
+    weight, path := algorithm.AStar(board(b), []int{0, 1, 7, 2}, []int{11}, nil)
+    c.Expect(weight, Equals, 10.0)
+    c.Expect(path, ContainsInOrder, []int{2, 3, 4, 11})
+  })
+  c.Specify("Check A* with an unreachable destination", func() {
+    b[0][1], b[1][0] = 0, 0
+    weight, path := algorithm.AStar(board(b), []int{0}, []int{11}, manhattan(11))
+    c.Expect(weight, Equals, -1.0)
+    c.Expect(len(path), Equals, 0)
+  })
+}
+
+func PathCacheSpec(c gospec.Context) {
+  cache := algorithm.MakePathCache()
+  finds := 0
+  find := func() (float64, []int) {
+    finds++
+    return 2, []int{1, 2, 3}
+  }
+  c.Specify("Paths are only found once", func() {
+    weight, path := cache.Path(algorithm.PathKey{Start: 1, End: 3, Tag: "a"}, find)
+    c.Expect(weight, Equals, 2.0)
+    c.Expect(path, ContainsInOrder, []int{1, 2, 3})
+    path[0] = 5
+    weight, path = cache.Path(algorithm.PathKey{Start: 1, End: 3, Tag: "a"}, find)
+    c.Expect(path, ContainsInOrder, []int{1, 2, 3})
+    c.Expect(finds, Equals, 1)
+  })
+  c.Specify("Paths with different keys are found separately", func() {
+    cache.Path(algorithm.PathKey{Start: 1, End: 3, Tag: "a"}, find)
+    cache.Path(algorithm.PathKey{Start: 1, End: 3, Tag: "b"}, find)
+    cache.Path(algorithm.PathKey{Start: 1, End: 4, Tag: "a"}, find)
+    c.Expect(finds, Equals, 3)
+  })
+  c.Specify("Clear forgets all paths", func() {
+    cache.Path(algorithm.PathKey{Start: 1, End: 3, Tag: "a"}, find)
+    cache.Clear()
+    cache.Path(algorithm.PathKey{Start: 1, End: 3, Tag: "a"}, find)
+    c.Expect(finds, Equals, 2)
+  })
+}
+
 func ReachableSpec(c gospec.Context) {
   b := [][]int{
     []int{1, 2, 9, 4, 3, 2, 1}, // 0 - 6
@@ -226,3 +335,60 @@ func TopoSpec(c gospec.Context) {
   })
 }
 
+
+// A Graph where every edge has weight 1.
+type edgeList [][]int
+
+func (e edgeList) NumVertex() int {
+  return len(e)
+}
+func (e edgeList) Adjacent(n int) ([]int, []float64) {
+  weights := make([]float64, len(e[n]))
+  for i := range weights {
+    weights[i] = 1
+  }
+  return e[n], weights
+}
+
+func FindCyclesSpec(c gospec.Context) {
+  c.Specify("An acyclic graph has no cycles", func() {
+    e := edgeList{
+      []int{ 1, 2 },
+      []int{ 2 },
+      []int{ },
+    }
+    c.Expect(len(algorithm.FindCycles(e)), Equals, 0)
+    c.Expect(algorithm.TopoSortGraph(e), ContainsInOrder, []int{0, 1, 2})
+  })
+
+  c.Specify("Each loop is found", func() {
+    e := edgeList{
+      []int{ 1 },     // 0
+      []int{ 2, 3 },
+      []int{ 0 },
+      []int{ 4 },
+      []int{ 4, 5 },
+      []int{ 7 },     // 5
+      []int{ 5 },
+      []int{ 6 },
+    }
+    cycles := algorithm.FindCycles(e)
+    c.Assume(len(cycles), Equals, 3)
+    c.Expect(cycles[0], ContainsInOrder, []int{0, 1, 2})
+    c.Expect(cycles[1], ContainsInOrder, []int{4})
+    c.Expect(cycles[2], ContainsInOrder, []int{5, 7, 6})
+    c.Expect(len(algorithm.TopoSortGraph(e)), Equals, 0)
+  })
+
+  c.Specify("The shortest loop through the first vertex is found", func() {
+    e := edgeList{
+      []int{ 1, 3 },
+      []int{ 2 },
+      []int{ 3 },
+      []int{ 0 },
+    }
+    cycles := algorithm.FindCycles(e)
+    c.Assume(len(cycles), Equals, 1)
+    c.Expect(cycles[0], ContainsInOrder, []int{0, 3})
+  })
+}
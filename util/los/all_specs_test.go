@@ -0,0 +1,15 @@
+package los_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(LineSpec)
+	r.AddSpec(VisibleSpec)
+	r.AddSpec(FOVSpec)
+	r.AddSpec(ViewerSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,184 @@
+package los
+
+// Multipliers that map the first octant onto each of the eight octants.
+var octants = [8][4]int{
+	{1, 0, 0, 1},
+	{0, 1, 1, 0},
+	{0, -1, 1, 0},
+	{-1, 0, 0, 1},
+	{-1, 0, 0, -1},
+	{0, -1, -1, 0},
+	{0, 1, -1, 0},
+	{1, 0, 0, -1},
+}
+
+// Calls mark for every cell within radius of x, y that can be seen from it,
+// including x, y itself, or nothing if x, y is off of the grid.  Cells can be
+// marked more than once.  Uses recursive shadow casting, one octant at a time.
+func (g *Grid) fov(x, y, radius int, mark func(x, y int)) {
+	if !g.InBounds(x, y) {
+		return
+	}
+	mark(x, y)
+	for _, m := range octants {
+		g.castLight(x, y, 1, 1.0, 0.0, radius, m, mark)
+	}
+}
+
+// Scans rows of an octant starting at row, where each row is further from
+// cx, cy than the last.  start and end are the slopes of the part of the
+// octant that is still lit, every opaque cell splits off a recursive scan
+// for the lit part beside it and narrows the rest.
+func (g *Grid) castLight(cx, cy, row int, start, end float64, radius int, m [4]int, mark func(x, y int)) {
+	if start < end {
+		return
+	}
+	new_start := 0.0
+	for j := row; j <= radius; j++ {
+		blocked := false
+		dy := -j
+		for dx := -j; dx <= 0; dx++ {
+			x := cx + dx*m[0] + dy*m[1]
+			y := cy + dx*m[2] + dy*m[3]
+			l_slope := (float64(dx) - 0.5) / (float64(dy) + 0.5)
+			r_slope := (float64(dx) + 0.5) / (float64(dy) - 0.5)
+			if start < r_slope {
+				continue
+			}
+			if end > l_slope {
+				break
+			}
+			if dx*dx+dy*dy <= radius*radius && g.InBounds(x, y) {
+				mark(x, y)
+			}
+			opaque := g.Opaque(x, y)
+			if blocked {
+				if opaque {
+					new_start = r_slope
+					continue
+				}
+				blocked = false
+				start = new_start
+			} else if opaque && j < radius {
+				blocked = true
+				g.castLight(cx, cy, j+1, start, l_slope, radius, m, mark)
+				new_start = r_slope
+			}
+		}
+		if blocked {
+			break
+		}
+	}
+}
+
+// Returns all of the cells within radius of x, y that can be seen from x, y.
+// Opaque cells are included if they can be seen, so walls show up.
+func (g *Grid) FOV(x, y, radius int) [][2]int {
+	v := MakeViewer(g, x, y, radius)
+	return v.Cells()
+}
+
+// A Viewer caches the field of view from one cell and keeps it up to date,
+// only recomputing it when the viewer moves or when a cell within its radius
+// changes.
+type Viewer struct {
+	grid   *Grid
+	x, y   int
+	radius int
+
+	// visible covers the square of side 2*radius+1 centered on x, y.
+	visible []bool
+	cells   [][2]int
+	version int
+	valid   bool
+}
+
+func MakeViewer(grid *Grid, x, y, radius int) *Viewer {
+	if radius < 0 {
+		radius = 0
+	}
+	return &Viewer{grid: grid, x: x, y: y, radius: radius}
+}
+
+// Moves the viewer to x, y.
+func (v *Viewer) SetPosition(x, y int) {
+	if x != v.x || y != v.y {
+		v.x, v.y = x, y
+		v.valid = false
+	}
+}
+
+func (v *Viewer) SetRadius(radius int) {
+	if radius < 0 {
+		radius = 0
+	}
+	if radius != v.radius {
+		v.radius = radius
+		v.valid = false
+	}
+}
+
+// Returns true if nothing within the viewer's radius has changed since it
+// last computed its field of view.
+func (v *Viewer) current() bool {
+	if !v.valid {
+		return false
+	}
+	if v.version == v.grid.version {
+		return true
+	}
+	changes := v.grid.changes
+	if len(changes) == 0 || changes[0].version > v.version+1 {
+		// Some of the changes since our last update have been forgotten.
+		return false
+	}
+	for i := len(changes) - 1; i >= 0 && changes[i].version > v.version; i-- {
+		dx, dy := changes[i].x-v.x, changes[i].y-v.y
+		if dx >= -v.radius && dx <= v.radius && dy >= -v.radius && dy <= v.radius {
+			return false
+		}
+	}
+	v.version = v.grid.version
+	return true
+}
+
+func (v *Viewer) update() {
+	if v.current() {
+		return
+	}
+	side := 2*v.radius + 1
+	if len(v.visible) != side*side {
+		v.visible = make([]bool, side*side)
+	} else {
+		for i := range v.visible {
+			v.visible[i] = false
+		}
+	}
+	v.cells = v.cells[:0]
+	v.grid.fov(v.x, v.y, v.radius, func(x, y int) {
+		i := (x - v.x + v.radius) + (y-v.y+v.radius)*side
+		if !v.visible[i] {
+			v.visible[i] = true
+			v.cells = append(v.cells, [2]int{x, y})
+		}
+	})
+	v.version = v.grid.version
+	v.valid = true
+}
+
+// Returns true if the cell at x, y can be seen by the viewer.
+func (v *Viewer) Visible(x, y int) bool {
+	v.update()
+	dx, dy := x-v.x, y-v.y
+	if dx < -v.radius || dx > v.radius || dy < -v.radius || dy > v.radius {
+		return false
+	}
+	return v.visible[(dx+v.radius)+(dy+v.radius)*(2*v.radius+1)]
+}
+
+// Returns all of the cells that the viewer can see.  The returned slice must
+// not be modified, and is only valid until the viewer is next updated.
+func (v *Viewer) Cells() [][2]int {
+	v.update()
+	return v.cells
+}
@@ -0,0 +1,121 @@
+// Package los answers line of sight and field of view questions on 2d grids
+// of cells that are either opaque or not.  It uses the same cell coordinates
+// as util/pathing, so a game can keep a los.Grid and a pathing.Grid side by
+// side for the same map.
+package los
+
+import (
+	"fmt"
+)
+
+// How many changes a Grid remembers.  A Viewer that was last updated before
+// the oldest remembered change recomputes its field of view from scratch.
+const changeHistory = 1024
+
+type change struct {
+	x, y    int
+	version int
+}
+
+// A Grid is a dx by dy grid of cells, each of which is opaque or not.
+type Grid struct {
+	dx, dy int
+	opaque []bool
+
+	// Every change to a cell bumps version, the last few changes are kept in
+	// changes so that Viewers can tell if any of them are in their view.
+	version int
+	changes []change
+}
+
+// Makes a grid where every cell is transparent.
+func MakeGrid(dx, dy int) *Grid {
+	if dx <= 0 || dy <= 0 {
+		panic(fmt.Sprintf("Cannot make a %dx%d grid.", dx, dy))
+	}
+	return &Grid{
+		dx:     dx,
+		dy:     dy,
+		opaque: make([]bool, dx*dy),
+	}
+}
+
+func (g *Grid) Dims() (dx, dy int) {
+	return g.dx, g.dy
+}
+
+func (g *Grid) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.dx && y < g.dy
+}
+
+// Returns true if the cell at x, y blocks sight.  Cells off of the grid are
+// always opaque.
+func (g *Grid) Opaque(x, y int) bool {
+	return !g.InBounds(x, y) || g.opaque[x+y*g.dx]
+}
+
+func (g *Grid) SetOpaque(x, y int, opaque bool) {
+	if !g.InBounds(x, y) {
+		panic(fmt.Sprintf("(%d, %d) is not on a %dx%d grid.", x, y, g.dx, g.dy))
+	}
+	if g.opaque[x+y*g.dx] == opaque {
+		return
+	}
+	g.opaque[x+y*g.dx] = opaque
+	g.version++
+	if len(g.changes) == changeHistory {
+		copy(g.changes, g.changes[changeHistory/2:])
+		g.changes = g.changes[:changeHistory/2]
+	}
+	g.changes = append(g.changes, change{x, y, g.version})
+}
+
+// Returns true if there is a clear line of sight from x0, y0 to x1, y1.  Only
+// the cells between the two ends matter, so a wall can be seen even though
+// it is opaque.
+func (g *Grid) Visible(x0, y0, x1, y1 int) bool {
+	line := Line(x0, y0, x1, y1)
+	if len(line) <= 2 {
+		return true
+	}
+	for _, c := range line[1 : len(line)-1] {
+		if g.Opaque(c[0], c[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the cells on the line from x0, y0 to x1, y1 including both ends,
+// using Bresenham's algorithm.
+func Line(x0, y0, x1, y1 int) [][2]int {
+	dx, sx := x1-x0, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	dy, sy := y1-y0, 1
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	n := dx
+	if dy > n {
+		n = dy
+	}
+	line := make([][2]int, 0, n+1)
+	err := dx - dy
+	for {
+		line = append(line, [2]int{x0, y0})
+		if x0 == x1 && y0 == y1 {
+			return line
+		}
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package los_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/util/los"
+)
+
+// Makes a grid from rows of text, where '#' is opaque.
+func makeGrid(rows ...string) *los.Grid {
+	g := los.MakeGrid(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			g.SetOpaque(x, y, c == '#')
+		}
+	}
+	return g
+}
+
+func LineSpec(c gospec.Context) {
+	c.Specify("Lines include both ends", func() {
+		c.Expect(los.Line(1, 1, 1, 1), ContainsInOrder, [][2]int{{1, 1}})
+		c.Expect(los.Line(0, 0, 3, 0), ContainsInOrder, [][2]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}})
+	})
+	c.Specify("Lines step one cell at a time in every direction", func() {
+		for _, end := range [][2]int{{5, 2}, {-5, 2}, {2, -5}, {-2, -5}, {3, 3}} {
+			line := los.Line(0, 0, end[0], end[1])
+			c.Expect(line[len(line)-1], Equals, end)
+			for i := 1; i < len(line); i++ {
+				dx := line[i][0] - line[i-1][0]
+				dy := line[i][1] - line[i-1][1]
+				c.Expect(dx*dx <= 1 && dy*dy <= 1, IsTrue)
+			}
+		}
+	})
+}
+
+func VisibleSpec(c gospec.Context) {
+	g := makeGrid(
+		".....",
+		"..#..",
+		".....",
+	)
+	c.Specify("Opaque cells block sight", func() {
+		c.Expect(g.Visible(0, 1, 4, 1), Equals, false)
+		c.Expect(g.Visible(0, 0, 4, 0), IsTrue)
+		c.Expect(g.Visible(2, 0, 2, 2), Equals, false)
+	})
+	c.Specify("Opaque cells can be seen themselves", func() {
+		c.Expect(g.Visible(0, 1, 2, 1), IsTrue)
+		c.Expect(g.Visible(2, 1, 2, 1), IsTrue)
+	})
+}
+
+func FOVSpec(c gospec.Context) {
+	g := makeGrid(
+		".......",
+		".......",
+		"...#...",
+		".......",
+		".......",
+	)
+	cells := make(map[[2]int]bool)
+	for _, cell := range g.FOV(3, 4, 10) {
+		cells[cell] = true
+	}
+	c.Specify("The viewer's own cell and walls are visible", func() {
+		c.Expect(cells[[2]int{3, 4}], IsTrue)
+		c.Expect(cells[[2]int{3, 2}], IsTrue)
+	})
+	c.Specify("Cells behind walls are not visible", func() {
+		c.Expect(cells[[2]int{3, 1}], Equals, false)
+		c.Expect(cells[[2]int{3, 0}], Equals, false)
+	})
+	c.Specify("Everything else is visible", func() {
+		c.Expect(cells[[2]int{0, 0}], IsTrue)
+		c.Expect(cells[[2]int{6, 0}], IsTrue)
+		c.Expect(cells[[2]int{0, 4}], IsTrue)
+	})
+	c.Specify("Radius limits the field of view", func() {
+		c.Expect(len(g.FOV(3, 4, 0)), Equals, 1)
+		for _, cell := range g.FOV(0, 0, 2) {
+			c.Expect(cell[0]*cell[0]+cell[1]*cell[1] <= 4, IsTrue)
+		}
+	})
+}
+
+func ViewerSpec(c gospec.Context) {
+	g := makeGrid(
+		".......",
+		".......",
+		".......",
+		".......",
+		".......",
+	)
+	v := los.MakeViewer(g, 3, 4, 10)
+	c.Expect(v.Visible(3, 0), IsTrue)
+	c.Specify("Viewers update when cells in range change", func() {
+		g.SetOpaque(3, 2, true)
+		c.Expect(v.Visible(3, 0), Equals, false)
+		g.SetOpaque(3, 2, false)
+		c.Expect(v.Visible(3, 0), IsTrue)
+	})
+	c.Specify("Viewers don't recompute for changes out of range", func() {
+		near := los.MakeViewer(g, 0, 0, 3)
+		cells := near.Cells()
+		g.SetOpaque(6, 4, true)
+		c.Expect(&near.Cells()[0], Equals, &cells[0])
+		g.SetOpaque(1, 1, true)
+		c.Expect(near.Visible(1, 1), IsTrue)
+		c.Expect(near.Visible(2, 2), Equals, false)
+	})
+	c.Specify("Viewers update when they move", func() {
+		g.SetOpaque(3, 2, true)
+		c.Expect(v.Visible(3, 0), Equals, false)
+		v.SetPosition(2, 4)
+		c.Expect(v.Visible(3, 0), IsTrue)
+	})
+}
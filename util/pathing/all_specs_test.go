@@ -0,0 +1,14 @@
+package pathing_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(GridSpec)
+	r.AddSpec(RegionSpec)
+	r.AddSpec(PlannerSpec)
+	gospec.MainGoTest(r, t)
+}
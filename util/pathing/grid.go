@@ -0,0 +1,241 @@
+// Package pathing finds paths on 2d grids of cells with terrain costs, which
+// is what most tile based and tactics games need.  A Grid is an
+// algorithm.Graph, so anything in util/algorithm can also be used on it.
+package pathing
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/util/algorithm"
+	"math"
+)
+
+// A cost less than 0 means that a cell can't be entered.
+const Impassable = -1
+
+// A Grid is a dx by dy grid of cells, each with a cost for moving into it.
+// Moving into a cell diagonally costs sqrt(2) times as much, and diagonal
+// moves aren't allowed to cut the corner of an impassable cell.  Cells are
+// numbered x + y*dx when a Grid is used as an algorithm.Graph.
+type Grid struct {
+	dx, dy   int
+	diagonal bool
+	costs    []float64
+
+	// Every change to a cell bumps version.  changed[v] is the version when
+	// cell v last changed and cheaper is the version when any cell's cost last
+	// went down, Planners use these to tell whether their paths are stale.
+	version int
+	changed []int
+	cheaper int
+
+	// Connected regions, see Region(), recomputed when needed after a change.
+	regions        []int
+	region_version int
+}
+
+// Makes a grid where every cell has a cost of 1.  If diagonal is true then
+// each cell connects to its 8 neighbors, otherwise only to the 4 that share
+// an edge with it.
+func MakeGrid(dx, dy int, diagonal bool) *Grid {
+	if dx <= 0 || dy <= 0 {
+		panic(fmt.Sprintf("Cannot make a %dx%d grid.", dx, dy))
+	}
+	g := &Grid{
+		dx:             dx,
+		dy:             dy,
+		diagonal:       diagonal,
+		costs:          make([]float64, dx*dy),
+		changed:        make([]int, dx*dy),
+		region_version: -1,
+	}
+	for i := range g.costs {
+		g.costs[i] = 1
+	}
+	return g
+}
+
+func (g *Grid) Dims() (dx, dy int) {
+	return g.dx, g.dy
+}
+
+func (g *Grid) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.dx && y < g.dy
+}
+
+// Returns the vertex for the cell at x, y.
+func (g *Grid) Vertex(x, y int) int {
+	if !g.InBounds(x, y) {
+		panic(fmt.Sprintf("(%d, %d) is not on a %dx%d grid.", x, y, g.dx, g.dy))
+	}
+	return x + y*g.dx
+}
+
+// Returns the cell for vertex v.
+func (g *Grid) Cell(v int) (x, y int) {
+	return v % g.dx, v / g.dx
+}
+
+func (g *Grid) Cost(x, y int) float64 {
+	return g.costs[g.Vertex(x, y)]
+}
+
+// Sets the cost of moving into the cell at x, y, use Impassable for cells
+// that can't be entered.
+func (g *Grid) SetCost(x, y int, cost float64) {
+	v := g.Vertex(x, y)
+	if cost < 0 {
+		cost = Impassable
+	}
+	if g.costs[v] == cost {
+		return
+	}
+	g.version++
+	if cost >= 0 && (g.costs[v] < 0 || cost < g.costs[v]) {
+		g.cheaper = g.version
+	}
+	g.costs[v] = cost
+	g.changed[v] = g.version
+}
+
+func (g *Grid) Passable(x, y int) bool {
+	return g.InBounds(x, y) && g.costs[x+y*g.dx] >= 0
+}
+
+func (g *Grid) NumVertex() int {
+	return g.dx * g.dy
+}
+
+var neighbors = [][2]int{
+	{1, 0}, {0, 1}, {-1, 0}, {0, -1},
+	{1, 1}, {-1, 1}, {-1, -1}, {1, -1},
+}
+
+func (g *Grid) Adjacent(v int) (adj []int, cost []float64) {
+	x, y := g.Cell(v)
+	n := 4
+	if g.diagonal {
+		n = 8
+	}
+	for _, d := range neighbors[:n] {
+		nx, ny := x+d[0], y+d[1]
+		if !g.Passable(nx, ny) {
+			continue
+		}
+		c := g.costs[nx+ny*g.dx]
+		if d[0] != 0 && d[1] != 0 {
+			if !g.Passable(x+d[0], y) || !g.Passable(x, y+d[1]) {
+				continue
+			}
+			c *= math.Sqrt2
+		}
+		adj = append(adj, nx+ny*g.dx)
+		cost = append(cost, c)
+	}
+	return
+}
+
+// Returns a heuristic for AStar that never overestimates the cost of getting
+// to x, y.
+func (g *Grid) heuristic(x, y int) algorithm.Heuristic {
+	min := math.Inf(1)
+	for _, c := range g.costs {
+		if c >= 0 && c < min {
+			min = c
+		}
+	}
+	if math.IsInf(min, 1) {
+		min = 0
+	}
+	return func(v int) float64 {
+		vx, vy := g.Cell(v)
+		dx := math.Abs(float64(vx - x))
+		dy := math.Abs(float64(vy - y))
+		if !g.diagonal {
+			return min * (dx + dy)
+		}
+		// Octile distance
+		return min * (math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy))
+	}
+}
+
+// Returns the cheapest path from x0, y0 to x1, y1 and its cost, not counting
+// the starting cell.  The path includes both ends.  Returns -1 and nil if
+// there is no path.
+func (g *Grid) Path(x0, y0, x1, y1 int) (float64, [][2]int) {
+	if !g.Passable(x1, y1) || !g.InBounds(x0, y0) || !g.Connected(x0, y0, x1, y1) {
+		return -1, nil
+	}
+	cost, path := algorithm.AStar(g, []int{g.Vertex(x0, y0)}, []int{g.Vertex(x1, y1)}, g.heuristic(x1, y1))
+	if path == nil {
+		return -1, nil
+	}
+	cells := make([][2]int, len(path))
+	for i, v := range path {
+		cells[i][0], cells[i][1] = g.Cell(v)
+	}
+	return cost, cells
+}
+
+// Returns all of the cells that can be reached from x, y for a total cost of
+// at most limit, including x, y itself.
+func (g *Grid) Reachable(x, y int, limit float64) [][2]int {
+	var cells [][2]int
+	for _, v := range algorithm.ReachableWithinLimit(g, []int{g.Vertex(x, y)}, limit) {
+		var cell [2]int
+		cell[0], cell[1] = g.Cell(v)
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+// Returns a number identifying the region that the cell at x, y is in.  Two
+// cells are in the same region if and only if there is a path between them.
+// Impassable cells are in region -1.
+func (g *Grid) Region(x, y int) int {
+	v := g.Vertex(x, y)
+	if g.region_version != g.version {
+		g.findRegions()
+	}
+	return g.regions[v]
+}
+
+// Returns true iff there is a path from x0, y0 to x1, y1.  This is much
+// faster than finding the path.
+func (g *Grid) Connected(x0, y0, x1, y1 int) bool {
+	r := g.Region(x0, y0)
+	return r != -1 && r == g.Region(x1, y1)
+}
+
+// Labels every cell with its region by flood filling from each passable cell
+// that doesn't have a region yet.  Costs don't matter here, only whether cells
+// can be entered, and that is symmetric between neighbors.
+func (g *Grid) findRegions() {
+	if g.regions == nil {
+		g.regions = make([]int, g.dx*g.dy)
+	}
+	for i := range g.regions {
+		g.regions[i] = -1
+	}
+	next := 0
+	var stack []int
+	for v := range g.regions {
+		if g.regions[v] != -1 || g.costs[v] < 0 {
+			continue
+		}
+		g.regions[v] = next
+		stack = append(stack[:0], v)
+		for len(stack) > 0 {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			adj, _ := g.Adjacent(cur)
+			for _, a := range adj {
+				if g.regions[a] == -1 {
+					g.regions[a] = next
+					stack = append(stack, a)
+				}
+			}
+		}
+		next++
+	}
+	g.region_version = g.version
+}
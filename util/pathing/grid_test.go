@@ -0,0 +1,143 @@
+package pathing_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/util/algorithm"
+	"github.com/runningwild/glop/util/pathing"
+	"math"
+)
+
+// Makes a grid from rows of costs, where 0 is impassable.
+func makeGrid(rows [][]int, diagonal bool) *pathing.Grid {
+	g := pathing.MakeGrid(len(rows[0]), len(rows), diagonal)
+	for y := range rows {
+		for x, c := range rows[y] {
+			if c == 0 {
+				g.SetCost(x, y, pathing.Impassable)
+			} else {
+				g.SetCost(x, y, float64(c))
+			}
+		}
+	}
+	return g
+}
+
+func GridSpec(c gospec.Context) {
+	rows := [][]int{
+		{1, 1, 1, 1, 1},
+		{1, 0, 0, 0, 1},
+		{1, 1, 9, 1, 1},
+	}
+	c.Specify("A grid is an algorithm.Graph", func() {
+		var g algorithm.Graph = makeGrid(rows, false)
+		c.Expect(g.NumVertex(), Equals, 15)
+		weight, path := algorithm.Dijkstra(g, []int{10}, []int{14})
+		c.Expect(weight, Equals, 8.0)
+		c.Expect(path, ContainsInOrder, []int{10, 5, 0, 1, 2, 3, 4, 9, 14})
+	})
+	c.Specify("Paths avoid expensive and impassable cells", func() {
+		g := makeGrid(rows, false)
+		cost, path := g.Path(0, 2, 4, 2)
+		c.Expect(cost, Equals, 8.0)
+		c.Expect(len(path), Equals, 9)
+		c.Expect(path[0], Equals, [2]int{0, 2})
+		c.Expect(path[8], Equals, [2]int{4, 2})
+		g.SetCost(2, 2, 2)
+		cost, path = g.Path(0, 2, 4, 2)
+		c.Expect(cost, Equals, 5.0)
+		c.Expect(path[2], Equals, [2]int{2, 2})
+	})
+	c.Specify("Diagonal moves cost more and don't cut corners", func() {
+		g := makeGrid([][]int{
+			{1, 1, 1},
+			{1, 1, 1},
+			{1, 1, 1},
+		}, true)
+		cost, path := g.Path(0, 0, 2, 2)
+		c.Expect(math.Abs(cost-2*math.Sqrt2) < 1e-9, IsTrue)
+		c.Expect(len(path), Equals, 3)
+		g.SetCost(1, 0, pathing.Impassable)
+		cost, path = g.Path(0, 0, 1, 1)
+		c.Expect(cost, Equals, 2.0)
+		c.Expect(path, ContainsInOrder, [][2]int{{0, 0}, {0, 1}, {1, 1}})
+	})
+	c.Specify("No path gives a cost of -1", func() {
+		g := makeGrid(rows, false)
+		g.SetCost(0, 1, pathing.Impassable)
+		g.SetCost(4, 1, pathing.Impassable)
+		cost, path := g.Path(0, 0, 0, 2)
+		c.Expect(cost, Equals, -1.0)
+		c.Expect(len(path), Equals, 0)
+	})
+	c.Specify("Reachable respects the cost limit", func() {
+		g := makeGrid(rows, false)
+		c.Expect(len(g.Reachable(0, 0, 2)), Equals, 5)
+		c.Expect(len(g.Reachable(0, 2, 1)), Equals, 3)
+	})
+}
+
+func RegionSpec(c gospec.Context) {
+	g := makeGrid([][]int{
+		{1, 1, 0, 1},
+		{1, 1, 0, 1},
+		{0, 0, 0, 1},
+	}, true)
+	c.Specify("Cells are in the same region iff they are connected", func() {
+		c.Expect(g.Connected(0, 0, 1, 1), IsTrue)
+		c.Expect(g.Connected(0, 0, 3, 2), Equals, false)
+		c.Expect(g.Region(2, 0), Equals, -1)
+		c.Expect(g.Connected(2, 0, 2, 0), Equals, false)
+	})
+	c.Specify("Regions are updated when cells change", func() {
+		g.SetCost(2, 1, 1)
+		c.Expect(g.Connected(0, 0, 3, 2), IsTrue)
+		g.SetCost(2, 1, pathing.Impassable)
+		c.Expect(g.Connected(0, 0, 3, 2), Equals, false)
+	})
+	c.Specify("Diagonals that cut corners don't connect regions", func() {
+		g.SetCost(2, 1, 1)
+		g.SetCost(3, 1, pathing.Impassable)
+		c.Expect(g.Connected(2, 1, 3, 2), Equals, false)
+		g.SetCost(3, 1, 1)
+		c.Expect(g.Connected(2, 1, 3, 2), IsTrue)
+	})
+}
+
+func PlannerSpec(c gospec.Context) {
+	g := makeGrid([][]int{
+		{1, 1, 1, 1, 1},
+		{1, 1, 1, 1, 1},
+		{1, 1, 1, 1, 1},
+	}, false)
+	p := pathing.MakePlanner(g, 0, 1, 4, 1)
+	cost, path := p.Path()
+	c.Expect(cost, Equals, 4.0)
+	c.Specify("The planner matches Grid.Path after changes", func() {
+		g.SetCost(2, 1, pathing.Impassable)
+		cost, path = p.Path()
+		c.Expect(cost, Equals, 6.0)
+		gcost, _ := g.Path(0, 1, 4, 1)
+		c.Expect(cost, Equals, gcost)
+		g.SetCost(2, 1, 1)
+		cost, _ = p.Path()
+		c.Expect(cost, Equals, 4.0)
+	})
+	c.Specify("Changes away from the path don't force a new search", func() {
+		g.SetCost(2, 0, 5)
+		g.SetCost(2, 2, pathing.Impassable)
+		_, again := p.Path()
+		c.Expect(&again[0], Equals, &path[0])
+	})
+	c.Specify("Moving the start along the path keeps the rest of it", func() {
+		p.SetStart(2, 1)
+		cost, again := p.Path()
+		c.Expect(cost, Equals, 2.0)
+		c.Expect(again[0], Equals, [2]int{2, 1})
+		c.Expect(&again[0], Equals, &path[2])
+		p.SetStart(2, 0)
+		cost, again = p.Path()
+		c.Expect(cost, Equals, 3.0)
+		c.Expect(again[0], Equals, [2]int{2, 0})
+	})
+}
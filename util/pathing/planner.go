@@ -0,0 +1,88 @@
+package pathing
+
+import "math"
+
+// A Planner keeps a path from a start cell to a goal up to date as the grid
+// changes, only searching the grid again when a change could have affected
+// the path.  This suits units that move along a path over many turns while
+// other units and terrain change around them.
+type Planner struct {
+	grid       *Grid
+	goal       [2]int
+	start      [2]int
+	cost       float64
+	path       [][2]int
+	has        bool
+	planned_at int // the grid version the path was planned at
+}
+
+func MakePlanner(grid *Grid, x0, y0, x1, y1 int) *Planner {
+	return &Planner{
+		grid:  grid,
+		start: [2]int{x0, y0},
+		goal:  [2]int{x1, y1},
+	}
+}
+
+// Moves the start of the path to x, y.  If x, y is on the current path the
+// part of the path before it is dropped without searching again.
+func (p *Planner) SetStart(x, y int) {
+	cell := [2]int{x, y}
+	if cell == p.start {
+		return
+	}
+	p.start = cell
+	for i, c := range p.path {
+		if c == cell {
+			// The rest of an optimal path is an optimal path from any cell on it.
+			p.cost -= p.pathCost(p.path[:i+1])
+			p.path = p.path[i:]
+			return
+		}
+	}
+	p.has = false
+}
+
+// Returns the total cost of moving along path, not counting the first cell.
+func (p *Planner) pathCost(path [][2]int) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		c := p.grid.Cost(path[i][0], path[i][1])
+		if path[i][0] != path[i-1][0] && path[i][1] != path[i-1][1] {
+			c *= math.Sqrt2
+		}
+		total += c
+	}
+	return total
+}
+
+// Returns true if a change to the grid since the path was planned could
+// have made it wrong.  Cells getting more expensive off of the path can't
+// make any other path better than this one, so only cells on the path getting
+// more expensive or any cell anywhere getting cheaper matters.
+func (p *Planner) stale() bool {
+	if !p.has {
+		return true
+	}
+	if p.grid.cheaper > p.planned_at {
+		return true
+	}
+	for _, c := range p.path {
+		if p.grid.changed[p.grid.Vertex(c[0], c[1])] > p.planned_at {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the current path from the start to the goal and its cost, the same
+// as Grid.Path() would, searching again only if it needs to.  The returned
+// path must not be modified.
+func (p *Planner) Path() (float64, [][2]int) {
+	if p.stale() {
+		p.cost, p.path = p.grid.Path(p.start[0], p.start[1], p.goal[0], p.goal[1])
+		p.has = true
+		p.planned_at = p.grid.version
+	}
+	return p.cost, p.path
+}